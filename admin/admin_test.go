@@ -0,0 +1,131 @@
+package admin
+
+import (
+	"crypto/ecdsa"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/crypto"
+	"github.com/babbleio/babble/net"
+	"github.com/babbleio/babble/node"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func newKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	key, err := crypto.GenerateECDSAKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, crypto.PubKeyHex(&key.PublicKey)
+}
+
+func TestAdmin(t *testing.T) {
+	nodeKey, nodePub := newKey(t)
+	operatorKey, operatorPub := newKey(t)
+	strangerKey, strangerPub := newKey(t)
+
+	peers := []net.Peer{{NetAddr: "127.0.0.1:9970", PubKeyHex: nodePub}}
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+	conf := node.TestConfig(t)
+	n := node.NewNode(conf, crypto.NewECDSAKeyPair(nodeKey), peers, trans, aproxy.NewInmemAppProxy(conf.Logger))
+	if err := n.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	server, err := NewServer("127.0.0.1:0", &n, nodeKey, []string{operatorPub}, dir, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer server.Close()
+	go server.Serve()
+
+	client, err := Dial(server.Addr(), operatorKey, nodePub, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	status, err := client.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.PubKey != nodePub {
+		t.Fatalf("Status should describe the node, got %+v", status)
+	}
+	stats, err := client.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats["state"] != n.State().String() {
+		t.Fatalf("Stats should report state %s, got %s", n.State(), stats["state"])
+	}
+	if _, err := client.Jobs(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Reload(node.Reloadable{SyncLimit: 50}); err != nil {
+		t.Fatal(err)
+	}
+	if stats, _ := client.Stats(); stats["sync_limit"] != "50" {
+		t.Fatalf("Reload should set the sync limit to 50, got %s", stats["sync_limit"])
+	}
+	if err := client.Reload(node.Reloadable{SyncLimit: conf.CacheSize + 1}); err == nil {
+		t.Fatal("Reload to an invalid Config should fail")
+	}
+	if err := client.Suspend(); err != nil {
+		t.Fatal(err)
+	}
+	if n.State() != node.Suspended {
+		t.Fatalf("Suspend should suspend the node, not leave it %s", n.State())
+	}
+	if err := client.Suspend(); err == nil {
+		t.Fatal("Suspending a suspended node should fail")
+	}
+	if err := client.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if n.State() != node.Babbling {
+		t.Fatalf("Resume should move the node back to Babbling, not %s", n.State())
+	}
+	if err := client.Evict("0xUNKNOWN"); err == nil {
+		t.Fatal("Evicting a key that is not a participant should fail")
+	}
+	if err := client.Admit("0xUNKNOWN"); err == nil {
+		t.Fatal("Admitting an invalid key should fail")
+	}
+	if err := client.Admit(strangerPub); err != nil {
+		t.Fatal(err)
+	}
+
+	//errors of the node are passed on: there is no Frame to back up before
+	//the first consensus round
+	if err := client.Backup(ioutil.Discard); err == nil {
+		t.Fatal("Backup before consensus should fail")
+	}
+	if _, err := client.Prune(); err == nil || err.Error() != node.ErrNotPrunable.Error() {
+		t.Fatalf("Pruning the inmem store should fail with %q, got %v", node.ErrNotPrunable, err)
+	}
+
+	//the node only accepts its operators
+	if stranger, err := Dial(server.Addr(), strangerKey, nodePub, time.Second); err == nil {
+		defer stranger.Close()
+		if _, err := stranger.Status(); err == nil {
+			t.Fatal("Unknown operator key should be rejected")
+		}
+	}
+
+	//and operators only talk to the node they expect
+	if _, err := Dial(server.Addr(), operatorKey, operatorPub, time.Second); err == nil {
+		t.Fatal("Node with an unexpected key should be rejected")
+	}
+}
@@ -0,0 +1,116 @@
+package admin
+
+import (
+	gocrypto "crypto"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"time"
+
+	bnet "github.com/babbleio/babble/net"
+	"github.com/babbleio/babble/node"
+)
+
+//Client is the operator end of the admin channel
+type Client struct {
+	rpcClient *rpc.Client
+}
+
+//Dial connects to the admin channel at addr with the operator key key, and
+//checks that the node presents the public key nodeKey
+func Dial(addr string, key gocrypto.Signer, nodeKey string, timeout time.Duration) (*Client, error) {
+	tlsConfig, err := bnet.PeerTLSConfig(key, func(pubKey string) bool {
+		return pubKey == nodeKey
+	})
+	if err != nil {
+		return nil, err
+	}
+	dialer := net.Dialer{Timeout: timeout}
+	conn, err := tls.DialWithDialer(&dialer, "tcp", addr, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &Client{rpcClient: jsonrpc.NewClient(conn)}, nil
+}
+
+func (c *Client) Close() error {
+	return c.rpcClient.Close()
+}
+
+func (c *Client) Status() (node.Status, error) {
+	var status node.Status
+	err := c.rpcClient.Call("Admin.Status", Empty{}, &status)
+	return status, err
+}
+
+func (c *Client) Stats() (map[string]string, error) {
+	var stats map[string]string
+	err := c.rpcClient.Call("Admin.Stats", Empty{}, &stats)
+	return stats, err
+}
+
+func (c *Client) Evict(pubKey string) error {
+	return c.rpcClient.Call("Admin.Evict", pubKey, &Empty{})
+}
+
+func (c *Client) Admit(pubKey string) error {
+	return c.rpcClient.Call("Admin.Admit", pubKey, &Empty{})
+}
+
+//Reload changes the parameters of r that are set on the node
+func (c *Client) Reload(r node.Reloadable) error {
+	return c.rpcClient.Call("Admin.Reload", r, &Empty{})
+}
+
+func (c *Client) Suspend() error {
+	return c.rpcClient.Call("Admin.Suspend", Empty{}, &Empty{})
+}
+
+func (c *Client) Resume() error {
+	return c.rpcClient.Call("Admin.Resume", Empty{}, &Empty{})
+}
+
+//Diagnostics returns the path of the bundle written on the node
+func (c *Client) Diagnostics() (string, error) {
+	var path string
+	err := c.rpcClient.Call("Admin.Diagnostics", Empty{}, &path)
+	return path, err
+}
+
+func (c *Client) Jobs() ([]node.JobStatus, error) {
+	var jobs []node.JobStatus
+	err := c.rpcClient.Call("Admin.Jobs", Empty{}, &jobs)
+	return jobs, err
+}
+
+//Backup takes a backup of the node and writes it to w, in the format read by
+//node.ReadSnapshot
+func (c *Client) Backup(w io.Writer) error {
+	args := BackupArgs{}
+	for {
+		var chunk BackupChunk
+		if err := c.rpcClient.Call("Admin.Backup", args, &chunk); err != nil {
+			return err
+		}
+		if _, err := w.Write(chunk.Data); err != nil {
+			return err
+		}
+		args.ID = chunk.ID
+		args.Offset += int64(len(chunk.Data))
+		if args.Offset >= chunk.Size {
+			return nil
+		}
+		if len(chunk.Data) == 0 {
+			return io.ErrUnexpectedEOF
+		}
+	}
+}
+
+//Prune prunes the store of the node. Round is -1 if nothing was pruned.
+func (c *Client) Prune() (node.PruneResult, error) {
+	var res node.PruneResult
+	err := c.rpcClient.Call("Admin.Prune", Empty{}, &res)
+	return res, err
+}
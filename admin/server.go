@@ -0,0 +1,282 @@
+/*
+Package admin implements the admin channel of a Babble node: an RPC server,
+separate from the public HTTP service, through which operators manage peers,
+collect diagnostics and take backups of a remote node.
+
+Both ends are authenticated with TLS on babble keys, the same way participants
+authenticate each other when gossiping over TLS. The node only accepts the keys
+of its operators, and operators only talk to the node whose key they expect.
+*/
+package admin
+
+import (
+	gocrypto "crypto"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+
+	bnet "github.com/babbleio/babble/net"
+	"github.com/babbleio/babble/node"
+)
+
+//Empty is the argument or the reply of the methods that do not need one
+type Empty struct{}
+
+type Server struct {
+	listener  net.Listener
+	rpcServer *rpc.Server
+	admin     *Admin
+	logger    *logrus.Logger
+}
+
+//NewServer listens on bindAddress for the operators whose public keys are in
+//operators. diagDir is the directory of the node where diagnostic bundles are
+//written.
+func NewServer(bindAddress string, n *node.Node, key gocrypto.Signer, operators []string, diagDir string, logger *logrus.Logger) (*Server, error) {
+	allowed := make(map[string]bool)
+	for _, k := range operators {
+		allowed[k] = true
+	}
+	tlsConfig, err := bnet.PeerTLSConfig(key, func(pubKey string) bool {
+		return allowed[pubKey]
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := tls.Listen("tcp", bindAddress, tlsConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	admin := &Admin{
+		node:    n,
+		diagDir: diagDir,
+		logger:  logger,
+	}
+	rpcServer := rpc.NewServer()
+	rpcServer.RegisterName("Admin", admin)
+
+	return &Server{
+		listener:  listener,
+		rpcServer: rpcServer,
+		admin:     admin,
+		logger:    logger,
+	}, nil
+}
+
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+//Serve accepts connections until Close is called
+func (s *Server) Serve() {
+	s.logger.WithField("bind_address", s.Addr()).Debug("Admin channel serving")
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.rpcServer.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+func (s *Server) Close() error {
+	s.admin.dropBackup()
+	return s.listener.Close()
+}
+
+//------------------------------------------------------------------------------
+
+//Admin holds the methods of the admin channel
+type Admin struct {
+	node    *node.Node
+	diagDir string
+	logger  *logrus.Logger
+
+	backupLock sync.Mutex
+	backup     *os.File //backup being downloaded, see Backup
+}
+
+func (a *Admin) Status(args Empty, reply *node.Status) error {
+	*reply = a.node.Status()
+	return nil
+}
+
+func (a *Admin) Stats(args Empty, reply *map[string]string) error {
+	*reply = a.node.GetStats()
+	return nil
+}
+
+//Evict proposes to remove the participant with public key pubKey
+func (a *Admin) Evict(pubKey string, reply *Empty) error {
+	a.logger.WithField("pub_key", pubKey).Info("Admin: evict")
+	return a.node.ProposeEviction(pubKey)
+}
+
+//Admit lets the node with public key pubKey join the cluster, see Node.Admit
+func (a *Admin) Admit(pubKey string, reply *Empty) error {
+	a.logger.WithField("pub_key", pubKey).Info("Admin: admit")
+	return a.node.Admit(pubKey)
+}
+
+//Reload changes the parameters of the node that can change at runtime
+func (a *Admin) Reload(args node.Reloadable, reply *Empty) error {
+	a.logger.WithFields(logrus.Fields{
+		"heartbeat":  args.HeartbeatTimeout,
+		"sync_limit": args.SyncLimit,
+		"log_level":  args.LogLevel,
+	}).Info("Admin: reload")
+	return a.node.Reload(args)
+}
+
+//Suspend stops the node from gossiping and serving its peers until Resume
+func (a *Admin) Suspend(args Empty, reply *Empty) error {
+	a.logger.Info("Admin: suspend")
+	return a.node.Suspend()
+}
+
+//Resume resumes gossip after Suspend
+func (a *Admin) Resume(args Empty, reply *Empty) error {
+	a.logger.Info("Admin: resume")
+	return a.node.Resume()
+}
+
+//Diagnostics writes a diagnostic bundle on the node and returns its path
+func (a *Admin) Diagnostics(args Empty, reply *string) error {
+	path, err := a.node.WriteDiagnostics(a.diagDir)
+	if err != nil {
+		return err
+	}
+	a.logger.WithField("path", path).Info("Admin: diagnostics written")
+	*reply = path
+	return nil
+}
+
+//Jobs returns the background jobs of the node and their last runs
+func (a *Admin) Jobs(args Empty, reply *[]node.JobStatus) error {
+	*reply = a.node.Jobs()
+	return nil
+}
+
+//backupChunk is the most bytes of a backup returned by a call to Backup
+const backupChunk = 1024 * 1024
+
+//BackupArgs asks for the part of a backup that starts at Offset. An empty ID
+//takes a new backup.
+type BackupArgs struct {
+	ID     string
+	Offset int64
+}
+
+//BackupChunk is a part of the backup ID, which is Size bytes long
+type BackupChunk struct {
+	ID   string
+	Size int64
+	Data []byte
+}
+
+//Backup takes a backup of the node, see node.WriteSnapshot, and returns it in
+//chunks. The backup is written to a file in the directory of the node, which is
+//deleted once its last chunk is sent, or when another backup is taken.
+func (a *Admin) Backup(args BackupArgs, reply *BackupChunk) error {
+	a.backupLock.Lock()
+	defer a.backupLock.Unlock()
+
+	if args.ID == "" {
+		if err := a.takeBackup(); err != nil {
+			return err
+		}
+	} else if a.backup == nil || args.ID != filepath.Base(a.backup.Name()) {
+		return fmt.Errorf("Unknown backup %s", args.ID)
+	}
+
+	info, err := a.backup.Stat()
+	if err != nil {
+		return err
+	}
+	if args.Offset < 0 || args.Offset > info.Size() {
+		return fmt.Errorf("Offset %d out of a backup of %d bytes", args.Offset, info.Size())
+	}
+	size := info.Size() - args.Offset
+	if size > backupChunk {
+		size = backupChunk
+	}
+	data := make([]byte, size)
+	if _, err := a.backup.ReadAt(data, args.Offset); err != nil && err != io.EOF {
+		return err
+	}
+	*reply = BackupChunk{
+		ID:   filepath.Base(a.backup.Name()),
+		Size: info.Size(),
+		Data: data,
+	}
+	if args.Offset+size == info.Size() {
+		a.dropBackupLocked()
+	}
+	return nil
+}
+
+//takeBackup replaces the backup being downloaded with a new one. It must be
+//called with the backupLock held.
+func (a *Admin) takeBackup() error {
+	a.dropBackupLocked()
+	f, err := ioutil.TempFile(a.diagDir, "backup-")
+	if err != nil {
+		return err
+	}
+	snapshot, err := a.node.WriteSnapshot(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	a.backup = f
+	a.logger.WithFields(logrus.Fields{
+		"last_consensus_round": snapshot.LastConsensusRound,
+		"events":               len(snapshot.Frame.Events),
+		"store":                snapshot.Store,
+	}).Info("Admin: backup taken")
+	return nil
+}
+
+func (a *Admin) dropBackup() {
+	a.backupLock.Lock()
+	defer a.backupLock.Unlock()
+	a.dropBackupLocked()
+}
+
+func (a *Admin) dropBackupLocked() {
+	if a.backup == nil {
+		return
+	}
+	a.backup.Close()
+	os.Remove(a.backup.Name())
+	a.backup = nil
+}
+
+//Prune prunes the store of the node and compacts it
+func (a *Admin) Prune(args Empty, reply *node.PruneResult) error {
+	res, err := a.node.Prune()
+	if err != nil {
+		return err
+	}
+	a.logger.WithFields(logrus.Fields{
+		"round":  res.Round,
+		"events": res.Events,
+	}).Info("Admin: prune")
+	*reply = res
+	return nil
+}
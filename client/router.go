@@ -0,0 +1,210 @@
+/*
+Package client helps applications that can reach the HTTP services of several
+Babble nodes to submit their transactions where they are included the fastest.
+
+A Router polls the Status of every node and ranks them: nodes that are Babbling
+come first, then the ones that lag the least behind the most advanced node,
+then the ones with the smallest transaction pool.
+*/
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+//DefaultMaxAge is the time during which the Status of the nodes is reused
+const DefaultMaxAge = 2 * time.Second
+
+//Health is what a Router knows about a node
+type Health struct {
+	Addr               string //address of the HTTP service
+	Error              string //why the Status could not be read, if it could not
+	State              string
+	LastConsensusRound int //-1 until a round is decided
+	TransactionPool    int
+	DiskPressure       bool //the node refuses transactions for lack of disk space
+	Lag                int  //rounds behind the most advanced node
+}
+
+//Healthy tells whether the node accepts and gossips transactions
+func (h Health) Healthy() bool {
+	return h.Error == "" && h.State == "Babbling" && !h.DiskPressure
+}
+
+//the fields of node.Status used to rank the nodes
+type status struct {
+	State              string
+	LastConsensusRound *int
+	TransactionPool    int
+	DiskPressure       bool
+}
+
+//TxResponse is the response of the service to SubmitTx, with the node that
+//accepted the transaction
+type TxResponse struct {
+	Hash string
+	Addr string
+}
+
+type Router struct {
+	addrs  []string
+	client *http.Client
+	MaxAge time.Duration
+
+	lock      sync.Mutex
+	ranking   []Health
+	refreshed time.Time
+}
+
+//NewRouter creates a Router for the services at addrs, which are IP:Port
+func NewRouter(addrs []string, timeout time.Duration) *Router {
+	return &Router{
+		addrs:  addrs,
+		client: &http.Client{Timeout: timeout},
+		MaxAge: DefaultMaxAge,
+	}
+}
+
+//Refresh polls every node and returns them from the healthiest to the least
+//healthy
+func (r *Router) Refresh() []Health {
+	ranking := make([]Health, len(r.addrs))
+	var wg sync.WaitGroup
+	for i, addr := range r.addrs {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+			ranking[i] = r.health(addr)
+		}(i, addr)
+	}
+	wg.Wait()
+
+	maxRound := -1
+	for _, h := range ranking {
+		if h.Error == "" && h.LastConsensusRound > maxRound {
+			maxRound = h.LastConsensusRound
+		}
+	}
+	for i := range ranking {
+		ranking[i].Lag = maxRound - ranking[i].LastConsensusRound
+	}
+	sort.SliceStable(ranking, func(i, j int) bool {
+		a, b := ranking[i], ranking[j]
+		if a.Healthy() != b.Healthy() {
+			return a.Healthy()
+		}
+		if a.Lag != b.Lag {
+			return a.Lag < b.Lag
+		}
+		return a.TransactionPool < b.TransactionPool
+	})
+
+	r.lock.Lock()
+	r.ranking = ranking
+	r.refreshed = time.Now()
+	r.lock.Unlock()
+	return ranking
+}
+
+//Ranking returns the nodes from the healthiest to the least healthy. It only
+//polls them again if the last Refresh is older than MaxAge.
+func (r *Router) Ranking() []Health {
+	r.lock.Lock()
+	ranking, refreshed := r.ranking, r.refreshed
+	r.lock.Unlock()
+	if ranking != nil && time.Since(refreshed) < r.MaxAge {
+		return ranking
+	}
+	return r.Refresh()
+}
+
+//Healthiest returns the address of the healthiest node
+func (r *Router) Healthiest() (string, error) {
+	ranking := r.Ranking()
+	if len(ranking) == 0 || !ranking[0].Healthy() {
+		return "", fmt.Errorf("No healthy node")
+	}
+	return ranking[0].Addr, nil
+}
+
+//MaxRound returns the last consensus round of the most advanced node, -1 if
+//none has decided a round
+func (r *Router) MaxRound() int {
+	ranking := r.Ranking()
+	if len(ranking) == 0 || ranking[0].Error != "" {
+		return -1
+	}
+	return ranking[0].LastConsensusRound + ranking[0].Lag
+}
+
+//SubmitTx submits a transaction to the healthiest node that accepts it. The
+//ranking is refreshed after a failure.
+func (r *Router) SubmitTx(tx []byte) (TxResponse, error) {
+	var lastErr error
+	for _, h := range r.Ranking() {
+		if !h.Healthy() {
+			break
+		}
+		hash, err := r.submit(h.Addr, tx)
+		if err == nil {
+			return TxResponse{Hash: hash, Addr: h.Addr}, nil
+		}
+		lastErr = err
+	}
+	r.Refresh()
+	if lastErr == nil {
+		lastErr = fmt.Errorf("No healthy node")
+	}
+	return TxResponse{}, lastErr
+}
+
+func (r *Router) health(addr string) Health {
+	h := Health{Addr: addr, LastConsensusRound: -1}
+	resp, err := r.client.Get(fmt.Sprintf("http://%s/Status", addr))
+	if err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	var s status
+	if err := decode(resp, http.StatusOK, &s); err != nil {
+		h.Error = err.Error()
+		return h
+	}
+	h.State = s.State
+	h.TransactionPool = s.TransactionPool
+	h.DiskPressure = s.DiskPressure
+	if s.LastConsensusRound != nil {
+		h.LastConsensusRound = *s.LastConsensusRound
+	}
+	return h
+}
+
+func (r *Router) submit(addr string, tx []byte) (string, error) {
+	resp, err := r.client.Post(fmt.Sprintf("http://%s/SubmitTx", addr),
+		"application/octet-stream", bytes.NewReader(tx))
+	if err != nil {
+		return "", err
+	}
+	var submitted TxResponse
+	if err := decode(resp, http.StatusAccepted, &submitted); err != nil {
+		return "", err
+	}
+	return submitted.Hash, nil
+}
+
+func decode(resp *http.Response, status int, out interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
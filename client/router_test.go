@@ -0,0 +1,107 @@
+package client
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+//fakeNode serves a Status and accepts transactions like the service of a node
+type fakeNode struct {
+	server    *httptest.Server
+	state     string
+	lastRound *int
+	pool      int
+	received  [][]byte
+}
+
+func newFakeNode(state string, lastRound int, pool int) *fakeNode {
+	n := &fakeNode{state: state, pool: pool}
+	if lastRound >= 0 {
+		n.lastRound = &lastRound
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Status", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(status{
+			State:              n.state,
+			LastConsensusRound: n.lastRound,
+			TransactionPool:    n.pool,
+		})
+	})
+	mux.HandleFunc("/SubmitTx", func(w http.ResponseWriter, r *http.Request) {
+		tx, _ := ioutil.ReadAll(r.Body)
+		n.received = append(n.received, tx)
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(TxResponse{Hash: "HASH"})
+	})
+	n.server = httptest.NewServer(mux)
+	return n
+}
+
+func (n *fakeNode) addr() string {
+	return strings.TrimPrefix(n.server.URL, "http://")
+}
+
+func TestRouterRanking(t *testing.T) {
+	behind := newFakeNode("Babbling", 5, 0)
+	defer behind.server.Close()
+	busy := newFakeNode("Babbling", 10, 50)
+	defer busy.server.Close()
+	idle := newFakeNode("Babbling", 10, 0)
+	defer idle.server.Close()
+	catchingUp := newFakeNode("CatchingUp", 12, 0)
+	defer catchingUp.server.Close()
+
+	addrs := []string{behind.addr(), "127.0.0.1:1", busy.addr(), catchingUp.addr(), idle.addr()}
+	router := NewRouter(addrs, time.Second)
+	ranking := router.Refresh()
+
+	expected := []string{idle.addr(), busy.addr(), behind.addr()}
+	for i, addr := range expected {
+		if ranking[i].Addr != addr {
+			t.Fatalf("ranking[%d] should be %s, not %s", i, addr, ranking[i].Addr)
+		}
+	}
+	if ranking[2].Lag != 7 {
+		t.Fatalf("Lag of the node behind should be 7, not %d", ranking[2].Lag)
+	}
+	for _, h := range ranking[3:] {
+		if h.Healthy() {
+			t.Fatalf("%s should not be healthy", h.Addr)
+		}
+	}
+	if max := router.MaxRound(); max != 12 {
+		t.Fatalf("MaxRound should be 12, not %d", max)
+	}
+
+	res, err := router.SubmitTx([]byte("tx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Addr != idle.addr() || res.Hash != "HASH" {
+		t.Fatalf("Transaction should be submitted to %s, got %#v", idle.addr(), res)
+	}
+	if len(idle.received) != 1 || string(idle.received[0]) != "tx" {
+		t.Fatalf("%s should have received the transaction", idle.addr())
+	}
+}
+
+func TestRouterNoHealthyNode(t *testing.T) {
+	suspended := newFakeNode("Suspended", 3, 0)
+	defer suspended.server.Close()
+
+	router := NewRouter([]string{suspended.addr()}, time.Second)
+	if _, err := router.Healthiest(); err == nil {
+		t.Fatal("Healthiest should fail without a Babbling node")
+	}
+	if _, err := router.SubmitTx([]byte("tx")); err == nil {
+		t.Fatal("SubmitTx should fail without a Babbling node")
+	}
+	if len(suspended.received) != 0 {
+		t.Fatal("A suspended node should not receive transactions")
+	}
+}
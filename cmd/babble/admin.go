@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/babbleio/babble/admin"
+	"github.com/babbleio/babble/crypto"
+	"github.com/babbleio/babble/node"
+)
+
+var (
+	AdminAddressFlag = cli.StringFlag{
+		Name:  "admin_addr",
+		Usage: "IP:Port of the TLS admin channel, which serves admissions, evictions and backups",
+	}
+	AdminKeysFlag = cli.StringFlag{
+		Name:  "admin_keys",
+		Usage: "Comma-separated public keys of the operators allowed on the admin channel, besides the node key",
+	}
+	AdminNodeKeyFlag = cli.StringFlag{
+		Name:  "node_key",
+		Usage: "Public key of the node to manage (default: the key of the datadir)",
+	}
+	AdminTimeoutFlag = cli.IntFlag{
+		Name:  "timeout",
+		Usage: "Timeout in seconds to connect to the node",
+		Value: 10,
+	}
+	ReloadHeartbeatFlag = cli.IntFlag{
+		Name:  "heartbeat",
+		Usage: "New heartbeat timer milliseconds (0 = unchanged)",
+	}
+	ReloadSyncLimitFlag = cli.IntFlag{
+		Name:  "sync_limit",
+		Usage: "New max number of events for sync (0 = unchanged)",
+	}
+	ReloadLogLevelFlag = cli.StringFlag{
+		Name:  "log_level",
+		Usage: "New log level: debug, info, warn, error, fatal, panic (empty = unchanged)",
+	}
+)
+
+var adminFlags = []cli.Flag{
+	DataDirFlag,
+	AdminAddressFlag,
+	AdminNodeKeyFlag,
+	AdminTimeoutFlag,
+}
+
+var adminCommand = cli.Command{
+	Name:  "admin",
+	Usage: "Manage a running node through its admin channel, with the key of the datadir",
+	Subcommands: []cli.Command{
+		{
+			Name:   "status",
+			Usage:  "Print the status of the node",
+			Action: adminStatus,
+			Flags:  adminFlags,
+		},
+		{
+			Name:   "stats",
+			Usage:  "Print the stats of the node",
+			Action: adminStats,
+			Flags:  adminFlags,
+		},
+		{
+			Name:   "jobs",
+			Usage:  "Print the background jobs of the node and their last runs",
+			Action: adminJobs,
+			Flags:  adminFlags,
+		},
+		{
+			Name:      "evict",
+			Usage:     "Propose to remove a participant. A super-majority of the other participants must propose it",
+			ArgsUsage: "<pub_key>",
+			Action:    adminEvict,
+			Flags:     adminFlags,
+		},
+		{
+			Name:      "admit",
+			Usage:     "Let a new node join the cluster. A super-majority of the participants must admit it",
+			ArgsUsage: "<pub_key>",
+			Action:    adminAdmit,
+			Flags:     adminFlags,
+		},
+		{
+			Name:   "reload",
+			Usage:  "Change the heartbeat, sync limit or log level of the node without restarting it",
+			Action: adminReload,
+			Flags:  append(adminFlags, ReloadHeartbeatFlag, ReloadSyncLimitFlag, ReloadLogLevelFlag),
+		},
+		{
+			Name:   "suspend",
+			Usage:  "Stop the node from gossiping and serving its peers, without stopping it",
+			Action: adminSuspend,
+			Flags:  adminFlags,
+		},
+		{
+			Name:   "resume",
+			Usage:  "Resume gossip on a suspended node",
+			Action: adminResume,
+			Flags:  adminFlags,
+		},
+		{
+			Name:   "diagnostics",
+			Usage:  "Write a diagnostic bundle in the datadir of the node",
+			Action: adminDiagnostics,
+			Flags:  adminFlags,
+		},
+		{
+			Name:   "prune",
+			Usage:  "Drop the history of the store below the last final Blocks and compact it",
+			Action: adminPrune,
+			Flags:  adminFlags,
+		},
+		{
+			Name:   "backup",
+			Usage:  "Back up the hashgraph of the node",
+			Action: backup,
+			Flags:  append(adminFlags, BackupOutFlag),
+		},
+	},
+}
+
+//startAdmin serves the admin channel of n if --admin_addr is set. The node key
+//is always allowed, so that an operator with access to the datadir can use it.
+func startAdmin(c *cli.Context, n *node.Node, key crypto.KeyPair, datadir string, logger *logrus.Logger) (*admin.Server, error) {
+	addr := c.String(AdminAddressFlag.Name)
+	if addr == "" {
+		return nil, nil
+	}
+	adminKey, ok := crypto.PrivateKey(key)
+	if !ok {
+		return nil, fmt.Errorf("--admin_addr requires the key of the datadir, not a remote signer")
+	}
+	operators := []string{crypto.KeyPairPubKeyHex(key)}
+	for _, k := range strings.Split(c.String(AdminKeysFlag.Name), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			operators = append(operators, k)
+		}
+	}
+	server, err := admin.NewServer(addr, n, adminKey, operators, datadir, logger)
+	if err != nil {
+		return nil, err
+	}
+	go server.Serve()
+	return server, nil
+}
+
+func dialAdmin(c *cli.Context) (*admin.Client, error) {
+	addr := c.String(AdminAddressFlag.Name)
+	if addr == "" {
+		return nil, cli.NewExitError("--admin_addr is required", 1)
+	}
+	keyPair, err := readNodeKey(c)
+	if err != nil {
+		return nil, err
+	}
+	key, ok := crypto.PrivateKey(keyPair)
+	if !ok {
+		return nil, fmt.Errorf("The admin client requires the key of the datadir")
+	}
+	nodeKey := c.String(AdminNodeKeyFlag.Name)
+	if nodeKey == "" {
+		nodeKey = crypto.KeyPairPubKeyHex(keyPair)
+	}
+	timeout := time.Duration(c.Int(AdminTimeoutFlag.Name)) * time.Second
+	return admin.Dial(addr, key, nodeKey, timeout)
+}
+
+func printJSON(v interface{}) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func adminStatus(c *cli.Context) error {
+	client, err := dialAdmin(c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	status, err := client.Status()
+	if err != nil {
+		return err
+	}
+	return printJSON(status)
+}
+
+func adminStats(c *cli.Context) error {
+	client, err := dialAdmin(c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	stats, err := client.Stats()
+	if err != nil {
+		return err
+	}
+	return printJSON(stats)
+}
+
+func adminJobs(c *cli.Context) error {
+	client, err := dialAdmin(c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	jobs, err := client.Jobs()
+	if err != nil {
+		return err
+	}
+	return printJSON(jobs)
+}
+
+func adminEvict(c *cli.Context) error {
+	pubKey := c.Args().First()
+	if pubKey == "" {
+		return cli.NewExitError("the public key of the participant is required", 1)
+	}
+	client, err := dialAdmin(c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Evict(pubKey); err != nil {
+		return err
+	}
+	fmt.Printf("Proposed the eviction of %s\n", pubKey)
+	return nil
+}
+
+func adminAdmit(c *cli.Context) error {
+	pubKey := c.Args().First()
+	if pubKey == "" {
+		return cli.NewExitError("the public key of the new node is required", 1)
+	}
+	client, err := dialAdmin(c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Admit(pubKey); err != nil {
+		return err
+	}
+	fmt.Printf("Admitted %s\n", pubKey)
+	return nil
+}
+
+func adminReload(c *cli.Context) error {
+	r := node.Reloadable{
+		HeartbeatTimeout: time.Duration(c.Int(ReloadHeartbeatFlag.Name)) * time.Millisecond,
+		SyncLimit:        c.Int(ReloadSyncLimitFlag.Name),
+		LogLevel:         c.String(ReloadLogLevelFlag.Name),
+	}
+	if r == (node.Reloadable{}) {
+		return cli.NewExitError("one of --heartbeat, --sync_limit or --log_level is required", 1)
+	}
+	client, err := dialAdmin(c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Reload(r); err != nil {
+		return err
+	}
+	fmt.Println("Reloaded")
+	return nil
+}
+
+func adminSuspend(c *cli.Context) error {
+	client, err := dialAdmin(c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Suspend(); err != nil {
+		return err
+	}
+	fmt.Println("Suspended")
+	return nil
+}
+
+func adminResume(c *cli.Context) error {
+	client, err := dialAdmin(c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	if err := client.Resume(); err != nil {
+		return err
+	}
+	fmt.Println("Resumed")
+	return nil
+}
+
+func adminDiagnostics(c *cli.Context) error {
+	client, err := dialAdmin(c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	path, err := client.Diagnostics()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Diagnostics written on the node to %s\n", path)
+	return nil
+}
+
+func adminPrune(c *cli.Context) error {
+	client, err := dialAdmin(c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	res, err := client.Prune()
+	if err != nil {
+		return err
+	}
+	if res.Round < 0 {
+		fmt.Println("Nothing to prune")
+		return nil
+	}
+	fmt.Printf("Pruned %d Events below round %d in %s\n", res.Events, res.Round, res.Duration)
+	return nil
+}
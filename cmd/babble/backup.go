@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/babbleio/babble/node"
+)
+
+var (
+	BackupOutFlag = cli.StringFlag{
+		Name:  "out",
+		Usage: "File to write the backup to",
+	}
+)
+
+//backup downloads a backup from the admin channel of a running node. The file
+//only appears at the --out path once it is complete and verified.
+func backup(c *cli.Context) error {
+	out := c.String(BackupOutFlag.Name)
+	if out == "" {
+		return cli.NewExitError("--out is required", 1)
+	}
+	client, err := dialAdmin(c)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return saveBackup(out, client.Backup)
+}
+
+//saveBackup writes the backup written by fetch to out, once it is verified
+func saveBackup(out string, fetch func(w io.Writer) error) error {
+	tmp := out + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+
+	err = fetch(f)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	//verify the backup before keeping it
+	f, err = os.Open(tmp)
+	if err != nil {
+		return err
+	}
+	snapshot, err := node.ReadSnapshot(f)
+	var size int64
+	if info, serr := f.Stat(); serr == nil {
+		size = info.Size()
+	}
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("Invalid backup: %s", err)
+	}
+
+	if err := os.Rename(tmp, out); err != nil {
+		return err
+	}
+
+	fmt.Printf("Backup written to %s (%d bytes)\n", out, size)
+	fmt.Printf("Taken: %s\n", snapshot.Taken.Format(time.RFC3339))
+	fmt.Printf("Last consensus round: %d\n", snapshot.LastConsensusRound)
+	fmt.Printf("Consensus events: %d\n", snapshot.ConsensusEvents)
+	fmt.Printf("Frame events: %d\n", len(snapshot.Frame.Events))
+	fmt.Printf("Store: %t\n", snapshot.Store)
+	return nil
+}
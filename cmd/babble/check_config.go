@@ -0,0 +1,327 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	stdnet "net"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/babbleio/babble/codec"
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/crypto"
+	"github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+	"github.com/babbleio/babble/node"
+)
+
+//configChecker collects the results of check-config
+type configChecker struct {
+	errors int
+}
+
+func (c *configChecker) ok(format string, args ...interface{}) {
+	fmt.Printf("[OK]    %s\n", fmt.Sprintf(format, args...))
+}
+
+func (c *configChecker) fail(hint string, format string, args ...interface{}) {
+	c.errors++
+	fmt.Printf("[ERROR] %s\n", fmt.Sprintf(format, args...))
+	if hint != "" {
+		fmt.Printf("        -> %s\n", hint)
+	}
+}
+
+//checkConfig validates the configuration given to the run command without
+//starting the node
+func checkConfig(c *cli.Context) error {
+	checker := &configChecker{}
+	if err := applyConfigFile(c); err != nil {
+		checker.fail("", "config: %s", err)
+	}
+
+	datadir := c.String(DataDirFlag.Name)
+	checker.checkDataDir(datadir)
+	checker.checkParams(c)
+	checker.checkStore(c, datadir)
+
+	key := checker.checkKey(c, datadir)
+	if selector := c.String(K8sSelectorFlag.Name); selector != "" {
+		if expected := c.Int(K8sPeersFlag.Name); expected <= 0 {
+			checker.fail("set --k8s_peers to the number of participants of the cluster",
+				"--k8s_peers is required with --k8s_selector")
+		} else {
+			checker.ok("%d peers discovered from Kubernetes pods matching %s", expected, selector)
+		}
+	} else {
+		checker.checkPeers(datadir, key, c.String(NodeAddressFlag.Name), c.String(JoinFlag.Name) != "")
+	}
+
+	checker.checkPort("node_addr", c.String(NodeAddressFlag.Name))
+	if !c.Bool(NoClientFlag.Name) {
+		checker.checkSocket("proxy_addr", c.String(ProxyAddressFlag.Name))
+	}
+	checker.checkPort("service_addr", c.String(ServiceAddressFlag.Name))
+
+	if checker.errors > 0 {
+		return cli.NewExitError(fmt.Sprintf("%d problem(s) found", checker.errors), 1)
+	}
+	fmt.Println("Configuration OK")
+	return nil
+}
+
+func (c *configChecker) checkDataDir(datadir string) {
+	info, err := os.Stat(datadir)
+	if err != nil {
+		c.fail("create the directory or point --datadir to an existing one",
+			"datadir %s: %s", datadir, err)
+		return
+	}
+	if !info.IsDir() {
+		c.fail("--datadir must be a directory", "datadir %s is not a directory", datadir)
+		return
+	}
+
+	//the node writes its key and peers in datadir
+	f, err := os.Create(filepath.Join(datadir, ".check-config"))
+	if err != nil {
+		c.fail("check the permissions of the directory", "datadir %s is not writable: %s", datadir, err)
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+	c.ok("datadir %s", datadir)
+}
+
+func (c *configChecker) checkParams(ctx *cli.Context) {
+	errors := c.errors
+	if _, err := node.GetProfile(ctx.String(ProfileFlag.Name)); err != nil {
+		c.fail("", "%s", err)
+	}
+
+	//unset flags take the value of the profile
+	positive := []cli.IntFlag{HeartbeatFlag, MaxPoolFlag, TcpTimeoutFlag, CacheSizeFlag, SyncLimitFlag}
+	for _, f := range positive {
+		if v := ctx.Int(f.Name); ctx.IsSet(f.Name) && v <= 0 {
+			c.fail("remove the flag to use the value of the profile", "%s must be positive, got %d", f.Name, v)
+		}
+	}
+	for _, f := range []cli.IntFlag{SyncTimeoutFlag, EagerSyncTimeoutFlag, FastForwardTimeoutFlag} {
+		if v := ctx.Int(f.Name); ctx.IsSet(f.Name) && v < 0 {
+			c.fail("use 0 for the tcp_timeout", "%s must not be negative, got %d", f.Name, v)
+		}
+	}
+	if v := ctx.Int(MaxEventPayloadFlag.Name); ctx.IsSet(MaxEventPayloadFlag.Name) && v < 0 {
+		c.fail("use 0 to disable the limit", "%s must not be negative, got %d", MaxEventPayloadFlag.Name, v)
+	}
+
+	_, err := node.NewEventCreationPolicy(ctx.String(EventPolicyFlag.Name),
+		time.Duration(ctx.Int(EventIntervalFlag.Name))*time.Millisecond)
+	if err != nil {
+		c.fail("valid policies are sync, transactions and timer", "%s: %s", EventPolicyFlag.Name, err)
+	}
+	if _, err := codec.Get(ctx.String(CodecFlag.Name)); err != nil {
+		c.fail(fmt.Sprintf("valid codecs are %s", strings.Join(codec.Names(), ", ")),
+			"%s: %s", CodecFlag.Name, err)
+	}
+	if store := ctx.String(StoreFlag.Name); store != "inmem" && store != "badger" {
+		c.fail("valid stores are inmem and badger", "%s: Unknown store %s", StoreFlag.Name, store)
+	}
+	if c.errors != errors {
+		return
+	}
+
+	//the parameters are valid one by one, but maybe not together
+	profile, _ := node.GetProfile(ctx.String(ProfileFlag.Name))
+	conf, _ := nodeConfig(ctx, profile, logrus.New())
+	if err := conf.Validate(); err != nil {
+		for _, problem := range err.(node.ConfigError) {
+			c.fail("adjust the flags or choose another profile", "%s", problem)
+		}
+		return
+	}
+	c.ok("parameters")
+}
+
+//checkStore opens the badger store of a node that ran before and reads its last
+//round. A missing or empty directory is a first start. The store can not be
+//checked while a node uses it.
+func (c *configChecker) checkStore(ctx *cli.Context, datadir string) {
+	if ctx.String(StoreFlag.Name) != "badger" {
+		return
+	}
+	path := ctx.String(StorePathFlag.Name)
+	if path == "" {
+		path = filepath.Join(datadir, "badger_db")
+	}
+	files, err := ioutil.ReadDir(path)
+	if os.IsNotExist(err) || (err == nil && len(files) == 0) {
+		c.ok("badger store %s will be created", path)
+		return
+	}
+	if err != nil {
+		c.fail("check the permissions of the directory", "badger store %s: %s", path, err)
+		return
+	}
+
+	store, err := hashgraph.LoadBadgerStore(ctx.Int(CacheSizeFlag.Name), path)
+	if err != nil {
+		c.fail("stop the node using the store, or restore it from a backup",
+			"badger store %s can not be opened: %s", path, err)
+		return
+	}
+	defer store.Close()
+	round, err := store.LastStoredRound()
+	if err != nil {
+		c.fail("restore the store from a backup",
+			"badger store %s: last round is not readable: %s", path, err)
+		return
+	}
+	c.ok("badger store %s, last round %d", path, round)
+}
+
+func (c *configChecker) checkKey(ctx *cli.Context, datadir string) crypto.KeyPair {
+	key, path, err := loadNodeKey(ctx, datadir)
+	if err != nil {
+		c.fail("the file must contain an EC or Ed25519 private key generated by 'babble keygen', and priv_key.json needs its passphrase",
+			"%s: %s", filepath.Base(path), err)
+		return nil
+	}
+	if key == nil {
+		c.fail("generate one with 'babble keygen' and save the private key in priv_key.pem",
+			"No private key in %s", datadir)
+		return nil
+	}
+	c.ok("%s private key %s", key.Algorithm(), crypto.KeyPairPubKeyHex(key))
+	return key
+}
+
+//joining nodes are not listed in peers.json yet
+func (c *configChecker) checkPeers(datadir string, key crypto.KeyPair, nodeAddr string, joining bool) {
+	peers, err := net.NewJSONPeers(datadir).Peers()
+	if err != nil {
+		c.fail("peers.json must be a JSON list of {\"NetAddr\", \"PubKeyHex\"} objects",
+			"peers.json: %s", err)
+		return
+	}
+	if len(peers) == 0 {
+		c.fail("list every participant, including this node, in peers.json",
+			"No peers in %s", datadir)
+		return
+	}
+
+	problems := c.checkPeerList(peers)
+
+	if key != nil {
+		if err := net.CheckKeyAlgorithms(key.Algorithm(), peers); err != nil && problems == 0 {
+			c.fail("every node of the cluster must use keys of the same algorithm", "%s", err)
+			problems++
+		}
+		self := crypto.KeyPairPubKeyHex(key)
+		found := false
+		for _, p := range peers {
+			if p.PubKeyHex != self {
+				continue
+			}
+			found = true
+			if p.NetAddr != nodeAddr {
+				c.fail("peers use this address to reach the node; update peers.json or --node_addr",
+					"peers.json lists this node at %s but node_addr is %s", p.NetAddr, nodeAddr)
+				problems++
+			}
+		}
+		if !found && !joining {
+			c.fail("add this node's public key to peers.json, and the same file on every peer",
+				"The private key does not match any peer")
+			problems++
+		}
+	}
+
+	if problems == 0 {
+		c.ok("%d peers", len(peers))
+	}
+}
+
+//checkPeerList checks the records of peers on their own and against one
+//another, and returns the number of problems found
+func (c *configChecker) checkPeerList(peers []net.Peer) int {
+	pubKeys := make(map[string]bool)
+	addrs := make(map[string]bool)
+	problems := 0
+	for i, p := range peers {
+		if _, _, err := stdnet.SplitHostPort(p.NetAddr); err != nil {
+			c.fail("addresses are IP:Port", "peers[%d] invalid NetAddr %q: %s", i, p.NetAddr, err)
+			problems++
+		}
+		if len(p.PubKeyHex) < 3 || p.PubKeyHex[:2] != "0x" {
+			c.fail("public keys are 0x-prefixed hex strings", "peers[%d] invalid PubKeyHex %q", i, p.PubKeyHex)
+			problems++
+		} else if _, err := p.PubKeyBytes(); err != nil {
+			c.fail("public keys are 0x-prefixed hex strings", "peers[%d] invalid PubKeyHex %q", i, p.PubKeyHex)
+			problems++
+		} else if _, err := p.Algorithm(); err != nil {
+			c.fail("KeyAlgorithm is optional, but must match the key", "peers[%d] %s", i, err)
+			problems++
+		}
+		for _, pin := range p.TLSPins {
+			if err := net.CheckPin(pin); err != nil {
+				c.fail("pins are spki-sha256:<hex> or cert-sha256:<hex>, see 'babble peers pin'", "peers[%d] %s", i, err)
+				problems++
+			}
+		}
+		if pubKeys[p.PubKeyHex] {
+			c.fail("every participant must have its own key", "peers[%d] duplicate PubKeyHex", i)
+			problems++
+		}
+		if addrs[p.NetAddr] {
+			c.fail("every participant must have its own address", "peers[%d] duplicate NetAddr %s", i, p.NetAddr)
+			problems++
+		}
+		pubKeys[p.PubKeyHex] = true
+		addrs[p.NetAddr] = true
+	}
+
+	//the keys of the peers are valid, but may be of different algorithms
+	if problems == 0 && len(peers) > 0 {
+		if _, err := net.PinPeerCertificates(&tls.Config{}, peers); err != nil {
+			c.fail("either every peer has TLSPins or none does", "%s", err)
+			problems++
+		}
+		alg, _ := peers[0].Algorithm()
+		if err := net.CheckKeyAlgorithms(alg, peers); err != nil {
+			c.fail("every node of the cluster must use keys of the same algorithm", "%s", err)
+			problems++
+		}
+	}
+	return problems
+}
+
+//checkSocket checks an address of the socket proxy, which may also be a Unix
+//domain socket or a named pipe
+func (c *configChecker) checkSocket(name, addr string) {
+	l, err := common.ListenSocket(addr)
+	if err != nil {
+		c.fail("stop the process using the address or choose another one",
+			"%s %s is not available: %s", name, addr, err)
+		return
+	}
+	l.Close()
+	c.ok("%s %s", name, addr)
+}
+
+func (c *configChecker) checkPort(name, addr string) {
+	l, err := stdnet.Listen("tcp", addr)
+	if err != nil {
+		c.fail("stop the process using the port or choose another address",
+			"%s %s is not available: %s", name, addr, err)
+		return
+	}
+	l.Close()
+	c.ok("%s %s", name, addr)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/babbleio/babble/crypto"
+	"github.com/babbleio/babble/net"
+	"github.com/babbleio/babble/node"
+)
+
+var CloneSourceFlag = cli.StringFlag{
+	Name:  "source",
+	Usage: "IP:Port of the HTTP service of the node to clone",
+}
+
+//clone writes the participants of a running node to the peers.json of the
+//datadir, and generates the key of the observer if there is none. The observer
+//itself is cloned when it starts, see node/observer.go.
+func clone(c *cli.Context) error {
+	source := c.String(CloneSourceFlag.Name)
+	if source == "" {
+		return cli.NewExitError("--source is required", 1)
+	}
+	datadir := c.String(DataDirFlag.Name)
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://%s/Clone", source))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Clone failed: %s", resp.Status)
+	}
+	var info node.CloneInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return fmt.Errorf("Clone failed: %s", err)
+	}
+	if len(info.Peers) == 0 {
+		return fmt.Errorf("Clone failed: %s has no participants", source)
+	}
+
+	if err := makeDataDir(datadir); err != nil {
+		return err
+	}
+	peers := net.NewJSONPeers(datadir)
+	if old, err := peers.Peers(); err == nil && len(old) > 0 && !c.Bool(KeyForceFlag.Name) {
+		return cli.NewExitError(fmt.Sprintf("%s already has a peers.json. Use --force to replace it", datadir), 1)
+	}
+
+	//the observer needs a key of the algorithm of the participants, but not one
+	//of theirs
+	key, _, err := loadNodeKey(c, datadir)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		alg, err := info.Source.Algorithm()
+		if err != nil {
+			return err
+		}
+		if key, err = crypto.GenerateKeyPair(alg); err != nil {
+			return err
+		}
+		if err := crypto.NewPemKey(datadir).WriteKeyPair(key); err != nil {
+			return err
+		}
+		fmt.Printf("Generated %s key %s\n", alg, crypto.KeyPairPubKeyHex(key))
+	}
+	for _, p := range info.Peers {
+		if p.PubKeyHex == crypto.KeyPairPubKeyHex(key) {
+			return fmt.Errorf("The key in %s is a participant's, an observer needs its own", datadir)
+		}
+	}
+
+	if err := peers.SetPeers(info.Peers); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote the %d participants of %s to peers.json\n", len(info.Peers), info.Source.NetAddr)
+	fmt.Printf("Source at Block %d, round %d\n", info.LastBlock, info.LastConsensusRound)
+	fmt.Printf("Start the observer with:\n\n")
+	fmt.Printf("    babble run --datadir %s --observer --clone_from %s --node_addr <IP:Port>\n", datadir, info.Source.NetAddr)
+	return nil
+}
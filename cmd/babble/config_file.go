@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"gopkg.in/urfave/cli.v1"
+)
+
+var ConfigFileFlag = cli.StringFlag{
+	Name:  "config",
+	Usage: "JSON file of flag values, keyed by flag name. Flags on the command line take precedence",
+}
+
+//applyConfigFile sets the flags that are not on the command line from the file
+//of --config, for instance:
+//
+//	{
+//		"datadir": "/var/lib/babble",
+//		"node_addr": "172.77.5.1:1337",
+//		"heartbeat": 50,
+//		"no_client": true
+//	}
+func applyConfigFile(c *cli.Context) error {
+	path := c.String(ConfigFileFlag.Name)
+	if path == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var values map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&values); err != nil {
+		return fmt.Errorf("%s: %s", path, err)
+	}
+
+	//sorted so that the first error is always the same
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if name == ConfigFileFlag.Name {
+			return fmt.Errorf("%s: a config file can not include another", path)
+		}
+		if c.IsSet(name) {
+			continue
+		}
+		var value string
+		switch v := values[name].(type) {
+		case string:
+			value = v
+		case json.Number, bool:
+			value = fmt.Sprint(v)
+		default:
+			return fmt.Errorf("%s: %s must be a string, a number or a boolean", path, name)
+		}
+		if err := c.Set(name, value); err != nil {
+			return fmt.Errorf("%s: %s: %s", path, name, err)
+		}
+	}
+	return nil
+}
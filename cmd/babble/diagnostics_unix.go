@@ -0,0 +1,31 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/babbleio/babble/node"
+)
+
+//watchDiagnostics writes a diagnostic bundle to dir on SIGUSR1
+func watchDiagnostics(n *node.Node, dir string, logger *logrus.Logger) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR1)
+
+	go func() {
+		for range sigCh {
+			path, err := n.WriteDiagnostics(dir)
+			if err != nil {
+				logger.WithField("error", err).Error("Writing diagnostics")
+				continue
+			}
+			logger.WithField("path", path).Info("Diagnostics written")
+		}
+	}()
+}
@@ -0,0 +1,12 @@
+package main
+
+import (
+	"github.com/Sirupsen/logrus"
+
+	"github.com/babbleio/babble/node"
+)
+
+//watchDiagnostics is not supported on Windows, which has no SIGUSR1
+func watchDiagnostics(n *node.Node, dir string, logger *logrus.Logger) {
+	logger.Debug("Diagnostic dumps on signal are not supported on Windows")
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+/*
+Zero-downtime restarts. On SIGUSR2, a running node starts a new instance of the
+babble binary (which may have been upgraded in place) with the same arguments,
+and passes it the listening consensus socket. The old process then drains:
+it stops gossiping, waits for in-flight syncs to finish and exits. Peers that
+connect in the meantime are queued by the kernel instead of being refused.
+
+The new process waits for its parent to exit before binding the other ports,
+and resumes from the last Event in its badger store, or else by fast-forwarding
+from a peer, instead of creating a new initial Event.
+*/
+
+const (
+	listenFDEnv   = "BABBLE_LISTEN_FD"
+	handoffPIDEnv = "BABBLE_HANDOFF_PID"
+
+	//the first entry of exec.Cmd.ExtraFiles becomes file descriptor 3
+	handoffFD = 3
+
+	handoffParentTimeout = 30 * time.Second
+)
+
+type listenerFiler interface {
+	ListenerFile() (*os.File, error)
+}
+
+//inheritedListener returns the consensus listener passed by a parent process
+//during a handoff, or nil if the process was started normally
+func inheritedListener() (net.Listener, error) {
+	fdStr := os.Getenv(listenFDEnv)
+	if fdStr == "" {
+		return nil, nil
+	}
+	os.Unsetenv(listenFDEnv)
+
+	fd, err := strconv.Atoi(fdStr)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid %s %s", listenFDEnv, fdStr)
+	}
+	f := os.NewFile(uintptr(fd), "babble-listener")
+	defer f.Close()
+
+	return net.FileListener(f)
+}
+
+//waitForParent blocks until the process that handed off its socket has exited
+//and released the other ports
+func waitForParent() error {
+	pidStr := os.Getenv(handoffPIDEnv)
+	if pidStr == "" {
+		return nil
+	}
+	os.Unsetenv(handoffPIDEnv)
+
+	pid, err := strconv.Atoi(pidStr)
+	if err != nil {
+		return fmt.Errorf("Invalid %s %s", handoffPIDEnv, pidStr)
+	}
+
+	deadline := time.Now().Add(handoffParentTimeout)
+	for os.Getppid() == pid {
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Parent process %d did not exit", pid)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	return nil
+}
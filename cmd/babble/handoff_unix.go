@@ -0,0 +1,69 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/babbleio/babble/common"
+)
+
+//watchHandoff hands the consensus socket over to a new process on SIGUSR2
+//and calls shutdown once the new process is started
+func watchHandoff(trans listenerFiler, shutdown func(), logger *logrus.Logger) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGUSR2)
+
+	go func() {
+		for range sigCh {
+			logger.Info("Handing off to new process")
+			pid, err := startSuccessor(trans)
+			if err != nil {
+				logger.WithField("error", err).Error("Handoff failed, keep running")
+				continue
+			}
+			logger.WithField("pid", pid).Info("Successor started, draining")
+
+			//tell systemd to follow the new process
+			common.SdNotify(fmt.Sprintf("MAINPID=%d", pid))
+
+			signal.Stop(sigCh)
+			shutdown()
+			return
+		}
+	}()
+}
+
+func startSuccessor(trans listenerFiler) (int, error) {
+	f, err := trans.ListenerFile()
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = []*os.File{f}
+	cmd.Env = append(os.Environ(),
+		fmt.Sprintf("%s=%d", listenFDEnv, handoffFD),
+		fmt.Sprintf("%s=%d", handoffPIDEnv, os.Getpid()))
+
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+	return cmd.Process.Pid, nil
+}
@@ -0,0 +1,9 @@
+package main
+
+import "github.com/Sirupsen/logrus"
+
+//watchHandoff is not supported on Windows, which cannot pass sockets to a
+//child process
+func watchHandoff(trans listenerFiler, shutdown func(), logger *logrus.Logger) {
+	logger.Debug("Socket handoff is not supported on Windows")
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/babbleio/babble/net"
+	"github.com/babbleio/babble/node"
+)
+
+var (
+	HistoryLogFlag = cli.StringFlag{
+		Name:  "log",
+		Usage: "Transaction log of the legacy system, one JSON list of base64 transactions per Block and per line",
+	}
+	HistoryOutFlag = cli.StringFlag{
+		Name:  "out",
+		Usage: "File to write the history to",
+	}
+)
+
+//buildHistory converts a transaction log into a History for the participants
+//listed in the peers.json of the datadir, and prints its hash
+func buildHistory(c *cli.Context) error {
+	logPath := c.String(HistoryLogFlag.Name)
+	out := c.String(HistoryOutFlag.Name)
+	if logPath == "" || out == "" {
+		return cli.NewExitError("--log and --out are required", 1)
+	}
+
+	peers, err := net.NewJSONPeers(c.String(DataDirFlag.Name)).Peers()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(logPath)
+	if err != nil {
+		return err
+	}
+	txs, err := node.ReadTransactionLog(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	history, err := node.NewHistory(txs, peers)
+	if err != nil {
+		return err
+	}
+
+	tmp := out + ".tmp"
+	f, err = os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp)
+	err = node.EncodeHistory(f, history)
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, out); err != nil {
+		return err
+	}
+
+	fmt.Printf("History written to %s\n", out)
+	fmt.Printf("Participants: %d\n", len(history.Frame.Participants))
+	fmt.Printf("Blocks: %d\n", len(history.Blocks))
+	fmt.Printf("Transactions: %d\n", history.Transactions())
+	fmt.Printf("Hash: %X\n", history.Hash)
+	return nil
+}
+
+//readHistory reads and verifies a file written by buildHistory
+func readHistory(path string) (node.History, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return node.History{}, err
+	}
+	defer f.Close()
+	history, err := node.ReadHistory(f)
+	if err != nil {
+		return node.History{}, fmt.Errorf("Invalid history %s: %s", path, err)
+	}
+	return history, nil
+}
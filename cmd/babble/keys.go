@@ -0,0 +1,391 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/babbleio/babble/crypto"
+	"github.com/babbleio/babble/net"
+)
+
+var (
+	KeyPrivateFlag = cli.BoolFlag{
+		Name:  "private",
+		Usage: "Export the private key in PEM format instead of the public peer record",
+	}
+	KeyForceFlag = cli.BoolFlag{
+		Name:  "force",
+		Usage: "Replace the existing node key. The old key is kept in a backup file",
+	}
+	KeySaveFlag = cli.BoolFlag{
+		Name:  "save",
+		Usage: "Save the key as the node key of --datadir instead of printing it",
+	}
+	KeyEncryptFlag = cli.BoolFlag{
+		Name:  "encrypt",
+		Usage: "Store the key encrypted with the passphrase in priv_key.json instead of priv_key.pem",
+	}
+	PassphraseFileFlag = cli.StringFlag{
+		Name:  "passphrase_file",
+		Usage: "File holding the passphrase of priv_key.json (default: $" + passphraseEnv + ")",
+	}
+)
+
+//passphraseEnv is the environment variable read for the passphrase of
+//priv_key.json when there is no --passphrase_file
+const passphraseEnv = "BABBLE_PASSPHRASE"
+
+var keysCommand = cli.Command{
+	Name:  "keys",
+	Usage: "Manage the node key in the datadir",
+	Subcommands: []cli.Command{
+		{
+			Name:   "list",
+			Usage:  "List the keys in the datadir",
+			Action: keysList,
+			Flags:  []cli.Flag{DataDirFlag},
+		},
+		{
+			Name:      "inspect",
+			Usage:     "Show the public key of a PEM key file (default: the node key)",
+			ArgsUsage: "[file]",
+			Action:    keysInspect,
+			Flags:     []cli.Flag{DataDirFlag},
+		},
+		{
+			Name:   "export",
+			Usage:  "Print the peer record of the node key, ready for peers.json",
+			Action: keysExport,
+			Flags:  []cli.Flag{DataDirFlag, NodeAddressFlag, KeyPrivateFlag, PassphraseFileFlag},
+		},
+		{
+			Name:      "import",
+			Usage:     "Use the key in a PEM file as the node key",
+			ArgsUsage: "<file>",
+			Action:    keysImport,
+			Flags:     []cli.Flag{DataDirFlag, KeyForceFlag, KeyEncryptFlag, PassphraseFileFlag},
+		},
+		{
+			Name:   "encrypt",
+			Usage:  "Encrypt priv_key.pem with a passphrase into priv_key.json, and remove it",
+			Action: keysEncrypt,
+			Flags:  []cli.Flag{DataDirFlag, PassphraseFileFlag},
+		},
+	},
+}
+
+func readKeyFile(path string) (crypto.KeyPair, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	key, err := crypto.ParsePemKeyPair(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return key, nil
+}
+
+//readPassphrase returns the passphrase of priv_key.json, from --passphrase_file
+//or the environment
+func readPassphrase(c *cli.Context) ([]byte, error) {
+	path := c.String(PassphraseFileFlag.Name)
+	if path == "" {
+		passphrase := os.Getenv(passphraseEnv)
+		if passphrase == "" {
+			return nil, fmt.Errorf("The key is encrypted: set --passphrase_file or $%s", passphraseEnv)
+		}
+		return []byte(passphrase), nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(data, "\r\n"), nil
+}
+
+//loadNodeKey reads the key of the datadir, from priv_key.json if it is
+//encrypted and priv_key.pem otherwise. It returns the path of the key, and a nil
+//key if there is none.
+func loadNodeKey(c *cli.Context, datadir string) (crypto.KeyPair, string, error) {
+	keyFile := crypto.NewKeyFile(datadir)
+	if keyFile.Exists() {
+		passphrase, err := readPassphrase(c)
+		if err != nil {
+			return nil, keyFile.Path(), err
+		}
+		key, err := keyFile.ReadKeyPair(passphrase)
+		return key, keyFile.Path(), err
+	}
+	pemKey := crypto.NewPemKey(datadir)
+	key, err := pemKey.ReadKeyPair()
+	return key, pemKey.Path(), err
+}
+
+func readNodeKey(c *cli.Context) (crypto.KeyPair, error) {
+	datadir := c.String(DataDirFlag.Name)
+	key, path, err := loadNodeKey(c, datadir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	if key == nil {
+		return nil, fmt.Errorf("No key in %s. Generate one with 'babble keygen'", datadir)
+	}
+	return key, nil
+}
+
+//nodeSigner returns the remote signer of --signer_url, or the key of the
+//datadir
+func nodeSigner(c *cli.Context, timeout time.Duration) (crypto.KeyPair, error) {
+	if url := c.String(SignerURLFlag.Name); url != "" {
+		signer, err := crypto.NewRemoteSigner(url, timeout)
+		if err != nil {
+			return nil, err
+		}
+		return signer, nil
+	}
+	return readNodeKey(c)
+}
+
+//serveSigner serves the key of the datadir to the nodes started with
+//--signer_url, so that it is kept out of their process
+func serveSigner(c *cli.Context) error {
+	key, err := readNodeKey(c)
+	if err != nil {
+		return err
+	}
+	addr := c.String(SignerAddressFlag.Name)
+	fmt.Printf("Serving %s on %s\n", crypto.KeyPairPubKeyHex(key), addr)
+	return http.ListenAndServe(addr, crypto.SignerHandler(key))
+}
+
+func keysList(c *cli.Context) error {
+	datadir := c.String(DataDirFlag.Name)
+	nodeKeyPath := crypto.NewPemKey(datadir).Path()
+	keyFile := crypto.NewKeyFile(datadir)
+
+	files, err := filepath.Glob(filepath.Join(datadir, "*.pem*"))
+	if err != nil {
+		return err
+	}
+	encrypted, err := filepath.Glob(keyFile.Path() + "*")
+	if err != nil {
+		return err
+	}
+	if len(files)+len(encrypted) == 0 {
+		fmt.Printf("No keys in %s\n", datadir)
+		return nil
+	}
+
+	//the encrypted key takes precedence over priv_key.pem
+	if keyFile.Exists() {
+		nodeKeyPath = keyFile.Path()
+	}
+	for _, f := range encrypted {
+		data, err := ioutil.ReadFile(f)
+		if err == nil {
+			var alg, pub string
+			if alg, pub, err = crypto.KeyFilePubKey(data); err == nil {
+				role := ""
+				if f == nodeKeyPath {
+					role = "\t(node key)"
+				}
+				fmt.Printf("%s\t%s\t%s\tencrypted%s\n", filepath.Base(f), alg, pub, role)
+				continue
+			}
+		}
+		fmt.Printf("%s\tinvalid: %s\n", filepath.Base(f), err)
+	}
+	for _, f := range files {
+		key, err := readKeyFile(f)
+		if err != nil {
+			fmt.Printf("%s\tinvalid: %s\n", filepath.Base(f), err)
+			continue
+		}
+		role := ""
+		if f == nodeKeyPath {
+			role = "\t(node key)"
+		}
+		fmt.Printf("%s\t%s\t%s%s\n", filepath.Base(f), key.Algorithm(), crypto.KeyPairPubKeyHex(key), role)
+	}
+	return nil
+}
+
+func keysInspect(c *cli.Context) error {
+	datadir := c.String(DataDirFlag.Name)
+
+	var key crypto.KeyPair
+	var err error
+	path := c.Args().First()
+	if path == "" {
+		key, path, err = loadNodeKey(c, datadir)
+		if err == nil && key == nil {
+			err = fmt.Errorf("No key in %s. Generate one with 'babble keygen'", datadir)
+		}
+	} else {
+		key, err = readKeyFile(path)
+	}
+	if err != nil {
+		return err
+	}
+	pubKey := crypto.KeyPairPubKeyHex(key)
+
+	fmt.Printf("File:      %s\n", path)
+	fmt.Printf("Algorithm: %s\n", key.Algorithm())
+	fmt.Printf("PublicKey: %s\n", pubKey)
+
+	peers, err := net.NewJSONPeers(datadir).Peers()
+	if err != nil {
+		return nil
+	}
+	for _, p := range peers {
+		if p.PubKeyHex == pubKey {
+			fmt.Printf("Peer:      %s\n", p.NetAddr)
+			return nil
+		}
+	}
+	fmt.Println("Peer:      not in peers.json")
+	return nil
+}
+
+func keysExport(c *cli.Context) error {
+	key, err := readNodeKey(c)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool(KeyPrivateFlag.Name) {
+		data, err := crypto.EncodePemKeyPair(key)
+		if err != nil {
+			return err
+		}
+		fmt.Print(string(data))
+		return nil
+	}
+
+	record, err := json.MarshalIndent(net.Peer{
+		NetAddr:      c.String(NodeAddressFlag.Name),
+		PubKeyHex:    crypto.KeyPairPubKeyHex(key),
+		KeyAlgorithm: key.Algorithm(),
+	}, "", "\t")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(record))
+	return nil
+}
+
+func keysImport(c *cli.Context) error {
+	path := c.Args().First()
+	if path == "" {
+		return cli.NewExitError("Missing key file", 1)
+	}
+	key, err := readKeyFile(path)
+	if err != nil {
+		return err
+	}
+	if err := storeNodeKey(c, key); err != nil {
+		return err
+	}
+	fmt.Printf("Imported %s\n", crypto.KeyPairPubKeyHex(key))
+	return nil
+}
+
+//storeNodeKey makes key the node key of the datadir, in priv_key.json with
+//--encrypt and priv_key.pem otherwise. With --force, the previous key is kept
+//in a backup file.
+func storeNodeKey(c *cli.Context, key crypto.KeyPair) error {
+	datadir := c.String(DataDirFlag.Name)
+	pemKey := crypto.NewPemKey(datadir)
+	keyFile := crypto.NewKeyFile(datadir)
+
+	//either file would be the node key after the import
+	for _, path := range []string{pemKey.Path(), keyFile.Path()} {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			continue
+		}
+		if !c.Bool(KeyForceFlag.Name) {
+			return cli.NewExitError(fmt.Sprintf("%s already exists. Use --force to replace it", path), 1)
+		}
+		backup := fmt.Sprintf("%s.bak-%s", path, time.Now().UTC().Format("20060102-150405"))
+		if err := os.Rename(path, backup); err != nil {
+			return err
+		}
+		fmt.Printf("Previous key saved to %s\n", backup)
+	}
+
+	if err := makeDataDir(datadir); err != nil {
+		return err
+	}
+	if c.Bool(KeyEncryptFlag.Name) {
+		passphrase, err := readPassphrase(c)
+		if err != nil {
+			return err
+		}
+		if err := keyFile.WriteKeyPair(key, passphrase); err != nil {
+			return err
+		}
+	} else if err := pemKey.WriteKeyPair(key); err != nil {
+		return err
+	}
+	return nil
+}
+
+//keygenSave generates the node key of the datadir instead of printing a new
+//key pair
+func keygenSave(c *cli.Context) error {
+	key, err := crypto.GenerateKeyPair(c.String(KeyAlgorithmFlag.Name))
+	if err != nil {
+		return err
+	}
+	if err := storeNodeKey(c, key); err != nil {
+		return err
+	}
+	fmt.Printf("Generated %s key %s in %s\n", key.Algorithm(), crypto.KeyPairPubKeyHex(key), c.String(DataDirFlag.Name))
+	fmt.Println("Run 'babble keys export' for its record in peers.json")
+	return nil
+}
+
+//keysEncrypt replaces priv_key.pem with priv_key.json. The key file is read
+//back before priv_key.pem is removed.
+func keysEncrypt(c *cli.Context) error {
+	datadir := c.String(DataDirFlag.Name)
+	pemKey := crypto.NewPemKey(datadir)
+	keyFile := crypto.NewKeyFile(datadir)
+	if keyFile.Exists() {
+		return cli.NewExitError(fmt.Sprintf("%s already exists", keyFile.Path()), 1)
+	}
+	key, err := pemKey.ReadKeyPair()
+	if err != nil {
+		return fmt.Errorf("%s: %s", pemKey.Path(), err)
+	}
+	if key == nil {
+		return fmt.Errorf("No key in %s. Generate one with 'babble keygen'", datadir)
+	}
+	passphrase, err := readPassphrase(c)
+	if err != nil {
+		return err
+	}
+
+	if err := keyFile.WriteKeyPair(key, passphrase); err != nil {
+		return err
+	}
+	check, err := keyFile.ReadKeyPair(passphrase)
+	if err != nil || check == nil || crypto.KeyPairPubKeyHex(check) != crypto.KeyPairPubKeyHex(key) {
+		os.Remove(keyFile.Path())
+		return fmt.Errorf("Could not read back %s: %v", keyFile.Path(), err)
+	}
+	if err := os.Remove(pemKey.Path()); err != nil {
+		return err
+	}
+	fmt.Printf("Encrypted %s into %s\n", crypto.KeyPairPubKeyHex(key), keyFile.Path())
+	return nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	stdnet "net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/babbleio/babble/crypto"
+	"github.com/babbleio/babble/net"
+	"github.com/babbleio/babble/node"
+)
+
+const (
+	k8sRefreshInterval = 15 * time.Second
+	k8sPollInterval    = 2 * time.Second
+	//consecutive listings that must return the same peers before starting
+	k8sStableListings = 3
+)
+
+//discoverKubernetesPeers announces the public key of this node on its pod and
+//waits until exactly the expected number of participants, this node among
+//them, have done the same. Every pod starts its hashgraph with the peers it
+//found, so they must all find the same ones: the set must be listed
+//k8sStableListings times in a row before it is used. The pod name is read from
+//HOSTNAME, which Kubernetes sets to the pod name.
+func discoverKubernetesPeers(selector, namespace string, expected int,
+	nodeAddr string, key crypto.KeyPair, logger *logrus.Logger) (*net.KubernetesPeers, []net.Peer, error) {
+
+	if key == nil {
+		return nil, nil, fmt.Errorf("No private key to announce")
+	}
+	if expected <= 0 {
+		return nil, nil, fmt.Errorf("--k8s_peers is required with --k8s_selector")
+	}
+
+	_, portStr, err := stdnet.SplitHostPort(nodeAddr)
+	if err != nil {
+		return nil, nil, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Invalid port in node_addr %s", nodeAddr)
+	}
+
+	store, err := net.NewKubernetesPeers(namespace, selector, port)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	podName, err := os.Hostname()
+	if err != nil {
+		return nil, nil, err
+	}
+	pubKey := crypto.KeyPairPubKeyHex(key)
+	if err := store.Announce(podName, pubKey); err != nil {
+		return nil, nil, err
+	}
+
+	var last []net.Peer
+	stable := 0
+	for {
+		peers, err := store.Peers()
+		switch {
+		case err != nil:
+			logger.WithField("error", err).Error("Listing Kubernetes peers")
+			stable = 0
+		case len(peers) != expected || !hasPeer(peers, pubKey):
+			if len(peers) > expected {
+				logger.WithFields(logrus.Fields{
+					"peers":    len(peers),
+					"expected": expected,
+				}).Warn("More Kubernetes peers than expected, check --k8s_selector")
+			} else {
+				logger.WithFields(logrus.Fields{
+					"peers":    len(peers),
+					"expected": expected,
+				}).Info("Waiting for Kubernetes peers")
+			}
+			stable = 0
+		default:
+			if samePeers(peers, last) {
+				stable++
+			} else {
+				stable = 1
+			}
+			if stable >= k8sStableListings {
+				logger.WithField("peers", len(peers)).Info("Discovered Kubernetes peers")
+				return store, peers, nil
+			}
+		}
+		last = peers
+		time.Sleep(k8sPollInterval)
+	}
+}
+
+//hasPeer is true if one of peers has the public key pubKey
+func hasPeer(peers []net.Peer, pubKey string) bool {
+	for _, p := range peers {
+		if p.PubKeyHex == pubKey {
+			return true
+		}
+	}
+	return false
+}
+
+//samePeers is true if a and b, both sorted by public key, list the same
+//participants at the same addresses
+func samePeers(a, b []net.Peer) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].PubKeyHex != b[i].PubKeyHex || a[i].NetAddr != b[i].NetAddr {
+			return false
+		}
+	}
+	return true
+}
+
+//refreshKubernetesPeers follows the participants when their pods are
+//rescheduled with a new IP
+func refreshKubernetesPeers(store *net.KubernetesPeers, n *node.Node, logger *logrus.Logger) {
+	for range time.Tick(k8sRefreshInterval) {
+		peers, err := store.Peers()
+		if err != nil {
+			logger.WithField("error", err).Error("Listing Kubernetes peers")
+			continue
+		}
+		n.UpdatePeerAddresses(peers)
+	}
+}
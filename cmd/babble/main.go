@@ -1,11 +1,16 @@
 package main
 
 import (
+	"crypto/tls"
+	"encoding/hex"
 	"fmt"
+	"io/ioutil"
 	"os"
 	"os/user"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	_ "net/http/pprof"
@@ -13,6 +18,9 @@ import (
 	"github.com/Sirupsen/logrus"
 	"gopkg.in/urfave/cli.v1"
 
+	"github.com/babbleio/babble/client"
+	"github.com/babbleio/babble/codec"
+	"github.com/babbleio/babble/common"
 	"github.com/babbleio/babble/crypto"
 	"github.com/babbleio/babble/net"
 	"github.com/babbleio/babble/node"
@@ -38,29 +46,96 @@ var (
 	}
 	ProxyAddressFlag = cli.StringFlag{
 		Name:  "proxy_addr",
-		Usage: "IP:Port to bind Proxy Server",
+		Usage: "Address to bind Proxy Server: IP:Port, unix:<path> or, on Windows, \\\\.\\pipe\\<name>",
 		Value: "127.0.0.1:1338",
 	}
 	ClientAddressFlag = cli.StringFlag{
 		Name:  "client_addr",
-		Usage: "IP:Port of Client App",
+		Usage: "Address of Client App: IP:Port, unix:<path> or, on Windows, \\\\.\\pipe\\<name>",
 		Value: "127.0.0.1:1339",
 	}
+	GrpcAddressFlag = cli.StringFlag{
+		Name:  "grpc_addr",
+		Usage: "IP:Port to bind the gRPC Proxy Server, used instead of proxy_addr and client_addr (see proxy/app/babble.proto)",
+	}
 	ServiceAddressFlag = cli.StringFlag{
 		Name:  "service_addr",
 		Usage: "IP:Port of HTTP Service",
 		Value: "127.0.0.1:80",
 	}
+	ProfileFlag = cli.StringFlag{
+		Name:  "profile",
+		Usage: "Preset of tuning parameters: embedded, standard, high-throughput. Other flags override it",
+		Value: "standard",
+	}
 	LogLevelFlag = cli.StringFlag{
 		Name:  "log_level",
 		Usage: "debug, info, warn, error, fatal, panic",
 		Value: "debug",
 	}
+	LogFileFlag = cli.StringFlag{
+		Name:  "log_file",
+		Usage: "Write logs to this file instead of stderr",
+	}
+	LogMaxSizeFlag = cli.IntFlag{
+		Name:  "log_max_size",
+		Usage: "Rotate the log file when it exceeds this many megabytes (0 = never)",
+		Value: 100,
+	}
+	LogMaxAgeFlag = cli.IntFlag{
+		Name:  "log_max_age",
+		Usage: "Rotate the log file after this many hours (0 = never)",
+	}
+	LogMaxBackupsFlag = cli.IntFlag{
+		Name:  "log_max_backups",
+		Usage: "Number of rotated log files to keep (0 = all)",
+		Value: 10,
+	}
+	LogCompressFlag = cli.BoolFlag{
+		Name:  "log_compress",
+		Usage: "Compress rotated log files with gzip",
+	}
 	HeartbeatFlag = cli.IntFlag{
 		Name:  "heartbeat",
 		Usage: "Heartbeat timer milliseconds (time between gossips)",
 		Value: 1000,
 	}
+	HeartbeatJitterFlag = cli.Float64Flag{
+		Name:  "heartbeat_jitter",
+		Usage: "Share of the heartbeat added at random to each delay between gossips, between 0 and 1 (0 = 1)",
+	}
+	MaxHeartbeatFlag = cli.IntFlag{
+		Name:  "max_heartbeat",
+		Usage: "Heartbeat milliseconds reached by backing off while no transactions arrive (0 = no backoff)",
+	}
+	MaxBackgroundJobsFlag = cli.IntFlag{
+		Name:  "max_background_jobs",
+		Usage: "Periodic background jobs run at the same time (0 = no limit)",
+	}
+	DutyWindowFlag = cli.IntFlag{
+		Name:  "duty_window",
+		Usage: "Consensus rounds and syncs the validator duties are computed over (0 = 100)",
+	}
+	MaxPoolTxsFlag = cli.IntFlag{
+		Name:  "max_pool_txs",
+		Usage: "Transactions in the pool above which submissions are refused (0 = no limit)",
+	}
+	MaxPoolBytesFlag = cli.IntFlag{
+		Name:  "max_pool_bytes",
+		Usage: "Bytes of transactions in the pool above which submissions are refused (0 = no limit)",
+	}
+	BlockOnFullPoolFlag = cli.BoolFlag{
+		Name:  "block_on_full_pool",
+		Usage: "Make submissions wait for room in a full transaction pool instead of refusing them",
+	}
+	VerifyWorkersFlag = cli.IntFlag{
+		Name:  "verify_workers",
+		Usage: "Goroutines checking the signatures of synced events in parallel (0 = one per CPU)",
+	}
+	FanOutFlag = cli.IntFlag{
+		Name:  "fan_out",
+		Usage: "Peers gossiped with concurrently in each gossip round (0 = 1)",
+	}
 	MaxPoolFlag = cli.IntFlag{
 		Name:  "max_pool",
 		Usage: "Max number of pooled connections",
@@ -71,18 +146,323 @@ var (
 		Usage: "TCP timeout milliseconds",
 		Value: 1000,
 	}
+	SyncTimeoutFlag = cli.IntFlag{
+		Name:  "sync_timeout",
+		Usage: "Sync RPC timeout milliseconds (default: tcp_timeout)",
+	}
+	EagerSyncTimeoutFlag = cli.IntFlag{
+		Name:  "eager_sync_timeout",
+		Usage: "EagerSync RPC timeout milliseconds (default: tcp_timeout)",
+	}
+	FastForwardTimeoutFlag = cli.IntFlag{
+		Name:  "fast_forward_timeout",
+		Usage: "FastForward RPC timeout milliseconds",
+		Value: 30000,
+	}
+	FastForwardChunkFlag = cli.IntFlag{
+		Name:  "fast_forward_chunk",
+		Usage: "Max bytes of the Frame and App snapshot received per FastForward request (0 = all at once)",
+		Value: 1024 * 1024,
+	}
+	PushBatchFlag = cli.IntFlag{
+		Name:  "push_batch",
+		Usage: "Max Events in each request that pushes Events to a peer, the next ones follow (0 = no limit but the sync limits)",
+	}
+	RPCRateLimitsFlag = cli.StringFlag{
+		Name:  "rpc_rate_limits",
+		Usage: "Requests per second accepted from each peer, by type of RPC (ex: sync=20,eager_sync=50)",
+	}
 	CacheSizeFlag = cli.IntFlag{
 		Name:  "cache_size",
 		Usage: "Number of items in LRU caches",
-		Value: 500,
+		Value: 1000,
 	}
 	SyncLimitFlag = cli.IntFlag{
 		Name:  "sync_limit",
 		Usage: "Max number of events for sync",
 		Value: 1000,
 	}
+	EventPolicyFlag = cli.StringFlag{
+		Name:  "event_policy",
+		Usage: "When to create events: sync, transactions, timer",
+		Value: "sync",
+	}
+	EventIntervalFlag = cli.IntFlag{
+		Name:  "event_interval",
+		Usage: "Min milliseconds between events with the timer event policy",
+		Value: 100,
+	}
+	K8sSelectorFlag = cli.StringFlag{
+		Name:  "k8s_selector",
+		Usage: "Discover peers from the Kubernetes pods matching this label selector instead of peers.json",
+	}
+	K8sNamespaceFlag = cli.StringFlag{
+		Name:  "k8s_namespace",
+		Usage: "Kubernetes namespace of the pods (default: namespace of the service account)",
+	}
+	K8sPeersFlag = cli.IntFlag{
+		Name:  "k8s_peers",
+		Usage: "Number of participants to wait for before starting, required with --k8s_selector",
+	}
+	CodecFlag = cli.StringFlag{
+		Name:  "codec",
+		Usage: "Wire format of the RPCs between nodes: gob, json, protobuf. Must be the same on every node",
+		Value: "gob",
+	}
+	ProtobufFlag = cli.BoolFlag{
+		Name:  "protobuf",
+		Usage: "Use protobuf instead of the codec with the peers that support it too",
+	}
+	NoMultiplexFlag = cli.BoolFlag{
+		Name:  "no_multiplex",
+		Usage: "Open separate connections to the peers instead of multiplexing the RPCs over one",
+	}
+	CompressFlag = cli.BoolFlag{
+		Name:  "compress",
+		Usage: "Compress the Events of syncs with the peers that accept it",
+	}
+	ShareConnectivityFlag = cli.BoolFlag{
+		Name:  "share_connectivity",
+		Usage: "Gossip connectivity measurements so that /Connectivity reports the whole cluster",
+	}
+	ConsensusCPUShareFlag = cli.Float64Flag{
+		Name:  "consensus_cpu_share",
+		Usage: "Max share of time spent computing consensus, between 0 and 1 (0 = no cap)",
+	}
+	ZoneFlag = cli.StringFlag{
+		Name:  "zone",
+		Usage: "Zone or region of the node, advertised to its peers",
+	}
+	ZoneAffinityFlag = cli.Float64Flag{
+		Name:  "zone_affinity",
+		Usage: "Share of gossip rounds with peers of the same zone, between 0 and 1 (0 = no bias)",
+	}
+	PeerSelectionFlag = cli.StringFlag{
+		Name:  "peer_selection",
+		Usage: "How to pick the peer of each gossip: random, round-robin, least-recent, lag-weighted",
+		Value: "random",
+	}
+	MaxPeersFlag = cli.IntFlag{
+		Name:  "max_peers",
+		Usage: "Number of peers gossiped with at a time, rotated over time (0 = all)",
+	}
+	PeerRotationFlag = cli.IntFlag{
+		Name:  "peer_rotation",
+		Usage: "Seconds between two rotations of the peers gossiped with, with --max_peers (0 = never)",
+		Value: 30,
+	}
+	SchemeVersionFlag = cli.IntFlag{
+		Name:  "scheme_version",
+		Usage: "Version of the scheme Events are hashed and signed with",
+	}
+	MinSchemeVersionFlag = cli.IntFlag{
+		Name:  "min_scheme_version",
+		Usage: "Oldest scheme version accepted in the Events of other nodes",
+	}
+	HashFlag = cli.StringFlag{
+		Name:  "hash",
+		Usage: "Hash function of Events and Blocks, chosen at genesis: sha256, blake2b-256 or keccak-256. Must be the same on every node",
+	}
+	CommitDedupRoundsFlag = cli.IntFlag{
+		Name:  "commit_dedup_rounds",
+		Usage: "Deliver a transaction committed again within this many rounds only once (0 = deliver every copy)",
+	}
+	OrphanRoundsFlag = cli.IntFlag{
+		Name:  "orphan_rounds",
+		Usage: "Rounds after which received Events whose parents never arrived are discarded",
+		Value: 10,
+	}
+	MetricsFlag = cli.BoolFlag{
+		Name:  "metrics",
+		Usage: "Serve Prometheus metrics on /metrics of the service address",
+	}
+	MaxEventPayloadFlag = cli.IntFlag{
+		Name:  "max_event_payload",
+		Usage: "Max bytes of transactions per event. Larger transactions are chunked (0 = no limit)",
+		Value: 1024 * 1024,
+	}
+	JoinFlag = cli.StringFlag{
+		Name:  "join",
+		Usage: "IP:Port of a participant of a running network to join instead of starting a new one",
+	}
+	JoinTimeoutFlag = cli.IntFlag{
+		Name:  "join_timeout",
+		Usage: "Milliseconds to wait for the network to accept the node with --join",
+		Value: 60000,
+	}
+	AdmitKeysFlag = cli.StringFlag{
+		Name:  "admit_keys",
+		Usage: "Comma-separated public keys of the nodes allowed to join the network, see also babble admin admit",
+	}
+	DrainTimeoutFlag = cli.IntFlag{
+		Name:  "drain_timeout",
+		Usage: "Milliseconds to wait for the syncs in flight when the node stops on a signal or a handoff",
+		Value: 5000,
+	}
+	StoreFlag = cli.StringFlag{
+		Name:  "store",
+		Usage: "Where the hashgraph is kept: inmem, badger",
+		Value: "inmem",
+	}
+	StorePathFlag = cli.StringFlag{
+		Name:  "store_path",
+		Usage: "Directory of the badger store (default: <datadir>/badger_db)",
+	}
+	MinFreeDiskFlag = cli.IntFlag{
+		Name:  "min_free_disk",
+		Usage: "MB of free space on the filesystem of the datadir below which the node refuses transactions. 0 disables",
+	}
+	ModeFlag = cli.StringFlag{
+		Name:  "mode",
+		Usage: "validator: prune the history of the badger store; archive: keep and serve the whole hashgraph",
+		Value: "validator",
+	}
+	PruneIntervalFlag = cli.IntFlag{
+		Name:  "prune_interval",
+		Usage: "Seconds between the prunings of the badger store of a validator, which drop the history below the last final Blocks. 0 means 60, negative disables",
+	}
+	PruneRetentionFlag = cli.IntFlag{
+		Name:  "prune_retention",
+		Usage: "Decided rounds kept when the store is pruned",
+		Value: node.DefaultPruneRetention,
+	}
+	TLSFlag = cli.BoolFlag{
+		Name:  "tls",
+		Usage: "Gossip over TLS authenticated by the node keys. Every node of the network must use it",
+	}
+	SignerURLFlag = cli.StringFlag{
+		Name:  "signer_url",
+		Usage: "URL of a remote signer holding the node key, used instead of priv_key.pem (see crypto/remote_signer.go)",
+	}
+	SignerAddressFlag = cli.StringFlag{
+		Name:  "signer_addr",
+		Usage: "IP:Port to serve the key of the datadir to nodes started with --signer_url",
+		Value: "127.0.0.1:8500",
+	}
+	KeyAlgorithmFlag = cli.StringFlag{
+		Name:  "algorithm",
+		Usage: "Algorithm of the key: ecdsa-p256 or ed25519. Every node of the network must use the same",
+		Value: crypto.ECDSAP256,
+	}
+	GenesisFlag = cli.StringFlag{
+		Name:  "genesis",
+		Usage: "File of the genesis state delivered to the App on the first start. Must be the same on every node",
+	}
+	HistoryFlag = cli.StringFlag{
+		Name:  "history",
+		Usage: "File written by the history command, committed to the App as pre-genesis Blocks on the first start. Must be the same on every node",
+	}
+	HistoryHashFlag = cli.StringFlag{
+		Name:  "history_hash",
+		Usage: "Expected hash of --history, in hex",
+	}
+	ObserverFlag = cli.BoolFlag{
+		Name:  "observer",
+		Usage: "Follow the hashgraph of the peers, from genesis unless --clone_from is set, without creating Events. The key must not be one of the peers",
+	}
+	CloneFromFlag = cli.StringFlag{
+		Name:  "clone_from",
+		Usage: "IP:Port of the node an --observer fast-forwards from first, see the clone command",
+	}
+	ServicePeersFlag = cli.StringFlag{
+		Name:  "service_peers",
+		Usage: "Comma-separated IP:Port of the services of other nodes, where submitted transactions are redirected when this node lags",
+	}
+	ServiceSubmitFlag = cli.BoolFlag{
+		Name:  "service_submit",
+		Usage: "Accept transactions on /SubmitTx of the service, for the tx send command. Anyone who can reach the service can then submit transactions",
+	}
+	RedirectLagFlag = cli.IntFlag{
+		Name:  "redirect_lag",
+		Usage: "Rounds behind the most advanced node before transactions are redirected, with --service_peers",
+		Value: 10,
+	}
 )
 
+var runFlags = []cli.Flag{
+	ConfigFileFlag,
+	DataDirFlag,
+	NodeAddressFlag,
+	NoClientFlag,
+	ProxyAddressFlag,
+	ClientAddressFlag,
+	GrpcAddressFlag,
+	ServiceAddressFlag,
+	ProfileFlag,
+	LogLevelFlag,
+	LogFileFlag,
+	LogMaxSizeFlag,
+	LogMaxAgeFlag,
+	LogMaxBackupsFlag,
+	LogCompressFlag,
+	HeartbeatFlag,
+	HeartbeatJitterFlag,
+	MaxHeartbeatFlag,
+	FanOutFlag,
+	VerifyWorkersFlag,
+	MaxBackgroundJobsFlag,
+	DutyWindowFlag,
+	MaxPoolTxsFlag,
+	MaxPoolBytesFlag,
+	BlockOnFullPoolFlag,
+	MaxPoolFlag,
+	TcpTimeoutFlag,
+	SyncTimeoutFlag,
+	EagerSyncTimeoutFlag,
+	FastForwardTimeoutFlag,
+	FastForwardChunkFlag,
+	PushBatchFlag,
+	RPCRateLimitsFlag,
+	CacheSizeFlag,
+	SyncLimitFlag,
+	MaxEventPayloadFlag,
+	EventPolicyFlag,
+	EventIntervalFlag,
+	CodecFlag,
+	ProtobufFlag,
+	CompressFlag,
+	NoMultiplexFlag,
+	ShareConnectivityFlag,
+	ZoneFlag,
+	ZoneAffinityFlag,
+	PeerSelectionFlag,
+	MaxPeersFlag,
+	PeerRotationFlag,
+	ConsensusCPUShareFlag,
+	SchemeVersionFlag,
+	MinSchemeVersionFlag,
+	HashFlag,
+	CommitDedupRoundsFlag,
+	OrphanRoundsFlag,
+	MetricsFlag,
+	K8sSelectorFlag,
+	K8sNamespaceFlag,
+	K8sPeersFlag,
+	JoinFlag,
+	JoinTimeoutFlag,
+	AdmitKeysFlag,
+	DrainTimeoutFlag,
+	StoreFlag,
+	StorePathFlag,
+	MinFreeDiskFlag,
+	ModeFlag,
+	PruneIntervalFlag,
+	PruneRetentionFlag,
+	TLSFlag,
+	GenesisFlag,
+	SignerURLFlag,
+	PassphraseFileFlag,
+	HistoryFlag,
+	HistoryHashFlag,
+	ObserverFlag,
+	CloneFromFlag,
+	ServicePeersFlag,
+	ServiceSubmitFlag,
+	RedirectLagFlag,
+	AdminAddressFlag,
+	AdminKeysFlag,
+}
+
 func main() {
 	app := cli.NewApp()
 	app.Name = "babble"
@@ -92,32 +472,67 @@ func main() {
 			Name:   "keygen",
 			Usage:  "Dump new key pair",
 			Action: keygen,
+			Flags:  []cli.Flag{KeyAlgorithmFlag, KeySaveFlag, DataDirFlag, KeyForceFlag, KeyEncryptFlag, PassphraseFileFlag},
 		},
+		keysCommand,
+		peersCommand,
 		{
 			Name:   "run",
 			Usage:  "Run node",
 			Action: run,
+			Flags:  runFlags,
+		},
+		txCommand,
+		selftestCommand,
+		{
+			Name:   "clone",
+			Usage:  "Prepare a datadir to run an observer cloned from a running node",
+			Action: clone,
 			Flags: []cli.Flag{
 				DataDirFlag,
-				NodeAddressFlag,
-				NoClientFlag,
-				ProxyAddressFlag,
-				ClientAddressFlag,
-				ServiceAddressFlag,
-				LogLevelFlag,
-				HeartbeatFlag,
-				MaxPoolFlag,
-				TcpTimeoutFlag,
-				CacheSizeFlag,
-				SyncLimitFlag,
+				CloneSourceFlag,
+				KeyForceFlag,
 			},
 		},
+		{
+			Name:   "backup",
+			Usage:  "Back up the hashgraph and store of a running node through its admin channel",
+			Action: backup,
+			Flags:  append(adminFlags, BackupOutFlag),
+		},
+		adminCommand,
+		{
+			Name:   "history",
+			Usage:  "Convert the transaction log of a legacy system into a history for --history",
+			Action: buildHistory,
+			Flags: []cli.Flag{
+				DataDirFlag,
+				HistoryLogFlag,
+				HistoryOutFlag,
+			},
+		},
+		{
+			Name:   "signer",
+			Usage:  "Serve the key of the datadir as a remote signer",
+			Action: serveSigner,
+			Flags:  []cli.Flag{DataDirFlag, SignerAddressFlag, PassphraseFileFlag},
+		},
+		{
+			Name:   "check-config",
+			Usage:  "Validate the configuration of the run command without starting the node",
+			Action: checkConfig,
+			Flags:  runFlags,
+		},
 	}
 	app.Run(os.Args)
 }
 
 func keygen(c *cli.Context) error {
-	pemDump, err := crypto.GeneratePemKey()
+	if c.Bool(KeySaveFlag.Name) {
+		return keygenSave(c)
+	}
+
+	pemDump, err := crypto.GeneratePemKeyPair(c.String(KeyAlgorithmFlag.Name))
 	if err != nil {
 		fmt.Println("Error generating PemDump")
 		os.Exit(2)
@@ -132,20 +547,64 @@ func keygen(c *cli.Context) error {
 }
 
 func run(c *cli.Context) error {
+	if err := applyConfigFile(c); err != nil {
+		return err
+	}
+
 	logger := logrus.New()
 	logger.Level = logLevel(c.String(LogLevelFlag.Name))
 
+	// Services have no console, so log to the datadir by default. They run as
+	// another account, whose profile is not the datadir of the user.
+	asService := runningAsService()
+	logFile := c.String(LogFileFlag.Name)
+	if asService {
+		if !c.IsSet(DataDirFlag.Name) {
+			if err := c.Set(DataDirFlag.Name, serviceDataDir()); err != nil {
+				return err
+			}
+		}
+		if err := makeDataDir(c.String(DataDirFlag.Name)); err != nil {
+			return err
+		}
+		if logFile == "" {
+			logFile = filepath.Join(c.String(DataDirFlag.Name), "babble.log")
+		}
+	}
+
+	if logFile != "" {
+		out, err := common.NewRotatingFile(logFile, common.RotatingFileConfig{
+			MaxSize:    int64(c.Int(LogMaxSizeFlag.Name)) * 1024 * 1024,
+			MaxAge:     time.Duration(c.Int(LogMaxAgeFlag.Name)) * time.Hour,
+			MaxBackups: c.Int(LogMaxBackupsFlag.Name),
+			Compress:   c.Bool(LogCompressFlag.Name),
+		})
+		if err != nil {
+			return err
+		}
+		defer out.Close()
+		logger.Out = out
+	}
+
 	datadir := c.String(DataDirFlag.Name)
 	addr := c.String(NodeAddressFlag.Name)
 	noclient := c.Bool(NoClientFlag.Name)
 	proxyAddress := c.String(ProxyAddressFlag.Name)
 	clientAddress := c.String(ClientAddressFlag.Name)
+	grpcAddress := c.String(GrpcAddressFlag.Name)
 	serviceAddress := c.String(ServiceAddressFlag.Name)
-	heartbeat := c.Int(HeartbeatFlag.Name)
-	maxPool := c.Int(MaxPoolFlag.Name)
-	tcpTimeout := c.Int(TcpTimeoutFlag.Name)
-	cacheSize := c.Int(CacheSizeFlag.Name)
-	syncLimit := c.Int(SyncLimitFlag.Name)
+	profile, err := node.GetProfile(c.String(ProfileFlag.Name))
+	if err != nil {
+		return err
+	}
+	heartbeat := profileInt(c, HeartbeatFlag, int(profile.HeartbeatTimeout/time.Millisecond))
+	maxPool := profileInt(c, MaxPoolFlag, profile.MaxPool)
+	tcpTimeout := profileInt(c, TcpTimeoutFlag, int(profile.TCPTimeout/time.Millisecond))
+	fastForwardTimeout := profileInt(c, FastForwardTimeoutFlag, int(profile.FastForwardTimeout/time.Millisecond))
+	cacheSize := profileInt(c, CacheSizeFlag, profile.CacheSize)
+	maxEventPayload := profileInt(c, MaxEventPayloadFlag, profile.MaxEventPayload)
+	eventPolicy := c.String(EventPolicyFlag.Name)
+	codecName := c.String(CodecFlag.Name)
 	logger.WithFields(logrus.Fields{
 		"datadir":      datadir,
 		"node_addr":    addr,
@@ -153,52 +612,197 @@ func run(c *cli.Context) error {
 		"proxy_addr":   proxyAddress,
 		"client_addr":  clientAddress,
 		"service_addr": serviceAddress,
+		"profile":      profile.Name,
 		"heartbeat":    heartbeat,
 		"max_pool":     maxPool,
 		"tcp_timeout":  tcpTimeout,
 		"cache_size":   cacheSize,
+		"max_payload":  maxEventPayload,
+		"event_policy": eventPolicy,
+		"codec":        codecName,
 	}).Debug("RUN")
 
-	conf := node.NewConfig(time.Duration(heartbeat)*time.Millisecond,
-		time.Duration(tcpTimeout)*time.Millisecond,
-		cacheSize, syncLimit, logger)
+	conf, err := nodeConfig(c, profile, logger)
+	if err != nil {
+		return err
+	}
+	if err := conf.Validate(); err != nil {
+		return err
+	}
 
-	// Create the PEM key
-	pemKey := crypto.NewPemKey(datadir)
+	wireCodec, err := codec.Get(codecName)
+	if err != nil {
+		return err
+	}
 
-	// Try a read
-	key, err := pemKey.ReadKey()
+	// Read the node key, or connect to the remote signer
+	key, err := nodeSigner(c, conf.TCPTimeout)
 	if err != nil {
 		return err
 	}
 
-	// Create the peer store
-	store := net.NewJSONPeers(datadir)
+	var peers []net.Peer
+	var k8sPeers *net.KubernetesPeers
+	if selector := c.String(K8sSelectorFlag.Name); selector != "" {
+		// Discover the peers from the Kubernetes API
+		k8sPeers, peers, err = discoverKubernetesPeers(selector,
+			c.String(K8sNamespaceFlag.Name), c.Int(K8sPeersFlag.Name),
+			addr, key, logger)
+		if err != nil {
+			return err
+		}
+	} else {
+		// Create the peer store
+		store := net.NewJSONPeers(datadir)
+
+		// Try a read
+		peers, err = store.Peers()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Prefer the addresses learned since the peers file was written
+	book, err := net.NewAddressBook(datadir)
+	if err != nil {
+		return err
+	}
+	if k8sPeers == nil {
+		var written time.Time
+		if info, err := os.Stat(filepath.Join(datadir, "peers.json")); err == nil {
+			written = info.ModTime()
+		}
+		resolved := book.Resolve(peers, written)
+		for i := range peers {
+			if resolved[i].NetAddr != peers[i].NetAddr {
+				logger.WithFields(logrus.Fields{
+					"pub_key": peers[i].PubKeyHex,
+					"file":    peers[i].NetAddr,
+					"learned": resolved[i].NetAddr,
+				}).Info("Using learned peer address")
+			}
+		}
+		peers = resolved
+	}
+	conf.AddressBook = book
 
-	// Try a read
-	peers, err := store.Peers()
+	// Use the consensus socket of the process we are taking over from, if any
+	inherited, err := inheritedListener()
 	if err != nil {
 		return err
 	}
 
-	trans, err := net.NewTCPTransport(addr,
-		nil, maxPool, conf.TCPTimeout, logger)
+	// Only accept the keys of the peers, and of the participants that joined
+	// through consensus once the node exists
+	var tlsConfig *tls.Config
+	var isParticipant atomic.Value
+	if c.Bool(TLSFlag.Name) {
+		tlsKey, ok := crypto.PrivateKey(key)
+		if !ok {
+			return fmt.Errorf("--tls requires the key of the datadir, not a remote signer")
+		}
+		registered := make(map[string]bool)
+		for _, p := range peers {
+			registered[p.PubKeyHex] = true
+		}
+		tlsConfig, err = net.PeerTLSConfig(tlsKey, func(pubKey string) bool {
+			if registered[pubKey] {
+				return true
+			}
+			f, ok := isParticipant.Load().(func(string) bool)
+			return ok && f(pubKey)
+		})
+		if err != nil {
+			return err
+		}
+		// The peers may also pin the certificates their nodes present
+		tlsConfig, err = net.PinPeerCertificates(tlsConfig, peers)
+		if err != nil {
+			return err
+		}
+	}
+
+	var trans *net.NetworkTransport
+	if inherited != nil {
+		trans, err = net.NewTCPTransportFromListener(inherited,
+			nil, maxPool, conf.TCPTimeout, tlsConfig, logger)
+	} else {
+		trans, err = net.NewTCPTransport(addr,
+			nil, maxPool, conf.TCPTimeout, tlsConfig, logger)
+	}
 	if err != nil {
 		return err
 	}
+	trans.SetCodec(wireCodec)
+	trans.SetRPCTimeouts(net.RPCTimeouts{
+		Sync:        time.Duration(c.Int(SyncTimeoutFlag.Name)) * time.Millisecond,
+		EagerSync:   time.Duration(c.Int(EagerSyncTimeoutFlag.Name)) * time.Millisecond,
+		FastForward: time.Duration(fastForwardTimeout) * time.Millisecond,
+	})
+
+	// The other ports are released when the previous process exits
+	if err := waitForParent(); err != nil {
+		return err
+	}
 
 	var prox proxy.AppProxy
 	if noclient {
 		prox = aproxy.NewInmemAppProxy(logger)
+	} else if grpcAddress != "" {
+		grpcProxy, err := aproxy.NewGrpcAppProxy(grpcAddress, logger)
+		if err != nil {
+			return err
+		}
+		prox = grpcProxy
 	} else {
 		prox = aproxy.NewSocketAppProxy(clientAddress, proxyAddress,
 			conf.TCPTimeout, logger)
 	}
 
 	node := node.NewNode(conf, key, peers, trans, prox)
-	node.Init()
+	isParticipant.Store(node.IsParticipant)
+	if inherited != nil {
+		if err := node.Resume(); err != nil {
+			return err
+		}
+	} else if target := c.String(JoinFlag.Name); target != "" {
+		timeout := time.Duration(c.Int(JoinTimeoutFlag.Name)) * time.Millisecond
+		if err := node.Join(target, timeout); err != nil {
+			return err
+		}
+	} else if err := node.Init(); err != nil {
+		return err
+	}
 
+	drainTimeout := time.Duration(c.Int(DrainTimeoutFlag.Name)) * time.Millisecond
+	stop := func() { node.GracefulShutdown(drainTimeout) }
+	watchStop(stop, logger)
+	watchHandoff(trans, stop, logger)
+	watchDiagnostics(&node, datadir, logger)
+	watchReload(&node, c.String(ConfigFileFlag.Name), logger)
+
+	if asService {
+		runService(stop, logger)
+	}
+
+	if k8sPeers != nil {
+		go refreshKubernetesPeers(k8sPeers, &node, logger)
+	}
+
+	adminServer, err := startAdmin(c, &node, key, datadir, logger)
+	if err != nil {
+		return err
+	}
 	serviceServer := service.NewService(serviceAddress, &node, logger)
+	if adminServer != nil {
+		defer adminServer.Close()
+	}
+	if c.Bool(ServiceSubmitFlag.Name) {
+		serviceServer.EnableSubmitTx()
+	}
+	if router := serviceRouter(c, serviceAddress); router != nil {
+		serviceServer.SetRouter(router, c.Int(RedirectLagFlag.Name))
+	}
 	go serviceServer.Serve()
 
 	node.Run(true)
@@ -206,6 +810,119 @@ func run(c *cli.Context) error {
 	return nil
 }
 
+//profileInt returns the value of flag if it is set on the command line, and
+//the value of the profile otherwise
+func profileInt(c *cli.Context, flag cli.IntFlag, profileValue int) int {
+	if c.IsSet(flag.Name) {
+		return c.Int(flag.Name)
+	}
+	return profileValue
+}
+
+//nodeConfig builds the node Config from the profile and the flags that
+//override it
+func nodeConfig(c *cli.Context, profile node.Profile, logger *logrus.Logger) (*node.Config, error) {
+	heartbeat := profileInt(c, HeartbeatFlag, int(profile.HeartbeatTimeout/time.Millisecond))
+	tcpTimeout := profileInt(c, TcpTimeoutFlag, int(profile.TCPTimeout/time.Millisecond))
+	conf := node.NewConfig(time.Duration(heartbeat)*time.Millisecond,
+		time.Duration(tcpTimeout)*time.Millisecond,
+		profileInt(c, CacheSizeFlag, profile.CacheSize),
+		profileInt(c, SyncLimitFlag, profile.SyncLimit),
+		logger)
+	conf.MaxEventPayload = profileInt(c, MaxEventPayloadFlag, profile.MaxEventPayload)
+	conf.SyncBytesLimit = profile.SyncBytesLimit
+	conf.StallTimeout = profile.StallTimeout
+	conf.InboundSyncs = profile.InboundSyncs
+	conf.InsertChunk = profile.InsertChunk
+	conf.FastForwardChunk = c.Int(FastForwardChunkFlag.Name)
+	conf.PushBatch = c.Int(PushBatchFlag.Name)
+	conf.ConsensusCPUShare = profile.ConsensusCPUShare
+	conf.HeartbeatJitter = c.Float64(HeartbeatJitterFlag.Name)
+	conf.MaxHeartbeat = time.Duration(c.Int(MaxHeartbeatFlag.Name)) * time.Millisecond
+	conf.FanOut = c.Int(FanOutFlag.Name)
+	conf.VerifyWorkers = c.Int(VerifyWorkersFlag.Name)
+	conf.MaxBackgroundJobs = c.Int(MaxBackgroundJobsFlag.Name)
+	conf.DutyWindow = c.Int(DutyWindowFlag.Name)
+	conf.MaxPoolTxs = c.Int(MaxPoolTxsFlag.Name)
+	conf.MaxPoolBytes = c.Int(MaxPoolBytesFlag.Name)
+	conf.BlockOnFullPool = c.Bool(BlockOnFullPoolFlag.Name)
+	rateLimits, err := net.ParseRPCRateLimits(c.String(RPCRateLimitsFlag.Name))
+	if err != nil {
+		return nil, err
+	}
+	conf.RPCRateLimits = rateLimits
+	if c.IsSet(ConsensusCPUShareFlag.Name) {
+		conf.ConsensusCPUShare = c.Float64(ConsensusCPUShareFlag.Name)
+	}
+	conf.CommitDedupRounds = c.Int(CommitDedupRoundsFlag.Name)
+	conf.OrphanRounds = c.Int(OrphanRoundsFlag.Name)
+	for _, k := range strings.Split(c.String(AdmitKeysFlag.Name), ",") {
+		if k = strings.TrimSpace(k); k != "" {
+			conf.AdmitKeys = append(conf.AdmitKeys, k)
+		}
+	}
+	conf.Capabilities = net.CapFetch | net.CapCompression | net.CapDictionary | net.CapPushBatches
+	if !c.Bool(NoMultiplexFlag.Name) {
+		conf.Capabilities |= net.CapMultiplex
+	}
+	if c.Bool(ProtobufFlag.Name) {
+		conf.Capabilities |= net.CapProtobuf
+	}
+	conf.CompressEvents = c.Bool(CompressFlag.Name)
+	conf.Store = c.String(StoreFlag.Name)
+	conf.StorePath = c.String(StorePathFlag.Name)
+	if conf.StorePath == "" {
+		conf.StorePath = filepath.Join(c.String(DataDirFlag.Name), "badger_db")
+	}
+	conf.MinFreeDisk = int64(c.Int(MinFreeDiskFlag.Name)) * 1024 * 1024
+	conf.DiskPath = c.String(DataDirFlag.Name)
+	switch mode := c.String(ModeFlag.Name); mode {
+	case "validator":
+	case "archive":
+		conf.Archive = true
+	default:
+		return nil, fmt.Errorf("Unknown mode %s", mode)
+	}
+	conf.PruneInterval = time.Duration(c.Int(PruneIntervalFlag.Name)) * time.Second
+	conf.PruneRetention = c.Int(PruneRetentionFlag.Name)
+	policy, err := node.NewEventCreationPolicy(c.String(EventPolicyFlag.Name),
+		time.Duration(c.Int(EventIntervalFlag.Name))*time.Millisecond)
+	if err != nil {
+		return nil, err
+	}
+	conf.EventPolicy = policy
+	conf.ShareConnectivity = c.Bool(ShareConnectivityFlag.Name)
+	conf.Metrics = c.Bool(MetricsFlag.Name)
+	conf.Zone = c.String(ZoneFlag.Name)
+	conf.ZoneAffinity = c.Float64(ZoneAffinityFlag.Name)
+	conf.PeerSelection = c.String(PeerSelectionFlag.Name)
+	conf.MaxActivePeers = c.Int(MaxPeersFlag.Name)
+	conf.PeerRotation = time.Duration(c.Int(PeerRotationFlag.Name)) * time.Second
+	conf.SchemeVersion = c.Int(SchemeVersionFlag.Name)
+	conf.MinSchemeVersion = c.Int(MinSchemeVersionFlag.Name)
+	conf.Hash = c.String(HashFlag.Name)
+	conf.Observer = c.Bool(ObserverFlag.Name)
+	conf.CloneFrom = c.String(CloneFromFlag.Name)
+	if genesis := c.String(GenesisFlag.Name); genesis != "" {
+		if conf.GenesisState, err = ioutil.ReadFile(genesis); err != nil {
+			return nil, err
+		}
+	}
+	if path := c.String(HistoryFlag.Name); path != "" {
+		history, err := readHistory(path)
+		if err != nil {
+			return nil, err
+		}
+		conf.History = &history
+	}
+	if hash := c.String(HistoryHashFlag.Name); hash != "" {
+		if conf.HistoryHash, err = hex.DecodeString(strings.TrimPrefix(hash, "0x")); err != nil {
+			return nil, fmt.Errorf("Invalid --history_hash: %s", err)
+		}
+	}
+	return conf, nil
+}
+
 func defaultDataDir() string {
 	// Try to place the data folder in the user's home dir
 	home := homeDir()
@@ -213,6 +930,9 @@ func defaultDataDir() string {
 		if runtime.GOOS == "darwin" {
 			return filepath.Join(home, "Library", "BABBLE")
 		} else if runtime.GOOS == "windows" {
+			if appData := os.Getenv("APPDATA"); appData != "" {
+				return filepath.Join(appData, "BABBLE")
+			}
 			return filepath.Join(home, "AppData", "Roaming", "BABBLE")
 		} else {
 			return filepath.Join(home, ".babble")
@@ -222,6 +942,15 @@ func defaultDataDir() string {
 	return ""
 }
 
+//makeDataDir creates datadir if needed and restricts it to the user of the
+//node, which keeps the private key and the database away from other users
+func makeDataDir(datadir string) error {
+	if err := os.MkdirAll(datadir, 0700); err != nil {
+		return err
+	}
+	return common.RestrictDir(datadir)
+}
+
 func homeDir() string {
 	if home := os.Getenv("HOME"); home != "" {
 		return home
@@ -250,3 +979,18 @@ func logLevel(l string) logrus.Level {
 		return logrus.DebugLevel
 	}
 }
+
+//serviceRouter ranks this node and the nodes of --service_peers, or returns
+//nil if there are none
+func serviceRouter(c *cli.Context, serviceAddress string) *client.Router {
+	addrs := []string{}
+	for _, addr := range strings.Split(c.String(ServicePeersFlag.Name), ",") {
+		if addr = strings.TrimSpace(addr); addr != "" {
+			addrs = append(addrs, addr)
+		}
+	}
+	if len(addrs) == 0 {
+		return nil
+	}
+	return client.NewRouter(append(addrs, serviceAddress), time.Second)
+}
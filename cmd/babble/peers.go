@@ -0,0 +1,158 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/babbleio/babble/crypto"
+	"github.com/babbleio/babble/net"
+)
+
+var peersCommand = cli.Command{
+	Name:  "peers",
+	Usage: "Write and check the peers.json of the datadir",
+	Subcommands: []cli.Command{
+		{
+			Name:      "generate",
+			Usage:     "Write peers.json from the records printed by 'babble keys export' on each node",
+			ArgsUsage: "<record file>...",
+			Action:    peersGenerate,
+			Flags:     []cli.Flag{DataDirFlag, KeyForceFlag},
+		},
+		{
+			Name:   "validate",
+			Usage:  "Check the peers.json of the datadir, and the node key against it",
+			Action: peersValidate,
+			Flags:  []cli.Flag{DataDirFlag, NodeAddressFlag, PassphraseFileFlag},
+		},
+		{
+			Name:      "pin",
+			Usage:     "Print the TLSPins of PEM certificates, or the SPKI pin of the node key for --tls",
+			ArgsUsage: "[<cert.pem>...]",
+			Action:    peersPin,
+			Flags:     []cli.Flag{DataDirFlag, PassphraseFileFlag},
+		},
+	},
+}
+
+//readPeerRecords reads a file holding one peer record, or a list of them
+func readPeerRecords(path string) ([]net.Peer, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(strings.TrimSpace(string(data)), "[") {
+		var peers []net.Peer
+		if err := json.Unmarshal(data, &peers); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		return peers, nil
+	}
+	var peer net.Peer
+	if err := json.Unmarshal(data, &peer); err != nil {
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	return []net.Peer{peer}, nil
+}
+
+func peersGenerate(c *cli.Context) error {
+	if c.NArg() == 0 {
+		return cli.NewExitError("Missing peer record files", 1)
+	}
+	peers := []net.Peer{}
+	for _, path := range c.Args() {
+		records, err := readPeerRecords(path)
+		if err != nil {
+			return err
+		}
+		peers = append(peers, records...)
+	}
+
+	datadir := c.String(DataDirFlag.Name)
+	if _, err := os.Stat(filepath.Join(datadir, "peers.json")); err == nil && !c.Bool(KeyForceFlag.Name) {
+		return cli.NewExitError(fmt.Sprintf("%s already has a peers.json. Use --force to replace it", datadir), 1)
+	}
+
+	//the records are checked like check-config does, before anything is written
+	checker := &configChecker{}
+	checker.checkPeerList(peers)
+	if checker.errors > 0 {
+		return cli.NewExitError(fmt.Sprintf("%d problem(s) found", checker.errors), 1)
+	}
+
+	if err := makeDataDir(datadir); err != nil {
+		return err
+	}
+	if err := net.NewJSONPeers(datadir).SetPeers(peers); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d peers to %s\n", len(peers), datadir)
+	return nil
+}
+
+func peersValidate(c *cli.Context) error {
+	datadir := c.String(DataDirFlag.Name)
+	checker := &configChecker{}
+
+	//peers.json can be checked on its own, without a key
+	key, _, err := loadNodeKey(c, datadir)
+	if err != nil {
+		checker.fail("", "node key: %s", err)
+	}
+	checker.checkPeers(datadir, key, c.String(NodeAddressFlag.Name), key == nil)
+
+	if checker.errors > 0 {
+		return cli.NewExitError(fmt.Sprintf("%d problem(s) found", checker.errors), 1)
+	}
+	return nil
+}
+
+//peersPin prints the pins of the certificates given as arguments. Without
+//arguments, it prints the SPKI pin of the certificates --tls makes of the node
+//key, which only depends on the key.
+func peersPin(c *cli.Context) error {
+	if c.NArg() == 0 {
+		key, _, err := loadNodeKey(c, c.String(DataDirFlag.Name))
+		if err != nil {
+			return err
+		}
+		if key == nil {
+			return cli.NewExitError("No node key in the datadir", 1)
+		}
+		tlsKey, ok := crypto.PrivateKey(key)
+		if !ok {
+			return cli.NewExitError("--tls requires the key of the datadir, not a remote signer", 1)
+		}
+		spki, err := x509.MarshalPKIXPublicKey(tlsKey.Public())
+		if err != nil {
+			return err
+		}
+		fmt.Println(net.SPKIPin(&x509.Certificate{RawSubjectPublicKeyInfo: spki}))
+		return nil
+	}
+
+	for _, path := range c.Args() {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		block, _ := pem.Decode(data)
+		if block == nil || block.Type != "CERTIFICATE" {
+			return cli.NewExitError(fmt.Sprintf("%s: no PEM certificate", path), 1)
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+		fmt.Printf("%s\n  %s\n  %s\n", path, net.SPKIPin(cert), net.CertificatePin(cert))
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/babbleio/babble/node"
+)
+
+//readReloadable reads the parameters a running node can change from the file
+//of --config: heartbeat, sync_limit and log_level, the other ones being left to
+//the next restart
+func readReloadable(path string) (node.Reloadable, error) {
+	var r node.Reloadable
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+	var values map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&values); err != nil {
+		return r, fmt.Errorf("%s: %s", path, err)
+	}
+
+	intValue := func(name string) (int, error) {
+		v, ok := values[name]
+		if !ok {
+			return 0, nil
+		}
+		i, err := strconv.Atoi(fmt.Sprint(v))
+		if err != nil {
+			return 0, fmt.Errorf("%s: %s must be an integer", path, name)
+		}
+		return i, nil
+	}
+	heartbeat, err := intValue(HeartbeatFlag.Name)
+	if err != nil {
+		return r, err
+	}
+	r.HeartbeatTimeout = time.Duration(heartbeat) * time.Millisecond
+	if r.SyncLimit, err = intValue(SyncLimitFlag.Name); err != nil {
+		return r, err
+	}
+	if v, ok := values[LogLevelFlag.Name]; ok {
+		r.LogLevel = fmt.Sprint(v)
+	}
+	return r, nil
+}
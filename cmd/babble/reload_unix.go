@@ -0,0 +1,36 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+
+	"github.com/babbleio/babble/node"
+)
+
+//watchReload reloads the heartbeat, sync limit and log level of the --config
+//file on SIGHUP. Without a file, SIGHUP keeps its default behaviour.
+func watchReload(n *node.Node, path string, logger *logrus.Logger) {
+	if path == "" {
+		return
+	}
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+
+	go func() {
+		for range sigCh {
+			r, err := readReloadable(path)
+			if err == nil {
+				err = n.Reload(r)
+			}
+			if err != nil {
+				logger.WithField("error", err).Error("Reloading config")
+			}
+		}
+	}()
+}
@@ -0,0 +1,15 @@
+package main
+
+import (
+	"github.com/Sirupsen/logrus"
+
+	"github.com/babbleio/babble/node"
+)
+
+//watchReload is not supported on Windows, which has no SIGHUP. The admin
+//channel reloads the config instead.
+func watchReload(n *node.Node, path string, logger *logrus.Logger) {
+	if path != "" {
+		logger.Debug("Reloading the config on signal is not supported on Windows")
+	}
+}
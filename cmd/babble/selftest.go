@@ -0,0 +1,382 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	gonet "net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/babbleio/babble/crypto"
+	"github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+	"github.com/babbleio/babble/service"
+)
+
+/*
+selftest checks an installation end to end, with the binary that runs it:
+
+ 1. it generates the keys and the peers.json of a cluster of --nodes nodes in
+    a temporary directory, on free ports of 127.0.0.1,
+ 2. it starts each node with 'babble run --no_client', so that Blocks are
+    committed to the built-in in-memory App,
+ 3. it submits --txs transactions through the HTTP services, round robin, and
+    waits until every node committed all of them,
+ 4. it compares the Blocks of every node up to the last one that carries a
+    transaction: the signatures aside, they must be identical.
+
+Each step is printed with PASS or FAIL. The nodes are stopped in any case, and
+the directory is removed, unless a step failed or --keep is set, so that the
+logs of the nodes can be read.
+*/
+
+var (
+	SelftestNodesFlag = cli.IntFlag{
+		Name:  "nodes",
+		Usage: "Number of nodes in the test cluster",
+		Value: 4,
+	}
+	SelftestTxsFlag = cli.IntFlag{
+		Name:  "txs",
+		Usage: "Number of transactions to commit",
+		Value: 20,
+	}
+	SelftestTimeoutFlag = cli.IntFlag{
+		Name:  "timeout",
+		Usage: "Seconds to wait for the nodes to start, and then for the transactions to be committed",
+		Value: 60,
+	}
+	SelftestKeepFlag = cli.BoolFlag{
+		Name:  "keep",
+		Usage: "Keep the datadirs and logs of the nodes",
+	}
+)
+
+var selftestCommand = cli.Command{
+	Name:   "selftest",
+	Usage:  "Run a local cluster with this binary and check that its nodes commit identical ledgers",
+	Action: selftest,
+	Flags: []cli.Flag{
+		SelftestNodesFlag,
+		SelftestTxsFlag,
+		SelftestTimeoutFlag,
+		SelftestKeepFlag,
+		KeyAlgorithmFlag,
+	},
+}
+
+//interval between two polls of the nodes of the test cluster
+const selftestPollInterval = 100 * time.Millisecond
+
+//selftestNode is a node of the test cluster
+type selftestNode struct {
+	datadir     string
+	nodeAddr    string
+	serviceAddr string
+	cmd         *exec.Cmd
+}
+
+func (n *selftestNode) url(path string) string {
+	return "http://" + n.serviceAddr + path
+}
+
+//selftestReport prints the result of each step, and remembers whether one
+//failed
+type selftestReport struct {
+	failed bool
+}
+
+//step runs f unless a previous step failed. f returns a detail to print
+//after the name of the step.
+func (r *selftestReport) step(name string, f func() (string, error)) {
+	if r.failed {
+		fmt.Printf("SKIP  %s\n", name)
+		return
+	}
+	start := time.Now()
+	detail, err := f()
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		r.failed = true
+		fmt.Printf("FAIL  %s: %s (%s)\n", name, err, elapsed)
+		return
+	}
+	if detail != "" {
+		name += ": " + detail
+	}
+	fmt.Printf("PASS  %s (%s)\n", name, elapsed)
+}
+
+func selftest(c *cli.Context) error {
+	size := c.Int(SelftestNodesFlag.Name)
+	txs := c.Int(SelftestTxsFlag.Name)
+	timeout := time.Duration(c.Int(SelftestTimeoutFlag.Name)) * time.Second
+	if size < 1 || txs < 1 {
+		return cli.NewExitError("--nodes and --txs must be positive", 1)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	dir, err := ioutil.TempDir("", "babble-selftest")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Self-test of %s with %d nodes and %d transactions in %s\n", exe, size, txs, dir)
+	report := &selftestReport{}
+	var nodes []*selftestNode
+	var hashes []string
+	var lastBlock int
+
+	report.step("generate keys and peers", func() (string, error) {
+		var err error
+		nodes, err = selftestCluster(dir, size, c.String(KeyAlgorithmFlag.Name))
+		return "", err
+	})
+	defer func() { stopSelftestNodes(nodes) }()
+
+	report.step("start nodes", func() (string, error) {
+		for _, n := range nodes {
+			if err := n.start(exe); err != nil {
+				return "", err
+			}
+		}
+		for _, n := range nodes {
+			if err := n.waitReady(time.Now().Add(timeout)); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("%d nodes Babbling", len(nodes)), nil
+	})
+
+	report.step("submit transactions", func() (string, error) {
+		var err error
+		hashes, err = submitSelftestTxs(nodes, txs)
+		return "", err
+	})
+
+	report.step("commit on every node", func() (string, error) {
+		var err error
+		lastBlock, err = waitSelftestCommits(nodes, hashes, time.Now().Add(timeout))
+		return fmt.Sprintf("up to Block %d", lastBlock), err
+	})
+
+	report.step("compare ledgers", func() (string, error) {
+		return compareSelftestLedgers(nodes, lastBlock, txs)
+	})
+
+	stopSelftestNodes(nodes)
+	nodes = nil
+	if report.failed || c.Bool(SelftestKeepFlag.Name) {
+		fmt.Printf("The datadirs and logs of the nodes are in %s\n", dir)
+	} else {
+		os.RemoveAll(dir)
+	}
+	if report.failed {
+		return cli.NewExitError("FAIL", 1)
+	}
+	fmt.Println("PASS")
+	return nil
+}
+
+//selftestCluster writes the keys and the peers.json of size nodes in dir
+func selftestCluster(dir string, size int, algorithm string) ([]*selftestNode, error) {
+	nodes := []*selftestNode{}
+	peers := []net.Peer{}
+	for i := 0; i < size; i++ {
+		n := &selftestNode{datadir: filepath.Join(dir, fmt.Sprintf("node%d", i))}
+		var err error
+		if n.nodeAddr, err = freeLocalAddr(); err != nil {
+			return nil, err
+		}
+		if n.serviceAddr, err = freeLocalAddr(); err != nil {
+			return nil, err
+		}
+		key, err := crypto.GenerateKeyPair(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		if err := makeDataDir(n.datadir); err != nil {
+			return nil, err
+		}
+		if err := crypto.NewPemKey(n.datadir).WriteKeyPair(key); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+		peers = append(peers, net.Peer{
+			NetAddr:      n.nodeAddr,
+			PubKeyHex:    crypto.KeyPairPubKeyHex(key),
+			KeyAlgorithm: key.Algorithm(),
+		})
+	}
+	for _, n := range nodes {
+		if err := net.NewJSONPeers(n.datadir).SetPeers(peers); err != nil {
+			return nil, err
+		}
+	}
+	return nodes, nil
+}
+
+//freeLocalAddr returns an address of 127.0.0.1 with a port that is free now
+func freeLocalAddr() (string, error) {
+	l, err := gonet.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
+
+func (n *selftestNode) start(exe string) error {
+	n.cmd = exec.Command(exe, "run",
+		"--datadir", n.datadir,
+		"--node_addr", n.nodeAddr,
+		"--service_addr", n.serviceAddr,
+		"--no_client",
+		"--heartbeat", "10",
+		"--log_level", "info",
+		"--log_file", filepath.Join(n.datadir, "babble.log"),
+	)
+	return n.cmd.Start()
+}
+
+//waitReady waits until the node is Babbling
+func (n *selftestNode) waitReady(deadline time.Time) error {
+	client := &http.Client{Timeout: time.Second}
+	for time.Now().Before(deadline) {
+		var stats map[string]string
+		if err := getJSON(client, n.url("/Stats"), &stats); err == nil && stats["state"] == "Babbling" {
+			return nil
+		}
+		time.Sleep(selftestPollInterval)
+	}
+	return fmt.Errorf("%s not Babbling before the timeout, see %s", n.nodeAddr, filepath.Join(n.datadir, "babble.log"))
+}
+
+//stopSelftestNodes interrupts the nodes, or kills them where interrupts are not
+//supported, and waits for them to exit
+func stopSelftestNodes(nodes []*selftestNode) {
+	for _, n := range nodes {
+		if n.cmd == nil || n.cmd.Process == nil {
+			continue
+		}
+		if err := n.cmd.Process.Signal(os.Interrupt); err != nil {
+			n.cmd.Process.Kill()
+		}
+		done := make(chan struct{})
+		go func() {
+			n.cmd.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			n.cmd.Process.Kill()
+			<-done
+		}
+		n.cmd = nil
+	}
+}
+
+//submitSelftestTxs submits count transactions to the nodes in turn, and returns
+//their hashes
+func submitSelftestTxs(nodes []*selftestNode, count int) ([]string, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	hashes := []string{}
+	for i := 0; i < count; i++ {
+		n := nodes[i%len(nodes)]
+		tx := []byte(fmt.Sprintf("selftest %d", i))
+		resp, err := client.Post(n.url("/SubmitTx"), "application/octet-stream", bytes.NewReader(tx))
+		if err != nil {
+			return nil, err
+		}
+		var submitted service.TxResponse
+		if err := decodeTxResponse(resp, http.StatusAccepted, &submitted); err != nil {
+			return nil, fmt.Errorf("%s: %s", n.nodeAddr, err)
+		}
+		hashes = append(hashes, submitted.Hash)
+	}
+	return hashes, nil
+}
+
+//waitSelftestCommits waits until every node committed the transactions, and
+//returns the highest watermark they were committed at
+func waitSelftestCommits(nodes []*selftestNode, hashes []string, deadline time.Time) (int, error) {
+	client := &http.Client{Timeout: time.Second}
+	watermark := -1
+	for _, n := range nodes {
+		for _, h := range hashes {
+			for {
+				var status service.TxResponse
+				resp, err := client.Get(n.url("/Tx/" + h))
+				if err == nil {
+					err = decodeTxResponse(resp, http.StatusOK, &status)
+				}
+				if err == nil && status.Committed {
+					if status.Watermark > watermark {
+						watermark = status.Watermark
+					}
+					break
+				}
+				if time.Now().After(deadline) {
+					return 0, fmt.Errorf("%s did not commit transaction %s before the timeout", n.nodeAddr, h)
+				}
+				time.Sleep(selftestPollInterval)
+			}
+		}
+	}
+	return watermark, nil
+}
+
+//compareSelftestLedgers checks that the nodes committed the same Blocks up to
+//lastBlock, and that they carry the txs transactions
+func compareSelftestLedgers(nodes []*selftestNode, lastBlock, txs int) (string, error) {
+	client := &http.Client{Timeout: 10 * time.Second}
+	committed := 0
+	for i := 0; i <= lastBlock; i++ {
+		var first hashgraph.Block
+		for j, n := range nodes {
+			var block hashgraph.Block
+			path := fmt.Sprintf("/Block/%d?watermark=%d", i, lastBlock)
+			if err := getJSON(client, n.url(path), &block); err != nil {
+				return "", fmt.Errorf("%s: Block %d: %s", n.nodeAddr, i, err)
+			}
+			//each node collects the signatures it received
+			block.Signatures = nil
+			if j == 0 {
+				first = block
+				continue
+			}
+			if !reflect.DeepEqual(block, first) {
+				return "", fmt.Errorf("Block %d of %s differs from %s", i, n.nodeAddr, nodes[0].nodeAddr)
+			}
+		}
+		committed += len(first.Transactions)
+	}
+	if committed != txs {
+		return "", fmt.Errorf("%d transactions in the ledger instead of %d", committed, txs)
+	}
+	return fmt.Sprintf("%d identical Blocks", lastBlock+1), nil
+}
+
+func getJSON(client *http.Client, url string, out interface{}) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, bytes.TrimSpace(msg))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package main
+
+import "github.com/Sirupsen/logrus"
+
+//runningAsService is only true on Windows. Elsewhere, service managers run the
+//node like any other process.
+func runningAsService() bool {
+	return false
+}
+
+func runService(shutdown func(), logger *logrus.Logger) {}
+
+func serviceDataDir() string {
+	return defaultDataDir()
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/Sirupsen/logrus"
+	"golang.org/x/sys/windows/svc"
+)
+
+const serviceName = "babble"
+
+//runningAsService reports whether the process was started by the Windows
+//Service Control Manager
+func runningAsService() bool {
+	interactive, err := svc.IsAnInteractiveSession()
+	return err == nil && !interactive
+}
+
+//serviceDataDir is the datadir of a service without --datadir. Services run as
+//LocalSystem or a service account, whose roaming profile is no place for the
+//node's data.
+func serviceDataDir() string {
+	programData := os.Getenv("ProgramData")
+	if programData == "" {
+		programData = `C:\ProgramData`
+	}
+	return filepath.Join(programData, "BABBLE")
+}
+
+//runService reports the node to the Service Control Manager and calls
+//shutdown when the service is stopped
+func runService(shutdown func(), logger *logrus.Logger) {
+	go func() {
+		err := svc.Run(serviceName, &serviceHandler{
+			shutdown: shutdown,
+			logger:   logger,
+		})
+		if err != nil {
+			logger.WithField("error", err).Error("Running Windows service")
+		}
+	}()
+}
+
+type serviceHandler struct {
+	shutdown func()
+	logger   *logrus.Logger
+}
+
+func (h *serviceHandler) Execute(args []string, r <-chan svc.ChangeRequest, s chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown
+	s <- svc.Status{State: svc.Running, Accepts: accepted}
+
+	for c := range r {
+		switch c.Cmd {
+		case svc.Interrogate:
+			s <- c.CurrentStatus
+		case svc.Stop, svc.Shutdown:
+			h.logger.Info("Windows service stopping")
+			s <- svc.Status{State: svc.StopPending}
+			h.shutdown()
+			return false, 0
+		default:
+			h.logger.WithField("cmd", c.Cmd).Debug("Unexpected service control request")
+		}
+	}
+	return false, 0
+}
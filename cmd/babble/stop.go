@@ -0,0 +1,23 @@
+package main
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/Sirupsen/logrus"
+)
+
+//watchStop calls stop on SIGINT or SIGTERM, which drains the node before it
+//exits. A second signal interrupts the drain.
+func watchStop(stop func(), logger *logrus.Logger) {
+	sigCh := make(chan os.Signal, 2)
+	signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		for sig := range sigCh {
+			logger.WithField("signal", sig.String()).Info("Stopping")
+			go stop()
+		}
+	}()
+}
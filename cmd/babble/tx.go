@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/urfave/cli.v1"
+
+	"github.com/babbleio/babble/service"
+)
+
+var (
+	TxHexFlag = cli.BoolFlag{
+		Name:  "hex",
+		Usage: "The argument is hex encoded",
+	}
+	TxFileFlag = cli.StringFlag{
+		Name:  "file",
+		Usage: "Read the transaction from this file (- for stdin) instead of the argument",
+	}
+	TxWaitFlag = cli.BoolFlag{
+		Name:  "wait",
+		Usage: "Wait until the transaction is committed",
+	}
+	TxTimeoutFlag = cli.IntFlag{
+		Name:  "timeout",
+		Usage: "Seconds to wait for the commitment with --wait",
+		Value: 30,
+	}
+)
+
+var txCommand = cli.Command{
+	Name:  "tx",
+	Usage: "Interact with the transactions of a running node",
+	Subcommands: []cli.Command{
+		{
+			Name:      "send",
+			Usage:     "Submit a transaction through the HTTP service of a node",
+			ArgsUsage: "[transaction]",
+			Action:    txSend,
+			Flags: []cli.Flag{
+				ServiceAddressFlag,
+				TxHexFlag,
+				TxFileFlag,
+				TxWaitFlag,
+				TxTimeoutFlag,
+			},
+		},
+	},
+}
+
+//interval between two polls of the transaction status with --wait
+const txPollInterval = 200 * time.Millisecond
+
+//txSend prints the hash of the submitted transaction and, with --wait, exits
+//with an error if it is not committed in time
+func txSend(c *cli.Context) error {
+	tx, err := readTxArg(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	baseURL := fmt.Sprintf("http://%s", c.String(ServiceAddressFlag.Name))
+
+	var submitted service.TxResponse
+	resp, err := client.Post(baseURL+"/SubmitTx", "application/octet-stream", bytes.NewReader(tx))
+	if err != nil {
+		return err
+	}
+	err = decodeTxResponse(resp, http.StatusAccepted, &submitted)
+	if err != nil {
+		return err
+	}
+	fmt.Println(submitted.Hash)
+
+	if !c.Bool(TxWaitFlag.Name) {
+		return nil
+	}
+
+	deadline := time.Now().Add(time.Duration(c.Int(TxTimeoutFlag.Name)) * time.Second)
+	for time.Now().Before(deadline) {
+		var status service.TxResponse
+		resp, err := client.Get(baseURL + "/Tx/" + submitted.Hash)
+		if err != nil {
+			return err
+		}
+		if err := decodeTxResponse(resp, http.StatusOK, &status); err != nil {
+			return err
+		}
+		if status.Committed {
+			fmt.Printf("Committed at %s, watermark %d\n", status.Time.Format(time.RFC3339Nano), status.Watermark)
+			return nil
+		}
+		time.Sleep(txPollInterval)
+	}
+	return cli.NewExitError("Transaction not committed before the timeout", 2)
+}
+
+func readTxArg(c *cli.Context) ([]byte, error) {
+	var tx []byte
+	if file := c.String(TxFileFlag.Name); file != "" {
+		if c.NArg() > 0 {
+			return nil, fmt.Errorf("Give either --file or a transaction argument, not both")
+		}
+		var err error
+		if file == "-" {
+			tx, err = ioutil.ReadAll(os.Stdin)
+		} else {
+			tx, err = ioutil.ReadFile(file)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		if c.NArg() != 1 {
+			return nil, fmt.Errorf("Usage: babble tx send [--hex] <transaction> or --file <file>")
+		}
+		tx = []byte(c.Args().First())
+	}
+
+	if c.Bool(TxHexFlag.Name) {
+		decoded, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(tx)), "0x"))
+		if err != nil {
+			return nil, fmt.Errorf("Invalid hex transaction: %s", err)
+		}
+		tx = decoded
+	}
+	if len(tx) == 0 {
+		return nil, fmt.Errorf("Empty transaction")
+	}
+	return tx, nil
+}
+
+func decodeTxResponse(resp *http.Response, status int, out *service.TxResponse) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != status {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -18,12 +18,12 @@ var (
 	}
 	ProxyAddressFlag = cli.StringFlag{
 		Name:  "proxy_addr",
-		Usage: "IP:Port to bind Proxy Server",
+		Usage: "Address to bind Proxy Server: IP:Port, unix:<path> or, on Windows, \\\\.\\pipe\\<name>",
 		Value: "127.0.0.1:1338",
 	}
 	ClientAddressFlag = cli.StringFlag{
 		Name:  "client_addr",
-		Usage: "IP:Port of Client App",
+		Usage: "Address of Client App: IP:Port, unix:<path> or, on Windows, \\\\.\\pipe\\<name>",
 		Value: "127.0.0.1:1339",
 	}
 	LogLevelFlag = cli.StringFlag{
@@ -0,0 +1,162 @@
+/*
+Package codec defines how Babble serializes the values it sends over the wire
+and writes to storage.
+
+The format used by the transport is selected in the configuration and must be
+the same on every node of a network. Event hashes are always computed on the
+Gob encoding, whatever the transport uses, so that changing the wire format
+does not change the hashgraph.
+*/
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+//Encoder writes values to a stream
+type Encoder interface {
+	Encode(v interface{}) error
+}
+
+//Decoder reads values written by the Encoder of the same Codec. When created on
+//a *bufio.Reader, it does not read past the end of a value so that the stream
+//can be shared with other readers.
+type Decoder interface {
+	Decode(v interface{}) error
+}
+
+//Codec creates Encoders and Decoders for a serialization format
+type Codec interface {
+	Name() string
+	NewEncoder(w io.Writer) Encoder
+	NewDecoder(r io.Reader) Decoder
+}
+
+var (
+	//Gob is the default Codec. It is also used to compute hashes.
+	Gob Codec = gobCodec{}
+	//JSON is slower and larger than Gob but readable by other languages
+	JSON Codec = jsonCodec{}
+)
+
+var codecs = map[string]Codec{
+	Gob.Name():  Gob,
+	JSON.Name(): JSON,
+}
+
+//Register makes a Codec available to Get. It is meant to be called from init
+//functions, for example to add a protobuf Codec for generated message types.
+func Register(c Codec) {
+	codecs[c.Name()] = c
+}
+
+//Get returns the Codec called name. An empty name returns Gob.
+func Get(name string) (Codec, error) {
+	if name == "" {
+		return Gob, nil
+	}
+	c, ok := codecs[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown codec %s", name)
+	}
+	return c, nil
+}
+
+//Names returns the names of the registered Codecs
+func Names() []string {
+	res := []string{}
+	for name := range codecs {
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	return res
+}
+
+//Marshal encodes v with c
+func Marshal(c Codec, v interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := c.NewEncoder(&b).Encode(v); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+//Unmarshal decodes data with c into v
+func Unmarshal(c Codec, data []byte, v interface{}) error {
+	return c.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//GOB
+
+type gobCodec struct{}
+
+func (gobCodec) Name() string {
+	return "gob"
+}
+
+func (gobCodec) NewEncoder(w io.Writer) Encoder {
+	return gob.NewEncoder(w)
+}
+
+func (gobCodec) NewDecoder(r io.Reader) Decoder {
+	return gob.NewDecoder(r)
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//JSON
+
+//jsonCodec writes one value per line. encoding/json never writes raw newlines
+//inside a value.
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return "json"
+}
+
+func (jsonCodec) NewEncoder(w io.Writer) Encoder {
+	return json.NewEncoder(w)
+}
+
+//json.Decoder reads ahead, so values are read line by line instead
+func (jsonCodec) NewDecoder(r io.Reader) Decoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &jsonDecoder{r: br, max: maxJSONValue}
+}
+
+//largest value accepted by the JSON decoder, like maxProtoMessage
+const maxJSONValue = 256 * 1024 * 1024
+
+type jsonDecoder struct {
+	r   *bufio.Reader
+	max int //bytes of the longest line read
+}
+
+//Decode fails as soon as a line is longer than max, instead of buffering
+//whatever a peer sends before its newline
+func (d *jsonDecoder) Decode(v interface{}) error {
+	var line []byte
+	for {
+		chunk, err := d.r.ReadSlice('\n')
+		if len(line)+len(chunk) > d.max {
+			return fmt.Errorf("JSON value of more than %d bytes", d.max)
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return err
+		}
+		return json.Unmarshal(line, v)
+	}
+}
@@ -0,0 +1,102 @@
+package codec
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type testValue struct {
+	Name   string
+	Data   [][]byte
+	Known  map[int]int
+	Stamp  time.Time
+	hidden int
+}
+
+func TestRoundTrip(t *testing.T) {
+	in := testValue{
+		Name:  "value",
+		Data:  [][]byte{[]byte("line\nbreak"), []byte{0, 1, 2}},
+		Known: map[int]int{0: 1, 5: 10},
+		Stamp: time.Date(2018, 3, 1, 12, 0, 0, 42, time.UTC),
+	}
+	for _, c := range []Codec{Gob, JSON} {
+		data, err := Marshal(c, in)
+		if err != nil {
+			t.Fatalf("%s: %s", c.Name(), err)
+		}
+		var out testValue
+		if err := Unmarshal(c, data, &out); err != nil {
+			t.Fatalf("%s: %s", c.Name(), err)
+		}
+		if !reflect.DeepEqual(in, out) {
+			t.Fatalf("%s: value should be %#v, not %#v", c.Name(), in, out)
+		}
+	}
+}
+
+//The transport interleaves raw bytes with encoded values on the same stream
+func TestDecoderDoesNotReadAhead(t *testing.T) {
+	for _, c := range []Codec{Gob, JSON} {
+		var b bytes.Buffer
+		enc := c.NewEncoder(&b)
+		for i := 0; i < 3; i++ {
+			b.WriteByte(byte(i))
+			if err := enc.Encode(testValue{Name: "v", Known: map[int]int{i: i}}); err != nil {
+				t.Fatalf("%s: %s", c.Name(), err)
+			}
+		}
+
+		r := bufio.NewReader(&b)
+		dec := c.NewDecoder(r)
+		for i := 0; i < 3; i++ {
+			marker, err := r.ReadByte()
+			if err != nil {
+				t.Fatalf("%s: %s", c.Name(), err)
+			}
+			if int(marker) != i {
+				t.Fatalf("%s: marker should be %d, not %d", c.Name(), i, marker)
+			}
+			var out testValue
+			if err := dec.Decode(&out); err != nil {
+				t.Fatalf("%s: %s", c.Name(), err)
+			}
+			if out.Known[i] != i {
+				t.Fatalf("%s: value %d decoded as %#v", c.Name(), i, out)
+			}
+		}
+	}
+}
+
+func TestJSONValueLimit(t *testing.T) {
+	var out testValue
+	small := &jsonDecoder{r: bufio.NewReaderSize(bytes.NewReader([]byte(`{"Name":"a"}`+"\n")), 16), max: 64}
+	if err := small.Decode(&out); err != nil || out.Name != "a" {
+		t.Fatalf("A short value should be decoded, got %#v (%v)", out, err)
+	}
+
+	//a line longer than the buffer of the reader and than the limit
+	long := append(bytes.Repeat([]byte(" "), 100), []byte(`{"Name":"a"}`+"\n")...)
+	d := &jsonDecoder{r: bufio.NewReaderSize(bytes.NewReader(long), 16), max: 64}
+	if err := d.Decode(&out); err == nil {
+		t.Fatal("A value longer than the limit should be refused")
+	}
+}
+
+func TestGet(t *testing.T) {
+	if c, err := Get(""); err != nil || c != Gob {
+		t.Fatalf("Default codec should be gob, got %v, %v", c, err)
+	}
+	if c, err := Get("json"); err != nil || c != JSON {
+		t.Fatalf("json codec should be found, got %v, %v", c, err)
+	}
+	if _, err := Get("xml"); err == nil {
+		t.Fatal("Get should fail for unknown codecs")
+	}
+	if names := Names(); !reflect.DeepEqual(names, []string{"gob", "json", "protobuf"}) {
+		t.Fatalf("Names should be gob, json and protobuf, not %v", names)
+	}
+}
@@ -0,0 +1,300 @@
+package codec
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+/*
+Protobuf encodes the values that implement ProtoMessage, plus strings for the
+errors of the transport. The messages are written by hand with the helpers below
+instead of being generated, so that Babble does not depend on the protobuf
+libraries, but they follow the .proto files published next to the types, so
+that other languages can decode them.
+
+On a stream, every value is preceded by its length as a varint, like the
+writeDelimited functions of the protobuf libraries.
+*/
+
+//largest message accepted by the Protobuf decoder
+const maxProtoMessage = 256 * 1024 * 1024
+
+//Protobuf wire types
+const (
+	WireVarint  = 0
+	WireFixed64 = 1
+	WireBytes   = 2
+	WireFixed32 = 5
+)
+
+//ProtoMessage is implemented by the values that the Protobuf Codec can encode,
+//usually with MarshalProto on the value and UnmarshalProto on the pointer
+type ProtoMessage interface {
+	protoMarshaler
+	UnmarshalProto(data []byte) error
+}
+
+type protoMarshaler interface {
+	MarshalProto() []byte
+}
+
+//Protobuf is a compact format that other languages can read with the published
+//.proto files. It only encodes ProtoMessages and strings.
+var Protobuf Codec = protoCodec{}
+
+func init() {
+	Register(Protobuf)
+}
+
+type protoCodec struct{}
+
+func (protoCodec) Name() string {
+	return "protobuf"
+}
+
+func (protoCodec) NewEncoder(w io.Writer) Encoder {
+	return &protoEncoder{w: w}
+}
+
+func (protoCodec) NewDecoder(r io.Reader) Decoder {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &protoDecoder{r: br}
+}
+
+type protoEncoder struct {
+	w io.Writer
+}
+
+func (e *protoEncoder) Encode(v interface{}) error {
+	var msg []byte
+	switch v := v.(type) {
+	case protoMarshaler:
+		msg = v.MarshalProto()
+	case string:
+		msg = []byte(v)
+	case nil:
+	default:
+		return fmt.Errorf("Protobuf codec can not encode %T", v)
+	}
+	_, err := e.w.Write(append(appendVarint(nil, uint64(len(msg))), msg...))
+	return err
+}
+
+type protoDecoder struct {
+	r *bufio.Reader
+}
+
+func (d *protoDecoder) Decode(v interface{}) error {
+	size, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return err
+	}
+	if size > maxProtoMessage {
+		return fmt.Errorf("Protobuf message of %d bytes is too large", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(d.r, msg); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return err
+	}
+	switch v := v.(type) {
+	case ProtoMessage:
+		return v.UnmarshalProto(msg)
+	case *string:
+		*v = string(msg)
+		return nil
+	default:
+		return fmt.Errorf("Protobuf codec can not decode %T", v)
+	}
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//Writing messages
+
+func appendTag(b []byte, field int, wireType int) []byte {
+	return appendVarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+func appendVarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func zigzag(v int64) uint64 {
+	return uint64(v<<1) ^ uint64(v>>63)
+}
+
+//AppendVarint appends a uint64 field, unless v is zero
+func AppendVarint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	return appendVarint(appendTag(b, field, WireVarint), v)
+}
+
+//AppendOneofVarint appends a uint64 field even if v is zero, as the members of
+//a oneof must be written to tell which one is set
+func AppendOneofVarint(b []byte, field int, v uint64) []byte {
+	return appendVarint(appendTag(b, field, WireVarint), v)
+}
+
+//AppendInt appends a sint64 field, unless v is zero. Babble uses -1 in many
+//places, which zigzag encoding keeps to a single byte.
+func AppendInt(b []byte, field int, v int) []byte {
+	return AppendVarint(b, field, zigzag(int64(v)))
+}
+
+//AppendBool appends a bool field, unless v is false
+func AppendBool(b []byte, field int, v bool) []byte {
+	if !v {
+		return b
+	}
+	return AppendVarint(b, field, 1)
+}
+
+//AppendString appends a string field, unless v is empty
+func AppendString(b []byte, field int, v string) []byte {
+	if v == "" {
+		return b
+	}
+	return AppendBytes(b, field, []byte(v))
+}
+
+//AppendBytes appends a length-delimited field, even if v is empty, so that it
+//can write the elements of repeated fields. Embedded messages are appended with
+//it too.
+func AppendBytes(b []byte, field int, v []byte) []byte {
+	b = appendVarint(appendTag(b, field, WireBytes), uint64(len(v)))
+	return append(b, v...)
+}
+
+//AppendTime appends a Time message, unless t is zero. The offset of the zone is
+//only written for times that are not in UTC, so that the Gob encoding of the
+//decoded time, on which Event hashes depend, is the same as the original.
+func AppendTime(b []byte, field int, t time.Time) []byte {
+	if t.IsZero() {
+		return b
+	}
+	var msg []byte
+	msg = AppendInt(msg, 1, int(t.Unix()))
+	msg = AppendVarint(msg, 2, uint64(t.Nanosecond()))
+	if t.Location() != time.UTC {
+		_, offset := t.Zone()
+		msg = appendVarint(appendTag(msg, 3, WireVarint), zigzag(int64(offset)))
+	}
+	return AppendBytes(b, field, msg)
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//Reading messages
+
+//ProtoField is a field read from a message. Varint holds the value of varint
+//fields and Bytes the content of length-delimited ones.
+type ProtoField struct {
+	Number int
+	Type   int
+	Varint uint64
+	Bytes  []byte
+}
+
+//Int decodes a sint64 field
+func (f ProtoField) Int() int {
+	return int(int64(f.Varint>>1) ^ -int64(f.Varint&1))
+}
+
+//Bool decodes a bool field
+func (f ProtoField) Bool() bool {
+	return f.Varint != 0
+}
+
+//String decodes a string field
+func (f ProtoField) String() string {
+	return string(f.Bytes)
+}
+
+//Copy returns a copy of a bytes field, which otherwise shares the memory of the
+//message
+func (f ProtoField) Copy() []byte {
+	return append([]byte{}, f.Bytes...)
+}
+
+//Time decodes a Time message
+func (f ProtoField) Time() (time.Time, error) {
+	var sec, nsec int
+	var offset *int
+	err := ReadFields(f.Bytes, func(f ProtoField) error {
+		switch f.Number {
+		case 1:
+			sec = f.Int()
+		case 2:
+			nsec = int(f.Varint)
+		case 3:
+			o := f.Int()
+			offset = &o
+		}
+		return nil
+	})
+	if err != nil {
+		return time.Time{}, err
+	}
+	t := time.Unix(int64(sec), int64(nsec))
+	if offset == nil {
+		return t.UTC(), nil
+	}
+	return t.In(time.FixedZone("", *offset)), nil
+}
+
+//ReadFields calls f with every field of a message, in order. Fixed-size fields,
+//which Babble does not use, are skipped.
+func ReadFields(msg []byte, f func(field ProtoField) error) error {
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+		if n <= 0 {
+			return errors.New("Invalid protobuf tag")
+		}
+		msg = msg[n:]
+		field := ProtoField{Number: int(tag >> 3), Type: int(tag & 7)}
+
+		switch field.Type {
+		case WireVarint:
+			field.Varint, n = binary.Uvarint(msg)
+			if n <= 0 {
+				return errors.New("Invalid protobuf varint")
+			}
+			msg = msg[n:]
+		case WireBytes:
+			l, n := binary.Uvarint(msg)
+			if n <= 0 || l > uint64(len(msg)-n) {
+				return errors.New("Invalid protobuf length")
+			}
+			field.Bytes = msg[n : n+int(l)]
+			msg = msg[n+int(l):]
+		case WireFixed64, WireFixed32:
+			size := 8
+			if field.Type == WireFixed32 {
+				size = 4
+			}
+			if len(msg) < size {
+				return errors.New("Truncated protobuf field")
+			}
+			msg = msg[size:]
+			continue
+		default:
+			return fmt.Errorf("Unsupported protobuf wire type %d", field.Type)
+		}
+		if err := f(field); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+// +build !windows
+
+package common
+
+import "os"
+
+//RestrictDir makes the directory at path only accessible to the user of the
+//process
+func RestrictDir(path string) error {
+	return os.Chmod(path, 0700)
+}
@@ -0,0 +1,89 @@
+package common
+
+import (
+	"fmt"
+	"syscall"
+	"unsafe"
+)
+
+var (
+	convertSDDL               = advapi32.NewProc("ConvertStringSecurityDescriptorToSecurityDescriptorW")
+	getSecurityDescriptorDacl = advapi32.NewProc("GetSecurityDescriptorDacl")
+	setNamedSecurityInfo      = advapi32.NewProc("SetNamedSecurityInfoW")
+)
+
+const (
+	sddlRevision          = 1
+	seFileObject          = 1
+	daclSecurityInfo      = 0x4
+	protectedDaclSecurity = 0x80000000
+
+	//SYSTEM, the administrators and the user of the process, inherited by
+	//the files and directories created inside
+	dirSDDL = "D:P(A;OICI;FA;;;SY)(A;OICI;FA;;;BA)(A;OICI;FA;;;%s)"
+)
+
+// RestrictDir makes the directory at path only accessible to the user of the
+// process, SYSTEM and the administrators, in place of the permissions it
+// inherits from its parent
+func RestrictDir(path string) error {
+	sd, err := ownerSecurityDescriptor(dirSDDL)
+	if err != nil {
+		return err
+	}
+	defer syscall.LocalFree(syscall.Handle(sd))
+
+	var present, defaulted int32
+	var dacl uintptr
+	r, _, err := getSecurityDescriptorDacl.Call(sd,
+		uintptr(unsafe.Pointer(&present)),
+		uintptr(unsafe.Pointer(&dacl)),
+		uintptr(unsafe.Pointer(&defaulted)))
+	if r == 0 {
+		return err
+	}
+	p, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return err
+	}
+	r, _, _ = setNamedSecurityInfo.Call(uintptr(unsafe.Pointer(p)),
+		seFileObject,
+		daclSecurityInfo|protectedDaclSecurity,
+		0,
+		0,
+		dacl,
+		0)
+	if r != 0 {
+		return fmt.Errorf("Restricting %s: %s", path, syscall.Errno(r))
+	}
+	return nil
+}
+
+// ownerSecurityDescriptor returns the security descriptor of sddl, a format
+// that takes the SID of the user of the process. It must be freed with
+// LocalFree.
+func ownerSecurityDescriptor(sddl string) (uintptr, error) {
+	token, err := syscall.OpenCurrentProcessToken()
+	if err != nil {
+		return 0, err
+	}
+	defer token.Close()
+	user, err := token.GetTokenUser()
+	if err != nil {
+		return 0, err
+	}
+	sid, err := user.User.Sid.String()
+	if err != nil {
+		return 0, err
+	}
+	s, err := syscall.UTF16PtrFromString(fmt.Sprintf(sddl, sid))
+	if err != nil {
+		return 0, err
+	}
+	var sd uintptr
+	r, _, err := convertSDDL.Call(uintptr(unsafe.Pointer(s)), sddlRevision, uintptr(unsafe.Pointer(&sd)), 0)
+	if r == 0 {
+		return 0, err
+	}
+	return sd, nil
+}
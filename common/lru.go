@@ -2,17 +2,33 @@ package common
 
 //TAKEN FROM HASHICORP LRU
 
-import "container/list"
+import (
+	"container/list"
+	"sync"
+)
 
 // EvictCallback is used to get a callback when a cache entry is evicted
 type EvictCallback func(key interface{}, value interface{})
 
-// LRU implements a non-thread safe fixed size LRU cache
+// LRU implements a fixed size LRU cache. It is safe for concurrent use so that
+// the read paths of the hashgraph, which also record hits and recent-ness, can
+// run in parallel.
 type LRU struct {
+	l         sync.Mutex
 	size      int
 	evictList *list.List
 	items     map[interface{}]*list.Element
 	onEvict   EvictCallback
+	hits      int
+	misses    int
+}
+
+// LRUStats reports the usage of an LRU cache
+type LRUStats struct {
+	Size   int
+	Len    int
+	Hits   int
+	Misses int
 }
 
 // entry is used to hold a value in the evictList
@@ -34,6 +50,8 @@ func NewLRU(size int, onEvict EvictCallback) *LRU {
 
 // Purge is used to completely clear the cache
 func (c *LRU) Purge() {
+	c.l.Lock()
+	defer c.l.Unlock()
 	for k, v := range c.items {
 		if c.onEvict != nil {
 			c.onEvict(k, v.Value.(*entry).value)
@@ -45,6 +63,8 @@ func (c *LRU) Purge() {
 
 // Add adds a value to the cache.  Returns true if an eviction occurred.
 func (c *LRU) Add(key, value interface{}) bool {
+	c.l.Lock()
+	defer c.l.Unlock()
 	// Check for existing item
 	if ent, ok := c.items[key]; ok {
 		c.evictList.MoveToFront(ent)
@@ -67,16 +87,22 @@ func (c *LRU) Add(key, value interface{}) bool {
 
 // Get looks up a key's value from the cache.
 func (c *LRU) Get(key interface{}) (value interface{}, ok bool) {
+	c.l.Lock()
+	defer c.l.Unlock()
 	if ent, ok := c.items[key]; ok {
 		c.evictList.MoveToFront(ent)
+		c.hits++
 		return ent.Value.(*entry).value, true
 	}
+	c.misses++
 	return
 }
 
 // Check if a key is in the cache, without updating the recent-ness
 // or deleting it for being stale.
 func (c *LRU) Contains(key interface{}) (ok bool) {
+	c.l.Lock()
+	defer c.l.Unlock()
 	_, ok = c.items[key]
 	return ok
 }
@@ -84,6 +110,8 @@ func (c *LRU) Contains(key interface{}) (ok bool) {
 // Returns the key value (or undefined if not found) without updating
 // the "recently used"-ness of the key.
 func (c *LRU) Peek(key interface{}) (value interface{}, ok bool) {
+	c.l.Lock()
+	defer c.l.Unlock()
 	if ent, ok := c.items[key]; ok {
 		return ent.Value.(*entry).value, true
 	}
@@ -93,6 +121,8 @@ func (c *LRU) Peek(key interface{}) (value interface{}, ok bool) {
 // Remove removes the provided key from the cache, returning if the
 // key was contained.
 func (c *LRU) Remove(key interface{}) bool {
+	c.l.Lock()
+	defer c.l.Unlock()
 	if ent, ok := c.items[key]; ok {
 		c.removeElement(ent)
 		return true
@@ -102,6 +132,8 @@ func (c *LRU) Remove(key interface{}) bool {
 
 // RemoveOldest removes the oldest item from the cache.
 func (c *LRU) RemoveOldest() (interface{}, interface{}, bool) {
+	c.l.Lock()
+	defer c.l.Unlock()
 	ent := c.evictList.Back()
 	if ent != nil {
 		c.removeElement(ent)
@@ -113,6 +145,8 @@ func (c *LRU) RemoveOldest() (interface{}, interface{}, bool) {
 
 // GetOldest returns the oldest entry
 func (c *LRU) GetOldest() (interface{}, interface{}, bool) {
+	c.l.Lock()
+	defer c.l.Unlock()
 	ent := c.evictList.Back()
 	if ent != nil {
 		kv := ent.Value.(*entry)
@@ -123,6 +157,8 @@ func (c *LRU) GetOldest() (interface{}, interface{}, bool) {
 
 // Keys returns a slice of the keys in the cache, from oldest to newest.
 func (c *LRU) Keys() []interface{} {
+	c.l.Lock()
+	defer c.l.Unlock()
 	keys := make([]interface{}, len(c.items))
 	i := 0
 	for ent := c.evictList.Back(); ent != nil; ent = ent.Prev() {
@@ -134,9 +170,24 @@ func (c *LRU) Keys() []interface{} {
 
 // Len returns the number of items in the cache.
 func (c *LRU) Len() int {
+	c.l.Lock()
+	defer c.l.Unlock()
 	return c.evictList.Len()
 }
 
+// Stats returns the size, length and hit/miss counts of Get since the cache
+// was created.
+func (c *LRU) Stats() LRUStats {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return LRUStats{
+		Size:   c.size,
+		Len:    c.evictList.Len(),
+		Hits:   c.hits,
+		Misses: c.misses,
+	}
+}
+
 // removeOldest removes the oldest item from the cache.
 func (c *LRU) removeOldest() {
 	ent := c.evictList.Back()
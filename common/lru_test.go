@@ -1,6 +1,9 @@
 package common
 
-import "testing"
+import (
+	"sync"
+	"testing"
+)
 
 func TestLRU(t *testing.T) {
 	evictCounter := 0
@@ -151,3 +154,26 @@ func TestLRU_Peek(t *testing.T) {
 		t.Errorf("should not have updated recent-ness of 1")
 	}
 }
+
+// Test that concurrent readers and writers keep the cache consistent
+func TestLRU_Concurrent(t *testing.T) {
+	l := NewLRU(64, nil)
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				l.Add(w*1000+i, i)
+				l.Get(i)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	stats := l.Stats()
+	if stats.Len != 64 || stats.Hits+stats.Misses != 4000 {
+		t.Fatalf("bad stats: %#v", stats)
+	}
+}
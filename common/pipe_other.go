@@ -0,0 +1,18 @@
+//go:build !windows
+// +build !windows
+
+package common
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+func listenPipe(addr string) (net.Listener, error) {
+	return nil, fmt.Errorf("Named pipe %s is only supported on Windows", addr)
+}
+
+func dialPipe(addr string, timeout time.Duration) (net.Conn, error) {
+	return nil, fmt.Errorf("Named pipe %s is only supported on Windows", addr)
+}
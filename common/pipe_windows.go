@@ -0,0 +1,286 @@
+package common
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+/*
+Named pipes are opened for overlapped I/O, so that a read and a write can be
+pending on the same handle at the same time, like the requests and responses of
+an RPC connection. Each operation waits on its own event.
+
+The pipe only accepts local clients, and its security descriptor grants access
+to the user of the process, SYSTEM and the administrators. An App run by another
+user must be given the same account, or run as an administrator.
+*/
+
+var (
+	kernel32 = syscall.NewLazyDLL("kernel32.dll")
+	advapi32 = syscall.NewLazyDLL("advapi32.dll")
+
+	createNamedPipe     = kernel32.NewProc("CreateNamedPipeW")
+	connectNamedPipe    = kernel32.NewProc("ConnectNamedPipe")
+	waitNamedPipe       = kernel32.NewProc("WaitNamedPipeW")
+	createEvent         = kernel32.NewProc("CreateEventW")
+	getOverlappedResult = kernel32.NewProc("GetOverlappedResult")
+)
+
+const (
+	pipeAccessDuplex        = 0x3
+	pipeFirstInstance       = 0x80000
+	pipeRejectRemoteClients = 0x8
+	pipeUnlimitedInstances  = 255
+	pipeBufferSize          = 64 * 1024
+
+	errPipeBusy      = syscall.Errno(231)
+	errNoData        = syscall.Errno(232)
+	errPipeConnected = syscall.Errno(535)
+
+	//SYSTEM, the administrators and the user of the process
+	pipeSDDL = "D:P(A;;GA;;;SY)(A;;GA;;;BA)(A;;GA;;;%s)"
+)
+
+var errPipeClosed = errors.New("Named pipe closed")
+
+type pipeAddr string
+
+func (a pipeAddr) Network() string { return "pipe" }
+func (a pipeAddr) String() string  { return string(a) }
+
+type pipeListener struct {
+	name *uint16
+	addr pipeAddr
+	sa   *syscall.SecurityAttributes
+
+	lock   sync.Mutex
+	next   *pipeConn //instance the next client connects to
+	closed bool
+}
+
+func listenPipe(addr string) (net.Listener, error) {
+	name, err := syscall.UTF16PtrFromString(addr)
+	if err != nil {
+		return nil, err
+	}
+	sa, err := pipeSecurity()
+	if err != nil {
+		return nil, err
+	}
+	l := &pipeListener{name: name, addr: pipeAddr(addr), sa: sa}
+	//the first instance fails if another process already serves the pipe
+	if l.next, err = l.newInstance(pipeFirstInstance); err != nil {
+		syscall.LocalFree(syscall.Handle(sa.SecurityDescriptor))
+		return nil, err
+	}
+	return l, nil
+}
+
+//pipeSecurity returns the security attributes of a pipe that only the user of
+//the process, SYSTEM and the administrators can open
+func pipeSecurity() (*syscall.SecurityAttributes, error) {
+	sd, err := ownerSecurityDescriptor(pipeSDDL)
+	if err != nil {
+		return nil, err
+	}
+	return &syscall.SecurityAttributes{
+		Length:             uint32(unsafe.Sizeof(syscall.SecurityAttributes{})),
+		SecurityDescriptor: sd,
+	}, nil
+}
+
+func (l *pipeListener) newInstance(flags uint32) (*pipeConn, error) {
+	r, _, err := createNamedPipe.Call(uintptr(unsafe.Pointer(l.name)),
+		uintptr(pipeAccessDuplex|syscall.FILE_FLAG_OVERLAPPED|flags),
+		pipeRejectRemoteClients,
+		pipeUnlimitedInstances,
+		pipeBufferSize,
+		pipeBufferSize,
+		0,
+		uintptr(unsafe.Pointer(l.sa)))
+	if syscall.Handle(r) == syscall.InvalidHandle {
+		return nil, err
+	}
+	return &pipeConn{handle: syscall.Handle(r), addr: l.addr}, nil
+}
+
+//Accept waits for a client on the current instance of the pipe, and creates
+//the instance of the next client
+func (l *pipeListener) Accept() (net.Conn, error) {
+	l.lock.Lock()
+	if l.closed {
+		l.lock.Unlock()
+		return nil, errPipeClosed
+	}
+	conn := l.next
+	l.lock.Unlock()
+
+	_, err := conn.overlapped(func(o *syscall.Overlapped) error {
+		r, _, err := connectNamedPipe.Call(uintptr(conn.handle), uintptr(unsafe.Pointer(o)))
+		if r != 0 {
+			return nil
+		}
+		return err
+	})
+	//the client already went away, its reads fail
+	if err == errNoData {
+		err = nil
+	}
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed {
+		return nil, errPipeClosed
+	}
+	next, nerr := l.newInstance(0)
+	if nerr != nil {
+		conn.Close()
+		l.closed = true
+		return nil, &net.OpError{Op: "listen", Net: "pipe", Addr: l.addr, Err: nerr}
+	}
+	l.next = next
+	if err != nil {
+		conn.Close()
+		return nil, &net.OpError{Op: "accept", Net: "pipe", Addr: l.addr, Err: err}
+	}
+	return conn, nil
+}
+
+//Close stops listening. It releases a pending Accept.
+func (l *pipeListener) Close() error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed {
+		return nil
+	}
+	l.closed = true
+	l.next.Close()
+	syscall.LocalFree(syscall.Handle(l.sa.SecurityDescriptor))
+	return nil
+}
+
+func (l *pipeListener) Addr() net.Addr {
+	return l.addr
+}
+
+func dialPipe(addr string, timeout time.Duration) (net.Conn, error) {
+	name, err := syscall.UTF16PtrFromString(addr)
+	if err != nil {
+		return nil, err
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		h, err := syscall.CreateFile(name,
+			syscall.GENERIC_READ|syscall.GENERIC_WRITE,
+			0,
+			nil,
+			syscall.OPEN_EXISTING,
+			syscall.FILE_FLAG_OVERLAPPED,
+			0)
+		if err == nil {
+			return &pipeConn{handle: h, addr: pipeAddr(addr)}, nil
+		}
+		//every instance is taken until the server creates the next one
+		wait := time.Until(deadline)
+		if err != errPipeBusy || wait <= 0 {
+			return nil, &net.OpError{Op: "dial", Net: "pipe", Addr: pipeAddr(addr), Err: err}
+		}
+		waitNamedPipe.Call(uintptr(unsafe.Pointer(name)), uintptr(wait/time.Millisecond))
+	}
+}
+
+//pipeConn is an end of a named pipe
+type pipeConn struct {
+	handle    syscall.Handle
+	addr      pipeAddr
+	closeOnce sync.Once
+}
+
+//overlapped starts an operation with start and waits for its result. It waits
+//on the event even if the pipe is closed meanwhile, so that o is not released
+//before the operation completes.
+func (c *pipeConn) overlapped(start func(o *syscall.Overlapped) error) (int, error) {
+	event, _, err := createEvent.Call(0, 1, 0, 0)
+	if event == 0 {
+		return 0, err
+	}
+	defer syscall.CloseHandle(syscall.Handle(event))
+
+	o := &syscall.Overlapped{HEvent: syscall.Handle(event)}
+	switch err := start(o); err {
+	case nil, syscall.ERROR_IO_PENDING:
+	case errPipeConnected:
+		//the client connected before ConnectNamedPipe, which sets no event
+		return 0, nil
+	default:
+		return 0, err
+	}
+	if _, err := syscall.WaitForSingleObject(o.HEvent, syscall.INFINITE); err != nil {
+		return 0, err
+	}
+	var n uint32
+	r, _, err := getOverlappedResult.Call(uintptr(c.handle), uintptr(unsafe.Pointer(o)), uintptr(unsafe.Pointer(&n)), 0)
+	if r == 0 {
+		return int(n), err
+	}
+	return int(n), nil
+}
+
+func (c *pipeConn) Read(p []byte) (int, error) {
+	n, err := c.overlapped(func(o *syscall.Overlapped) error {
+		return syscall.ReadFile(c.handle, p, nil, o)
+	})
+	switch err {
+	case nil:
+		return n, nil
+	case syscall.ERROR_BROKEN_PIPE, errNoData:
+		return n, io.EOF
+	case syscall.ERROR_OPERATION_ABORTED:
+		return n, errPipeClosed
+	default:
+		return n, err
+	}
+}
+
+func (c *pipeConn) Write(p []byte) (int, error) {
+	n, err := c.overlapped(func(o *syscall.Overlapped) error {
+		return syscall.WriteFile(c.handle, p, nil, o)
+	})
+	if err == syscall.ERROR_OPERATION_ABORTED {
+		err = errPipeClosed
+	}
+	return n, err
+}
+
+//Close aborts the pending reads and writes and closes the pipe
+func (c *pipeConn) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		syscall.CancelIoEx(c.handle, nil)
+		err = syscall.CloseHandle(c.handle)
+	})
+	return err
+}
+
+func (c *pipeConn) LocalAddr() net.Addr  { return c.addr }
+func (c *pipeConn) RemoteAddr() net.Addr { return c.addr }
+
+//named pipes have no deadlines, the RPC clients of the proxies time out on
+//their own
+
+func (c *pipeConn) SetDeadline(t time.Time) error {
+	return errors.New("Deadlines are not supported on named pipes")
+}
+
+func (c *pipeConn) SetReadDeadline(t time.Time) error {
+	return c.SetDeadline(t)
+}
+
+func (c *pipeConn) SetWriteDeadline(t time.Time) error {
+	return c.SetDeadline(t)
+}
@@ -0,0 +1,214 @@
+package common
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const rotatedTimeFormat = "20060102-150405.000"
+
+//RotatingFileConfig configures a RotatingFile. Zero values disable the
+//corresponding feature.
+type RotatingFileConfig struct {
+	MaxSize    int64         //rotate when the file exceeds this many bytes
+	MaxAge     time.Duration //rotate when the file is older than this
+	MaxBackups int           //number of rotated files to keep
+	Compress   bool          //gzip rotated files
+}
+
+//RotatingFile is an io.Writer, typically used as the output of a logger, that
+//writes to a file and rotates it by size and/or age. Rotated files are renamed
+//with a timestamp suffix, optionally compressed in the background, and the
+//oldest ones are deleted beyond MaxBackups.
+type RotatingFile struct {
+	path string
+	conf RotatingFileConfig
+
+	l       sync.Mutex
+	file    *os.File
+	size    int64
+	opened  time.Time
+	pending sync.WaitGroup //background compressions
+
+	now func() time.Time
+}
+
+//NewRotatingFile opens path for appending, creating it if needed
+func NewRotatingFile(path string, conf RotatingFileConfig) (*RotatingFile, error) {
+	r := &RotatingFile{
+		path: path,
+		conf: conf,
+		now:  time.Now,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *RotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(r.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	r.opened = r.now()
+	return nil
+}
+
+//Write implements io.Writer
+func (r *RotatingFile) Write(p []byte) (int, error) {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if r.file == nil {
+		return 0, fmt.Errorf("Log file %s is closed", r.path)
+	}
+
+	if r.needRotate(int64(len(p))) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+func (r *RotatingFile) needRotate(next int64) bool {
+	if r.size == 0 {
+		return false
+	}
+	if r.conf.MaxSize > 0 && r.size+next > r.conf.MaxSize {
+		return true
+	}
+	if r.conf.MaxAge > 0 && r.now().Sub(r.opened) >= r.conf.MaxAge {
+		return true
+	}
+	return false
+}
+
+//Rotate closes the current file and starts a new one
+func (r *RotatingFile) Rotate() error {
+	r.l.Lock()
+	defer r.l.Unlock()
+	return r.rotate()
+}
+
+func (r *RotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	r.file = nil
+
+	rotated := r.path + "." + r.now().UTC().Format(rotatedTimeFormat)
+	if err := os.Rename(r.path, rotated); err != nil {
+		return err
+	}
+
+	if err := r.open(); err != nil {
+		return err
+	}
+
+	r.pending.Add(1)
+	go func() {
+		defer r.pending.Done()
+		if r.conf.Compress {
+			compressFile(rotated)
+		}
+		r.removeOldBackups()
+	}()
+	return nil
+}
+
+//Close waits for background work and closes the file
+func (r *RotatingFile) Close() error {
+	r.l.Lock()
+	defer r.l.Unlock()
+	r.pending.Wait()
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+//Backups returns the rotated files, oldest first
+func (r *RotatingFile) Backups() ([]string, error) {
+	matches, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return nil, err
+	}
+	backups := []string{}
+	for _, m := range matches {
+		if !strings.HasSuffix(m, ".tmp") {
+			backups = append(backups, m)
+		}
+	}
+	//the timestamp suffix sorts chronologically
+	sort.Strings(backups)
+	return backups, nil
+}
+
+func (r *RotatingFile) removeOldBackups() {
+	if r.conf.MaxBackups <= 0 {
+		return
+	}
+	backups, err := r.Backups()
+	if err != nil {
+		return
+	}
+	for len(backups) > r.conf.MaxBackups {
+		os.Remove(backups[0])
+		backups = backups[1:]
+	}
+}
+
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := path + ".gz.tmp"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if cerr := gz.Close(); err == nil {
+		err = cerr
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
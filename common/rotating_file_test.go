@@ -0,0 +1,113 @@
+package common
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileSize(t *testing.T) {
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	path := filepath.Join(dir, "babble.log")
+	r, err := NewRotatingFile(path, RotatingFileConfig{MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	for _, line := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		if _, err := r.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(current) != "dddddddd\n" {
+		t.Fatalf("Current file should only contain the last line, got %q", current)
+	}
+
+	backups, err := r.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("There should be 2 backups, not %d: %v", len(backups), backups)
+	}
+	last, err := ioutil.ReadFile(backups[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(last) != "cccccccc\n" {
+		t.Fatalf("Newest backup should contain the third line, got %q", last)
+	}
+}
+
+func TestRotatingFileAgeAndCompression(t *testing.T) {
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Now()
+	path := filepath.Join(dir, "babble.log")
+	r, err := NewRotatingFile(path, RotatingFileConfig{MaxAge: time.Hour, Compress: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.now = func() time.Time { return now }
+	r.opened = now
+
+	r.Write([]byte("old\n"))
+	now = now.Add(30 * time.Minute)
+	r.Write([]byte("still old\n"))
+	now = now.Add(31 * time.Minute)
+	r.Write([]byte("new\n"))
+	if err := r.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := r.Backups()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 1 || !strings.HasSuffix(backups[0], ".gz") {
+		t.Fatalf("There should be 1 compressed backup, got %v", backups)
+	}
+
+	f, err := os.Open(backups[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "old\nstill old\n" {
+		t.Fatalf("Backup should contain the first two lines, got %q", content)
+	}
+}
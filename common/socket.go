@@ -0,0 +1,75 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+/*
+The socket proxies between a node and its App listen on and dial addresses of
+three kinds:
+
+	IP:Port             a TCP socket, on every platform
+	unix:<path>         a Unix domain socket, created with mode 0600, so that only
+	                    the user of the node and the App can connect
+	\\.\pipe\<name>     a Windows named pipe, restricted to the user of the node,
+	                    SYSTEM and the administrators
+
+Unix domain sockets also work on Windows 10 and later. A stale socket file left
+by a process that crashed is removed before listening on its path, but not the
+socket of a running process.
+*/
+
+const (
+	unixPrefix = "unix:"
+	pipePrefix = `\\.\pipe\`
+)
+
+//IsPipeAddr is true if addr is the address of a Windows named pipe
+func IsPipeAddr(addr string) bool {
+	return strings.HasPrefix(strings.ToLower(addr), pipePrefix)
+}
+
+//ListenSocket listens on a TCP, Unix domain socket or named pipe address
+func ListenSocket(addr string) (net.Listener, error) {
+	switch {
+	case IsPipeAddr(addr):
+		return listenPipe(addr)
+	case strings.HasPrefix(addr, unixPrefix):
+		path := strings.TrimPrefix(addr, unixPrefix)
+		//only remove sockets that nobody serves, never a file given by mistake
+		if info, err := os.Lstat(path); err == nil && info.Mode()&os.ModeSocket != 0 {
+			if conn, err := net.Dial("unix", path); err == nil {
+				conn.Close()
+				return nil, fmt.Errorf("%s is already in use", path)
+			}
+			os.Remove(path)
+		}
+		l, err := net.Listen("unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := os.Chmod(path, 0600); err != nil {
+			l.Close()
+			return nil, fmt.Errorf("Restricting %s: %s", path, err)
+		}
+		return l, nil
+	default:
+		return net.Listen("tcp", addr)
+	}
+}
+
+//DialSocket connects to an address of ListenSocket
+func DialSocket(addr string, timeout time.Duration) (net.Conn, error) {
+	switch {
+	case IsPipeAddr(addr):
+		return dialPipe(addr, timeout)
+	case strings.HasPrefix(addr, unixPrefix):
+		return net.DialTimeout("unix", strings.TrimPrefix(addr, unixPrefix), timeout)
+	default:
+		return net.DialTimeout("tcp", addr, timeout)
+	}
+}
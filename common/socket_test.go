@@ -0,0 +1,91 @@
+package common
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestUnixSocket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "proxy.sock")
+
+	//a socket left by a process that crashed
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	l, err := ListenSocket("unix:" + path)
+	if err != nil {
+		t.Fatalf("Listening over a stale socket should succeed: %s", err)
+	}
+	defer l.Close()
+	if info, err := os.Stat(path); err != nil {
+		t.Fatal(err)
+	} else if runtime.GOOS != "windows" && info.Mode().Perm() != 0600 {
+		t.Fatalf("The socket should only be open to its user, not %s", info.Mode().Perm())
+	}
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		buf := make([]byte, 4)
+		if _, err := conn.Read(buf); err == nil {
+			conn.Write(buf)
+		}
+	}()
+
+	conn, err := DialSocket("unix:"+path, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 4)
+	if _, err := conn.Read(buf); err != nil || string(buf) != "ping" {
+		t.Fatalf("The socket should echo ping, got %q (%v)", buf, err)
+	}
+	if _, err := ListenSocket("unix:" + path); err == nil {
+		t.Fatal("Listening on a socket in use should fail")
+	}
+
+	//regular files are never removed
+	file := filepath.Join(dir, "file")
+	if err := ioutil.WriteFile(file, []byte("data"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ListenSocket("unix:" + file); err == nil {
+		t.Fatal("Listening on a regular file should fail")
+	}
+	if _, err := os.Stat(file); err != nil {
+		t.Fatal("The regular file should be kept")
+	}
+}
+
+func TestPipeAddr(t *testing.T) {
+	if !IsPipeAddr(`\\.\pipe\babble`) || IsPipeAddr("127.0.0.1:1338") || IsPipeAddr("unix:/tmp/babble.sock") {
+		t.Fatal(`Only \\.\pipe\ addresses are named pipes`)
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+	if _, err := ListenSocket(`\\.\pipe\babble`); err == nil {
+		t.Fatal("Named pipes should only be supported on Windows")
+	}
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+/*
+Minimal implementation of the systemd notification protocol (sd_notify(3)).
+Messages are sent to the unix datagram socket given by the NOTIFY_SOCKET
+environment variable. When the process is not started by systemd, the variable
+is not set and notifications are silently ignored.
+*/
+
+const (
+	SdReady    = "READY=1"
+	SdStopping = "STOPPING=1"
+	SdWatchdog = "WATCHDOG=1"
+)
+
+//SdNotify sends state to the systemd notification socket. It returns false if
+//notifications are not supported by the environment.
+func SdNotify(state string) (bool, error) {
+	socketAddr := os.Getenv("NOTIFY_SOCKET")
+	if socketAddr == "" {
+		return false, nil
+	}
+	//abstract namespace socket
+	if socketAddr[0] == '@' {
+		socketAddr = "\x00" + socketAddr[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{
+		Name: socketAddr,
+		Net:  "unixgram",
+	})
+	if err != nil {
+		return false, err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+//SdWatchdogInterval returns the watchdog timeout configured in the systemd
+//unit, or 0 if the watchdog is disabled or not meant for this process
+func SdWatchdogInterval() (time.Duration, error) {
+	usecStr := os.Getenv("WATCHDOG_USEC")
+	if usecStr == "" {
+		return 0, nil
+	}
+	usec, err := strconv.Atoi(usecStr)
+	if err != nil || usec <= 0 {
+		return 0, fmt.Errorf("Invalid WATCHDOG_USEC %s", usecStr)
+	}
+
+	if pidStr := os.Getenv("WATCHDOG_PID"); pidStr != "" {
+		pid, err := strconv.Atoi(pidStr)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid WATCHDOG_PID %s", pidStr)
+		}
+		if pid != os.Getpid() {
+			return 0, nil
+		}
+	}
+
+	return time.Duration(usec) * time.Microsecond, nil
+}
@@ -0,0 +1,79 @@
+package common
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSdNotify(t *testing.T) {
+	os.Unsetenv("NOTIFY_SOCKET")
+	if ok, err := SdNotify(SdReady); ok || err != nil {
+		t.Fatalf("SdNotify without socket should be ignored, got %v, %v", ok, err)
+	}
+
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	addr := &net.UnixAddr{Name: filepath.Join(dir, "notify.sock"), Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", addr.Name)
+	defer os.Unsetenv("NOTIFY_SOCKET")
+
+	ok, err := SdNotify(SdReady)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("SdNotify should report a sent notification")
+	}
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg := string(buf[:n]); msg != SdReady {
+		t.Fatalf("Notification should be %s, not %s", SdReady, msg)
+	}
+}
+
+func TestSdWatchdogInterval(t *testing.T) {
+	defer os.Unsetenv("WATCHDOG_USEC")
+	defer os.Unsetenv("WATCHDOG_PID")
+
+	os.Unsetenv("WATCHDOG_USEC")
+	if d, err := SdWatchdogInterval(); d != 0 || err != nil {
+		t.Fatalf("Watchdog should be disabled, got %v, %v", d, err)
+	}
+
+	os.Setenv("WATCHDOG_USEC", "3000000")
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()))
+	if d, err := SdWatchdogInterval(); d != 3*time.Second || err != nil {
+		t.Fatalf("Watchdog interval should be 3s, got %v, %v", d, err)
+	}
+
+	os.Setenv("WATCHDOG_PID", strconv.Itoa(os.Getpid()+1))
+	if d, err := SdWatchdogInterval(); d != 0 || err != nil {
+		t.Fatalf("Watchdog for another process should be ignored, got %v, %v", d, err)
+	}
+
+	os.Setenv("WATCHDOG_USEC", "abc")
+	os.Unsetenv("WATCHDOG_PID")
+	if _, err := SdWatchdogInterval(); err == nil {
+		t.Fatal("Invalid WATCHDOG_USEC should fail")
+	}
+}
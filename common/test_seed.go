@@ -0,0 +1,52 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+//TestSeedEnv is the environment variable that fixes the seed of a test run
+const TestSeedEnv = "BABBLE_TEST_SEED"
+
+var (
+	testSeed     int64
+	testSeedOnce sync.Once
+)
+
+//TestSeed returns the seed of all the randomness of a test run: peer
+//selection, heartbeat jitter and generated transactions. It is read from
+//BABBLE_TEST_SEED if set, and picked at random otherwise, and does not change
+//for the rest of the run. Keys are still generated from crypto/rand.
+func TestSeed() int64 {
+	testSeedOnce.Do(func() {
+		if s := os.Getenv(TestSeedEnv); s != "" {
+			seed, err := strconv.ParseInt(s, 10, 64)
+			if err != nil {
+				panic(fmt.Sprintf("Invalid %s: %s", TestSeedEnv, err))
+			}
+			testSeed = seed
+			return
+		}
+		testSeed = time.Now().UnixNano()
+	})
+	return testSeed
+}
+
+//RunWithSeed runs the tests of a package and prints the seed if they fail, so
+//that the failure can be reproduced. It is meant to be called from TestMain:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(common.RunWithSeed(m))
+//	}
+func RunWithSeed(m *testing.M) int {
+	seed := TestSeed()
+	code := m.Run()
+	if code != 0 {
+		fmt.Printf("Test seed %d. Reproduce with %s=%d\n", seed, TestSeedEnv, seed)
+	}
+	return code
+}
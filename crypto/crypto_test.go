@@ -42,3 +42,65 @@ func TestPem(t *testing.T) {
 		t.Fatalf("Keys do not match")
 	}
 }
+
+func TestPemEncoding(t *testing.T) {
+	key, _ := GenerateECDSAKey()
+
+	data, err := EncodePemKey(key)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	nKey, err := ParsePemKey(data)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if PubKeyHex(&nKey.PublicKey) != PubKeyHex(&key.PublicKey) {
+		t.Fatalf("Keys do not match")
+	}
+
+	if _, err := ParsePemKey([]byte("not a key")); err == nil {
+		t.Fatalf("Parsing garbage should fail")
+	}
+}
+
+func TestKeyPairs(t *testing.T) {
+	hash := SHA256([]byte("babble"))
+	for _, algorithm := range []string{ECDSAP256, Ed25519} {
+		key, err := GenerateKeyPair(algorithm)
+		if err != nil {
+			t.Fatalf("%s: %s", algorithm, err)
+		}
+		if a, err := PubKeyAlgorithm(key.PublicKey()); err != nil || a != algorithm {
+			t.Fatalf("Public key should be %s, got %s, %v", algorithm, a, err)
+		}
+		r, s, err := key.Sign(hash)
+		if err != nil {
+			t.Fatalf("%s: %s", algorithm, err)
+		}
+		if !VerifySignature(key.PublicKey(), hash, r, s) {
+			t.Fatalf("%s signature should be valid", algorithm)
+		}
+		if VerifySignature(key.PublicKey(), SHA256([]byte("other")), r, s) {
+			t.Fatalf("%s signature of another hash should be invalid", algorithm)
+		}
+
+		data, err := EncodePemKeyPair(key)
+		if err != nil {
+			t.Fatalf("%s: %s", algorithm, err)
+		}
+		read, err := ParsePemKeyPair(data)
+		if err != nil {
+			t.Fatalf("%s: %s", algorithm, err)
+		}
+		if read.Algorithm() != algorithm || !reflect.DeepEqual(read.PublicKey(), key.PublicKey()) {
+			t.Fatalf("%s key read from PEM does not match", algorithm)
+		}
+	}
+
+	if _, err := PubKeyAlgorithm([]byte{1, 2, 3}); err == nil {
+		t.Fatal("Public key of 3 bytes should be invalid")
+	}
+	if _, err := GenerateKeyPair("rsa"); err == nil {
+		t.Fatal("Unknown algorithm should be refused")
+	}
+}
@@ -0,0 +1,71 @@
+package crypto
+
+import (
+	"fmt"
+	"sync"
+)
+
+/*
+Events and Blocks are hashed with SHA256 by default. Networks that interoperate
+with ecosystems standardized on other primitives choose another Hasher when they
+start, and keep it: BLAKE2b-256, as in Zcash or Polkadot, or Keccak-256, the
+pre-standard SHA3 of Ethereum. Both come from golang.org/x/crypto.
+*/
+
+const (
+	SHA256Hash     = "sha256"
+	BLAKE2b256Hash = "blake2b-256"
+	Keccak256Hash  = "keccak-256"
+)
+
+//Hasher is a hash function, known by its name
+type Hasher interface {
+	Name() string
+	Hash(data []byte) []byte
+}
+
+type hashFunc struct {
+	name string
+	fn   func(data []byte) []byte
+}
+
+func (h hashFunc) Name() string {
+	return h.name
+}
+
+func (h hashFunc) Hash(data []byte) []byte {
+	return h.fn(data)
+}
+
+//NewHasher returns the Hasher of fn
+func NewHasher(name string, fn func(data []byte) []byte) Hasher {
+	return hashFunc{name, fn}
+}
+
+var (
+	hasherLock sync.RWMutex
+	hashers    = map[string]Hasher{
+		SHA256Hash:     NewHasher(SHA256Hash, SHA256),
+		BLAKE2b256Hash: NewHasher(BLAKE2b256Hash, BLAKE2b256),
+		Keccak256Hash:  NewHasher(Keccak256Hash, Keccak256),
+	}
+)
+
+//RegisterHasher makes a Hasher available by its name. It is meant to be called
+//from init functions.
+func RegisterHasher(h Hasher) {
+	hasherLock.Lock()
+	defer hasherLock.Unlock()
+	hashers[h.Name()] = h
+}
+
+//GetHasher returns the Hasher of a name
+func GetHasher(name string) (Hasher, error) {
+	hasherLock.RLock()
+	defer hasherLock.RUnlock()
+	h, ok := hashers[name]
+	if !ok {
+		return nil, fmt.Errorf("Unknown hash function %s", name)
+	}
+	return h, nil
+}
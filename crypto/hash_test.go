@@ -0,0 +1,38 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestHashers(t *testing.T) {
+	long := bytes.Repeat([]byte("a"), 200)
+	cases := []struct {
+		hasher string
+		data   []byte
+		hash   string
+	}{
+		{SHA256Hash, []byte("abc"), "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"},
+		{BLAKE2b256Hash, nil, "0e5751c026e543b2e8ab2eb06099daa1d1e5df47778f7787faab45cdf12fe3a8"},
+		{BLAKE2b256Hash, []byte("abc"), "bddd813c634239723171ef3fee98579b94964e3bb1cb3e427262c8c068d52319"},
+		{BLAKE2b256Hash, long[:128], "ae2aa48507885c4c950fb809b2076f959cde9f8ea6da260d9a3587df33dac450"},
+		{BLAKE2b256Hash, long, "6b6e59aaf00eb730cf93de53560846722184bbd92f8368c21ffa95380c2f9fe6"},
+		{Keccak256Hash, nil, "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+		{Keccak256Hash, []byte("abc"), "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45"},
+		{Keccak256Hash, long, "96ea54061def936c4be90b518992fdc6f12f535068a256229aca54267b4d084d"},
+	}
+	for _, tc := range cases {
+		h, err := GetHasher(tc.hasher)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(h.Hash(tc.data)); got != tc.hash {
+			t.Fatalf("%s of %d bytes should be %s, got %s", tc.hasher, len(tc.data), tc.hash, got)
+		}
+	}
+
+	if _, err := GetHasher("md5"); err == nil {
+		t.Fatal("Unknown hash function should fail")
+	}
+}
@@ -0,0 +1,213 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+/*
+priv_key.pem holds the node key in clear. An operator who does not want a raw
+private key on disk encrypts it with a passphrase into priv_key.json instead, a
+keystore in the spirit of the Ethereum one:
+
+	{
+		"Version": 1,
+		"Algorithm": "ed25519",
+		"PublicKey": "0x...",
+		"KDF": "pbkdf2-sha256",
+		"Iterations": 262144,
+		"Salt": "<hex>",
+		"Nonce": "<hex>",
+		"Ciphertext": "<hex>"
+	}
+
+The passphrase is stretched with PBKDF2-HMAC-SHA256 into an AES-256 key, and
+the PEM encoding of the key is sealed with AES-GCM, authenticated along with the
+public key. The public key is in clear so that the file can be listed without
+the passphrase.
+
+Files are written with keyFileIterations. A file is only decrypted with between
+keyFileMinIterations and keyFileMaxIterations, so that a tampered file can
+neither weaken the derivation nor make the node spin on it.
+*/
+
+const (
+	keyFilePath       = "priv_key.json"
+	keyFileVersion    = 1
+	keyFileKDF        = "pbkdf2-sha256"
+	keyFileIterations = 262144
+
+	keyFileMinIterations = 100000
+	keyFileMaxIterations = 10000000
+)
+
+//ErrWrongPassphrase is returned when a key file cannot be decrypted
+var ErrWrongPassphrase = errors.New("Wrong passphrase or corrupted key file")
+
+type encryptedKey struct {
+	Version    int
+	Algorithm  string
+	PublicKey  string
+	KDF        string
+	Iterations int
+	Salt       string
+	Nonce      string
+	Ciphertext string
+}
+
+//EncryptKeyPair encodes key in the key file format, encrypted with passphrase
+func EncryptKeyPair(key KeyPair, passphrase []byte) ([]byte, error) {
+	plain, err := EncodePemKeyPair(key)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := keyFileCipher(passphrase, salt, keyFileIterations)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	pub := KeyPairPubKeyHex(key)
+
+	return json.MarshalIndent(encryptedKey{
+		Version:    keyFileVersion,
+		Algorithm:  key.Algorithm(),
+		PublicKey:  pub,
+		KDF:        keyFileKDF,
+		Iterations: keyFileIterations,
+		Salt:       hex.EncodeToString(salt),
+		Nonce:      hex.EncodeToString(nonce),
+		Ciphertext: hex.EncodeToString(aead.Seal(nil, nonce, plain, []byte(pub))),
+	}, "", "\t")
+}
+
+//DecryptKeyPair decodes a key file encrypted with passphrase
+func DecryptKeyPair(data []byte, passphrase []byte) (KeyPair, error) {
+	var ek encryptedKey
+	if err := json.Unmarshal(data, &ek); err != nil {
+		return nil, err
+	}
+	if ek.Version != keyFileVersion {
+		return nil, fmt.Errorf("Unsupported key file version %d", ek.Version)
+	}
+	if ek.KDF != keyFileKDF || ek.Iterations < keyFileMinIterations || ek.Iterations > keyFileMaxIterations {
+		return nil, fmt.Errorf("Unsupported key derivation %s with %d iterations", ek.KDF, ek.Iterations)
+	}
+	salt, err := hex.DecodeString(ek.Salt)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid salt: %s", err)
+	}
+	nonce, err := hex.DecodeString(ek.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid nonce: %s", err)
+	}
+	ciphertext, err := hex.DecodeString(ek.Ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid ciphertext: %s", err)
+	}
+
+	aead, err := keyFileCipher(passphrase, salt, ek.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("Invalid nonce of %d bytes", len(nonce))
+	}
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(ek.PublicKey))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	key, err := ParsePemKeyPair(plain)
+	if err != nil {
+		return nil, err
+	}
+	if KeyPairPubKeyHex(key) != ek.PublicKey {
+		return nil, ErrWrongPassphrase
+	}
+	return key, nil
+}
+
+//KeyFilePubKey returns the public key of a key file, without decrypting it
+func KeyFilePubKey(data []byte) (algorithm string, pubKey string, err error) {
+	var ek encryptedKey
+	if err := json.Unmarshal(data, &ek); err != nil {
+		return "", "", err
+	}
+	return ek.Algorithm, ek.PublicKey, nil
+}
+
+func keyFileCipher(passphrase, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2.Key(passphrase, salt, iterations, 32, sha256.New))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+//KeyFile is the encrypted counterpart of PemKey
+type KeyFile struct {
+	l    sync.Mutex
+	path string
+}
+
+func NewKeyFile(base string) *KeyFile {
+	return &KeyFile{
+		path: filepath.Join(base, keyFilePath),
+	}
+}
+
+//Path returns the path of the key file
+func (k *KeyFile) Path() string {
+	return k.path
+}
+
+//Exists tells whether there is a key file
+func (k *KeyFile) Exists() bool {
+	_, err := os.Stat(k.path)
+	return err == nil
+}
+
+//ReadKeyPair decrypts the key file. It returns nil if there is no key.
+func (k *KeyFile) ReadKeyPair(passphrase []byte) (KeyPair, error) {
+	k.l.Lock()
+	defer k.l.Unlock()
+
+	buf, err := ioutil.ReadFile(k.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	return DecryptKeyPair(buf, passphrase)
+}
+
+//WriteKeyPair encrypts key with passphrase into the key file
+func (k *KeyFile) WriteKeyPair(key KeyPair, passphrase []byte) error {
+	k.l.Lock()
+	defer k.l.Unlock()
+
+	data, err := EncryptKeyPair(key, passphrase)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(k.path, data, 0600)
+}
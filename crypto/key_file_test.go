@@ -0,0 +1,82 @@
+package crypto
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func TestEncryptKeyPair(t *testing.T) {
+	for _, alg := range []string{ECDSAP256, Ed25519} {
+		key, err := GenerateKeyPair(alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := EncryptKeyPair(key, []byte("secret"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		dec, err := DecryptKeyPair(data, []byte("secret"))
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if dec.Algorithm() != alg || KeyPairPubKeyHex(dec) != KeyPairPubKeyHex(key) {
+			t.Fatalf("%s: decrypted key should be the encrypted one", alg)
+		}
+		if _, err := DecryptKeyPair(data, []byte("wrong")); err != ErrWrongPassphrase {
+			t.Fatalf("%s: wrong passphrase should fail with ErrWrongPassphrase, got %v", alg, err)
+		}
+		if a, pub, err := KeyFilePubKey(data); err != nil || a != alg || pub != KeyPairPubKeyHex(key) {
+			t.Fatalf("%s: key file should tell its public key, got %s %s %v", alg, a, pub, err)
+		}
+	}
+}
+
+func TestDecryptKeyPairIterations(t *testing.T) {
+	key, _ := GenerateKeyPair(Ed25519)
+	data, err := EncryptKeyPair(key, []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, iterations := range []int{0, 1, keyFileMinIterations - 1, keyFileMaxIterations + 1} {
+		var ek encryptedKey
+		if err := json.Unmarshal(data, &ek); err != nil {
+			t.Fatal(err)
+		}
+		ek.Iterations = iterations
+		tampered, err := json.Marshal(ek)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := DecryptKeyPair(tampered, []byte("secret")); err == nil || err == ErrWrongPassphrase {
+			t.Fatalf("Key file with %d iterations should be refused before decryption, got %v", iterations, err)
+		}
+	}
+}
+
+func TestKeyFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keyFile := NewKeyFile(dir)
+	if key, err := keyFile.ReadKeyPair([]byte("secret")); err != nil || key != nil || keyFile.Exists() {
+		t.Fatalf("Missing key file should read as no key, got %v %v", key, err)
+	}
+
+	key, _ := GenerateKeyPair(Ed25519)
+	if err := keyFile.WriteKeyPair(key, []byte("secret")); err != nil {
+		t.Fatal(err)
+	}
+	read, err := keyFile.ReadKeyPair([]byte("secret"))
+	if err != nil || read == nil || KeyPairPubKeyHex(read) != KeyPairPubKeyHex(key) {
+		t.Fatalf("Key file should hold the key, got %v", err)
+	}
+	if info, _ := os.Stat(keyFile.Path()); info.Mode().Perm() != 0600 {
+		t.Fatalf("Key file should be 0600, not %v", info.Mode().Perm())
+	}
+}
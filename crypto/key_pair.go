@@ -0,0 +1,156 @@
+package crypto
+
+import (
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+)
+
+/*
+A node signs its Events and Blocks with a KeyPair. Two algorithms are supported:
+ECDSA on the P256 curve, the default, and Ed25519, which signs and verifies
+faster and has smaller signatures.
+
+A public key tells its algorithm: ECDSA keys are 65 bytes, the uncompressed
+point of P256, and Ed25519 keys are 32 bytes. Signatures are carried as the R
+and S integers of ECDSA, so an Ed25519 signature is split into its two halves
+of 32 bytes.
+*/
+
+const (
+	ECDSAP256 = "ecdsa-p256"
+	Ed25519   = "ed25519"
+)
+
+//Signer signs hashes
+type Signer interface {
+	Sign(hash []byte) (r, s *big.Int, err error)
+}
+
+//KeyPair is a private key and its public key
+type KeyPair interface {
+	Signer
+	Algorithm() string
+	PublicKey() []byte
+}
+
+type ecdsaKeyPair struct {
+	key *ecdsa.PrivateKey
+}
+
+//NewECDSAKeyPair returns the KeyPair of an ECDSA key on the P256 curve
+func NewECDSAKeyPair(key *ecdsa.PrivateKey) KeyPair {
+	return ecdsaKeyPair{key}
+}
+
+func (k ecdsaKeyPair) Sign(hash []byte) (*big.Int, *big.Int, error) {
+	return Sign(k.key, hash)
+}
+
+func (k ecdsaKeyPair) Algorithm() string {
+	return ECDSAP256
+}
+
+func (k ecdsaKeyPair) PublicKey() []byte {
+	return FromECDSAPub(&k.key.PublicKey)
+}
+
+type ed25519KeyPair struct {
+	key ed25519.PrivateKey
+}
+
+//NewEd25519KeyPair returns the KeyPair of an Ed25519 key
+func NewEd25519KeyPair(key ed25519.PrivateKey) KeyPair {
+	return ed25519KeyPair{key}
+}
+
+func (k ed25519KeyPair) Sign(hash []byte) (*big.Int, *big.Int, error) {
+	sig := ed25519.Sign(k.key, hash)
+	half := ed25519.SignatureSize / 2
+	return new(big.Int).SetBytes(sig[:half]), new(big.Int).SetBytes(sig[half:]), nil
+}
+
+func (k ed25519KeyPair) Algorithm() string {
+	return Ed25519
+}
+
+func (k ed25519KeyPair) PublicKey() []byte {
+	return []byte(k.key.Public().(ed25519.PublicKey))
+}
+
+//GenerateKeyPair returns a new KeyPair of the algorithm
+func GenerateKeyPair(algorithm string) (KeyPair, error) {
+	switch algorithm {
+	case ECDSAP256:
+		key, err := GenerateECDSAKey()
+		if err != nil {
+			return nil, err
+		}
+		return NewECDSAKeyPair(key), nil
+	case Ed25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		return NewEd25519KeyPair(key), nil
+	}
+	return nil, fmt.Errorf("Unknown key algorithm %s", algorithm)
+}
+
+//ECDSAKey returns the ECDSA private key of a KeyPair, if it is one
+func ECDSAKey(k KeyPair) (*ecdsa.PrivateKey, bool) {
+	e, ok := k.(ecdsaKeyPair)
+	if !ok {
+		return nil, false
+	}
+	return e.key, true
+}
+
+//PrivateKey returns the private key of a KeyPair held in memory, of either
+//algorithm, as the crypto.Signer of the standard library that TLS uses. A
+//RemoteSigner has none.
+func PrivateKey(k KeyPair) (gocrypto.Signer, bool) {
+	switch k := k.(type) {
+	case ecdsaKeyPair:
+		return k.key, true
+	case ed25519KeyPair:
+		return k.key, true
+	}
+	return nil, false
+}
+
+//KeyPairPubKeyHex returns the public key of k in the format used to identify
+//peers
+func KeyPairPubKeyHex(k KeyPair) string {
+	return fmt.Sprintf("0x%X", k.PublicKey())
+}
+
+//PubKeyAlgorithm returns the algorithm of a public key
+func PubKeyAlgorithm(pub []byte) (string, error) {
+	switch {
+	case len(pub) == ed25519.PublicKeySize:
+		return Ed25519, nil
+	case ToECDSAPub(pub) != nil && ToECDSAPub(pub).X != nil:
+		return ECDSAP256, nil
+	}
+	return "", fmt.Errorf("Invalid public key of %d bytes", len(pub))
+}
+
+//VerifySignature checks a signature of hash with the algorithm of pub
+func VerifySignature(pub []byte, hash []byte, r, s *big.Int) bool {
+	if len(pub) != ed25519.PublicKeySize {
+		return Verify(ToECDSAPub(pub), hash, r, s)
+	}
+	half := ed25519.SignatureSize / 2
+	if r.Sign() < 0 || s.Sign() < 0 || r.BitLen() > 8*half || s.BitLen() > 8*half {
+		return false
+	}
+	sig := make([]byte, ed25519.SignatureSize)
+	rb, sb := r.Bytes(), s.Bytes()
+	copy(sig[half-len(rb):half], rb)
+	copy(sig[2*half-len(sb):], sb)
+	return ed25519.Verify(ed25519.PublicKey(pub), hash, sig)
+}
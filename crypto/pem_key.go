@@ -2,6 +2,7 @@ package crypto
 
 import (
 	"crypto/ecdsa"
+	"crypto/ed25519"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
@@ -43,25 +44,117 @@ func (k *PemKey) ReadKey() (*ecdsa.PrivateKey, error) {
 		return nil, nil
 	}
 
-	// Decode the PEM key
-	block, _ := pem.Decode(buf)
-	if block == nil {
-		return nil, fmt.Errorf("Error decoding PEM block from data")
+	return ParsePemKey(buf)
+}
+
+//ReadKeyPair reads a key of any algorithm. It returns nil if there is no key.
+func (k *PemKey) ReadKeyPair() (KeyPair, error) {
+	k.l.Lock()
+	defer k.l.Unlock()
+
+	buf, err := ioutil.ReadFile(k.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
 	}
-	return x509.ParseECPrivateKey(block.Bytes)
+	if len(buf) == 0 {
+		return nil, nil
+	}
+	return ParsePemKeyPair(buf)
+}
+
+//Path returns the path of the key file
+func (k *PemKey) Path() string {
+	return k.path
 }
 
 func (k *PemKey) WriteKey(key *ecdsa.PrivateKey) error {
 	k.l.Lock()
 	defer k.l.Unlock()
 
-	b, err := x509.MarshalECPrivateKey(key)
+	data, err := EncodePemKey(key)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(k.path, data, 0600)
+}
+
+//WriteKeyPair writes a key of any algorithm
+func (k *PemKey) WriteKeyPair(key KeyPair) error {
+	k.l.Lock()
+	defer k.l.Unlock()
+
+	data, err := EncodePemKeyPair(key)
 	if err != nil {
 		return err
 	}
+	return ioutil.WriteFile(k.path, data, 0600)
+}
+
+//ParsePemKey decodes a PEM encoded EC private key
+func ParsePemKey(data []byte) (*ecdsa.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("Error decoding PEM block from data")
+	}
+	return x509.ParseECPrivateKey(block.Bytes)
+}
+
+//EncodePemKey encodes an EC private key in PEM format
+func EncodePemKey(key *ecdsa.PrivateKey) ([]byte, error) {
+	b, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
 	pemBlock := &pem.Block{Type: "EC PRIVATE KEY", Bytes: b}
-	data := pem.EncodeToMemory(pemBlock)
-	return ioutil.WriteFile(k.path, data, 0755)
+	return pem.EncodeToMemory(pemBlock), nil
+}
+
+//ParsePemKeyPair decodes a PEM encoded EC private key, or an Ed25519 private
+//key in PKCS #8
+func ParsePemKeyPair(data []byte) (KeyPair, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("Error decoding PEM block from data")
+	}
+	if block.Type == "EC PRIVATE KEY" {
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return NewECDSAKeyPair(key), nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	switch key := key.(type) {
+	case ed25519.PrivateKey:
+		return NewEd25519KeyPair(key), nil
+	case *ecdsa.PrivateKey:
+		return NewECDSAKeyPair(key), nil
+	}
+	return nil, fmt.Errorf("Unsupported private key %T", key)
+}
+
+//EncodePemKeyPair encodes an EC key like EncodePemKey, and an Ed25519 key in
+//PKCS #8
+func EncodePemKeyPair(key KeyPair) ([]byte, error) {
+	switch k := key.(type) {
+	case ecdsaKeyPair:
+		return EncodePemKey(k.key)
+	case ed25519KeyPair:
+		b, err := x509.MarshalPKCS8PrivateKey(k.key)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: b}), nil
+	}
+	return nil, fmt.Errorf("Unsupported key algorithm %s", key.Algorithm())
+}
+
+//PubKeyHex returns the public key in the format used to identify peers
+func PubKeyHex(pub *ecdsa.PublicKey) string {
+	return fmt.Sprintf("0x%X", FromECDSAPub(pub))
 }
 
 type PemDump struct {
@@ -70,19 +163,22 @@ type PemDump struct {
 }
 
 func GeneratePemKey() (*PemDump, error) {
-	key, err := GenerateECDSAKey()
+	return GeneratePemKeyPair(ECDSAP256)
+}
+
+//GeneratePemKeyPair is GeneratePemKey for a key of the algorithm
+func GeneratePemKeyPair(algorithm string) (*PemDump, error) {
+	key, err := GenerateKeyPair(algorithm)
 	if err != nil {
 		return nil, err
 	}
 
-	pub := fmt.Sprintf("0x%X", FromECDSAPub(&key.PublicKey))
+	pub := KeyPairPubKeyHex(key)
 
-	b, err := x509.MarshalECPrivateKey(key)
+	data, err := EncodePemKeyPair(key)
 	if err != nil {
 		return nil, err
 	}
-	pemBlock := &pem.Block{Type: "EC PRIVATE KEY", Bytes: b}
-	data := pem.EncodeToMemory(pemBlock)
 
 	pemDump := PemDump{
 		PublicKey:  pub,
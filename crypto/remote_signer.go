@@ -0,0 +1,146 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+/*
+By default the private key of a node is read from priv_key.pem in its datadir,
+and lives in the memory of the process. A RemoteSigner instead asks a signing
+service for every signature, so that the key can stay in an HSM or a KMS. The
+service is typically a small adapter in front of the vendor API, which serves:
+
+	GET  /PublicKey  => {"PublicKey": "0x04..."}
+	POST /Sign       {"Hash": "<hex>"} => {"R": "<hex>", "S": "<hex>"}
+
+The public key is fetched once, when the RemoteSigner is created, and tells the
+algorithm of the key. SignerHandler serves a KeyPair with the same protocol.
+*/
+
+//RemoteSigner is a KeyPair whose private key is held by a signing service
+type RemoteSigner struct {
+	url       string
+	client    *http.Client
+	pubKey    []byte
+	algorithm string
+}
+
+type remotePublicKey struct {
+	PublicKey string
+}
+
+type remoteSignRequest struct {
+	Hash string
+}
+
+type remoteSignResponse struct {
+	R string
+	S string
+}
+
+//NewRemoteSigner connects to the signing service at url, for instance
+//http://127.0.0.1:8500, and fetches its public key
+func NewRemoteSigner(url string, timeout time.Duration) (*RemoteSigner, error) {
+	s := &RemoteSigner{
+		url:    strings.TrimSuffix(url, "/"),
+		client: &http.Client{Timeout: timeout},
+	}
+	resp, err := s.client.Get(s.url + "/PublicKey")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Remote signer public key: %s", resp.Status)
+	}
+	var pub remotePublicKey
+	if err := json.NewDecoder(resp.Body).Decode(&pub); err != nil {
+		return nil, fmt.Errorf("Remote signer public key: %s", err)
+	}
+	if s.pubKey, err = hex.DecodeString(strings.TrimPrefix(pub.PublicKey, "0x")); err != nil {
+		return nil, fmt.Errorf("Remote signer public key: %s", err)
+	}
+	if s.algorithm, err = PubKeyAlgorithm(s.pubKey); err != nil {
+		return nil, fmt.Errorf("Remote signer public key: %s", err)
+	}
+	return s, nil
+}
+
+//Sign asks the signing service for a signature of hash
+func (s *RemoteSigner) Sign(hash []byte) (*big.Int, *big.Int, error) {
+	body, err := json.Marshal(remoteSignRequest{Hash: hex.EncodeToString(hash)})
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := s.client.Post(s.url+"/Sign", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("Remote signer: %s", resp.Status)
+	}
+	var sig remoteSignResponse
+	if err := json.NewDecoder(resp.Body).Decode(&sig); err != nil {
+		return nil, nil, fmt.Errorf("Remote signer: %s", err)
+	}
+	r, okR := new(big.Int).SetString(sig.R, 16)
+	ss, okS := new(big.Int).SetString(sig.S, 16)
+	if !okR || !okS {
+		return nil, nil, fmt.Errorf("Remote signer returned an invalid signature")
+	}
+	//a misconfigured service signing with another key is caught here rather
+	//than by the peers
+	if !VerifySignature(s.pubKey, hash, r, ss) {
+		return nil, nil, fmt.Errorf("Remote signer returned a signature of another key")
+	}
+	return r, ss, nil
+}
+
+func (s *RemoteSigner) Algorithm() string {
+	return s.algorithm
+}
+
+func (s *RemoteSigner) PublicKey() []byte {
+	return s.pubKey
+}
+
+//SignerHandler serves key with the protocol of RemoteSigner
+func SignerHandler(key KeyPair) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/PublicKey", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(remotePublicKey{PublicKey: KeyPairPubKeyHex(key)})
+	})
+	mux.HandleFunc("/Sign", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+		var req remoteSignRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		hash, err := hex.DecodeString(req.Hash)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		rInt, sInt, err := key.Sign(hash)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(remoteSignResponse{R: rInt.Text(16), S: sInt.Text(16)})
+	})
+	return mux
+}
@@ -0,0 +1,52 @@
+package crypto
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRemoteSigner(t *testing.T) {
+	hash := []byte("0123456789abcdef0123456789abcdef")
+	for _, alg := range []string{ECDSAP256, Ed25519} {
+		key, err := GenerateKeyPair(alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		server := httptest.NewServer(SignerHandler(key))
+		defer server.Close()
+
+		signer, err := NewRemoteSigner(server.URL, time.Second)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if signer.Algorithm() != alg || !bytes.Equal(signer.PublicKey(), key.PublicKey()) {
+			t.Fatalf("%s: remote signer should have the key of the service", alg)
+		}
+		r, s, err := signer.Sign(hash)
+		if err != nil {
+			t.Fatalf("%s: %v", alg, err)
+		}
+		if !VerifySignature(key.PublicKey(), hash, r, s) {
+			t.Fatalf("%s: remote signature should verify", alg)
+		}
+	}
+}
+
+func TestRemoteSignerWrongKey(t *testing.T) {
+	key, _ := GenerateKeyPair(ECDSAP256)
+	other, _ := GenerateKeyPair(ECDSAP256)
+	server := httptest.NewServer(SignerHandler(key))
+	defer server.Close()
+
+	signer, err := NewRemoteSigner(server.URL, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the service now signs with another key than the one it advertised
+	signer.pubKey = other.PublicKey()
+	if _, _, err := signer.Sign([]byte("hash")); err == nil {
+		t.Fatal("Signature of another key should be rejected")
+	}
+}
@@ -6,6 +6,9 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"math/big"
+
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/sha3"
 )
 
 func SHA256(hashBytes []byte) []byte {
@@ -15,6 +18,20 @@ func SHA256(hashBytes []byte) []byte {
 	return hash
 }
 
+//BLAKE2b256 returns the BLAKE2b hash of data, of 32 bytes
+func BLAKE2b256(data []byte) []byte {
+	hash := blake2b.Sum256(data)
+	return hash[:]
+}
+
+//Keccak256 returns the Keccak-256 hash of data, the pre-standard SHA3 of
+//Ethereum
+func Keccak256(data []byte) []byte {
+	hasher := sha3.NewLegacyKeccak256()
+	hasher.Write(data)
+	return hasher.Sum(nil)
+}
+
 func GenerateECDSAKey() (*ecdsa.PrivateKey, error) {
 	return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
 }
@@ -0,0 +1,179 @@
+package hashgraph
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/dgraph-io/badger"
+)
+
+/*
+A backup of a BadgerStore is a copy of every key of its database, read from a
+single read-only transaction. It is consistent with the hashgraph at the time
+the transaction was opened, even though the store keeps changing while the
+backup is written out. RestoreBadgerStore writes it to a new database, which
+LoadBadgerStore then opens like the original.
+
+The backup is a sequence of records, a key and a value each prefixed with its
+uvarint length, ended by an empty key.
+*/
+
+//maxBackupRecord bounds the keys and values read by RestoreBadgerStore
+const maxBackupRecord = 256 * 1024 * 1024
+
+//restoreBatch is the number of keys written in each transaction of
+//RestoreBadgerStore
+const restoreBatch = 1000
+
+//StoreBackup is a consistent view of a BadgerStore, see BadgerStore.Backup
+type StoreBackup struct {
+	txn *badger.Txn
+}
+
+//Backup opens a consistent view of the database. What is written to the store
+//afterwards is not part of the backup. The StoreBackup must be closed once it
+//is written.
+func (s *BadgerStore) Backup() *StoreBackup {
+	return &StoreBackup{txn: s.db.NewTransaction(false)}
+}
+
+//WriteTo writes the backup to w
+func (b *StoreBackup) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	write := func(p []byte) error {
+		var l [binary.MaxVarintLen64]byte
+		m, err := bw.Write(l[:binary.PutUvarint(l[:], uint64(len(p)))])
+		n += int64(m)
+		if err != nil {
+			return err
+		}
+		m, err = bw.Write(p)
+		n += int64(m)
+		return err
+	}
+
+	it := b.txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+	for it.Rewind(); it.Valid(); it.Next() {
+		item := it.Item()
+		val, err := item.ValueCopy(nil)
+		if err != nil {
+			return n, err
+		}
+		if err := write(item.Key()); err != nil {
+			return n, err
+		}
+		if err := write(val); err != nil {
+			return n, err
+		}
+	}
+	if err := write(nil); err != nil {
+		return n, err
+	}
+	return n, bw.Flush()
+}
+
+//Close releases the view of the database
+func (b *StoreBackup) Close() {
+	b.txn.Discard()
+}
+
+//ReadStoreBackup reads a backup written by StoreBackup.WriteTo and calls f with
+//each of its keys and values. Nothing past the end of the backup is read from r.
+func ReadStoreBackup(r *bufio.Reader, f func(key, val []byte) error) error {
+	read := func() ([]byte, error) {
+		l, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+		if l > maxBackupRecord {
+			return nil, fmt.Errorf("Store backup record of %d bytes", l)
+		}
+		p := make([]byte, l)
+		if _, err := io.ReadFull(r, p); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		return p, nil
+	}
+
+	for {
+		key, err := read()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if len(key) == 0 {
+			return nil
+		}
+		val, err := read()
+		if err == io.EOF {
+			return io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return err
+		}
+		if err := f(key, val); err != nil {
+			return err
+		}
+	}
+}
+
+//RestoreBadgerStore writes the backup read from r to a new database at path,
+//which must not exist or be empty
+func RestoreBadgerStore(path string, r *bufio.Reader) error {
+	if files, err := ioutil.ReadDir(path); err == nil && len(files) > 0 {
+		return fmt.Errorf("%s is not empty", path)
+	} else if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	db, err := openBadger(path)
+	if err != nil {
+		return err
+	}
+
+	type record struct{ key, val []byte }
+	batch := []record{}
+	flush := func() error {
+		err := db.Update(func(txn *badger.Txn) error {
+			for _, r := range batch {
+				if err := txn.Set(r.key, r.val); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		batch = batch[:0]
+		return err
+	}
+	err = ReadStoreBackup(r, func(key, val []byte) error {
+		batch = append(batch, record{key, val})
+		if len(batch) < restoreBatch {
+			return nil
+		}
+		return flush()
+	})
+	if err == nil {
+		err = flush()
+	}
+	if cerr := db.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return err
+	}
+
+	//only keep databases that LoadBadgerStore can open
+	store, err := LoadBadgerStore(1, path)
+	if err != nil {
+		return errors.New("Restored store can not be loaded: " + err.Error())
+	}
+	return store.Close()
+}
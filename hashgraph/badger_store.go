@@ -0,0 +1,765 @@
+package hashgraph
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/babbleio/babble/codec"
+	cm "github.com/babbleio/babble/common"
+	"github.com/dgraph-io/badger"
+)
+
+/*
+BadgerStore writes the Events, Rounds, Roots, participants and consensus order
+of the hashgraph to a Badger database so that they survive a restart, along
+with the last Blocks committed by the node, which carry its numbering, and the
+state the node keeps along with them. It keeps
+an InmemStore in front of the database: reads are served from the caches and
+only fall back to the database for what was evicted, or never loaded since the
+database was opened.
+*/
+
+type BadgerStore struct {
+	participants map[string]int //[public key] => id
+	inmemStore   *InmemStore
+	db           *badger.DB
+	path         string
+}
+
+//storedEvent is the form of an Event written to the database. Unlike
+//Event.Marshal, it keeps the wire information and the coordinates computed by
+//the Hashgraph, which are not part of the signed Event.
+type storedEvent struct {
+	Event                Event
+	SelfParentIndex      int
+	OtherParentCreatorID int
+	OtherParentIndex     int
+	CreatorID            int
+	TopologicalIndex     int
+	RoundReceived        *int
+	ConsensusTimestamp   time.Time
+	LastAncestors        []storedCoordinates
+	FirstDescendants     []storedCoordinates
+}
+
+//Hash is no longer set, see EventCoordinates. It stays so that the databases
+//written before coordinates were indexes can still be read.
+type storedCoordinates struct {
+	Hash  string
+	Index int
+}
+
+//NewBadgerStore opens, or creates, the database at path and records the
+//participants in it
+func NewBadgerStore(participants map[string]int, cacheSize int, path string) (*BadgerStore, error) {
+	db, err := openBadger(path)
+	if err != nil {
+		return nil, err
+	}
+	store := &BadgerStore{
+		participants: participants,
+		inmemStore:   NewInmemStore(participants, cacheSize),
+		db:           db,
+		path:         path,
+	}
+	for pk, id := range participants {
+		if err := store.dbSetParticipant(pk, id); err != nil {
+			db.Close()
+			return nil, err
+		}
+		root, _ := store.inmemStore.GetRoot(pk)
+		if err := store.dbSetRoot(pk, root); err != nil {
+			db.Close()
+			return nil, err
+		}
+	}
+	return store, nil
+}
+
+//LoadBadgerStore opens an existing database and restores the participants and
+//Roots recorded in it
+func LoadBadgerStore(cacheSize int, path string) (*BadgerStore, error) {
+	db, err := openBadger(path)
+	if err != nil {
+		return nil, err
+	}
+	store := &BadgerStore{
+		db:   db,
+		path: path,
+	}
+	participants, err := store.dbGetParticipants()
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	if len(participants) == 0 {
+		db.Close()
+		return nil, fmt.Errorf("No participants in %s", path)
+	}
+	roots := make(map[string]Root)
+	for pk := range participants {
+		root, err := store.dbGetRoot(pk)
+		if err != nil {
+			db.Close()
+			return nil, err
+		}
+		roots[pk] = root
+	}
+	store.participants = participants
+	store.inmemStore = NewInmemStore(participants, cacheSize)
+	if err := store.inmemStore.Reset(roots); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+func openBadger(path string) (*badger.DB, error) {
+	opts := badger.DefaultOptions(path)
+	opts.SyncWrites = false
+	opts.EventLogging = false
+	return badger.Open(opts)
+}
+
+//Path returns the directory of the database
+func (s *BadgerStore) Path() string {
+	return s.path
+}
+
+func (s *BadgerStore) Participants() map[string]int {
+	return s.participants
+}
+
+//++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//Store interface
+
+func (s *BadgerStore) CacheSize() int {
+	return s.inmemStore.CacheSize()
+}
+
+func (s *BadgerStore) GetEvent(key string) (Event, error) {
+	event, err := s.inmemStore.GetEvent(key)
+	if cm.Is(err, cm.KeyNotFound) {
+		return s.dbGetEvent(key)
+	}
+	return event, err
+}
+
+func (s *BadgerStore) SetEvent(event Event) error {
+	if err := s.inmemStore.SetEvent(event); err != nil {
+		return err
+	}
+	return s.dbSetEvent(event)
+}
+
+func (s *BadgerStore) ParticipantEvents(participant string, skip int) ([]string, error) {
+	res, err := s.inmemStore.ParticipantEvents(participant, skip)
+	if cm.Is(err, cm.TooLate) {
+		return s.dbParticipantEvents(participant, skip)
+	}
+	return res, err
+}
+
+func (s *BadgerStore) ParticipantEvent(participant string, index int) (string, error) {
+	res, err := s.inmemStore.ParticipantEvent(participant, index)
+	if cm.Is(err, cm.TooLate) || cm.Is(err, cm.KeyNotFound) {
+		return s.dbParticipantEvent(participant, index)
+	}
+	return res, err
+}
+
+func (s *BadgerStore) LastFrom(participant string) (string, bool, error) {
+	return s.inmemStore.LastFrom(participant)
+}
+
+func (s *BadgerStore) Known() map[int]int {
+	return s.inmemStore.Known()
+}
+
+//KnownRanges extends the ranges of the caches down to the Roots because the
+//older Events are still served from the database. The Events below the Roots
+//were pruned, even if they are still in the caches.
+func (s *BadgerStore) KnownRanges() KnownRanges {
+	ranges := s.inmemStore.KnownRanges()
+	for pk, id := range s.participants {
+		r, ok := ranges[id]
+		if !ok {
+			continue
+		}
+		root, err := s.inmemStore.GetRoot(pk)
+		if err != nil {
+			continue
+		}
+		if first := root.Index + 1; first <= r.Last {
+			r.First = first
+			ranges[id] = r
+		}
+	}
+	return ranges
+}
+
+func (s *BadgerStore) ConsensusEvents() []string {
+	return s.inmemStore.ConsensusEvents()
+}
+
+func (s *BadgerStore) ConsensusEventsCount() int {
+	return s.inmemStore.ConsensusEventsCount()
+}
+
+func (s *BadgerStore) AddConsensusEvent(key string) error {
+	index := s.inmemStore.ConsensusEventsCount()
+	if err := s.inmemStore.AddConsensusEvent(key); err != nil {
+		return err
+	}
+	return s.dbSet(consensusKey(index), []byte(key))
+}
+
+func (s *BadgerStore) GetRound(r int) (RoundInfo, error) {
+	round, err := s.inmemStore.GetRound(r)
+	if cm.Is(err, cm.KeyNotFound) {
+		return s.dbGetRound(r)
+	}
+	return round, err
+}
+
+func (s *BadgerStore) SetRound(r int, round RoundInfo) error {
+	if err := s.inmemStore.SetRound(r, round); err != nil {
+		return err
+	}
+	val, err := round.Marshal()
+	if err != nil {
+		return err
+	}
+	return s.dbSet(roundKey(r), val)
+}
+
+func (s *BadgerStore) LastRound() int {
+	return s.inmemStore.LastRound()
+}
+
+func (s *BadgerStore) RoundWitnesses(r int) []string {
+	round, err := s.GetRound(r)
+	if err != nil {
+		return []string{}
+	}
+	return round.Witnesses()
+}
+
+func (s *BadgerStore) RoundEvents(r int) int {
+	round, err := s.GetRound(r)
+	if err != nil {
+		return 0
+	}
+	return len(round.Events)
+}
+
+func (s *BadgerStore) GetRoot(participant string) (Root, error) {
+	root, err := s.inmemStore.GetRoot(participant)
+	if cm.Is(err, cm.KeyNotFound) {
+		return s.dbGetRoot(participant)
+	}
+	return root, err
+}
+
+func (s *BadgerStore) AddParticipant(participant string, id int) error {
+	s.participants[participant] = id
+	if err := s.inmemStore.AddParticipant(participant, id); err != nil {
+		return err
+	}
+	if err := s.dbSetParticipant(participant, id); err != nil {
+		return err
+	}
+	root, _ := s.inmemStore.GetRoot(participant)
+	return s.dbSetRoot(participant, root)
+}
+
+func (s *BadgerStore) Reset(roots map[string]Root) error {
+	if err := s.inmemStore.Reset(roots); err != nil {
+		return err
+	}
+	for pk, root := range roots {
+		if err := s.dbSetRoot(pk, root); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Compact reclaims the space of the value log taken by values that were
+//overwritten. It returns once there is nothing left to reclaim.
+func (s *BadgerStore) Compact() error {
+	for {
+		err := s.db.RunValueLogGC(0.5)
+		if err == badger.ErrNoRewrite {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+//Prune deletes the Events below roots, the Rounds below round and the consensus
+//entries of the deleted Events from the database, and makes roots the Roots of
+//the store, see prune.go. The Events still in the caches are served until they
+//are evicted. Compact reclaims the space afterwards.
+func (s *BadgerStore) Prune(roots map[string]Root, round int) (int, error) {
+	keys := [][]byte{}
+	deleted := make(map[string]bool)
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		//key and value of the entries below last that start with prefix
+		scan := func(prefix, last []byte, f func(key, val []byte) error) error {
+			for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+				item := it.Item()
+				if last != nil && bytes.Compare(item.Key(), last) > 0 {
+					return nil
+				}
+				val, err := item.ValueCopy(nil)
+				if err != nil {
+					return err
+				}
+				if err := f(append([]byte{}, item.Key()...), val); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		for pk, root := range roots {
+			prefix := []byte(fmt.Sprintf("pe_%s_", pk))
+			err := scan(prefix, participantEventKey(pk, root.Index), func(key, val []byte) error {
+				keys = append(keys, key, eventKey(string(val)))
+				deleted[string(val)] = true
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+		err := scan([]byte("round_"), roundKey(round-1), func(key, val []byte) error {
+			keys = append(keys, key)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return scan([]byte("topo_"), nil, func(key, val []byte) error {
+			if deleted[string(val)] {
+				keys = append(keys, key)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	//a transaction only holds so many writes
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > pruneBatch {
+			n = pruneBatch
+		}
+		err := s.db.Update(func(txn *badger.Txn) error {
+			for _, k := range keys[:n] {
+				if err := txn.Delete(k); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return 0, err
+		}
+		keys = keys[n:]
+	}
+
+	for pk, root := range roots {
+		if err := s.dbSetRoot(pk, root); err != nil {
+			return 0, err
+		}
+		s.inmemStore.roots[pk] = root
+	}
+	return len(deleted), nil
+}
+
+//pruneBatch is the number of keys deleted in each transaction of Prune
+const pruneBatch = 1000
+
+//SetBlock writes a committed Block to the database and deletes the Blocks more
+//than keep below it, including the ones a fast-forward skipped
+func (s *BadgerStore) SetBlock(block Block, keep int) error {
+	val, err := codec.Marshal(codec.Gob, block)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(txn *badger.Txn) error {
+		old := [][]byte{}
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		prefix := []byte(blockPrefix)
+		last := blockKey(block.Index - keep)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			if bytes.Compare(it.Item().Key(), last) > 0 {
+				break
+			}
+			old = append(old, append([]byte{}, it.Item().Key()...))
+		}
+		it.Close()
+		for _, k := range old {
+			if err := txn.Delete(k); err != nil {
+				return err
+			}
+		}
+		return txn.Set(blockKey(block.Index), val)
+	})
+}
+
+//SetNodeState writes state of the node that must survive a restart like the
+//hashgraph, such as its commit deduplication window, under name
+func (s *BadgerStore) SetNodeState(name string, val []byte) error {
+	return s.dbSet(nodeStateKey(name), val)
+}
+
+//GetNodeState returns the state written under name, or a KeyNotFound StoreErr
+func (s *BadgerStore) GetNodeState(name string) ([]byte, error) {
+	return s.dbGet(nodeStateKey(name))
+}
+
+//Blocks returns the Blocks written by SetBlock, by index
+func (s *BadgerStore) Blocks() ([]Block, error) {
+	blocks := []Block{}
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		prefix := []byte(blockPrefix)
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			val, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var block Block
+			if err := codec.Unmarshal(codec.Gob, val, &block); err != nil {
+				return err
+			}
+			blocks = append(blocks, block)
+		}
+		return nil
+	})
+	return blocks, err
+}
+
+//LastStoredRound returns the last round written to the database, once it is
+//decoded, or -1 if there is none. Unlike LastRound, it does not wait for the
+//hashgraph to be replayed from the store.
+func (s *BadgerStore) LastStoredRound() (int, error) {
+	last := -1
+	err := s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = true
+		it := txn.NewIterator(opts)
+		defer it.Close()
+		prefix := []byte("round_")
+		//round keys are padded, so the last one comes first in reverse
+		it.Seek(append(append([]byte{}, prefix...), 0xFF))
+		if !it.ValidForPrefix(prefix) {
+			return nil
+		}
+		val, err := it.Item().ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+		if err := NewRoundInfo().Unmarshal(val); err != nil {
+			return err
+		}
+		_, err = fmt.Sscanf(string(it.Item().Key()), "round_%d", &last)
+		return err
+	})
+	return last, err
+}
+
+func (s *BadgerStore) Close() error {
+	if err := s.inmemStore.Close(); err != nil {
+		return err
+	}
+	return s.db.Close()
+}
+
+//++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//Database
+
+func eventKey(hash string) []byte {
+	return []byte("event_" + hash)
+}
+
+func participantEventKey(participant string, index int) []byte {
+	return []byte(fmt.Sprintf("pe_%s_%09d", participant, index))
+}
+
+func roundKey(r int) []byte {
+	return []byte(fmt.Sprintf("round_%09d", r))
+}
+
+func consensusKey(index int) []byte {
+	return []byte(fmt.Sprintf("topo_%09d", index))
+}
+
+func rootKey(participant string) []byte {
+	return []byte("root_" + participant)
+}
+
+const participantPrefix = "participant_"
+
+const blockPrefix = "block_"
+
+func nodeStateKey(name string) []byte {
+	return []byte("state_" + name)
+}
+
+//Blocks are numbered from 0, so that their keys sort like their indexes
+func blockKey(index int) []byte {
+	return []byte(fmt.Sprintf("%s%09d", blockPrefix, index))
+}
+
+func participantKey(participant string) []byte {
+	return []byte(participantPrefix + participant)
+}
+
+func (s *BadgerStore) dbSet(key []byte, val []byte) error {
+	return s.db.Update(func(txn *badger.Txn) error {
+		return txn.Set(key, val)
+	})
+}
+
+//dbGet returns a KeyNotFound StoreErr if the key is not in the database
+func (s *BadgerStore) dbGet(key []byte) ([]byte, error) {
+	var val []byte
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(key)
+		if err != nil {
+			return err
+		}
+		val, err = item.ValueCopy(nil)
+		return err
+	})
+	if err == badger.ErrKeyNotFound {
+		return nil, cm.NewStoreErr(cm.KeyNotFound, string(key))
+	}
+	return val, err
+}
+
+func (s *BadgerStore) dbGetEvent(key string) (Event, error) {
+	val, err := s.dbGet(eventKey(key))
+	if err != nil {
+		return Event{}, err
+	}
+	var se storedEvent
+	if err := codec.Unmarshal(codec.Gob, val, &se); err != nil {
+		return Event{}, err
+	}
+	return se.event(), nil
+}
+
+//dbSetEvent writes the Event and, the first time, indexes it by creator
+func (s *BadgerStore) dbSetEvent(event Event) error {
+	key := event.Hex()
+	val, err := codec.Marshal(codec.Gob, newStoredEvent(event))
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(txn *badger.Txn) error {
+		if err := txn.Set(eventKey(key), val); err != nil {
+			return err
+		}
+		return txn.Set(participantEventKey(event.Creator(), event.Index()), []byte(key))
+	})
+}
+
+//dbParticipantEvents returns the hashes of the participant's Events with an
+//index above skip, in order
+func (s *BadgerStore) dbParticipantEvents(participant string, skip int) ([]string, error) {
+	res := []string{}
+	prefix := []byte(fmt.Sprintf("pe_%s_", participant))
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(participantEventKey(participant, skip+1)); it.ValidForPrefix(prefix); it.Next() {
+			val, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			res = append(res, string(val))
+		}
+		return nil
+	})
+	return res, err
+}
+
+//dbTopologicalEvents returns the Events above the Roots in the order they were
+//inserted, with the wire information they were inserted with
+func (s *BadgerStore) dbTopologicalEvents() ([]Event, error) {
+	stored := []storedEvent{}
+	prefix := []byte("event_")
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			val, err := it.Item().ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			var se storedEvent
+			if err := codec.Unmarshal(codec.Gob, val, &se); err != nil {
+				return err
+			}
+			root, err := s.inmemStore.GetRoot(se.Event.Creator())
+			if err != nil || se.Event.Index() <= root.Index {
+				continue
+			}
+			stored = append(stored, se)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	events := make([]Event, len(stored))
+	for i, se := range stored {
+		e := se.Event
+		e.SetWireInfo(se.SelfParentIndex, se.OtherParentCreatorID, se.OtherParentIndex, se.CreatorID)
+		e.topologicalIndex = se.TopologicalIndex
+		events[i] = e
+	}
+	sort.Sort(ByTopologicalOrder(events))
+	return events, nil
+}
+
+func (s *BadgerStore) dbParticipantEvent(participant string, index int) (string, error) {
+	val, err := s.dbGet(participantEventKey(participant, index))
+	if err != nil {
+		return "", err
+	}
+	return string(val), nil
+}
+
+func (s *BadgerStore) dbGetRound(r int) (RoundInfo, error) {
+	val, err := s.dbGet(roundKey(r))
+	if err != nil {
+		return *NewRoundInfo(), err
+	}
+	round := NewRoundInfo()
+	if err := round.Unmarshal(val); err != nil {
+		return *NewRoundInfo(), err
+	}
+	return *round, nil
+}
+
+func (s *BadgerStore) dbGetRoot(participant string) (Root, error) {
+	val, err := s.dbGet(rootKey(participant))
+	if err != nil {
+		return Root{}, err
+	}
+	var root Root
+	if err := codec.Unmarshal(codec.Gob, val, &root); err != nil {
+		return Root{}, err
+	}
+	return root, nil
+}
+
+func (s *BadgerStore) dbSetRoot(participant string, root Root) error {
+	val, err := codec.Marshal(codec.Gob, root)
+	if err != nil {
+		return err
+	}
+	return s.dbSet(rootKey(participant), val)
+}
+
+func (s *BadgerStore) dbSetParticipant(participant string, id int) error {
+	return s.dbSet(participantKey(participant), []byte(strconv.Itoa(id)))
+}
+
+func (s *BadgerStore) dbGetParticipants() (map[string]int, error) {
+	participants := make(map[string]int)
+	prefix := []byte(participantPrefix)
+	err := s.db.View(func(txn *badger.Txn) error {
+		it := txn.NewIterator(badger.DefaultIteratorOptions)
+		defer it.Close()
+		for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+			item := it.Item()
+			val, err := item.ValueCopy(nil)
+			if err != nil {
+				return err
+			}
+			id, err := strconv.Atoi(string(val))
+			if err != nil {
+				return err
+			}
+			participants[string(item.Key()[len(prefix):])] = id
+		}
+		return nil
+	})
+	return participants, err
+}
+
+//++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//storedEvent
+
+func newStoredEvent(e Event) storedEvent {
+	coordinates := func(cs []EventCoordinates) []storedCoordinates {
+		if cs == nil {
+			return nil
+		}
+		res := make([]storedCoordinates, len(cs))
+		for i, c := range cs {
+			res[i] = storedCoordinates{Index: c.index}
+		}
+		return res
+	}
+	var roundReceived *int
+	if e.received {
+		roundReceived = &e.roundReceived
+	}
+	return storedEvent{
+		Event:                e,
+		SelfParentIndex:      e.Body.selfParentIndex,
+		OtherParentCreatorID: e.Body.otherParentCreatorID,
+		OtherParentIndex:     e.Body.otherParentIndex,
+		CreatorID:            e.Body.creatorID,
+		TopologicalIndex:     e.topologicalIndex,
+		RoundReceived:        roundReceived,
+		ConsensusTimestamp:   e.consensusTimestamp,
+		LastAncestors:        coordinates(e.lastAncestors),
+		FirstDescendants:     coordinates(e.firstDescendants),
+	}
+}
+
+func (se storedEvent) event() Event {
+	coordinates := func(cs []storedCoordinates) []EventCoordinates {
+		if cs == nil {
+			return nil
+		}
+		res := make([]EventCoordinates, len(cs))
+		for i, c := range cs {
+			res[i] = EventCoordinates{index: c.Index}
+		}
+		return res
+	}
+	e := se.Event
+	e.Body.selfParentIndex = se.SelfParentIndex
+	e.Body.otherParentCreatorID = se.OtherParentCreatorID
+	e.Body.otherParentIndex = se.OtherParentIndex
+	e.Body.creatorID = se.CreatorID
+	e.topologicalIndex = se.TopologicalIndex
+	if se.RoundReceived != nil {
+		e.SetRoundReceived(*se.RoundReceived)
+	}
+	e.consensusTimestamp = se.ConsensusTimestamp
+	e.lastAncestors = coordinates(se.LastAncestors)
+	e.firstDescendants = coordinates(se.FirstDescendants)
+	return e
+}
@@ -0,0 +1,425 @@
+package hashgraph
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	cm "github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/crypto"
+)
+
+func initBadgerStore(cacheSize int, t *testing.T) (*BadgerStore, []pub, string) {
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	participantPubs := []pub{}
+	participants := make(map[string]int)
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateECDSAKey()
+		pubKey := crypto.FromECDSAPub(&key.PublicKey)
+		participantPubs = append(participantPubs,
+			pub{i, pubKey, fmt.Sprintf("0x%X", pubKey)})
+		participants[fmt.Sprintf("0x%X", pubKey)] = i
+	}
+
+	store, err := NewBadgerStore(participants, cacheSize, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return store, participantPubs, dir
+}
+
+func TestBadgerEvents(t *testing.T) {
+	//the caches only keep the last Events, the others come from the database
+	cacheSize := 2
+	testSize := 10
+	store, participants, dir := initBadgerStore(cacheSize, t)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+
+	events := make(map[string][]Event)
+	for _, p := range participants {
+		items := []Event{}
+		for k := 0; k < testSize; k++ {
+			event := NewEvent([][]byte{[]byte(fmt.Sprintf("%s_%d", p.hex[:5], k))},
+				[]string{"", ""},
+				p.pubKey,
+				k)
+			event.SetWireInfo(k-1, 1, k, p.id)
+			event.topologicalIndex = k
+			event.lastAncestors = []EventCoordinates{{index: k}}
+			if err := store.SetEvent(event); err != nil {
+				t.Fatal(err)
+			}
+			items = append(items, event)
+		}
+		events[p.hex] = items
+	}
+
+	for p, evs := range events {
+		for k, ev := range evs {
+			rev, err := store.GetEvent(ev.Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(ev.Body, rev.Body) || rev.topologicalIndex != k ||
+				!reflect.DeepEqual(ev.lastAncestors, rev.lastAncestors) {
+				t.Fatalf("events[%s][%d] should be %#v, not %#v", p, k, ev, rev)
+			}
+		}
+	}
+
+	for _, p := range participants {
+		pEvents, err := store.ParticipantEvents(p.hex, 3)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if l := len(pEvents); l != testSize-4 {
+			t.Fatalf("%s should have %d events after 3, not %d", p.hex, testSize-4, l)
+		}
+		for k, e := range events[p.hex][4:] {
+			if e.Hex() != pEvents[k] {
+				t.Fatalf("ParticipantEvents[%s][%d] should be %s, not %s",
+					p.hex, k, e.Hex(), pEvents[k])
+			}
+		}
+		if h, err := store.ParticipantEvent(p.hex, 0); err != nil || h != events[p.hex][0].Hex() {
+			t.Fatalf("ParticipantEvent[%s][0] should be %s, not %s (%v)",
+				p.hex, events[p.hex][0].Hex(), h, err)
+		}
+
+		//the database holds every Event since the Root
+		r := store.KnownRanges()[p.id]
+		if r.First != 0 || r.Last != testSize-1 {
+			t.Fatalf("%s should know Events 0 to %d, not %v", p.hex, testSize-1, r)
+		}
+	}
+
+	if _, err := store.GetEvent("0xUNKNOWN"); !cm.Is(err, cm.KeyNotFound) {
+		t.Fatalf("GetEvent of an unknown Event should return KeyNotFound, not %v", err)
+	}
+}
+
+func TestLoadBadgerStore(t *testing.T) {
+	store, participants, dir := initBadgerStore(10, t)
+	defer os.RemoveAll(dir)
+
+	if r, err := store.LastStoredRound(); err != nil || r != -1 {
+		t.Fatalf("An empty store should have no last round, got %d (%v)", r, err)
+	}
+
+	round := NewRoundInfo()
+	event := NewEvent([][]byte{[]byte("tx")}, []string{"", ""}, participants[0].pubKey, 0)
+	round.AddEvent(event.Hex(), true)
+	if err := store.SetEvent(event); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []int{0, 11, 2} {
+		if err := store.SetRound(r, *round); err != nil {
+			t.Fatal(err)
+		}
+	}
+	root := Root{X: "x", Y: "y", Index: 5, Round: 1, Others: map[string]string{}}
+	roots := map[string]Root{}
+	for _, p := range participants {
+		roots[p.hex] = NewBaseRoot()
+	}
+	roots[participants[1].hex] = root
+	if err := store.Reset(roots); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadBadgerStore(10, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+
+	if !reflect.DeepEqual(loaded.Participants(), store.Participants()) {
+		t.Fatalf("Participants should be %v, not %v", store.Participants(), loaded.Participants())
+	}
+	if r, err := loaded.GetRoot(participants[1].hex); err != nil || !reflect.DeepEqual(r, root) {
+		t.Fatalf("Root should be %#v, not %#v (%v)", root, r, err)
+	}
+	if ev, err := loaded.GetEvent(event.Hex()); err != nil || !reflect.DeepEqual(ev.Body, event.Body) {
+		t.Fatalf("Event should be %#v, not %#v (%v)", event, ev, err)
+	}
+	if r, err := loaded.GetRound(0); err != nil || !reflect.DeepEqual(r, *round) {
+		t.Fatalf("Round should be %#v, not %#v (%v)", *round, r, err)
+	}
+	if r, err := loaded.LastStoredRound(); err != nil || r != 11 {
+		t.Fatalf("Last stored round should be 11, not %d (%v)", r, err)
+	}
+}
+
+func TestBootstrap(t *testing.T) {
+	logger := cm.NewTestLogger(t)
+	h, _ := initConsensusHashgraph(logger)
+
+	//the Events of the consensus hashgraph, in the order they were inserted
+	events := []Event{}
+	for pk := range h.Participants {
+		hashes, err := h.Store.ParticipantEvents(pk, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, hash := range hashes {
+			ev, err := h.Store.GetEvent(hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, ev)
+		}
+	}
+	sort.Sort(ByTopologicalOrder(events))
+
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewBadgerStore(h.Participants, cacheSize, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bh := NewHashgraph(h.Participants, store, nil, logger)
+	for _, ev := range events {
+		if err := bh.InsertEvent(ev, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bh.DivideRounds()
+	bh.DecideFame()
+	bh.FindOrder()
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadBadgerStore(cacheSize, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	rh := NewHashgraph(loaded.Participants(), loaded, nil, logger)
+	committed, err := rh.Bootstrap()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := rh.Store.(*BadgerStore); !ok {
+		t.Fatalf("Bootstrap should keep the BadgerStore, not %T", rh.Store)
+	}
+	if !reflect.DeepEqual(rh.Known(), bh.Known()) {
+		t.Fatalf("Known should be %v, not %v", bh.Known(), rh.Known())
+	}
+	if *rh.LastConsensusRound != *bh.LastConsensusRound {
+		t.Fatalf("LastConsensusRound should be %d, not %d", *bh.LastConsensusRound, *rh.LastConsensusRound)
+	}
+	if !reflect.DeepEqual(rh.UndecidedRounds, bh.UndecidedRounds) {
+		t.Fatalf("UndecidedRounds should be %v, not %v", bh.UndecidedRounds, rh.UndecidedRounds)
+	}
+	if !reflect.DeepEqual(rh.ConsensusEvents(), bh.ConsensusEvents()) {
+		t.Fatalf("ConsensusEvents should be %v, not %v", bh.ConsensusEvents(), rh.ConsensusEvents())
+	}
+	if len(committed) != bh.Store.ConsensusEventsCount() {
+		t.Fatalf("Bootstrap should return %d committed Events, not %d",
+			bh.Store.ConsensusEventsCount(), len(committed))
+	}
+}
+
+func TestBadgerPrune(t *testing.T) {
+	logger := cm.NewTestLogger(t)
+	h, index := initConsensusHashgraph(logger)
+
+	events := []Event{}
+	for pk := range h.Participants {
+		hashes, err := h.Store.ParticipantEvents(pk, -1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, hash := range hashes {
+			ev, err := h.Store.GetEvent(hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			events = append(events, ev)
+		}
+	}
+	sort.Sort(ByTopologicalOrder(events))
+
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := NewBadgerStore(h.Participants, cacheSize, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bh := NewHashgraph(h.Participants, store, nil, logger)
+	for _, ev := range events {
+		if err := bh.InsertEvent(ev, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+	bh.DivideRounds()
+	bh.DecideFame()
+	bh.FindOrder()
+
+	//the witnesses of round 2 are g0, g1 and g2, the sixth Events of their
+	//creators
+	roots, err := bh.PruneRoots(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	o02, _ := bh.Store.GetEvent(index["o02"])
+	if op := roots[o02.Creator()].Others[index["o02"]]; op != index["f21"] {
+		t.Fatalf("The other-parent of o02, f21, should be in the Root of its creator, got %s", op)
+	}
+	deleted, err := store.Prune(roots, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deleted != 15 {
+		t.Fatalf("The 5 first Events of each participant should be deleted, got %d", deleted)
+	}
+	for id, r := range store.KnownRanges() {
+		if r.First != 5 {
+			t.Fatalf("The Events of %d should be held from index 5, got %v", id, r)
+		}
+	}
+	if _, err := store.dbGetRound(1); err == nil {
+		t.Fatal("Round 1 should be deleted from the database")
+	}
+	known := bh.Known()
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//the pruned store restarts from its Roots
+	loaded, err := LoadBadgerStore(cacheSize, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	rh := NewHashgraph(loaded.Participants(), loaded, nil, logger)
+	if _, err := rh.Bootstrap(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(rh.Known(), known) {
+		t.Fatalf("Known should be %v, not %v", known, rh.Known())
+	}
+	for _, name := range []string{"g0", "g1", "g2", "h0"} {
+		if r := rh.Round(index[name]); r != bh.Round(index[name]) {
+			t.Fatalf("%s should be in round %d, not %d", name, bh.Round(index[name]), r)
+		}
+	}
+}
+
+func TestBadgerBlocks(t *testing.T) {
+	store, _, dir := initBadgerStore(10, t)
+	defer os.RemoveAll(dir)
+
+	//a fast-forward skips from Block 2 to Block 20
+	for _, i := range []int{0, 1, 2, 20, 21, 22} {
+		block := NewBlock(i, 2*i, [][]byte{[]byte(fmt.Sprintf("tx%d", i))})
+		block.StateHash = []byte{byte(i)}
+		if err := store.SetBlock(block, 2); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadBadgerStore(10, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer loaded.Close()
+	blocks, err := loaded.Blocks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	indexes := []int{}
+	for _, block := range blocks {
+		indexes = append(indexes, block.Index)
+	}
+	if !reflect.DeepEqual(indexes, []int{21, 22}) {
+		t.Fatalf("Blocks 21 and 22 should be kept, not %v", indexes)
+	}
+	if last := blocks[1]; last.RoundReceived != 44 || !bytes.Equal(last.StateHash, []byte{22}) {
+		t.Fatalf("Block 22 should be restored, got %#v", last)
+	}
+}
+
+func TestBadgerBackup(t *testing.T) {
+	store, participants, dir := initBadgerStore(10, t)
+	defer os.RemoveAll(dir)
+	defer store.Close()
+
+	event := NewEvent([][]byte{[]byte("tx")}, []string{"", ""}, participants[0].pubKey, 0)
+	if err := store.SetEvent(event); err != nil {
+		t.Fatal(err)
+	}
+
+	//what is written after the backup is opened is not part of it
+	backup := store.Backup()
+	later := NewEvent([][]byte{[]byte("later")}, []string{"", ""}, participants[1].pubKey, 0)
+	if err := store.SetEvent(later); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if _, err := backup.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	backup.Close()
+	data := buf.Bytes()
+
+	restoreDir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(restoreDir)
+	truncated := bufio.NewReader(bytes.NewReader(data[:len(data)-1]))
+	if err := RestoreBadgerStore(filepath.Join(restoreDir, "truncated"), truncated); err == nil {
+		t.Fatal("Restoring a truncated backup should fail")
+	}
+	path := filepath.Join(restoreDir, "store")
+	if err := RestoreBadgerStore(path, bufio.NewReader(bytes.NewReader(data))); err != nil {
+		t.Fatal(err)
+	}
+	if err := RestoreBadgerStore(path, bufio.NewReader(bytes.NewReader(data))); err == nil {
+		t.Fatal("Restoring over an existing store should fail")
+	}
+
+	restored, err := LoadBadgerStore(10, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	if !reflect.DeepEqual(restored.Participants(), store.Participants()) {
+		t.Fatalf("Participants should be %v, not %v", store.Participants(), restored.Participants())
+	}
+	if ev, err := restored.GetEvent(event.Hex()); err != nil || !reflect.DeepEqual(ev.Body, event.Body) {
+		t.Fatalf("Event should be %#v, not %#v (%v)", event, ev, err)
+	}
+	if _, err := restored.GetEvent(later.Hex()); !cm.Is(err, cm.KeyNotFound) {
+		t.Fatalf("Event written after the backup should not be restored, got %v", err)
+	}
+}
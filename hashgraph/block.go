@@ -0,0 +1,161 @@
+package hashgraph
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/babbleio/babble/codec"
+	"github.com/babbleio/babble/crypto"
+)
+
+//Block is a batch of consensus transactions that the App commits atomically.
+//The transactions of a Block are those of the Events that were received in the
+//same round, in consensus order.
+//
+//Once the App has committed a Block and returned its StateHash, every
+//validator signs the Block. A Block signed by a super-majority of the
+//validators of its round is final, which external clients can check with the
+//public keys of the validators, without running a node.
+//
+//Metadata is the opaque data that the Apps of the participants attached to the
+//Events of the Block, in consensus order. It is delivered to the Apps with the
+//transactions, and signed with them.
+type Block struct {
+	Index         int
+	RoundReceived int
+	Transactions  [][]byte
+	StateHash     []byte            //state of the App after committing the Block
+	Signatures    map[string]string //[validator public key] => signature
+	Version       int               //Scheme whose hash function hashes the Block, see HashScheme
+	Metadata      []BlockMetadata
+}
+
+//BlockMetadata is opaque data that the App of a node attached to one of the
+//Events of a Block
+type BlockMetadata struct {
+	Creator string //public key of the node, in hex
+	Data    []byte
+}
+
+func NewBlock(index, roundReceived int, transactions [][]byte) Block {
+	return Block{
+		Index:         index,
+		RoundReceived: roundReceived,
+		Transactions:  transactions,
+	}
+}
+
+//blockBody is the part of a Block that validators sign
+type blockBody struct {
+	Index         int
+	RoundReceived int
+	Transactions  [][]byte
+	StateHash     []byte
+}
+
+//blockBodyMetadata is the signed part of a Block with Metadata. Blocks without
+//Metadata keep the body they were signed with before Metadata existed.
+type blockBodyMetadata struct {
+	Index         int
+	RoundReceived int
+	Transactions  [][]byte
+	StateHash     []byte
+	Metadata      []BlockMetadata
+}
+
+//Hash is the hash of the signed fields, in gob like Event bodies, with the
+//hash function of the Scheme of its Version
+func (b *Block) Hash() ([]byte, error) {
+	scheme, err := GetScheme(b.Version)
+	if err != nil {
+		return nil, err
+	}
+	var body interface{} = &blockBody{b.Index, b.RoundReceived, b.Transactions, b.StateHash}
+	if len(b.Metadata) > 0 {
+		body = &blockBodyMetadata{b.Index, b.RoundReceived, b.Transactions, b.StateHash, b.Metadata}
+	}
+	bytes, err := codec.Marshal(codec.Gob, body)
+	if err != nil {
+		return nil, err
+	}
+	return scheme.Hash(bytes), nil
+}
+
+//BlockSignature is the signature of a Block by a validator
+type BlockSignature struct {
+	Validator string //public key, in hex
+	Index     int    //of the Block
+	Signature string
+}
+
+//Sign returns the signature of the Block by key
+func (b *Block) Sign(key crypto.KeyPair) (BlockSignature, error) {
+	hash, err := b.Hash()
+	if err != nil {
+		return BlockSignature{}, err
+	}
+	r, s, err := key.Sign(hash)
+	if err != nil {
+		return BlockSignature{}, err
+	}
+	return BlockSignature{
+		Validator: crypto.KeyPairPubKeyHex(key),
+		Index:     b.Index,
+		Signature: fmt.Sprintf("%X|%X", r, s),
+	}, nil
+}
+
+//Verify checks that sig is a signature of the Block by its validator
+func (b *Block) Verify(sig BlockSignature) (bool, error) {
+	if sig.Index != b.Index {
+		return false, nil
+	}
+	pubBytes, err := hex.DecodeString(strings.TrimPrefix(sig.Validator, "0x"))
+	if err != nil {
+		return false, err
+	}
+	if _, err := crypto.PubKeyAlgorithm(pubBytes); err != nil {
+		return false, fmt.Errorf("Invalid validator key %s", sig.Validator)
+	}
+	parts := strings.Split(sig.Signature, "|")
+	if len(parts) != 2 {
+		return false, fmt.Errorf("Invalid signature %s", sig.Signature)
+	}
+	r, okR := new(big.Int).SetString(parts[0], 16)
+	s, okS := new(big.Int).SetString(parts[1], 16)
+	if !okR || !okS {
+		return false, fmt.Errorf("Invalid signature %s", sig.Signature)
+	}
+	hash, err := b.Hash()
+	if err != nil {
+		return false, err
+	}
+	return crypto.VerifySignature(pubBytes, hash, r, s), nil
+}
+
+//SetSignature adds sig to the Signatures of the Block if it is valid
+func (b *Block) SetSignature(sig BlockSignature) error {
+	ok, err := b.Verify(sig)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("Invalid signature of Block %d by %s", b.Index, sig.Validator)
+	}
+	if b.Signatures == nil {
+		b.Signatures = make(map[string]string)
+	}
+	b.Signatures[sig.Validator] = sig.Signature
+	return nil
+}
+
+//GetSignatures returns the Signatures of the Block as BlockSignatures
+func (b *Block) GetSignatures() []BlockSignature {
+	sigs := make([]BlockSignature, 0, len(b.Signatures))
+	for validator, sig := range b.Signatures {
+		sigs = append(sigs, BlockSignature{validator, b.Index, sig})
+	}
+	return sigs
+}
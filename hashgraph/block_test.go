@@ -0,0 +1,121 @@
+package hashgraph
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	"github.com/babbleio/babble/codec"
+	"github.com/babbleio/babble/crypto"
+)
+
+func TestBlockSignature(t *testing.T) {
+	key, _ := crypto.GenerateECDSAKey()
+	block := NewBlock(3, 7, [][]byte{[]byte("tx1"), []byte("tx2")})
+	block.StateHash = []byte("state")
+
+	sig, err := block.Sign(crypto.NewECDSAKeyPair(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := block.SetSignature(sig); err != nil {
+		t.Fatal(err)
+	}
+	if len(block.Signatures) != 1 || block.GetSignatures()[0] != sig {
+		t.Fatalf("Block should hold the signature, got %v", block.Signatures)
+	}
+
+	//the signature covers the state hash
+	other := NewBlock(3, 7, block.Transactions)
+	other.StateHash = []byte("other state")
+	if err := other.SetSignature(sig); err == nil {
+		t.Fatal("Signature of a different state should be rejected")
+	}
+
+	//and the index
+	moved := NewBlock(4, 7, block.Transactions)
+	moved.StateHash = block.StateHash
+	if ok, _ := moved.Verify(sig); ok {
+		t.Fatal("Signature of another index should be rejected")
+	}
+
+	sig.Signature = "garbage"
+	if _, err := block.Verify(sig); err == nil {
+		t.Fatal("Malformed signature should be an error")
+	}
+}
+
+func TestBlockHashVersion(t *testing.T) {
+	key, _ := crypto.GenerateECDSAKey()
+	block := NewBlock(3, 7, [][]byte{[]byte("tx1")})
+	version, err := HashScheme(crypto.BLAKE2b256Hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Version = version
+
+	sig, err := block.Sign(crypto.NewECDSAKeyPair(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := block.SetSignature(sig); err != nil {
+		t.Fatal(err)
+	}
+
+	//the version survives the wire
+	var decoded Block
+	if err := decoded.UnmarshalProto(block.MarshalProto()); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Version != version {
+		t.Fatalf("Block version should be %d, got %d", version, decoded.Version)
+	}
+
+	//a node hashing with SHA256 does not accept the signature
+	sha := NewBlock(3, 7, block.Transactions)
+	if ok, _ := sha.Verify(sig); ok {
+		t.Fatal("Signature of a BLAKE2b hash should be rejected with SHA256")
+	}
+}
+
+func TestBlockMetadata(t *testing.T) {
+	key, _ := crypto.GenerateECDSAKey()
+	block := NewBlock(3, 7, [][]byte{[]byte("tx1")})
+	plain, err := block.Hash()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//Blocks without metadata keep the hash they had before metadata existed
+	legacy, err := codec.Marshal(codec.Gob, &blockBody{3, 7, block.Transactions, nil})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(plain, crypto.SHA256(legacy)) {
+		t.Fatal("Hash of a Block without metadata should not change")
+	}
+
+	block.Metadata = []BlockMetadata{{Creator: "0xAB", Data: []byte("oracle")}}
+	sig, err := block.Sign(crypto.NewECDSAKeyPair(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := block.SetSignature(sig); err != nil {
+		t.Fatal(err)
+	}
+
+	//the signature covers the metadata
+	other := NewBlock(3, 7, block.Transactions)
+	other.Metadata = []BlockMetadata{{Creator: "0xAB", Data: []byte("forged")}}
+	if ok, _ := other.Verify(sig); ok {
+		t.Fatal("Signature of different metadata should be rejected")
+	}
+
+	var decoded Block
+	if err := decoded.UnmarshalProto(block.MarshalProto()); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, block) {
+		t.Fatalf("Block should survive the wire, got %+v", decoded)
+	}
+}
@@ -106,6 +106,31 @@ func (pec *ParticipantEventsCache) Known() map[int]int {
 	return known
 }
 
+//returns [participant id] => range of the cached indexes. Participants without
+//Events are left out.
+func (pec *ParticipantEventsCache) KnownRanges() KnownRanges {
+	ranges := make(KnownRanges)
+	for p, evs := range pec.participantEvents {
+		items, lastIndex := evs.GetLastWindow()
+		if len(items) == 0 {
+			continue
+		}
+		ranges[pec.participants[p]] = Range{
+			First: lastIndex - len(items) + 1,
+			Last:  lastIndex,
+		}
+	}
+	return ranges
+}
+
+//AddParticipant starts caching the Events of a participant added at runtime
+func (pec *ParticipantEventsCache) AddParticipant(participant string, id int) {
+	pec.participants[participant] = id
+	if _, ok := pec.participantEvents[participant]; !ok {
+		pec.participantEvents[participant] = cm.NewRollingIndex(pec.size)
+	}
+}
+
 func (pec *ParticipantEventsCache) Reset() error {
 	items := make(map[string]*cm.RollingIndex)
 	for pk := range pec.participants {
@@ -1,52 +1,37 @@
 package hashgraph
 
-import "math/big"
+import (
+	"math/big"
 
+	"github.com/babbleio/babble/hashgraph/ordering"
+)
+
+//ConsensusSorter implements sort.Interface for []Event following the
+//tie-breaking rules of the ordering package. It knows no rounds, so signatures
+//are whitened with 0, as they always were.
 type ConsensusSorter struct {
-	a     []Event
-	r     map[int]RoundInfo
-	cache map[int]*big.Int
+	a []Event
 }
 
 func NewConsensusSorter(events []Event) ConsensusSorter {
 	return ConsensusSorter{
-		a:     events,
-		r:     make(map[int]RoundInfo),
-		cache: make(map[int]*big.Int),
+		a: events,
 	}
 }
 
 func (b ConsensusSorter) Len() int      { return len(b.a) }
 func (b ConsensusSorter) Swap(i, j int) { b.a[i], b.a[j] = b.a[j], b.a[i] }
 func (b ConsensusSorter) Less(i, j int) bool {
-	irr, jrr := -1, -1
-	if b.a[i].roundReceived != nil {
-		irr = *b.a[i].roundReceived
-	}
-	if b.a[j].roundReceived != nil {
-		jrr = *b.a[j].roundReceived
-	}
-	if irr != jrr {
-		return irr < jrr
-	}
-
-	if !b.a[i].consensusTimestamp.Equal(b.a[j].consensusTimestamp) {
-		return b.a[i].consensusTimestamp.Before(b.a[j].consensusTimestamp)
+	ki, kj := b.a[i].OrderingKey(), b.a[j].OrderingKey()
+	var prn *big.Int
+	if ki.RoundReceived == kj.RoundReceived {
+		prn = b.GetPseudoRandomNumber(ki.RoundReceived)
 	}
-
-	w := b.GetPseudoRandomNumber(*b.a[i].roundReceived)
-	wsi := new(big.Int)
-	wsi = wsi.Xor(b.a[i].S, w)
-	wsj := new(big.Int)
-	wsj = wsj.Xor(b.a[j].S, w)
-	return wsi.Cmp(wsj) < 0
+	return ordering.Less(ki, kj, prn)
 }
+
+//GetPseudoRandomNumber returns the number that whitens the signatures of the
+//Events received in round, which is always 0
 func (b ConsensusSorter) GetPseudoRandomNumber(round int) *big.Int {
-	if ps, ok := b.cache[round]; ok {
-		return ps
-	}
-	rd := b.r[round]
-	ps := rd.PseudoRandomNumber()
-	b.cache[round] = ps
-	return ps
+	return new(big.Int)
 }
@@ -1,14 +1,13 @@
 package hashgraph
 
 import (
-	"bytes"
-	"crypto/ecdsa"
-	"encoding/gob"
 	"fmt"
 	"math/big"
 	"time"
 
+	"github.com/babbleio/babble/codec"
 	"github.com/babbleio/babble/crypto"
+	"github.com/babbleio/babble/hashgraph/ordering"
 )
 
 type EventBody struct {
@@ -17,6 +16,7 @@ type EventBody struct {
 	Creator      []byte    //creator's public key
 	Timestamp    time.Time //creator's claimed timestamp of the event's creation
 	Index        int       //index in the sequence of events created by Creator
+	Version      int       //scheme the event is hashed and signed with, see Scheme
 
 	//wire
 	//It is cheaper to send ints then hashes over the wire
@@ -26,32 +26,41 @@ type EventBody struct {
 	creatorID            int
 }
 
-//gob encoding of body only
+//gob encoding of body only. Hashes depend on it so it does not follow the
+//transport codec.
 func (e *EventBody) Marshal() ([]byte, error) {
-	var b bytes.Buffer
-	enc := gob.NewEncoder(&b) //will write to b
-	if err := enc.Encode(e); err != nil {
-		return nil, err
-	}
-	return b.Bytes(), nil
+	return codec.Marshal(codec.Gob, e)
 }
 
 func (e *EventBody) Unmarshal(data []byte) error {
-	b := bytes.NewBuffer(data)
-	dec := gob.NewDecoder(b) //will read from b
-	return dec.Decode(e)
+	return codec.Unmarshal(codec.Gob, data, e)
 }
 
 func (e *EventBody) Hash() ([]byte, error) {
+	scheme, err := GetScheme(e.Version)
+	if err != nil {
+		return nil, err
+	}
 	hashBytes, err := e.Marshal()
 	if err != nil {
 		return nil, err
 	}
-	return crypto.SHA256(hashBytes), nil
+	return scheme.Hash(hashBytes), nil
 }
 
+/*
+Every Event holds the coordinates of its last ancestor and first descendant by
+each participant, which makes 2N of them per Event. They used to be hashes, that
+is 2N strings per Event for the garbage collector to scan, and the mark phase of
+a large hashgraph took long enough to show up as sync timeouts. Coordinates are
+now only indexes, and the Event a coordinate refers to is found through the
+index of the Events of its participant, see Hashgraph.eventAt. The slices of
+coordinates hold no pointers, so the collector skips them altogether.
+
+roundReceived is likewise a plain int, rather than a pointer to one that was a
+separate object for every Event.
+*/
 type EventCoordinates struct {
-	hash  string
 	index int
 }
 
@@ -61,15 +70,17 @@ type Event struct {
 
 	topologicalIndex int
 
-	roundReceived      *int
+	roundReceived      int //only valid if received is true
 	consensusTimestamp time.Time
 
 	lastAncestors    []EventCoordinates //[participant fake id] => last ancestor
 	firstDescendants []EventCoordinates //[participant fake id] => first descendant
 
-	creator string
-	hash    []byte
-	hex     string
+	creator  string
+	hash     []byte
+	hex      string
+	verified bool //signature checked by VerifyEvents
+	received bool //roundReceived is decided
 }
 
 func NewEvent(transactions [][]byte,
@@ -81,7 +92,7 @@ func NewEvent(transactions [][]byte,
 		Transactions: transactions,
 		Parents:      parents,
 		Creator:      creator,
-		Timestamp:    time.Now().Round(0), //strip monotonic time
+		Timestamp:    time.Now().UTC().Round(0), //strip monotonic time
 		Index:        index,
 	}
 	return Event{
@@ -122,52 +133,67 @@ func (e *Event) IsLoaded() bool {
 		len(e.Body.Transactions) > 0
 }
 
-//ecdsa sig
-func (e *Event) Sign(privKey *ecdsa.PrivateKey) error {
+//signature of the body with the scheme of its Version
+func (e *Event) Sign(key crypto.Signer) error {
+	scheme, err := GetScheme(e.Body.Version)
+	if err != nil {
+		return err
+	}
 	signBytes, err := e.Body.Hash()
 	if err != nil {
 		return err
 	}
-	e.R, e.S, err = crypto.Sign(privKey, signBytes)
+	e.R, e.S, err = scheme.Sign(key, signBytes)
 	return err
 }
 
+//Verify checks the signature of the body, unless it was found valid before,
+//see signature_cache.go
 func (e *Event) Verify() (bool, error) {
-	pubBytes := e.Body.Creator
-	pubKey := crypto.ToECDSAPub(pubBytes)
-
+	scheme, err := GetScheme(e.Body.Version)
+	if err != nil {
+		return false, err
+	}
+	if e.R == nil || e.S == nil {
+		return false, nil
+	}
 	signBytes, err := e.Body.Hash()
 	if err != nil {
 		return false, err
 	}
 
-	return crypto.Verify(pubKey, signBytes, e.R, e.S), nil
+	key := newSignatureKey(e.Body.Version, e.Body.Creator, signBytes, e.R, e.S)
+	if verifiedSignatures.Contains(key) {
+		return true, nil
+	}
+	if !scheme.Verify(e.Body.Creator, signBytes, e.R, e.S) {
+		return false, nil
+	}
+	verifiedSignatures.Add(key, true)
+	return true, nil
 }
 
 //gob encoding of body and signature
 func (e *Event) Marshal() ([]byte, error) {
-	var b bytes.Buffer
-	enc := gob.NewEncoder(&b)
-	if err := enc.Encode(e); err != nil {
-		return nil, err
-	}
-	return b.Bytes(), nil
+	return codec.Marshal(codec.Gob, e)
 }
 
 func (e *Event) Unmarshal(data []byte) error {
-	b := bytes.NewBuffer(data)
-	dec := gob.NewDecoder(b) //will read from b
-	return dec.Decode(e)
+	return codec.Unmarshal(codec.Gob, data, e)
 }
 
-//sha256 hash of body and signature
+//hash of body and signature, with the scheme of the body's Version
 func (e *Event) Hash() ([]byte, error) {
 	if len(e.hash) == 0 {
+		scheme, err := GetScheme(e.Body.Version)
+		if err != nil {
+			return nil, err
+		}
 		hashBytes, err := e.Marshal()
 		if err != nil {
 			return nil, err
 		}
-		e.hash = crypto.SHA256(hashBytes)
+		e.hash = scheme.Hash(hashBytes)
 	}
 	return e.hash, nil
 }
@@ -180,11 +206,27 @@ func (e *Event) Hex() string {
 	return e.hex
 }
 
-func (e *Event) SetRoundReceived(rr int) {
-	if e.roundReceived == nil {
-		e.roundReceived = new(int)
+//OrderingKey returns the fields used to sort the Event in consensus order
+func (e *Event) OrderingKey() ordering.Key {
+	return ordering.Key{
+		RoundReceived:      e.RoundReceived(),
+		ConsensusTimestamp: e.consensusTimestamp,
+		S:                  e.S,
+		Hash:               e.Hex(),
 	}
-	*e.roundReceived = rr
+}
+
+//RoundReceived returns the round in which the Event reached consensus, or -1
+func (e *Event) RoundReceived() int {
+	if !e.received {
+		return -1
+	}
+	return e.roundReceived
+}
+
+func (e *Event) SetRoundReceived(rr int) {
+	e.roundReceived = rr
+	e.received = true
 }
 
 func (e *Event) SetWireInfo(selfParentIndex,
@@ -207,6 +249,7 @@ func (e *Event) ToWire() WireEvent {
 			CreatorID:            e.Body.creatorID,
 			Timestamp:            e.Body.Timestamp,
 			Index:                e.Body.Index,
+			Version:              e.Body.Version,
 		},
 		R: e.R,
 		S: e.S,
@@ -252,6 +295,13 @@ type WireBody struct {
 
 	Timestamp time.Time
 	Index     int
+	Version   int
+}
+
+//WireRef identifies an Event like WireBodies reference their parents
+type WireRef struct {
+	CreatorID int
+	Index     int
 }
 
 type WireEvent struct {
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/babbleio/babble/codec"
 	"github.com/babbleio/babble/crypto"
 )
 
@@ -53,7 +54,7 @@ func TestSignEvent(t *testing.T) {
 	body.Creator = publicKeyBytes
 
 	event := Event{Body: body}
-	if err := event.Sign(privateKey); err != nil {
+	if err := event.Sign(crypto.NewECDSAKeyPair(privateKey)); err != nil {
 		t.Fatalf("Error signing Event: %s", err)
 	}
 
@@ -66,6 +67,24 @@ func TestSignEvent(t *testing.T) {
 	}
 }
 
+func TestSignEventEd25519(t *testing.T) {
+	key, _ := crypto.GenerateKeyPair(crypto.Ed25519)
+	body := createDummyEventBody()
+	body.Creator = key.PublicKey()
+
+	event := Event{Body: body}
+	if err := event.Sign(key); err != nil {
+		t.Fatalf("Error signing Event: %s", err)
+	}
+	if ok, err := event.Verify(); err != nil || !ok {
+		t.Fatalf("Event should be valid, got %v, %v", ok, err)
+	}
+	event.Body.Index++
+	if ok, _ := event.Verify(); ok {
+		t.Fatal("Event with another body should be invalid")
+	}
+}
+
 func TestMarshallEvent(t *testing.T) {
 	privateKey, _ := crypto.GenerateECDSAKey()
 	publicKeyBytes := crypto.FromECDSAPub(&privateKey.PublicKey)
@@ -74,7 +93,7 @@ func TestMarshallEvent(t *testing.T) {
 	body.Creator = publicKeyBytes
 
 	event := Event{Body: body}
-	if err := event.Sign(privateKey); err != nil {
+	if err := event.Sign(crypto.NewECDSAKeyPair(privateKey)); err != nil {
 		t.Fatalf("Error signing Event: %s", err)
 	}
 
@@ -93,6 +112,80 @@ func TestMarshallEvent(t *testing.T) {
 	}
 }
 
+//The hash of an Event must survive the JSON codec. JSON writes a zero offset
+//as Z, which is only decoded to the same location for UTC timestamps.
+func TestEventJSONHash(t *testing.T) {
+	privateKey, _ := crypto.GenerateECDSAKey()
+	publicKeyBytes := crypto.FromECDSAPub(&privateKey.PublicKey)
+
+	event := NewEvent([][]byte{[]byte("abc")}, []string{"", ""}, publicKeyBytes, 0)
+	if err := event.Sign(crypto.NewECDSAKeyPair(privateKey)); err != nil {
+		t.Fatalf("Error signing Event: %s", err)
+	}
+
+	raw, err := codec.Marshal(codec.JSON, event)
+	if err != nil {
+		t.Fatalf("Error marshalling Event: %s", err)
+	}
+	var newEvent Event
+	if err := codec.Unmarshal(codec.JSON, raw, &newEvent); err != nil {
+		t.Fatalf("Error unmarshalling Event: %s", err)
+	}
+
+	if newEvent.Hex() != event.Hex() {
+		t.Fatalf("Hash should be %s, not %s", event.Hex(), newEvent.Hex())
+	}
+	if ok, err := newEvent.Verify(); err != nil || !ok {
+		t.Fatalf("Signature should still be valid, got %v, %v", ok, err)
+	}
+}
+
+//Protobuf writes the offset of the zone of timestamps that are not in UTC, so
+//that their Gob encoding, and the hash, are the same after decoding
+func TestEventProtobufHash(t *testing.T) {
+	privateKey, _ := crypto.GenerateECDSAKey()
+	publicKeyBytes := crypto.FromECDSAPub(&privateKey.PublicKey)
+
+	for _, loc := range []*time.Location{time.UTC, time.FixedZone("CET", 3600)} {
+		event := NewEvent([][]byte{[]byte("abc"), []byte{}}, []string{"self", "other"}, publicKeyBytes, 3)
+		event.Body.Timestamp = event.Body.Timestamp.In(loc)
+		if err := event.Sign(crypto.NewECDSAKeyPair(privateKey)); err != nil {
+			t.Fatalf("Error signing Event: %s", err)
+		}
+
+		raw, err := codec.Marshal(codec.Protobuf, event)
+		if err != nil {
+			t.Fatalf("Error marshalling Event: %s", err)
+		}
+		var newEvent Event
+		if err := codec.Unmarshal(codec.Protobuf, raw, &newEvent); err != nil {
+			t.Fatalf("Error unmarshalling Event: %s", err)
+		}
+
+		if newEvent.Hex() != event.Hex() {
+			t.Fatalf("%s: hash should be %s, not %s", loc, event.Hex(), newEvent.Hex())
+		}
+		if ok, err := newEvent.Verify(); err != nil || !ok {
+			t.Fatalf("%s: signature should still be valid, got %v, %v", loc, ok, err)
+		}
+
+		event.SetWireInfo(1, 66, 2, 67)
+		wireEvent := event.ToWire()
+		raw, err = codec.Marshal(codec.Protobuf, wireEvent)
+		if err != nil {
+			t.Fatalf("Error marshalling WireEvent: %s", err)
+		}
+		var newWireEvent WireEvent
+		if err := codec.Unmarshal(codec.Protobuf, raw, &newWireEvent); err != nil {
+			t.Fatalf("Error unmarshalling WireEvent: %s", err)
+		}
+		if !newWireEvent.Body.Timestamp.Equal(wireEvent.Body.Timestamp) ||
+			newWireEvent.Body.CreatorID != 67 || newWireEvent.R.Cmp(wireEvent.R) != 0 {
+			t.Fatalf("%s: WireEvent should be %#v, not %#v", loc, wireEvent, newWireEvent)
+		}
+	}
+}
+
 func TestWireEvent(t *testing.T) {
 	privateKey, _ := crypto.GenerateECDSAKey()
 	publicKeyBytes := crypto.FromECDSAPub(&privateKey.PublicKey)
@@ -101,7 +194,7 @@ func TestWireEvent(t *testing.T) {
 	body.Creator = publicKeyBytes
 
 	event := Event{Body: body}
-	if err := event.Sign(privateKey); err != nil {
+	if err := event.Sign(crypto.NewECDSAKeyPair(privateKey)); err != nil {
 		t.Fatalf("Error signing Event: %s", err)
 	}
 
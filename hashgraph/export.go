@@ -0,0 +1,193 @@
+package hashgraph
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/babbleio/babble/common"
+)
+
+/*
+Export copies a range of rounds of the hashgraph into a Graph, to visualize
+consensus progress or to debug a fork: every Event of the rounds with its
+parents, its round, whether it is a witness, the fame decided for it and the
+round in which it reached consensus. A Graph encodes to JSON as is, and
+WriteDOT renders it for GraphViz:
+
+	dot -Tsvg graph.dot > graph.svg
+
+Events are listed in topological order. Their parents are given by hash even
+when they are below the exported rounds, but only the edges between exported
+Events are drawn. A fork shows up as two Events of the same creator with the
+same index.
+*/
+
+//MaxExportRounds is the largest number of rounds that Export copies at once
+const MaxExportRounds = 100
+
+//GraphEvent is an Event of a Graph
+type GraphEvent struct {
+	Hash          string
+	Creator       int //id of the creator, see Graph.Participants
+	Index         int
+	SelfParent    string
+	OtherParent   string
+	Round         int
+	Witness       bool
+	Famous        string //fame of a witness: Undefined, True or False
+	RoundReceived int    //-1 until the Event reaches consensus
+	Transactions  int
+}
+
+//GraphRound is a round of a Graph
+type GraphRound struct {
+	Index     int
+	Witnesses int
+	Decided   bool //the fame of every witness is decided
+}
+
+//Graph is a range of rounds of the hashgraph, see Export
+type Graph struct {
+	From         int
+	To           int
+	Participants map[string]int //[public key] => id
+	Rounds       []GraphRound
+	Events       []GraphEvent
+}
+
+//Export returns the rounds from to to of the hashgraph, at most
+//MaxExportRounds of them. Rounds that the Store does not hold, because they are
+//below its Roots or were not created yet, are left out.
+func (h *Hashgraph) Export(from, to int) (Graph, error) {
+	if from < 0 || to < from {
+		return Graph{}, fmt.Errorf("Invalid range of rounds [%d, %d]", from, to)
+	}
+	if to-from >= MaxExportRounds {
+		return Graph{}, fmt.Errorf("Can not export more than %d rounds", MaxExportRounds)
+	}
+
+	g := Graph{
+		From:         from,
+		To:           to,
+		Participants: make(map[string]int, len(h.Participants)),
+		Rounds:       []GraphRound{},
+		Events:       []GraphEvent{},
+	}
+	for pk, id := range h.Participants {
+		g.Participants[pk] = id
+	}
+
+	events := []Event{}
+	roundEvents := make(map[string]RoundEvent)
+	for r := from; r <= to && r <= h.Store.LastRound(); r++ {
+		round, err := h.Store.GetRound(r)
+		if common.Is(err, common.KeyNotFound) {
+			continue
+		}
+		if err != nil {
+			return Graph{}, err
+		}
+		g.Rounds = append(g.Rounds, GraphRound{
+			Index:     r,
+			Witnesses: len(round.Witnesses()),
+			Decided:   round.WitnessesDecided(),
+		})
+		for x, re := range round.Events {
+			ev, err := h.Store.GetEvent(x)
+			if err != nil {
+				return Graph{}, err
+			}
+			events = append(events, ev)
+			roundEvents[x] = re
+		}
+	}
+	sort.Sort(ByTopologicalOrder(events))
+
+	for _, ev := range events {
+		x := ev.Hex()
+		re := roundEvents[x]
+		g.Events = append(g.Events, GraphEvent{
+			Hash:          x,
+			Creator:       h.Participants[ev.Creator()],
+			Index:         ev.Index(),
+			SelfParent:    ev.SelfParent(),
+			OtherParent:   ev.OtherParent(),
+			Round:         h.Round(x),
+			Witness:       re.Witness,
+			Famous:        re.Famous.String(),
+			RoundReceived: ev.RoundReceived(),
+			Transactions:  len(ev.Transactions()),
+		})
+	}
+	return g, nil
+}
+
+//WriteDOT renders g in the DOT language of GraphViz. Every creator has a
+//column, which a fork makes wider; witnesses are double circles, gold when
+//famous and grey when not, and the Events that reached consensus are green.
+func (g Graph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph hashgraph {\n")
+	fmt.Fprintf(bw, "\tlabel=\"rounds %d to %d\";\n", g.From, g.To)
+	fmt.Fprintf(bw, "\trankdir=BT;\n")
+	fmt.Fprintf(bw, "\tnode [shape=circle, style=filled, fillcolor=white, fontsize=10];\n")
+
+	byCreator := make(map[int][]GraphEvent)
+	exported := make(map[string]bool, len(g.Events))
+	for _, e := range g.Events {
+		byCreator[e.Creator] = append(byCreator[e.Creator], e)
+		exported[e.Hash] = true
+	}
+	names := make(map[int]string, len(g.Participants))
+	for pk, id := range g.Participants {
+		names[id] = pk
+	}
+	creators := []int{}
+	for id := range byCreator {
+		creators = append(creators, id)
+	}
+	sort.Ints(creators)
+
+	for _, id := range creators {
+		fmt.Fprintf(bw, "\tsubgraph cluster_%d {\n", id)
+		fmt.Fprintf(bw, "\t\tlabel=%q;\n\t\tcolor=lightgrey;\n", fmt.Sprintf("%d %s", id, shortKey(names[id])))
+		for _, e := range byCreator[id] {
+			attrs := []string{fmt.Sprintf("label=\"%d.%d\\nr%d\"", e.Creator, e.Index, e.Round)}
+			if e.Witness {
+				attrs = append(attrs, "shape=doublecircle")
+			}
+			switch {
+			case e.Famous == True.String():
+				attrs = append(attrs, "fillcolor=gold")
+			case e.Famous == False.String():
+				attrs = append(attrs, "fillcolor=grey")
+			case e.RoundReceived >= 0:
+				attrs = append(attrs, "fillcolor=palegreen")
+			}
+			fmt.Fprintf(bw, "\t\t%q [%s];\n", e.Hash, strings.Join(attrs, ", "))
+		}
+		fmt.Fprintf(bw, "\t}\n")
+	}
+
+	for _, e := range g.Events {
+		if exported[e.SelfParent] {
+			fmt.Fprintf(bw, "\t%q -> %q;\n", e.SelfParent, e.Hash)
+		}
+		if exported[e.OtherParent] {
+			fmt.Fprintf(bw, "\t%q -> %q [style=dashed, constraint=false];\n", e.OtherParent, e.Hash)
+		}
+	}
+	fmt.Fprintf(bw, "}\n")
+	return bw.Flush()
+}
+
+//shortKey abbreviates a public key in hex for labels
+func shortKey(pk string) string {
+	if len(pk) <= 12 {
+		return pk
+	}
+	return pk[:12] + "..."
+}
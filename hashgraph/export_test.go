@@ -0,0 +1,73 @@
+package hashgraph
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/babbleio/babble/common"
+)
+
+func TestExport(t *testing.T) {
+	h, index := initConsensusHashgraph(common.NewTestLogger(t))
+	h.DivideRounds()
+	h.DecideFame()
+	h.DecideRoundReceived()
+
+	g, err := h.Export(0, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(g.Rounds) != 2 || !g.Rounds[0].Decided || g.Rounds[0].Witnesses != 3 {
+		t.Fatalf("Rounds 0 and 1 should be exported, with the 3 famous witnesses of round 0, got %+v", g.Rounds)
+	}
+	if n := h.Store.RoundEvents(0) + h.Store.RoundEvents(1); len(g.Events) != n {
+		t.Fatalf("The %d Events of rounds 0 and 1 should be exported, got %d", n, len(g.Events))
+	}
+
+	events := make(map[string]GraphEvent)
+	pos := make(map[string]int)
+	for i, e := range g.Events {
+		events[e.Hash] = e
+		pos[e.Hash] = i
+	}
+	e0 := events[index["e0"]]
+	if !e0.Witness || e0.Famous != "True" || e0.RoundReceived != 1 || e0.Round != 0 {
+		t.Fatalf("e0 should be a famous witness of round 0 received in round 1, got %+v", e0)
+	}
+	if f1 := events[index["f1"]]; f1.Round != 1 || f1.Creator != 1 || f1.Index != 2 || f1.SelfParent != index["e10"] {
+		t.Fatalf("f1 should be Event 2 of participant 1 in round 1, got %+v", f1)
+	}
+	if _, ok := events[index["g1"]]; ok {
+		t.Fatal("g1 is in round 2, it should not be exported")
+	}
+	if pos[index["e21"]] > pos[index["e21b"]] || pos[index["e10"]] > pos[index["e21"]] {
+		t.Fatal("Events should be in topological order")
+	}
+
+	var dot bytes.Buffer
+	if err := g.WriteDOT(&dot); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"digraph hashgraph {",
+		fmt.Sprintf("%q -> %q;", index["e0"], index["e02"]),
+		fmt.Sprintf("%q -> %q [style=dashed", index["e21b"], index["e02"]),
+		"shape=doublecircle",
+	} {
+		if !strings.Contains(dot.String(), want) {
+			t.Fatalf("The DOT graph should contain %s, got\n%s", want, dot.String())
+		}
+	}
+
+	if g, err := h.Export(2, 50); err != nil || g.Rounds[len(g.Rounds)-1].Index != h.Store.LastRound() {
+		t.Fatalf("The export should stop at the last round, got %+v (%v)", g.Rounds, err)
+	}
+	if _, err := h.Export(1, 0); err == nil {
+		t.Fatal("An empty range should fail")
+	}
+	if _, err := h.Export(0, MaxExportRounds); err == nil {
+		t.Fatalf("More than %d rounds should fail", MaxExportRounds)
+	}
+}
@@ -1,6 +1,8 @@
 package hashgraph
 
 type Frame struct {
-	Roots  map[string]Root
-	Events []Event
+	Roots        map[string]Root
+	Events       []Event
+	Participants []Participant
+	Proposals    []Proposal
 }
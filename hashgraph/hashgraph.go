@@ -10,6 +10,7 @@ import (
 	"github.com/Sirupsen/logrus"
 
 	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/hashgraph/ordering"
 )
 
 type Hashgraph struct {
@@ -25,6 +26,13 @@ type Hashgraph struct {
 	commitCh                chan []Event   //channel for committing events
 	topologicalIndex        int            //counter used to order events in topological order
 	superMajority           int
+	joinRounds              map[string]int //[public key] => first round of participants added at runtime
+	leaveRounds             map[string]int //[public key] => first round without participants removed at runtime
+	schemes                 SchemeWindow   //versions of the Events accepted by InsertEvent
+
+	//participants that proposed to add or evict a participant, until a
+	//super-majority of them did, see applyMembership
+	proposals map[proposalKey]map[string]bool //=> proposers
 
 	ancestorCache           *common.LRU
 	selfAncestorCache       *common.LRU
@@ -32,6 +40,12 @@ type Hashgraph struct {
 	stronglySeeCache        *common.LRU
 	parentRoundCache        *common.LRU
 	roundCache              *common.LRU
+	famousWitnessesCache    *common.LRU
+
+	//votes cast on the fame of undecided witnesses. Votes only depend on the
+	//ancestry of the voter so they are kept across calls to DecideFame and
+	//dropped once the witness is decided.
+	fameVotes map[string]map[string]bool //[x][y] => vote(y,x)
 
 	logger *logrus.Logger
 }
@@ -59,12 +73,22 @@ func NewHashgraph(participants map[string]int, store Store, commitCh chan []Even
 		stronglySeeCache:        common.NewLRU(cacheSize, nil),
 		parentRoundCache:        common.NewLRU(cacheSize, nil),
 		roundCache:              common.NewLRU(cacheSize, nil),
+		famousWitnessesCache:    common.NewLRU(cacheSize, nil),
+		fameVotes:               make(map[string]map[string]bool),
+		joinRounds:              make(map[string]int),
+		leaveRounds:             make(map[string]int),
+		proposals:               make(map[proposalKey]map[string]bool),
 		logger:                  logger,
 		superMajority:           2*len(participants)/3 + 1,
 		UndecidedRounds:         []int{0}, //initialize
 	}
 }
 
+//SetSchemeWindow sets the versions of the Events accepted by InsertEvent
+func (h *Hashgraph) SetSchemeWindow(w SchemeWindow) {
+	h.schemes = w
+}
+
 func (h *Hashgraph) SuperMajority() int {
 	return h.superMajority
 }
@@ -95,7 +119,7 @@ func (h *Hashgraph) ancestor(x, y string) bool {
 	}
 
 	eyCreator := h.Participants[ey.Creator()]
-	lastAncestorKnownFromYCreator := ex.lastAncestors[eyCreator].index
+	lastAncestorKnownFromYCreator := lastAncestor(ex, eyCreator).index
 
 	return lastAncestorKnownFromYCreator >= ey.Index()
 }
@@ -138,32 +162,36 @@ func (h *Hashgraph) See(x, y string) bool {
 }
 
 //oldest self-ancestor of x to see y
-func (h *Hashgraph) OldestSelfAncestorToSee(x, y string) string {
+func (h *Hashgraph) OldestSelfAncestorToSee(x, y string) (string, error) {
 	if c, ok := h.oldestSelfAncestorCache.Get(Key{x, y}); ok {
-		return c.(string)
+		return c.(string), nil
+	}
+	res, err := h.oldestSelfAncestorToSee(x, y)
+	if err != nil {
+		return "", err
 	}
-	res := h.oldestSelfAncestorToSee(x, y)
 	h.oldestSelfAncestorCache.Add(Key{x, y}, res)
-	return res
+	return res, nil
 }
 
-func (h *Hashgraph) oldestSelfAncestorToSee(x, y string) string {
+func (h *Hashgraph) oldestSelfAncestorToSee(x, y string) (string, error) {
 	ex, err := h.Store.GetEvent(x)
 	if err != nil {
-		return ""
+		return "", err
 	}
 	ey, err := h.Store.GetEvent(y)
 	if err != nil {
-		return ""
+		return "", err
 	}
 
-	a := ey.firstDescendants[h.Participants[ex.Creator()]]
+	id := h.Participants[ex.Creator()]
+	a := firstDescendant(ey, id)
 
 	if a.index <= ex.Index() {
-		return a.hash
+		return h.eventAt(id, a.index)
 	}
 
-	return ""
+	return "", nil
 }
 
 //true if x strongly sees y
@@ -188,13 +216,21 @@ func (h *Hashgraph) stronglySee(x, y string) bool {
 		return false
 	}
 
+	//rounds only matter once participants were added at runtime
+	round := -1
+	if h.membershipChanged() {
+		round = h.Round(y)
+	}
 	c := 0
-	for i := 0; i < len(ex.lastAncestors); i++ {
-		if ex.lastAncestors[i].index >= ey.firstDescendants[i].index {
+	for pk, i := range h.Participants {
+		if !h.counts(pk, round) {
+			continue
+		}
+		if lastAncestor(ex, i).index >= firstDescendant(ey, i).index {
 			c++
 		}
 	}
-	return c >= h.SuperMajority()
+	return c >= h.superMajorityAt(round)
 }
 
 //round: max of parent rounds
@@ -295,13 +331,13 @@ func (h *Hashgraph) RoundInc(x string) bool {
 	//If parent-round was obtained from a regulare Event, then we need to check
 	//if x strongly-sees a strong majority of withnesses from parent-round.
 	c := 0
-	for _, w := range h.Store.RoundWitnesses(parentRound.round) {
+	for _, w := range h.roundWitnesses(parentRound.round) {
 		if h.StronglySee(x, w) {
 			c++
 		}
 	}
 
-	return c >= h.SuperMajority()
+	return c >= h.superMajorityAt(parentRound.round)
 }
 
 func (h *Hashgraph) RoundReceived(x string) int {
@@ -310,11 +346,7 @@ func (h *Hashgraph) RoundReceived(x string) int {
 	if err != nil {
 		return -1
 	}
-	if ex.roundReceived == nil {
-		return -1
-	}
-
-	return *ex.roundReceived
+	return ex.RoundReceived()
 }
 
 func (h *Hashgraph) Round(x string) int {
@@ -354,12 +386,18 @@ func (h *Hashgraph) RoundDiff(x, y string) (int, error) {
 }
 
 func (h *Hashgraph) InsertEvent(event Event, setWireInfo bool) error {
-	//verify signature
-	if ok, err := event.Verify(); !ok {
-		if err != nil {
-			return err
+	if err := h.schemes.Check(event.Body.Version); err != nil {
+		return err
+	}
+
+	//verify signature, unless VerifyEvents did
+	if !event.verified {
+		if ok, err := event.Verify(); !ok {
+			if err != nil {
+				return err
+			}
+			return fmt.Errorf("Invalid signature")
 		}
-		return fmt.Errorf("Invalid signature")
 	}
 
 	if err := h.CheckSelfParent(event); err != nil {
@@ -467,18 +505,18 @@ func (h *Hashgraph) InitEventCoordinates(event *Event) error {
 			}
 		}
 	} else if selfParentError != nil {
-		copy(event.lastAncestors[:members], otherParent.lastAncestors)
+		for i := 0; i < members; i++ {
+			event.lastAncestors[i] = lastAncestor(otherParent, i)
+		}
 	} else if otherParentError != nil {
-		copy(event.lastAncestors[:members], selfParent.lastAncestors)
+		for i := 0; i < members; i++ {
+			event.lastAncestors[i] = lastAncestor(selfParent, i)
+		}
 	} else {
-		selfParentLastAncestors := selfParent.lastAncestors
-		otherParentLastAncestors := otherParent.lastAncestors
-
-		copy(event.lastAncestors[:members], selfParentLastAncestors)
 		for i := 0; i < members; i++ {
-			if event.lastAncestors[i].index < otherParentLastAncestors[i].index {
-				event.lastAncestors[i].index = otherParentLastAncestors[i].index
-				event.lastAncestors[i].hash = otherParentLastAncestors[i].hash
+			event.lastAncestors[i] = lastAncestor(selfParent, i)
+			if op := lastAncestor(otherParent, i); event.lastAncestors[i].index < op.index {
+				event.lastAncestors[i] = op
 			}
 		}
 	}
@@ -490,10 +528,9 @@ func (h *Hashgraph) InitEventCoordinates(event *Event) error {
 	if !ok {
 		return fmt.Errorf("Could not find fake creator id")
 	}
-	hash := event.Hex()
 
-	event.firstDescendants[fakeCreatorID] = EventCoordinates{index: index, hash: hash}
-	event.lastAncestors[fakeCreatorID] = EventCoordinates{index: index, hash: hash}
+	event.firstDescendants[fakeCreatorID] = EventCoordinates{index: index}
+	event.lastAncestors[fakeCreatorID] = EventCoordinates{index: index}
 
 	return nil
 }
@@ -505,17 +542,23 @@ func (h *Hashgraph) UpdateAncestorFirstDescendant(event Event) error {
 		return fmt.Errorf("Could not find creator fake id (%s)", event.Creator())
 	}
 	index := event.Index()
-	hash := event.Hex()
 
 	for i := 0; i < len(event.lastAncestors); i++ {
-		ah := event.lastAncestors[i].hash
+		ah, err := h.eventAt(i, event.lastAncestors[i].index)
+		if err != nil {
+			return err
+		}
 		for ah != "" {
 			a, err := h.Store.GetEvent(ah)
 			if err != nil {
 				break
 			}
-			if a.firstDescendants[fakeCreatorID].index == math.MaxInt64 {
-				a.firstDescendants[fakeCreatorID] = EventCoordinates{index: index, hash: hash}
+			if firstDescendant(a, fakeCreatorID).index == math.MaxInt64 {
+				//a was inserted before the creator of event joined
+				for len(a.firstDescendants) <= fakeCreatorID {
+					a.firstDescendants = append(a.firstDescendants, EventCoordinates{index: math.MaxInt64})
+				}
+				a.firstDescendants[fakeCreatorID] = EventCoordinates{index: index}
 				if err := h.Store.SetEvent(a); err != nil {
 					return err
 				}
@@ -567,25 +610,40 @@ func (h *Hashgraph) SetWireInfo(event *Event) error {
 }
 
 func (h *Hashgraph) ReadWireInfo(wevent WireEvent) (*Event, error) {
+	return h.readWireInfo(wevent, nil)
+}
+
+//readWireInfo looks the parents of wevent up in batch, which holds the hashes of
+//Events that are not in the Store yet, and then in the Store
+func (h *Hashgraph) readWireInfo(wevent WireEvent, batch map[WireRef]string) (*Event, error) {
 	selfParent := ""
 	otherParent := ""
 	var err error
 
-	creator := h.ReverseParticipants[wevent.Body.CreatorID]
+	creator, ok := h.ReverseParticipants[wevent.Body.CreatorID]
+	if !ok {
+		return nil, fmt.Errorf("Unknown participant %d", wevent.Body.CreatorID)
+	}
 	creatorBytes, err := hex.DecodeString(creator[2:])
 	if err != nil {
 		return nil, err
 	}
 
+	parent := func(creatorID int, creator string, index int) (string, error) {
+		if hash, ok := batch[WireRef{CreatorID: creatorID, Index: index}]; ok {
+			return hash, nil
+		}
+		return h.Store.ParticipantEvent(creator, index)
+	}
 	if wevent.Body.SelfParentIndex >= 0 {
-		selfParent, err = h.Store.ParticipantEvent(creator, wevent.Body.SelfParentIndex)
+		selfParent, err = parent(wevent.Body.CreatorID, creator, wevent.Body.SelfParentIndex)
 		if err != nil {
 			return nil, err
 		}
 	}
 	if wevent.Body.OtherParentIndex >= 0 {
 		otherParentCreator := h.ReverseParticipants[wevent.Body.OtherParentCreatorID]
-		otherParent, err = h.Store.ParticipantEvent(otherParentCreator, wevent.Body.OtherParentIndex)
+		otherParent, err = parent(wevent.Body.OtherParentCreatorID, otherParentCreator, wevent.Body.OtherParentIndex)
 		if err != nil {
 			return nil, err
 		}
@@ -598,6 +656,7 @@ func (h *Hashgraph) ReadWireInfo(wevent WireEvent) (*Event, error) {
 
 		Timestamp:            wevent.Body.Timestamp,
 		Index:                wevent.Body.Index,
+		Version:              wevent.Body.Version,
 		selfParentIndex:      wevent.Body.SelfParentIndex,
 		otherParentCreatorID: wevent.Body.OtherParentCreatorID,
 		otherParentIndex:     wevent.Body.OtherParentIndex,
@@ -637,7 +696,7 @@ func (h *Hashgraph) DivideRounds() error {
 
 //decide if witnesses are famous
 func (h *Hashgraph) DecideFame() error {
-	votes := make(map[string](map[string]bool)) //[x][y]=>vote(x,y)
+	votes := h.fameVotes
 
 	decidedRounds := map[int]int{} // [round number] => index in h.UndefinedRounds
 	defer h.updateUndecidedRounds(decidedRounds)
@@ -651,16 +710,25 @@ func (h *Hashgraph) DecideFame() error {
 			if roundInfo.IsDecided(x) {
 				continue
 			}
+			//witnesses of participants that do not count yet are not famous
+			if !h.counts(h.creator(x), i) {
+				roundInfo.SetFame(x, false)
+				continue
+			}
 		X:
 			for j := i + 1; j <= h.Store.LastRound(); j++ {
-				for _, y := range h.Store.RoundWitnesses(j) {
+				for _, y := range h.roundWitnesses(j) {
+					//y already voted in a previous pass without deciding x
+					if _, ok := votes[x][y]; ok {
+						continue
+					}
 					diff := j - i
 					if diff == 1 {
-						setVote(votes, y, x, h.See(y, x))
+						setVote(votes, x, y, h.See(y, x))
 					} else {
 						//count votes
 						ssWitnesses := []string{}
-						for _, w := range h.Store.RoundWitnesses(j - 1) {
+						for _, w := range h.roundWitnesses(j - 1) {
 							if h.StronglySee(y, w) {
 								ssWitnesses = append(ssWitnesses, w)
 							}
@@ -668,7 +736,7 @@ func (h *Hashgraph) DecideFame() error {
 						yays := 0
 						nays := 0
 						for _, w := range ssWitnesses {
-							if votes[w][x] {
+							if votes[x][w] {
 								yays++
 							} else {
 								nays++
@@ -682,24 +750,28 @@ func (h *Hashgraph) DecideFame() error {
 						}
 
 						//normal round
-						if math.Mod(float64(diff), float64(len(h.Participants))) > 0 {
-							if t >= h.SuperMajority() {
+						if math.Mod(float64(diff), float64(h.participantsAt(i))) > 0 {
+							if t >= h.superMajorityAt(j-1) {
 								roundInfo.SetFame(x, v)
-								setVote(votes, y, x, v)
 								break X //break out of j loop
 							} else {
-								setVote(votes, y, x, v)
+								setVote(votes, x, y, v)
 							}
 						} else { //coin round
-							if t >= h.SuperMajority() {
-								setVote(votes, y, x, v)
+							if t >= h.superMajorityAt(j-1) {
+								setVote(votes, x, y, v)
 							} else {
-								setVote(votes, y, x, middleBit(y)) //middle bit of y's hash
+								setVote(votes, x, y, middleBit(y)) //middle bit of y's hash
 							}
 						}
 					}
 				}
 			}
+
+			//the votes on a decided witness are not needed anymore
+			if roundInfo.IsDecided(x) {
+				delete(votes, x)
+			}
 		}
 
 		//Update decidedRounds and LastConsensusRound if all witnesses have been decided
@@ -749,12 +821,15 @@ func (h *Hashgraph) DecideRoundReceived() error {
 				return err
 			}
 
-			//skip if some witnesses are left undecided
-			if !(tr.WitnessesDecided() && h.UndecidedRounds[0] > i) {
+			//skip if some witnesses are left undecided. UndecidedRounds can be
+			//empty when the last rounds only have witnesses of participants
+			//that were removed.
+			undecided := len(h.UndecidedRounds) > 0 && h.UndecidedRounds[0] <= i
+			if !tr.WitnessesDecided() || undecided {
 				continue
 			}
 
-			fws := tr.FamousWitnesses()
+			fws := h.roundFamousWitnesses(i, tr)
 			//set of famous witnesses that see x
 			s := []string{}
 			for _, w := range fws {
@@ -771,7 +846,11 @@ func (h *Hashgraph) DecideRoundReceived() error {
 
 				t := []string{}
 				for _, a := range s {
-					t = append(t, h.OldestSelfAncestorToSee(a, x))
+					osa, err := h.OldestSelfAncestorToSee(a, x)
+					if err != nil {
+						return err
+					}
+					t = append(t, osa)
 				}
 
 				ex.consensusTimestamp = h.MedianTimestamp(t)
@@ -788,6 +867,19 @@ func (h *Hashgraph) DecideRoundReceived() error {
 	return nil
 }
 
+//famous witnesses of a decided round. The result is cached because it is
+//requested for every undetermined event.
+func (h *Hashgraph) roundFamousWitnesses(r int, roundInfo RoundInfo) []string {
+	if c, ok := h.famousWitnessesCache.Get(r); ok {
+		return c.([]string)
+	}
+	fws := roundInfo.FamousWitnesses()
+	if roundInfo.WitnessesDecided() {
+		h.famousWitnessesCache.Add(r, fws)
+	}
+	return fws
+}
+
 func (h *Hashgraph) FindOrder() error {
 	err := h.DecideRoundReceived()
 	if err != nil {
@@ -801,7 +893,7 @@ func (h *Hashgraph) FindOrder() error {
 		if err != nil {
 			return err
 		}
-		if ex.roundReceived != nil {
+		if ex.received {
 			newConsensusEvents = append(newConsensusEvents, ex)
 		} else {
 			newUndeterminedEvents = append(newUndeterminedEvents, x)
@@ -821,6 +913,9 @@ func (h *Hashgraph) FindOrder() error {
 		if e.IsLoaded() {
 			h.PendingLoadedEvents--
 		}
+		if err := h.applyMembership(e); err != nil {
+			return err
+		}
 	}
 
 	if h.commitCh != nil && len(newConsensusEvents) > 0 {
@@ -831,13 +926,25 @@ func (h *Hashgraph) FindOrder() error {
 }
 
 func (h *Hashgraph) MedianTimestamp(eventHashes []string) time.Time {
-	events := []Event{}
+	timestamps := []time.Time{}
 	for _, x := range eventHashes {
 		ex, _ := h.Store.GetEvent(x)
-		events = append(events, ex)
+		timestamps = append(timestamps, ex.Body.Timestamp)
+	}
+	return ordering.MedianTimestamp(timestamps)
+}
+
+//CacheStats returns the usage of the caches of the consensus methods
+func (h *Hashgraph) CacheStats() map[string]common.LRUStats {
+	return map[string]common.LRUStats{
+		"ancestor":         h.ancestorCache.Stats(),
+		"self_ancestor":    h.selfAncestorCache.Stats(),
+		"oldest_self":      h.oldestSelfAncestorCache.Stats(),
+		"strongly_see":     h.stronglySeeCache.Stats(),
+		"parent_round":     h.parentRoundCache.Stats(),
+		"round":            h.roundCache.Stats(),
+		"famous_witnesses": h.famousWitnessesCache.Stats(),
 	}
-	sort.Sort(ByTimestamp(events))
-	return events[len(events)/2].Body.Timestamp
 }
 
 func (h *Hashgraph) ConsensusEvents() []string {
@@ -849,6 +956,10 @@ func (h *Hashgraph) Known() map[int]int {
 	return h.Store.Known()
 }
 
+func (h *Hashgraph) KnownRanges() KnownRanges {
+	return h.Store.KnownRanges()
+}
+
 func (h *Hashgraph) Reset(roots map[string]Root) error {
 	if err := h.Store.Reset(roots); err != nil {
 		return err
@@ -859,6 +970,89 @@ func (h *Hashgraph) Reset(roots map[string]Root) error {
 	h.PendingLoadedEvents = 0
 	h.topologicalIndex = 0
 
+	h.ResetCaches()
+
+	return nil
+}
+
+//Bootstrap rebuilds the hashgraph from the Events of a BadgerStore opened with
+//LoadBadgerStore. The Events are inserted again in topological order and
+//consensus is run on them. It returns the Events that reach consensus, which
+//were already committed before the restart. The database is not written to
+//during the replay.
+func (h *Hashgraph) Bootstrap() ([]Event, error) {
+	badgerStore, ok := h.Store.(*BadgerStore)
+	if !ok {
+		return nil, nil
+	}
+
+	events, err := badgerStore.dbTopologicalEvents()
+	if err != nil || len(events) == 0 {
+		return nil, err
+	}
+
+	h.Store = badgerStore.inmemStore
+	defer func() { h.Store = badgerStore }()
+
+	//a store that was fast-forwarded starts from its Roots
+	roots := make(map[string]Root)
+	fastForwarded := false
+	for pk := range badgerStore.participants {
+		root, err := h.Store.GetRoot(pk)
+		if err != nil {
+			return nil, err
+		}
+		roots[pk] = root
+		fastForwarded = fastForwarded || root.X != ""
+	}
+	if fastForwarded {
+		if err := h.Reset(roots); err != nil {
+			return nil, err
+		}
+	}
+
+	commitCh := h.commitCh
+	replayCh := make(chan []Event)
+	committed := []Event{}
+	done := make(chan struct{})
+	go func() {
+		for evs := range replayCh {
+			committed = append(committed, evs...)
+		}
+		close(done)
+	}()
+	h.commitCh = replayCh
+	defer func() { h.commitCh = commitCh }()
+
+	//the Events were accepted before, maybe with an older scheme window
+	schemes := h.schemes
+	h.schemes = SchemeWindow{}
+	defer func() { h.schemes = schemes }()
+
+	for _, e := range events {
+		if err := h.InsertEvent(e, false); err != nil {
+			close(replayCh)
+			return nil, fmt.Errorf("Replaying Event %s: %s", e.Hex(), err)
+		}
+	}
+	err = h.DivideRounds()
+	if err == nil {
+		err = h.DecideFame()
+	}
+	if err == nil {
+		err = h.FindOrder()
+	}
+	close(replayCh)
+	<-done
+	if err != nil {
+		return nil, err
+	}
+	return committed, nil
+}
+
+//ResetCaches drops all the memoized results of the consensus methods. They
+//are recomputed from the Store on demand.
+func (h *Hashgraph) ResetCaches() {
 	cacheSize := h.Store.CacheSize()
 	h.ancestorCache = common.NewLRU(cacheSize, nil)
 	h.selfAncestorCache = common.NewLRU(cacheSize, nil)
@@ -866,8 +1060,29 @@ func (h *Hashgraph) Reset(roots map[string]Root) error {
 	h.stronglySeeCache = common.NewLRU(cacheSize, nil)
 	h.parentRoundCache = common.NewLRU(cacheSize, nil)
 	h.roundCache = common.NewLRU(cacheSize, nil)
+	h.famousWitnessesCache = common.NewLRU(cacheSize, nil)
+	h.fameVotes = make(map[string]map[string]bool)
+}
 
-	return nil
+//ResetUndecidedRounds rebuilds the queue of undecided rounds from the round
+//following LastConsensusRound and drops the cached fame votes, so that the
+//next call to DecideFame votes again on every witness that is not decided.
+func (h *Hashgraph) ResetUndecidedRounds() {
+	from := 0
+	if h.LastConsensusRound != nil {
+		from = *h.LastConsensusRound + 1
+	}
+
+	undecided := []int{}
+	for r := from; r <= h.Store.LastRound(); r++ {
+		if _, err := h.Store.GetRound(r); err == nil {
+			undecided = append(undecided, r)
+		}
+	}
+	if len(undecided) > 0 {
+		h.UndecidedRounds = undecided
+	}
+	h.fameVotes = make(map[string]map[string]bool)
 }
 
 func (h *Hashgraph) GetFrame() (Frame, error) {
@@ -967,8 +1182,10 @@ func (h *Hashgraph) GetFrame() (Frame, error) {
 	}
 
 	frame := Frame{
-		Roots:  roots,
-		Events: events,
+		Roots:        roots,
+		Events:       events,
+		Participants: h.GetParticipants(),
+		Proposals:    h.GetProposals(),
 	}
 
 	return frame, nil
@@ -14,6 +14,7 @@ import (
 	"reflect"
 
 	"math"
+	"math/big"
 
 	"github.com/babbleio/babble/common"
 	"github.com/babbleio/babble/crypto"
@@ -28,7 +29,7 @@ type Node struct {
 	ID     int
 	Pub    []byte
 	PubHex string
-	Key    *ecdsa.PrivateKey
+	Key    crypto.KeyPair
 	Events []Event
 }
 
@@ -36,7 +37,7 @@ func NewNode(key *ecdsa.PrivateKey, id int) Node {
 	pub := crypto.FromECDSAPub(&key.PublicKey)
 	node := Node{
 		ID:     id,
-		Key:    key,
+		Key:    crypto.NewECDSAKeyPair(key),
 		Pub:    pub,
 		PubHex: fmt.Sprintf("0x%X", pub),
 		Events: []Event{},
@@ -443,20 +444,16 @@ func TestInsertEvent(t *testing.T) {
 
 	expectedFirstDescendants[0] = EventCoordinates{
 		index: 0,
-		hash:  index["e0"],
 	}
 	expectedFirstDescendants[1] = EventCoordinates{
 		index: 1,
-		hash:  index["e10"],
 	}
 	expectedFirstDescendants[2] = EventCoordinates{
 		index: 2,
-		hash:  index["e21"],
 	}
 
 	expectedLastAncestors[0] = EventCoordinates{
 		index: 0,
-		hash:  index["e0"],
 	}
 	expectedLastAncestors[1] = EventCoordinates{
 		index: -1,
@@ -471,6 +468,10 @@ func TestInsertEvent(t *testing.T) {
 	if !reflect.DeepEqual(e0.lastAncestors, expectedLastAncestors) {
 		t.Fatal("e0 lastAncestors not good")
 	}
+	checkCoordinates(t, &h, index, "e0 firstDescendants", e0.firstDescendants,
+		[]string{"e0", "e10", "e21"})
+	checkCoordinates(t, &h, index, "e0 lastAncestors", e0.lastAncestors,
+		[]string{"e0", "", ""})
 
 	//e21
 	e21, err := h.Store.GetEvent(index["e21"])
@@ -492,28 +493,22 @@ func TestInsertEvent(t *testing.T) {
 
 	expectedFirstDescendants[0] = EventCoordinates{
 		index: 2,
-		hash:  index["e02"],
 	}
 	expectedFirstDescendants[1] = EventCoordinates{
 		index: 3,
-		hash:  index["f1"],
 	}
 	expectedFirstDescendants[2] = EventCoordinates{
 		index: 2,
-		hash:  index["e21"],
 	}
 
 	expectedLastAncestors[0] = EventCoordinates{
 		index: 0,
-		hash:  index["e0"],
 	}
 	expectedLastAncestors[1] = EventCoordinates{
 		index: 1,
-		hash:  index["e10"],
 	}
 	expectedLastAncestors[2] = EventCoordinates{
 		index: 2,
-		hash:  index["e21"],
 	}
 
 	if !reflect.DeepEqual(e21.firstDescendants, expectedFirstDescendants) {
@@ -522,6 +517,10 @@ func TestInsertEvent(t *testing.T) {
 	if !reflect.DeepEqual(e21.lastAncestors, expectedLastAncestors) {
 		t.Fatal("e21 lastAncestors not good")
 	}
+	checkCoordinates(t, &h, index, "e21 firstDescendants", e21.firstDescendants,
+		[]string{"e02", "f1", "e21"})
+	checkCoordinates(t, &h, index, "e21 lastAncestors", e21.lastAncestors,
+		[]string{"e0", "e10", "e21"})
 
 	//f1
 	f1, err := h.Store.GetEvent(index["f1"])
@@ -541,7 +540,6 @@ func TestInsertEvent(t *testing.T) {
 	}
 	expectedFirstDescendants[1] = EventCoordinates{
 		index: 3,
-		hash:  index["f1"],
 	}
 	expectedFirstDescendants[2] = EventCoordinates{
 		index: math.MaxInt64,
@@ -549,15 +547,12 @@ func TestInsertEvent(t *testing.T) {
 
 	expectedLastAncestors[0] = EventCoordinates{
 		index: 2,
-		hash:  index["e02"],
 	}
 	expectedLastAncestors[1] = EventCoordinates{
 		index: 3,
-		hash:  index["f1"],
 	}
 	expectedLastAncestors[2] = EventCoordinates{
 		index: 2,
-		hash:  index["e21"],
 	}
 
 	if !reflect.DeepEqual(f1.firstDescendants, expectedFirstDescendants) {
@@ -566,6 +561,10 @@ func TestInsertEvent(t *testing.T) {
 	if !reflect.DeepEqual(f1.lastAncestors, expectedLastAncestors) {
 		t.Fatal("f1 lastAncestors not good")
 	}
+	checkCoordinates(t, &h, index, "f1 firstDescendants", f1.firstDescendants,
+		[]string{"", "f1", ""})
+	checkCoordinates(t, &h, index, "f1 lastAncestors", f1.lastAncestors,
+		[]string{"e02", "f1", "e21"})
 
 	//Pending loaded Events
 	if ple := h.PendingLoadedEvents; ple != 4 {
@@ -574,6 +573,20 @@ func TestInsertEvent(t *testing.T) {
 
 }
 
+//checkCoordinates checks that coordinates point to the Events called expected,
+//"" for none
+func checkCoordinates(t *testing.T, h *Hashgraph, index map[string]string, name string, coordinates []EventCoordinates, expected []string) {
+	for id, c := range coordinates {
+		hash, err := h.eventAt(id, c.index)
+		if err != nil {
+			t.Fatalf("%s[%d]: %v", name, id, err)
+		}
+		if hash != index[expected[id]] {
+			t.Fatalf("%s[%d] should be %s, not %s", name, id, expected[id], getName(index, hash))
+		}
+	}
+}
+
 func TestReadWireInfo(t *testing.T) {
 	h, index := initRoundHashgraph(t)
 
@@ -996,27 +1009,84 @@ func TestDecideFame(t *testing.T) {
 	}
 }
 
+func TestDecideFameIncremental(t *testing.T) {
+	h, index := initConsensusHashgraph(common.NewTestLogger(t))
+
+	h.DivideRounds()
+	h.DecideFame()
+
+	events := []Event{}
+	for _, hash := range index {
+		ev, err := h.Store.GetEvent(hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, ev)
+	}
+	sort.Sort(ByTopologicalOrder(events))
+
+	//insert the same events in two batches and decide fame after each batch
+	h2 := NewHashgraph(h.Participants, NewInmemStore(h.Participants, cacheSize), nil, common.NewTestLogger(t))
+	half := len(events) / 2
+	for _, batch := range [][]Event{events[:half], events[half:]} {
+		for _, ev := range batch {
+			if err := h2.InsertEvent(ev, true); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := h2.DivideRounds(); err != nil {
+			t.Fatal(err)
+		}
+		if err := h2.DecideFame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for r := 0; r <= h.Store.LastRound(); r++ {
+		expected, _ := h.Store.GetRound(r)
+		got, _ := h2.Store.GetRound(r)
+		if !reflect.DeepEqual(expected.Events, got.Events) {
+			t.Fatalf("Round %d should be %v, not %v", r, expected.Events, got.Events)
+		}
+		if got.WitnessesDecided() {
+			for _, w := range got.Witnesses() {
+				if _, ok := h2.fameVotes[w]; ok {
+					t.Fatalf("Votes on decided witness %s should be dropped", getName(index, w))
+				}
+			}
+		}
+	}
+}
+
 func TestOldestSelfAncestorToSee(t *testing.T) {
 	h, index := initConsensusHashgraph(common.NewTestLogger(t))
 
-	if a := h.OldestSelfAncestorToSee(index["f0"], index["e1"]); a != index["e02"] {
+	if a, err := h.OldestSelfAncestorToSee(index["f0"], index["e1"]); err != nil || a != index["e02"] {
 		t.Fatalf("oldest self ancestor of f0 to see e1 should be e02 not %s", getName(index, a))
 	}
-	if a := h.OldestSelfAncestorToSee(index["f1"], index["e0"]); a != index["e10"] {
+	if a, err := h.OldestSelfAncestorToSee(index["f1"], index["e0"]); err != nil || a != index["e10"] {
 		t.Fatalf("oldest self ancestor of f1 to see e0 should be e10 not %s", getName(index, a))
 	}
-	if a := h.OldestSelfAncestorToSee(index["f1b"], index["e0"]); a != index["e10"] {
+	if a, err := h.OldestSelfAncestorToSee(index["f1b"], index["e0"]); err != nil || a != index["e10"] {
 		t.Fatalf("oldest self ancestor of f1b to see e0 should be e10 not %s", getName(index, a))
 	}
-	if a := h.OldestSelfAncestorToSee(index["g2"], index["f1"]); a != index["f2"] {
+	if a, err := h.OldestSelfAncestorToSee(index["g2"], index["f1"]); err != nil || a != index["f2"] {
 		t.Fatalf("oldest self ancestor of g2 to see f1 should be f2 not %s", getName(index, a))
 	}
-	if a := h.OldestSelfAncestorToSee(index["e21"], index["e1"]); a != index["e21"] {
+	if a, err := h.OldestSelfAncestorToSee(index["e21"], index["e1"]); err != nil || a != index["e21"] {
 		t.Fatalf("oldest self ancestor of e20 to see e1 should be e21 not %s", getName(index, a))
 	}
-	if a := h.OldestSelfAncestorToSee(index["e2"], index["e1"]); a != "" {
+	if a, err := h.OldestSelfAncestorToSee(index["e2"], index["e1"]); err != nil || a != "" {
 		t.Fatalf("oldest self ancestor of e2 to see e1 should be '' not %s", getName(index, a))
 	}
+
+	//coordinates that the Store can not resolve are errors, not missing Events
+	if _, err := h.eventAt(0, 1000); err == nil {
+		t.Fatal("eventAt should fail for an Event the Store does not have")
+	}
+	if _, err := h.eventAt(len(h.Participants), 0); err == nil {
+		t.Fatal("eventAt should fail for an unknown participant")
+	}
 }
 
 func TestDecideRoundReceived(t *testing.T) {
@@ -1029,7 +1099,7 @@ func TestDecideRoundReceived(t *testing.T) {
 	for name, hash := range index {
 		e, _ := h.Store.GetEvent(hash)
 		if rune(name[0]) == rune('e') {
-			if r := *e.roundReceived; r != 1 {
+			if r := e.RoundReceived(); r != 1 {
 				t.Fatalf("%s round received should be 1 not %d", name, r)
 			}
 		}
@@ -1477,3 +1547,23 @@ func disp(index map[string]string, events []string) string {
 	}
 	return fmt.Sprintf("[%s]", strings.Join(names, " "))
 }
+
+//The consensus order whitens signatures with 0: Events that tie on round
+//received and timestamp are sorted by the S of their signature as it is
+func TestConsensusSorterWhitening(t *testing.T) {
+	events := []Event{}
+	for _, s := range []int64{0xF0, 0x0F, 0xFF} {
+		e := NewEvent(nil, []string{"", ""}, []byte("creator"), int(s))
+		e.SetRoundReceived(3)
+		e.S = big.NewInt(s)
+		events = append(events, e)
+	}
+	sorter := NewConsensusSorter(events)
+	sort.Sort(sorter)
+
+	for i, s := range []int64{0x0F, 0xF0, 0xFF} {
+		if events[i].S.Int64() != s {
+			t.Fatalf("Event %d should have S %X, not %X", i, s, events[i].S)
+		}
+	}
+}
@@ -89,8 +89,20 @@ func (s *InmemStore) addParticpantEvent(participant string, hash string, index i
 	return s.participantEventsCache.Add(participant, hash, index)
 }
 
+//Known returns the last index of every participant. The history below the
+//Roots is known even if no Event was added on top of them.
 func (s *InmemStore) Known() map[int]int {
-	return s.participantEventsCache.Known()
+	known := s.participantEventsCache.Known()
+	for p, id := range s.participantEventsCache.participants {
+		if root, ok := s.roots[p]; ok && root.Index > known[id] {
+			known[id] = root.Index
+		}
+	}
+	return known
+}
+
+func (s *InmemStore) KnownRanges() KnownRanges {
+	return s.participantEventsCache.KnownRanges()
 }
 
 func (s *InmemStore) ConsensusEvents() []string {
@@ -160,6 +172,16 @@ func (s *InmemStore) GetRoot(participant string) (Root, error) {
 	return res, nil
 }
 
+//AddParticipant makes room for a participant added at runtime. It starts
+//from a base Root unless the store was reset with a Root for it.
+func (s *InmemStore) AddParticipant(participant string, id int) error {
+	s.participantEventsCache.AddParticipant(participant, id)
+	if _, ok := s.roots[participant]; !ok {
+		s.roots[participant] = NewBaseRoot()
+	}
+	return nil
+}
+
 func (s *InmemStore) Reset(roots map[string]Root) error {
 	s.roots = roots
 	s.eventCache = cm.NewLRU(s.cacheSize, nil)
@@ -0,0 +1,57 @@
+package hashgraph
+
+import "sort"
+
+//Range is an inclusive range of Event indexes
+type Range struct {
+	First int
+	Last  int
+}
+
+/*
+KnownRanges maps participant ids to the range of indexes of the Events that a
+node holds.
+
+Known only gives the last index of every participant, which supposes that a node
+holds the whole history below it. That is not the case of a node that was
+fast-forwarded, that pruned its history, or that evicted older Events from its
+caches: it knows where the history of a participant is, but only holds the most
+recent part of it. KnownRanges shows where that part starts so that peers can
+tell which Events a node is able to serve before asking for them.
+
+The Events a node holds from a participant are always contiguous, because an
+Event is only inserted after its self-parent, so a single range per participant
+describes them. Participants of which no Event is held are left out.
+*/
+type KnownRanges map[int]Range
+
+//Last returns the index of the last Event held from participant id, or -1
+func (k KnownRanges) Last(id int) int {
+	r, ok := k[id]
+	if !ok {
+		return -1
+	}
+	return r.Last
+}
+
+//Holds is true if the Event with this index from participant id is held
+func (k KnownRanges) Holds(id, index int) bool {
+	r, ok := k[id]
+	return ok && index >= r.First && index <= r.Last
+}
+
+//Missing returns the ids of the participants for which a peer with Known other
+//could not be brought up to date by a node with these ranges and Known last:
+//the node knows Events that the peer does not, but the ones that directly
+//follow what the peer knows are no longer held.
+func (k KnownRanges) Missing(last, other map[int]int) []int {
+	missing := []int{}
+	for id, l := range last {
+		next := other[id] + 1
+		if l >= next && !k.Holds(id, next) {
+			missing = append(missing, id)
+		}
+	}
+	sort.Ints(missing)
+	return missing
+}
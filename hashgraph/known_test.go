@@ -0,0 +1,87 @@
+package hashgraph
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestKnownRangesMissing(t *testing.T) {
+	ranges := KnownRanges{
+		0: {First: 0, Last: 9},
+		1: {First: 6, Last: 9},
+		2: {First: 5, Last: 9},
+	}
+	last := map[int]int{0: 9, 1: 9, 2: 9, 3: 4}
+
+	if ranges.Last(1) != 9 || ranges.Last(3) != -1 {
+		t.Fatalf("Last should be 9 and -1, not %d and %d", ranges.Last(1), ranges.Last(3))
+	}
+	if !ranges.Holds(1, 6) || ranges.Holds(1, 4) || ranges.Holds(1, 10) {
+		t.Fatalf("Participant 1 should hold index 6 but not indexes 4 and 10")
+	}
+
+	cases := []struct {
+		other    map[int]int
+		expected []int
+	}{
+		//up to date
+		{map[int]int{0: 9, 1: 9, 2: 9, 3: 4}, []int{}},
+		//behind, but the next Events are held
+		{map[int]int{0: 1, 1: 5, 2: 4, 3: 4}, []int{}},
+		//next Events pruned, or not held at all
+		{map[int]int{0: -1, 1: 3, 2: 1, 3: 2}, []int{1, 2, 3}},
+	}
+	for i, c := range cases {
+		if m := ranges.Missing(last, c.other); !reflect.DeepEqual(m, c.expected) {
+			t.Fatalf("Case %d: Missing should be %v, not %v", i, c.expected, m)
+		}
+	}
+}
+
+func TestInmemKnownAfterReset(t *testing.T) {
+	store, participants := initInmemStore(10)
+
+	//participant 0 restarts from a Root at index 4 and adds Events 5 and 6;
+	//participant 1 only has a Root at index 2
+	roots := map[string]Root{
+		participants[0].hex: {X: "x0", Index: 4, Others: map[string]string{}},
+		participants[1].hex: {X: "x1", Index: 2, Others: map[string]string{}},
+		participants[2].hex: NewBaseRoot(),
+	}
+	if err := store.Reset(roots); err != nil {
+		t.Fatal(err)
+	}
+	selfParent := "x0"
+	for i := 5; i < 7; i++ {
+		event := NewEvent([][]byte{[]byte(fmt.Sprintf("%d", i))},
+			[]string{selfParent, ""},
+			participants[0].pubKey,
+			i)
+		if err := store.SetEvent(event); err != nil {
+			t.Fatal(err)
+		}
+		selfParent = event.Hex()
+	}
+
+	expectedKnown := map[int]int{0: 6, 1: 2, 2: -1}
+	if known := store.Known(); !reflect.DeepEqual(known, expectedKnown) {
+		t.Fatalf("Known should be %v, not %v", expectedKnown, known)
+	}
+
+	expectedRanges := KnownRanges{0: {First: 5, Last: 6}}
+	ranges := store.KnownRanges()
+	if !reflect.DeepEqual(ranges, expectedRanges) {
+		t.Fatalf("KnownRanges should be %v, not %v", expectedRanges, ranges)
+	}
+
+	//a peer that has not seen the Roots cannot be synced from this store
+	missing := ranges.Missing(store.Known(), map[int]int{0: 1, 1: -1, 2: -1})
+	if !reflect.DeepEqual(missing, []int{0, 1}) {
+		t.Fatalf("Missing should be [0 1], not %v", missing)
+	}
+	missing = ranges.Missing(store.Known(), map[int]int{0: 4, 1: 2, 2: -1})
+	if len(missing) != 0 {
+		t.Fatalf("Missing should be empty, not %v", missing)
+	}
+}
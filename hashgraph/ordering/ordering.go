@@ -0,0 +1,105 @@
+/*
+Package ordering contains the tie-breaking rules used to compute the consensus
+order of events once their round-received is known. The rules do not depend on
+the rest of the hashgraph implementation so that other implementations can
+check their compatibility with Babble against the test vectors published in
+testdata/vectors.json.
+
+Events are sorted by:
+
+  1. round-received, ascending. Events without a round-received (-1) come
+     first but are never part of a consensus batch.
+  2. consensus timestamp, ascending. The consensus timestamp of an event is the
+     median of the timestamps of the oldest self-ancestors of the round's famous
+     witnesses that see it (see MedianTimestamp).
+  3. whitened signature, ascending. The S component of the event's signature is
+     XORed with a pseudo-random number of the round-received.
+
+Babble whitens with 0, the pseudo-random number of a round without famous
+witnesses, so its consensus order compares the S components as they are. An
+implementation reproduces it by passing a nil or zero number to Less. The
+number of the famous witnesses of the round (see PseudoRandomNumber) is what
+the whitening was designed for, and would only be used by a new version of the
+protocol, since it changes the order of events.
+
+Events that no rule tells apart have identical signatures. Their order is left
+to the sort, as it always was in Babble.
+*/
+package ordering
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"time"
+)
+
+//Key holds the fields of an Event used to compute the consensus order
+type Key struct {
+	RoundReceived      int //-1 if unknown
+	ConsensusTimestamp time.Time
+	S                  *big.Int //S component of the creator's signature
+	Hash               string   //hex encoded event hash, not used by Less
+}
+
+//PseudoRandomNumber returns the XOR of the hashes of the famous witnesses of a
+//round. Hashes are hex encoded, with or without a 0x prefix. The result does
+//not depend on the order of the hashes.
+func PseudoRandomNumber(famousWitnesses []string) (*big.Int, error) {
+	res := new(big.Int)
+	for _, w := range famousWitnesses {
+		h, ok := new(big.Int).SetString(trimHexPrefix(w), 16)
+		if !ok {
+			return nil, fmt.Errorf("Invalid hash %s", w)
+		}
+		res.Xor(res, h)
+	}
+	return res, nil
+}
+
+//Whiten returns s XOR prn. A nil value is treated as 0.
+func Whiten(s, prn *big.Int) *big.Int {
+	res := new(big.Int)
+	if s != nil {
+		res.Set(s)
+	}
+	if prn != nil {
+		res.Xor(res, prn)
+	}
+	return res
+}
+
+//MedianTimestamp returns the median of a non-empty list of timestamps. With
+//an even number of timestamps, the upper one of the two middle values is used.
+func MedianTimestamp(timestamps []time.Time) time.Time {
+	sorted := make([]time.Time, len(timestamps))
+	copy(sorted, timestamps)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Before(sorted[j])
+	})
+	return sorted[len(sorted)/2]
+}
+
+//Less reports whether a comes before b in consensus order. prn is the
+//pseudo-random number of the round-received shared by a and b, nil or 0 for
+//the order of Babble; it is only used when the round-received and consensus
+//timestamp are equal.
+func Less(a, b Key, prn *big.Int) bool {
+	if a.RoundReceived != b.RoundReceived {
+		return a.RoundReceived < b.RoundReceived
+	}
+
+	if !a.ConsensusTimestamp.Equal(b.ConsensusTimestamp) {
+		return a.ConsensusTimestamp.Before(b.ConsensusTimestamp)
+	}
+
+	return Whiten(a.S, prn).Cmp(Whiten(b.S, prn)) < 0
+}
+
+func trimHexPrefix(s string) string {
+	if strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X") {
+		return s[2:]
+	}
+	return s
+}
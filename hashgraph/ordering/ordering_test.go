@@ -0,0 +1,111 @@
+package ordering
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+)
+
+type vectors struct {
+	PseudoRandomNumbers []struct {
+		FamousWitnesses []string `json:"famous_witnesses"`
+		Result          string   `json:"result"`
+	} `json:"pseudo_random_numbers"`
+	MedianTimestamps []struct {
+		Timestamps []time.Time `json:"timestamps"`
+		Result     time.Time   `json:"result"`
+	} `json:"median_timestamps"`
+	Sort []struct {
+		Name   string            `json:"name"`
+		Prns   map[string]string `json:"prns"`
+		Events []struct {
+			Hash               string    `json:"hash"`
+			RoundReceived      int       `json:"round_received"`
+			ConsensusTimestamp time.Time `json:"consensus_timestamp"`
+			S                  string    `json:"s"`
+		} `json:"events"`
+		Order []string `json:"order"`
+	} `json:"sort"`
+}
+
+func loadVectors(t *testing.T) vectors {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "vectors.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var v vectors
+	if err := json.Unmarshal(data, &v); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func hexInt(t *testing.T, s string) *big.Int {
+	i, ok := new(big.Int).SetString(s, 16)
+	if !ok {
+		t.Fatalf("Invalid hex number %s", s)
+	}
+	return i
+}
+
+func TestPseudoRandomNumberVectors(t *testing.T) {
+	for i, v := range loadVectors(t).PseudoRandomNumbers {
+		prn, err := PseudoRandomNumber(v.FamousWitnesses)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prn.Cmp(hexInt(t, v.Result)) != 0 {
+			t.Fatalf("pseudo_random_numbers[%d] should be %s, not %x", i, v.Result, prn)
+		}
+	}
+
+	if _, err := PseudoRandomNumber([]string{"0xZZ"}); err == nil {
+		t.Fatal("Invalid hash should fail")
+	}
+}
+
+func TestMedianTimestampVectors(t *testing.T) {
+	for i, v := range loadVectors(t).MedianTimestamps {
+		if m := MedianTimestamp(v.Timestamps); !m.Equal(v.Result) {
+			t.Fatalf("median_timestamps[%d] should be %s, not %s", i, v.Result, m)
+		}
+	}
+}
+
+func TestSortVectors(t *testing.T) {
+	for _, v := range loadVectors(t).Sort {
+		prns := make(map[int]*big.Int)
+		for r, p := range v.Prns {
+			round, err := strconv.Atoi(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			prns[round] = hexInt(t, p)
+		}
+
+		keys := []Key{}
+		for _, e := range v.Events {
+			keys = append(keys, Key{
+				RoundReceived:      e.RoundReceived,
+				ConsensusTimestamp: e.ConsensusTimestamp,
+				S:                  hexInt(t, e.S),
+				Hash:               e.Hash,
+			})
+		}
+
+		sort.Slice(keys, func(i, j int) bool {
+			return Less(keys[i], keys[j], prns[keys[i].RoundReceived])
+		})
+
+		for i, k := range keys {
+			if k.Hash != v.Order[i] {
+				t.Fatalf("%s: position %d should be %s, not %s", v.Name, i, v.Order[i], k.Hash)
+			}
+		}
+	}
+}
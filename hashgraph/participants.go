@@ -0,0 +1,548 @@
+package hashgraph
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/babbleio/babble/codec"
+	"github.com/babbleio/babble/crypto"
+)
+
+/*
+Participants can be added to and removed from a running hashgraph. A node that wants to join
+signs a PeerJoin with its key and asks one of the participants to propose it,
+which puts the PeerJoin in an Event. PeerJoins that are not signed by the new
+participant are ignored, so that nobody can add a key they do not hold. Like an
+eviction below, a join takes the PeerJoins of a super-majority of the active
+participants, and the new participant is added by the PeerJoin that completes
+the super-majority: every participant adds it at the same position of the
+consensus order and gives it the next free id.
+
+A new participant only counts in the super-majority from JoinRoundDelay rounds
+after the round-received of the PeerJoin. By then, all the participants have
+applied the join, so they compute the same rounds and fame. If a node already
+divided the Events of these rounds, they are divided again with the new
+super-majority.
+
+Removals work the same way with a PeerLeave transaction. A participant can
+leave on its own, but evicting another one takes a super-majority of the other
+active participants: each proposes the eviction in a PeerLeave of its own
+Events, and the participant is removed by the PeerLeave that completes the
+super-majority, in consensus order. The pending proposals of joins and
+evictions are part of the Frames, so that a node that fast-forwards counts them
+like the others. The removed participant keeps its id, so that its past Events
+can still be read, but stops counting in the super-majority JoinRoundDelay
+rounds after the round-received of that PeerLeave. Its witnesses of these
+rounds are not famous, and it can not join again with the same key.
+
+The coordinates of the Events inserted before a participant joined do not
+cover it: it is treated as having no ancestor and no descendant among them.
+*/
+
+//JoinRoundDelay is the number of rounds between the round-received of a
+//PeerJoin and the first round where the new participant counts
+const JoinRoundDelay = 6
+
+var (
+	peerJoinMagic  = []byte{0xBA, 0xBB, 0x1E, 0x10}
+	peerLeaveMagic = []byte{0xBA, 0xBB, 0x1E, 0x11}
+)
+
+//PeerJoin is the internal transaction that adds a participant. It is signed by
+//the new participant, which proves that it holds the key.
+type PeerJoin struct {
+	PubKey    string //hex public key of the new participant
+	NetAddr   string //where the other participants can reach it
+	Signature string //of PubKey and NetAddr by the new participant, see Sign
+}
+
+//hash is what the new participant signs. The prefix keeps it from being taken
+//for the hash of an Event or a Block.
+func (j PeerJoin) hash() []byte {
+	return crypto.SHA256([]byte("babble-join|" + j.PubKey + "|" + j.NetAddr))
+}
+
+//Sign sets the Signature of the PeerJoin with key, the key of the new
+//participant
+func (j *PeerJoin) Sign(key crypto.KeyPair) error {
+	if pk := crypto.KeyPairPubKeyHex(key); pk != j.PubKey {
+		return fmt.Errorf("Key %s can not sign the PeerJoin of %s", pk, j.PubKey)
+	}
+	r, s, err := key.Sign(j.hash())
+	if err != nil {
+		return err
+	}
+	j.Signature = fmt.Sprintf("%X|%X", r, s)
+	return nil
+}
+
+//Verify is true if the PeerJoin is signed by the new participant
+func (j PeerJoin) Verify() bool {
+	pubBytes, err := hex.DecodeString(strings.TrimPrefix(j.PubKey, "0x"))
+	if err != nil {
+		return false
+	}
+	if _, err := crypto.PubKeyAlgorithm(pubBytes); err != nil {
+		return false
+	}
+	parts := strings.Split(j.Signature, "|")
+	if len(parts) != 2 {
+		return false
+	}
+	r, okR := new(big.Int).SetString(parts[0], 16)
+	s, okS := new(big.Int).SetString(parts[1], 16)
+	if !okR || !okS {
+		return false
+	}
+	return crypto.VerifySignature(pubBytes, j.hash(), r, s)
+}
+
+//Marshal returns the transaction to put in an Event
+func (j PeerJoin) Marshal() ([]byte, error) {
+	var b bytes.Buffer
+	b.Write(peerJoinMagic)
+	if err := codec.Gob.NewEncoder(&b).Encode(j); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+//IsPeerJoin is true if tx looks like a PeerJoin. Applications must not submit
+//transactions with the same prefix.
+func IsPeerJoin(tx []byte) bool {
+	return bytes.HasPrefix(tx, peerJoinMagic)
+}
+
+//ReadPeerJoin decodes tx if it is a PeerJoin
+func ReadPeerJoin(tx []byte) (PeerJoin, bool) {
+	var j PeerJoin
+	if !IsPeerJoin(tx) {
+		return j, false
+	}
+	if err := codec.Gob.NewDecoder(bytes.NewReader(tx[len(peerJoinMagic):])).Decode(&j); err != nil {
+		return j, false
+	}
+	return j, true
+}
+
+//PeerLeave is the internal transaction that removes a participant
+type PeerLeave struct {
+	PubKey string
+}
+
+//Marshal returns the transaction to put in an Event
+func (l PeerLeave) Marshal() ([]byte, error) {
+	var b bytes.Buffer
+	b.Write(peerLeaveMagic)
+	if err := codec.Gob.NewEncoder(&b).Encode(l); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+//IsPeerLeave is true if tx looks like a PeerLeave
+func IsPeerLeave(tx []byte) bool {
+	return bytes.HasPrefix(tx, peerLeaveMagic)
+}
+
+//ReadPeerLeave decodes tx if it is a PeerLeave
+func ReadPeerLeave(tx []byte) (PeerLeave, bool) {
+	var l PeerLeave
+	if !IsPeerLeave(tx) {
+		return l, false
+	}
+	if err := codec.Gob.NewDecoder(bytes.NewReader(tx[len(peerLeaveMagic):])).Decode(&l); err != nil {
+		return l, false
+	}
+	return l, true
+}
+
+//IsMembershipTransaction is true if tx looks like a PeerJoin or a PeerLeave
+func IsMembershipTransaction(tx []byte) bool {
+	return IsPeerJoin(tx) || IsPeerLeave(tx)
+}
+
+//Proposal is a membership change proposed by some participants, which is
+//applied once a super-majority of them proposed it
+type Proposal struct {
+	Join      bool     //adds PubKey to the participants, otherwise removes it
+	PubKey    string   //participant to add or remove
+	Proposers []string //participants that proposed it, sorted
+}
+
+//proposalKey identifies a pending Proposal
+type proposalKey struct {
+	join   bool
+	pubKey string
+}
+
+//Participant describes a member of the hashgraph
+type Participant struct {
+	PubKey string
+	ID     int
+	Round  int //first round where the participant counts. -1 from the genesis.
+	Until  int //first round where the participant does not count. -1 if it did not leave.
+}
+
+//GetParticipants returns the participants ordered by id
+func (h *Hashgraph) GetParticipants() []Participant {
+	res := []Participant{}
+	for pk, id := range h.Participants {
+		p := Participant{PubKey: pk, ID: id, Round: -1, Until: -1}
+		if r, ok := h.joinRounds[pk]; ok {
+			p.Round = r
+		}
+		if r, ok := h.leaveRounds[pk]; ok {
+			p.Until = r
+		}
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+//SetParticipants replaces the participants, typically with the ones of a
+//Frame before resetting the hashgraph to it
+func (h *Hashgraph) SetParticipants(participants []Participant) error {
+	for pk := range h.Participants {
+		delete(h.Participants, pk)
+	}
+	h.ReverseParticipants = make(map[int]string)
+	h.joinRounds = make(map[string]int)
+	h.leaveRounds = make(map[string]int)
+	for _, p := range participants {
+		h.Participants[p.PubKey] = p.ID
+		h.ReverseParticipants[p.ID] = p.PubKey
+		if p.Round >= 0 {
+			h.joinRounds[p.PubKey] = p.Round
+		}
+		if p.Until >= 0 {
+			h.leaveRounds[p.PubKey] = p.Until
+		}
+		if err := h.Store.AddParticipant(p.PubKey, p.ID); err != nil {
+			return err
+		}
+	}
+	h.updateSuperMajority()
+	h.ResetCaches()
+	return nil
+}
+
+//AddParticipant adds a participant that counts from round on. It returns the
+//id of the participant, which is not changed if it was already known.
+func (h *Hashgraph) AddParticipant(pubKey string, round int) (int, error) {
+	if id, ok := h.Participants[pubKey]; ok {
+		return id, nil
+	}
+	id := len(h.Participants)
+	if _, ok := h.ReverseParticipants[id]; ok {
+		return -1, fmt.Errorf("Participant id %d is already taken", id)
+	}
+	if err := h.Store.AddParticipant(pubKey, id); err != nil {
+		return -1, err
+	}
+	h.Participants[pubKey] = id
+	h.ReverseParticipants[id] = pubKey
+	h.joinRounds[pubKey] = round
+	delete(h.proposals, proposalKey{join: true, pubKey: pubKey})
+	h.updateSuperMajority()
+
+	h.logger.WithFields(map[string]interface{}{
+		"participant": pubKey,
+		"id":          id,
+		"round":       round,
+	}).Info("Participant added")
+
+	return id, h.redivideRounds(round)
+}
+
+//RemoveParticipant makes a participant stop counting from round on. Removing
+//a participant that already left does nothing.
+func (h *Hashgraph) RemoveParticipant(pubKey string, round int) error {
+	if _, ok := h.Participants[pubKey]; !ok {
+		return fmt.Errorf("Unknown participant %s", pubKey)
+	}
+	if _, ok := h.leaveRounds[pubKey]; ok {
+		return nil
+	}
+	h.leaveRounds[pubKey] = round
+	delete(h.proposals, proposalKey{pubKey: pubKey})
+	h.updateSuperMajority()
+
+	h.logger.WithFields(map[string]interface{}{
+		"participant": pubKey,
+		"round":       round,
+	}).Info("Participant removed")
+
+	return h.redivideRounds(round)
+}
+
+//applyMembership adds and removes the participants proposed in the
+//transactions of a consensus Event
+func (h *Hashgraph) applyMembership(e Event) error {
+	round := e.RoundReceived() + JoinRoundDelay
+	for _, tx := range e.Transactions() {
+		if j, ok := ReadPeerJoin(tx); ok {
+			//a removed participant can not join again
+			if _, ok := h.Participants[j.PubKey]; ok {
+				continue
+			}
+			if !j.Verify() {
+				h.logger.WithField("participant", j.PubKey).Debug("Ignoring PeerJoin not signed by the new participant")
+				continue
+			}
+			if !h.propose(proposalKey{join: true, pubKey: j.PubKey}, e.Creator()) {
+				continue
+			}
+			if _, err := h.AddParticipant(j.PubKey, round); err != nil {
+				return err
+			}
+		} else if l, ok := ReadPeerLeave(tx); ok {
+			if !h.Active(l.PubKey) {
+				h.logger.WithField("participant", l.PubKey).Debug("Ignoring PeerLeave of an inactive participant")
+				continue
+			}
+			//a participant leaves on its own, others are evicted by a
+			//super-majority
+			if l.PubKey != e.Creator() && !h.propose(proposalKey{pubKey: l.PubKey}, e.Creator()) {
+				continue
+			}
+			if err := h.RemoveParticipant(l.PubKey, round); err != nil {
+				h.logger.WithField("error", err).Error("Ignoring PeerLeave")
+			}
+		}
+	}
+	return nil
+}
+
+//propose records that proposer proposed the change p. It returns true, and
+//forgets the proposal, once a super-majority of the active participants other
+//than the one added or removed proposed it.
+func (h *Hashgraph) propose(p proposalKey, proposer string) bool {
+	if !h.Active(proposer) {
+		return false
+	}
+	proposers, ok := h.proposals[p]
+	if !ok {
+		proposers = make(map[string]bool)
+		h.proposals[p] = proposers
+	}
+	proposers[proposer] = true
+
+	votes, others := 0, 0
+	for pk := range h.Participants {
+		if pk == p.pubKey || !h.Active(pk) {
+			continue
+		}
+		others++
+		if proposers[pk] {
+			votes++
+		}
+	}
+	h.logger.WithFields(map[string]interface{}{
+		"participant": p.pubKey,
+		"join":        p.join,
+		"proposer":    proposer,
+		"votes":       votes,
+	}).Debug("Membership change proposed")
+	if votes < 2*others/3+1 {
+		return false
+	}
+	delete(h.proposals, p)
+	return true
+}
+
+//GetProposals returns the pending membership changes, ordered by public key,
+//evictions first
+func (h *Hashgraph) GetProposals() []Proposal {
+	res := []Proposal{}
+	for key, proposers := range h.proposals {
+		p := Proposal{Join: key.join, PubKey: key.pubKey}
+		for proposer := range proposers {
+			p.Proposers = append(p.Proposers, proposer)
+		}
+		sort.Strings(p.Proposers)
+		res = append(res, p)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].PubKey != res[j].PubKey {
+			return res[i].PubKey < res[j].PubKey
+		}
+		return !res[i].Join && res[j].Join
+	})
+	return res
+}
+
+//SetProposals replaces the pending membership changes, typically with the ones
+//of a Frame
+func (h *Hashgraph) SetProposals(proposals []Proposal) {
+	h.proposals = make(map[proposalKey]map[string]bool)
+	for _, p := range proposals {
+		proposers := make(map[string]bool)
+		for _, proposer := range p.Proposers {
+			proposers[proposer] = true
+		}
+		h.proposals[proposalKey{join: p.Join, pubKey: p.PubKey}] = proposers
+	}
+}
+
+//Active is true if the participant was not removed
+func (h *Hashgraph) Active(pubKey string) bool {
+	_, ok := h.Participants[pubKey]
+	_, left := h.leaveRounds[pubKey]
+	return ok && !left
+}
+
+//counts is true if the participant counts in round
+func (h *Hashgraph) counts(pubKey string, round int) bool {
+	if r, ok := h.joinRounds[pubKey]; ok && r > round {
+		return false
+	}
+	if r, ok := h.leaveRounds[pubKey]; ok && r <= round {
+		return false
+	}
+	return true
+}
+
+//updateSuperMajority sets the super-majority of the participants that did not
+//leave, as returned by SuperMajority
+func (h *Hashgraph) updateSuperMajority() {
+	h.superMajority = 2*(len(h.Participants)-len(h.leaveRounds))/3 + 1
+}
+
+//membershipChanged is true once participants were added or removed at runtime
+func (h *Hashgraph) membershipChanged() bool {
+	return len(h.joinRounds) > 0 || len(h.leaveRounds) > 0
+}
+
+func (h *Hashgraph) creator(x string) string {
+	ex, err := h.Store.GetEvent(x)
+	if err != nil {
+		return ""
+	}
+	return ex.Creator()
+}
+
+//roundWitnesses returns the witnesses of round whose creators count in it
+func (h *Hashgraph) roundWitnesses(round int) []string {
+	witnesses := h.Store.RoundWitnesses(round)
+	if !h.membershipChanged() {
+		return witnesses
+	}
+	res := []string{}
+	for _, w := range witnesses {
+		if h.counts(h.creator(w), round) {
+			res = append(res, w)
+		}
+	}
+	return res
+}
+
+//participantsAt returns the number of participants that count in round
+func (h *Hashgraph) participantsAt(round int) int {
+	n := 0
+	for pk := range h.Participants {
+		if h.counts(pk, round) {
+			n++
+		}
+	}
+	return n
+}
+
+func (h *Hashgraph) superMajorityAt(round int) int {
+	if !h.membershipChanged() {
+		return h.superMajority
+	}
+	return 2*h.participantsAt(round)/3 + 1
+}
+
+//redivideRounds divides the undetermined Events again from round from on,
+//after the super-majority of these rounds changed
+func (h *Hashgraph) redivideRounds(from int) error {
+	lastRound := h.Store.LastRound()
+	if from > lastRound {
+		return nil
+	}
+
+	undecided := []int{}
+	for _, r := range h.UndecidedRounds {
+		if r < from {
+			undecided = append(undecided, r)
+		}
+	}
+	for r := from; r <= lastRound; r++ {
+		if err := h.Store.SetRound(r, *NewRoundInfo()); err != nil {
+			return err
+		}
+	}
+	h.ResetCaches()
+
+	for _, x := range h.UndeterminedEvents {
+		r := h.Round(x)
+		roundInfo, err := h.Store.GetRound(r)
+		if err != nil {
+			return err
+		}
+		if _, ok := roundInfo.Events[x]; ok {
+			continue
+		}
+		if len(roundInfo.Events) == 0 && r >= from {
+			undecided = append(undecided, r)
+		}
+		roundInfo.AddEvent(x, h.Witness(x))
+		if err := h.Store.SetRound(r, roundInfo); err != nil {
+			return err
+		}
+	}
+	sort.Ints(undecided)
+	h.UndecidedRounds = undecided
+	return nil
+}
+
+//coordinates of Events inserted before a participant joined do not cover it
+
+func lastAncestor(e Event, id int) EventCoordinates {
+	if id >= len(e.lastAncestors) {
+		return EventCoordinates{index: -1}
+	}
+	return e.lastAncestors[id]
+}
+
+//eventAt returns the hash of the Event of participant id at index, or "" if
+//there is none, like for the coordinates of no ancestor or descendant. It fails
+//when the Store can not return the Event, like when it was evicted.
+func (h *Hashgraph) eventAt(id, index int) (string, error) {
+	if index < 0 || index == math.MaxInt64 {
+		return "", nil
+	}
+	pubKey, ok := h.ReverseParticipants[id]
+	if !ok {
+		return "", fmt.Errorf("Unknown participant %d", id)
+	}
+	return h.Store.ParticipantEvent(pubKey, index)
+}
+
+func firstDescendant(e Event, id int) EventCoordinates {
+	if id >= len(e.firstDescendants) {
+		return EventCoordinates{index: math.MaxInt64}
+	}
+	return e.firstDescendants[id]
+}
+
+//IsValidatorAt is true if pubKey is a participant that counts in round. Its
+//signatures of the Blocks received in that round are accepted.
+func (h *Hashgraph) IsValidatorAt(pubKey string, round int) bool {
+	_, ok := h.Participants[pubKey]
+	return ok && h.counts(pubKey, round)
+}
+
+//SuperMajorityAt returns the number of participants that make a super-majority
+//in round
+func (h *Hashgraph) SuperMajorityAt(round int) int {
+	return h.superMajorityAt(round)
+}
@@ -0,0 +1,304 @@
+package hashgraph
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/crypto"
+)
+
+func newParticipantKey() string {
+	key, _ := crypto.GenerateECDSAKey()
+	return fmt.Sprintf("0x%X", crypto.FromECDSAPub(&key.PublicKey))
+}
+
+func TestMembershipTransactions(t *testing.T) {
+	join := PeerJoin{PubKey: newParticipantKey(), NetAddr: "127.0.0.1:1337"}
+	tx, err := join.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, ok := ReadPeerJoin(tx)
+	if !ok || read != join {
+		t.Fatalf("ReadPeerJoin should return %v, not %v (%v)", join, read, ok)
+	}
+	if _, ok := ReadPeerJoin([]byte("app transaction")); ok {
+		t.Fatal("An application transaction should not be read as a PeerJoin")
+	}
+	if _, ok := ReadPeerJoin(append(peerJoinMagic, []byte("garbage")...)); ok {
+		t.Fatal("A corrupted PeerJoin should not be read")
+	}
+
+	leave := PeerLeave{PubKey: join.PubKey}
+	tx, err = leave.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if readLeave, ok := ReadPeerLeave(tx); !ok || readLeave != leave {
+		t.Fatalf("ReadPeerLeave should return %v, not %v (%v)", leave, readLeave, ok)
+	}
+	if _, ok := ReadPeerJoin(tx); ok || !IsMembershipTransaction(tx) {
+		t.Fatal("A PeerLeave should only be read as a PeerLeave")
+	}
+}
+
+func TestAddParticipant(t *testing.T) {
+	h, index := initConsensusHashgraph(common.NewTestLogger(t))
+	h.DivideRounds()
+	h.DecideFame()
+
+	pk1, pk2 := newParticipantKey(), newParticipantKey()
+	if id, err := h.AddParticipant(pk1, 100); err != nil || id != 3 {
+		t.Fatalf("The first new participant should get id 3, got %d (%v)", id, err)
+	}
+	if id, err := h.AddParticipant(pk2, 101); err != nil || id != 4 {
+		t.Fatalf("The second new participant should get id 4, got %d (%v)", id, err)
+	}
+	if id, _ := h.AddParticipant(pk1, 200); id != 3 {
+		t.Fatalf("Adding a participant again should keep id 3, not %d", id)
+	}
+
+	//participants only count from their join round
+	expected := map[int]int{0: 3, 100: 3, 101: 4}
+	for round, sm := range expected {
+		if s := h.superMajorityAt(round); s != sm {
+			t.Fatalf("Super-majority of round %d should be %d, not %d", round, sm, s)
+		}
+	}
+
+	participants := h.GetParticipants()
+	if len(participants) != 5 || participants[3].PubKey != pk1 || participants[3].Round != 100 ||
+		participants[0].Round != -1 {
+		t.Fatalf("Unexpected participants %v", participants)
+	}
+
+	//Events inserted before the join neither see nor are seen by the new
+	//participants, and earlier rounds are not affected
+	e0, _ := h.Store.GetEvent(index["e0"])
+	if a := lastAncestor(e0, 4); a.index != -1 {
+		t.Fatalf("e0 should have no ancestor from participant 4, got %d", a.index)
+	}
+	if d := firstDescendant(e0, 4); d.index != math.MaxInt64 {
+		t.Fatalf("e0 should have no descendant from participant 4, got %d", d.index)
+	}
+	if !h.StronglySee(index["g0"], index["e0"]) {
+		t.Fatal("g0 should still strongly see e0")
+	}
+	if r := h.Round(index["g0"]); r != 2 {
+		t.Fatalf("g0 round should still be 2, not %d", r)
+	}
+}
+
+func TestAddParticipantRedivideRounds(t *testing.T) {
+	h, index := initConsensusHashgraph(common.NewTestLogger(t))
+	h.DivideRounds()
+	if r := h.Round(index["g0"]); r != 2 {
+		t.Fatalf("g0 round should be 2, not %d", r)
+	}
+
+	//with 5 participants from round 0, the 3 original ones can not make a
+	//super-majority anymore
+	for i := 0; i < 2; i++ {
+		if _, err := h.AddParticipant(newParticipantKey(), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if r := h.Round(index["g0"]); r != 0 {
+		t.Fatalf("g0 round should be 0 after the join, not %d", r)
+	}
+	if w := h.Store.RoundWitnesses(2); len(w) != 0 {
+		t.Fatalf("Round 2 should have no witnesses anymore, not %v", w)
+	}
+	if len(h.UndecidedRounds) != 1 || h.UndecidedRounds[0] != 0 {
+		t.Fatalf("UndecidedRounds should be [0], not %v", h.UndecidedRounds)
+	}
+}
+
+func TestRemoveParticipant(t *testing.T) {
+	h, index := initConsensusHashgraph(common.NewTestLogger(t))
+	h.DivideRounds()
+	h.DecideFame()
+
+	pk := h.ReverseParticipants[2]
+	if err := h.RemoveParticipant(pk, 100); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.RemoveParticipant(pk, 200); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.RemoveParticipant(newParticipantKey(), 100); err == nil {
+		t.Fatal("Removing an unknown participant should fail")
+	}
+
+	if h.Active(pk) || !h.Active(h.ReverseParticipants[0]) {
+		t.Fatal("Only participant 2 should be inactive")
+	}
+	if sm := h.SuperMajority(); sm != 2 {
+		t.Fatalf("SuperMajority should be 2 with 2 participants left, not %d", sm)
+	}
+	expected := map[int]int{0: 3, 99: 3, 100: 2, 200: 2}
+	for round, sm := range expected {
+		if s := h.superMajorityAt(round); s != sm {
+			t.Fatalf("Super-majority of round %d should be %d, not %d", round, sm, s)
+		}
+	}
+	if p := h.GetParticipants()[2]; p.Until != 100 || p.Round != -1 {
+		t.Fatalf("Participant 2 should count until round 100, got %v", p)
+	}
+
+	//the removed participant keeps its id and its past Events
+	if id, _ := h.AddParticipant(pk, 300); id != 2 {
+		t.Fatalf("Participant 2 should keep its id, not %d", id)
+	}
+	if r := h.Round(index["g2"]); r != 2 {
+		t.Fatalf("g2 round should still be 2, not %d", r)
+	}
+}
+
+func TestEvictionProposals(t *testing.T) {
+	h, _ := initConsensusHashgraph(common.NewTestLogger(t))
+	h.DivideRounds()
+	h.DecideFame()
+
+	pk0, pk1, pk2 := h.ReverseParticipants[0], h.ReverseParticipants[1], h.ReverseParticipants[2]
+	//an Event of creator pk in consensus at round 10 with a PeerLeave of target
+	leaveEvent := func(pk, target string) Event {
+		creator, _ := hex.DecodeString(pk[2:])
+		tx, err := PeerLeave{PubKey: target}.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := NewEvent([][]byte{tx}, []string{"", ""}, creator, 0)
+		e.SetRoundReceived(10)
+		return e
+	}
+
+	//the 2 other participants are a super-majority, each counts once
+	for _, pk := range []string{pk0, pk0, newParticipantKey()} {
+		if err := h.applyMembership(leaveEvent(pk, pk1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !h.Active(pk1) {
+		t.Fatal("A single proposal should not evict a participant")
+	}
+	proposals := h.GetProposals()
+	if len(proposals) != 1 || proposals[0].PubKey != pk1 ||
+		!reflect.DeepEqual(proposals[0].Proposers, []string{pk0}) {
+		t.Fatalf("The proposal of %s should be pending, not %v", pk0, proposals)
+	}
+
+	//the pending proposals go with the Frames
+	fresh, _ := initConsensusHashgraph(common.NewTestLogger(t))
+	fresh.SetProposals(proposals)
+	if p := fresh.GetProposals(); !reflect.DeepEqual(p, proposals) {
+		t.Fatalf("SetProposals should restore %v, not %v", proposals, p)
+	}
+
+	if err := h.applyMembership(leaveEvent(pk1, pk0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := h.applyMembership(leaveEvent(pk2, pk1)); err != nil {
+		t.Fatal(err)
+	}
+	if h.Active(pk1) {
+		t.Fatal("pk1 should be evicted by a super-majority of the others")
+	}
+	if p := h.GetParticipants()[1]; p.Until != 10+JoinRoundDelay {
+		t.Fatalf("pk1 should count until round %d, not %d", 10+JoinRoundDelay, p.Until)
+	}
+	if p := h.GetProposals(); len(p) != 1 || p[0].PubKey != pk0 {
+		t.Fatalf("Only the eviction of pk0 should be pending, not %v", p)
+	}
+
+	//a participant leaves on its own
+	if err := h.applyMembership(leaveEvent(pk0, pk0)); err != nil {
+		t.Fatal(err)
+	}
+	if h.Active(pk0) || len(h.GetProposals()) != 0 {
+		t.Fatal("pk0 should have left, and its eviction should not be pending anymore")
+	}
+}
+
+func TestJoinProposals(t *testing.T) {
+	h, _ := initConsensusHashgraph(common.NewTestLogger(t))
+	h.DivideRounds()
+	h.DecideFame()
+
+	key, _ := crypto.GenerateECDSAKey()
+	join := PeerJoin{PubKey: crypto.KeyPairPubKeyHex(crypto.NewECDSAKeyPair(key)), NetAddr: "127.0.0.1:1337"}
+	if join.Verify() {
+		t.Fatal("An unsigned PeerJoin should not verify")
+	}
+	other, _ := crypto.GenerateECDSAKey()
+	if err := join.Sign(crypto.NewECDSAKeyPair(other)); err == nil {
+		t.Fatal("Only the new participant should sign its PeerJoin")
+	}
+	if err := join.Sign(crypto.NewECDSAKeyPair(key)); err != nil {
+		t.Fatal(err)
+	}
+	if !join.Verify() {
+		t.Fatal("A PeerJoin signed by the new participant should verify")
+	}
+	moved := join
+	moved.NetAddr = "127.0.0.1:1338"
+	if moved.Verify() {
+		t.Fatal("The signature should cover the address")
+	}
+
+	//an Event of creator pk in consensus at round 10 with j
+	joinEvent := func(pk string, j PeerJoin) Event {
+		creator, _ := hex.DecodeString(pk[2:])
+		tx, err := j.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		e := NewEvent([][]byte{tx}, []string{"", ""}, creator, 0)
+		e.SetRoundReceived(10)
+		return e
+	}
+
+	//PeerJoins not signed by the new participant are not counted
+	for _, pk := range []string{h.ReverseParticipants[0], h.ReverseParticipants[1], h.ReverseParticipants[2]} {
+		if err := h.applyMembership(joinEvent(pk, moved)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := h.Participants[join.PubKey]; ok || len(h.GetProposals()) != 0 {
+		t.Fatal("A PeerJoin with a bad signature should be ignored")
+	}
+
+	//all 3 participants make the super-majority, each counts once, and
+	//outsiders do not count
+	for _, pk := range []string{h.ReverseParticipants[0], h.ReverseParticipants[0], newParticipantKey(), h.ReverseParticipants[1]} {
+		if err := h.applyMembership(joinEvent(pk, join)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := h.Participants[join.PubKey]; ok {
+		t.Fatal("2 proposals out of 3 participants should not add a participant")
+	}
+	proposals := h.GetProposals()
+	if len(proposals) != 1 || !proposals[0].Join || len(proposals[0].Proposers) != 2 {
+		t.Fatalf("The join should be pending with 2 proposers, not %v", proposals)
+	}
+
+	if err := h.applyMembership(joinEvent(h.ReverseParticipants[2], join)); err != nil {
+		t.Fatal(err)
+	}
+	if id, ok := h.Participants[join.PubKey]; !ok || id != 3 {
+		t.Fatalf("The new participant should be added with id 3, got %d (%v)", id, ok)
+	}
+	if p := h.GetParticipants()[3]; p.Round != 10+JoinRoundDelay {
+		t.Fatalf("The new participant should count from round %d, not %d", 10+JoinRoundDelay, p.Round)
+	}
+	if p := h.GetProposals(); len(p) != 0 {
+		t.Fatalf("No proposal should be pending, not %v", p)
+	}
+}
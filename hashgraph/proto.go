@@ -0,0 +1,416 @@
+package hashgraph
+
+import (
+	"math/big"
+
+	"github.com/babbleio/babble/codec"
+)
+
+/*
+Protobuf encoding of the types that travel in RPCs, following net/wire.proto.
+The transport uses it with the peers that support it. Like with the other
+codecs, Event hashes are computed on the Gob encoding of the body, which the
+decoded values reproduce exactly.
+*/
+
+//appendBigInt writes a signature component, unless it is nil
+func appendBigInt(b []byte, field int, v *big.Int) []byte {
+	if v == nil {
+		return b
+	}
+	return codec.AppendBytes(b, field, v.Bytes())
+}
+
+func appendStringMap(b []byte, field int, m map[string]string) []byte {
+	for k, v := range m {
+		var entry []byte
+		entry = codec.AppendString(entry, 1, k)
+		entry = codec.AppendString(entry, 2, v)
+		b = codec.AppendBytes(b, field, entry)
+	}
+	return b
+}
+
+func readStringMapEntry(m *map[string]string, data []byte) error {
+	var k, v string
+	err := codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			k = f.String()
+		case 2:
+			v = f.String()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if *m == nil {
+		*m = make(map[string]string)
+	}
+	(*m)[k] = v
+	return nil
+}
+
+//------------------------------------------------------------------------------
+
+func (we WireEvent) MarshalProto() []byte {
+	var b []byte
+	for _, tx := range we.Body.Transactions {
+		b = codec.AppendBytes(b, 1, tx)
+	}
+	b = codec.AppendInt(b, 2, we.Body.SelfParentIndex)
+	b = codec.AppendInt(b, 3, we.Body.OtherParentCreatorID)
+	b = codec.AppendInt(b, 4, we.Body.OtherParentIndex)
+	b = codec.AppendInt(b, 5, we.Body.CreatorID)
+	b = codec.AppendTime(b, 6, we.Body.Timestamp)
+	b = codec.AppendInt(b, 7, we.Body.Index)
+	b = appendBigInt(b, 8, we.R)
+	b = appendBigInt(b, 9, we.S)
+	return codec.AppendInt(b, 10, we.Body.Version)
+}
+
+func (we *WireEvent) UnmarshalProto(data []byte) error {
+	*we = WireEvent{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		var err error
+		switch f.Number {
+		case 1:
+			we.Body.Transactions = append(we.Body.Transactions, f.Copy())
+		case 2:
+			we.Body.SelfParentIndex = f.Int()
+		case 3:
+			we.Body.OtherParentCreatorID = f.Int()
+		case 4:
+			we.Body.OtherParentIndex = f.Int()
+		case 5:
+			we.Body.CreatorID = f.Int()
+		case 6:
+			we.Body.Timestamp, err = f.Time()
+		case 7:
+			we.Body.Index = f.Int()
+		case 8:
+			we.R = new(big.Int).SetBytes(f.Bytes)
+		case 9:
+			we.S = new(big.Int).SetBytes(f.Bytes)
+		case 10:
+			we.Body.Version = f.Int()
+		}
+		return err
+	})
+}
+
+//MarshalProto writes the exported fields of the Event, like Gob
+func (e Event) MarshalProto() []byte {
+	var b []byte
+	for _, tx := range e.Body.Transactions {
+		b = codec.AppendBytes(b, 1, tx)
+	}
+	for _, p := range e.Body.Parents {
+		b = codec.AppendBytes(b, 2, []byte(p))
+	}
+	if len(e.Body.Creator) > 0 {
+		b = codec.AppendBytes(b, 3, e.Body.Creator)
+	}
+	b = codec.AppendTime(b, 4, e.Body.Timestamp)
+	b = codec.AppendInt(b, 5, e.Body.Index)
+	b = appendBigInt(b, 6, e.R)
+	b = appendBigInt(b, 7, e.S)
+	return codec.AppendInt(b, 8, e.Body.Version)
+}
+
+func (e *Event) UnmarshalProto(data []byte) error {
+	*e = Event{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		var err error
+		switch f.Number {
+		case 1:
+			e.Body.Transactions = append(e.Body.Transactions, f.Copy())
+		case 2:
+			e.Body.Parents = append(e.Body.Parents, f.String())
+		case 3:
+			e.Body.Creator = f.Copy()
+		case 4:
+			e.Body.Timestamp, err = f.Time()
+		case 5:
+			e.Body.Index = f.Int()
+		case 6:
+			e.R = new(big.Int).SetBytes(f.Bytes)
+		case 7:
+			e.S = new(big.Int).SetBytes(f.Bytes)
+		case 8:
+			e.Body.Version = f.Int()
+		}
+		return err
+	})
+}
+
+func (r Root) MarshalProto() []byte {
+	var b []byte
+	b = codec.AppendString(b, 1, r.X)
+	b = codec.AppendString(b, 2, r.Y)
+	b = codec.AppendInt(b, 3, r.Index)
+	b = codec.AppendInt(b, 4, r.Round)
+	return appendStringMap(b, 5, r.Others)
+}
+
+func (r *Root) UnmarshalProto(data []byte) error {
+	*r = Root{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			r.X = f.String()
+		case 2:
+			r.Y = f.String()
+		case 3:
+			r.Index = f.Int()
+		case 4:
+			r.Round = f.Int()
+		case 5:
+			return readStringMapEntry(&r.Others, f.Bytes)
+		}
+		return nil
+	})
+}
+
+func (p Participant) MarshalProto() []byte {
+	var b []byte
+	b = codec.AppendString(b, 1, p.PubKey)
+	b = codec.AppendInt(b, 2, p.ID)
+	b = codec.AppendInt(b, 3, p.Round)
+	return codec.AppendInt(b, 4, p.Until)
+}
+
+func (p *Participant) UnmarshalProto(data []byte) error {
+	*p = Participant{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			p.PubKey = f.String()
+		case 2:
+			p.ID = f.Int()
+		case 3:
+			p.Round = f.Int()
+		case 4:
+			p.Until = f.Int()
+		}
+		return nil
+	})
+}
+
+func (p Proposal) MarshalProto() []byte {
+	var b []byte
+	b = codec.AppendString(b, 1, p.PubKey)
+	for _, proposer := range p.Proposers {
+		b = codec.AppendString(b, 2, proposer)
+	}
+	return codec.AppendBool(b, 3, p.Join)
+}
+
+func (p *Proposal) UnmarshalProto(data []byte) error {
+	*p = Proposal{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			p.PubKey = f.String()
+		case 2:
+			p.Proposers = append(p.Proposers, f.String())
+		case 3:
+			p.Join = f.Bool()
+		}
+		return nil
+	})
+}
+
+func (f Frame) MarshalProto() []byte {
+	var b []byte
+	for pk, root := range f.Roots {
+		var entry []byte
+		entry = codec.AppendString(entry, 1, pk)
+		entry = codec.AppendBytes(entry, 2, root.MarshalProto())
+		b = codec.AppendBytes(b, 1, entry)
+	}
+	for _, e := range f.Events {
+		b = codec.AppendBytes(b, 2, e.MarshalProto())
+	}
+	for _, p := range f.Participants {
+		b = codec.AppendBytes(b, 3, p.MarshalProto())
+	}
+	for _, p := range f.Proposals {
+		b = codec.AppendBytes(b, 4, p.MarshalProto())
+	}
+	return b
+}
+
+func (f *Frame) UnmarshalProto(data []byte) error {
+	*f = Frame{}
+	return codec.ReadFields(data, func(field codec.ProtoField) error {
+		switch field.Number {
+		case 1:
+			var pk string
+			var root Root
+			err := codec.ReadFields(field.Bytes, func(entry codec.ProtoField) error {
+				switch entry.Number {
+				case 1:
+					pk = entry.String()
+				case 2:
+					return root.UnmarshalProto(entry.Bytes)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			if f.Roots == nil {
+				f.Roots = make(map[string]Root)
+			}
+			f.Roots[pk] = root
+		case 2:
+			var e Event
+			if err := e.UnmarshalProto(field.Bytes); err != nil {
+				return err
+			}
+			f.Events = append(f.Events, e)
+		case 3:
+			var p Participant
+			if err := p.UnmarshalProto(field.Bytes); err != nil {
+				return err
+			}
+			f.Participants = append(f.Participants, p)
+		case 4:
+			var p Proposal
+			if err := p.UnmarshalProto(field.Bytes); err != nil {
+				return err
+			}
+			f.Proposals = append(f.Proposals, p)
+		}
+		return nil
+	})
+}
+
+func (b Block) MarshalProto() []byte {
+	var msg []byte
+	msg = codec.AppendInt(msg, 1, b.Index)
+	msg = codec.AppendInt(msg, 2, b.RoundReceived)
+	for _, tx := range b.Transactions {
+		msg = codec.AppendBytes(msg, 3, tx)
+	}
+	if len(b.StateHash) > 0 {
+		msg = codec.AppendBytes(msg, 4, b.StateHash)
+	}
+	msg = appendStringMap(msg, 5, b.Signatures)
+	if b.Version != 0 {
+		msg = codec.AppendInt(msg, 6, b.Version)
+	}
+	for _, m := range b.Metadata {
+		var mb []byte
+		mb = codec.AppendString(mb, 1, m.Creator)
+		mb = codec.AppendBytes(mb, 2, m.Data)
+		msg = codec.AppendBytes(msg, 7, mb)
+	}
+	return msg
+}
+
+func (b *Block) UnmarshalProto(data []byte) error {
+	*b = Block{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			b.Index = f.Int()
+		case 2:
+			b.RoundReceived = f.Int()
+		case 3:
+			b.Transactions = append(b.Transactions, f.Copy())
+		case 4:
+			b.StateHash = f.Copy()
+		case 5:
+			return readStringMapEntry(&b.Signatures, f.Bytes)
+		case 6:
+			b.Version = f.Int()
+		case 7:
+			var m BlockMetadata
+			err := codec.ReadFields(f.Bytes, func(f codec.ProtoField) error {
+				switch f.Number {
+				case 1:
+					m.Creator = f.String()
+				case 2:
+					m.Data = f.Copy()
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			b.Metadata = append(b.Metadata, m)
+		}
+		return nil
+	})
+}
+
+func (s BlockSignature) MarshalProto() []byte {
+	var b []byte
+	b = codec.AppendString(b, 1, s.Validator)
+	b = codec.AppendInt(b, 2, s.Index)
+	return codec.AppendString(b, 3, s.Signature)
+}
+
+func (s *BlockSignature) UnmarshalProto(data []byte) error {
+	*s = BlockSignature{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			s.Validator = f.String()
+		case 2:
+			s.Index = f.Int()
+		case 3:
+			s.Signature = f.String()
+		}
+		return nil
+	})
+}
+
+//MarshalProto writes one entry per participant, with its range
+func (k KnownRanges) MarshalProto() []byte {
+	var b []byte
+	for id, r := range k {
+		var entry, rb []byte
+		entry = codec.AppendInt(entry, 1, id)
+		rb = codec.AppendInt(rb, 1, r.First)
+		rb = codec.AppendInt(rb, 2, r.Last)
+		entry = codec.AppendBytes(entry, 2, rb)
+		b = codec.AppendBytes(b, 1, entry)
+	}
+	return b
+}
+
+//UnmarshalProto adds the entries of data to k, which must not be nil. Entries
+//without a range are skipped.
+func (k KnownRanges) UnmarshalProto(data []byte) error {
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		var id int
+		var r *Range
+		err := codec.ReadFields(f.Bytes, func(entry codec.ProtoField) error {
+			switch entry.Number {
+			case 1:
+				id = entry.Int()
+			case 2:
+				r = &Range{}
+				return codec.ReadFields(entry.Bytes, func(rf codec.ProtoField) error {
+					switch rf.Number {
+					case 1:
+						r.First = rf.Int()
+					case 2:
+						r.Last = rf.Int()
+					}
+					return nil
+				})
+			}
+			return nil
+		})
+		if err != nil || r == nil {
+			return err
+		}
+		k[id] = *r
+		return nil
+	})
+}
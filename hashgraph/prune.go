@@ -0,0 +1,85 @@
+package hashgraph
+
+/*
+Without pruning, a persistent Store keeps every Event, Round and consensus entry
+of the hashgraph. Pruning moves the Roots of the Store up to the witnesses of a
+decided round, the way fast-forwarding to a Frame would, and deletes what lies
+below them. The Store then looks like the one of a node that fast-forwarded to
+that round: it restarts from the new Roots and replays the Events above them,
+and its KnownRanges start right above them, so that peers that are further
+behind are told to fast-forward.
+
+The Root of a participant is set right below its witness in the pruned round,
+with the round before it, so that the witness stays in its round when the
+hashgraph is rebuilt on top of the Root. Participants without a witness in that
+round keep their Root. The Events above the new Roots whose other-parent is
+deleted are recorded in Root.Others.
+*/
+
+//Pruner is implemented by the Stores whose history can be pruned, like
+//BadgerStore
+type Pruner interface {
+	//Prune deletes the Events below roots and the Rounds below round, and
+	//makes roots the Roots of the Store. It returns the number of Events
+	//deleted.
+	Prune(roots map[string]Root, round int) (int, error)
+}
+
+//PruneRoots returns the Roots of every participant once the history below
+//round is pruned. round must be decided.
+func (h *Hashgraph) PruneRoots(round int) (map[string]Root, error) {
+	roots := make(map[string]Root)
+	for p := range h.Participants {
+		root, err := h.Store.GetRoot(p)
+		if err != nil {
+			return nil, err
+		}
+		others := make(map[string]string, len(root.Others))
+		for k, v := range root.Others {
+			others[k] = v
+		}
+		root.Others = others
+		roots[p] = root
+	}
+
+	for _, wh := range h.Store.RoundWitnesses(round) {
+		w, err := h.Store.GetEvent(wh)
+		if err != nil {
+			return nil, err
+		}
+		if w.Index()-1 <= roots[w.Creator()].Index {
+			continue
+		}
+		roots[w.Creator()] = Root{
+			X:      w.SelfParent(),
+			Y:      w.OtherParent(),
+			Index:  w.Index() - 1,
+			Round:  h.Round(wh) - 1,
+			Others: map[string]string{},
+		}
+	}
+
+	for p, root := range roots {
+		events, err := h.Store.ParticipantEvents(p, root.Index)
+		if err != nil {
+			return nil, err
+		}
+		for i, e := range events {
+			ev, err := h.Store.GetEvent(e)
+			if err != nil {
+				return nil, err
+			}
+			op := ev.OtherParent()
+			if op == "" || (i == 0 && op == root.Y) {
+				continue
+			}
+			//an other-parent that is no longer in the Store is already
+			//below the Roots
+			if other, err := h.Store.GetEvent(op); err == nil && other.Index() > roots[other.Creator()].Index {
+				continue
+			}
+			root.Others[e] = op
+		}
+	}
+	return roots, nil
+}
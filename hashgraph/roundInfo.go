@@ -1,9 +1,10 @@
 package hashgraph
 
 import (
-	"bytes"
-	"encoding/gob"
 	"math/big"
+
+	"github.com/babbleio/babble/codec"
+	"github.com/babbleio/babble/hashgraph/ordering"
 )
 
 type Trilean int
@@ -96,28 +97,19 @@ func (r *RoundInfo) IsDecided(witness string) bool {
 	return ok && w.Witness && w.Famous != Undefined
 }
 
+//PseudoRandomNumber is the XOR of the hashes of the round's famous witnesses
 func (r *RoundInfo) PseudoRandomNumber() *big.Int {
-	res := new(big.Int)
-	for x, e := range r.Events {
-		if e.Witness && e.Famous == True {
-			s, _ := new(big.Int).SetString(x, 16)
-			res = res.Xor(res, s)
-		}
+	res, err := ordering.PseudoRandomNumber(r.FamousWitnesses())
+	if err != nil {
+		return new(big.Int)
 	}
 	return res
 }
 
 func (r *RoundInfo) Marshal() ([]byte, error) {
-	var b bytes.Buffer
-	enc := gob.NewEncoder(&b)
-	if err := enc.Encode(r); err != nil {
-		return nil, err
-	}
-	return b.Bytes(), nil
+	return codec.Marshal(codec.Gob, r)
 }
 
 func (r *RoundInfo) Unmarshal(data []byte) error {
-	b := bytes.NewBuffer(data)
-	dec := gob.NewDecoder(b) //will read from b
-	return dec.Decode(r)
+	return codec.Unmarshal(codec.Gob, data, r)
 }
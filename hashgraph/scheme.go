@@ -0,0 +1,140 @@
+package hashgraph
+
+import (
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/babbleio/babble/crypto"
+)
+
+/*
+The Version of an EventBody selects the Scheme its Event is hashed and signed
+with. A new hash function or curve is introduced as a new version: nodes first
+learn it while still creating Events with the previous one, then switch to it,
+and finally stop accepting the previous one. During that transition window,
+Events of both versions coexist in the hashgraph, each checked with its own
+Scheme.
+
+Version 0 is SHA256 over the Gob encoding with ECDSA signatures, which is how
+Events were hashed and signed before they were versioned. It also accepts the
+signatures of Ed25519 keys, whose algorithm is told by the key of the creator.
+Versions 2 and 3 are version 0 with BLAKE2b-256 and Keccak-256 as hash function.
+They are not meant for a transition, but for networks that interoperate with
+ecosystems standardized on those primitives, and select them from their genesis
+with HashScheme: they are Genesis schemes, which a node only accepts if it uses
+them alone. Version 1 is left to the tests.
+*/
+
+//Scheme hashes and signs Events. BatchVerify is optional: the schemes whose
+//signatures can be checked faster together provide it, and VerifyEvents then
+//passes it the signatures of a batch at once, reporting which are valid.
+//ECDSA has no such shortcut, so version 0 leaves it nil.
+type Scheme struct {
+	Hash        func(data []byte) []byte
+	Sign        func(key crypto.Signer, hash []byte) (r, s *big.Int, err error)
+	Verify      func(pubKey []byte, hash []byte, r, s *big.Int) bool
+	BatchVerify func(pubKeys [][]byte, hashes [][]byte, r, s []*big.Int) []bool
+	Genesis     bool //only used by the networks started with it, never in a transition
+}
+
+func signWithKey(key crypto.Signer, hash []byte) (*big.Int, *big.Int, error) {
+	return key.Sign(hash)
+}
+
+var (
+	schemeLock sync.RWMutex
+	schemes    = map[int]Scheme{
+		0: {
+			Hash:   crypto.SHA256,
+			Sign:   signWithKey,
+			Verify: crypto.VerifySignature,
+		},
+		2: {
+			Hash:    crypto.BLAKE2b256,
+			Sign:    signWithKey,
+			Verify:  crypto.VerifySignature,
+			Genesis: true,
+		},
+		3: {
+			Hash:    crypto.Keccak256,
+			Sign:    signWithKey,
+			Verify:  crypto.VerifySignature,
+			Genesis: true,
+		},
+	}
+
+	//hashSchemes are the versions that only differ from version 0 by their
+	//hash function
+	hashSchemes = map[string]int{
+		crypto.SHA256Hash:     0,
+		crypto.BLAKE2b256Hash: 2,
+		crypto.Keccak256Hash:  3,
+	}
+)
+
+//HashScheme returns the version of the Scheme that hashes with the named
+//crypto.Hasher, for a network that uses it from its genesis
+func HashScheme(hasher string) (int, error) {
+	version, ok := hashSchemes[hasher]
+	if !ok {
+		return 0, fmt.Errorf("No event scheme hashes with %s", hasher)
+	}
+	return version, nil
+}
+
+//RegisterScheme makes a Scheme available to the Events of the given version. It
+//is meant to be called from init functions.
+func RegisterScheme(version int, s Scheme) {
+	schemeLock.Lock()
+	defer schemeLock.Unlock()
+	schemes[version] = s
+}
+
+//GetScheme returns the Scheme of a version
+func GetScheme(version int) (Scheme, error) {
+	schemeLock.RLock()
+	defer schemeLock.RUnlock()
+	s, ok := schemes[version]
+	if !ok {
+		return Scheme{}, fmt.Errorf("Unknown event scheme version %d", version)
+	}
+	return s, nil
+}
+
+//SchemeWindow is the versions a node accepts from other nodes: the registered
+//ones from Oldest on. Active is the version of the Events it creates.
+type SchemeWindow struct {
+	Active int
+	Oldest int
+}
+
+//Check returns an error if Events of the version are not accepted
+func (w SchemeWindow) Check(version int) error {
+	if version < w.Oldest {
+		return fmt.Errorf("Event scheme version %d is older than %d", version, w.Oldest)
+	}
+	s, err := GetScheme(version)
+	if err != nil {
+		return err
+	}
+	if s.Genesis && version != w.Active {
+		return fmt.Errorf("Event scheme version %d is only used by the networks started with it", version)
+	}
+	return nil
+}
+
+//Validate returns an error if the node would not accept its own Events
+func (w SchemeWindow) Validate() error {
+	if w.Oldest < 0 || w.Oldest > w.Active {
+		return fmt.Errorf("Invalid event scheme window: oldest %d, active %d", w.Oldest, w.Active)
+	}
+	s, err := GetScheme(w.Active)
+	if err != nil {
+		return err
+	}
+	if s.Genesis && w.Oldest != w.Active {
+		return fmt.Errorf("Event scheme version %d can not be used in a transition from %d", w.Active, w.Oldest)
+	}
+	return nil
+}
@@ -0,0 +1,133 @@
+package hashgraph
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"fmt"
+	"testing"
+
+	"github.com/babbleio/babble/codec"
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/crypto"
+)
+
+//version 1 of the tests is version 0 with another hash function
+func init() {
+	v0, _ := GetScheme(0)
+	RegisterScheme(1, Scheme{
+		Hash: func(data []byte) []byte {
+			hash := sha512.Sum512_256(data)
+			return hash[:]
+		},
+		Sign:   v0.Sign,
+		Verify: v0.Verify,
+	})
+}
+
+func TestSchemeVersions(t *testing.T) {
+	privateKey, _ := crypto.GenerateECDSAKey()
+	publicKeyBytes := crypto.FromECDSAPub(&privateKey.PublicKey)
+
+	hashes := [][]byte{}
+	for _, version := range []int{0, 1} {
+		event := NewEvent([][]byte{[]byte("abc")}, []string{"self", "other"}, publicKeyBytes, 1)
+		event.Body.Version = version
+		if err := event.Sign(crypto.NewECDSAKeyPair(privateKey)); err != nil {
+			t.Fatalf("Error signing Event of version %d: %s", version, err)
+		}
+		if ok, err := event.Verify(); err != nil || !ok {
+			t.Fatalf("Event of version %d should be valid, got %v, %v", version, ok, err)
+		}
+
+		raw, err := codec.Marshal(codec.Protobuf, event)
+		if err != nil {
+			t.Fatalf("Error marshalling Event: %s", err)
+		}
+		var newEvent Event
+		if err := codec.Unmarshal(codec.Protobuf, raw, &newEvent); err != nil {
+			t.Fatalf("Error unmarshalling Event: %s", err)
+		}
+		if newEvent.Body.Version != version || newEvent.Hex() != event.Hex() {
+			t.Fatalf("Event of version %d should survive Protobuf, got version %d", version, newEvent.Body.Version)
+		}
+
+		event.SetWireInfo(0, 1, 0, 0)
+		var wireEvent WireEvent
+		raw, _ = codec.Marshal(codec.Protobuf, event.ToWire())
+		if err := codec.Unmarshal(codec.Protobuf, raw, &wireEvent); err != nil {
+			t.Fatalf("Error unmarshalling WireEvent: %s", err)
+		}
+		if wireEvent.Body.Version != version {
+			t.Fatalf("WireEvent version should be %d, not %d", version, wireEvent.Body.Version)
+		}
+
+		hash, _ := event.Hash()
+		hashes = append(hashes, hash)
+	}
+	if bytes.Equal(hashes[0], hashes[1]) {
+		t.Fatalf("Versions 0 and 1 should hash differently")
+	}
+
+	event := NewEvent(nil, []string{"", ""}, publicKeyBytes, 0)
+	event.Body.Version = 99
+	if err := event.Sign(crypto.NewECDSAKeyPair(privateKey)); err == nil {
+		t.Fatalf("Signing with an unknown scheme should fail")
+	}
+	if _, err := event.Verify(); err == nil {
+		t.Fatalf("Verifying with an unknown scheme should fail")
+	}
+}
+
+func TestSchemeWindow(t *testing.T) {
+	cases := []struct {
+		window  SchemeWindow
+		valid   bool
+		accepts []int
+		rejects []int
+	}{
+		{SchemeWindow{}, true, []int{0, 1}, []int{2}},
+		{SchemeWindow{Active: 1}, true, []int{0, 1}, []int{-1}},
+		{SchemeWindow{Active: 1, Oldest: 1}, true, []int{1}, []int{0}},
+		{SchemeWindow{Active: 0, Oldest: 1}, false, nil, nil},
+		{SchemeWindow{Active: 2, Oldest: 1}, false, nil, nil},
+	}
+	for _, tc := range cases {
+		if err := tc.window.Validate(); (err == nil) != tc.valid {
+			t.Fatalf("%+v: validity should be %v, got %v", tc.window, tc.valid, err)
+		}
+		for _, v := range tc.accepts {
+			if err := tc.window.Check(v); err != nil {
+				t.Fatalf("%+v should accept version %d: %s", tc.window, v, err)
+			}
+		}
+		for _, v := range tc.rejects {
+			if err := tc.window.Check(v); err == nil {
+				t.Fatalf("%+v should reject version %d", tc.window, v)
+			}
+		}
+	}
+}
+
+func TestInsertEventScheme(t *testing.T) {
+	privateKey, _ := crypto.GenerateECDSAKey()
+	publicKeyBytes := crypto.FromECDSAPub(&privateKey.PublicKey)
+	participants := map[string]int{fmt.Sprintf("0x%X", publicKeyBytes): 0}
+
+	for _, version := range []int{0, 1} {
+		h := NewHashgraph(participants, NewInmemStore(participants, 10), nil, common.NewTestLogger(t))
+		h.SetSchemeWindow(SchemeWindow{Active: 1, Oldest: 1})
+
+		event := NewEvent(nil, []string{"", ""}, publicKeyBytes, 0)
+		event.Body.Version = version
+		if err := event.Sign(crypto.NewECDSAKeyPair(privateKey)); err != nil {
+			t.Fatalf("Error signing Event: %s", err)
+		}
+		err := h.InsertEvent(event, true)
+		if version == 0 && err == nil {
+			t.Fatalf("Event of version 0 should be rejected")
+		}
+		if version == 1 && err != nil {
+			t.Fatalf("Event of version 1 should be accepted: %s", err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package hashgraph
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"math/big"
+
+	"github.com/babbleio/babble/common"
+)
+
+/*
+The same Event is often received several times: by overlapping syncs, again
+after it was orphaned, in the Frame of a FastForward. Verify remembers the
+signatures it found valid, identified by the scheme version, the public key,
+the hash of the body and the signature, and does not check them again. The
+cache is shared by all the Hashgraphs of the process, which check the same
+signatures with the same schemes.
+*/
+
+//signatures remembered as valid
+const verifiedCacheSize = 10000
+
+var verifiedSignatures = common.NewLRU(verifiedCacheSize, nil)
+
+type signatureKey [sha256.Size]byte
+
+func newSignatureKey(version int, pubKey []byte, hash []byte, r, s *big.Int) signatureKey {
+	h := sha256.New()
+	var buf [binary.MaxVarintLen64]byte
+	for _, b := range [][]byte{pubKey, hash, r.Bytes(), s.Bytes()} {
+		h.Write(buf[:binary.PutUvarint(buf[:], uint64(len(b)))])
+		h.Write(b)
+	}
+	h.Write(buf[:binary.PutVarint(buf[:], int64(version))])
+	var key signatureKey
+	copy(key[:], h.Sum(nil))
+	return key
+}
+
+//ResetVerifiedSignatures forgets the signatures found valid, so that they are
+//checked again
+func ResetVerifiedSignatures() {
+	verifiedSignatures.Purge()
+}
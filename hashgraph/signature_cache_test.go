@@ -0,0 +1,105 @@
+package hashgraph
+
+import (
+	"math/big"
+	"sync/atomic"
+	"testing"
+
+	"github.com/babbleio/babble/crypto"
+)
+
+//version 100 of the tests checks signatures in batches and counts them
+const batchVersion = 100
+
+var batchVerified int32
+
+func init() {
+	v0, _ := GetScheme(0)
+	RegisterScheme(batchVersion, Scheme{
+		Hash:   v0.Hash,
+		Sign:   v0.Sign,
+		Verify: v0.Verify,
+		BatchVerify: func(pubKeys [][]byte, hashes [][]byte, r, s []*big.Int) []bool {
+			atomic.AddInt32(&batchVerified, int32(len(pubKeys)))
+			res := make([]bool, len(pubKeys))
+			for i := range pubKeys {
+				res[i] = v0.Verify(pubKeys[i], hashes[i], r[i], s[i])
+			}
+			return res
+		},
+	})
+}
+
+func signedEvents(t *testing.T, version int, n int) []*Event {
+	privateKey, _ := crypto.GenerateECDSAKey()
+	publicKeyBytes := crypto.FromECDSAPub(&privateKey.PublicKey)
+	events := []*Event{}
+	for i := 0; i < n; i++ {
+		event := NewEvent([][]byte{[]byte("abc")}, []string{"self", "other"}, publicKeyBytes, i)
+		event.Body.Version = version
+		if err := event.Sign(crypto.NewECDSAKeyPair(privateKey)); err != nil {
+			t.Fatal(err)
+		}
+		events = append(events, &event)
+	}
+	return events
+}
+
+func TestVerifiedSignatures(t *testing.T) {
+	ResetVerifiedSignatures()
+	event := signedEvents(t, 0, 1)[0]
+	if ok, err := event.Verify(); err != nil || !ok {
+		t.Fatalf("Event should be valid, got %v, %v", ok, err)
+	}
+	if verifiedSignatures.Len() != 1 {
+		t.Fatalf("The valid signature should be remembered, not %d", verifiedSignatures.Len())
+	}
+	if ok, _ := event.Verify(); !ok {
+		t.Fatal("Event should still be valid")
+	}
+
+	//a remembered signature does not vouch for another body or signature
+	forged := *event
+	forged.Body.Index = 10
+	if ok, _ := forged.Verify(); ok {
+		t.Fatal("Event with another body should be invalid")
+	}
+	forged = *event
+	forged.S = new(big.Int).Add(event.S, big.NewInt(1))
+	if ok, _ := forged.Verify(); ok {
+		t.Fatal("Event with another signature should be invalid")
+	}
+	forged.R = nil
+	if ok, _ := forged.Verify(); ok {
+		t.Fatal("Event without signature should be invalid")
+	}
+	if verifiedSignatures.Len() != 1 {
+		t.Fatalf("Only the valid signature should be remembered, not %d", verifiedSignatures.Len())
+	}
+}
+
+func TestVerifyEventsBatch(t *testing.T) {
+	ResetVerifiedSignatures()
+	atomic.StoreInt32(&batchVerified, 0)
+	events := signedEvents(t, batchVersion, 6)
+	events[3].S = big.NewInt(1)
+
+	VerifyEvents(events, 2)
+	for i, ev := range events {
+		if ev.verified != (i != 3) {
+			t.Fatalf("Event %d should be verified: %v, got %v", i, i != 3, ev.verified)
+		}
+	}
+	if n := atomic.LoadInt32(&batchVerified); n != 6 {
+		t.Fatalf("6 signatures should be checked in batches, not %d", n)
+	}
+
+	//the valid signatures are not checked again
+	for _, ev := range events {
+		ev.verified = false
+	}
+	VerifyEvents(events, 2)
+	if n := atomic.LoadInt32(&batchVerified); n != 7 {
+		t.Fatalf("Only the invalid signature should be checked again, got %d checks", n-6)
+	}
+}
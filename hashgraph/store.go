@@ -8,6 +8,7 @@ type Store interface {
 	ParticipantEvent(string, int) (string, error)
 	LastFrom(string) (string, bool, error)
 	Known() map[int]int
+	KnownRanges() KnownRanges
 	ConsensusEvents() []string
 	ConsensusEventsCount() int
 	AddConsensusEvent(string) error
@@ -17,5 +18,7 @@ type Store interface {
 	RoundWitnesses(int) []string
 	RoundEvents(int) int
 	GetRoot(string) (Root, error)
+	AddParticipant(string, int) error
 	Reset(map[string]Root) error
+	Close() error
 }
@@ -0,0 +1,452 @@
+/*
+Package verify checks a sequence of Frames and Blocks without running a node,
+so that third parties can audit the history of a Babble network.
+
+The genesis of a network is its list of participants, as found in peers.json.
+Given the genesis, a Verifier checks every Frame it is given:
+
+  - every Root and every Event belongs to a participant
+  - every Event is signed by its creator
+  - the first Event of a participant in a Frame sits on the participant's Root,
+    and the following ones on the previous Event of the same participant
+  - other-parents, when there is one, are Events that come earlier in the
+    Frame, or are recorded in the creator's Root when they are outside of the
+    Frame
+  - no participant ever signed two different Events with the same index, in
+    this Frame or in any Frame verified before
+
+The Roots of the first Frame are taken as they are: an audit that starts from
+genesis, where they are all base Roots, covers the whole history. The Roots of
+the next Frames must be Events verified before, so the Frames must overlap, as
+the Frames taken at every consensus round do. A Root or an Event that
+contradicts an Event seen in a previous Frame breaks the chain of custody.
+
+The participants change with PeerJoin and PeerLeave transactions. A Frame lists
+the participants with the round from which they count and, once they left, the
+round from which they stop counting. The Verifier follows these changes, but
+only accepts a new participant when it has seen the PeerJoins of a
+super-majority of the other active participants, signed by the new participant,
+in the Events it verified, and a departure when it has seen the PeerLeave of
+the participant itself or of a super-majority of the others. The pending
+proposals of the first Frame are taken as they are, like its Roots.
+
+A Block is final when a super-majority of the participants that count in its
+round-received signed it. The Verifier checks that the Blocks it is given are
+final, with the participants known from the Frames verified before, and that
+they follow each other.
+
+Frames and Blocks are usually read from a stream of Entries, as written by a
+codec.Encoder, with VerifyStream.
+*/
+package verify
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/babbleio/babble/codec"
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+//LoadGenesis reads the public keys of the participants from a peers.json file
+func LoadGenesis(path string) ([]string, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var peers []net.Peer
+	if err := json.Unmarshal(data, &peers); err != nil {
+		return nil, fmt.Errorf("Invalid genesis file %s: %s", path, err)
+	}
+	participants := make([]string, len(peers))
+	for i, p := range peers {
+		participants[i] = p.PubKeyHex
+	}
+	return participants, nil
+}
+
+//Entry is an element of the streams read by VerifyStream: a Frame or a Block
+type Entry struct {
+	Frame *hg.Frame
+	Block *hg.Block
+}
+
+//member is when a participant counts: from round round, until round until
+//excluded. -1 means from genesis and forever.
+type member struct {
+	round int
+	until int
+}
+
+//counts is true if the participant counts in round
+func (m member) counts(round int) bool {
+	return m.round <= round && (m.until < 0 || round < m.until)
+}
+
+//change identifies a membership change proposed by PeerJoins or PeerLeaves
+type change struct {
+	join   bool
+	pubKey string
+}
+
+//Verifier checks Frames and Blocks against a genesis and against the Frames it
+//has verified before
+type Verifier struct {
+	members map[string]member
+	//[creator][index] => hash of every Event and Root verified so far
+	history map[string]map[int]string
+	//[change] => participants that proposed it in the Events verified so far
+	proposals map[change]map[string]bool
+	lastBlock int
+	frames    int
+	blocks    int
+	events    int
+}
+
+//NewVerifier creates a Verifier for the network made of participants,
+//identified by their public keys in hex
+func NewVerifier(participants []string) *Verifier {
+	v := &Verifier{
+		members:   make(map[string]member),
+		history:   make(map[string]map[int]string),
+		proposals: make(map[change]map[string]bool),
+		lastBlock: -1,
+	}
+	for _, p := range participants {
+		v.members[p] = member{round: -1, until: -1}
+		v.history[p] = make(map[int]string)
+	}
+	return v
+}
+
+//Frames returns the number of Frames verified successfully
+func (v *Verifier) Frames() int {
+	return v.frames
+}
+
+//Blocks returns the number of Blocks verified successfully
+func (v *Verifier) Blocks() int {
+	return v.blocks
+}
+
+//Events returns the number of distinct Events verified successfully
+func (v *Verifier) Events() int {
+	return v.events
+}
+
+//VerifyFrame checks frame. The history of the Verifier is only updated when
+//the Frame is valid.
+func (v *Verifier) VerifyFrame(frame hg.Frame) error {
+	members, err := v.frameMembers(frame)
+	if err != nil {
+		return err
+	}
+	for p := range members {
+		if _, ok := frame.Roots[p]; !ok {
+			return fmt.Errorf("Missing Root for participant %s", p)
+		}
+	}
+	for p := range frame.Roots {
+		if _, ok := members[p]; !ok {
+			return fmt.Errorf("Root for unknown participant %s", p)
+		}
+	}
+
+	//[creator][index] => hash, for this Frame only
+	seen := make(map[string]map[int]string)
+	record := func(creator string, index int, hash string) error {
+		if index < 0 {
+			return nil
+		}
+		if h, ok := v.history[creator][index]; ok && h != hash {
+			return fmt.Errorf("Fork: %s signed %s and %s at index %d", creator, h, hash, index)
+		}
+		if h, ok := seen[creator][index]; ok && h != hash {
+			return fmt.Errorf("Fork: %s signed %s and %s at index %d", creator, h, hash, index)
+		}
+		if seen[creator] == nil {
+			seen[creator] = make(map[int]string)
+		}
+		seen[creator][index] = hash
+		return nil
+	}
+
+	for p, root := range frame.Roots {
+		if err := v.verifyRoot(p, root); err != nil {
+			return err
+		}
+		if err := record(p, root.Index, root.X); err != nil {
+			return err
+		}
+	}
+
+	inFrame := make(map[string]bool)
+	last := make(map[string]*hg.Event)
+	for i := range frame.Events {
+		ev := &frame.Events[i]
+		if err := verifyEvent(ev, members, frame.Roots, last[ev.Creator()], inFrame); err != nil {
+			return fmt.Errorf("Event %d (%s): %s", i, ev.Hex(), err)
+		}
+		if err := record(ev.Creator(), ev.Index(), ev.Hex()); err != nil {
+			return err
+		}
+		inFrame[ev.Hex()] = true
+		last[ev.Creator()] = ev
+	}
+
+	proposals := v.frameProposals(frame)
+	if err := v.verifyMembership(members, proposals); err != nil {
+		return err
+	}
+
+	for _, ev := range frame.Events {
+		if _, ok := v.history[ev.Creator()][ev.Index()]; !ok {
+			v.events++
+		}
+	}
+	v.members = members
+	v.proposals = proposals
+	for creator, indexes := range seen {
+		if v.history[creator] == nil {
+			v.history[creator] = make(map[int]string)
+		}
+		for index, hash := range indexes {
+			v.history[creator][index] = hash
+		}
+	}
+	v.frames++
+	return nil
+}
+
+//frameMembers returns the participants listed by frame, or the current ones if
+//it lists none. A participant can join or leave, but never disappear, and the
+//round from which it counts never changes.
+func (v *Verifier) frameMembers(frame hg.Frame) (map[string]member, error) {
+	if len(frame.Participants) == 0 {
+		return v.members, nil
+	}
+	members := make(map[string]member, len(frame.Participants))
+	for _, p := range frame.Participants {
+		m := member{round: p.Round, until: p.Until}
+		if old, ok := v.members[p.PubKey]; ok {
+			if m.round != old.round || (old.until >= 0 && m.until != old.until) {
+				return nil, fmt.Errorf("Rounds of participant %s changed from %d-%d to %d-%d",
+					p.PubKey, old.round, old.until, m.round, m.until)
+			}
+		} else if m.round < 0 {
+			return nil, fmt.Errorf("Participant %s joined without a join round", p.PubKey)
+		}
+		members[p.PubKey] = m
+	}
+	for p := range v.members {
+		if _, ok := members[p]; !ok {
+			return nil, fmt.Errorf("Participant %s is missing from the Frame", p)
+		}
+	}
+	return members, nil
+}
+
+//verifyRoot checks that the Root of a participant is an Event verified before,
+//unless it is the first Frame
+func (v *Verifier) verifyRoot(p string, root hg.Root) error {
+	if root.Index < 0 {
+		if root.X != "" || root.Index != -1 {
+			return fmt.Errorf("Root of %s has self-parent %s but index %d", p, root.X, root.Index)
+		}
+		return nil
+	}
+	if root.X == "" {
+		return fmt.Errorf("Root of %s has no self-parent but index %d", p, root.Index)
+	}
+	if v.frames == 0 {
+		return nil
+	}
+	if h, ok := v.history[p][root.Index]; !ok || h != root.X {
+		return fmt.Errorf("Root of %s is not an Event of the previous Frames", p)
+	}
+	return nil
+}
+
+//frameProposals returns the proposals of the Verifier with the PeerJoins and
+//PeerLeaves of the Events of frame. The pending proposals of the first Frame
+//are taken as they are.
+func (v *Verifier) frameProposals(frame hg.Frame) map[change]map[string]bool {
+	proposals := make(map[change]map[string]bool, len(v.proposals))
+	add := func(c change, proposer string) {
+		if proposals[c] == nil {
+			proposals[c] = make(map[string]bool)
+		}
+		proposals[c][proposer] = true
+	}
+	for c, proposers := range v.proposals {
+		for p := range proposers {
+			add(c, p)
+		}
+	}
+	if v.frames == 0 {
+		for _, p := range frame.Proposals {
+			for _, proposer := range p.Proposers {
+				add(change{join: p.Join, pubKey: p.PubKey}, proposer)
+			}
+		}
+	}
+	for _, ev := range frame.Events {
+		for _, tx := range ev.Transactions() {
+			if j, ok := hg.ReadPeerJoin(tx); ok && j.Verify() {
+				add(change{join: true, pubKey: j.PubKey}, ev.Creator())
+			} else if l, ok := hg.ReadPeerLeave(tx); ok {
+				add(change{pubKey: l.PubKey}, ev.Creator())
+			}
+		}
+	}
+	return proposals
+}
+
+//verifyMembership checks that the participants that joined or left since the
+//last Frame were proposed by the participants that had to
+func (v *Verifier) verifyMembership(members map[string]member, proposals map[change]map[string]bool) error {
+	for p, m := range members {
+		old, known := v.members[p]
+		switch {
+		case !known:
+			if !v.superMajority(p, proposals[change{join: true, pubKey: p}]) {
+				return fmt.Errorf("Participant %s joined without the PeerJoins of a super-majority", p)
+			}
+		case old.until < 0 && m.until >= 0:
+			proposers := proposals[change{pubKey: p}]
+			if !proposers[p] && !v.superMajority(p, proposers) {
+				return fmt.Errorf("Participant %s left without a PeerLeave of its own or of a super-majority", p)
+			}
+		}
+	}
+	return nil
+}
+
+//superMajority is true if proposers include a super-majority of the active
+//participants other than p
+func (v *Verifier) superMajority(p string, proposers map[string]bool) bool {
+	votes, others := 0, 0
+	for pk, m := range v.members {
+		if pk == p || m.until >= 0 {
+			continue
+		}
+		others++
+		if proposers[pk] {
+			votes++
+		}
+	}
+	return votes >= 2*others/3+1
+}
+
+func verifyEvent(ev *hg.Event, members map[string]member, roots map[string]hg.Root, prev *hg.Event, inFrame map[string]bool) error {
+	creator := ev.Creator()
+	if _, ok := members[creator]; !ok {
+		return fmt.Errorf("Unknown creator %s", creator)
+	}
+	if len(ev.Body.Parents) != 2 {
+		return fmt.Errorf("Event should have 2 parents, not %d", len(ev.Body.Parents))
+	}
+	if ev.R == nil || ev.S == nil {
+		return fmt.Errorf("Missing signature")
+	}
+	ok, err := ev.Verify()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return fmt.Errorf("Invalid signature")
+	}
+
+	root := roots[creator]
+	if prev == nil {
+		if ev.SelfParent() != root.X || ev.Index() != root.Index+1 {
+			return fmt.Errorf("Event does not sit on the Root of its creator")
+		}
+	} else {
+		if ev.SelfParent() != prev.Hex() || ev.Index() != prev.Index()+1 {
+			return fmt.Errorf("Self-parent should be %s", prev.Hex())
+		}
+	}
+
+	otherParent := ev.OtherParent()
+	switch {
+	case otherParent == "":
+	case inFrame[otherParent]:
+	case prev == nil && otherParent == root.Y:
+	case root.Others[ev.Hex()] == otherParent:
+	default:
+		return fmt.Errorf("Unknown other-parent %s", otherParent)
+	}
+	return nil
+}
+
+//VerifyBlock checks that block follows the last Block verified, if any, and
+//that it is signed by a super-majority of the participants that count in its
+//round-received
+func (v *Verifier) VerifyBlock(block hg.Block) error {
+	if v.blocks > 0 && block.Index != v.lastBlock+1 {
+		return fmt.Errorf("Block %d should follow Block %d", block.Index, v.lastBlock)
+	}
+
+	validators := 0
+	for _, m := range v.members {
+		if m.counts(block.RoundReceived) {
+			validators++
+		}
+	}
+	signers := 0
+	for _, sig := range block.GetSignatures() {
+		m, ok := v.members[sig.Validator]
+		if !ok || !m.counts(block.RoundReceived) {
+			return fmt.Errorf("Block %d signed by %s, which does not count in round %d",
+				block.Index, sig.Validator, block.RoundReceived)
+		}
+		ok, err := block.Verify(sig)
+		if err != nil {
+			return fmt.Errorf("Block %d: %s", block.Index, err)
+		}
+		if !ok {
+			return fmt.Errorf("Invalid signature of Block %d by %s", block.Index, sig.Validator)
+		}
+		signers++
+	}
+	if signers < 2*validators/3+1 {
+		return fmt.Errorf("Block %d is signed by %d of the %d participants of round %d",
+			block.Index, signers, validators, block.RoundReceived)
+	}
+
+	v.lastBlock = block.Index
+	v.blocks++
+	return nil
+}
+
+//VerifyStream verifies the Entries decoded from dec until the end of the
+//stream and returns the number of valid Frames and Blocks
+func (v *Verifier) VerifyStream(dec codec.Decoder) (int, error) {
+	count := 0
+	for {
+		var entry Entry
+		err := dec.Decode(&entry)
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, fmt.Errorf("Decoding Entry %d: %s", count, err)
+		}
+		switch {
+		case entry.Frame != nil:
+			err = v.VerifyFrame(*entry.Frame)
+		case entry.Block != nil:
+			err = v.VerifyBlock(*entry.Block)
+		default:
+			err = fmt.Errorf("Empty Entry")
+		}
+		if err != nil {
+			return count, fmt.Errorf("Entry %d: %s", count, err)
+		}
+		count++
+	}
+}
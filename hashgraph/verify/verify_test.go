@@ -0,0 +1,403 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/babbleio/babble/codec"
+	"github.com/babbleio/babble/crypto"
+	hg "github.com/babbleio/babble/hashgraph"
+)
+
+type participant struct {
+	key    *ecdsa.PrivateKey
+	pub    []byte
+	hex    string
+	events []hg.Event
+}
+
+func initParticipants(n int, t *testing.T) []*participant {
+	res := []*participant{}
+	for i := 0; i < n; i++ {
+		key, err := crypto.GenerateECDSAKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+		pub := crypto.FromECDSAPub(&key.PublicKey)
+		res = append(res, &participant{
+			key: key,
+			pub: pub,
+			hex: fmt.Sprintf("0x%X", pub),
+		})
+	}
+	return res
+}
+
+func (p *participant) newEvent(otherParent string, t *testing.T) hg.Event {
+	return p.newEventTxs(otherParent, [][]byte{[]byte(fmt.Sprintf("tx %d", len(p.events)))}, t)
+}
+
+func (p *participant) newEventTxs(otherParent string, txs [][]byte, t *testing.T) hg.Event {
+	selfParent := ""
+	if l := len(p.events); l > 0 {
+		selfParent = p.events[l-1].Hex()
+	}
+	ev := hg.NewEvent(txs, []string{selfParent, otherParent}, p.pub, len(p.events))
+	if err := ev.Sign(crypto.NewECDSAKeyPair(p.key)); err != nil {
+		t.Fatal(err)
+	}
+	p.events = append(p.events, ev)
+	return ev
+}
+
+func publicKeys(ps []*participant) []string {
+	res := []string{}
+	for _, p := range ps {
+		res = append(res, p.hex)
+	}
+	return res
+}
+
+/*
+Frame 0 starts from the base roots:
+
+	a0   b0   c0
+	a1 - b0
+	b1 - a1
+
+Frame 1 starts on a1, b1 and c0:
+
+	a2 - b1
+	c1 - a2
+*/
+func initFrames(t *testing.T) ([]*participant, []hg.Frame) {
+	ps := initParticipants(3, t)
+	a, b, c := ps[0], ps[1], ps[2]
+
+	frame0 := hg.Frame{Roots: map[string]hg.Root{}}
+	for _, p := range ps {
+		frame0.Roots[p.hex] = hg.NewBaseRoot()
+		frame0.Events = append(frame0.Events, p.newEvent("", t))
+	}
+	frame0.Events = append(frame0.Events,
+		a.newEvent(b.events[0].Hex(), t),
+		b.newEvent(a.events[1].Hex(), t))
+
+	frame1 := hg.Frame{
+		Roots: map[string]hg.Root{
+			a.hex: {X: a.events[1].Hex(), Y: b.events[1].Hex(), Index: 1, Others: map[string]string{}},
+			b.hex: {X: b.events[1].Hex(), Y: a.events[1].Hex(), Index: 1, Others: map[string]string{}},
+			c.hex: {X: c.events[0].Hex(), Y: "", Index: 0, Others: map[string]string{}},
+		},
+	}
+	frame1.Events = append(frame1.Events, a.newEvent(b.events[1].Hex(), t))
+	frame1.Events = append(frame1.Events, c.newEvent(a.events[2].Hex(), t))
+
+	return ps, []hg.Frame{frame0, frame1}
+}
+
+func TestVerifyFrames(t *testing.T) {
+	ps, frames := initFrames(t)
+
+	v := NewVerifier(publicKeys(ps))
+	for i, f := range frames {
+		if err := v.VerifyFrame(f); err != nil {
+			t.Fatalf("Frame %d should be valid: %s", i, err)
+		}
+	}
+	if v.Frames() != 2 || v.Events() != 7 {
+		t.Fatalf("Verifier should have seen 2 frames and 7 events, not %d and %d",
+			v.Frames(), v.Events())
+	}
+}
+
+func TestVerifyTampering(t *testing.T) {
+	ps, frames := initFrames(t)
+
+	cases := map[string]func(f *hg.Frame){
+		"signature": func(f *hg.Frame) {
+			f.Events[3].Body.Transactions = [][]byte{[]byte("forged")}
+		},
+		"root": func(f *hg.Frame) {
+			delete(f.Roots, ps[2].hex)
+		},
+		"self-parent": func(f *hg.Frame) {
+			f.Events = f.Events[1:]
+		},
+		"other-parent": func(f *hg.Frame) {
+			f.Events[3], f.Events[4] = f.Events[4], f.Events[3]
+		},
+	}
+	for name, tamper := range cases {
+		frame := hg.Frame{Roots: map[string]hg.Root{}}
+		for k, r := range frames[0].Roots {
+			frame.Roots[k] = r
+		}
+		frame.Events = append([]hg.Event{}, frames[0].Events...)
+		tamper(&frame)
+
+		if err := NewVerifier(publicKeys(ps)).VerifyFrame(frame); err == nil {
+			t.Fatalf("Frame with tampered %s should be rejected", name)
+		}
+	}
+
+	//a participant outside of the genesis
+	if err := NewVerifier(publicKeys(ps[:2])).VerifyFrame(frames[0]); err == nil {
+		t.Fatal("Frame with unknown participant should be rejected")
+	}
+}
+
+func TestVerifyFork(t *testing.T) {
+	ps, frames := initFrames(t)
+	a := ps[0]
+
+	//a signs a different Event with index 2
+	fork := hg.NewEvent([][]byte{[]byte("fork")},
+		[]string{a.events[1].Hex(), ps[1].events[1].Hex()}, a.pub, 2)
+	if err := fork.Sign(crypto.NewECDSAKeyPair(a.key)); err != nil {
+		t.Fatal(err)
+	}
+	frame2 := hg.Frame{
+		Roots: frames[1].Roots,
+		Events: []hg.Event{
+			fork,
+		},
+	}
+
+	v := NewVerifier(publicKeys(ps))
+	for i, f := range frames {
+		if err := v.VerifyFrame(f); err != nil {
+			t.Fatalf("Frame %d should be valid: %s", i, err)
+		}
+	}
+	if err := v.VerifyFrame(frame2); err == nil || !strings.Contains(err.Error(), "Fork") {
+		t.Fatalf("Fork across frames should be detected, got %v", err)
+	}
+	if v.Frames() != 2 {
+		t.Fatalf("Invalid frame should not be counted")
+	}
+}
+
+func TestVerifyStream(t *testing.T) {
+	ps, frames := initFrames(t)
+
+	dir, err := ioutil.TempDir("", "babble-verify")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	genesis := filepath.Join(dir, "peers.json")
+	peers := []string{}
+	for i, p := range ps {
+		peers = append(peers, fmt.Sprintf(`{"NetAddr":"127.0.0.1:%d","PubKeyHex":"%s"}`, 1337+i, p.hex))
+	}
+	if err := ioutil.WriteFile(genesis, []byte("["+strings.Join(peers, ",")+"]"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	participants, err := LoadGenesis(genesis)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range []codec.Codec{codec.Gob, codec.JSON} {
+		var b bytes.Buffer
+		enc := c.NewEncoder(&b)
+		for i := range frames {
+			if err := enc.Encode(Entry{Frame: &frames[i]}); err != nil {
+				t.Fatal(err)
+			}
+		}
+		block := signedBlock(0, 1, ps, t)
+		if err := enc.Encode(Entry{Block: &block}); err != nil {
+			t.Fatal(err)
+		}
+
+		count, err := NewVerifier(participants).VerifyStream(c.NewDecoder(&b))
+		if err != nil {
+			t.Fatalf("%s: %s", c.Name(), err)
+		}
+		if count != 3 {
+			t.Fatalf("%s: stream should contain 3 valid entries, not %d", c.Name(), count)
+		}
+	}
+}
+
+func TestVerifyRootAuthentication(t *testing.T) {
+	ps, frames := initFrames(t)
+	a := ps[0]
+
+	v := NewVerifier(publicKeys(ps))
+	if err := v.VerifyFrame(frames[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	//the Root of a skips Events that no Frame showed
+	frame := hg.Frame{Roots: map[string]hg.Root{}, Events: frames[1].Events[1:]}
+	for k, r := range frames[1].Roots {
+		frame.Roots[k] = r
+	}
+	frame.Roots[a.hex] = hg.Root{X: a.events[2].Hex(), Index: 2, Others: map[string]string{}}
+	if err := v.VerifyFrame(frame); err == nil || !strings.Contains(err.Error(), "previous Frames") {
+		t.Fatalf("Root that is not an Event of the previous Frames should be rejected, got %v", err)
+	}
+	if err := v.VerifyFrame(frames[1]); err != nil {
+		t.Fatal(err)
+	}
+}
+
+//signedBlock returns a Block of round round signed by signers
+func signedBlock(index, round int, signers []*participant, t *testing.T) hg.Block {
+	block := hg.NewBlock(index, round, [][]byte{[]byte("tx")})
+	for _, p := range signers {
+		sig, err := block.Sign(crypto.NewECDSAKeyPair(p.key))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := block.SetSignature(sig); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return block
+}
+
+func TestVerifyBlocks(t *testing.T) {
+	ps := initParticipants(4, t)
+	outsider := initParticipants(1, t)[0]
+
+	v := NewVerifier(publicKeys(ps))
+	if err := v.VerifyBlock(signedBlock(0, 1, ps[:3], t)); err != nil {
+		t.Fatalf("Block signed by a super-majority should be valid: %s", err)
+	}
+	if err := v.VerifyBlock(signedBlock(1, 2, ps[:2], t)); err == nil {
+		t.Fatal("Block signed by 2 of 4 participants should be rejected")
+	}
+	if err := v.VerifyBlock(signedBlock(1, 2, append(ps[:3:3], outsider), t)); err == nil {
+		t.Fatal("Block signed by a stranger should be rejected")
+	}
+	if err := v.VerifyBlock(signedBlock(2, 2, ps, t)); err == nil {
+		t.Fatal("Block that skips an index should be rejected")
+	}
+
+	forged := signedBlock(1, 2, ps, t)
+	forged.Transactions = [][]byte{[]byte("forged")}
+	if err := v.VerifyBlock(forged); err == nil {
+		t.Fatal("Block with forged transactions should be rejected")
+	}
+	if v.Blocks() != 1 {
+		t.Fatalf("Verifier should have seen 1 valid Block, not %d", v.Blocks())
+	}
+}
+
+//membershipFrame returns the Frame that follows initFrames, where a, b and c
+//create an Event with the transactions of txs, by participant
+func membershipFrame(ps []*participant, txs map[*participant][]byte, members []hg.Participant, t *testing.T) hg.Frame {
+	a, b, c := ps[0], ps[1], ps[2]
+	frame := hg.Frame{
+		Roots: map[string]hg.Root{
+			a.hex: {X: a.events[2].Hex(), Index: 2, Others: map[string]string{}},
+			b.hex: {X: b.events[1].Hex(), Index: 1, Others: map[string]string{}},
+			c.hex: {X: c.events[1].Hex(), Index: 1, Others: map[string]string{}},
+		},
+		Participants: members,
+	}
+	for _, p := range ps[:3] {
+		tx := txs[p]
+		if tx == nil {
+			tx = []byte("tx")
+		}
+		frame.Events = append(frame.Events, p.newEventTxs("", [][]byte{tx}, t))
+	}
+	for _, p := range ps[:3] {
+		p.events = p.events[:len(p.events)-1]
+	}
+	return frame
+}
+
+func TestVerifyMembership(t *testing.T) {
+	ps, frames := initFrames(t)
+	d := initParticipants(1, t)[0]
+	a, b, c := ps[0], ps[1], ps[2]
+
+	join := hg.PeerJoin{PubKey: d.hex, NetAddr: "127.0.0.1:1340"}
+	if err := join.Sign(crypto.NewECDSAKeyPair(d.key)); err != nil {
+		t.Fatal(err)
+	}
+	joinTx, err := join.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaveTx, err := hg.PeerLeave{PubKey: c.hex}.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	members := []hg.Participant{
+		{PubKey: a.hex, ID: 0, Round: -1, Until: -1},
+		{PubKey: b.hex, ID: 1, Round: -1, Until: -1},
+		{PubKey: c.hex, ID: 2, Round: -1, Until: -1},
+	}
+	withD := append(members[:3:3], hg.Participant{PubKey: d.hex, ID: 3, Round: 10, Until: -1})
+	withoutC := append(members[:2:2], hg.Participant{PubKey: c.hex, ID: 2, Round: -1, Until: 12})
+
+	verifier := func() *Verifier {
+		v := NewVerifier(publicKeys(ps))
+		for i, f := range frames {
+			if err := v.VerifyFrame(f); err != nil {
+				t.Fatalf("Frame %d should be valid: %s", i, err)
+			}
+		}
+		return v
+	}
+
+	cases := []struct {
+		name    string
+		txs     map[*participant][]byte
+		members []hg.Participant
+		valid   bool
+	}{
+		{"join", map[*participant][]byte{a: joinTx, b: joinTx, c: joinTx}, withD, true},
+		{"join without a super-majority", map[*participant][]byte{a: joinTx, b: joinTx}, withD, false},
+		{"leave", map[*participant][]byte{c: leaveTx}, withoutC, true},
+		{"eviction without a super-majority", map[*participant][]byte{a: leaveTx}, withoutC, false},
+		{"missing participant", nil, members[:2], false},
+	}
+	for _, tc := range cases {
+		frame := membershipFrame(ps, tc.txs, tc.members, t)
+		for _, p := range tc.members {
+			if p.PubKey == d.hex {
+				frame.Roots[d.hex] = hg.NewBaseRoot()
+			}
+		}
+		err := verifier().VerifyFrame(frame)
+		if tc.valid && err != nil {
+			t.Fatalf("%s should be accepted: %s", tc.name, err)
+		}
+		if !tc.valid && err == nil {
+			t.Fatalf("%s should be rejected", tc.name)
+		}
+	}
+
+	//d signs Blocks from the round it joined
+	v := verifier()
+	if err := v.VerifyFrame(membershipFrame(ps, map[*participant][]byte{a: joinTx, b: joinTx, c: joinTx}, withD, t)); err == nil {
+		t.Fatal("Frame without a Root for the new participant should be rejected")
+	}
+	frame := membershipFrame(ps, map[*participant][]byte{a: joinTx, b: joinTx, c: joinTx}, withD, t)
+	frame.Roots[d.hex] = hg.NewBaseRoot()
+	if err := v.VerifyFrame(frame); err != nil {
+		t.Fatal(err)
+	}
+	if err := v.VerifyBlock(signedBlock(0, 9, []*participant{a, b, d}, t)); err == nil {
+		t.Fatal("Block signed by a participant before it counts should be rejected")
+	}
+	if err := v.VerifyBlock(signedBlock(0, 10, []*participant{a, b, d}, t)); err != nil {
+		t.Fatalf("Block signed by a super-majority of the new participants should be valid: %s", err)
+	}
+}
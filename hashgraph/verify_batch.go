@@ -0,0 +1,120 @@
+package hashgraph
+
+import (
+	"math/big"
+	"sync"
+)
+
+/*
+Checking signatures is most of the cost of InsertEvent, and a large sync is
+inserted one Event at a time. To use more than one CPU, the Events of a batch
+are first read with ReadWireEvents, then VerifyEvents checks their signatures
+on several goroutines, and the Events are finally inserted in order.
+
+The signature of an Event covers the hashes of its parents, which may be earlier
+in the batch, so ReadWireEvents resolves them within the batch before they are
+in the Store. VerifyEvents marks the Events whose signature is valid, and
+InsertEvent only checks the others, so an invalid signature is still rejected by
+InsertEvent, after the Events before it are inserted.
+*/
+
+//ReadWireEvents reads a batch of WireEvents in topological order, whose parents
+//are either in the Store or earlier in the batch. On error, it returns the
+//Events read before it.
+func (h *Hashgraph) ReadWireEvents(wevents []WireEvent) ([]*Event, error) {
+	events := make([]*Event, 0, len(wevents))
+	batch := make(map[WireRef]string, len(wevents))
+	for _, we := range wevents {
+		ev, err := h.readWireInfo(we, batch)
+		if err != nil {
+			return events, err
+		}
+		batch[WireRef{CreatorID: we.Body.CreatorID, Index: we.Body.Index}] = ev.Hex()
+		events = append(events, ev)
+	}
+	return events, nil
+}
+
+//VerifyEvents checks the signatures of events on up to workers goroutines and
+//marks the valid ones, which InsertEvent does not check again. The signatures of
+//schemes with BatchVerify are checked together on each goroutine.
+func VerifyEvents(events []*Event, workers int) {
+	if workers > len(events) {
+		workers = len(events)
+	}
+	if workers <= 1 {
+		verifyChunk(events)
+		return
+	}
+
+	var wg sync.WaitGroup
+	size := (len(events) + workers - 1) / workers
+	for start := 0; start < len(events); start += size {
+		end := start + size
+		if end > len(events) {
+			end = len(events)
+		}
+		wg.Add(1)
+		go func(chunk []*Event) {
+			defer wg.Done()
+			verifyChunk(chunk)
+		}(events[start:end])
+	}
+	wg.Wait()
+}
+
+//verifyChunk marks the Events with a valid signature. Events that can not be
+//checked are left for InsertEvent to reject.
+func verifyChunk(events []*Event) {
+	batches := make(map[int][]*Event)
+	for _, ev := range events {
+		scheme, err := GetScheme(ev.Body.Version)
+		if err != nil {
+			continue
+		}
+		if scheme.BatchVerify == nil {
+			ev.verified, _ = ev.Verify()
+			continue
+		}
+		batches[ev.Body.Version] = append(batches[ev.Body.Version], ev)
+	}
+
+	for version, batch := range batches {
+		scheme, _ := GetScheme(version)
+		var (
+			pubKeys, hashes [][]byte
+			r, s            []*big.Int
+			keys            []signatureKey
+			unchecked       []*Event
+		)
+		for _, ev := range batch {
+			if ev.R == nil || ev.S == nil {
+				continue
+			}
+			hash, err := ev.Body.Hash()
+			if err != nil {
+				continue
+			}
+			key := newSignatureKey(version, ev.Body.Creator, hash, ev.R, ev.S)
+			if verifiedSignatures.Contains(key) {
+				ev.verified = true
+				continue
+			}
+			pubKeys = append(pubKeys, ev.Body.Creator)
+			hashes = append(hashes, hash)
+			r = append(r, ev.R)
+			s = append(s, ev.S)
+			keys = append(keys, key)
+			unchecked = append(unchecked, ev)
+		}
+		if len(unchecked) == 0 {
+			continue
+		}
+		for i, ok := range scheme.BatchVerify(pubKeys, hashes, r, s) {
+			if ok && i < len(unchecked) {
+				unchecked[i].verified = true
+				verifiedSignatures.Add(keys[i], true)
+			}
+		}
+	}
+}
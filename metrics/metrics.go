@@ -0,0 +1,294 @@
+/*
+Package metrics implements the counters, gauges and histograms that a Babble
+node exposes to Prometheus, and the text exposition format that Prometheus
+scrapes. It is a small subset of the Prometheus client library, without its
+dependencies.
+*/
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+//ContentType is the content type of the text exposition format
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+//collector is a metric of the Registry
+type collector interface {
+	write(w io.Writer)
+}
+
+//Registry holds metrics and serves them to Prometheus as an http.Handler
+type Registry struct {
+	l          sync.Mutex
+	names      map[string]bool
+	collectors []collector
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		names: make(map[string]bool),
+	}
+}
+
+//register panics if a metric is registered twice, which is a programming error
+func (r *Registry) register(name string, c collector) {
+	r.l.Lock()
+	defer r.l.Unlock()
+	if r.names[name] {
+		panic(fmt.Sprintf("Metric %s registered twice", name))
+	}
+	r.names[name] = true
+	r.collectors = append(r.collectors, c)
+}
+
+//NewCounter registers a Counter
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{desc: desc{name, help, "counter"}}
+	r.register(name, c)
+	return c
+}
+
+//NewCounterVec registers a CounterVec whose counters differ by the value of
+//label
+func (r *Registry) NewCounterVec(name, help, label string) *CounterVec {
+	c := &CounterVec{
+		desc:     desc{name, help, "counter"},
+		label:    label,
+		counters: make(map[string]*Counter),
+	}
+	r.register(name, c)
+	return c
+}
+
+//NewGauge registers a Gauge
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{desc: desc{name, help, "gauge"}}
+	r.register(name, g)
+	return g
+}
+
+//NewGaugeFunc registers a gauge whose value is computed by f on every scrape
+func (r *Registry) NewGaugeFunc(name, help string, f func() float64) {
+	r.register(name, &gaugeFunc{desc{name, help, "gauge"}, f})
+}
+
+//NewHistogram registers a Histogram with the given upper bounds of buckets, in
+//increasing order
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := &Histogram{
+		desc:    desc{name, help, "histogram"},
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+	r.register(name, h)
+	return h
+}
+
+//Write writes all the metrics in the text exposition format
+func (r *Registry) Write(w io.Writer) error {
+	r.l.Lock()
+	collectors := append([]collector{}, r.collectors...)
+	r.l.Unlock()
+
+	var buf bytes.Buffer
+	for _, c := range collectors {
+		c.write(&buf)
+	}
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+	r.Write(w)
+}
+
+//ExponentialBuckets returns count buckets, the first of which is start and
+//each following one factor times larger than the previous
+func ExponentialBuckets(start, factor float64, count int) []float64 {
+	buckets := make([]float64, count)
+	for i := range buckets {
+		buckets[i] = start
+		start *= factor
+	}
+	return buckets
+}
+
+type desc struct {
+	name, help, kind string
+}
+
+func (d desc) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.name, d.help, d.name, d.kind)
+}
+
+//Counter is a value that only goes up
+type Counter struct {
+	desc
+	l sync.Mutex
+	v float64
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+//Add adds v, which must not be negative
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+	c.l.Lock()
+	c.v += v
+	c.l.Unlock()
+}
+
+func (c *Counter) Value() float64 {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return c.v
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", c.name, formatFloat(c.Value()))
+}
+
+//CounterVec is a set of counters of the same metric, one per value of a label
+type CounterVec struct {
+	desc
+	label    string
+	l        sync.Mutex
+	counters map[string]*Counter
+}
+
+//With returns the counter of a value of the label, creating it if needed
+func (c *CounterVec) With(value string) *Counter {
+	c.l.Lock()
+	defer c.l.Unlock()
+	counter, ok := c.counters[value]
+	if !ok {
+		counter = &Counter{}
+		c.counters[value] = counter
+	}
+	return counter
+}
+
+func (c *CounterVec) write(w io.Writer) {
+	c.writeHeader(w)
+	c.l.Lock()
+	values := make([]string, 0, len(c.counters))
+	for v := range c.counters {
+		values = append(values, v)
+	}
+	c.l.Unlock()
+	sort.Strings(values)
+	for _, v := range values {
+		fmt.Fprintf(w, "%s{%s=%q} %s\n", c.name, c.label, v, formatFloat(c.With(v).Value()))
+	}
+}
+
+//Gauge is a value that goes up and down
+type Gauge struct {
+	desc
+	l sync.Mutex
+	v float64
+}
+
+func (g *Gauge) Set(v float64) {
+	g.l.Lock()
+	g.v = v
+	g.l.Unlock()
+}
+
+func (g *Gauge) Add(v float64) {
+	g.l.Lock()
+	g.v += v
+	g.l.Unlock()
+}
+
+func (g *Gauge) Value() float64 {
+	g.l.Lock()
+	defer g.l.Unlock()
+	return g.v
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.Value()))
+}
+
+type gaugeFunc struct {
+	desc
+	f func() float64
+}
+
+func (g *gaugeFunc) write(w io.Writer) {
+	g.writeHeader(w)
+	fmt.Fprintf(w, "%s %s\n", g.name, formatFloat(g.f()))
+}
+
+//Histogram counts observations in buckets
+type Histogram struct {
+	desc
+	buckets []float64
+	l       sync.Mutex
+	counts  []uint64 //observations in each bucket, not cumulated
+	count   uint64
+	sum     float64
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.l.Lock()
+	defer h.l.Unlock()
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+//Count returns the number of observations
+func (h *Histogram) Count() uint64 {
+	h.l.Lock()
+	defer h.l.Unlock()
+	return h.count
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.l.Lock()
+	counts := append([]uint64{}, h.counts...)
+	count, sum := h.count, h.sum
+	h.l.Unlock()
+
+	h.writeHeader(w)
+	var cumulated uint64
+	for i, b := range h.buckets {
+		cumulated += counts[i]
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", h.name, formatFloat(b), cumulated)
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", h.name, count)
+	fmt.Fprintf(w, "%s_sum %s\n", h.name, formatFloat(sum))
+	fmt.Fprintf(w, "%s_count %d\n", h.name, count)
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("txs_total", "Transactions")
+	vec := r.NewCounterVec("errors_total", "Errors", "rpc")
+	h := r.NewHistogram("latency_seconds", "Latency", []float64{0.1, 1})
+	r.NewGaugeFunc("round", "Round", func() float64 { return 7 })
+
+	c.Add(2)
+	c.Inc()
+	c.Add(-1) //counters do not go down
+	vec.With("sync").Inc()
+	vec.With("fast_forward").Add(2)
+	h.Observe(0.05)
+	h.Observe(0.1)
+	h.Observe(0.5)
+	h.Observe(3)
+
+	var buf bytes.Buffer
+	if err := r.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	expected := `# HELP txs_total Transactions
+# TYPE txs_total counter
+txs_total 3
+# HELP errors_total Errors
+# TYPE errors_total counter
+errors_total{rpc="fast_forward"} 2
+errors_total{rpc="sync"} 1
+# HELP latency_seconds Latency
+# TYPE latency_seconds histogram
+latency_seconds_bucket{le="0.1"} 2
+latency_seconds_bucket{le="1"} 3
+latency_seconds_bucket{le="+Inf"} 4
+latency_seconds_sum 3.65
+latency_seconds_count 4
+# HELP round Round
+# TYPE round gauge
+round 7
+`
+	if buf.String() != expected {
+		t.Fatalf("Metrics should be\n%s\nnot\n%s", expected, buf.String())
+	}
+
+	rec := httptest.NewRecorder()
+	r.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	if ct := rec.Header().Get("Content-Type"); ct != ContentType {
+		t.Fatalf("Content-Type should be %s, not %s", ContentType, ct)
+	}
+	if !strings.Contains(rec.Body.String(), "txs_total 3") {
+		t.Fatalf("The handler should serve the metrics")
+	}
+}
+
+func TestRegisterTwice(t *testing.T) {
+	r := NewRegistry()
+	r.NewCounter("txs_total", "Transactions")
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("Registering a metric twice should panic")
+		}
+	}()
+	r.NewGauge("txs_total", "Transactions")
+}
@@ -0,0 +1,264 @@
+package net
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	addressBookPath = "address_book.json"
+)
+
+// Sources of the addresses in an AddressBook
+const (
+	SourceParticipants = "participants" // initial participants of the node
+	SourceJoin         = "join"
+	SourceDiscovery    = "discovery"
+)
+
+// AddressBookEntry is what a node learned about a peer
+type AddressBookEntry struct {
+	PubKeyHex   string
+	NetAddr     string
+	Source      string            // where NetAddr was learned from
+	AddrUpdated time.Time         // when NetAddr was learned
+	LastSeen    time.Time         // last request that got a response
+	LastFailure time.Time         // last request that did not
+	Failures    int               // failed requests since LastSeen
+	Removed     bool              // the peer is not a participant anymore
+	Metadata    map[string]string `json:",omitempty"`
+}
+
+// AddressBook remembers the peers a node learned about, from the peers file,
+// from the cluster when they join, or from discovery, along with their
+// reachability. It is kept in a JSON file of the data directory so that a
+// restarted node knows the current addresses of its peers even if the peers
+// file is outdated.
+//
+// Changes are only written to disk by Save, which the node calls periodically.
+type AddressBook struct {
+	l       sync.Mutex
+	path    string
+	entries map[string]*AddressBookEntry // [PubKeyHex] => entry
+	dirty   bool
+	now     func() time.Time
+}
+
+// NewAddressBook loads the address book of the data directory base, or
+// creates an empty one if there is none yet.
+func NewAddressBook(base string) (*AddressBook, error) {
+	b := &AddressBook{
+		path:    filepath.Join(base, addressBookPath),
+		entries: make(map[string]*AddressBookEntry),
+		now:     time.Now,
+	}
+
+	buf, err := ioutil.ReadFile(b.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if len(buf) == 0 {
+		return b, nil
+	}
+
+	var entries []AddressBookEntry
+	if err := json.NewDecoder(bytes.NewReader(buf)).Decode(&entries); err != nil {
+		return nil, err
+	}
+	for i := range entries {
+		b.entries[entries[i].PubKeyHex] = &entries[i]
+	}
+	return b, nil
+}
+
+// Learn records the address of a peer. The reachability history of the peer
+// is reset if its address changed.
+func (b *AddressBook) Learn(peer Peer, source string) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	e, ok := b.entries[peer.PubKeyHex]
+	if !ok {
+		e = &AddressBookEntry{PubKeyHex: peer.PubKeyHex}
+		b.entries[peer.PubKeyHex] = e
+	}
+	if e.NetAddr != peer.NetAddr {
+		e.NetAddr = peer.NetAddr
+		e.Source = source
+		e.AddrUpdated = b.now().UTC()
+		e.LastSeen = time.Time{}
+		e.LastFailure = time.Time{}
+		e.Failures = 0
+		b.dirty = true
+	}
+	if e.Removed {
+		e.Removed = false
+		b.dirty = true
+	}
+}
+
+// Remove marks a peer that is not a participant anymore. Its entry is kept
+// for the record.
+func (b *AddressBook) Remove(pubKey string) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if e, ok := b.entries[pubKey]; ok && !e.Removed {
+		e.Removed = true
+		b.dirty = true
+	}
+}
+
+// Contacted records the outcome of a request sent to the peer at addr
+func (b *AddressBook) Contacted(addr string, err error) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	for _, e := range b.entries {
+		if e.NetAddr != addr {
+			continue
+		}
+		if err != nil {
+			e.Failures++
+			e.LastFailure = b.now().UTC()
+		} else {
+			e.Failures = 0
+			e.LastSeen = b.now().UTC()
+		}
+		b.dirty = true
+	}
+}
+
+// SetMetadata attaches a value to a known peer
+func (b *AddressBook) SetMetadata(pubKey, key, value string) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	e, ok := b.entries[pubKey]
+	if !ok {
+		return
+	}
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]string)
+	}
+	if e.Metadata[key] != value {
+		e.Metadata[key] = value
+		b.dirty = true
+	}
+}
+
+// Entry returns what is known about the peer with public key pubKey
+func (b *AddressBook) Entry(pubKey string) (AddressBookEntry, bool) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	e, ok := b.entries[pubKey]
+	if !ok {
+		return AddressBookEntry{}, false
+	}
+	return copyEntry(e), true
+}
+
+// Entries returns all the entries, sorted by public key
+func (b *AddressBook) Entries() []AddressBookEntry {
+	b.l.Lock()
+	defer b.l.Unlock()
+	return b.sortedEntries()
+}
+
+// Resolve returns peers with the addresses of the address book, where it
+// learned them after since, typically the modification time of the peers file.
+func (b *AddressBook) Resolve(peers []Peer, since time.Time) []Peer {
+	b.l.Lock()
+	defer b.l.Unlock()
+	res := make([]Peer, len(peers))
+	for i, p := range peers {
+		res[i] = p
+		if e, ok := b.entries[p.PubKeyHex]; ok && e.NetAddr != "" && e.AddrUpdated.After(since) {
+			res[i].NetAddr = e.NetAddr
+		}
+	}
+	return res
+}
+
+// Peers implements the PeerStore interface. It returns the peers that were not
+// removed.
+func (b *AddressBook) Peers() ([]Peer, error) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	peers := []Peer{}
+	for _, e := range b.sortedEntries() {
+		if !e.Removed {
+			peers = append(peers, Peer{NetAddr: e.NetAddr, PubKeyHex: e.PubKeyHex})
+		}
+	}
+	return peers, nil
+}
+
+// SetPeers implements the PeerStore interface. The peers that are not in the
+// list are marked as removed.
+func (b *AddressBook) SetPeers(peers []Peer) error {
+	keep := make(map[string]bool)
+	for _, p := range peers {
+		b.Learn(p, "")
+		keep[p.PubKeyHex] = true
+	}
+	for _, e := range b.Entries() {
+		if !keep[e.PubKeyHex] {
+			b.Remove(e.PubKeyHex)
+		}
+	}
+	return b.Save()
+}
+
+// Save writes the address book to disk if it changed since the last Save
+func (b *AddressBook) Save() error {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if !b.dirty {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(b.sortedEntries()); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that a crash never leaves a
+	// truncated address book
+	tmp := b.path + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, b.path); err != nil {
+		return err
+	}
+	b.dirty = false
+	return nil
+}
+
+// must be called with b.l locked
+func (b *AddressBook) sortedEntries() []AddressBookEntry {
+	entries := make([]AddressBookEntry, 0, len(b.entries))
+	for _, e := range b.entries {
+		entries = append(entries, copyEntry(e))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].PubKeyHex < entries[j].PubKeyHex
+	})
+	return entries
+}
+
+func copyEntry(e *AddressBookEntry) AddressBookEntry {
+	c := *e
+	if e.Metadata != nil {
+		c.Metadata = make(map[string]string, len(e.Metadata))
+		for k, v := range e.Metadata {
+			c.Metadata[k] = v
+		}
+	}
+	return c
+}
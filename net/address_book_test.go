@@ -0,0 +1,75 @@
+package net
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestAddressBook(t *testing.T) {
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	book, err := NewAddressBook(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	book.now = func() time.Time { return now }
+
+	book.Learn(Peer{NetAddr: "addr0", PubKeyHex: "0x00"}, SourceParticipants)
+	book.Learn(Peer{NetAddr: "addr1", PubKeyHex: "0x01"}, SourceParticipants)
+	book.Contacted("addr0", nil)
+	book.Contacted("addr1", errors.New("timeout"))
+	book.Contacted("addr1", errors.New("timeout"))
+	book.SetMetadata("0x00", "zone", "eu")
+
+	//a peer moved: its history starts over
+	now = now.Add(time.Hour)
+	book.Learn(Peer{NetAddr: "addr2", PubKeyHex: "0x00"}, SourceDiscovery)
+	book.Remove("0x01")
+
+	if err := book.Save(); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := NewAddressBook(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e, ok := loaded.Entry("0x00")
+	if !ok {
+		t.Fatal("0x00 should be in the address book")
+	}
+	if e.NetAddr != "addr2" || e.Source != SourceDiscovery || !e.AddrUpdated.Equal(now) {
+		t.Fatalf("0x00 should be at addr2 since %s, from discovery: %+v", now, e)
+	}
+	if !e.LastSeen.IsZero() || e.Metadata["zone"] != "eu" {
+		t.Fatalf("0x00 should have no history but keep its metadata: %+v", e)
+	}
+	e, _ = loaded.Entry("0x01")
+	if e.Failures != 2 || !e.Removed {
+		t.Fatalf("0x01 should be removed after 2 failures: %+v", e)
+	}
+
+	peers, _ := loaded.Peers()
+	if len(peers) != 1 || peers[0].NetAddr != "addr2" {
+		t.Fatalf("Peers should only list 0x00 at addr2, not %v", peers)
+	}
+
+	//only the addresses learned after the peers file was written are used
+	file := []Peer{{NetAddr: "addr0", PubKeyHex: "0x00"}, {NetAddr: "addr3", PubKeyHex: "0x03"}}
+	resolved := loaded.Resolve(file, now.Add(-time.Minute))
+	if resolved[0].NetAddr != "addr2" || resolved[1].NetAddr != "addr3" {
+		t.Fatalf("Resolved peers should be addr2 and addr3, not %v", resolved)
+	}
+	resolved = loaded.Resolve(file, now.Add(time.Minute))
+	if resolved[0].NetAddr != "addr0" {
+		t.Fatalf("A newer peers file should win, not %v", resolved)
+	}
+}
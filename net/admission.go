@@ -0,0 +1,147 @@
+package net
+
+import (
+	"crypto/tls"
+	"errors"
+	"net"
+)
+
+/*
+SetAdmissionPolicy lets embedders decide which inbound connections a
+NetworkTransport serves, with rules that do not belong in babble: geo-fencing,
+business hours, an external allowlist service, etc. It applies on top of TLS,
+see PeerTLSConfig, and of the rate limits.
+
+The policy is asked once per connection, or per stream of a mux session, when
+its first request is decoded, so that it sees the address the peer claims in
+the From field of its requests next to the IP address of the connection. With
+TLS, it also gets the babble key of the peer certificate, which unlike the
+claimed address was proven by the handshake. The first request of a refused
+connection is answered with ErrNotAdmitted and ErrorNotAdmitted, and the
+connection is closed.
+
+The policy is called from the goroutines that serve the connections, possibly
+concurrently, and delays the requests for as long as it takes. Policies that
+query external services should cache their answers.
+*/
+
+// ErrNotAdmitted is the error of the requests on the connections refused by
+// the admission policy of the responder.
+var ErrNotAdmitted = errors.New("Connection not admitted")
+
+// errNotAdmitted closes a refused connection once the refusal is sent
+var errNotAdmitted = errors.New("connection not admitted")
+
+// InboundConn describes an inbound connection to an AdmissionPolicy.
+type InboundConn struct {
+	RemoteAddr string // address of the remote end of the connection
+	Host       string // IP address of the remote end, which identifies the peer for rate limits
+	From       string // address the peer claims in its first request
+	PubKey     string // babble key, in hex, of the TLS certificate of the peer. Empty without TLS
+	RPC        string // type of the first request: sync, eager_sync, fast_forward, join or fetch
+}
+
+// AdmissionPolicy decides whether the transport serves an inbound connection.
+type AdmissionPolicy interface {
+	// Admit returns nil to serve conn, or the reason why it is refused.
+	Admit(conn InboundConn) error
+}
+
+// AdmissionFunc is an AdmissionPolicy implemented by a function.
+type AdmissionFunc func(conn InboundConn) error
+
+// Admit implements AdmissionPolicy.
+func (f AdmissionFunc) Admit(conn InboundConn) error {
+	return f(conn)
+}
+
+// SetAdmissionPolicy applies policy to the connections accepted from now on.
+// refused is called with every refused connection and the reason; it may be
+// nil. A nil policy admits every connection.
+func (n *NetworkTransport) SetAdmissionPolicy(policy AdmissionPolicy, refused func(conn InboundConn, err error)) {
+	n.admissionLock.Lock()
+	defer n.admissionLock.Unlock()
+	n.admission = policy
+	n.refused = refused
+}
+
+// admit asks the admission policy whether to serve the connection of in, whose
+// first request is cmd.
+func (n *NetworkTransport) admit(in *inboundConn, rpcType uint8, cmd interface{}) error {
+	n.admissionLock.Lock()
+	policy, refused := n.admission, n.refused
+	n.admissionLock.Unlock()
+	if policy == nil {
+		return nil
+	}
+
+	conn := InboundConn{
+		Host:   in.peer,
+		From:   requestFrom(cmd),
+		PubKey: certificateKey(in.conn),
+		RPC:    rpcNames[rpcType],
+	}
+	if addr := in.conn.RemoteAddr(); addr != nil {
+		conn.RemoteAddr = addr.String()
+	}
+	err := policy.Admit(conn)
+	if err != nil && refused != nil {
+		refused(conn, err)
+	}
+	return err
+}
+
+// requestFrom returns the From field of a request.
+func requestFrom(cmd interface{}) string {
+	switch req := cmd.(type) {
+	case *SyncRequest:
+		return req.From
+	case *EagerSyncRequest:
+		return req.From
+	case *FastForwardRequest:
+		return req.From
+	case *JoinRequest:
+		return req.From
+	case *FetchRequest:
+		return req.From
+	}
+	return ""
+}
+
+// certificateKey returns the babble key of the certificate presented by the
+// other end of a TLS connection, or of the connection of a mux stream. It is
+// empty for other connections.
+func certificateKey(conn net.Conn) string {
+	if stream, ok := conn.(*muxStream); ok {
+		conn = stream.session.conn
+	}
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return ""
+	}
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return ""
+	}
+	key, err := CertificatePubKey(certs[0].Raw)
+	if err != nil {
+		return ""
+	}
+	return key
+}
+
+// refusedResponse returns the response to a request on a refused connection.
+func refusedResponse(rpcType uint8) interface{} {
+	switch rpcType {
+	case rpcSync:
+		return &SyncResponse{ErrorCode: ErrorNotAdmitted}
+	case rpcEagerSync:
+		return &EagerSyncResponse{ErrorCode: ErrorNotAdmitted}
+	case rpcFastForward:
+		return &FastForwardResponse{}
+	case rpcJoin:
+		return &JoinResponse{}
+	default:
+		return &FetchResponse{}
+	}
+}
@@ -0,0 +1,110 @@
+package net
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/crypto"
+)
+
+func TestNetworkTransport_Admission(t *testing.T) {
+	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans1.Close()
+	refused := make(chan InboundConn, 1)
+	trans1.SetAdmissionPolicy(AdmissionFunc(func(conn InboundConn) error {
+		if conn.From != "A" {
+			return errors.New("unknown peer")
+		}
+		return nil
+	}), func(conn InboundConn, err error) { refused <- conn })
+
+	rpcCh := trans1.Consumer()
+	go func() {
+		for {
+			select {
+			case rpc := <-rpcCh:
+				rpc.Respond(&SyncResponse{From: "C"}, nil)
+			case <-trans1.shutdownCh:
+				return
+			}
+		}
+	}()
+
+	transA, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer transA.Close()
+	var resp SyncResponse
+	for i := 0; i < 2; i++ {
+		if err := transA.Sync(context.Background(), trans1.LocalAddr(), &SyncRequest{From: "A"}, &resp); err != nil {
+			t.Fatalf("Sync %d of an admitted peer should succeed: %v", i, err)
+		}
+	}
+
+	transB, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer transB.Close()
+	resp = SyncResponse{}
+	err = transB.Sync(context.Background(), trans1.LocalAddr(), &SyncRequest{From: "B"}, &resp)
+	if err == nil || err.Error() != ErrNotAdmitted.Error() {
+		t.Fatalf("The Sync of a refused peer should fail with ErrNotAdmitted, got %v", err)
+	}
+	if resp.ErrorCode != ErrorNotAdmitted {
+		t.Fatalf("The Sync of a refused peer should have ErrorNotAdmitted, not %v", resp.ErrorCode)
+	}
+	select {
+	case conn := <-refused:
+		if conn.From != "B" || conn.Host != "127.0.0.1" || conn.RPC != "sync" || conn.PubKey != "" {
+			t.Fatalf("The refused connection should be a sync from B, got %+v", conn)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("The refused connection should be reported")
+	}
+
+	//without a policy, every connection is admitted again
+	trans1.SetAdmissionPolicy(nil, nil)
+	if err := transB.Sync(context.Background(), trans1.LocalAddr(), &SyncRequest{From: "B"}, &resp); err != nil {
+		t.Fatalf("Sync should succeed without a policy: %v", err)
+	}
+}
+
+func TestAdmissionPubKey(t *testing.T) {
+	keys := []*ecdsa.PrivateKey{}
+	for i := 0; i < 2; i++ {
+		key, _ := crypto.GenerateECDSAKey()
+		keys = append(keys, key)
+	}
+	trans0 := peerTLSTransport(t, keys[0], keys[1])
+	defer trans0.Close()
+	trans1 := peerTLSTransport(t, keys[1], keys[0])
+	defer trans1.Close()
+
+	admitted := make(chan InboundConn, 1)
+	trans0.SetAdmissionPolicy(AdmissionFunc(func(conn InboundConn) error {
+		admitted <- conn
+		return nil
+	}), nil)
+	go func() {
+		for rpc := range trans0.Consumer() {
+			rpc.Respond(&SyncResponse{From: "0"}, nil)
+		}
+	}()
+
+	var out SyncResponse
+	if err := trans1.Sync(context.Background(), trans0.LocalAddr(), &SyncRequest{From: "1"}, &out); err != nil {
+		t.Fatal(err)
+	}
+	if conn := <-admitted; conn.PubKey != pubKeyHex(keys[1]) {
+		t.Fatalf("The policy should get the key of the peer certificate, got %q", conn.PubKey)
+	}
+}
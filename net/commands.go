@@ -1,40 +1,217 @@
 package net
 
-import "github.com/babbleio/babble/hashgraph"
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/babbleio/babble/hashgraph"
+)
+
+//SyncLimits are the preferred sync limits of a node. Zero values mean no
+//preference.
+type SyncLimits struct {
+	Events int //max number of events in a single sync
+	Bytes  int //max size of the events in a single sync
+}
+
+//Capabilities are optional features supported by a node. They are advertised
+//in every SyncRequest and SyncResponse, and a feature is only used between two
+//peers that both advertise it, so that nodes of different versions can gossip
+//during a rolling upgrade. Nodes that predate them advertise none.
+type Capabilities uint32
+
+const (
+	CapCompression    Capabilities = 1 << iota //compressed Event payloads
+	CapProtobuf                                //protobuf encoding of Events
+	CapFastSyncChunks                          //Frames sent in chunks by FastForward
+	CapObserver                                //the node does not create Events
+	CapFetch                                   //serves FetchRequests
+	CapMultiplex                               //serves mux sessions
+	CapDictionary                              //compressed Events with EventsDictionaryV1
+	CapPushBatches                             //EagerSyncs continued by the next request
+	CapArchive                                 //the node keeps the whole hashgraph
+)
+
+var capabilityNames = []string{"compression", "protobuf", "fast-sync-chunks", "observer", "fetch", "multiplex", "event-dictionary", "push-batches", "archive"}
+
+//Has is true if all the flags of f are set
+func (c Capabilities) Has(f Capabilities) bool {
+	return c&f == f
+}
+
+func (c Capabilities) String() string {
+	names := []string{}
+	for i, name := range capabilityNames {
+		if c.Has(1 << uint(i)) {
+			names = append(names, name)
+		}
+	}
+	if unknown := c &^ (1<<uint(len(capabilityNames)) - 1); unknown != 0 {
+		names = append(names, fmt.Sprintf("0x%x", uint32(unknown)))
+	}
+	if len(names) == 0 {
+		return "none"
+	}
+	return strings.Join(names, ",")
+}
+
+//PeerLink is what a node measured about its connection to a peer
+type PeerLink struct {
+	LastOutbound time.Time     //last successful request to the peer
+	LastInbound  time.Time     //last request received from the peer
+	RTT          time.Duration //smoothed round-trip time of requests to the peer
+	Failures     int           //consecutive failed requests to the peer
+}
+
+//Reachable is true if the last request to the peer succeeded
+func (l PeerLink) Reachable() bool {
+	return !l.LastOutbound.IsZero() && l.Failures == 0
+}
+
+//ConnectivityRow maps the addresses of the peers of a node to its links
+type ConnectivityRow map[string]PeerLink
+
+//ErrorCode tells the requester of a Sync or EagerSync why it was refused or
+//failed, so that it can decide whether and when to try again. The error message
+//still travels with the response; errors that fit none of the codes, like
+//successful responses, have ErrorNone.
+type ErrorCode uint8
+
+const (
+	ErrorNone         ErrorCode = iota
+	ErrorBusy                   //the responder is not ready or has too many pending syncs
+	ErrorTooFarBehind           //the requester is over the SyncLimit or misses pruned Events
+	ErrorNotInPeerSet           //the responder does not gossip with the requester (yet)
+	ErrorStore                  //the responder failed to read or write its store
+	ErrorRateLimited            //the requester sent more requests than the responder accepts
+	ErrorStopping               //the responder is shutting down
+	ErrorSuspended              //the responder was suspended by its operator
+	ErrorNotAdmitted            //the admission policy of the responder refused the connection
+)
+
+var errorCodeNames = []string{"none", "busy", "too-far-behind", "not-in-peerset", "store-error", "rate-limited", "stopping", "suspended", "not-admitted"}
+
+func (c ErrorCode) String() string {
+	if int(c) < len(errorCodeNames) {
+		return errorCodeNames[c]
+	}
+	return fmt.Sprintf("error-%d", uint8(c))
+}
 
 type SyncRequest struct {
-	From  string
-	Known map[int]int
+	From         string
+	Known        map[int]int
+	Limits       SyncLimits      //limits the requester wants the response to respect
+	Connectivity ConnectivityRow //connectivity of the requester, if shared
+	Capabilities Capabilities    //features supported by the requester
+	Zone         string          //zone or region of the requester
+
+	BlockSignatures []hashgraph.BlockSignature //signatures of the last Blocks known to the requester
+	Compress        bool                       //the requester accepts CompressedEvents
 }
 
 type SyncResponse struct {
-	From      string
-	SyncLimit bool
-	Events    []hashgraph.WireEvent
-	Known     map[int]int
+	From         string
+	SyncLimit    bool
+	Events       []hashgraph.WireEvent
+	Known        map[int]int
+	Ranges       hashgraph.KnownRanges //Events held by the responder, where its history starts
+	Limits       SyncLimits            //limits the responder wants future requests to respect
+	Connectivity ConnectivityRow       //connectivity of the responder, if shared
+	Capabilities Capabilities          //features supported by the responder
+	Zone         string                //zone or region of the responder
+
+	BlockSignatures  []hashgraph.BlockSignature //signatures of the last Blocks known to the responder
+	CompressedEvents []byte                     //Events compressed with CompressEvents, instead of Events
+	ErrorCode        ErrorCode                  //why the request failed, or ErrorTooFarBehind with SyncLimit
+	Dictionary       uint32                     //preset dictionary of CompressedEvents
+	Truncated        bool                       //Events were cut to the limits, the requester should sync again
 }
 
 //++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
 
 type EagerSyncRequest struct {
-	From   string
-	Events []hashgraph.WireEvent
+	From             string
+	Events           []hashgraph.WireEvent
+	CompressedEvents []byte //Events compressed with CompressEvents, instead of Events
+	Dictionary       uint32 //preset dictionary of CompressedEvents
+	More             bool   //more Events of the same push follow in the next request
 }
 
 type EagerSyncResponse struct {
+	From      string
+	Success   bool
+	ErrorCode ErrorCode //why the request failed
+}
+
+//++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+//FetchRequest asks for specific Events and their ancestors that the requester
+//does not know. Events received on the wire only reference their parents by
+//creator id and index, so missing parents are requested that way, while other
+//Events can be requested by hash.
+type FetchRequest struct {
 	From    string
-	Success bool
+	Hashes  []string
+	Parents []hashgraph.WireRef
+	Known   map[int]int //what the requester knows, to leave out of the ancestors
+}
+
+//FetchResponse holds the Events found, in topological order. Requested Events
+//that the responder does not have are left out.
+type FetchResponse struct {
+	From   string
+	Events []hashgraph.WireEvent
 }
 
 //++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
 
 type FastForwardRequest struct {
-	From string
+	From      string
+	Probe     bool   //only asks for the Round, to pick the peer to fast-forward from
+	ChunkSize int    //max bytes of the chunk to send, 0 to get the Frame and the Snapshot whole
+	Transfer  string //chunked transfer to continue. Empty starts a new one
+	Offset    int    //offset in the transfer of the chunk to send
 }
 
 type FastForwardResponse struct {
-	From  string
-	Head  string
-	Seq   int
-	Frame hashgraph.Frame
+	From     string
+	Head     string
+	Seq      int
+	Frame    hashgraph.Frame
+	Block    hashgraph.Block //last Block committed by the responder before the Frame
+	Snapshot []byte          //state of the App after Block. nil if there is no Block yet
+	Round    int             //last consensus round of the responder, -1 if none
+	Transfer string          //ID of the chunked transfer of Frame, Block and Snapshot, empty if sent whole
+	Size     int             //bytes of the whole transfer
+	Offset   int             //offset of Chunk in the transfer
+	Chunk    []byte
+	Checksum uint32       //CRC-32C of Chunk
+	Dedup    []DedupEntry //commit deduplication window of the responder at the Frame
+}
+
+//DedupEntry is a transaction in the commit deduplication window of a node, see
+//node/commit_dedup.go
+type DedupEntry struct {
+	Round int    //round received of the transaction
+	Hash  string //TxHash of the transaction
+}
+
+//++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+//JoinRequest asks a participant to propose Peer as a new participant. It is
+//signed with the key of Peer, see hashgraph.PeerJoin.
+type JoinRequest struct {
+	From      string
+	Peer      Peer
+	Signature string
+}
+
+//JoinResponse is Accepted once the join reached consensus. Peers are then the
+//participants that the new peer can gossip with.
+type JoinResponse struct {
+	From     string
+	Accepted bool
+	Peers    []Peer
 }
@@ -0,0 +1,145 @@
+package net
+
+import (
+	"bytes"
+	"compress/flate"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/babbleio/babble/hashgraph"
+)
+
+/*
+The Events of a SyncResponse or of an EagerSyncRequest can be sent compressed in
+CompressedEvents instead of Events. They are encoded as an EagerSyncRequest of
+wire.proto that only holds the Events, whatever the codec of the transport, and
+compressed with DEFLATE. Snappy or zstd would be faster, but they are not in the
+standard library; the fastest level of DEFLATE already shrinks repetitive
+transactions several times.
+
+A requester sets Compress in its SyncRequest if it accepts a compressed
+response. EagerSyncRequests are only compressed for peers that advertised
+CapCompression.
+
+DEFLATE learns nothing from the few tiny Events of a sync in an idle network:
+they are mostly tags, small integers and signatures. Peers that both advertise
+CapDictionary prime it with a preset dictionary of such Events instead, and
+the Dictionary field of the message tells which one. The dictionaries are
+shipped with the code, see gen_events_dictionary.go, and never change once
+released: a new one gets a new version.
+*/
+
+//go:generate go run gen_events_dictionary.go
+
+//largest decompressed Events accepted, against compression bombs
+const maxDecompressedEvents = 256 * 1024 * 1024
+
+//NoDictionary and EventsDictionaryV1 are the preset dictionaries of
+//CompressEvents
+const (
+	NoDictionary       uint32 = 0
+	EventsDictionaryV1 uint32 = 1
+)
+
+//eventsDictionaries are the preset dictionaries by version
+var eventsDictionaries = map[uint32][]byte{
+	EventsDictionaryV1: eventsDictionaryV1,
+}
+
+//CompressEvents returns the compressed encoding of events with the preset
+//dictionary of version dictionary, and whether it is smaller than the
+//uncompressed one
+func CompressEvents(events []hashgraph.WireEvent, dictionary uint32) ([]byte, bool, error) {
+	dict, err := eventsDictionary(dictionary)
+	if err != nil {
+		return nil, false, err
+	}
+	raw := EagerSyncRequest{Events: events}.MarshalProto()
+	level := compressionLevel(dict, len(raw))
+	var b bytes.Buffer
+	w, err := getEventsWriter(&b, dictionary, dict, level)
+	if err != nil {
+		return nil, false, err
+	}
+	defer putEventsWriter(w, dictionary, level)
+	if _, err := w.Write(raw); err != nil {
+		return nil, false, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, false, err
+	}
+	return b.Bytes(), b.Len() < len(raw), nil
+}
+
+//eventsWriterKey identifies the pool of DEFLATE writers of a dictionary and a
+//level
+type eventsWriterKey struct {
+	dictionary uint32
+	level      int
+}
+
+//eventsWriters pools the DEFLATE writers of CompressEvents: a writer allocates
+//about half a megabyte, much more than the Events of most syncs, and
+//allocating one per sync kept the garbage collector busy
+var eventsWriters sync.Map
+
+func getEventsWriter(b *bytes.Buffer, dictionary uint32, dict []byte, level int) (*flate.Writer, error) {
+	key := eventsWriterKey{dictionary, level}
+	if pool, ok := eventsWriters.Load(key); ok {
+		if w, ok := pool.(*sync.Pool).Get().(*flate.Writer); ok {
+			w.Reset(b)
+			return w, nil
+		}
+	}
+	return flate.NewWriterDict(b, level, dict)
+}
+
+func putEventsWriter(w *flate.Writer, dictionary uint32, level int) {
+	pool, _ := eventsWriters.LoadOrStore(eventsWriterKey{dictionary, level}, &sync.Pool{})
+	pool.(*sync.Pool).Put(w)
+}
+
+//DecompressEvents decodes the result of CompressEvents
+func DecompressEvents(data []byte, dictionary uint32) ([]hashgraph.WireEvent, error) {
+	dict, err := eventsDictionary(dictionary)
+	if err != nil {
+		return nil, err
+	}
+	r := flate.NewReaderDict(bytes.NewReader(data), dict)
+	defer r.Close()
+	raw, err := ioutil.ReadAll(io.LimitReader(r, maxDecompressedEvents+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) > maxDecompressedEvents {
+		return nil, fmt.Errorf("Compressed Events exceed %d bytes", maxDecompressedEvents)
+	}
+	var req EagerSyncRequest
+	if err := req.UnmarshalProto(raw); err != nil {
+		return nil, err
+	}
+	return req.Events, nil
+}
+
+func eventsDictionary(version uint32) ([]byte, error) {
+	if version == NoDictionary {
+		return nil, nil
+	}
+	dict, ok := eventsDictionaries[version]
+	if !ok {
+		return nil, fmt.Errorf("Unknown compression dictionary %d", version)
+	}
+	return dict, nil
+}
+
+//compressionLevel is the level of DEFLATE for size bytes of Events. The small
+//syncs that the dictionary is for are compressed thoroughly, which costs little
+//at their size; the large ones as fast as possible.
+func compressionLevel(dict []byte, size int) int {
+	if dict != nil && size <= len(dict) {
+		return flate.BestCompression
+	}
+	return flate.BestSpeed
+}
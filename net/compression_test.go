@@ -0,0 +1,106 @@
+package net
+
+import (
+	"bytes"
+	"crypto/rand"
+	"math/big"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/hashgraph"
+)
+
+func TestCompressEvents(t *testing.T) {
+	events := []hashgraph.WireEvent{}
+	for i := 0; i < 10; i++ {
+		events = append(events, hashgraph.WireEvent{
+			Body: hashgraph.WireBody{
+				Transactions:         [][]byte{bytes.Repeat([]byte("tx"), 100)},
+				SelfParentIndex:      i - 1,
+				OtherParentCreatorID: 1,
+				OtherParentIndex:     i,
+				CreatorID:            0,
+				Timestamp:            time.Date(2018, 3, 1, 12, 0, i, 0, time.UTC),
+				Index:                i,
+			},
+			R: big.NewInt(int64(i + 1)),
+			S: big.NewInt(int64(i + 2)),
+		})
+	}
+
+	compressed, smaller, err := CompressEvents(events, NoDictionary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !smaller {
+		t.Fatalf("Repeated transactions should compress, got %d bytes", len(compressed))
+	}
+	decompressed, err := DecompressEvents(compressed, NoDictionary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(events, decompressed) {
+		t.Fatalf("Decompressed Events should be %#v, not %#v", events, decompressed)
+	}
+
+	//random transactions do not compress
+	random := make([]byte, 1000)
+	rand.Read(random)
+	events = []hashgraph.WireEvent{{Body: hashgraph.WireBody{Transactions: [][]byte{random}}}}
+	if _, smaller, err := CompressEvents(events, NoDictionary); err != nil || smaller {
+		t.Fatalf("Random transactions should not compress, smaller: %v, err: %v", smaller, err)
+	}
+
+	if _, err := DecompressEvents([]byte("not deflate"), NoDictionary); err == nil {
+		t.Fatal("DecompressEvents should fail on invalid data")
+	}
+}
+
+func TestEventsDictionary(t *testing.T) {
+	//the few small Events of an idle network
+	events := []hashgraph.WireEvent{}
+	for i := 0; i < 3; i++ {
+		r, s := make([]byte, 32), make([]byte, 32)
+		rand.Read(r)
+		rand.Read(s)
+		events = append(events, hashgraph.WireEvent{
+			Body: hashgraph.WireBody{
+				SelfParentIndex:      1200 + i,
+				OtherParentCreatorID: (i + 1) % 4,
+				OtherParentIndex:     1199 + i,
+				CreatorID:            i,
+				Timestamp:            time.Date(2026, 10, 16, 9, 30, i, 123456789, time.UTC),
+				Index:                1201 + i,
+			},
+			R: new(big.Int).SetBytes(r),
+			S: new(big.Int).SetBytes(s),
+		})
+	}
+
+	plain, _, err := CompressEvents(events, NoDictionary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compressed, _, err := CompressEvents(events, EventsDictionaryV1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(compressed) >= len(plain) {
+		t.Fatalf("Dictionary should compress small syncs better, got %d bytes, %d without", len(compressed), len(plain))
+	}
+	decompressed, err := DecompressEvents(compressed, EventsDictionaryV1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(events, decompressed) {
+		t.Fatalf("Decompressed Events should be %#v, not %#v", events, decompressed)
+	}
+
+	if _, _, err := CompressEvents(events, 99); err == nil {
+		t.Fatal("Unknown dictionary should be refused")
+	}
+	if _, err := DecompressEvents(compressed, 99); err == nil {
+		t.Fatal("Unknown dictionary should be refused")
+	}
+}
@@ -0,0 +1,261 @@
+// Code generated by gen_events_dictionary.go; DO NOT EDIT.
+
+package net
+
+var eventsDictionaryV1 = []byte("" +
+	"\x12\x5e\x10\x0c\x18\x02\x20\x0e\x28\x06\x32\x0c\x08\xce\xec\xe9\xc7\x0d\x10\xa2\xff\xb3\xdb\x01\x38\x0e\x42\x20\x4a\x20\x50\x02" +
+	"\x12\x5c\x10\x14\x18\x04\x20\x12\x28\x0a\x32\x0c\x08\xf6\x8b\xae\xc4\x0d\x10\xaf\x8c\xe6\xa5\x02\x38\x16\x42\x20\x4a\x20\x12\x5a" +
+	"\x10\x28\x18\x04\x20\x26\x32\x0c\x08\x84\x96\x9b\xc9\x0d\x10\x8d\xad\xb6\xb1\x01\x38\x2a\x42\x20\x4a\x20\x12\x5b\x10\x20\x18\x0c" +
+	"\x20\x20\x28\x02\x32\x0b\x08\xa6\xd7\xa7\xa5\x0e\x10\x91\xbc\x89\x4c\x38\x22\x42\x20\x4a\x20\x12\x5f\x10\xd4\x06\x20\xd6\x06\x28" +
+	"\x0a\x32\x0c\x08\xbe\xa8\xef\xcc\x0d\x10\xa2\x86\xc1\xd3\x03\x38\xd6\x06\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x0a\x20\x0a\x28\x06" +
+	"\x32\x0c\x08\xa2\xcb\x91\xe4\x0c\x10\x89\x93\x82\xb9\x03\x38\x0c\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\x84\x58\x18\x04\x20\x84\x58" +
+	"\x28\x08\x32\x0c\x08\x86\xff\x9a\xa1\x0e\x10\x8d\xc3\x8a\xa0\x02\x38\x86\x58\x42\x20\x4a\x20\x12\x5e\x10\xb4\x03\x18\x02\x20\xb2" +
+	"\x03\x28\x04\x32\x0b\x08\x8a\xe5\xf1\xdc\x0c\x10\xbf\x89\xb1\x1e\x38\xb6\x03\x42\x20\x4a\x20\x12\x5c\x10\x04\x18\x0c\x20\x06\x28" +
+	"\x04\x32\x0c\x08\xac\xd1\xc2\xb0\x0d\x10\xdd\xf8\xb3\xfd\x01\x38\x06\x42\x20\x4a\x20\x12\x5e\x10\x46\x18\x0c\x20\x44\x28\x08\x32" +
+	"\x0c\x08\x86\xc5\xf1\xf2\x0d\x10\xd8\xb6\xb2\xed\x01\x38\x48\x42\x20\x4a\x20\x50\x02\x12\x5a\x10\x01\x20\x03\x28\x08\x32\x0c\x08" +
+	"\xc8\xdd\xfb\xe5\x0d\x10\xf1\xc7\xc0\xd3\x02\x42\x20\x4a\x20\x50\x02\x12\x5d\x10\xc2\x02\x20\xc4\x02\x28\x04\x32\x0c\x08\xf0\x8b" +
+	"\x88\x88\x0d\x10\xff\xe1\xbd\xa3\x01\x38\xc4\x02\x42\x20\x4a\x20\x12\x5e\x10\x8c\x02\x18\x06\x20\x8c\x02\x32\x0b\x08\xd2\xe3\x81" +
+	"\x80\x0d\x10\x94\x8d\xb1\x64\x38\x8e\x02\x42\x20\x4a\x20\x50\x02\x12\x59\x10\x2a\x18\x04\x20\x2a\x32\x0b\x08\xa0\x8b\xc6\xfd\x0c" +
+	"\x10\xaf\xc1\xa8\x3b\x38\x2c\x42\x20\x4a\x20\x12\x5e\x10\xe8\x0f\x18\x04\x20\xe8\x0f\x32\x0b\x08\xa0\xff\x97\xef\x0c\x10\xb0\xe4" +
+	"\x99\x5f\x38\xea\x0f\x42\x20\x4a\x20\x50\x02\x12\x5a\x10\x02\x18\x0a\x32\x0c\x08\xe6\xf9\xdf\xd9\x0c\x10\xb1\xb0\xd7\xb7\x01\x38" +
+	"\x04\x42\x20\x4a\x20\x50\x02\x12\x5a\x10\x12\x20\x10\x28\x04\x32\x0c\x08\xb6\xc9\x94\xea\x0d\x10\xfa\xb2\x80\x87\x03\x38\x14\x42" +
+	"\x20\x4a\x20\x12\x5c\x10\x38\x20\x36\x28\x04\x32\x0c\x08\xb2\xd0\xb5\xb7\x0d\x10\xa5\xa2\xf7\xbb\x01\x38\x3a\x42\x20\x4a\x20\x50" +
+	"\x02\x12\x5a\x10\x01\x18\x02\x28\x06\x32\x0c\x08\xd4\xa9\xdf\xfb\x0c\x10\xde\xe6\xbf\xa0\x03\x42\x20\x4a\x20\x50\x02\x12\x5c\x10" +
+	"\x52\x18\x04\x20\x54\x28\x02\x32\x0c\x08\x80\xef\xbc\xe9\x0d\x10\xa8\xb7\xa2\xff\x02\x38\x54\x42\x20\x4a\x20\x12\x61\x10\xa8\x05" +
+	"\x18\x06\x20\xa8\x05\x28\x02\x32\x0c\x08\xb6\xf0\xdf\xe5\x0d\x10\x9f\x92\x98\xe3\x02\x38\xaa\x05\x42\x20\x4a\x20\x50\x02\x12\x61" +
+	"\x10\xe4\x02\x18\x06\x20\xe6\x02\x28\x0a\x32\x0c\x08\xe4\x90\xc4\xdf\x0d\x10\xfa\xf9\x8b\xb7\x02\x38\xe6\x02\x42\x20\x4a\x20\x50" +
+	"\x02\x12\x5c\x10\x02\x18\x06\x20\x04\x28\x04\x32\x0c\x08\xde\xa6\xf8\x95\x0d\x10\xb3\xa2\xad\xf6\x02\x38\x04\x42\x20\x4a\x20\x12" +
+	"\x5e\x10\x16\x18\x02\x20\x18\x28\x08\x32\x0c\x08\xac\xf7\xe2\xec\x0c\x10\x96\xdc\xe1\xa4\x02\x38\x18\x42\x20\x4a\x20\x50\x02\x12" +
+	"\x61\x10\x98\x07\x18\x04\x20\x9a\x07\x28\x08\x32\x0c\x08\xb2\xd4\xbf\xfa\x0c\x10\xcc\xe3\xeb\xd6\x03\x38\x9a\x07\x42\x20\x4a\x20" +
+	"\x50\x02\x12\x5c\x10\x04\x18\x04\x20\x02\x32\x0c\x08\xe0\xd1\x96\x80\x0e\x10\xf7\x8b\x97\xb7\x02\x38\x06\x42\x20\x4a\x20\x50\x02" +
+	"\x12\x5c\x10\x52\x20\x54\x28\x06\x32\x0c\x08\xf0\x9c\xef\x90\x0e\x10\xa9\xa1\x8f\xbc\x03\x38\x54\x42\x20\x4a\x20\x50\x02\x12\x5a" +
+	"\x10\x16\x20\x18\x28\x0c\x32\x0c\x08\xae\xfc\xd6\xaf\x0d\x10\x96\xbb\xe9\xea\x01\x38\x18\x42\x20\x4a\x20\x12\x5f\x10\xda\x11\x18" +
+	"\x02\x20\xdc\x11\x32\x0c\x08\x80\xa9\x9b\xc4\x0d\x10\xdc\xb1\xb9\xc4\x01\x38\xdc\x11\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\x58\x18" +
+	"\x02\x20\x56\x28\x06\x32\x0c\x08\xba\xa3\xa0\x84\x0d\x10\xfb\xbd\xf9\x8f\x01\x38\x5a\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xb4\x11" +
+	"\x20\xb4\x11\x28\x04\x32\x0c\x08\xfe\xaa\xb4\xa5\x0e\x10\x84\xf7\x8b\x9d\x02\x38\xb6\x11\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\xce" +
+	"\x02\x20\xd0\x02\x28\x06\x32\x0b\x08\xc4\xa4\x91\x94\x0e\x10\xeb\x8b\xe3\x2e\x38\xd0\x02\x42\x20\x4a\x20\x12\x5e\x10\x08\x18\x0c" +
+	"\x20\x08\x28\x06\x32\x0c\x08\xdc\x9a\xa7\x89\x0d\x10\xda\xf5\xd4\xa9\x01\x38\x0a\x42\x20\x4a\x20\x50\x02\x12\x5a\x18\x08\x20\x02" +
+	"\x28\x06\x32\x0c\x08\xae\x96\xfc\xfa\x0d\x10\xce\xa6\x86\x9d\x01\x38\x02\x42\x20\x4a\x20\x12\x5f\x10\x8c\x0b\x18\x02\x20\x8e\x0b" +
+	"\x28\x0a\x32\x0c\x08\x8e\xdd\xd0\xc6\x0d\x10\xd5\xbb\xc9\x96\x02\x38\x8e\x0b\x42\x20\x4a\x20\x12\x5a\x10\x02\x18\x06\x28\x0a\x32" +
+	"\x0c\x08\xf2\xf4\xb1\xfd\x0c\x10\xfd\xa2\x87\xcf\x03\x38\x04\x42\x20\x4a\x20\x12\x5c\x10\x08\x18\x0c\x20\x0a\x28\x04\x32\x0c\x08" +
+	"\xd6\xea\xf6\xfa\x0d\x10\x9e\x80\x80\xc6\x02\x38\x0a\x42\x20\x4a\x20\x12\x5c\x10\x72\x18\x04\x20\x70\x28\x02\x32\x0c\x08\xb6\xa5" +
+	"\x80\x88\x0e\x10\xe5\xa3\xda\x92\x01\x38\x74\x42\x20\x4a\x20\x12\x5f\x10\xfe\x3d\x20\xfe\x3d\x28\x06\x32\x0c\x08\xf0\xc4\xb6\xed" +
+	"\x0c\x10\x8a\xad\xbb\x8d\x02\x38\x80\x3e\x42\x20\x4a\x20\x50\x02\x12\x5d\x10\x86\x02\x20\x84\x02\x28\x0a\x32\x0c\x08\xc2\x81\xfb" +
+	"\xa1\x0e\x10\x99\xf2\xbc\xdb\x02\x38\x88\x02\x42\x20\x4a\x20\x12\x5c\x10\x02\x20\x04\x28\x06\x32\x0c\x08\xb4\xc0\xc9\xf9\x0d\x10" +
+	"\xd9\xb2\xa2\xc5\x03\x38\x04\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\x86\x06\x18\x02\x20\x88\x06\x28\x04\x32\x0b\x08\xa2\xe0\xcd\xc9" +
+	"\x0d\x10\xaa\xc2\xda\x5b\x38\x88\x06\x42\x20\x4a\x20\x12\x5e\x10\x16\x18\x04\x20\x16\x28\x02\x32\x0c\x08\xea\x8d\xfb\xc7\x0d\x10" +
+	"\xdd\xd9\x81\xaa\x01\x38\x18\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\x9a\x01\x18\x02\x20\x9a\x01\x32\x0c\x08\xe6\xfa\x98\xe6\x0c\x10" +
+	"\xc7\xf5\xd5\x88\x02\x38\x9c\x01\x42\x20\x4a\x20\x50\x02\x12\x5a\x18\x0a\x20\x02\x32\x0c\x08\x8c\x93\xeb\x8e\x0d\x10\x96\xb7\xe3" +
+	"\x91\x01\x38\x02\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\x6a\x18\x04\x20\x6c\x28\x02\x32\x0c\x08\xae\xbc\xaa\xda\x0d\x10\xa2\xfa\xb5" +
+	"\xb6\x01\x38\x6c\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\xf2\x09\x18\x02\x20\xf2\x09\x28\x06\x32\x0b\x08\xd2\xab\xf0\xad\x0d\x10\xce" +
+	"\xbe\xb7\x6f\x38\xf4\x09\x42\x20\x4a\x20\x12\x5b\x10\x02\x18\x06\x20\x04\x28\x02\x32\x0b\x08\x88\x8d\xae\x8d\x0e\x10\xee\xb6\xb8" +
+	"\x1b\x38\x04\x42\x20\x4a\x20\x12\x5e\x10\x04\x18\x06\x20\x02\x28\x08\x32\x0c\x08\xa2\x9f\xce\x86\x0e\x10\xbb\xcd\xdc\xd9\x01\x38" +
+	"\x06\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\x8c\x1b\x18\x0a\x20\x8c\x1b\x28\x02\x32\x0c\x08\xb4\x8d\xa1\x94\x0d\x10\xb1\xb5\xde\xdc" +
+	"\x03\x38\x8e\x1b\x42\x20\x4a\x20\x12\x5f\x10\xc0\x5c\x18\x02\x20\xbe\x5c\x28\x06\x32\x0c\x08\xb4\xeb\xe2\xd2\x0d\x10\xbb\x89\xc6" +
+	"\xf6\x01\x38\xc2\x5c\x42\x20\x4a\x20\x12\x5e\x10\x74\x18\x02\x20\x72\x28\x06\x32\x0c\x08\xfa\x94\xfe\xf6\x0c\x10\x81\xee\xc5\xd1" +
+	"\x02\x38\x76\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\x3c\x18\x04\x20\x3e\x28\x08\x32\x0c\x08\xc0\xe6\x9f\xed\x0c\x10\xb2\xda\x87\xbe" +
+	"\x01\x38\x3e\x42\x20\x4a\x20\x50\x02\x12\x5b\x10\x2a\x18\x04\x20\x2c\x28\x06\x32\x0b\x08\xd8\xa4\x86\xdd\x0d\x10\xa0\xca\x9d\x31" +
+	"\x38\x2c\x42\x20\x4a\x20\x12\x61\x10\xc0\x04\x18\x02\x20\xc0\x04\x28\x04\x32\x0c\x08\xfe\x9d\xa4\xef\x0c\x10\xe2\x9b\x92\xa1\x02" +
+	"\x38\xc2\x04\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xbc\x01\x18\x06\x20\xba\x01\x28\x04\x32\x0c\x08\xea\xea\xae\xd3\x0d\x10\xc3\xb3" +
+	"\xd7\x80\x02\x38\xbe\x01\x42\x20\x4a\x20\x12\x61\x10\x90\x07\x18\x08\x20\x92\x07\x28\x06\x32\x0c\x08\xbc\x97\x8b\xa8\x0d\x10\x9b" +
+	"\xc6\xa0\xf1\x02\x38\x92\x07\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x46\x18\x02\x20\x46\x28\x08\x32\x0c\x08\xc2\xf0\xea\xbe\x0d\x10" +
+	"\xe8\xc7\xff\xfa\x02\x38\x48\x42\x20\x4a\x20\x12\x5e\x10\x6e\x18\x02\x20\x70\x28\x08\x32\x0c\x08\x98\x90\xf1\xe8\x0d\x10\xfe\xce" +
+	"\xa3\x96\x02\x38\x70\x42\x20\x4a\x20\x50\x02\x12\x61\x10\xfc\x03\x18\x04\x20\xfa\x03\x28\x02\x32\x0c\x08\xde\xd5\xeb\xc7\x0d\x10" +
+	"\xa7\xc1\xa4\xc3\x03\x38\xfe\x03\x42\x20\x4a\x20\x50\x02\x12\x60\x10\x96\x21\x18\x06\x20\x98\x21\x28\x08\x32\x0b\x08\xaa\xd3\x96" +
+	"\x81\x0d\x10\xb6\xb6\x8b\x2a\x38\x98\x21\x42\x20\x4a\x20\x50\x02\x12\x5d\x10\x18\x18\x04\x20\x16\x28\x08\x32\x0b\x08\xc6\x98\xce" +
+	"\xc6\x0d\x10\x8e\xf2\x9c\x7e\x38\x1a\x42\x20\x4a\x20\x50\x02\x12\x5c\x18\x0a\x20\x02\x28\x06\x32\x0c\x08\xd2\xad\x84\x98\x0e\x10" +
+	"\xf1\xb4\xdc\xbc\x03\x38\x02\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xe4\x11\x18\x08\x20\xe2\x11\x28\x04\x32\x0c\x08\x9e\xf6\xa7\xa3" +
+	"\x0e\x10\xd3\xa8\xd1\xa9\x03\x38\xe6\x11\x42\x20\x4a\x20\x12\x5c\x10\x34\x18\x06\x20\x36\x28\x0c\x32\x0c\x08\xdc\x9d\x86\xdd\x0c" +
+	"\x10\x9c\xe8\xb7\x8d\x01\x38\x36\x42\x20\x4a\x20\x12\x5c\x10\x52\x18\x02\x20\x50\x28\x04\x32\x0c\x08\xc8\xb1\xd8\xbc\x0d\x10\xd1" +
+	"\x9d\xd7\xc6\x03\x38\x54\x42\x20\x4a\x20\x12\x57\x10\x02\x28\x06\x32\x0b\x08\xb2\xca\xef\xd6\x0d\x10\x98\xb7\xaa\x67\x38\x04\x42" +
+	"\x20\x4a\x20\x12\x5d\x10\x94\x1f\x18\x04\x20\x96\x1f\x32\x0c\x08\x82\x93\xf0\xcb\x0d\x10\xe7\x85\x93\xd8\x03\x38\x96\x1f\x42\x20" +
+	"\x4a\x20\x12\x5a\x10\x08\x20\x0a\x28\x04\x32\x0c\x08\x9a\x9e\xba\xe6\x0d\x10\x81\xdf\xf9\xb8\x01\x38\x0a\x42\x20\x4a\x20\x12\x60" +
+	"\x10\xec\x03\x18\x04\x20\xea\x03\x28\x02\x32\x0b\x08\xd4\xcc\xcf\xfa\x0c\x10\xcd\xcf\xc3\x23\x38\xee\x03\x42\x20\x4a\x20\x50\x02" +
+	"\x12\x61\x10\xd8\x26\x18\x08\x20\xd6\x26\x28\x04\x32\x0c\x08\x94\xc0\xc7\xb0\x0d\x10\xde\xc7\xbe\xcd\x03\x38\xda\x26\x42\x20\x4a" +
+	"\x20\x50\x02\x12\x5d\x10\x8a\x65\x18\x08\x20\x8c\x65\x32\x0c\x08\xfa\xc1\xc4\xbf\x0d\x10\x81\xc3\x80\xb0\x02\x38\x8c\x65\x42\x20" +
+	"\x4a\x20\x12\x5d\x10\xd8\x02\x20\xda\x02\x28\x08\x32\x0c\x08\xa2\xbd\x9a\xc4\x0d\x10\xa6\xb8\xe4\xec\x02\x38\xda\x02\x42\x20\x4a" +
+	"\x20\x12\x5e\x10\x8a\x02\x18\x04\x20\x8a\x02\x32\x0b\x08\x8e\xc5\xc4\xfa\x0d\x10\xaf\xf8\xee\x62\x38\x8c\x02\x42\x20\x4a\x20\x50" +
+	"\x02\x12\x59\x10\x70\x18\x02\x20\x70\x32\x0b\x08\xc4\xbb\xf5\xab\x0d\x10\xbb\x93\xf3\x1a\x38\x72\x42\x20\x4a\x20\x12\x5e\x10\xa2" +
+	"\x27\x20\xa0\x27\x28\x02\x32\x0b\x08\xd4\xc6\xa9\xef\x0d\x10\xb3\xfe\xa7\x51\x38\xa4\x27\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\x82" +
+	"\x21\x20\x80\x21\x28\x02\x32\x0c\x08\x86\xf4\xb6\xab\x0e\x10\xea\x9d\xb2\xfd\x02\x38\x84\x21\x42\x20\x4a\x20\x50\x02\x12\x61\x10" +
+	"\x94\x07\x18\x08\x20\x92\x07\x28\x06\x32\x0c\x08\xe6\x9a\xda\xb6\x0d\x10\xca\xbd\xec\x89\x03\x38\x96\x07\x42\x20\x4a\x20\x50\x02" +
+	"\x12\x5e\x10\x04\x18\x0a\x20\x04\x28\x0c\x32\x0c\x08\xf2\x92\xdf\xcf\x0d\x10\xad\xd2\x82\xc8\x01\x38\x06\x42\x20\x4a\x20\x50\x02" +
+	"\x12\x5c\x10\x02\x18\x04\x28\x02\x32\x0c\x08\xca\xc3\x89\x9d\x0d\x10\xf1\xc6\xb8\xdb\x01\x38\x04\x42\x20\x4a\x20\x50\x02\x12\x5f" +
+	"\x10\xe2\x1c\x18\x0c\x20\xe2\x1c\x28\x06\x32\x0c\x08\xd6\xe4\x8d\x8c\x0e\x10\xba\xf0\xa5\x82\x01\x38\xe4\x1c\x42\x20\x4a\x20\x12" +
+	"\x5d\x10\xd4\x14\x20\xd6\x14\x28\x04\x32\x0c\x08\x98\x9b\xbe\x91\x0e\x10\x8a\xdd\xca\xf6\x01\x38\xd6\x14\x42\x20\x4a\x20\x12\x5b" +
+	"\x10\x7c\x20\x7a\x28\x06\x32\x0b\x08\xb0\xff\x91\x8e\x0e\x10\x84\xcc\xdd\x38\x38\x7e\x42\x20\x4a\x20\x50\x02\x12\x61\x10\xbe\x02" +
+	"\x18\x0a\x20\xbe\x02\x28\x0c\x32\x0c\x08\x8c\x87\xa0\xd4\x0d\x10\xaf\xec\xae\x90\x02\x38\xc0\x02\x42\x20\x4a\x20\x50\x02\x12\x5c" +
+	"\x10\x0a\x18\x04\x20\x08\x28\x02\x32\x0c\x08\xf8\xfa\xf3\xf8\x0d\x10\xf9\xa9\xca\xb9\x01\x38\x0c\x42\x20\x4a\x20\x12\x5c\x10\x3c" +
+	"\x18\x08\x20\x3c\x28\x04\x32\x0c\x08\x94\xa5\x9f\xf0\x0d\x10\xce\xcd\xf1\xb9\x03\x38\x3e\x42\x20\x4a\x20\x12\x5a\x10\x02\x18\x02" +
+	"\x32\x0c\x08\xea\xa3\xfe\xb7\x0d\x10\xd6\x9b\xdf\x96\x03\x38\x04\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x14\x18\x06\x20\x12\x32\x0c" +
+	"\x08\xe2\x9d\xdb\x9d\x0e\x10\xe9\xf2\xfc\xd7\x02\x38\x16\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\x78\x18\x02\x20\x76\x28\x04\x32\x0c" +
+	"\x08\x98\xc4\xde\xc2\x0d\x10\x8c\xce\xfb\xf1\x01\x38\x7a\x42\x20\x4a\x20\x50\x02\x12\x5a\x10\x72\x18\x02\x20\x70\x32\x0c\x08\xf8" +
+	"\xde\xed\x89\x0e\x10\x88\xc4\xbf\xbb\x02\x38\x74\x42\x20\x4a\x20\x12\x5a\x10\x06\x18\x02\x20\x06\x32\x0c\x08\xd2\xcf\xba\xfb\x0c" +
+	"\x10\xdd\xe2\xc2\xcd\x03\x38\x08\x42\x20\x4a\x20\x12\x61\x10\xe0\x08\x18\x06\x20\xe2\x08\x28\x08\x32\x0c\x08\xec\xe0\xe8\x89\x0e" +
+	"\x10\xa8\x9d\xe0\xaf\x01\x38\xe2\x08\x42\x20\x4a\x20\x50\x02\x12\x61\x10\x8e\x07\x18\x06\x20\x90\x07\x28\x02\x32\x0c\x08\x88\xc0" +
+	"\x88\xce\x0d\x10\xbf\xca\x99\x83\x01\x38\x90\x07\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\xd4\x07\x18\x04\x20\xd4\x07\x28\x02\x32\x0b" +
+	"\x08\xc6\xf7\xc3\x9e\x0d\x10\xb4\xb9\xfa\x07\x38\xd6\x07\x42\x20\x4a\x20\x12\x61\x10\x92\x6a\x18\x04\x20\x92\x6a\x28\x06\x32\x0c" +
+	"\x08\xf6\xa5\xd5\xd0\x0d\x10\x84\xde\xe0\xff\x02\x38\x94\x6a\x42\x20\x4a\x20\x50\x02\x12\x5d\x10\x8a\x1f\x18\x02\x20\x8c\x1f\x32" +
+	"\x0c\x08\x90\xa9\xc3\x9d\x0d\x10\xa4\xfb\xd5\xa6\x01\x38\x8c\x1f\x42\x20\x4a\x20\x12\x61\x10\xae\x1f\x18\x08\x20\xac\x1f\x28\x02" +
+	"\x32\x0c\x08\xda\xea\xcb\x9a\x0e\x10\x82\xd1\x9e\x97\x03\x38\xb0\x1f\x42\x20\x4a\x20\x50\x02\x12\x61\x10\x92\x0a\x18\x02\x20\x90" +
+	"\x0a\x28\x0c\x32\x0c\x08\xfc\xd3\xf8\x9e\x0e\x10\xcf\xe3\x8b\x8b\x03\x38\x94\x0a\x42\x20\x4a\x20\x50\x02\x12\x5a\x10\x18\x20\x16" +
+	"\x28\x02\x32\x0c\x08\xe0\xf4\xcc\x8e\x0d\x10\xd9\xda\xbb\xd9\x01\x38\x1a\x42\x20\x4a\x20\x12\x5d\x10\x8a\x01\x20\x8c\x01\x28\x02" +
+	"\x32\x0c\x08\xf6\xc7\xb1\xb1\x0d\x10\xea\xca\xc6\xbf\x01\x38\x8c\x01\x42\x20\x4a\x20\x12\x5c\x10\x02\x18\x04\x20\x02\x32\x0c\x08" +
+	"\xf4\x80\x90\x92\x0e\x10\xe2\xb6\x94\xac\x01\x38\x04\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\x06\x18\x0c\x20\x06\x28\x06\x32\x0c\x08" +
+	"\xbe\xa8\xe2\x9e\x0e\x10\xb2\x93\x85\x9b\x03\x38\x08\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\x06\x18\x08\x20\x04\x28\x06\x32\x0c\x08" +
+	"\x80\xfb\xdb\x9f\x0e\x10\xe6\xf7\xd3\x8a\x03\x38\x08\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xa6\x0a\x18\x04\x20\xa8\x0a\x28\x02\x32" +
+	"\x0c\x08\x94\xec\xf2\xa7\x0d\x10\xd1\x9a\x93\xb8\x01\x38\xa8\x0a\x42\x20\x4a\x20\x12\x56\x18\x04\x32\x0c\x08\xa0\x83\xa6\xeb\x0d" +
+	"\x10\xf3\xab\xdd\xd7\x03\x38\x02\x42\x20\x4a\x20\x12\x5c\x10\x06\x18\x04\x20\x08\x28\x02\x32\x0c\x08\xf0\xc1\xe8\x92\x0e\x10\xfb" +
+	"\xc7\xbf\xa6\x01\x38\x08\x42\x20\x4a\x20\x12\x5b\x18\x04\x20\x01\x28\x02\x32\x0b\x08\xc4\xfb\xda\xa8\x0d\x10\xc1\xc4\x89\x14\x38" +
+	"\x02\x42\x20\x4a\x20\x50\x02\x12\x5a\x18\x08\x20\x01\x28\x06\x32\x0c\x08\xf2\x8f\xac\xbb\x0d\x10\xfe\xce\xeb\xbd\x01\x38\x02\x42" +
+	"\x20\x4a\x20\x12\x5d\x10\x82\x01\x18\x02\x20\x80\x01\x32\x0c\x08\xea\xb4\xba\xb7\x0d\x10\xc8\xae\xd0\xfd\x02\x38\x84\x01\x42\x20" +
+	"\x4a\x20\x12\x5e\x10\x3c\x18\x04\x20\x3e\x28\x06\x32\x0c\x08\xba\xad\x89\xe9\x0c\x10\xee\xf8\xea\xfa\x01\x38\x3e\x42\x20\x4a\x20" +
+	"\x50\x02\x12\x5a\x18\x0c\x20\x01\x28\x06\x32\x0c\x08\xfc\x93\xc6\xab\x0e\x10\xb1\x8c\xc3\x8f\x02\x38\x02\x42\x20\x4a\x20\x12\x5d" +
+	"\x10\x36\x18\x02\x20\x36\x28\x04\x32\x0b\x08\xcc\x9e\xed\x9a\x0e\x10\xc4\xaa\x84\x28\x38\x38\x42\x20\x4a\x20\x50\x02\x12\x5c\x10" +
+	"\x16\x18\x04\x20\x18\x28\x06\x32\x0c\x08\xfa\xbe\x84\xa2\x0e\x10\xa5\xa3\xb0\x80\x03\x38\x18\x42\x20\x4a\x20\x12\x5d\x10\x12\x18" +
+	"\x08\x20\x14\x28\x06\x32\x0b\x08\xe8\xf8\xc8\x8e\x0d\x10\xe9\xb2\xf0\x7b\x38\x14\x42\x20\x4a\x20\x50\x02\x12\x5a\x10\x02\x18\x04" +
+	"\x28\x08\x32\x0c\x08\xc4\x8a\xbb\xed\x0c\x10\x8c\xf1\x95\xe3\x02\x38\x04\x42\x20\x4a\x20\x12\x60\x10\x96\x0a\x18\x06\x20\x98\x0a" +
+	"\x28\x02\x32\x0b\x08\xca\xb9\x89\x8f\x0e\x10\xdb\xee\xe3\x2d\x38\x98\x0a\x42\x20\x4a\x20\x50\x02\x12\x58\x10\x01\x18\x04\x32\x0c" +
+	"\x08\xbe\x9f\xf4\xcd\x0d\x10\xe9\x9c\xcd\xcd\x01\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\x0a\x18\x04\x20\x0a\x28\x06\x32\x0c\x08\xaa" +
+	"\x85\xfb\xe3\x0d\x10\xbf\xb9\x82\xc7\x01\x38\x0c\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\x9c\x01\x18\x02\x20\x9e\x01\x32\x0c\x08\xca" +
+	"\x8d\xd5\xc6\x0d\x10\xbd\xa0\xeb\x87\x03\x38\x9e\x01\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xf0\x01\x18\x06\x20\xf2\x01\x32\x0c\x08" +
+	"\xd0\x90\xeb\x93\x0e\x10\xfd\xf7\x97\x8f\x03\x38\xf2\x01\x42\x20\x4a\x20\x50\x02\x12\x5d\x10\x04\x18\x06\x20\x04\x28\x04\x32\x0b" +
+	"\x08\xd2\xab\xc1\x8c\x0e\x10\xed\xf7\x89\x60\x38\x06\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xdc\x19\x18\x02\x20\xde\x19\x28\x04\x32" +
+	"\x0c\x08\xa0\xad\xc5\xa7\x0d\x10\xd0\xe7\x9c\xf7\x02\x38\xde\x19\x42\x20\x4a\x20\x12\x59\x10\x0c\x20\x0e\x28\x02\x32\x0b\x08\x8c" +
+	"\xd5\x97\x9a\x0e\x10\x94\xcb\xb2\x4e\x38\x0e\x42\x20\x4a\x20\x12\x5f\x10\xf4\x02\x20\xf4\x02\x28\x08\x32\x0c\x08\xc0\xb8\xa4\x9c" +
+	"\x0e\x10\x94\xa4\x9c\xca\x03\x38\xf6\x02\x42\x20\x4a\x20\x50\x02\x12\x61\x10\x82\x17\x18\x04\x20\x84\x17\x28\x08\x32\x0c\x08\xbe" +
+	"\x8b\x85\xc6\x0d\x10\xb6\xf4\xcb\xc6\x02\x38\x84\x17\x42\x20\x4a\x20\x50\x02\x12\x5a\x10\x01\x18\x0a\x28\x06\x32\x0c\x08\xb8\x86" +
+	"\xd9\x90\x0d\x10\xe6\xde\x89\xd5\x01\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xec\x09\x18\x04\x20\xec\x09\x28\x06\x32\x0c\x08\x88\xe1" +
+	"\x83\xe7\x0c\x10\x95\x96\x8d\x86\x03\x38\xee\x09\x42\x20\x4a\x20\x12\x61\x10\x80\x11\x18\x02\x20\xfe\x10\x28\x0a\x32\x0c\x08\x84" +
+	"\x99\x8f\xff\x0d\x10\x97\xa3\xde\xf8\x02\x38\x82\x11\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\x9c\x02\x20\x9c\x02\x28\x04\x32\x0c\x08" +
+	"\x82\x94\xe3\xb4\x0d\x10\xdc\xcb\xd7\x85\x01\x38\x9e\x02\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\xfa\x02\x18\x02\x20\xf8\x02\x32\x0b" +
+	"\x08\xc8\xdc\xaa\x92\x0d\x10\xda\x84\xc2\x01\x38\xfc\x02\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x78\x18\x06\x20\x76\x32\x0c\x08\x82" +
+	"\xba\xfb\x95\x0d\x10\xe2\xe2\x82\xbd\x01\x38\x7a\x42\x20\x4a\x20\x50\x02\x12\x5d\x10\xa0\x04\x20\xa2\x04\x28\x06\x32\x0c\x08\xb0" +
+	"\x9b\xb2\xc6\x0d\x10\xfd\xf9\xd6\xa6\x02\x38\xa2\x04\x42\x20\x4a\x20\x12\x5e\x10\x9e\x24\x18\x0a\x20\x9c\x24\x32\x0b\x08\x9c\xc6" +
+	"\xd3\x8c\x0d\x10\x88\xac\xad\x66\x38\xa0\x24\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x8e\x40\x20\x8e\x40\x28\x06\x32\x0b\x08\xe2\xa0" +
+	"\xf2\xd4\x0d\x10\xe2\xe8\xe6\x4e\x38\x90\x40\x42\x20\x4a\x20\x12\x5c\x10\x14\x18\x04\x20\x12\x32\x0c\x08\xe0\xd4\x9a\xf4\x0d\x10" +
+	"\xfe\xfc\xd3\x87\x02\x38\x16\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x08\x18\x04\x20\x06\x28\x02\x32\x0c\x08\xa4\x85\xe6\x96\x0d\x10" +
+	"\x88\x9c\xd5\xcf\x02\x38\x0a\x42\x20\x4a\x20\x12\x61\x10\x86\x16\x18\x04\x20\x84\x16\x28\x02\x32\x0c\x08\xea\xd2\xf2\xcc\x0d\x10" +
+	"\xba\x96\xa9\xa0\x01\x38\x88\x16\x42\x20\x4a\x20\x50\x02\x12\x5d\x10\xee\x03\x18\x02\x20\xf0\x03\x32\x0c\x08\x94\xec\xbf\x90\x0e" +
+	"\x10\xd0\xc6\xc7\x8f\x03\x38\xf0\x03\x42\x20\x4a\x20\x12\x5d\x10\xa8\x08\x18\x04\x20\xaa\x08\x32\x0c\x08\xf2\xef\xfb\xca\x0d\x10" +
+	"\xea\xa9\xe8\xfb\x01\x38\xaa\x08\x42\x20\x4a\x20\x12\x5f\x10\xaa\x7a\x18\x08\x20\xaa\x7a\x28\x02\x32\x0c\x08\xf4\xc5\xe6\x9d\x0d" +
+	"\x10\xe8\xaa\x9e\xaf\x01\x38\xac\x7a\x42\x20\x4a\x20\x12\x5d\x10\x02\x18\x02\x20\x02\x28\x06\x32\x0b\x08\xe6\xea\xfe\xa9\x0d\x10" +
+	"\xd5\xde\x86\x35\x38\x04\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xbc\x19\x20\xbc\x19\x28\x02\x32\x0c\x08\x80\xe1\x90\x8a\x0d\x10\x86" +
+	"\x99\xfd\xfd\x02\x38\xbe\x19\x42\x20\x4a\x20\x50\x02\x12\x60\x10\xde\x01\x18\x08\x20\xde\x01\x28\x04\x32\x0b\x08\xfe\xb8\x9b\xc1" +
+	"\x0d\x10\x80\xcd\xa5\x19\x38\xe0\x01\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xe0\x23\x18\x0a\x20\xe0\x23\x28\x02\x32\x0c\x08\x98\xcb" +
+	"\xf2\x85\x0d\x10\xdd\xcd\xfd\xba\x01\x38\xe2\x23\x42\x20\x4a\x20\x12\x5f\x10\x92\x13\x20\x90\x13\x28\x0a\x32\x0c\x08\xa8\xce\xef" +
+	"\xe4\x0d\x10\x8d\x98\xe7\x90\x02\x38\x94\x13\x42\x20\x4a\x20\x50\x02\x12\x61\x10\xd2\x1e\x18\x0a\x20\xd4\x1e\x28\x08\x32\x0c\x08" +
+	"\xca\xe4\xf3\x83\x0d\x10\x9d\xb8\xa4\xb5\x02\x38\xd4\x1e\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x1e\x18\x02\x20\x1e\x28\x06\x32\x0c" +
+	"\x08\xe8\xd8\x88\xeb\x0c\x10\xa1\xe3\xb2\xa5\x01\x38\x20\x42\x20\x4a\x20\x12\x5d\x10\x0c\x18\x08\x20\x0a\x28\x06\x32\x0b\x08\xd6" +
+	"\xa6\x8a\xde\x0c\x10\xad\xf6\x8b\x39\x38\x0e\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x70\x18\x0a\x20\x72\x32\x0c\x08\xcc\x8c\x86\xb7" +
+	"\x0d\x10\x99\xb5\x91\xc9\x03\x38\x72\x42\x20\x4a\x20\x50\x02\x12\x61\x10\xec\x33\x18\x0c\x20\xec\x33\x28\x04\x32\x0c\x08\xc4\xee" +
+	"\xef\xe3\x0c\x10\xa4\xcd\xfc\x92\x01\x38\xee\x33\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\xd0\x3c\x18\x04\x20\xce\x3c\x28\x02\x32\x0b" +
+	"\x08\xf8\xd9\x94\xa0\x0e\x10\xc8\x88\x9f\x63\x38\xd2\x3c\x42\x20\x4a\x20\x12\x5c\x10\x08\x18\x02\x20\x0a\x28\x04\x32\x0c\x08\xac" +
+	"\x9a\x87\xa1\x0e\x10\x9a\xcb\xa6\xa3\x01\x38\x0a\x42\x20\x4a\x20\x12\x5e\x10\xd8\x01\x18\x04\x20\xd6\x01\x28\x02\x32\x0b\x08\x8e" +
+	"\xdc\xd9\x93\x0e\x10\xe4\x98\xb9\x75\x38\xda\x01\x42\x20\x4a\x20\x12\x5c\x10\x3c\x18\x04\x20\x3a\x32\x0c\x08\xa6\x95\xb8\xb6\x0d" +
+	"\x10\xf2\xf7\xdc\x90\x03\x38\x3e\x42\x20\x4a\x20\x50\x02\x12\x5b\x10\x0c\x18\x06\x20\x0e\x28\x0c\x32\x0b\x08\xaa\x89\xe0\xc1\x0d" +
+	"\x10\xc8\xc6\xd2\x01\x38\x0e\x42\x20\x4a\x20\x12\x5f\x10\xde\x04\x18\x04\x20\xe0\x04\x28\x02\x32\x0c\x08\x90\x8d\xd0\x94\x0d\x10" +
+	"\xb0\xf6\xad\xbb\x03\x38\xe0\x04\x42\x20\x4a\x20\x12\x5d\x10\x94\x33\x20\x92\x33\x28\x06\x32\x0c\x08\xc2\xa4\xdf\x82\x0e\x10\xe4" +
+	"\xce\xc0\xf4\x02\x38\x96\x33\x42\x20\x4a\x20\x12\x5c\x10\x62\x18\x04\x20\x64\x28\x02\x32\x0c\x08\x84\xf2\xae\x81\x0e\x10\x9e\x90" +
+	"\x84\xac\x02\x38\x64\x42\x20\x4a\x20\x12\x60\x10\xe2\x04\x18\x0a\x20\xe2\x04\x28\x04\x32\x0b\x08\xe6\x94\x8c\xf3\x0d\x10\x9d\xac" +
+	"\xf3\x7a\x38\xe4\x04\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x22\x18\x02\x20\x20\x32\x0c\x08\x9a\xcf\xc5\xd9\x0c\x10\xe4\xb6\x84\xdc" +
+	"\x03\x38\x24\x42\x20\x4a\x20\x50\x02\x12\x5d\x10\x5e\x18\x08\x20\x60\x28\x06\x32\x0b\x08\xbe\xdc\xb9\xed\x0d\x10\x97\xdc\xf2\x46" +
+	"\x38\x60\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x02\x18\x02\x20\x04\x28\x08\x32\x0c\x08\xc2\xe2\xa0\xfc\x0d\x10\xce\xb4\xf8\xd6\x02" +
+	"\x38\x04\x42\x20\x4a\x20\x12\x5b\x10\x04\x18\x06\x20\x02\x28\x08\x32\x0b\x08\xe6\xf8\xcb\xec\x0d\x10\xec\xa2\xc8\x43\x38\x06\x42" +
+	"\x20\x4a\x20\x12\x61\x10\xc2\x32\x18\x04\x20\xc0\x32\x28\x02\x32\x0c\x08\xf8\x9b\xa7\xa7\x0e\x10\xa0\x88\xe0\x83\x03\x38\xc4\x32" +
+	"\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x16\x18\x04\x20\x18\x28\x02\x32\x0c\x08\xcc\xb1\xbe\xe8\x0c\x10\xbd\xe4\x91\xf3\x01\x38\x18" +
+	"\x42\x20\x4a\x20\x12\x5d\x10\xd8\x02\x20\xd6\x02\x28\x02\x32\x0c\x08\xec\xb9\xee\xe9\x0d\x10\x9c\x99\xcb\xbb\x02\x38\xda\x02\x42" +
+	"\x20\x4a\x20\x12\x5f\x10\xc2\x1b\x18\x06\x20\xc0\x1b\x28\x04\x32\x0c\x08\xdc\xaf\xd0\x84\x0d\x10\xd1\xc6\xef\xad\x03\x38\xc4\x1b" +
+	"\x42\x20\x4a\x20\x12\x5b\x10\x46\x20\x44\x28\x02\x32\x0b\x08\xbc\xf0\xfc\xbf\x0d\x10\x9e\x9e\xf0\x7f\x38\x48\x42\x20\x4a\x20\x50" +
+	"\x02\x12\x61\x10\xf2\x17\x18\x02\x20\xf0\x17\x28\x04\x32\x0c\x08\xe6\xb9\xde\x89\x0e\x10\xa5\xfc\xa7\xd0\x01\x38\xf4\x17\x42\x20" +
+	"\x4a\x20\x50\x02\x12\x58\x18\x04\x20\x02\x32\x0c\x08\xae\x96\xa3\xce\x0d\x10\xc8\x9c\xc0\xf9\x02\x38\x02\x42\x20\x4a\x20\x12\x59" +
+	"\x10\x12\x20\x14\x28\x08\x32\x0b\x08\xbc\xe7\x8f\xeb\x0c\x10\xf5\xbe\xcf\x5f\x38\x14\x42\x20\x4a\x20\x12\x5a\x18\x04\x20\x02\x28" +
+	"\x08\x32\x0c\x08\xc8\xc1\xc6\xeb\x0d\x10\xdd\xaf\xad\xe2\x01\x38\x02\x42\x20\x4a\x20\x12\x61\x10\xc6\x50\x18\x06\x20\xc6\x50\x28" +
+	"\x02\x32\x0c\x08\xf4\xd7\x9a\xce\x0d\x10\xec\xa4\x96\xe9\x01\x38\xc8\x50\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x0a\x18\x0a\x20\x0c" +
+	"\x28\x06\x32\x0c\x08\xd0\xdd\xdd\x81\x0d\x10\x96\xd4\xbe\xc2\x02\x38\x0c\x42\x20\x4a\x20\x12\x5a\x10\x01\x18\x08\x20\x01\x32\x0c" +
+	"\x08\xaa\x8a\xe6\xfe\x0d\x10\xb9\xb0\xe6\x88\x01\x42\x20\x4a\x20\x50\x02\x12\x61\x10\xfe\x02\x18\x0c\x20\xfe\x02\x28\x0a\x32\x0c" +
+	"\x08\xec\x88\xff\xe1\x0d\x10\xc2\x89\xfb\xfe\x02\x38\x80\x03\x42\x20\x4a\x20\x50\x02\x12\x61\x10\x8a\x01\x18\x02\x20\x8a\x01\x28" +
+	"\x04\x32\x0c\x08\x94\x98\x85\xa2\x0d\x10\xa3\x93\xbd\x93\x02\x38\x8c\x01\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\x98\x50\x18\x04\x20" +
+	"\x98\x50\x32\x0c\x08\xaa\x9e\x82\x96\x0e\x10\xbf\xc6\x89\xc1\x03\x38\x9a\x50\x42\x20\x4a\x20\x50\x02\x12\x60\x10\xa8\x05\x18\x02" +
+	"\x20\xa6\x05\x28\x04\x32\x0b\x08\xde\xa1\xff\xe1\x0c\x10\x9e\xdd\xfd\x33\x38\xaa\x05\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x60\x18" +
+	"\x04\x20\x62\x28\x02\x32\x0c\x08\x84\xc1\xe0\xb9\x0d\x10\xd2\xbd\x8f\xa2\x01\x38\x62\x42\x20\x4a\x20\x12\x5f\x10\xda\x08\x18\x02" +
+	"\x20\xd8\x08\x28\x06\x32\x0c\x08\xce\xed\xf8\xd7\x0d\x10\xdc\xc8\xe2\xfc\x02\x38\xdc\x08\x42\x20\x4a\x20\x12\x5c\x10\x44\x18\x06" +
+	"\x20\x42\x28\x08\x32\x0c\x08\xfa\xf3\xff\x9a\x0d\x10\xfe\xa5\x86\xa9\x01\x38\x46\x42\x20\x4a\x20\x12\x5c\x10\x76\x20\x76\x28\x02" +
+	"\x32\x0c\x08\xa8\xbb\xcf\xdc\x0d\x10\xc0\xf1\x90\x8b\x02\x38\x78\x42\x20\x4a\x20\x50\x02\x12\x5d\x10\xe8\x14\x20\xea\x14\x28\x06" +
+	"\x32\x0c\x08\xa4\xcb\xd7\xcf\x0d\x10\xb9\xd3\x92\xcd\x03\x38\xea\x14\x42\x20\x4a\x20\x12\x5a\x10\x52\x20\x52\x28\x02\x32\x0c\x08" +
+	"\xbe\xe9\xf5\xc1\x0d\x10\x98\xd1\xc6\x98\x01\x38\x54\x42\x20\x4a\x20\x12\x61\x10\x98\x2d\x18\x06\x20\x96\x2d\x28\x08\x32\x0c\x08" +
+	"\x96\xe6\xff\xe0\x0c\x10\x82\xb6\xa1\xae\x03\x38\x9a\x2d\x42\x20\x4a\x20\x50\x02\x12\x5d\x10\x9c\x24\x18\x06\x20\x9a\x24\x32\x0c" +
+	"\x08\x84\xf7\x92\xdb\x0c\x10\xf1\x83\xe0\xa4\x02\x38\x9e\x24\x42\x20\x4a\x20\x12\x5f\x10\xc8\x04\x20\xca\x04\x28\x04\x32\x0c\x08" +
+	"\x86\xe6\xb1\xe2\x0c\x10\xb9\xfa\xb3\xd7\x03\x38\xca\x04\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x04\x18\x06\x20\x06\x28\x02\x32\x0c" +
+	"\x08\xb8\xc4\xd6\x81\x0d\x10\xd3\x98\xe3\x9b\x03\x38\x06\x42\x20\x4a\x20\x12\x5d\x10\xfc\x72\x18\x02\x20\xfa\x72\x32\x0c\x08\xa2" +
+	"\x9b\xb2\x95\x0e\x10\xa6\xf4\xc8\xb1\x03\x38\xfe\x72\x42\x20\x4a\x20\x12\x5f\x10\xbc\x01\x18\x08\x20\xba\x01\x32\x0c\x08\x8c\x99" +
+	"\x9e\x96\x0e\x10\x83\xfa\xcf\x99\x01\x38\xbe\x01\x42\x20\x4a\x20\x50\x02\x12\x61\x10\xf8\x09\x18\x06\x20\xf6\x09\x28\x02\x32\x0c" +
+	"\x08\xfc\xd9\xb4\x94\x0d\x10\xd0\x82\xe8\xed\x01\x38\xfa\x09\x42\x20\x4a\x20\x50\x02\x12\x60\x10\xe8\x03\x18\x02\x20\xe6\x03\x28" +
+	"\x06\x32\x0b\x08\xac\xc6\xbd\xa7\x0e\x10\xc4\xa3\x81\x20\x38\xea\x03\x42\x20\x4a\x20\x50\x02\x12\x5d\x10\xca\x03\x18\x04\x20\xcc" +
+	"\x03\x32\x0c\x08\xb0\xcf\xb4\xe2\x0d\x10\xe3\xcf\xf0\x83\x03\x38\xcc\x03\x42\x20\x4a\x20\x12\x60\x10\xa6\x05\x18\x06\x20\xa4\x05" +
+	"\x28\x02\x32\x0b\x08\x9e\xc6\x83\xaa\x0e\x10\xe3\xc9\x88\x60\x38\xa8\x05\x42\x20\x4a\x20\x50\x02\x12\x5b\x10\x04\x18\x04\x20\x06" +
+	"\x28\x02\x32\x0b\x08\xfe\xdf\xf1\xb1\x0d\x10\xa2\xea\xb2\x72\x38\x06\x42\x20\x4a\x20\x12\x5c\x10\x02\x18\x04\x20\x04\x28\x0a\x32" +
+	"\x0c\x08\x80\xfc\xc2\x92\x0e\x10\xd4\xcf\xfd\xa4\x02\x38\x04\x42\x20\x4a\x20\x12\x60\x10\x80\x0d\x18\x02\x20\x80\x0d\x28\x06\x32" +
+	"\x0b\x08\xa6\xc8\xeb\xbc\x0d\x10\xf8\x8e\xbd\x5b\x38\x82\x0d\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\x96\x02\x20\x98\x02\x28\x02\x32" +
+	"\x0c\x08\xe6\xcf\xce\xb0\x0d\x10\xe0\xcf\xc3\xf4\x02\x38\x98\x02\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xa0\x13\x18\x04\x20\x9e\x13" +
+	"\x28\x02\x32\x0c\x08\xbe\xb2\xd0\xed\x0c\x10\x8e\xce\xe4\x91\x01\x38\xa2\x13\x42\x20\x4a\x20\x12\x5f\x10\x9e\x72\x18\x04\x20\x9e" +
+	"\x72\x28\x02\x32\x0c\x08\xc4\xe4\xbe\xc4\x0d\x10\x86\xb7\x9b\xfb\x02\x38\xa0\x72\x42\x20\x4a\x20\x12\x5d\x10\xe0\x1d\x20\xde\x1d" +
+	"\x28\x08\x32\x0c\x08\xba\xa4\x87\x9a\x0d\x10\xd5\xe1\xe0\xe3\x02\x38\xe2\x1d\x42\x20\x4a\x20\x12\x5d\x10\x14\x18\x04\x20\x16\x28" +
+	"\x06\x32\x0b\x08\xde\xa0\xfe\xbb\x0d\x10\xc4\xf5\xb8\x47\x38\x16\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\x8a\x03\x18\x02\x20\x8a\x03" +
+	"\x28\x08\x32\x0b\x08\xa4\xf4\xb1\x82\x0d\x10\xc8\xf3\x8b\x6a\x38\x8c\x03\x42\x20\x4a\x20\x12\x5f\x10\x8a\x03\x18\x02\x20\x88\x03" +
+	"\x28\x04\x32\x0c\x08\x88\x8f\xb5\x9a\x0e\x10\xbc\xe4\xe2\xb8\x01\x38\x8c\x03\x42\x20\x4a\x20\x12\x5b\x10\x42\x18\x02\x20\x42\x32" +
+	"\x0b\x08\xda\xfe\xad\xc6\x0d\x10\xee\xeb\xa2\x1b\x38\x44\x42\x20\x4a\x20\x50\x02\x12\x5a\x10\x01\x18\x04\x20\x03\x28\x08\x32\x0c" +
+	"\x08\xaa\xbb\xbf\xc9\x0d\x10\xf8\xf8\x86\xd5\x01\x42\x20\x4a\x20\x12\x5e\x10\x8c\x01\x18\x02\x20\x8c\x01\x28\x06\x32\x0b\x08\xe8" +
+	"\xf4\x93\xdc\x0d\x10\x91\x8a\xec\x6c\x38\x8e\x01\x42\x20\x4a\x20\x12\x5f\x10\xba\x02\x20\xb8\x02\x28\x06\x32\x0c\x08\xea\xeb\xce" +
+	"\x9a\x0e\x10\xc8\xbe\xea\xfb\x02\x38\xbc\x02\x42\x20\x4a\x20\x50\x02\x12\x5a\x10\x0a\x20\x08\x28\x0a\x32\x0c\x08\x90\xcc\xb1\xe7" +
+	"\x0d\x10\xb3\xc9\xf6\xc8\x01\x38\x0c\x42\x20\x4a\x20\x12\x5f\x10\xfe\x01\x20\x80\x02\x28\x06\x32\x0c\x08\xa8\xbf\xa2\xa8\x0e\x10" +
+	"\xdc\xe2\xca\x8b\x02\x38\x80\x02\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xde\x4c\x18\x04\x20\xe0\x4c\x32\x0c\x08\x80\x96\xaa\xde\x0d" +
+	"\x10\xb5\xc7\x8e\x96\x01\x38\xe0\x4c\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x28\x18\x06\x20\x2a\x28\x04\x32\x0c\x08\xd8\x81\xfa\xfe" +
+	"\x0c\x10\xe9\x8c\x94\xbc\x01\x38\x2a\x42\x20\x4a\x20\x12\x5a\x10\x34\x20\x34\x28\x08\x32\x0c\x08\xaa\xbc\xb8\xab\x0d\x10\xf6\xa8" +
+	"\xc0\x8f\x03\x38\x36\x42\x20\x4a\x20\x12\x5f\x10\xae\x17\x18\x08\x20\xac\x17\x28\x04\x32\x0c\x08\xd2\xc5\xf2\xdb\x0d\x10\x9d\xfe" +
+	"\x91\xbf\x01\x38\xb0\x17\x42\x20\x4a\x20\x12\x5c\x10\x02\x18\x0c\x28\x06\x32\x0c\x08\x86\xcf\x86\x85\x0e\x10\xb8\xd6\xac\xcf\x01" +
+	"\x38\x04\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\x1a\x18\x08\x20\x1a\x28\x02\x32\x0c\x08\xb0\xac\x8e\xbc\x0d\x10\xeb\x86\xd7\xab\x03" +
+	"\x38\x1c\x42\x20\x4a\x20\x50\x02\x12\x58\x10\x01\x20\x03\x28\x04\x32\x0c\x08\xb2\xc9\xe7\xe6\x0d\x10\xbd\xf3\xfd\x91\x03\x42\x20" +
+	"\x4a\x20\x12\x5c\x10\x9c\x01\x18\x02\x20\x9c\x01\x32\x0b\x08\xa4\xbd\xfa\x92\x0e\x10\xfd\x93\x9d\x31\x38\x9e\x01\x42\x20\x4a\x20" +
+	"\x12\x61\x10\xa0\x05\x18\x06\x20\xa0\x05\x28\x04\x32\x0c\x08\xb0\xf1\xf3\xe1\x0c\x10\xdd\xa2\x8a\x8f\x03\x38\xa2\x05\x42\x20\x4a" +
+	"\x20\x50\x02\x12\x5e\x10\xd2\x2a\x18\x04\x20\xd0\x2a\x28\x08\x32\x0b\x08\xc0\xb1\xd1\xf8\x0c\x10\xd3\xab\xcd\x7c\x38\xd4\x2a\x42" +
+	"\x20\x4a\x20\x12\x56\x18\x02\x32\x0c\x08\xf4\x85\xc7\xee\x0d\x10\xd6\x84\x82\x9f\x01\x38\x02\x42\x20\x4a\x20\x12\x5c\x10\x02\x20" +
+	"\x02\x28\x02\x32\x0c\x08\xf0\xb9\xbb\xdc\x0c\x10\xd9\xa8\xad\xd4\x02\x38\x04\x42\x20\x4a\x20\x50\x02\x12\x5b\x10\x04\x18\x08\x20" +
+	"\x02\x28\x06\x32\x0b\x08\xae\xf1\x99\xe6\x0c\x10\xf4\xd3\xfc\x46\x38\x06\x42\x20\x4a\x20\x12\x5c\x18\x04\x20\x01\x28\x02\x32\x0c" +
+	"\x08\x92\xb0\xed\x87\x0d\x10\xaa\xa0\xdb\xb0\x03\x38\x02\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xf6\x02\x18\x04\x20\xf4\x02\x28\x02" +
+	"\x32\x0c\x08\xb8\x9e\xe8\xf2\x0c\x10\xb9\x95\x8a\xee\x02\x38\xf8\x02\x42\x20\x4a\x20\x12\x5e\x10\x16\x18\x06\x20\x14\x28\x0a\x32" +
+	"\x0c\x08\x9c\xbe\xe2\xcd\x0d\x10\x85\xaa\xf6\xf1\x02\x38\x18\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\x4a\x18\x04\x20\x4a\x28\x08\x32" +
+	"\x0c\x08\x9e\xe1\xc2\xdc\x0d\x10\xc0\xec\xe8\xa3\x03\x38\x4c\x42\x20\x4a\x20\x50\x02\x12\x5a\x10\x08\x18\x04\x20\x06\x32\x0c\x08" +
+	"\xba\xbf\xe8\xe7\x0c\x10\x96\xf3\xe8\xa5\x01\x38\x0a\x42\x20\x4a\x20\x12\x5a\x10\x02\x20\x02\x28\x02\x32\x0c\x08\xe4\xac\xa2\xf4" +
+	"\x0c\x10\x90\xd8\xf5\x99\x01\x38\x04\x42\x20\x4a\x20\x12\x5d\x10\x6e\x18\x0c\x20\x6e\x28\x0a\x32\x0b\x08\xf6\xf6\x8e\xe7\x0c\x10" +
+	"\xc0\xf0\x91\x1e\x38\x70\x42\x20\x4a\x20\x50\x02\x12\x61\x10\x84\x16\x18\x04\x20\x82\x16\x28\x08\x32\x0c\x08\x98\xe4\xe5\xa7\x0d" +
+	"\x10\xab\x89\xda\xd4\x03\x38\x86\x16\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xf4\x52\x18\x06\x20\xf2\x52\x28\x02\x32\x0c\x08\xae\xd4" +
+	"\xf9\xa3\x0e\x10\xb2\xb3\xdb\xf3\x01\x38\xf6\x52\x42\x20\x4a\x20\x12\x61\x10\xee\x02\x18\x04\x20\xec\x02\x28\x02\x32\x0c\x08\x94" +
+	"\x83\x9d\xfd\x0d\x10\xe4\xb4\xa1\xbf\x03\x38\xf0\x02\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x04\x18\x04\x20\x04\x32\x0c\x08\xb2\xb4" +
+	"\x8b\x9e\x0e\x10\xaa\xa2\xc5\x87\x03\x38\x06\x42\x20\x4a\x20\x50\x02\x12\x5e\x10\x5e\x18\x02\x20\x60\x28\x04\x32\x0c\x08\xec\xe1" +
+	"\x89\xf4\x0c\x10\xcf\xb4\xb0\xb5\x02\x38\x60\x42\x20\x4a\x20\x50\x02\x12\x5a\x20\x02\x28\x02\x32\x0c\x08\xe4\xd2\xb9\x95\x0e\x10" +
+	"\xc3\xc9\xbe\xd3\x03\x38\x02\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xce\x01\x18\x02\x20\xd0\x01\x28\x06\x32\x0c\x08\xd2\xb1\xaf\xf9" +
+	"\x0d\x10\xad\xdb\xdf\xca\x01\x38\xd0\x01\x42\x20\x4a\x20\x12\x5e\x10\x02\x18\x02\x20\x02\x28\x04\x32\x0c\x08\xe0\xf9\x95\xf6\x0d" +
+	"\x10\xa0\xdc\x9b\x91\x01\x38\x04\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x74\x18\x02\x20\x76\x32\x0c\x08\xe8\xaa\xf6\xd6\x0d\x10\x9f" +
+	"\xd8\xf1\xb3\x03\x38\x76\x42\x20\x4a\x20\x50\x02\x12\x5d\x10\x1e\x18\x06\x20\x1e\x28\x02\x32\x0b\x08\x90\xef\xc1\xd9\x0d\x10\xf2" +
+	"\xd1\xe1\x42\x38\x20\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x04\x20\x06\x28\x02\x32\x0c\x08\x8c\xbd\xca\x8a\x0e\x10\x82\xd7\xfe\xd4" +
+	"\x02\x38\x06\x42\x20\x4a\x20\x50\x02\x12\x5d\x10\x56\x18\x02\x20\x58\x28\x06\x32\x0b\x08\xd4\x8d\xa3\xeb\x0c\x10\x9e\xdd\xf5\x29" +
+	"\x38\x58\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x0c\x20\x0a\x28\x06\x32\x0c\x08\xae\xf0\xc0\xca\x0d\x10\xff\xf1\x80\xf5\x02\x38\x0e" +
+	"\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xce\x02\x18\x06\x20\xce\x02\x28\x04\x32\x0c\x08\xe6\x88\x8b\x99\x0d\x10\xf2\xac\xe7\xb5\x03" +
+	"\x38\xd0\x02\x42\x20\x4a\x20\x12\x61\x10\x88\x02\x18\x02\x20\x8a\x02\x28\x08\x32\x0c\x08\xfc\x90\xda\xf7\x0d\x10\xc2\x84\xf8\x95" +
+	"\x02\x38\x8a\x02\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x1c\x18\x04\x20\x1e\x32\x0c\x08\xe0\xcc\xe4\xa5\x0d\x10\xdd\xfe\x9e\xca\x01" +
+	"\x38\x1e\x42\x20\x4a\x20\x50\x02\x12\x5a\x10\x6e\x20\x6c\x28\x02\x32\x0c\x08\xcc\xe4\xaa\xf3\x0c\x10\xd7\x89\x81\x8b\x03\x38\x70" +
+	"\x42\x20\x4a\x20\x12\x5c\x10\x0c\x18\x04\x20\x0e\x32\x0c\x08\xbe\xe4\x81\xa2\x0d\x10\x97\x89\x96\xdc\x02\x38\x0e\x42\x20\x4a\x20" +
+	"\x50\x02\x12\x5e\x10\x36\x18\x04\x20\x34\x28\x02\x32\x0c\x08\x8a\xa5\x9c\xa0\x0e\x10\xf6\xf0\xff\xe6\x02\x38\x38\x42\x20\x4a\x20" +
+	"\x50\x02\x12\x5b\x10\x70\x18\x04\x20\x72\x32\x0b\x08\xb4\xc5\x82\xee\x0c\x10\xab\x9f\x80\x3d\x38\x72\x42\x20\x4a\x20\x50\x02\x12" +
+	"\x5a\x18\x02\x28\x04\x32\x0c\x08\xde\xee\xa5\xa3\x0e\x10\x80\xae\xfb\xf5\x01\x38\x02\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x0a\x18" +
+	"\x06\x20\x08\x28\x02\x32\x0c\x08\xdc\xc1\xfa\xd4\x0d\x10\xeb\xf1\x9a\xb9\x03\x38\x0c\x42\x20\x4a\x20\x12\x5f\x10\xcc\x4c\x18\x04" +
+	"\x20\xce\x4c\x32\x0c\x08\xa2\x87\x88\xba\x0d\x10\x94\xf5\x8d\xc9\x02\x38\xce\x4c\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\x06\x18\x04" +
+	"\x20\x06\x28\x06\x32\x0c\x08\x96\xb4\xa4\x8f\x0d\x10\xc0\xc5\x94\x93\x02\x38\x08\x42\x20\x4a\x20\x12\x61\x10\xcc\x07\x18\x0a\x20" +
+	"\xce\x07\x28\x08\x32\x0c\x08\xa2\x99\xf5\xf6\x0c\x10\xbf\xfb\xa5\xc3\x02\x38\xce\x07\x42\x20\x4a\x20\x50\x02\x12\x5c\x10\xaa\x0f" +
+	"\x20\xa8\x0f\x28\x02\x32\x0b\x08\xfc\xb5\xb0\x8d\x0e\x10\x95\xde\x8c\x32\x38\xac\x0f\x42\x20\x4a\x20\x12\x5b\x10\x40\x18\x04\x20" +
+	"\x3e\x32\x0b\x08\xc2\xed\xff\x81\x0e\x10\xb0\xd8\xb8\x46\x38\x42\x42\x20\x4a\x20\x50\x02\x12\x60\x10\xc8\x0f\x18\x0c\x20\xca\x0f" +
+	"\x28\x0a\x32\x0b\x08\xba\xd7\xae\x83\x0e\x10\xd2\x88\x89\x0b\x38\xca\x0f\x42\x20\x4a\x20\x50\x02\x12\x60\x10\xa0\x0d\x18\x02\x20" +
+	"\xa2\x0d\x28\x06\x32\x0b\x08\xc8\xab\xbc\xf1\x0d\x10\x88\xe2\x93\x40\x38\xa2\x0d\x42\x20\x4a\x20\x50\x02\x12\x5f\x10\xbc\x15\x18" +
+	"\x02\x20\xbc\x15\x28\x04\x32\x0c\x08\xe0\xda\xfe\xef\x0d\x10\xe7\x88\xc8\x9e\x03\x38\xbe\x15\x42\x20\x4a\x20\x12\x61\x10\x98\x35" +
+	"\x18\x06\x20\x9a\x35\x28\x08\x32\x0c\x08\xa0\x8d\xa3\xb8\x0d\x10\xf1\xd0\xb2\xe9\x01\x38\x9a\x35\x42\x20\x4a\x20\x50\x02\x12\x5f")
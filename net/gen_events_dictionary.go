@@ -0,0 +1,91 @@
+//go:build ignore
+// +build ignore
+
+// This program writes events_dictionary_v1.go. Run it with go generate.
+//
+// The dictionary is the encoding of sample Events of an idle network, in the
+// format of CompressEvents, without their signatures: the signatures are
+// random, so only the tags, lengths, small integers and timestamps around them
+// can be found again in real syncs. Changing the samples changes the
+// dictionary, which then needs a new version: nodes only decompress with the
+// dictionaries they know.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"math/rand"
+	"time"
+
+	"github.com/babbleio/babble/codec"
+	"github.com/babbleio/babble/hashgraph"
+)
+
+const dictionarySize = 8 * 1024
+
+func main() {
+	r := rand.New(rand.NewSource(1))
+	sig := new(big.Int).Lsh(big.NewInt(1), 255)
+
+	var dict []byte
+	for len(dict) < dictionarySize {
+		peers := 3 + r.Intn(5)
+		index := r.Intn(1 << uint(2+r.Intn(12)))
+		creator := r.Intn(peers)
+		other := (creator + 1 + r.Intn(peers-1)) % peers
+		we := hashgraph.WireEvent{
+			Body: hashgraph.WireBody{
+				SelfParentIndex:      index - 1,
+				OtherParentCreatorID: other,
+				OtherParentIndex:     index - r.Intn(3),
+				CreatorID:            creator,
+				Timestamp:            time.Date(2024+r.Intn(7), time.Month(1+r.Intn(12)), 1+r.Intn(28), r.Intn(24), r.Intn(60), r.Intn(60), r.Intn(1e9), time.UTC),
+				Index:                index,
+				Version:              r.Intn(2),
+			},
+		}
+
+		//the header of the Event in the list, as if it was signed
+		signed := we
+		signed.R, signed.S = sig, sig
+		enc := signed.MarshalProto()
+		field := codec.AppendBytes(nil, 2, enc)
+		header := field[:len(field)-len(enc)]
+
+		//the Event without the bytes of its signature
+		body := we.MarshalProto()
+		version := codec.AppendInt(nil, 10, we.Body.Version)
+		sample := append([]byte{}, header...)
+		sample = append(sample, body[:len(body)-len(version)]...)
+		sample = append(sample, 0x42, 0x20, 0x4a, 0x20)
+		sample = append(sample, version...)
+		dict = append(dict, sample...)
+	}
+	dict = dict[:dictionarySize]
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by gen_events_dictionary.go; DO NOT EDIT.\n\n")
+	b.WriteString("package net\n\n")
+	b.WriteString("var eventsDictionaryV1 = []byte(\"\" +\n")
+	for i := 0; i < len(dict); i += 32 {
+		end := i + 32
+		if end > len(dict) {
+			end = len(dict)
+		}
+		sep := " +"
+		if end == len(dict) {
+			sep = ")"
+		}
+		b.WriteString("\t\"")
+		for _, c := range dict[i:end] {
+			fmt.Fprintf(&b, "\\x%02x", c)
+		}
+		b.WriteString("\"" + sep + "\n")
+	}
+	if err := ioutil.WriteFile("events_dictionary_v1.go", b.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
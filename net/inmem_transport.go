@@ -1,6 +1,7 @@
 package net
 
 import (
+	"context"
 	"crypto/rand"
 	"fmt"
 	"io"
@@ -65,45 +66,67 @@ func (i *InmemTransport) LocalAddr() string {
 }
 
 // Sync implements the Transport interface.
-func (i *InmemTransport) Sync(target string, args *SyncRequest, resp *SyncResponse) error {
-	rpcResp, err := i.makeRPC(target, args, nil, i.timeout)
+func (i *InmemTransport) Sync(ctx context.Context, target string, args *SyncRequest, resp *SyncResponse) error {
+	rpcResp, err := i.makeRPC(ctx, target, args, nil, i.timeout)
+
+	// Copy the result back, even on error for its ErrorCode
+	if out, ok := rpcResp.Response.(*SyncResponse); ok {
+		*resp = *out
+	}
+	return err
+}
+
+// Sync implements the Transport interface.
+func (i *InmemTransport) EagerSync(ctx context.Context, target string, args *EagerSyncRequest, resp *EagerSyncResponse) error {
+	rpcResp, err := i.makeRPC(ctx, target, args, nil, i.timeout)
+
+	// Copy the result back, even on error for its ErrorCode
+	if out, ok := rpcResp.Response.(*EagerSyncResponse); ok {
+		*resp = *out
+	}
+	return err
+}
+
+// FastForward implements the Transport interface.
+func (i *InmemTransport) FastForward(ctx context.Context, target string, args *FastForwardRequest, resp *FastForwardResponse) error {
+	rpcResp, err := i.makeRPC(ctx, target, args, nil, i.timeout)
 	if err != nil {
 		return err
 	}
 
 	// Copy the result back
-	out := rpcResp.Response.(*SyncResponse)
+	out := rpcResp.Response.(*FastForwardResponse)
 	*resp = *out
 	return nil
 }
 
-// Sync implements the Transport interface.
-func (i *InmemTransport) EagerSync(target string, args *EagerSyncRequest, resp *EagerSyncResponse) error {
-	rpcResp, err := i.makeRPC(target, args, nil, i.timeout)
+// Fetch implements the Transport interface.
+func (i *InmemTransport) Fetch(target string, args *FetchRequest, resp *FetchResponse) error {
+	rpcResp, err := i.makeRPC(context.Background(), target, args, nil, i.timeout)
 	if err != nil {
 		return err
 	}
 
 	// Copy the result back
-	out := rpcResp.Response.(*EagerSyncResponse)
+	out := rpcResp.Response.(*FetchResponse)
 	*resp = *out
 	return nil
 }
 
-// FastForward implements the Transport interface.
-func (i *InmemTransport) FastForward(target string, args *FastForwardRequest, resp *FastForwardResponse) error {
-	rpcResp, err := i.makeRPC(target, args, nil, i.timeout)
+// Join implements the Transport interface.
+func (i *InmemTransport) Join(target string, args *JoinRequest, resp *JoinResponse) error {
+	rpcResp, err := i.makeRPC(context.Background(), target, args, nil, i.timeout)
 	if err != nil {
 		return err
 	}
 
 	// Copy the result back
-	out := rpcResp.Response.(*FastForwardResponse)
+	out := rpcResp.Response.(*JoinResponse)
 	*resp = *out
 	return nil
 }
 
-func (i *InmemTransport) makeRPC(target string, args interface{}, r io.Reader, timeout time.Duration) (rpcResp RPCResponse, err error) {
+func (i *InmemTransport) makeRPC(ctx context.Context, target string, args interface{}, r io.Reader, timeout time.Duration) (rpcResp RPCResponse, err error) {
 	i.RLock()
 	peer, ok := i.peers[target]
 	i.RUnlock()
@@ -113,13 +136,20 @@ func (i *InmemTransport) makeRPC(target string, args interface{}, r io.Reader, t
 		return
 	}
 
-	// Send the RPC over
-	respCh := make(chan RPCResponse)
-	peer.consumerCh <- RPC{
+	// Send the RPC over. The response channel is buffered so that the peer
+	// does not block on an RPC that was given up.
+	respCh := make(chan RPCResponse, 1)
+	rpc := RPC{
 		Command:  args,
 		Reader:   r,
 		RespChan: respCh,
 	}
+	select {
+	case peer.consumerCh <- rpc:
+	case <-ctx.Done():
+		err = ctx.Err()
+		return
+	}
 
 	// Wait for a response
 	select {
@@ -127,6 +157,8 @@ func (i *InmemTransport) makeRPC(target string, args interface{}, r io.Reader, t
 		if rpcResp.Error != nil {
 			err = rpcResp.Error
 		}
+	case <-ctx.Done():
+		err = ctx.Err()
 	case <-time.After(timeout):
 		err = fmt.Errorf("command timed out")
 	}
@@ -0,0 +1,183 @@
+package net
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// PubKeyAnnotation is the pod annotation holding the public key of the
+	// node running in the pod
+	PubKeyAnnotation = "babble.io/pubkey"
+
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+)
+
+// KubernetesPeers is a read-only PeerStore that lists the pods matching a
+// label selector through the Kubernetes API. The address of a peer is the IP of
+// its pod and its public key is read from the PubKeyAnnotation of the pod,
+// which every node sets on its own pod with Announce. Pods that are not
+// running or not yet announced are skipped.
+type KubernetesPeers struct {
+	apiURL    string
+	token     string
+	namespace string
+	selector  string
+	port      int
+	client    *http.Client
+}
+
+// NewKubernetesPeers creates a KubernetesPeers store from the service account
+// mounted in the pod. port is the port that the nodes bind to.
+func NewKubernetesPeers(namespace, selector string, port int) (*KubernetesPeers, error) {
+	host, hostPort := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || hostPort == "" {
+		return nil, fmt.Errorf("Not running in a Kubernetes cluster")
+	}
+
+	token, err := ioutil.ReadFile(serviceAccountDir + "/token")
+	if err != nil {
+		return nil, err
+	}
+	ca, err := ioutil.ReadFile(serviceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(ca) {
+		return nil, fmt.Errorf("Invalid service account CA certificate")
+	}
+
+	if namespace == "" {
+		ns, err := ioutil.ReadFile(serviceAccountDir + "/namespace")
+		if err != nil {
+			return nil, err
+		}
+		namespace = string(ns)
+	}
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		},
+	}
+	apiURL := "https://" + net.JoinHostPort(host, hostPort)
+
+	return newKubernetesPeers(apiURL, string(token), namespace, selector, port, client), nil
+}
+
+func newKubernetesPeers(apiURL, token, namespace, selector string, port int, client *http.Client) *KubernetesPeers {
+	return &KubernetesPeers{
+		apiURL:    apiURL,
+		token:     token,
+		namespace: namespace,
+		selector:  selector,
+		port:      port,
+		client:    client,
+	}
+}
+
+type podList struct {
+	Items []pod `json:"items"`
+}
+
+type pod struct {
+	Metadata struct {
+		Name        string            `json:"name"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Status struct {
+		Phase string `json:"phase"`
+		PodIP string `json:"podIP"`
+	} `json:"status"`
+}
+
+// Peers implements the PeerStore interface.
+func (k *KubernetesPeers) Peers() ([]Peer, error) {
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods?labelSelector=%s",
+		url.PathEscape(k.namespace), url.QueryEscape(k.selector))
+
+	var list podList
+	if err := k.do("GET", path, "", nil, &list); err != nil {
+		return nil, err
+	}
+
+	peers := []Peer{}
+	for _, p := range list.Items {
+		pubKey := p.Metadata.Annotations[PubKeyAnnotation]
+		if p.Status.Phase != "Running" || p.Status.PodIP == "" || pubKey == "" {
+			continue
+		}
+		peers = append(peers, Peer{
+			NetAddr:   net.JoinHostPort(p.Status.PodIP, strconv.Itoa(k.port)),
+			PubKeyHex: pubKey,
+		})
+	}
+	sort.Sort(ByPubKey(peers))
+	return peers, nil
+}
+
+// SetPeers implements the PeerStore interface. The list of peers is owned by
+// Kubernetes so it cannot be set.
+func (k *KubernetesPeers) SetPeers([]Peer) error {
+	return fmt.Errorf("Kubernetes peers are read-only")
+}
+
+// Announce publishes the public key of the node running in podName by
+// annotating the pod
+func (k *KubernetesPeers) Announce(podName, pubKeyHex string) error {
+	patch := map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{
+				PubKeyAnnotation: pubKeyHex,
+			},
+		},
+	}
+	body, err := json.Marshal(patch)
+	if err != nil {
+		return err
+	}
+	path := fmt.Sprintf("/api/v1/namespaces/%s/pods/%s",
+		url.PathEscape(k.namespace), url.PathEscape(podName))
+	return k.do("PATCH", path, "application/merge-patch+json", body, nil)
+}
+
+func (k *KubernetesPeers) do(method, path, contentType string, body []byte, out interface{}) error {
+	req, err := http.NewRequest(method, k.apiURL+path, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if k.token != "" {
+		req.Header.Set("Authorization", "Bearer "+k.token)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := k.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("Kubernetes API %s %s: %s %s", method, path, resp.Status, msg)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
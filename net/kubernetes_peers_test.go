@@ -0,0 +1,80 @@
+package net
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestKubernetesPeers(t *testing.T) {
+	pods := []pod{}
+	addPod := func(name, phase, ip, pubKey string) {
+		var p pod
+		p.Metadata.Name = name
+		if pubKey != "" {
+			p.Metadata.Annotations = map[string]string{PubKeyAnnotation: pubKey}
+		}
+		p.Status.Phase = phase
+		p.Status.PodIP = ip
+		pods = append(pods, p)
+	}
+	addPod("babble-0", "Running", "10.0.0.2", "0xBB")
+	addPod("babble-1", "Running", "10.0.0.1", "")
+	addPod("babble-2", "Pending", "", "0xCC")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/api/v1/namespaces/default/pods":
+			if s := r.URL.Query().Get("labelSelector"); s != "app=babble" {
+				t.Errorf("labelSelector: %s", s)
+			}
+			json.NewEncoder(w).Encode(podList{Items: pods})
+		case r.Method == "PATCH" && r.URL.Path == "/api/v1/namespaces/default/pods/babble-1":
+			var patch pod
+			if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+				t.Errorf("patch: %v", err)
+			}
+			pods[1].Metadata.Annotations = patch.Metadata.Annotations
+			w.Write([]byte(`{}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	store := newKubernetesPeers(server.URL, "token", "default", "app=babble", 1337, server.Client())
+
+	// Only running and announced pods are listed
+	peers, err := store.Peers()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(peers) != 1 || peers[0].NetAddr != "10.0.0.2:1337" || peers[0].PubKeyHex != "0xBB" {
+		t.Fatalf("peers: %v", peers)
+	}
+
+	if err := store.Announce("babble-1", "0xAA"); err != nil {
+		t.Fatalf("err: %v", err)
+	}
+
+	// Peers are sorted by public key
+	peers, err = store.Peers()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	if len(peers) != 2 || peers[0].NetAddr != "10.0.0.1:1337" || peers[0].PubKeyHex != "0xAA" {
+		t.Fatalf("peers: %v", peers)
+	}
+
+	if err := store.Announce("babble-9", "0xDD"); err == nil {
+		t.Fatalf("Announce for unknown pod should fail")
+	}
+	if err := store.SetPeers(peers); err == nil {
+		t.Fatalf("SetPeers should fail")
+	}
+}
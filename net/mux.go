@@ -0,0 +1,432 @@
+package net
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+/*
+A mux session carries many streams over a single connection, so that the RPCs
+to a peer share one TCP (and TLS) handshake however many of them run at the same
+time. The NetworkTransport opens a session to the peers enabled with
+SetPeerMultiplex and uses its streams like connections: they are pooled, and
+every stream is served on the other side like an inbound connection.
+
+The dialer starts the session with the muxPreface byte, which the receiver tells
+apart from the type byte of a plain connection. Then both sides write frames: a
+header with the id of the stream, a flag and the length of the payload, followed
+by the payload. Only the dialer opens streams, with increasing ids; the first
+frame of an id opens it on the other side. A muxClose frame closes the stream for
+both sides.
+
+Frames are read by a single goroutine which buffers their payload in the stream,
+so a stream that is not read does not hold the others back.
+*/
+
+const (
+	// muxPreface is the first byte of a connection that carries a mux session.
+	muxPreface uint8 = 0x40
+
+	muxData  uint8 = 0
+	muxClose uint8 = 1
+
+	muxHeaderSize = 9
+	// muxMaxFrame is the largest payload of a frame. Larger writes are split.
+	muxMaxFrame = 64 * 1024
+)
+
+var (
+	errMuxClosed   = errors.New("mux stream closed")
+	errMuxShutdown = errors.New("mux session closed")
+	errMuxTimeout  = muxTimeoutError{}
+)
+
+type muxTimeoutError struct{}
+
+func (muxTimeoutError) Error() string   { return "i/o timeout" }
+func (muxTimeoutError) Timeout() bool   { return true }
+func (muxTimeoutError) Temporary() bool { return true }
+
+type muxSession struct {
+	conn   net.Conn
+	r      io.Reader
+	client bool
+
+	writeLock sync.Mutex
+	w         *bufio.Writer
+
+	lock     sync.Mutex
+	streams  map[uint32]*muxStream
+	lastID   uint32
+	acceptCh chan *muxStream
+	closeCh  chan struct{}
+	err      error
+	draining bool //closes the session once its last stream is closed
+}
+
+// newMuxSession starts a session on conn. The reader is the one frames are read
+// from, which may buffer the start of conn. The client side opens streams and
+// the server side accepts them.
+func newMuxSession(conn net.Conn, r io.Reader, client bool) *muxSession {
+	s := &muxSession{
+		conn:     conn,
+		r:        r,
+		client:   client,
+		w:        bufio.NewWriter(conn),
+		streams:  make(map[uint32]*muxStream),
+		acceptCh: make(chan *muxStream),
+		closeCh:  make(chan struct{}),
+	}
+	go s.readFrames()
+	return s
+}
+
+// open opens a new stream. Only clients open streams.
+func (s *muxSession) open() (*muxStream, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.err != nil {
+		return nil, s.err
+	}
+	s.lastID++
+	stream := newMuxStream(s, s.lastID)
+	s.streams[stream.id] = stream
+	return stream, nil
+}
+
+// accept returns the streams opened by the client, until the session is closed.
+func (s *muxSession) accept() (*muxStream, error) {
+	select {
+	case stream := <-s.acceptCh:
+		return stream, nil
+	case <-s.closeCh:
+		return nil, s.closeErr()
+	}
+}
+
+// closed is true once the connection of the session failed or was closed.
+func (s *muxSession) closed() bool {
+	select {
+	case <-s.closeCh:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *muxSession) closeErr() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+// Close closes the connection and all the streams.
+func (s *muxSession) Close() error {
+	s.shutdown(errMuxShutdown)
+	return nil
+}
+
+func (s *muxSession) shutdown(err error) {
+	s.lock.Lock()
+	if s.err != nil {
+		s.lock.Unlock()
+		return
+	}
+	s.err = err
+	streams := s.streams
+	s.streams = make(map[uint32]*muxStream)
+	close(s.closeCh)
+	s.lock.Unlock()
+
+	s.conn.Close()
+	for _, stream := range streams {
+		stream.notify()
+	}
+}
+
+func (s *muxSession) remove(id uint32) {
+	s.lock.Lock()
+	delete(s.streams, id)
+	idle := s.draining && len(s.streams) == 0
+	s.lock.Unlock()
+	if idle {
+		s.Close()
+	}
+}
+
+// drain closes the session once the streams that are open are closed, right
+// away if there are none. No stream should be opened on it anymore.
+func (s *muxSession) drain() {
+	s.lock.Lock()
+	s.draining = true
+	idle := len(s.streams) == 0
+	s.lock.Unlock()
+	if idle {
+		s.Close()
+	}
+}
+
+// writeFrame writes a frame before the deadline, if any. A failed write leaves
+// the connection in an unknown state, so it closes the session.
+func (s *muxSession) writeFrame(id uint32, flag uint8, payload []byte, deadline time.Time) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	if s.closed() {
+		return s.closeErr()
+	}
+
+	var header [muxHeaderSize]byte
+	binary.BigEndian.PutUint32(header[0:4], id)
+	header[4] = flag
+	binary.BigEndian.PutUint32(header[5:9], uint32(len(payload)))
+
+	s.conn.SetWriteDeadline(deadline)
+	_, err := s.w.Write(header[:])
+	if err == nil {
+		_, err = s.w.Write(payload)
+	}
+	if err == nil {
+		err = s.w.Flush()
+	}
+	if err != nil {
+		s.shutdown(err)
+	}
+	return err
+}
+
+// readFrames dispatches the frames to their stream until the connection fails.
+func (s *muxSession) readFrames() {
+	var header [muxHeaderSize]byte
+	for {
+		if _, err := io.ReadFull(s.r, header[:]); err != nil {
+			s.shutdown(err)
+			return
+		}
+		id := binary.BigEndian.Uint32(header[0:4])
+		flag := header[4]
+		size := binary.BigEndian.Uint32(header[5:9])
+		if size > muxMaxFrame {
+			s.shutdown(errors.New("mux frame too large"))
+			return
+		}
+		payload := make([]byte, size)
+		if _, err := io.ReadFull(s.r, payload); err != nil {
+			s.shutdown(err)
+			return
+		}
+
+		stream, err := s.stream(id, flag)
+		if err != nil {
+			s.shutdown(err)
+			return
+		}
+		if stream == nil {
+			continue
+		}
+		switch flag {
+		case muxData:
+			stream.received(payload)
+		case muxClose:
+			stream.remoteClose()
+		}
+	}
+}
+
+// stream returns the stream of a frame, which may open it on the server side.
+// Frames of streams that are already closed are dropped.
+func (s *muxSession) stream(id uint32, flag uint8) (*muxStream, error) {
+	s.lock.Lock()
+	stream, ok := s.streams[id]
+	if ok || s.client || flag != muxData || id <= s.lastID {
+		s.lock.Unlock()
+		return stream, nil
+	}
+	s.lastID = id
+	stream = newMuxStream(s, id)
+	s.streams[id] = stream
+	s.lock.Unlock()
+
+	select {
+	case s.acceptCh <- stream:
+		return stream, nil
+	case <-s.closeCh:
+		return nil, s.closeErr()
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// muxStream is a stream of a mux session. It implements net.Conn.
+type muxStream struct {
+	id      uint32
+	session *muxSession
+
+	lock          sync.Mutex
+	buf           bytes.Buffer
+	notifyCh      chan struct{}
+	remoteClosed  bool
+	localClosed   bool
+	readDeadline  time.Time
+	writeDeadline time.Time
+}
+
+func newMuxStream(s *muxSession, id uint32) *muxStream {
+	return &muxStream{
+		id:       id,
+		session:  s,
+		notifyCh: make(chan struct{}, 1),
+	}
+}
+
+func (m *muxStream) notify() {
+	select {
+	case m.notifyCh <- struct{}{}:
+	default:
+	}
+}
+
+func (m *muxStream) received(payload []byte) {
+	m.lock.Lock()
+	m.buf.Write(payload)
+	m.lock.Unlock()
+	m.notify()
+}
+
+func (m *muxStream) remoteClose() {
+	m.lock.Lock()
+	m.remoteClosed = true
+	m.lock.Unlock()
+	m.session.remove(m.id)
+	m.notify()
+}
+
+// closedByPeer is true once the other side closed the stream.
+func (m *muxStream) closedByPeer() bool {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	return m.remoteClosed
+}
+
+// Read implements net.Conn. It returns io.EOF once the other side closed the
+// stream and its data was read.
+func (m *muxStream) Read(b []byte) (int, error) {
+	for {
+		m.lock.Lock()
+		if m.buf.Len() > 0 {
+			n, _ := m.buf.Read(b)
+			m.lock.Unlock()
+			return n, nil
+		}
+		remoteClosed, localClosed, deadline := m.remoteClosed, m.localClosed, m.readDeadline
+		m.lock.Unlock()
+
+		switch {
+		case localClosed:
+			return 0, errMuxClosed
+		case remoteClosed:
+			return 0, io.EOF
+		case m.session.closed():
+			return 0, m.session.closeErr()
+		}
+
+		if deadline.IsZero() {
+			<-m.notifyCh
+			continue
+		}
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return 0, errMuxTimeout
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-m.notifyCh:
+			timer.Stop()
+		case <-timer.C:
+			return 0, errMuxTimeout
+		}
+	}
+}
+
+// Write implements net.Conn.
+func (m *muxStream) Write(b []byte) (int, error) {
+	m.lock.Lock()
+	closed := m.localClosed || m.remoteClosed
+	deadline := m.writeDeadline
+	m.lock.Unlock()
+	if closed {
+		return 0, errMuxClosed
+	}
+	if !deadline.IsZero() && time.Now().After(deadline) {
+		return 0, errMuxTimeout
+	}
+
+	written := 0
+	for written < len(b) {
+		end := written + muxMaxFrame
+		if end > len(b) {
+			end = len(b)
+		}
+		if err := m.session.writeFrame(m.id, muxData, b[written:end], deadline); err != nil {
+			return written, err
+		}
+		written = end
+	}
+	return written, nil
+}
+
+// Close implements net.Conn. It closes the stream for both sides.
+func (m *muxStream) Close() error {
+	m.lock.Lock()
+	if m.localClosed {
+		m.lock.Unlock()
+		return nil
+	}
+	m.localClosed = true
+	remoteClosed := m.remoteClosed
+	m.lock.Unlock()
+
+	m.session.remove(m.id)
+	m.notify()
+	if remoteClosed {
+		return nil
+	}
+	return m.session.writeFrame(m.id, muxClose, nil, time.Now().Add(time.Second))
+}
+
+func (m *muxStream) LocalAddr() net.Addr {
+	return m.session.conn.LocalAddr()
+}
+
+func (m *muxStream) RemoteAddr() net.Addr {
+	return m.session.conn.RemoteAddr()
+}
+
+func (m *muxStream) SetDeadline(t time.Time) error {
+	m.lock.Lock()
+	m.readDeadline = t
+	m.writeDeadline = t
+	m.lock.Unlock()
+	m.notify()
+	return nil
+}
+
+func (m *muxStream) SetReadDeadline(t time.Time) error {
+	m.lock.Lock()
+	m.readDeadline = t
+	m.lock.Unlock()
+	m.notify()
+	return nil
+}
+
+func (m *muxStream) SetWriteDeadline(t time.Time) error {
+	m.lock.Lock()
+	m.writeDeadline = t
+	m.lock.Unlock()
+	return nil
+}
@@ -0,0 +1,183 @@
+package net
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+)
+
+func TestMuxStreams(t *testing.T) {
+	c1, c2 := net.Pipe()
+	client := newMuxSession(c1, c1, true)
+	server := newMuxSession(c2, c2, false)
+	defer client.Close()
+	defer server.Close()
+
+	//the server echoes every stream until it is closed
+	go func() {
+		for {
+			stream, err := server.accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(stream, stream)
+				stream.Close()
+			}()
+		}
+	}()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			stream, err := client.open()
+			if err != nil {
+				errs <- err
+				return
+			}
+			//larger than a frame
+			msg := bytes.Repeat([]byte(fmt.Sprintf("stream %d ", i)), 10000)
+			if _, err := stream.Write(msg); err != nil {
+				errs <- err
+				return
+			}
+			echo := make([]byte, len(msg))
+			stream.SetDeadline(time.Now().Add(5 * time.Second))
+			if _, err := io.ReadFull(stream, echo); err != nil {
+				errs <- err
+				return
+			}
+			if !bytes.Equal(msg, echo) {
+				errs <- fmt.Errorf("stream %d echoed other data", i)
+			}
+			stream.Close()
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestMuxStreamClose(t *testing.T) {
+	c1, c2 := net.Pipe()
+	client := newMuxSession(c1, c1, true)
+	server := newMuxSession(c2, c2, false)
+	defer client.Close()
+	defer server.Close()
+
+	stream, err := client.open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream.Write([]byte("bye"))
+	accepted, err := server.accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//a read times out at the deadline
+	accepted.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+	buf := make([]byte, 3)
+	io.ReadFull(accepted, buf)
+	if _, err := accepted.Read(buf); err == nil || !err.(net.Error).Timeout() {
+		t.Fatalf("Read should time out, not return %v", err)
+	}
+	accepted.SetReadDeadline(time.Time{})
+
+	//the other side reads EOF once the stream is closed
+	stream.Close()
+	if data, err := ioutil.ReadAll(accepted); err != nil || len(data) != 0 {
+		t.Fatalf("ReadAll should return no data and no error, not %q, %v", data, err)
+	}
+
+	//closing the session fails the streams
+	other, _ := client.open()
+	client.Close()
+	if _, err := other.Read(buf); err == nil {
+		t.Fatal("Read should fail once the session is closed")
+	}
+	if _, err := client.open(); err == nil {
+		t.Fatal("A closed session should not open streams")
+	}
+}
+
+func TestNetworkTransport_Multiplex(t *testing.T) {
+	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans1.Close()
+	rpcCh := trans1.Consumer()
+
+	go func() {
+		for {
+			select {
+			case rpc := <-rpcCh:
+				req := rpc.Command.(*SyncRequest)
+				rpc.Respond(&SyncResponse{From: req.From}, nil)
+			case <-time.After(time.Second):
+				return
+			}
+		}
+	}()
+
+	trans2, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans2.Close()
+	trans2.SetPeerMultiplex(trans1.LocalAddr(), true)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			from := fmt.Sprintf("node%d", i)
+			var out SyncResponse
+			if err := trans2.Sync(context.Background(), trans1.LocalAddr(), &SyncRequest{From: from}, &out); err != nil {
+				errs <- err
+			} else if out.From != from {
+				errs <- fmt.Errorf("Sync %d got the response of %s", i, out.From)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+
+	//all the requests went through one session, of which 2 streams are pooled
+	trans2.muxLock.Lock()
+	sessions := len(trans2.sessions)
+	trans2.muxLock.Unlock()
+	if sessions != 1 {
+		t.Fatalf("There should be 1 mux session, not %d", sessions)
+	}
+	trans2.connPoolLock.Lock()
+	pooled := trans2.connPool[trans1.LocalAddr()]
+	trans2.connPoolLock.Unlock()
+	if len(pooled) != 2 {
+		t.Fatalf("2 streams should be pooled, not %d", len(pooled))
+	}
+	for _, conn := range pooled {
+		if _, ok := conn.conn.(*muxStream); !ok {
+			t.Fatalf("Pooled connections should be mux streams, not %T", conn.conn)
+		}
+	}
+}
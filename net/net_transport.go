@@ -2,21 +2,29 @@ package net
 
 import (
 	"bufio"
-	"encoding/gob"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"os"
 	"sync"
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/babbleio/babble/codec"
 )
 
 const (
 	rpcSync uint8 = iota
 	rpcEagerSync
 	rpcFastForward
+	rpcJoin
+	rpcFetch
+
+	// rpcProtobuf is set on the type of every RPC sent on a connection that
+	// uses the Protobuf codec instead of the codec of the transport.
+	rpcProtobuf uint8 = 0x80
 
 	// DefaultTimeoutScale is the default TimeoutScale in a NetworkTransport.
 	DefaultTimeoutScale = 256 * 1024 // 256KB
@@ -40,10 +48,26 @@ be simple TCP, TLS, etc.
 
 This transport is very simple and lightweight. Each RPC request is
 framed by sending a byte that indicates the message type, followed
-by the gob encoded request.
+by the encoded request.
 
 The response is an error string followed by the response object,
-both are encoded using gob.
+both are encoded with the transport's codec (gob by default).
+
+Connections to the peers enabled with SetPeerProtobuf use the Protobuf codec
+instead. The type byte of their requests carries the rpcProtobuf flag, from
+which the receiver learns the codec of the connection, so nothing else needs
+to be negotiated. Peers that predate it would reject the flag, so it must only
+be enabled for peers that advertised CapProtobuf.
+
+The RPCs to the peers enabled with SetPeerMultiplex go through streams of a
+single mux session instead of separate connections, see mux.go. The streams are
+pooled like connections, but opening one does not cost a handshake, so the RPCs
+that do not find a pooled stream are cheap too. It must only be enabled for
+peers that advertised CapMultiplex.
+
+SetMaxPeers limits the peers with which connections are kept open, shedding the
+idle connections of the least recently used ones, see peer_limit.go.
+SetAdmissionPolicy lets embedders refuse inbound connections, see admission.go.
 */
 type NetworkTransport struct {
 	logger *logrus.Logger
@@ -60,7 +84,40 @@ type NetworkTransport struct {
 
 	stream StreamLayer
 
-	timeout time.Duration
+	codec         codec.Codec
+	protobufPeers map[string]bool
+	codecLock     sync.Mutex
+
+	muxPeers map[string]bool
+	sessions map[string]*muxSession
+	muxLock  sync.Mutex
+
+	timeout      time.Duration
+	rpcTimeouts  RPCTimeouts
+	timeoutsLock sync.Mutex
+
+	limiter     *rateLimiter
+	throttled   func(peer, rpc string)
+	limiterLock sync.Mutex
+
+	admission     AdmissionPolicy
+	refused       func(conn InboundConn, err error)
+	admissionLock sync.Mutex
+
+	maxPeers  int
+	peerUse   map[string]uint64 // [target] => value of useSeq at its last RPC
+	inbound   map[*inboundConn]bool
+	useSeq    uint64
+	peersLock sync.Mutex
+}
+
+// RPCTimeouts are the I/O deadlines of each type of RPC. FastForward responses
+// carry a whole Frame and usually need much longer than syncs. A zero value
+// means the timeout of the transport.
+type RPCTimeouts struct {
+	Sync        time.Duration
+	EagerSync   time.Duration
+	FastForward time.Duration
 }
 
 // StreamLayer is used with the NetworkTransport to provide
@@ -77,8 +134,10 @@ type netConn struct {
 	conn   net.Conn
 	r      *bufio.Reader
 	w      *bufio.Writer
-	dec    *gob.Decoder
-	enc    *gob.Encoder
+	dec    codec.Decoder
+	enc    codec.Encoder
+	proto  bool //uses the Protobuf codec
+	reused bool //taken from the pool
 }
 
 func (n *netConn) Release() error {
@@ -99,13 +158,19 @@ func NewNetworkTransport(
 		logger.Level = logrus.DebugLevel
 	}
 	trans := &NetworkTransport{
-		connPool:   make(map[string][]*netConn),
-		consumeCh:  make(chan RPC),
-		logger:     logger,
-		maxPool:    maxPool,
-		shutdownCh: make(chan struct{}),
-		stream:     stream,
-		timeout:    timeout,
+		connPool:      make(map[string][]*netConn),
+		consumeCh:     make(chan RPC),
+		logger:        logger,
+		maxPool:       maxPool,
+		shutdownCh:    make(chan struct{}),
+		stream:        stream,
+		codec:         codec.Gob,
+		protobufPeers: make(map[string]bool),
+		muxPeers:      make(map[string]bool),
+		sessions:      make(map[string]*muxSession),
+		timeout:       timeout,
+		peerUse:       make(map[string]uint64),
+		inbound:       make(map[*inboundConn]bool),
 	}
 	go trans.listen()
 	return trans
@@ -119,11 +184,166 @@ func (n *NetworkTransport) Close() error {
 	if !n.shutdown {
 		close(n.shutdownCh)
 		n.stream.Close()
+		n.closeSessions()
 		n.shutdown = true
 	}
 	return nil
 }
 
+// ListenerFile returns a duplicate of the listening socket if the stream layer
+// supports it. See TCPStreamLayer.File.
+func (n *NetworkTransport) ListenerFile() (*os.File, error) {
+	f, ok := n.stream.(interface {
+		File() (*os.File, error)
+	})
+	if !ok {
+		return nil, fmt.Errorf("Stream layer does not expose its socket")
+	}
+	return f.File()
+}
+
+// SetCodec changes the codec used to encode RPCs. All the nodes of a network
+// must use the same codec. It only applies to new connections so it should be
+// called before the transport is used.
+func (n *NetworkTransport) SetCodec(c codec.Codec) {
+	n.codecLock.Lock()
+	defer n.codecLock.Unlock()
+	n.codec = c
+}
+
+func (n *NetworkTransport) getCodec() codec.Codec {
+	n.codecLock.Lock()
+	defer n.codecLock.Unlock()
+	return n.codec
+}
+
+// SetPeerProtobuf makes the new connections to target use the Protobuf codec,
+// or the codec of the transport again. Pooled connections that use the other
+// codec are closed when they come out of the pool.
+func (n *NetworkTransport) SetPeerProtobuf(target string, enabled bool) {
+	n.codecLock.Lock()
+	defer n.codecLock.Unlock()
+	if enabled {
+		n.protobufPeers[target] = true
+	} else {
+		delete(n.protobufPeers, target)
+	}
+}
+
+func (n *NetworkTransport) usesProtobuf(target string) bool {
+	n.codecLock.Lock()
+	defer n.codecLock.Unlock()
+	return n.protobufPeers[target]
+}
+
+// SetPeerMultiplex makes the new connections to target streams of a mux
+// session, or separate connections again.
+func (n *NetworkTransport) SetPeerMultiplex(target string, enabled bool) {
+	n.muxLock.Lock()
+	defer n.muxLock.Unlock()
+	if enabled {
+		n.muxPeers[target] = true
+	} else {
+		delete(n.muxPeers, target)
+	}
+}
+
+func (n *NetworkTransport) usesMux(target string) bool {
+	n.muxLock.Lock()
+	defer n.muxLock.Unlock()
+	return n.muxPeers[target]
+}
+
+// openStream opens a stream to target, on the existing mux session if it is
+// still open or on a new one.
+func (n *NetworkTransport) openStream(target string, timeout time.Duration) (net.Conn, error) {
+	n.muxLock.Lock()
+	session := n.sessions[target]
+	n.muxLock.Unlock()
+
+	if session == nil || session.closed() {
+		conn, err := n.stream.Dial(target, timeout)
+		if err != nil {
+			return nil, err
+		}
+		conn.SetWriteDeadline(time.Now().Add(timeout))
+		if _, err := conn.Write([]byte{muxPreface}); err != nil {
+			conn.Close()
+			return nil, err
+		}
+		dialed := newMuxSession(conn, conn, true)
+
+		//keep the session of a concurrent call that was faster
+		n.muxLock.Lock()
+		session = n.sessions[target]
+		if session == nil || session.closed() {
+			session = dialed
+			n.sessions[target] = session
+		} else {
+			dialed.Close()
+		}
+		n.muxLock.Unlock()
+	}
+	return session.open()
+}
+
+func (n *NetworkTransport) closeSessions() {
+	n.muxLock.Lock()
+	defer n.muxLock.Unlock()
+	for target, session := range n.sessions {
+		session.Close()
+		delete(n.sessions, target)
+	}
+}
+
+// SetRPCTimeouts overrides the timeout of the transport for some types of RPC.
+func (n *NetworkTransport) SetRPCTimeouts(timeouts RPCTimeouts) {
+	n.timeoutsLock.Lock()
+	defer n.timeoutsLock.Unlock()
+	n.rpcTimeouts = timeouts
+}
+
+// SetRateLimits limits the inbound RPCs of each peer, see rate_limit.go.
+func (n *NetworkTransport) SetRateLimits(limits RPCRateLimits, throttled func(peer, rpc string)) {
+	n.limiterLock.Lock()
+	defer n.limiterLock.Unlock()
+	n.limiter = newRateLimiter(limits)
+	n.throttled = throttled
+}
+
+// allowRPC returns false if the peer exceeded the rate limit of the RPC type.
+func (n *NetworkTransport) allowRPC(peer string, rpcType uint8) bool {
+	n.limiterLock.Lock()
+	limiter, throttled := n.limiter, n.throttled
+	n.limiterLock.Unlock()
+	if limiter == nil || limiter.allow(peer, rpcType) {
+		return true
+	}
+	if throttled != nil {
+		throttled(peer, rpcNames[rpcType])
+	}
+	return false
+}
+
+// rpcTimeout returns the I/O deadline of an RPC type.
+func (n *NetworkTransport) rpcTimeout(rpcType uint8) time.Duration {
+	n.timeoutsLock.Lock()
+	defer n.timeoutsLock.Unlock()
+	var timeout time.Duration
+	switch rpcType {
+	case rpcSync:
+		timeout = n.rpcTimeouts.Sync
+	case rpcEagerSync:
+		timeout = n.rpcTimeouts.EagerSync
+	case rpcFastForward:
+		timeout = n.rpcTimeouts.FastForward
+	}
+	if timeout == 0 {
+		return n.timeout
+	}
+	return timeout
+}
+
 // Consumer implements the Transport interface.
 func (n *NetworkTransport) Consumer() <-chan RPC {
 	return n.consumeCh
@@ -144,32 +364,55 @@ func (n *NetworkTransport) IsShutdown() bool {
 	}
 }
 
-// getPooledConn is used to grab a pooled connection.
-func (n *NetworkTransport) getPooledConn(target string) *netConn {
+// getPooledConn is used to grab a pooled connection that uses the Protobuf
+// codec or not.
+func (n *NetworkTransport) getPooledConn(target string, proto bool) *netConn {
 	n.connPoolLock.Lock()
 	defer n.connPoolLock.Unlock()
 
-	conns, ok := n.connPool[target]
-	if !ok || len(conns) == 0 {
-		return nil
-	}
+	for {
+		conns, ok := n.connPool[target]
+		if !ok || len(conns) == 0 {
+			return nil
+		}
 
-	var conn *netConn
-	num := len(conns)
-	conn, conns[num-1] = conns[num-1], nil
-	n.connPool[target] = conns[:num-1]
-	return conn
+		var conn *netConn
+		num := len(conns)
+		conn, conns[num-1] = conns[num-1], nil
+		n.connPool[target] = conns[:num-1]
+		if stream, ok := conn.conn.(*muxStream); ok && stream.session.closed() {
+			continue
+		}
+		if stream, ok := conn.conn.(*muxStream); ok && stream.closedByPeer() {
+			conn.Release()
+			continue
+		}
+		if conn.proto == proto {
+			conn.reused = true
+			return conn
+		}
+		conn.Release()
+	}
 }
 
 // getConn is used to get a connection from the pool.
 func (n *NetworkTransport) getConn(target string, timeout time.Duration) (*netConn, error) {
+	n.usePeer(target)
+
 	// Check for a pooled conn
-	if conn := n.getPooledConn(target); conn != nil {
+	proto := n.usesProtobuf(target)
+	if conn := n.getPooledConn(target, proto); conn != nil {
 		return conn, nil
 	}
 
-	// Dial a new connection
-	conn, err := n.stream.Dial(target, timeout)
+	// Dial a new connection, or open a stream
+	var conn net.Conn
+	var err error
+	if n.usesMux(target) {
+		conn, err = n.openStream(target, timeout)
+	} else {
+		conn, err = n.stream.Dial(target, timeout)
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -180,10 +423,15 @@ func (n *NetworkTransport) getConn(target string, timeout time.Duration) (*netCo
 		conn:   conn,
 		r:      bufio.NewReader(conn),
 		w:      bufio.NewWriter(conn),
+		proto:  proto,
 	}
 	// Setup encoder/decoders
-	netConn.dec = gob.NewDecoder(netConn.r)
-	netConn.enc = gob.NewEncoder(netConn.w)
+	c := n.getCodec()
+	if proto {
+		c = codec.Protobuf
+	}
+	netConn.dec = c.NewDecoder(netConn.r)
+	netConn.enc = c.NewEncoder(netConn.w)
 
 	// Done
 	return netConn, nil
@@ -191,6 +439,12 @@ func (n *NetworkTransport) getConn(target string, timeout time.Duration) (*netCo
 
 // returnConn returns a connection back to the pool.
 func (n *NetworkTransport) returnConn(conn *netConn) {
+	// The connections to a released peer are closed once their RPC is done
+	if !n.peerInUse(conn.target) {
+		conn.Release()
+		return
+	}
+
 	n.connPoolLock.Lock()
 	defer n.connPoolLock.Unlock()
 
@@ -205,42 +459,95 @@ func (n *NetworkTransport) returnConn(conn *netConn) {
 }
 
 // Sync implements the Transport interface.
-func (n *NetworkTransport) Sync(target string, args *SyncRequest, resp *SyncResponse) error {
-	return n.genericRPC(target, rpcSync, args, resp)
+func (n *NetworkTransport) Sync(ctx context.Context, target string, args *SyncRequest, resp *SyncResponse) error {
+	return n.genericRPC(ctx, target, rpcSync, args, resp)
 }
 
 // EagerSync implements the Transport interface.
-func (n *NetworkTransport) EagerSync(target string, args *EagerSyncRequest, resp *EagerSyncResponse) error {
-	return n.genericRPC(target, rpcEagerSync, args, resp)
+func (n *NetworkTransport) EagerSync(ctx context.Context, target string, args *EagerSyncRequest, resp *EagerSyncResponse) error {
+	return n.genericRPC(ctx, target, rpcEagerSync, args, resp)
 }
 
 // FastForward implements the Transport interface.
-func (n *NetworkTransport) FastForward(target string, args *FastForwardRequest, resp *FastForwardResponse) error {
-	return n.genericRPC(target, rpcFastForward, args, resp)
+func (n *NetworkTransport) FastForward(ctx context.Context, target string, args *FastForwardRequest, resp *FastForwardResponse) error {
+	return n.genericRPC(ctx, target, rpcFastForward, args, resp)
+}
+
+// Fetch implements the Transport interface.
+func (n *NetworkTransport) Fetch(target string, args *FetchRequest, resp *FetchResponse) error {
+	return n.genericRPC(context.Background(), target, rpcFetch, args, resp)
+}
+
+// Join implements the Transport interface.
+func (n *NetworkTransport) Join(target string, args *JoinRequest, resp *JoinResponse) error {
+	return n.genericRPC(context.Background(), target, rpcJoin, args, resp)
 }
 
-// genericRPC handles a simple request/response RPC.
-func (n *NetworkTransport) genericRPC(target string, rpcType uint8, args interface{}, resp interface{}) error {
+// genericRPC handles a simple request/response RPC. It gives up when ctx is
+// done, or at its deadline if it is sooner than the timeout of the RPC.
+func (n *NetworkTransport) genericRPC(ctx context.Context, target string, rpcType uint8, args interface{}, resp interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	// Get a conn
-	conn, err := n.getConn(target, n.timeout)
+	dialTimeout := n.timeout
+	if d, ok := ctx.Deadline(); ok && time.Until(d) < dialTimeout {
+		dialTimeout = time.Until(d)
+	}
+	conn, err := n.getConn(target, dialTimeout)
 	if err != nil {
 		return err
 	}
 
 	// Set a deadline
-	if n.timeout > 0 {
-		conn.conn.SetDeadline(time.Now().Add(n.timeout))
+	var deadline time.Time
+	if timeout := n.rpcTimeout(rpcType); timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	if !deadline.IsZero() {
+		conn.conn.SetDeadline(deadline)
 	}
 
-	// Send the RPC
-	if err = sendRPC(conn, rpcType, args); err != nil {
-		return err
+	// Interrupt the RPC when ctx is done, by moving the deadline to the past
+	done := make(chan struct{})
+	interrupted := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.conn.SetDeadline(time.Unix(1, 0))
+			interrupted <- true
+		case <-done:
+			interrupted <- false
+		}
+	}()
+
+	// Send the RPC and decode the response
+	canReturn := false
+	if err = sendRPC(conn, rpcType, args); err == nil {
+		canReturn, err = decodeResponse(conn, resp)
 	}
+	close(done)
 
-	// Decode the response
-	canReturn, err := decodeResponse(conn, resp)
+	if <-interrupted {
+		// The connection can not be pooled with its deadline in the past
+		if canReturn {
+			conn.Release()
+			return err
+		}
+		return ctx.Err()
+	}
 	if canReturn {
 		n.returnConn(conn)
+		return err
+	}
+	// The peer may have shed a pooled connection while it was idle, in which
+	// case it did not read the request: it is sent again on a new connection
+	if conn.reused && connShed(err) {
+		return n.genericRPC(ctx, target, rpcType, args, resp)
 	}
 	return err
 }
@@ -248,6 +555,9 @@ func (n *NetworkTransport) genericRPC(target string, rpcType uint8, args interfa
 // sendRPC is used to encode and send the RPC.
 func sendRPC(conn *netConn, rpcType uint8, args interface{}) error {
 	// Write the request type
+	if conn.proto {
+		rpcType |= rpcProtobuf
+	}
 	if err := conn.w.WriteByte(rpcType); err != nil {
 		conn.Release()
 		return err
@@ -317,12 +627,41 @@ func (n *NetworkTransport) handleConn(conn net.Conn) {
 	defer conn.Close()
 	r := bufio.NewReader(conn)
 	w := bufio.NewWriter(conn)
-	dec := gob.NewDecoder(r)
-	enc := gob.NewEncoder(w)
+
+	// The first request tells which codec the connection uses, unless the
+	// connection carries a mux session
+	first, err := r.Peek(1)
+	if err != nil {
+		if err != io.EOF && !n.IsShutdown() {
+			n.logger.WithField("error", err).Error("Failed to decode incoming command")
+		}
+		return
+	}
+	if first[0] == muxPreface {
+		r.ReadByte()
+		n.serveMux(conn, r)
+		return
+	}
+	proto := first[0]&rpcProtobuf != 0
+	c := n.getCodec()
+	if proto {
+		c = codec.Protobuf
+	}
+	dec := c.NewDecoder(r)
+	enc := c.NewEncoder(w)
+	peer := peerHost(conn)
+	in := n.acceptInbound(conn, peer)
+	defer n.closeInbound(in)
 
 	for {
-		if err := n.handleCommand(r, dec, enc); err != nil {
-			if err != io.EOF {
+		err := n.nextRequest(r, in)
+		if err == nil {
+			err = n.handleCommand(r, dec, enc, proto, in)
+		}
+		if err != nil {
+			if err == errNotAdmitted {
+				w.Flush()
+			} else if err != io.EOF && err != errConnShed && !n.IsShutdown() {
 				n.logger.WithField("error", err).Error("Failed to decode incoming command")
 			}
 			return
@@ -334,13 +673,37 @@ func (n *NetworkTransport) handleConn(conn net.Conn) {
 	}
 }
 
-// handleCommand is used to decode and dispatch a single command.
-func (n *NetworkTransport) handleCommand(r *bufio.Reader, dec *gob.Decoder, enc *gob.Encoder) error {
+// serveMux handles the streams of an inbound mux session like connections.
+func (n *NetworkTransport) serveMux(conn net.Conn, r io.Reader) {
+	session := newMuxSession(conn, r, false)
+	go func() {
+		select {
+		case <-n.shutdownCh:
+			session.Close()
+		case <-session.closeCh:
+		}
+	}()
+	for {
+		stream, err := session.accept()
+		if err != nil {
+			return
+		}
+		go n.handleConn(stream)
+	}
+}
+
+// handleCommand is used to decode and dispatch a single command received on
+// the connection in. The rate limits apply to the host of the connection.
+func (n *NetworkTransport) handleCommand(r *bufio.Reader, dec codec.Decoder, enc codec.Encoder, proto bool, in *inboundConn) error {
 	// Get the rpc type
 	rpcType, err := r.ReadByte()
 	if err != nil {
 		return err
 	}
+	if (rpcType&rpcProtobuf != 0) != proto {
+		return fmt.Errorf("codec changed on the connection")
+	}
+	rpcType &^= rpcProtobuf
 
 	// Create the RPC object
 	respCh := make(chan RPCResponse, 1)
@@ -368,10 +731,44 @@ func (n *NetworkTransport) handleCommand(r *bufio.Reader, dec *gob.Decoder, enc
 			return err
 		}
 		rpc.Command = &req
+	case rpcJoin:
+		var req JoinRequest
+		if err := dec.Decode(&req); err != nil {
+			return err
+		}
+		rpc.Command = &req
+	case rpcFetch:
+		var req FetchRequest
+		if err := dec.Decode(&req); err != nil {
+			return err
+		}
+		rpc.Command = &req
 	default:
 		return fmt.Errorf("unknown rpc type %d", rpcType)
 	}
 
+	// Refuse the connections that the admission policy does not admit
+	if !in.admitted {
+		if err := n.admit(in, rpcType, rpc.Command); err != nil {
+			if err := enc.Encode(ErrNotAdmitted.Error()); err != nil {
+				return err
+			}
+			if err := enc.Encode(refusedResponse(rpcType)); err != nil {
+				return err
+			}
+			return errNotAdmitted
+		}
+		in.admitted = true
+	}
+
+	// Refuse the requests over the rate limit without bothering the node
+	if !n.allowRPC(in.peer, rpcType) {
+		if err := enc.Encode(ErrRateLimited.Error()); err != nil {
+			return err
+		}
+		return enc.Encode(throttledResponse(rpcType))
+	}
+
 	// Dispatch the RPC
 	select {
 	case n.consumeCh <- rpc:
@@ -1,17 +1,21 @@
 package net
 
 import (
+	"context"
+	"math/big"
 	"reflect"
 	"sync"
 	"testing"
 	"time"
 
+	"github.com/Sirupsen/logrus"
+	"github.com/babbleio/babble/codec"
 	"github.com/babbleio/babble/common"
 	"github.com/babbleio/babble/hashgraph"
 )
 
 func TestNetworkTransport_StartStop(t *testing.T) {
-	trans, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, common.NewTestLogger(t))
+	trans, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -20,7 +24,7 @@ func TestNetworkTransport_StartStop(t *testing.T) {
 
 func TestNetworkTransport_Sync(t *testing.T) {
 	// Transport 1 is consumer
-	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, common.NewTestLogger(t))
+	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -54,6 +58,7 @@ func TestNetworkTransport_Sync(t *testing.T) {
 			1: 5,
 			2: 6,
 		},
+		Truncated: true,
 	}
 
 	// Listen for a request
@@ -74,14 +79,14 @@ func TestNetworkTransport_Sync(t *testing.T) {
 	}()
 
 	// Transport 2 makes outbound request
-	trans2, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, common.NewTestLogger(t))
+	trans2, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	defer trans2.Close()
 
 	var out SyncResponse
-	if err := trans2.Sync(trans1.LocalAddr(), &args, &out); err != nil {
+	if err := trans2.Sync(context.Background(), trans1.LocalAddr(), &args, &out); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
@@ -93,7 +98,7 @@ func TestNetworkTransport_Sync(t *testing.T) {
 
 func TestNetworkTransport_EagerSync(t *testing.T) {
 	// Transport 1 is consumer
-	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, common.NewTestLogger(t))
+	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -137,14 +142,14 @@ func TestNetworkTransport_EagerSync(t *testing.T) {
 	}()
 
 	// Transport 2 makes outbound request
-	trans2, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, common.NewTestLogger(t))
+	trans2, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	defer trans2.Close()
 
 	var out EagerSyncResponse
-	if err := trans2.EagerSync(trans1.LocalAddr(), &args, &out); err != nil {
+	if err := trans2.EagerSync(context.Background(), trans1.LocalAddr(), &args, &out); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
@@ -156,7 +161,7 @@ func TestNetworkTransport_EagerSync(t *testing.T) {
 
 func TestNetworkTransport_FastForward(t *testing.T) {
 	// Transport 1 is consumer
-	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, common.NewTestLogger(t))
+	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -225,14 +230,14 @@ func TestNetworkTransport_FastForward(t *testing.T) {
 	}()
 
 	// Transport 2 makes outbound request
-	trans2, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, common.NewTestLogger(t))
+	trans2, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
 	defer trans2.Close()
 
 	var out FastForwardResponse
-	if err := trans2.FastForward(trans1.LocalAddr(), &args, &out); err != nil {
+	if err := trans2.FastForward(context.Background(), trans1.LocalAddr(), &args, &out); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
@@ -244,7 +249,7 @@ func TestNetworkTransport_FastForward(t *testing.T) {
 
 func TestNetworkTransport_PooledConn(t *testing.T) {
 	// Transport 1 is consumer
-	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, common.NewTestLogger(t))
+	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -294,7 +299,7 @@ func TestNetworkTransport_PooledConn(t *testing.T) {
 	}()
 
 	// Transport 2 makes outbound request, 3 conn pool
-	trans2, err := NewTCPTransport("127.0.0.1:0", nil, 3, time.Second, common.NewTestLogger(t))
+	trans2, err := NewTCPTransport("127.0.0.1:0", nil, 3, time.Second, nil, common.NewTestLogger(t))
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -307,7 +312,7 @@ func TestNetworkTransport_PooledConn(t *testing.T) {
 	appendFunc := func() {
 		defer wg.Done()
 		var out SyncResponse
-		if err := trans2.Sync(trans1.LocalAddr(), &args, &out); err != nil {
+		if err := trans2.Sync(context.Background(), trans1.LocalAddr(), &args, &out); err != nil {
 			t.Fatalf("err: %v", err)
 		}
 
@@ -331,3 +336,325 @@ func TestNetworkTransport_PooledConn(t *testing.T) {
 		t.Fatalf("Expected 2 pooled conns!")
 	}
 }
+
+func TestNetworkTransport_JSONCodec(t *testing.T) {
+	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans1.Close()
+	trans1.SetCodec(codec.JSON)
+	rpcCh := trans1.Consumer()
+
+	args := SyncRequest{
+		From:  "A",
+		Known: map[int]int{0: 1, 1: 2},
+	}
+	resp := SyncResponse{
+		From: "B",
+		Events: []hashgraph.WireEvent{
+			hashgraph.WireEvent{
+				Body: hashgraph.WireBody{
+					Transactions:    [][]byte{[]byte("tx\n1")},
+					SelfParentIndex: 1,
+					CreatorID:       9,
+				},
+				R: big.NewInt(12),
+				S: big.NewInt(34),
+			},
+		},
+		Known: map[int]int{0: 5, 1: 5},
+	}
+
+	go func() {
+		for i := 0; i < 2; i++ {
+			select {
+			case rpc := <-rpcCh:
+				req := rpc.Command.(*SyncRequest)
+				if !reflect.DeepEqual(req, &args) {
+					t.Fatalf("command mismatch: %#v %#v", *req, args)
+				}
+				rpc.Respond(&resp, nil)
+			case <-time.After(200 * time.Millisecond):
+				t.Fatalf("timeout")
+			}
+		}
+	}()
+
+	trans2, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans2.Close()
+	trans2.SetCodec(codec.JSON)
+
+	//the second RPC reuses the pooled connection
+	for i := 0; i < 2; i++ {
+		var out SyncResponse
+		if err := trans2.Sync(context.Background(), trans1.LocalAddr(), &args, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !reflect.DeepEqual(resp, out) {
+			t.Fatalf("response mismatch: %#v %#v", resp, out)
+		}
+	}
+}
+
+func TestNetworkTransport_RPCTimeouts(t *testing.T) {
+	//the consumer logs errors about timed out requests after the test returns
+	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, logrus.New())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans1.Close()
+	rpcCh := trans1.Consumer()
+
+	//every response is delayed
+	go func() {
+		for {
+			select {
+			case rpc := <-rpcCh:
+				time.Sleep(200 * time.Millisecond)
+				switch rpc.Command.(type) {
+				case *SyncRequest:
+					rpc.Respond(&SyncResponse{From: "B"}, nil)
+				case *FastForwardRequest:
+					rpc.Respond(&FastForwardResponse{From: "B"}, nil)
+				}
+			case <-trans1.shutdownCh:
+				return
+			}
+		}
+	}()
+
+	trans2, err := NewTCPTransport("127.0.0.1:0", nil, 2, 50*time.Millisecond, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans2.Close()
+
+	var ffResp FastForwardResponse
+	if err := trans2.FastForward(context.Background(), trans1.LocalAddr(), &FastForwardRequest{From: "A"}, &ffResp); err == nil {
+		t.Fatal("FastForward should time out with the transport timeout")
+	}
+
+	trans2.SetRPCTimeouts(RPCTimeouts{FastForward: time.Second})
+	if err := trans2.FastForward(context.Background(), trans1.LocalAddr(), &FastForwardRequest{From: "A"}, &ffResp); err != nil {
+		t.Fatalf("FastForward should succeed with its own timeout: %v", err)
+	}
+	if ffResp.From != "B" {
+		t.Fatalf("FastForwardResponse.From should be B, not %s", ffResp.From)
+	}
+
+	var syncResp SyncResponse
+	if err := trans2.Sync(context.Background(), trans1.LocalAddr(), &SyncRequest{From: "A"}, &syncResp); err == nil {
+		t.Fatal("Sync should still time out with the transport timeout")
+	}
+}
+
+//An RPC is abandoned as soon as its context is done, long before the timeout
+//of the transport, and the next RPC gets a fresh connection
+func TestNetworkTransport_Context(t *testing.T) {
+	//the consumer logs errors about abandoned requests after the test returns
+	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, logrus.New())
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans1.Close()
+	rpcCh := trans1.Consumer()
+
+	//the first response is delayed
+	go func() {
+		delay := 500 * time.Millisecond
+		for {
+			select {
+			case rpc := <-rpcCh:
+				time.Sleep(delay)
+				delay = 0
+				rpc.Respond(&SyncResponse{From: "B"}, nil)
+			case <-trans1.shutdownCh:
+				return
+			}
+		}
+	}()
+
+	trans2, err := NewTCPTransport("127.0.0.1:0", nil, 2, 10*time.Second, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans2.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	start := time.Now()
+	var resp SyncResponse
+	if err := trans2.Sync(ctx, trans1.LocalAddr(), &SyncRequest{From: "A"}, &resp); err != context.Canceled {
+		t.Fatalf("Sync should be cancelled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 400*time.Millisecond {
+		t.Fatalf("Sync should return when cancelled, took %s", elapsed)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := trans2.Sync(ctx, trans1.LocalAddr(), &SyncRequest{From: "A"}, &resp); err != nil {
+		t.Fatalf("Sync after a cancelled one should succeed: %v", err)
+	}
+	if resp.From != "B" {
+		t.Fatalf("SyncResponse.From should be B, not %s", resp.From)
+	}
+
+	if err := trans2.Sync(ctx, trans1.LocalAddr(), &SyncRequest{From: "A"}, &resp); err != nil {
+		t.Fatalf("Sync within the deadline of its context should succeed: %v", err)
+	}
+	cancel()
+	if err := trans2.Sync(ctx, trans1.LocalAddr(), &SyncRequest{From: "A"}, &resp); err != context.Canceled {
+		t.Fatalf("Sync with a done context should fail at once, got %v", err)
+	}
+}
+
+//A connection to a peer enabled with SetPeerProtobuf uses protobuf whatever the
+//codec of the receiver, and the others keep using the codec of the transport
+func TestNetworkTransport_Protobuf(t *testing.T) {
+	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans1.Close()
+	rpcCh := trans1.Consumer()
+
+	stamp := time.Date(2018, 3, 1, 12, 0, 0, 42, time.UTC)
+	args := SyncRequest{
+		From:   "A",
+		Known:  map[int]int{0: 1, 1: -1},
+		Limits: SyncLimits{Events: 100, Bytes: 1024},
+		Connectivity: ConnectivityRow{
+			"B": PeerLink{LastOutbound: stamp, RTT: 3 * time.Millisecond, Failures: 2},
+		},
+		Capabilities:    CapProtobuf | CapObserver,
+		Zone:            "eu-west",
+		BlockSignatures: []hashgraph.BlockSignature{{Validator: "0x04AB", Index: 3, Signature: "1|2"}},
+	}
+	resp := SyncResponse{
+		From:      "B",
+		SyncLimit: true,
+		Events: []hashgraph.WireEvent{
+			hashgraph.WireEvent{
+				Body: hashgraph.WireBody{
+					Transactions:         [][]byte{[]byte("tx\n1")},
+					SelfParentIndex:      -1,
+					OtherParentCreatorID: 10,
+					CreatorID:            9,
+					Timestamp:            stamp,
+				},
+				R: big.NewInt(12),
+				S: big.NewInt(34),
+			},
+		},
+		Known:        map[int]int{0: 5, 1: 5},
+		Ranges:       hashgraph.KnownRanges{0: {First: 0, Last: 5}, 1: {First: 2, Last: 5}},
+		Capabilities: CapProtobuf,
+	}
+
+	go func() {
+		for i := 0; i < 3; i++ {
+			select {
+			case rpc := <-rpcCh:
+				req := rpc.Command.(*SyncRequest)
+				if !reflect.DeepEqual(req, &args) {
+					t.Fatalf("command mismatch: %#v %#v", *req, args)
+				}
+				rpc.Respond(&resp, nil)
+			case <-time.After(200 * time.Millisecond):
+				t.Fatalf("timeout")
+			}
+		}
+	}()
+
+	trans2, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans2.Close()
+
+	for i, proto := range []bool{true, true, false} {
+		trans2.SetPeerProtobuf(trans1.LocalAddr(), proto)
+		var out SyncResponse
+		if err := trans2.Sync(context.Background(), trans1.LocalAddr(), &args, &out); err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		if !reflect.DeepEqual(resp, out) {
+			t.Fatalf("response mismatch: %#v %#v", resp, out)
+		}
+		//the second RPC reuses the pooled protobuf connection, the third one
+		//replaces it
+		trans2.connPoolLock.Lock()
+		conns := trans2.connPool[trans1.LocalAddr()]
+		trans2.connPoolLock.Unlock()
+		if len(conns) != 1 || conns[0].proto != proto {
+			t.Fatalf("RPC %d should leave one pooled connection with proto %v", i, proto)
+		}
+	}
+}
+
+func TestProtobufCommands(t *testing.T) {
+	ff := FastForwardResponse{
+		From: "B",
+		Head: "head",
+		Seq:  4,
+		Frame: hashgraph.Frame{
+			Roots: map[string]hashgraph.Root{
+				"0": hashgraph.Root{X: "x0", Y: "y0", Index: -1, Round: -1, Others: map[string]string{"o1": "oldEvent"}},
+			},
+			Events: []hashgraph.Event{
+				hashgraph.Event{
+					Body: hashgraph.EventBody{
+						Parents:   []string{"p1", "p2"},
+						Creator:   []byte("creator"),
+						Index:     19,
+						Timestamp: time.Date(2018, 3, 1, 12, 0, 0, 42, time.UTC),
+					},
+				},
+			},
+			Participants: []hashgraph.Participant{{PubKey: "0x04AB", ID: 1, Round: -1, Until: -1}},
+			Proposals:    []hashgraph.Proposal{{PubKey: "0x04AB", Proposers: []string{"0x04CD", "0x04EF"}}, {Join: true, PubKey: "0x04AC", Proposers: []string{"0x04CD"}}},
+		},
+		Block: hashgraph.Block{
+			Index:         2,
+			RoundReceived: 7,
+			Transactions:  [][]byte{[]byte("tx")},
+			StateHash:     []byte("hash"),
+			Signatures:    map[string]string{"0x04AB": "1|2"},
+		},
+		Snapshot: []byte("snapshot"),
+		Dedup:    []DedupEntry{{Round: 6, Hash: "0xAB"}, {Round: 7, Hash: "0xCD"}},
+	}
+	join := JoinResponse{
+		From:     "B",
+		Accepted: true,
+		Peers:    []Peer{{NetAddr: "127.0.0.1:1337", PubKeyHex: "0x04AB"}},
+	}
+
+	for _, c := range []struct {
+		in, out codec.ProtoMessage
+	}{
+		{&ff, &FastForwardResponse{}},
+		{&FastForwardResponse{From: "B", Transfer: "0xAB", Size: 10, Offset: 4, Chunk: []byte("chunk"), Checksum: 42}, &FastForwardResponse{}},
+		{&FastForwardRequest{From: "A", ChunkSize: 5, Transfer: "0xAB", Offset: 4}, &FastForwardRequest{}},
+		{&join, &JoinResponse{}},
+		{&JoinRequest{From: "A", Peer: join.Peers[0], Signature: "1A|2B"}, &JoinRequest{}},
+		{&EagerSyncResponse{From: "A", Success: true}, &EagerSyncResponse{}},
+		{&FetchRequest{From: "A", Hashes: []string{"0xAB"}, Parents: []hashgraph.WireRef{{CreatorID: 2, Index: 0}}, Known: map[int]int{0: -1}}, &FetchRequest{}},
+	} {
+		data, err := codec.Marshal(codec.Protobuf, c.in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := codec.Unmarshal(codec.Protobuf, data, c.out); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(c.in, c.out) {
+			t.Fatalf("%T should be %#v, not %#v", c.in, c.in, c.out)
+		}
+	}
+}
@@ -4,10 +4,14 @@ import (
 	"bytes"
 	"encoding/hex"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
+
+	"github.com/babbleio/babble/crypto"
 )
 
 const (
@@ -17,12 +21,50 @@ const (
 type Peer struct {
 	NetAddr   string
 	PubKeyHex string
+	// KeyAlgorithm is the algorithm of PubKeyHex, ecdsa-p256 or ed25519. It is
+	// optional, the key tells it, but if set it must match the key.
+	KeyAlgorithm string `json:",omitempty"`
+	// TLSPins are the hashes of the TLS certificates, or of their public keys,
+	// the peer may present, see tls_pins.go. Empty means no pinning.
+	TLSPins []string `json:",omitempty"`
 }
 
 func (p *Peer) PubKeyBytes() ([]byte, error) {
 	return hex.DecodeString(p.PubKeyHex[2:])
 }
 
+// Algorithm returns the algorithm of the key of the peer. It fails if the key
+// is invalid or does not match KeyAlgorithm.
+func (p *Peer) Algorithm() (string, error) {
+	pub, err := hex.DecodeString(strings.TrimPrefix(p.PubKeyHex, "0x"))
+	if err != nil {
+		return "", fmt.Errorf("Invalid public key %s", p.PubKeyHex)
+	}
+	algorithm, err := crypto.PubKeyAlgorithm(pub)
+	if err != nil {
+		return "", fmt.Errorf("Invalid public key %s: %s", p.PubKeyHex, err)
+	}
+	if p.KeyAlgorithm != "" && p.KeyAlgorithm != algorithm {
+		return "", fmt.Errorf("Peer %s declares a %s key but has a %s key", p.NetAddr, p.KeyAlgorithm, algorithm)
+	}
+	return algorithm, nil
+}
+
+// CheckKeyAlgorithms returns an error unless every peer has a valid key of the
+// algorithm. The nodes of a cluster must all use the same algorithm.
+func CheckKeyAlgorithms(algorithm string, peers []Peer) error {
+	for _, p := range peers {
+		a, err := p.Algorithm()
+		if err != nil {
+			return err
+		}
+		if a != algorithm {
+			return fmt.Errorf("Peer %s has a %s key but this node has a %s key", p.NetAddr, a, algorithm)
+		}
+	}
+	return nil
+}
+
 // PeerStore provides an interface for persistent storage and
 // retrieval of peers.
 type PeerStore interface {
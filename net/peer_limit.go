@@ -0,0 +1,223 @@
+package net
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+)
+
+/*
+In a large network, a node gossips with a few peers at a time rather than with
+all of them, and it does not need connections to the others. SetMaxPeers limits
+the peers with which a NetworkTransport keeps connections open, in each
+direction:
+
+ - outbound, the peers with pooled connections or a mux session. Before the
+   first connection to a new peer, the idle connections to the peer used the
+   longest ago are closed, and its mux session once its streams are done.
+ - inbound, the peers with accepted connections, told apart by IP address like
+   for rate limits. When a new peer connects, the idle connections of the peer
+   whose last request is the oldest are closed.
+
+Connections are only shed while they wait for a request, never in the middle of
+one. The requester finds out on its next RPC on a pooled connection, which it
+sends again on a new connection: shedding costs a reconnection, not a failed
+RPC. ReleasePeer closes the idle outbound connections to a peer the node stopped
+gossiping with, without waiting for the limit to be reached.
+*/
+
+// errConnShed is returned for a request on an inbound connection that was shed
+var errConnShed = errors.New("connection shed")
+
+// inboundConn is a connection accepted from a peer. It can be shed while it is
+// not busy, that is while it waits for the next request.
+type inboundConn struct {
+	peer string
+	conn net.Conn
+	busy bool
+	shed bool
+	last uint64 // value of useSeq at its last request
+
+	admitted bool // by the admission policy, see admission.go
+}
+
+// SetMaxPeers limits the peers with which the transport keeps connections open,
+// in each direction. 0 means no limit.
+func (n *NetworkTransport) SetMaxPeers(max int) {
+	n.peersLock.Lock()
+	defer n.peersLock.Unlock()
+	n.maxPeers = max
+}
+
+// ReleasePeer closes the idle connections to peer and those of the RPCs in
+// progress once they are done. A later RPC to peer connects again.
+func (n *NetworkTransport) ReleasePeer(peer string) {
+	n.peersLock.Lock()
+	defer n.peersLock.Unlock()
+	n.releasePeer(peer)
+}
+
+// releasePeer is ReleasePeer with peersLock held.
+func (n *NetworkTransport) releasePeer(peer string) {
+	delete(n.peerUse, peer)
+
+	n.connPoolLock.Lock()
+	conns := n.connPool[peer]
+	delete(n.connPool, peer)
+	n.connPoolLock.Unlock()
+	for _, conn := range conns {
+		conn.Release()
+	}
+
+	n.muxLock.Lock()
+	session := n.sessions[peer]
+	delete(n.sessions, peer)
+	n.muxLock.Unlock()
+	if session != nil {
+		session.drain()
+	}
+}
+
+// usePeer records an RPC to target. When target has no connection open yet and
+// the limit is reached, it releases the peers used the longest ago.
+func (n *NetworkTransport) usePeer(target string) {
+	n.peersLock.Lock()
+	defer n.peersLock.Unlock()
+
+	n.useSeq++
+	n.peerUse[target] = n.useSeq
+	if n.maxPeers <= 0 {
+		return
+	}
+	open := n.outboundPeers()
+	if open[target] {
+		return
+	}
+	for len(open) >= n.maxPeers {
+		oldest := ""
+		for peer := range open {
+			if oldest == "" || n.peerUse[peer] < n.peerUse[oldest] {
+				oldest = peer
+			}
+		}
+		n.releasePeer(oldest)
+		delete(open, oldest)
+	}
+}
+
+// peerInUse is false once peer was released, until the next RPC to it.
+func (n *NetworkTransport) peerInUse(peer string) bool {
+	n.peersLock.Lock()
+	defer n.peersLock.Unlock()
+	_, ok := n.peerUse[peer]
+	return ok
+}
+
+// outboundPeers returns the peers with pooled connections or an open mux
+// session.
+func (n *NetworkTransport) outboundPeers() map[string]bool {
+	open := make(map[string]bool)
+	n.connPoolLock.Lock()
+	for target, conns := range n.connPool {
+		if len(conns) > 0 {
+			open[target] = true
+		}
+	}
+	n.connPoolLock.Unlock()
+
+	n.muxLock.Lock()
+	for target, session := range n.sessions {
+		if !session.closed() {
+			open[target] = true
+		}
+	}
+	n.muxLock.Unlock()
+	return open
+}
+
+// acceptInbound registers a connection accepted from peer. When peer is a new
+// one and the limit is reached, it sheds the peers whose last request is the
+// oldest.
+func (n *NetworkTransport) acceptInbound(conn net.Conn, peer string) *inboundConn {
+	n.peersLock.Lock()
+	defer n.peersLock.Unlock()
+
+	n.useSeq++
+	in := &inboundConn{peer: peer, conn: conn, last: n.useSeq}
+	if n.maxPeers > 0 {
+		n.shedInbound(peer)
+	}
+	n.inbound[in] = true
+	return in
+}
+
+// shedInbound makes room for the connections of peer.
+func (n *NetworkTransport) shedInbound(peer string) {
+	last := make(map[string]uint64) // [peer] => its last request
+	for in := range n.inbound {
+		if in.peer == peer {
+			return
+		}
+		if in.last > last[in.peer] {
+			last[in.peer] = in.last
+		}
+	}
+	for len(last) >= n.maxPeers {
+		oldest := ""
+		for p := range last {
+			if oldest == "" || last[p] < last[oldest] {
+				oldest = p
+			}
+		}
+		for in := range n.inbound {
+			if in.peer == oldest && !in.busy {
+				in.shed = true
+				in.conn.Close()
+				delete(n.inbound, in)
+			}
+		}
+		delete(last, oldest)
+	}
+}
+
+// closeInbound unregisters a connection that is closed.
+func (n *NetworkTransport) closeInbound(in *inboundConn) {
+	n.peersLock.Lock()
+	defer n.peersLock.Unlock()
+	delete(n.inbound, in)
+}
+
+// nextRequest waits for the next request on in, which is then busy until the
+// next call. It fails with errConnShed if in was shed in the meantime.
+func (n *NetworkTransport) nextRequest(r *bufio.Reader, in *inboundConn) error {
+	n.peersLock.Lock()
+	in.busy = false
+	n.peersLock.Unlock()
+
+	_, err := r.Peek(1)
+
+	n.peersLock.Lock()
+	defer n.peersLock.Unlock()
+	if in.shed {
+		return errConnShed
+	}
+	if err != nil {
+		return err
+	}
+	n.useSeq++
+	in.busy = true
+	in.last = n.useSeq
+	return nil
+}
+
+// connShed is true for the errors of an RPC on a connection that the peer
+// closed before reading the request.
+func connShed(err error) bool {
+	return err == io.EOF ||
+		err == io.ErrUnexpectedEOF ||
+		err == errMuxClosed ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
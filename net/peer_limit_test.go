@@ -0,0 +1,119 @@
+package net
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+)
+
+// newSyncServer returns a transport that answers every Sync until the test ends
+func newSyncServer(t *testing.T) *NetworkTransport {
+	trans, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	go func() {
+		for rpc := range trans.Consumer() {
+			rpc.Respond(&SyncResponse{From: trans.LocalAddr()}, nil)
+		}
+	}()
+	return trans
+}
+
+func syncWith(t *testing.T, trans *NetworkTransport, target string) {
+	var resp SyncResponse
+	if err := trans.Sync(context.Background(), target, &SyncRequest{From: "A"}, &resp); err != nil {
+		t.Fatalf("Sync with %s: %v", target, err)
+	}
+	if resp.From != target {
+		t.Fatalf("Response should come from %s, got %s", target, resp.From)
+	}
+}
+
+func TestNetworkTransport_MaxPeers(t *testing.T) {
+	servers := []*NetworkTransport{}
+	for i := 0; i < 3; i++ {
+		s := newSyncServer(t)
+		defer s.Close()
+		servers = append(servers, s)
+	}
+
+	trans, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans.Close()
+	trans.SetMaxPeers(2)
+
+	for _, s := range servers {
+		syncWith(t, trans, s.LocalAddr())
+	}
+	open := trans.outboundPeers()
+	if len(open) != 2 || open[servers[0].LocalAddr()] {
+		t.Fatalf("The connections to the first peer should be shed, got %v", open)
+	}
+
+	//the released peer is connected to again, in place of the least recent one
+	syncWith(t, trans, servers[0].LocalAddr())
+	open = trans.outboundPeers()
+	if len(open) != 2 || open[servers[1].LocalAddr()] {
+		t.Fatalf("The connections to the second peer should be shed, got %v", open)
+	}
+
+	trans.ReleasePeer(servers[2].LocalAddr())
+	if open = trans.outboundPeers(); open[servers[2].LocalAddr()] {
+		t.Fatalf("The connections to a released peer should be closed, got %v", open)
+	}
+}
+
+func TestNetworkTransport_ShedInbound(t *testing.T) {
+	server := newSyncServer(t)
+	defer server.Close()
+	server.SetMaxPeers(1)
+
+	trans, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans.Close()
+
+	syncWith(t, trans, server.LocalAddr())
+	if len(trans.connPool[server.LocalAddr()]) != 1 {
+		t.Fatal("The connection should be pooled")
+	}
+
+	//the server marks the connection idle after sending the response
+	for deadline := time.Now().Add(time.Second); ; time.Sleep(10 * time.Millisecond) {
+		server.peersLock.Lock()
+		busy := false
+		for in := range server.inbound {
+			busy = busy || in.busy
+		}
+		server.peersLock.Unlock()
+		if !busy {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("The inbound connection should be idle")
+		}
+	}
+
+	//another peer connects, for which the idle connection of the first is shed
+	local, remote := net.Pipe()
+	defer local.Close()
+	defer remote.Close()
+	server.acceptInbound(local, "10.0.0.1")
+
+	server.peersLock.Lock()
+	inbound := len(server.inbound)
+	server.peersLock.Unlock()
+	if inbound != 1 {
+		t.Fatalf("Only the connection of the new peer should remain, got %d", inbound)
+	}
+
+	//the requester sends the RPC again on a new connection
+	syncWith(t, trans, server.LocalAddr())
+}
@@ -77,3 +77,29 @@ func TestJSONPeers(t *testing.T) {
 		}
 	}
 }
+
+func TestPeerAlgorithm(t *testing.T) {
+	ecdsaKey, _ := scrypto.GenerateKeyPair(scrypto.ECDSAP256)
+	edKey, _ := scrypto.GenerateKeyPair(scrypto.Ed25519)
+	ecdsaPeer := Peer{NetAddr: "addr0", PubKeyHex: scrypto.KeyPairPubKeyHex(ecdsaKey)}
+	edPeer := Peer{NetAddr: "addr1", PubKeyHex: scrypto.KeyPairPubKeyHex(edKey), KeyAlgorithm: scrypto.Ed25519}
+
+	if a, err := ecdsaPeer.Algorithm(); err != nil || a != scrypto.ECDSAP256 {
+		t.Fatalf("Peer without KeyAlgorithm should have the algorithm of its key, got %s, %v", a, err)
+	}
+	if a, err := edPeer.Algorithm(); err != nil || a != scrypto.Ed25519 {
+		t.Fatalf("Peer should have an ed25519 key, got %s, %v", a, err)
+	}
+	edPeer.KeyAlgorithm = scrypto.ECDSAP256
+	if _, err := edPeer.Algorithm(); err == nil {
+		t.Fatal("KeyAlgorithm that does not match the key should be refused")
+	}
+	edPeer.KeyAlgorithm = ""
+
+	if err := CheckKeyAlgorithms(scrypto.ECDSAP256, []Peer{ecdsaPeer}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CheckKeyAlgorithms(scrypto.ECDSAP256, []Peer{ecdsaPeer, edPeer}); err == nil {
+		t.Fatal("Mixed algorithms should be refused")
+	}
+}
@@ -0,0 +1,113 @@
+package net
+
+import (
+	gocrypto "crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/babbleio/babble/crypto"
+)
+
+/*
+PeerTLSConfig returns the configuration of mutually-authenticated TLS between
+participants, to pass to NewTCPTransport.
+
+Participants are identified by their public keys, not by host names signed by a
+certificate authority. Every node presents a self-signed certificate of its
+babble key, and the TLS handshake proves that it holds the private key. A
+connection is only accepted, on either side, if the key of the certificate is
+one for which trusted returns true, typically the keys of the peers file.
+
+The key is an ECDSA key on the P256 curve or an Ed25519 key, the private keys of
+the two algorithms of crypto.KeyPair, see crypto.PrivateKey.
+*/
+func PeerTLSConfig(key gocrypto.Signer, trusted func(pubKeyHex string) bool) (*tls.Config, error) {
+	cert, err := peerCertificate(key)
+	if err != nil {
+		return nil, err
+	}
+
+	verify := func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("No peer certificate")
+		}
+		pubKey, err := CertificatePubKey(rawCerts[0])
+		if err != nil {
+			return err
+		}
+		if !trusted(pubKey) {
+			return fmt.Errorf("Untrusted peer key %s", pubKey)
+		}
+		return nil
+	}
+
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+		ClientAuth:   tls.RequireAnyClientCert,
+		//the certificates are self-signed: the chain is not verified but the
+		//key is, by VerifyPeerCertificate
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: verify,
+	}, nil
+}
+
+//CertificatePubKey returns the babble public key, in hex, of a DER certificate
+func CertificatePubKey(der []byte) (string, error) {
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return "", err
+	}
+	return keyHex(cert.PublicKey)
+}
+
+//keyHex returns the babble public key, in hex, of a public key of the standard
+//library
+func keyHex(pub gocrypto.PublicKey) (string, error) {
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("0x%X", crypto.FromECDSAPub(pub)), nil
+	case ed25519.PublicKey:
+		return fmt.Sprintf("0x%X", []byte(pub)), nil
+	}
+	return "", fmt.Errorf("Peer certificate key is not ECDSA or Ed25519 but %T", pub)
+}
+
+//peerCertificate creates a self-signed certificate of key
+func peerCertificate(key gocrypto.Signer) (tls.Certificate, error) {
+	commonName, err := keyHex(key.Public())
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName: commonName,
+		},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.AddDate(10, 0, 0),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, key.Public(), key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+	}, nil
+}
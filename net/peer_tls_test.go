@@ -0,0 +1,137 @@
+package net
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/crypto"
+)
+
+func pubKeyHex(key *ecdsa.PrivateKey) string {
+	return fmt.Sprintf("0x%X", crypto.FromECDSAPub(&key.PublicKey))
+}
+
+func peerTLSTransport(t *testing.T, key *ecdsa.PrivateKey, trusted ...*ecdsa.PrivateKey) *NetworkTransport {
+	keys := make(map[string]bool)
+	for _, k := range trusted {
+		keys[pubKeyHex(k)] = true
+	}
+	conf, err := PeerTLSConfig(key, func(pubKey string) bool { return keys[pubKey] })
+	if err != nil {
+		t.Fatal(err)
+	}
+	trans, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, conf, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return trans
+}
+
+func TestPeerTLS(t *testing.T) {
+	keys := []*ecdsa.PrivateKey{}
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateECDSAKey()
+		keys = append(keys, key)
+	}
+
+	//0 and 1 are peers, 2 is a stranger that trusts 0
+	trans0 := peerTLSTransport(t, keys[0], keys[1])
+	defer trans0.Close()
+	trans1 := peerTLSTransport(t, keys[1], keys[0])
+	defer trans1.Close()
+	trans2 := peerTLSTransport(t, keys[2], keys[0])
+	defer trans2.Close()
+
+	go func() {
+		for rpc := range trans0.Consumer() {
+			rpc.Respond(&SyncResponse{From: "0"}, nil)
+		}
+	}()
+
+	var out SyncResponse
+	if err := trans1.Sync(context.Background(), trans0.LocalAddr(), &SyncRequest{From: "1"}, &out); err != nil {
+		t.Fatalf("A peer should be able to sync over TLS: %s", err)
+	}
+	if out.From != "0" {
+		t.Fatalf("Response should come from 0, not %s", out.From)
+	}
+
+	if err := trans2.Sync(context.Background(), trans0.LocalAddr(), &SyncRequest{From: "2"}, &out); err == nil {
+		t.Fatalf("A key that is not a peer should be refused")
+	}
+
+	//a plain TCP client can not talk to a TLS node either
+	plain, err := NewTCPTransport("127.0.0.1:0", nil, 2, 200*time.Millisecond, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer plain.Close()
+	if err := plain.Sync(context.Background(), trans0.LocalAddr(), &SyncRequest{From: "plain"}, &out); err == nil {
+		t.Fatalf("A plain TCP connection should be refused")
+	}
+}
+
+func TestCertificatePubKey(t *testing.T) {
+	key, _ := crypto.GenerateECDSAKey()
+	cert, err := peerCertificate(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubKey, err := CertificatePubKey(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pubKey != pubKeyHex(key) {
+		t.Fatalf("Certificate key should be %s, not %s", pubKeyHex(key), pubKey)
+	}
+}
+
+func TestPeerTLSEd25519(t *testing.T) {
+	keys := []crypto.KeyPair{}
+	for i := 0; i < 2; i++ {
+		key, err := crypto.GenerateKeyPair(crypto.Ed25519)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+
+	transports := []*NetworkTransport{}
+	for i, key := range keys {
+		signer, _ := crypto.PrivateKey(key)
+		cert, err := peerCertificate(signer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pubKey, err := CertificatePubKey(cert.Certificate[0]); err != nil || pubKey != crypto.KeyPairPubKeyHex(key) {
+			t.Fatalf("Certificate key should be %s, not %s (%v)", crypto.KeyPairPubKeyHex(key), pubKey, err)
+		}
+
+		other := crypto.KeyPairPubKeyHex(keys[1-i])
+		conf, err := PeerTLSConfig(signer, func(pubKey string) bool { return pubKey == other })
+		if err != nil {
+			t.Fatal(err)
+		}
+		trans, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, conf, common.NewTestLogger(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer trans.Close()
+		transports = append(transports, trans)
+	}
+
+	go func() {
+		for rpc := range transports[0].Consumer() {
+			rpc.Respond(&SyncResponse{From: "0"}, nil)
+		}
+	}()
+
+	var out SyncResponse
+	if err := transports[1].Sync(context.Background(), transports[0].LocalAddr(), &SyncRequest{From: "1"}, &out); err != nil {
+		t.Fatalf("Peers with Ed25519 keys should be able to sync over TLS: %s", err)
+	}
+}
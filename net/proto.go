@@ -0,0 +1,549 @@
+package net
+
+import (
+	"time"
+
+	"github.com/babbleio/babble/codec"
+	"github.com/babbleio/babble/hashgraph"
+)
+
+//Protobuf encoding of the RPCs, following wire.proto
+
+func appendKnown(b []byte, field int, known map[int]int) []byte {
+	for id, index := range known {
+		var entry []byte
+		entry = codec.AppendInt(entry, 1, id)
+		entry = codec.AppendInt(entry, 2, index)
+		b = codec.AppendBytes(b, field, entry)
+	}
+	return b
+}
+
+func readKnownEntry(known *map[int]int, data []byte) error {
+	var id, index int
+	err := codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			id = f.Int()
+		case 2:
+			index = f.Int()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if *known == nil {
+		*known = make(map[int]int)
+	}
+	(*known)[id] = index
+	return nil
+}
+
+func appendLimits(b []byte, field int, l SyncLimits) []byte {
+	if l == (SyncLimits{}) {
+		return b
+	}
+	var msg []byte
+	msg = codec.AppendInt(msg, 1, l.Events)
+	msg = codec.AppendInt(msg, 2, l.Bytes)
+	return codec.AppendBytes(b, field, msg)
+}
+
+func readLimits(data []byte) (SyncLimits, error) {
+	var l SyncLimits
+	err := codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			l.Events = f.Int()
+		case 2:
+			l.Bytes = f.Int()
+		}
+		return nil
+	})
+	return l, err
+}
+
+func appendConnectivity(b []byte, field int, row ConnectivityRow) []byte {
+	for addr, link := range row {
+		var msg []byte
+		msg = codec.AppendTime(msg, 1, link.LastOutbound)
+		msg = codec.AppendTime(msg, 2, link.LastInbound)
+		msg = codec.AppendInt(msg, 3, int(link.RTT))
+		msg = codec.AppendInt(msg, 4, link.Failures)
+
+		var entry []byte
+		entry = codec.AppendString(entry, 1, addr)
+		entry = codec.AppendBytes(entry, 2, msg)
+		b = codec.AppendBytes(b, field, entry)
+	}
+	return b
+}
+
+func readConnectivityEntry(row *ConnectivityRow, data []byte) error {
+	var addr string
+	var link PeerLink
+	err := codec.ReadFields(data, func(entry codec.ProtoField) error {
+		switch entry.Number {
+		case 1:
+			addr = entry.String()
+		case 2:
+			return codec.ReadFields(entry.Bytes, func(f codec.ProtoField) error {
+				var err error
+				switch f.Number {
+				case 1:
+					link.LastOutbound, err = f.Time()
+				case 2:
+					link.LastInbound, err = f.Time()
+				case 3:
+					link.RTT = time.Duration(f.Int())
+				case 4:
+					link.Failures = f.Int()
+				}
+				return err
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if *row == nil {
+		*row = make(ConnectivityRow)
+	}
+	(*row)[addr] = link
+	return nil
+}
+
+func appendSignatures(b []byte, field int, sigs []hashgraph.BlockSignature) []byte {
+	for _, sig := range sigs {
+		b = codec.AppendBytes(b, field, sig.MarshalProto())
+	}
+	return b
+}
+
+func readSignature(sigs *[]hashgraph.BlockSignature, data []byte) error {
+	var sig hashgraph.BlockSignature
+	if err := sig.UnmarshalProto(data); err != nil {
+		return err
+	}
+	*sigs = append(*sigs, sig)
+	return nil
+}
+
+func appendEvents(b []byte, field int, events []hashgraph.WireEvent) []byte {
+	for _, we := range events {
+		b = codec.AppendBytes(b, field, we.MarshalProto())
+	}
+	return b
+}
+
+func readEvent(events *[]hashgraph.WireEvent, data []byte) error {
+	var we hashgraph.WireEvent
+	if err := we.UnmarshalProto(data); err != nil {
+		return err
+	}
+	*events = append(*events, we)
+	return nil
+}
+
+func (p Peer) MarshalProto() []byte {
+	var b []byte
+	b = codec.AppendString(b, 1, p.NetAddr)
+	b = codec.AppendString(b, 2, p.PubKeyHex)
+	b = codec.AppendString(b, 3, p.KeyAlgorithm)
+	for _, pin := range p.TLSPins {
+		b = codec.AppendString(b, 4, pin)
+	}
+	return b
+}
+
+func (p *Peer) UnmarshalProto(data []byte) error {
+	*p = Peer{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			p.NetAddr = f.String()
+		case 2:
+			p.PubKeyHex = f.String()
+		case 3:
+			p.KeyAlgorithm = f.String()
+		case 4:
+			p.TLSPins = append(p.TLSPins, f.String())
+		}
+		return nil
+	})
+}
+
+//++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+func (r SyncRequest) MarshalProto() []byte {
+	var b []byte
+	b = codec.AppendString(b, 1, r.From)
+	b = appendKnown(b, 2, r.Known)
+	b = appendLimits(b, 3, r.Limits)
+	b = appendConnectivity(b, 4, r.Connectivity)
+	b = codec.AppendVarint(b, 5, uint64(r.Capabilities))
+	b = codec.AppendString(b, 6, r.Zone)
+	b = appendSignatures(b, 7, r.BlockSignatures)
+	return codec.AppendBool(b, 8, r.Compress)
+}
+
+func (r *SyncRequest) UnmarshalProto(data []byte) error {
+	*r = SyncRequest{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		var err error
+		switch f.Number {
+		case 1:
+			r.From = f.String()
+		case 2:
+			err = readKnownEntry(&r.Known, f.Bytes)
+		case 3:
+			r.Limits, err = readLimits(f.Bytes)
+		case 4:
+			err = readConnectivityEntry(&r.Connectivity, f.Bytes)
+		case 5:
+			r.Capabilities = Capabilities(f.Varint)
+		case 6:
+			r.Zone = f.String()
+		case 7:
+			err = readSignature(&r.BlockSignatures, f.Bytes)
+		case 8:
+			r.Compress = f.Bool()
+		}
+		return err
+	})
+}
+
+func (r SyncResponse) MarshalProto() []byte {
+	var b []byte
+	b = codec.AppendString(b, 1, r.From)
+	b = codec.AppendBool(b, 2, r.SyncLimit)
+	b = appendEvents(b, 3, r.Events)
+	b = appendKnown(b, 4, r.Known)
+	if len(r.Ranges) > 0 {
+		b = codec.AppendBytes(b, 5, r.Ranges.MarshalProto())
+	}
+	b = appendLimits(b, 6, r.Limits)
+	b = appendConnectivity(b, 7, r.Connectivity)
+	b = codec.AppendVarint(b, 8, uint64(r.Capabilities))
+	b = codec.AppendString(b, 9, r.Zone)
+	b = appendSignatures(b, 10, r.BlockSignatures)
+	if len(r.CompressedEvents) > 0 {
+		b = codec.AppendBytes(b, 11, r.CompressedEvents)
+	}
+	b = codec.AppendVarint(b, 12, uint64(r.ErrorCode))
+	b = codec.AppendVarint(b, 13, uint64(r.Dictionary))
+	return codec.AppendBool(b, 14, r.Truncated)
+}
+
+func (r *SyncResponse) UnmarshalProto(data []byte) error {
+	*r = SyncResponse{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		var err error
+		switch f.Number {
+		case 1:
+			r.From = f.String()
+		case 2:
+			r.SyncLimit = f.Bool()
+		case 3:
+			err = readEvent(&r.Events, f.Bytes)
+		case 4:
+			err = readKnownEntry(&r.Known, f.Bytes)
+		case 5:
+			if r.Ranges == nil {
+				r.Ranges = make(hashgraph.KnownRanges)
+			}
+			err = r.Ranges.UnmarshalProto(f.Bytes)
+		case 6:
+			r.Limits, err = readLimits(f.Bytes)
+		case 7:
+			err = readConnectivityEntry(&r.Connectivity, f.Bytes)
+		case 8:
+			r.Capabilities = Capabilities(f.Varint)
+		case 9:
+			r.Zone = f.String()
+		case 10:
+			err = readSignature(&r.BlockSignatures, f.Bytes)
+		case 11:
+			r.CompressedEvents = f.Copy()
+		case 12:
+			r.ErrorCode = ErrorCode(f.Varint)
+		case 13:
+			r.Dictionary = uint32(f.Varint)
+		case 14:
+			r.Truncated = f.Bool()
+		}
+		return err
+	})
+}
+
+func (r EagerSyncRequest) MarshalProto() []byte {
+	b := codec.AppendString(nil, 1, r.From)
+	b = appendEvents(b, 2, r.Events)
+	if len(r.CompressedEvents) > 0 {
+		b = codec.AppendBytes(b, 3, r.CompressedEvents)
+	}
+	b = codec.AppendVarint(b, 4, uint64(r.Dictionary))
+	return codec.AppendBool(b, 5, r.More)
+}
+
+func (r *EagerSyncRequest) UnmarshalProto(data []byte) error {
+	*r = EagerSyncRequest{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			r.From = f.String()
+		case 2:
+			return readEvent(&r.Events, f.Bytes)
+		case 3:
+			r.CompressedEvents = f.Copy()
+		case 4:
+			r.Dictionary = uint32(f.Varint)
+		case 5:
+			r.More = f.Bool()
+		}
+		return nil
+	})
+}
+
+func (r EagerSyncResponse) MarshalProto() []byte {
+	b := codec.AppendString(nil, 1, r.From)
+	b = codec.AppendBool(b, 2, r.Success)
+	return codec.AppendVarint(b, 3, uint64(r.ErrorCode))
+}
+
+func (r *EagerSyncResponse) UnmarshalProto(data []byte) error {
+	*r = EagerSyncResponse{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			r.From = f.String()
+		case 2:
+			r.Success = f.Bool()
+		case 3:
+			r.ErrorCode = ErrorCode(f.Varint)
+		}
+		return nil
+	})
+}
+
+func (r FetchRequest) MarshalProto() []byte {
+	b := codec.AppendString(nil, 1, r.From)
+	for _, h := range r.Hashes {
+		b = codec.AppendBytes(b, 2, []byte(h))
+	}
+	for _, ref := range r.Parents {
+		var msg []byte
+		msg = codec.AppendInt(msg, 1, ref.CreatorID)
+		msg = codec.AppendInt(msg, 2, ref.Index)
+		b = codec.AppendBytes(b, 3, msg)
+	}
+	return appendKnown(b, 4, r.Known)
+}
+
+func (r *FetchRequest) UnmarshalProto(data []byte) error {
+	*r = FetchRequest{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			r.From = f.String()
+		case 2:
+			r.Hashes = append(r.Hashes, f.String())
+		case 3:
+			var ref hashgraph.WireRef
+			err := codec.ReadFields(f.Bytes, func(rf codec.ProtoField) error {
+				switch rf.Number {
+				case 1:
+					ref.CreatorID = rf.Int()
+				case 2:
+					ref.Index = rf.Int()
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			r.Parents = append(r.Parents, ref)
+		case 4:
+			return readKnownEntry(&r.Known, f.Bytes)
+		}
+		return nil
+	})
+}
+
+func (r FetchResponse) MarshalProto() []byte {
+	b := codec.AppendString(nil, 1, r.From)
+	return appendEvents(b, 2, r.Events)
+}
+
+func (r *FetchResponse) UnmarshalProto(data []byte) error {
+	*r = FetchResponse{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			r.From = f.String()
+		case 2:
+			return readEvent(&r.Events, f.Bytes)
+		}
+		return nil
+	})
+}
+
+func (r FastForwardRequest) MarshalProto() []byte {
+	b := codec.AppendString(nil, 1, r.From)
+	b = codec.AppendBool(b, 2, r.Probe)
+	b = codec.AppendInt(b, 3, r.ChunkSize)
+	b = codec.AppendString(b, 4, r.Transfer)
+	return codec.AppendInt(b, 5, r.Offset)
+}
+
+func (r *FastForwardRequest) UnmarshalProto(data []byte) error {
+	*r = FastForwardRequest{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			r.From = f.String()
+		case 2:
+			r.Probe = f.Bool()
+		case 3:
+			r.ChunkSize = f.Int()
+		case 4:
+			r.Transfer = f.String()
+		case 5:
+			r.Offset = f.Int()
+		}
+		return nil
+	})
+}
+
+func (r FastForwardResponse) MarshalProto() []byte {
+	var b []byte
+	b = codec.AppendString(b, 1, r.From)
+	b = codec.AppendString(b, 2, r.Head)
+	b = codec.AppendInt(b, 3, r.Seq)
+	b = codec.AppendBytes(b, 4, r.Frame.MarshalProto())
+	b = codec.AppendBytes(b, 5, r.Block.MarshalProto())
+	if r.Snapshot != nil {
+		b = codec.AppendBytes(b, 6, r.Snapshot)
+	}
+	b = codec.AppendInt(b, 7, r.Round)
+	b = codec.AppendString(b, 8, r.Transfer)
+	b = codec.AppendInt(b, 9, r.Size)
+	b = codec.AppendInt(b, 10, r.Offset)
+	if r.Chunk != nil {
+		b = codec.AppendBytes(b, 11, r.Chunk)
+	}
+	b = codec.AppendVarint(b, 12, uint64(r.Checksum))
+	for _, e := range r.Dedup {
+		b = codec.AppendBytes(b, 13, e.MarshalProto())
+	}
+	return b
+}
+
+func (r *FastForwardResponse) UnmarshalProto(data []byte) error {
+	*r = FastForwardResponse{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			r.From = f.String()
+		case 2:
+			r.Head = f.String()
+		case 3:
+			r.Seq = f.Int()
+		case 4:
+			return r.Frame.UnmarshalProto(f.Bytes)
+		case 5:
+			return r.Block.UnmarshalProto(f.Bytes)
+		case 6:
+			r.Snapshot = f.Copy()
+		case 7:
+			r.Round = f.Int()
+		case 8:
+			r.Transfer = f.String()
+		case 9:
+			r.Size = f.Int()
+		case 10:
+			r.Offset = f.Int()
+		case 11:
+			r.Chunk = f.Copy()
+		case 12:
+			r.Checksum = uint32(f.Varint)
+		case 13:
+			var e DedupEntry
+			if err := e.UnmarshalProto(f.Bytes); err != nil {
+				return err
+			}
+			r.Dedup = append(r.Dedup, e)
+		}
+		return nil
+	})
+}
+
+func (e DedupEntry) MarshalProto() []byte {
+	b := codec.AppendInt(nil, 1, e.Round)
+	return codec.AppendString(b, 2, e.Hash)
+}
+
+func (e *DedupEntry) UnmarshalProto(data []byte) error {
+	*e = DedupEntry{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			e.Round = f.Int()
+		case 2:
+			e.Hash = f.String()
+		}
+		return nil
+	})
+}
+
+func (r JoinRequest) MarshalProto() []byte {
+	b := codec.AppendString(nil, 1, r.From)
+	b = codec.AppendBytes(b, 2, r.Peer.MarshalProto())
+	return codec.AppendString(b, 3, r.Signature)
+}
+
+func (r *JoinRequest) UnmarshalProto(data []byte) error {
+	*r = JoinRequest{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			r.From = f.String()
+		case 2:
+			return r.Peer.UnmarshalProto(f.Bytes)
+		case 3:
+			r.Signature = f.String()
+		}
+		return nil
+	})
+}
+
+func (r JoinResponse) MarshalProto() []byte {
+	var b []byte
+	b = codec.AppendString(b, 1, r.From)
+	b = codec.AppendBool(b, 2, r.Accepted)
+	for _, p := range r.Peers {
+		b = codec.AppendBytes(b, 3, p.MarshalProto())
+	}
+	return b
+}
+
+func (r *JoinResponse) UnmarshalProto(data []byte) error {
+	*r = JoinResponse{}
+	return codec.ReadFields(data, func(f codec.ProtoField) error {
+		switch f.Number {
+		case 1:
+			r.From = f.String()
+		case 2:
+			r.Accepted = f.Bool()
+		case 3:
+			var p Peer
+			if err := p.UnmarshalProto(f.Bytes); err != nil {
+				return err
+			}
+			r.Peers = append(r.Peers, p)
+		}
+		return nil
+	})
+}
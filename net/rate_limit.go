@@ -0,0 +1,217 @@
+package net
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+A NetworkTransport with rate limits refuses the inbound RPCs of a peer beyond a
+number of requests per second, for each type of RPC, so that a misbehaving or
+buggy peer cannot flood the node. Peers are told apart by the IP address of
+their connection rather than by the From field of their requests, which they
+choose. Every peer has a token bucket per type of RPC, which holds up to Burst
+requests and refills at PerSecond.
+
+A refused request is answered straight from the listener, without reaching the
+node, with an error and, for Syncs and EagerSyncs, ErrorRateLimited. The
+requester treats that code like ErrorBusy and backs off.
+*/
+
+// RateLimit is the number of requests per second accepted from each peer, with
+// bursts of up to Burst requests. A zero PerSecond means no limit and a zero
+// Burst means PerSecond rounded up.
+type RateLimit struct {
+	PerSecond float64
+	Burst     int
+}
+
+// RPCRateLimits are the rate limits of each type of inbound RPC.
+type RPCRateLimits struct {
+	Sync        RateLimit
+	EagerSync   RateLimit
+	FastForward RateLimit
+	Fetch       RateLimit
+	Join        RateLimit
+}
+
+var rpcNames = map[uint8]string{
+	rpcSync:        "sync",
+	rpcEagerSync:   "eager_sync",
+	rpcFastForward: "fast_forward",
+	rpcJoin:        "join",
+	rpcFetch:       "fetch",
+}
+
+func (l *RPCRateLimits) limit(rpcType uint8) *RateLimit {
+	switch rpcType {
+	case rpcSync:
+		return &l.Sync
+	case rpcEagerSync:
+		return &l.EagerSync
+	case rpcFastForward:
+		return &l.FastForward
+	case rpcFetch:
+		return &l.Fetch
+	case rpcJoin:
+		return &l.Join
+	}
+	return nil
+}
+
+// Validate returns an error if a rate or a burst is negative.
+func (l RPCRateLimits) Validate() error {
+	for t := rpcSync; t <= rpcFetch; t++ {
+		if r := l.limit(t); r.PerSecond < 0 || r.Burst < 0 {
+			return fmt.Errorf("Invalid %s rate limit %g/s, burst %d", rpcNames[t], r.PerSecond, r.Burst)
+		}
+	}
+	return nil
+}
+
+// ParseRPCRateLimits reads rate limits in the form "sync=20,eager_sync=50",
+// with the requests per second of each type of RPC.
+func ParseRPCRateLimits(s string) (RPCRateLimits, error) {
+	var limits RPCRateLimits
+	if strings.TrimSpace(s) == "" {
+		return limits, nil
+	}
+	for _, item := range strings.Split(s, ",") {
+		parts := strings.SplitN(strings.TrimSpace(item), "=", 2)
+		if len(parts) != 2 {
+			return RPCRateLimits{}, fmt.Errorf("Invalid rate limit %q, expected <rpc>=<requests per second>", item)
+		}
+		var limit *RateLimit
+		for t, name := range rpcNames {
+			if name == parts[0] {
+				limit = limits.limit(t)
+			}
+		}
+		if limit == nil {
+			return RPCRateLimits{}, fmt.Errorf("Unknown RPC %q in rate limits", parts[0])
+		}
+		rate, err := strconv.ParseFloat(parts[1], 64)
+		if err != nil {
+			return RPCRateLimits{}, fmt.Errorf("Invalid rate limit %q: %s", item, err)
+		}
+		limit.PerSecond = rate
+	}
+	return limits, limits.Validate()
+}
+
+// ErrRateLimited is the error of the requests refused for exceeding the rate
+// limits of the responder.
+var ErrRateLimited = errors.New("Rate limit exceeded")
+
+// throttledResponse returns the response to a refused request of a type.
+func throttledResponse(rpcType uint8) interface{} {
+	switch rpcType {
+	case rpcSync:
+		return &SyncResponse{ErrorCode: ErrorRateLimited}
+	case rpcEagerSync:
+		return &EagerSyncResponse{ErrorCode: ErrorRateLimited}
+	case rpcFastForward:
+		return &FastForwardResponse{}
+	case rpcJoin:
+		return &JoinResponse{}
+	default:
+		return &FetchResponse{}
+	}
+}
+
+//------------------------------------------------------------------------------
+
+// maxIdleBuckets is the number of buckets above which the full ones are dropped.
+const maxIdleBuckets = 1024
+
+type bucketKey struct {
+	peer    string
+	rpcType uint8
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type rateLimiter struct {
+	lock    sync.Mutex
+	limits  RPCRateLimits
+	buckets map[bucketKey]*tokenBucket
+	now     func() time.Time
+}
+
+func newRateLimiter(limits RPCRateLimits) *rateLimiter {
+	return &rateLimiter{
+		limits:  limits,
+		buckets: make(map[bucketKey]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+func burst(l RateLimit) float64 {
+	if l.Burst > 0 {
+		return float64(l.Burst)
+	}
+	return math.Max(1, math.Ceil(l.PerSecond))
+}
+
+// allow takes a token from the bucket of the peer for the type of RPC. It
+// returns false if the bucket is empty.
+func (r *rateLimiter) allow(peer string, rpcType uint8) bool {
+	limit := r.limits.limit(rpcType)
+	if limit == nil || limit.PerSecond <= 0 {
+		return true
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	now := r.now()
+	if len(r.buckets) > maxIdleBuckets {
+		r.dropFull(now)
+	}
+	key := bucketKey{peer, rpcType}
+	b, ok := r.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: burst(*limit), last: now}
+		r.buckets[key] = b
+	}
+	b.tokens = math.Min(burst(*limit), b.tokens+now.Sub(b.last).Seconds()*limit.PerSecond)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// dropFull forgets the buckets that refilled, which start full again anyway.
+// Must be called with the lock held.
+func (r *rateLimiter) dropFull(now time.Time) {
+	for key, b := range r.buckets {
+		limit := r.limits.limit(key.rpcType)
+		if b.tokens+now.Sub(b.last).Seconds()*limit.PerSecond >= burst(*limit) {
+			delete(r.buckets, key)
+		}
+	}
+}
+
+// peerHost returns the IP address of the remote end of a connection, which
+// identifies the peer for rate limiting.
+func peerHost(conn net.Conn) string {
+	addr := conn.RemoteAddr()
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
@@ -0,0 +1,121 @@
+package net
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+)
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Now()
+	r := newRateLimiter(RPCRateLimits{
+		Sync:      RateLimit{PerSecond: 2},
+		EagerSync: RateLimit{PerSecond: 10, Burst: 1},
+	})
+	r.now = func() time.Time { return now }
+
+	//a burst of one second of requests is accepted
+	for i := 0; i < 2; i++ {
+		if !r.allow("A", rpcSync) {
+			t.Fatalf("Sync %d should be allowed", i)
+		}
+	}
+	if r.allow("A", rpcSync) {
+		t.Fatal("Third Sync should be refused")
+	}
+
+	//each peer and type of RPC has its own bucket, and unlimited types pass
+	if !r.allow("B", rpcSync) {
+		t.Fatal("Sync from another peer should be allowed")
+	}
+	if !r.allow("A", rpcEagerSync) || r.allow("A", rpcEagerSync) {
+		t.Fatal("Only one EagerSync should be allowed with a burst of 1")
+	}
+	for i := 0; i < 10; i++ {
+		if !r.allow("A", rpcFetch) {
+			t.Fatal("Fetch should not be limited")
+		}
+	}
+
+	//the bucket refills at the rate of the limit
+	now = now.Add(500 * time.Millisecond)
+	if !r.allow("A", rpcSync) {
+		t.Fatal("Sync should be allowed after half a second")
+	}
+	if r.allow("A", rpcSync) {
+		t.Fatal("Only one Sync should be allowed after half a second")
+	}
+}
+
+func TestParseRPCRateLimits(t *testing.T) {
+	limits, err := ParseRPCRateLimits("sync=20, eager_sync=0.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := RPCRateLimits{
+		Sync:      RateLimit{PerSecond: 20},
+		EagerSync: RateLimit{PerSecond: 0.5},
+	}
+	if limits != expected {
+		t.Fatalf("Limits should be %+v, not %+v", expected, limits)
+	}
+
+	for _, s := range []string{"sync", "gossip=1", "sync=x", "sync=-1"} {
+		if _, err := ParseRPCRateLimits(s); err == nil {
+			t.Fatalf("Parsing %q should fail", s)
+		}
+	}
+}
+
+func TestNetworkTransport_RateLimits(t *testing.T) {
+	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans1.Close()
+	throttled := make(chan string, 1)
+	trans1.SetRateLimits(RPCRateLimits{Sync: RateLimit{PerSecond: 0.1, Burst: 1}},
+		func(peer, rpc string) { throttled <- rpc })
+
+	rpcCh := trans1.Consumer()
+	go func() {
+		for {
+			select {
+			case rpc := <-rpcCh:
+				rpc.Respond(&SyncResponse{From: "B"}, nil)
+			case <-trans1.shutdownCh:
+				return
+			}
+		}
+	}()
+
+	trans2, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans2.Close()
+
+	var resp SyncResponse
+	if err := trans2.Sync(context.Background(), trans1.LocalAddr(), &SyncRequest{From: "A"}, &resp); err != nil {
+		t.Fatalf("First Sync should succeed: %v", err)
+	}
+
+	resp = SyncResponse{}
+	err = trans2.Sync(context.Background(), trans1.LocalAddr(), &SyncRequest{From: "A"}, &resp)
+	if err == nil || err.Error() != ErrRateLimited.Error() {
+		t.Fatalf("Second Sync should be rate limited, got %v", err)
+	}
+	if resp.ErrorCode != ErrorRateLimited {
+		t.Fatalf("Second Sync should have ErrorRateLimited, not %v", resp.ErrorCode)
+	}
+	select {
+	case rpc := <-throttled:
+		if rpc != "sync" {
+			t.Fatalf("Throttled RPC should be sync, not %s", rpc)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Throttled Sync should be reported")
+	}
+}
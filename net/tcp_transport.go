@@ -1,8 +1,10 @@
 package net
 
 import (
+	"crypto/tls"
 	"errors"
 	"net"
+	"os"
 	"time"
 
 	"github.com/Sirupsen/logrus"
@@ -13,20 +15,31 @@ var (
 	errNotTCP          = errors.New("local address is not a TCP address")
 )
 
-// TCPStreamLayer implements StreamLayer interface for plain TCP.
+// TCPStreamLayer implements StreamLayer interface for TCP, optionally
+// wrapped in TLS.
 type TCPStreamLayer struct {
 	advertise net.Addr
 	listener  *net.TCPListener
+	tls       *tls.Config //nil for plain TCP
 }
 
 // Dial implements the StreamLayer interface.
 func (t *TCPStreamLayer) Dial(address string, timeout time.Duration) (net.Conn, error) {
+	if t.tls != nil {
+		dialer := net.Dialer{Timeout: timeout}
+		return tls.DialWithDialer(&dialer, "tcp", address, t.tls)
+	}
 	return net.DialTimeout("tcp", address, timeout)
 }
 
-// Accept implements the net.Listener interface.
+// Accept implements the net.Listener interface. With TLS, the handshake
+// happens on the first read of the connection.
 func (t *TCPStreamLayer) Accept() (c net.Conn, err error) {
-	return t.listener.Accept()
+	conn, err := t.listener.Accept()
+	if err != nil || t.tls == nil {
+		return conn, err
+	}
+	return tls.Server(conn, t.tls), nil
 }
 
 // Close implements the net.Listener interface.
@@ -34,6 +47,12 @@ func (t *TCPStreamLayer) Close() (err error) {
 	return t.listener.Close()
 }
 
+// File returns a duplicate of the listening socket, which stays open after the
+// listener is closed. It is used to hand the socket over to another process.
+func (t *TCPStreamLayer) File() (*os.File, error) {
+	return t.listener.File()
+}
+
 // Addr implements the net.Listener interface.
 func (t *TCPStreamLayer) Addr() net.Addr {
 	// Use an advertise addr if provided
@@ -44,15 +63,32 @@ func (t *TCPStreamLayer) Addr() net.Addr {
 }
 
 // NewTCPTransport returns a NetworkTransport that is built on top of
-// a TCP streaming transport layer, with log output going to the supplied Logger
+// a TCP streaming transport layer, with log output going to the supplied Logger.
+// The connections use TLS if tlsConfig is not nil, see PeerTLSConfig.
 func NewTCPTransport(
 	bindAddr string,
 	advertise net.Addr,
 	maxPool int,
 	timeout time.Duration,
+	tlsConfig *tls.Config,
 	logger *logrus.Logger,
 ) (*NetworkTransport, error) {
-	return newTCPTransport(bindAddr, advertise, maxPool, timeout, func(stream StreamLayer) *NetworkTransport {
+	return newTCPTransport(bindAddr, advertise, maxPool, timeout, tlsConfig, func(stream StreamLayer) *NetworkTransport {
+		return NewNetworkTransport(stream, maxPool, timeout, logger)
+	})
+}
+
+// NewTCPTransportFromListener is like NewTCPTransport but uses a listener that
+// is already bound, for example one inherited from a parent process
+func NewTCPTransportFromListener(
+	list net.Listener,
+	advertise net.Addr,
+	maxPool int,
+	timeout time.Duration,
+	tlsConfig *tls.Config,
+	logger *logrus.Logger,
+) (*NetworkTransport, error) {
+	return tcpTransportFromListener(list, advertise, tlsConfig, func(stream StreamLayer) *NetworkTransport {
 		return NewNetworkTransport(stream, maxPool, timeout, logger)
 	})
 }
@@ -61,17 +97,31 @@ func newTCPTransport(bindAddr string,
 	advertise net.Addr,
 	maxPool int,
 	timeout time.Duration,
+	tlsConfig *tls.Config,
 	transportCreator func(stream StreamLayer) *NetworkTransport) (*NetworkTransport, error) {
 	// Try to bind
 	list, err := net.Listen("tcp", bindAddr)
 	if err != nil {
 		return nil, err
 	}
+	return tcpTransportFromListener(list, advertise, tlsConfig, transportCreator)
+}
+
+func tcpTransportFromListener(list net.Listener,
+	advertise net.Addr,
+	tlsConfig *tls.Config,
+	transportCreator func(stream StreamLayer) *NetworkTransport) (*NetworkTransport, error) {
+	tcpList, ok := list.(*net.TCPListener)
+	if !ok {
+		list.Close()
+		return nil, errNotTCP
+	}
 
 	// Create stream
 	stream := &TCPStreamLayer{
 		advertise: advertise,
-		listener:  list.(*net.TCPListener),
+		listener:  tcpList,
+		tls:       tlsConfig,
 	}
 
 	// Verify that we have a usable advertise address
@@ -8,7 +8,7 @@ import (
 )
 
 func TestTCPTransport_BadAddr(t *testing.T) {
-	_, err := NewTCPTransport("0.0.0.0:0", nil, 1, 0, common.NewTestLogger(t))
+	_, err := NewTCPTransport("0.0.0.0:0", nil, 1, 0, nil, common.NewTestLogger(t))
 	if err != errNotAdvertisable {
 		t.Fatalf("err: %v", err)
 	}
@@ -16,7 +16,7 @@ func TestTCPTransport_BadAddr(t *testing.T) {
 
 func TestTCPTransport_WithAdvertise(t *testing.T) {
 	addr := &net.TCPAddr{IP: []byte{127, 0, 0, 1}, Port: 12345}
-	trans, err := NewTCPTransport("0.0.0.0:0", addr, 1, 0, common.NewTestLogger(t))
+	trans, err := NewTCPTransport("0.0.0.0:0", addr, 1, 0, nil, common.NewTestLogger(t))
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -24,3 +24,39 @@ func TestTCPTransport_WithAdvertise(t *testing.T) {
 		t.Fatalf("bad: %v", trans.LocalAddr())
 	}
 }
+
+func TestTCPTransport_Handoff(t *testing.T) {
+	trans1, err := NewTCPTransport("127.0.0.1:0", nil, 1, 0, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	addr := trans1.LocalAddr()
+
+	f, err := trans1.ListenerFile()
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer f.Close()
+	trans1.Close()
+
+	//the socket survives the first transport
+	list, err := net.FileListener(f)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	trans2, err := NewTCPTransportFromListener(list, nil, 1, 0, nil, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	defer trans2.Close()
+
+	if trans2.LocalAddr() != addr {
+		t.Fatalf("bad: %v", trans2.LocalAddr())
+	}
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatalf("err: %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,116 @@
+package net
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+/*
+A certificate authority vouches for the names of the nodes, so a compromised or
+careless CA can issue a certificate that impersonates any validator. Pinning
+ties every peer record to the certificates it may present instead:
+
+	{
+		"NetAddr": "172.77.5.1:1337",
+		"PubKeyHex": "0x04...",
+		"TLSPins": ["spki-sha256:9f86d081..."]
+	}
+
+A pin is the SHA256 of the DER certificate (cert-sha256) or of its public key
+(spki-sha256), in hex. SPKI pins survive the renewal of a certificate with the
+same key, and are the only ones that suit the certificates of PeerTLSConfig,
+which are generated again every time the node starts.
+
+PinPeerCertificates adds the pins to a TLS configuration, on top of the
+verification it already does: a connection, dialed or accepted, is refused
+unless the leaf certificate of the other end matches a pin of one of the peers.
+Either every peer has pins or none does, because an unpinned peer could not
+connect at all.
+*/
+
+const (
+	//SPKIPinPrefix marks the pin of the public key of a certificate
+	SPKIPinPrefix = "spki-sha256:"
+	//CertPinPrefix marks the pin of a whole certificate
+	CertPinPrefix = "cert-sha256:"
+)
+
+//SPKIPin returns the pin of the public key of cert
+func SPKIPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return SPKIPinPrefix + hex.EncodeToString(sum[:])
+}
+
+//CertificatePin returns the pin of cert
+func CertificatePin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return CertPinPrefix + hex.EncodeToString(sum[:])
+}
+
+//CheckPin returns an error if pin is not in one of the pin formats
+func CheckPin(pin string) error {
+	var digest string
+	switch {
+	case strings.HasPrefix(pin, SPKIPinPrefix):
+		digest = pin[len(SPKIPinPrefix):]
+	case strings.HasPrefix(pin, CertPinPrefix):
+		digest = pin[len(CertPinPrefix):]
+	default:
+		return fmt.Errorf("TLS pin %q should start with %s or %s", pin, SPKIPinPrefix, CertPinPrefix)
+	}
+	if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+		return fmt.Errorf("TLS pin %q should end with a hex SHA256", pin)
+	}
+	return nil
+}
+
+//PinPeerCertificates returns a copy of conf that only accepts the certificates
+//pinned by peers. conf is returned as is if no peer has pins.
+func PinPeerCertificates(conf *tls.Config, peers []Peer) (*tls.Config, error) {
+	pins := make(map[string]bool)
+	pinned := 0
+	for _, p := range peers {
+		for _, pin := range p.TLSPins {
+			if err := CheckPin(pin); err != nil {
+				return nil, fmt.Errorf("Peer %s: %s", p.NetAddr, err)
+			}
+			pins[strings.ToLower(pin)] = true
+		}
+		if len(p.TLSPins) > 0 {
+			pinned++
+		}
+	}
+	if pinned == 0 {
+		return conf, nil
+	}
+	if pinned < len(peers) {
+		return nil, fmt.Errorf("%d of %d peers have no TLS pins", len(peers)-pinned, len(peers))
+	}
+
+	verify := conf.VerifyPeerCertificate
+	res := conf.Clone()
+	res.VerifyPeerCertificate = func(rawCerts [][]byte, chains [][]*x509.Certificate) error {
+		if verify != nil {
+			if err := verify(rawCerts, chains); err != nil {
+				return err
+			}
+		}
+		if len(rawCerts) == 0 {
+			return errors.New("No peer certificate")
+		}
+		cert, err := x509.ParseCertificate(rawCerts[0])
+		if err != nil {
+			return err
+		}
+		if !pins[SPKIPin(cert)] && !pins[CertificatePin(cert)] {
+			return fmt.Errorf("Peer certificate %s matches no TLS pin", SPKIPin(cert))
+		}
+		return nil
+	}
+	return res, nil
+}
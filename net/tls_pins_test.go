@@ -0,0 +1,96 @@
+package net
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/crypto"
+)
+
+func keySPKIPin(t *testing.T, key *ecdsa.PrivateKey) string {
+	cert, err := peerCertificate(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return SPKIPin(parsed)
+}
+
+func TestPinnedPeerTLS(t *testing.T) {
+	keys := []*ecdsa.PrivateKey{}
+	for i := 0; i < 3; i++ {
+		key, _ := crypto.GenerateECDSAKey()
+		keys = append(keys, key)
+	}
+
+	//every key is trusted, but only 0 and 1 are pinned
+	peers := []Peer{
+		{NetAddr: "0", TLSPins: []string{keySPKIPin(t, keys[0])}},
+		{NetAddr: "1", TLSPins: []string{keySPKIPin(t, keys[1])}},
+	}
+	transports := []*NetworkTransport{}
+	for _, key := range keys {
+		conf, err := PeerTLSConfig(key, func(string) bool { return true })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if conf, err = PinPeerCertificates(conf, peers); err != nil {
+			t.Fatal(err)
+		}
+		trans, err := NewTCPTransport("127.0.0.1:0", nil, 2, time.Second, conf, common.NewTestLogger(t))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer trans.Close()
+		transports = append(transports, trans)
+	}
+
+	go func() {
+		for rpc := range transports[0].Consumer() {
+			rpc.Respond(&SyncResponse{From: "0"}, nil)
+		}
+	}()
+
+	var out SyncResponse
+	if err := transports[1].Sync(context.Background(), transports[0].LocalAddr(), &SyncRequest{From: "1"}, &out); err != nil {
+		t.Fatalf("A pinned peer should be able to sync: %s", err)
+	}
+	if err := transports[2].Sync(context.Background(), transports[0].LocalAddr(), &SyncRequest{From: "2"}, &out); err == nil {
+		t.Fatalf("A certificate that matches no pin should be refused")
+	}
+}
+
+func TestPinPeerCertificates(t *testing.T) {
+	key, _ := crypto.GenerateECDSAKey()
+	conf, err := PeerTLSConfig(key, func(string) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	pin := keySPKIPin(t, key)
+
+	if res, err := PinPeerCertificates(conf, []Peer{{NetAddr: "0"}}); err != nil || res != conf {
+		t.Fatalf("Peers without pins should keep the configuration, got %v", err)
+	}
+	if _, err := PinPeerCertificates(conf, []Peer{{NetAddr: "0", TLSPins: []string{pin}}, {NetAddr: "1"}}); err == nil {
+		t.Fatalf("Peers should all have pins, or none")
+	}
+	if _, err := PinPeerCertificates(conf, []Peer{{NetAddr: "0", TLSPins: []string{"sha1:abcd"}}}); err == nil {
+		t.Fatalf("An invalid pin should be refused")
+	}
+
+	for _, bad := range []string{"", "spki-sha256:", "cert-sha256:zz", "spki-sha256:abcd", "sha256:" + pin[len(SPKIPinPrefix):]} {
+		if CheckPin(bad) == nil {
+			t.Fatalf("Pin %q should be invalid", bad)
+		}
+	}
+	if err := CheckPin(pin); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -1,6 +1,9 @@
 package net
 
-import "io"
+import (
+	"context"
+	"io"
+)
 
 // RPCResponse captures both a response and a potential error.
 type RPCResponse struct {
@@ -30,12 +33,21 @@ type Transport interface {
 	// LocalAddr is used to return our local address to distinguish from our peers.
 	LocalAddr() string
 
-	// Sync sends the appropriate RPC to the target node.
-	Sync(target string, args *SyncRequest, resp *SyncResponse) error
+	// Sync sends the appropriate RPC to the target node. Sync, EagerSync and
+	// FastForward give up as soon as ctx is done, with the error of ctx, and
+	// after the timeout of the transport otherwise.
+	Sync(ctx context.Context, target string, args *SyncRequest, resp *SyncResponse) error
 
-	EagerSync(target string, args *EagerSyncRequest, resp *EagerSyncResponse) error
+	EagerSync(ctx context.Context, target string, args *EagerSyncRequest, resp *EagerSyncResponse) error
 
-	FastForward(target string, args *FastForwardRequest, resp *FastForwardResponse) error
+	FastForward(ctx context.Context, target string, args *FastForwardRequest, resp *FastForwardResponse) error
+
+	// Fetch asks the target for specific Events. Only peers that advertise
+	// CapFetch serve it.
+	Fetch(target string, args *FetchRequest, resp *FetchResponse) error
+
+	// Join asks the target to propose the addition of a participant.
+	Join(target string, args *JoinRequest, resp *JoinResponse) error
 
 	// Close permanently closes a transport, stopping
 	// any associated goroutines and freeing other resources.
@@ -51,6 +63,45 @@ type WithPeers interface {
 	DisconnectAll()                   // Disconnect all peers, possibly to reconnect them later
 }
 
+// WithProtobuf is an interface that a transport may provide to encode the RPCs
+// to some peers with the Protobuf codec.
+type WithProtobuf interface {
+	SetPeerProtobuf(peer string, enabled bool)
+}
+
+// WithMultiplex is an interface that a transport may provide to carry the RPCs
+// to some peers over a single multiplexed connection.
+type WithMultiplex interface {
+	SetPeerMultiplex(peer string, enabled bool)
+}
+
+// WithRateLimits is an interface that a transport may provide to limit the
+// inbound RPCs of each peer.
+type WithRateLimits interface {
+	// SetRateLimits applies limits to the RPCs received from now on. throttled
+	// is called with the peer and the name of the type of every refused RPC; it
+	// may be nil.
+	SetRateLimits(limits RPCRateLimits, throttled func(peer, rpc string))
+}
+
+// WithAdmission is an interface that a transport may provide to let embedders
+// refuse inbound connections, see admission.go.
+type WithAdmission interface {
+	// SetAdmissionPolicy applies policy to the connections accepted from now
+	// on. refused is called with every refused connection; it may be nil.
+	SetAdmissionPolicy(policy AdmissionPolicy, refused func(conn InboundConn, err error))
+}
+
+// WithPeerLimit is an interface that a transport may provide to keep
+// connections open with a limited number of peers.
+type WithPeerLimit interface {
+	// SetMaxPeers limits the peers with open connections, in each direction.
+	// 0 means no limit.
+	SetMaxPeers(max int)
+	// ReleasePeer closes the connections to a peer once they are idle.
+	ReleasePeer(peer string)
+}
+
 // LoopbackTransport is an interface that provides a loopback transport suitable for testing
 // e.g. InmemTransport. It's there so we don't have to rewrite tests.
 type LoopbackTransport interface {
@@ -1,6 +1,7 @@
 package net
 
 import (
+	"context"
 	"reflect"
 	"testing"
 	"time"
@@ -92,7 +93,7 @@ func TestTransport_Sync(t *testing.T) {
 		trans2.Connect(addr1, trans1)
 
 		var out SyncResponse
-		if err := trans2.Sync(trans1.LocalAddr(), &args, &out); err != nil {
+		if err := trans2.Sync(context.Background(), trans1.LocalAddr(), &args, &out); err != nil {
 			t.Fatalf("err: %v", err)
 		}
 
@@ -151,7 +152,7 @@ func TestTransport_EagerSync(t *testing.T) {
 		trans2.Connect(addr1, trans1)
 
 		var out EagerSyncResponse
-		if err := trans2.EagerSync(trans1.LocalAddr(), &args, &out); err != nil {
+		if err := trans2.EagerSync(context.Background(), trans1.LocalAddr(), &args, &out); err != nil {
 			t.Fatalf("err: %v", err)
 		}
 
@@ -236,7 +237,7 @@ func TestTransport_FastForward(t *testing.T) {
 		trans2.Connect(addr1, trans1)
 
 		var out FastForwardResponse
-		if err := trans2.FastForward(trans1.LocalAddr(), &args, &out); err != nil {
+		if err := trans2.FastForward(context.Background(), trans1.LocalAddr(), &args, &out); err != nil {
 			t.Fatalf("err: %v", err)
 		}
 
@@ -0,0 +1,124 @@
+package node
+
+import (
+	"github.com/Sirupsen/logrus"
+
+	"github.com/babbleio/babble/net"
+)
+
+/*
+In networks of 50 validators and more, gossiping with every peer means keeping
+connections to all of them. With Config.MaxActivePeers, a node only gossips
+with that many peers at a time, its active peers, picked at random, and the
+strategy of Config.PeerSelection picks among them. Events still reach every
+node, through the active peers of its active peers.
+
+Every Config.PeerRotation, the active peer synced the longest ago is replaced by
+a random inactive one, so that the node gets to gossip with every peer over
+time and the gossip graph does not stay split. The connections to the peer it
+replaced are released once they are idle. An active peer that is backed off is
+replaced right away.
+
+The transport keeps connections with at most twice MaxActivePeers peers in each
+direction, which leaves room for the peers that picked the node as one of their
+own active peers. Beyond that, it sheds the idle connections of the peers used
+the longest ago, see net.WithPeerLimit.
+*/
+
+//peers with open connections, in each direction, for every active peer
+const connectedPeersPerActivePeer = 2
+
+//SetMaxActive limits the peers the strategies pick from. 0 means all.
+func (ps *peerSet) SetMaxActive(max int) {
+	ps.maxActive = max
+	ps.active = make(map[string]bool)
+}
+
+//activePeers returns the active peers, completed or replaced at random when
+//peers were removed or backed off. It returns all the peers without a limit.
+func (ps *peerSet) activePeers() []net.Peer {
+	if ps.maxActive <= 0 || len(ps.peers) <= ps.maxActive {
+		return ps.peers
+	}
+	for _, p := range ps.peers {
+		if ps.active[p.NetAddr] && ps.backoff.backedOff(p.NetAddr) {
+			if in := ps.randomInactive(true); in != "" {
+				delete(ps.active, p.NetAddr)
+				ps.active[in] = true
+			}
+		}
+	}
+	for len(ps.active) < ps.maxActive {
+		in := ps.randomInactive(true)
+		if in == "" {
+			in = ps.randomInactive(false)
+		}
+		ps.active[in] = true
+	}
+
+	active := []net.Peer{}
+	for _, p := range ps.peers {
+		if ps.active[p.NetAddr] {
+			active = append(active, p)
+		}
+	}
+	return active
+}
+
+//randomInactive returns a random peer that is not active, and not backed off
+//if healthy is set, or "" if there is none
+func (ps *peerSet) randomInactive(healthy bool) string {
+	inactive := []string{}
+	for _, p := range ps.peers {
+		if ps.active[p.NetAddr] || healthy && ps.backoff.backedOff(p.NetAddr) {
+			continue
+		}
+		inactive = append(inactive, p.NetAddr)
+	}
+	if len(inactive) == 0 {
+		return ""
+	}
+	return inactive[ps.rand.Intn(len(inactive))]
+}
+
+//Rotate replaces the active peer synced the longest ago with a random inactive
+//one that is not backed off, and returns both. They are empty when nothing
+//changed.
+func (ps *peerSet) Rotate() (out, in string) {
+	active := ps.activePeers()
+	if len(active) == len(ps.peers) {
+		return "", ""
+	}
+	in = ps.randomInactive(true)
+	if in == "" {
+		return "", ""
+	}
+	out = active[0].NetAddr
+	for _, p := range active[1:] {
+		if ps.synced[p.NetAddr] < ps.synced[out] {
+			out = p.NetAddr
+		}
+	}
+	delete(ps.active, out)
+	ps.active[in] = true
+	return out, in
+}
+
+//rotatePeers rotates the active peers and releases the connections to the one
+//that left
+func (n *Node) rotatePeers() error {
+	n.selectorLock.Lock()
+	out, in := n.peerSelector.Rotate()
+	n.selectorLock.Unlock()
+	if out == "" {
+		return nil
+	}
+	if t, ok := n.trans.(net.WithPeerLimit); ok {
+		t.ReleasePeer(out)
+	}
+	n.logger.WithFields(logrus.Fields{
+		"out": out,
+		"in":  in,
+	}).Debug("Rotated active peer")
+	return nil
+}
@@ -0,0 +1,84 @@
+package node
+
+import (
+	"testing"
+)
+
+func TestActivePeers(t *testing.T) {
+	ps := newTestPeerSelector(t, "random", strategyPeers(8))
+	ps.SetMaxActive(3)
+
+	picked := map[string]bool{}
+	for _, p := range picks(ps, 50) {
+		picked[p] = true
+	}
+	if len(picked) != 3 {
+		t.Fatalf("Only the 3 active peers should be picked, got %v", picked)
+	}
+
+	//the active peer synced the longest ago leaves
+	var oldest string
+	for p := range picked {
+		oldest = p
+		break
+	}
+	ps.Synced(oldest, 0)
+	for p := range picked {
+		if p != oldest {
+			ps.Synced(p, 0)
+		}
+	}
+	out, in := ps.Rotate()
+	if out != oldest {
+		t.Fatalf("%s should leave the active peers, got %s", oldest, out)
+	}
+	if in == "" || picked[in] {
+		t.Fatalf("An inactive peer should replace it, got %q", in)
+	}
+	delete(picked, out)
+	picked[in] = true
+	for _, p := range picks(ps, 50) {
+		if !picked[p] {
+			t.Fatalf("%s is not an active peer, active are %v", p, picked)
+		}
+	}
+
+	//a backed off active peer is replaced right away
+	for i := 0; i < backoffThreshold; i++ {
+		ps.(BackoffSelector).MarkFailure(in)
+	}
+	for _, p := range picks(ps, 50) {
+		if p == in {
+			t.Fatalf("Backed off peer %s should not be picked", in)
+		}
+	}
+	if n := len(ps.(*RandomPeerSelector).activePeers()); n != 3 {
+		t.Fatalf("There should still be 3 active peers, got %d", n)
+	}
+
+	//without a limit, every peer is picked and nothing rotates
+	ps.SetMaxActive(0)
+	if out, _ := ps.Rotate(); out != "" {
+		t.Fatalf("Nothing should rotate without a limit, got %s", out)
+	}
+	all := map[string]bool{}
+	for _, p := range picks(ps, 200) {
+		all[p] = true
+	}
+	if len(all) != 6 || all[in] {
+		t.Fatalf("All 6 peers that are not backed off should be picked, got %v", all)
+	}
+}
+
+func TestMaxActivePeersConfig(t *testing.T) {
+	conf := TestConfig(t)
+	conf.MaxActivePeers = 2
+	conf.FanOut = 3
+	if err := conf.Validate(); err == nil {
+		t.Fatal("FanOut above MaxActivePeers should be invalid")
+	}
+	conf.FanOut = 2
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,101 @@
+package node
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+With a MaxHeartbeat, a node that has no transactions to gossip slows down: each
+gossip round that starts with an empty transaction pool doubles the delay before
+the next one, up to MaxHeartbeat. The rounds still carry the Events of the other
+nodes towards consensus, only less often. A submitted transaction snaps the
+delay back to HeartbeatTimeout and restarts the timer, so that it is gossiped
+without waiting for a long idle delay to run out.
+*/
+
+//heartbeatBackoff stretches the delays of a ControlTimer while the node is idle.
+//A nil heartbeatBackoff leaves them as they are.
+type heartbeatBackoff struct {
+	base time.Duration
+	max  time.Duration
+
+	lock   sync.Mutex
+	factor time.Duration
+}
+
+//newHeartbeatBackoff returns nil if max is 0, which disables the backoff
+func newHeartbeatBackoff(base, max time.Duration) *heartbeatBackoff {
+	if max <= 0 {
+		return nil
+	}
+	return &heartbeatBackoff{
+		base:   base,
+		max:    max,
+		factor: 1,
+	}
+}
+
+//stretch returns a delay of the timer multiplied by the backoff, up to max
+func (b *heartbeatBackoff) stretch(delay time.Duration) time.Duration {
+	if b == nil {
+		return delay
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.factor == 1 {
+		return delay
+	}
+	if delay *= b.factor; delay > b.max {
+		delay = b.max
+	}
+	return delay
+}
+
+//idle doubles the next delays, until they reach max
+func (b *heartbeatBackoff) idle() {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.base*b.factor < b.max {
+		b.factor *= 2
+	}
+}
+
+//setBase changes the delay the stretched ones are computed from
+func (b *heartbeatBackoff) setBase(base time.Duration) {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.base = base
+}
+
+//busy brings the delays back to base. It returns true if they were stretched.
+func (b *heartbeatBackoff) busy() bool {
+	if b == nil {
+		return false
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	stretched := b.factor > 1
+	b.factor = 1
+	return stretched
+}
+
+//adaptHeartbeat is called on each tick of the control timer, before preGossip
+//empties the transaction pool into a self-event
+func (n *Node) adaptHeartbeat() {
+	n.coreLock.RLock()
+	idle := len(n.core.transactionPool) == 0
+	n.coreLock.RUnlock()
+
+	if idle {
+		n.controlTimer.backoff.idle()
+	} else {
+		n.controlTimer.backoff.busy()
+	}
+}
@@ -0,0 +1,53 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+)
+
+func TestHeartbeatBackoff(t *testing.T) {
+	base := 10 * time.Millisecond
+	b := newHeartbeatBackoff(base, 50*time.Millisecond)
+
+	if d := b.stretch(base); d != base {
+		t.Fatalf("The first delay should be %s, not %s", base, d)
+	}
+	expected := []time.Duration{20, 40, 50, 50}
+	for i, e := range expected {
+		b.idle()
+		if d := b.stretch(base); d != e*time.Millisecond {
+			t.Fatalf("Delay after %d idle rounds should be %s, not %s", i+1, e*time.Millisecond, d)
+		}
+	}
+
+	if !b.busy() {
+		t.Fatal("busy should report the stretched delays")
+	}
+	if d := b.stretch(base); d != base {
+		t.Fatalf("A transaction should bring the delay back to %s, not %s", base, d)
+	}
+	if b.busy() {
+		t.Fatal("busy should not report delays that were not stretched")
+	}
+
+	//disabled
+	nb := newHeartbeatBackoff(base, 0)
+	nb.idle()
+	if d := nb.stretch(base); d != base {
+		t.Fatalf("Without a MaxHeartbeat, the delay should stay %s, not %s", base, d)
+	}
+}
+
+func TestAdaptiveHeartbeatGossip(t *testing.T) {
+	_, nodes := initNodes(4, 1000, common.NewTestLogger(t))
+	for _, n := range nodes {
+		n.controlTimer.backoff = newHeartbeatBackoff(n.conf.HeartbeatTimeout, 8*n.conf.HeartbeatTimeout)
+	}
+
+	if err := gossip(nodes, 20, true, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	checkGossip(nodes, t)
+}
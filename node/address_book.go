@@ -0,0 +1,52 @@
+package node
+
+import (
+	"time"
+
+	"github.com/babbleio/babble/net"
+)
+
+/*
+With Config.AddressBook, the node records in the address book the peers it
+learns about, when they join or when discovery moves them, stops listing the
+participants that leave, and keeps track of which peers answer its requests.
+The address book is written to disk every addressBookInterval and on Shutdown,
+so that the next run of the node can use the addresses it learned instead of
+those of a peers file that may be outdated.
+*/
+
+const addressBookInterval = time.Minute
+
+//learnPeer records the address of a participant other than this node
+func (n *Node) learnPeer(peer net.Peer, source string) {
+	if n.conf.AddressBook == nil || peer.NetAddr == n.localAddr {
+		return
+	}
+	n.conf.AddressBook.Learn(peer, source)
+}
+
+func (n *Node) forgetPeer(pubKey string) {
+	if n.conf.AddressBook == nil {
+		return
+	}
+	n.conf.AddressBook.Remove(pubKey)
+}
+
+//outbound records the result of a request of type rpc sent to peer
+func (n *Node) outbound(peer string, rpc string, start time.Time, err error) {
+	n.connectivity.outbound(peer, time.Since(start), err)
+	if err != nil {
+		n.metrics.transportError(rpc)
+	}
+	if n.conf.AddressBook != nil {
+		n.conf.AddressBook.Contacted(peer, err)
+	}
+}
+
+func (n *Node) saveAddressBook() error {
+	err := n.conf.AddressBook.Save()
+	if err != nil {
+		n.logger.WithField("error", err).Error("Saving address book")
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package node
+
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/babbleio/babble/crypto"
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+/*
+A node that fast-forwards to a Frame also receives a snapshot of the App of the
+peer, taken after the last Block that the peer committed. The App of the
+catching-up node restores it and resumes with the Blocks that follow, instead of
+missing the transactions of the rounds it skipped.
+
+On its first start, before any Block, the App receives the genesis state of the
+Config with InitChain, so that every node starts from the same state.
+
+The snapshot is only consistent with the Frame if the peer committed all the
+Events that reached consensus in its hashgraph, so a peer with Events still on
+their way to the App refuses to answer and the node tries another one.
+*/
+
+//initChain delivers the genesis state of the Config to the App. Every node
+//of the cluster must use the same genesis state.
+func (n *Node) initChain() error {
+	if n.conf.GenesisState == nil {
+		return nil
+	}
+	if err := n.proxy.InitChain(n.conf.GenesisState); err != nil {
+		return fmt.Errorf("Delivering genesis state: %s", err)
+	}
+	n.logger.WithFields(logrus.Fields{
+		"bytes": len(n.conf.GenesisState),
+		"hash":  fmt.Sprintf("%X", crypto.SHA256(n.conf.GenesisState)),
+	}).Info("Delivered genesis state")
+	return nil
+}
+
+//initFresh prepares the App on the first start of the cluster: the genesis
+//state, then the imported history
+func (n *Node) initFresh() error {
+	if err := n.initChain(); err != nil {
+		return err
+	}
+	return n.importHistory()
+}
+
+//appSnapshot returns the last Block committed by the node and a snapshot of
+//the App after it, along with the Frame of the hashgraph and the commit
+//deduplication window at the Frame. The snapshot is nil if the node has not
+//committed any Block yet.
+func (n *Node) appSnapshot() (hg.Frame, hg.Block, []byte, []net.DedupEntry, error) {
+	n.commitLock.Lock()
+	n.coreLock.RLock()
+	frame, err := n.core.GetFrame()
+	pending := n.core.GetConsensusEventsCount() - n.committedEvents
+	n.coreLock.RUnlock()
+	lastBlock := n.blockIndex - 1
+	dedup := n.commitDedup.window()
+	n.commitLock.Unlock()
+
+	if err != nil {
+		return hg.Frame{}, hg.Block{}, nil, nil, err
+	}
+	//Events committed twice around a restore are counted twice
+	if pending > 0 {
+		return hg.Frame{}, hg.Block{}, nil, nil, fmt.Errorf("%d consensus Events not committed yet", pending)
+	}
+	if lastBlock < 0 {
+		return frame, hg.Block{}, nil, dedup, nil
+	}
+	block, ok := n.blocks.get(lastBlock)
+	if !ok {
+		return hg.Frame{}, hg.Block{}, nil, nil, fmt.Errorf("Block %d not found", lastBlock)
+	}
+	//the App keeps the snapshots of its recent Blocks, so it does not matter
+	//if it committed more Blocks in the meantime
+	snapshot, err := n.proxy.GetSnapshot(lastBlock)
+	if err != nil {
+		return hg.Frame{}, hg.Block{}, nil, nil, err
+	}
+	return frame, block, snapshot, dedup, nil
+}
+
+//restoreApp restores the snapshot of a peer in the App and fast-forwards the
+//hashgraph to the Frame, so that the next Block committed is the one that
+//follows block, with the commit deduplication window of the peer.
+func (n *Node) restoreApp(frame hg.Frame, block hg.Block, snapshot []byte, dedup []net.DedupEntry) error {
+	n.commitLock.Lock()
+	defer n.commitLock.Unlock()
+
+	if err := n.proxy.Restore(snapshot); err != nil {
+		return err
+	}
+
+	n.commitDedup.restore(dedup)
+	n.persistDedup()
+	n.blockIndex = block.Index + 1
+	n.watermark.set(block.Index)
+	//the consensus Events of the old hashgraph that are still on their way to
+	//the App are part of the snapshot
+	n.restoredRound = block.RoundReceived
+
+	n.coreLock.Lock()
+	n.committedEvents = n.core.GetConsensusEventsCount()
+	err := n.core.FastForward(frame)
+	n.id = n.core.ID()
+	n.coreLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	//signatures are only recorded once they are verified
+	sigs := block.GetSignatures()
+	block.Signatures = nil
+	n.blocks.add(block, n)
+	n.blocks.addSignatures(sigs, n)
+	n.persistBlock(block.Index)
+
+	n.logger.WithFields(logrus.Fields{
+		"block":      block.Index,
+		"state_hash": fmt.Sprintf("%X", block.StateHash),
+	}).Info("Restored App snapshot")
+	return nil
+}
@@ -0,0 +1,79 @@
+package node
+
+import (
+	"errors"
+	"sort"
+
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+/*
+A node runs in one of two modes. Validators, the default, prune the history of
+their badger store below the last final Blocks, see prune.go, and bring the
+peers that are too far behind up to date with a Frame. With Config.Archive, a
+node keeps the whole hashgraph instead:
+
+ - it never prunes, and its HTTP service answers the queries for Events and
+   rounds of any age,
+ - it advertises CapArchive, and serves the archive peers that are too far
+   behind the oldest Events they miss, SyncLimit at a time, instead of telling
+   them to fast-forward,
+ - when it is too far behind itself, it does not fast-forward over the history
+   it would lose, but waits for an archive peer to serve it.
+
+An archive node that joins a running network still fast-forwards to the point
+where it joins: its history starts there.
+*/
+
+//ErrArchive is returned by Prune on an archive node
+var ErrArchive = errors.New("Archive nodes keep their whole hashgraph")
+
+//servesHistory is true if peer is served the history it misses, whatever
+//SyncLimit, because both nodes are archive nodes
+func (n *Node) servesHistory(peer string) bool {
+	return n.conf.Archive && n.peerSupports(peer, net.CapArchive)
+}
+
+//RoundInfo describes a round of the hashgraph and the fame of its witnesses
+type RoundInfo struct {
+	Round     int
+	Events    []string //hashes of the Events of the round, sorted
+	Witnesses []string
+	Famous    []string //witnesses decided famous
+	Decided   bool     //the fame of every witness is decided
+}
+
+//RoundInfo looks up a round of the hashgraph. The rounds below the Roots of
+//the store, because they were pruned or the node fast-forwarded past them, are
+//not found.
+func (n *Node) RoundInfo(r int) (RoundInfo, error) {
+	//reading the caches of the store updates them
+	n.coreLock.Lock()
+	round, err := n.core.hg.Store.GetRound(r)
+	n.coreLock.Unlock()
+	if err != nil {
+		return RoundInfo{}, err
+	}
+
+	info := RoundInfo{
+		Round:     r,
+		Events:    []string{},
+		Witnesses: []string{},
+		Famous:    []string{},
+		Decided:   round.WitnessesDecided(),
+	}
+	for hash, e := range round.Events {
+		info.Events = append(info.Events, hash)
+		if e.Witness {
+			info.Witnesses = append(info.Witnesses, hash)
+		}
+		if e.Famous == hg.True {
+			info.Famous = append(info.Famous, hash)
+		}
+	}
+	sort.Strings(info.Events)
+	sort.Strings(info.Witnesses)
+	sort.Strings(info.Famous)
+	return info, nil
+}
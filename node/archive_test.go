@@ -0,0 +1,124 @@
+package node
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestArchiveConfig(t *testing.T) {
+	conf := TestConfig(t)
+	conf.Archive = true
+	if err := conf.Validate(); err == nil {
+		t.Fatal("Archive should require the badger store")
+	}
+	conf.Store = "badger"
+	conf.StorePath = "badger_db"
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if i := conf.pruneInterval(); i != 0 {
+		t.Fatalf("Archive nodes should not prune, got an interval of %v", i)
+	}
+	conf.PruneInterval = time.Minute
+	if err := conf.Validate(); err == nil {
+		t.Fatal("Archive nodes should not accept a PruneInterval")
+	}
+
+	conf.Archive = false
+	conf.PruneInterval = 0
+	if i := conf.pruneInterval(); i != DefaultPruneInterval {
+		t.Fatalf("Validators should prune every %v, got %v", DefaultPruneInterval, i)
+	}
+	conf.PruneInterval = -1
+	if i := conf.pruneInterval(); i != 0 {
+		t.Fatalf("A negative PruneInterval should disable pruning, got %v", i)
+	}
+}
+
+func TestArchive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(4)
+	nodes := []*Node{}
+	for i := range peers {
+		conf := NewConfig(5*time.Millisecond, time.Second, 1000, 1000, logger)
+		conf.Seed = common.TestSeed()
+		conf.Store = "badger"
+		conf.StorePath = filepath.Join(dir, fmt.Sprintf("node%d", i))
+		conf.Archive = true
+		trans, err := net.NewTCPTransport(peers[i].NetAddr, nil, 2, time.Second, nil, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node := NewNode(conf, keys[i], peers, trans, aproxy.NewInmemAppProxy(logger))
+		if err := node.Init(); err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, &node)
+	}
+	defer shutdownNodes(nodes)
+	if err := gossip(nodes, 5, false, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	node := nodes[0]
+
+	if !node.conf.Capabilities.Has(net.CapArchive) {
+		t.Fatal("Archive nodes should advertise CapArchive")
+	}
+	if _, err := node.Prune(); err != ErrArchive {
+		t.Fatalf("Archive nodes should not prune, got %v", err)
+	}
+
+	info, err := node.RoundInfo(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.Decided || len(info.Witnesses) != 4 || len(info.Famous) == 0 {
+		t.Fatalf("Round 0 should have 4 decided witnesses, got %+v", info)
+	}
+	if len(info.Events) < len(info.Witnesses) {
+		t.Fatalf("The witnesses should be among the Events of the round, got %+v", info)
+	}
+	if _, err := node.RoundInfo(1000); !common.Is(err, common.KeyNotFound) {
+		t.Fatalf("Round 1000 should not be found, got %v", err)
+	}
+
+	//a deep sync serves the oldest missing Events first, with their parents
+	none := map[int]int{0: -1, 1: -1, 2: -1, 3: -1}
+	events, err := node.core.DiffLimit(none, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 5 {
+		t.Fatalf("The diff should hold 5 Events, got %d", len(events))
+	}
+	sent := map[string]bool{}
+	for _, e := range events {
+		for _, p := range []string{e.SelfParent(), e.OtherParent()} {
+			if _, err := node.core.hg.Store.GetEvent(p); p != "" && err == nil && !sent[p] {
+				t.Fatalf("The parent %s of %s should be sent before it", p, e.Hex())
+			}
+		}
+		sent[e.Hex()] = true
+	}
+	all, err := node.core.Diff(none)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) <= 5 {
+		t.Fatalf("Diff should not be limited, got %d Events", len(all))
+	}
+}
@@ -0,0 +1,144 @@
+package node
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/babbleio/babble/codec"
+	hg "github.com/babbleio/babble/hashgraph"
+)
+
+/*
+A backup is a Snapshot, followed by a copy of the BadgerStore of the node when
+it has one (see hashgraph/backup.go). Both are taken at the same frame boundary:
+consensus is paused for the time it takes to copy the last Frame and to open a
+read-only view of the store, and the store is written out from that view while
+gossip and consensus carry on. The backup of a node with an InmemStore only
+holds the Frame.
+*/
+
+//snapshotVersion is incremented when the format of Snapshot changes
+const snapshotVersion = 2
+
+//Snapshot is a consistent copy of a node's hashgraph taken at a frame
+//boundary. The Frame can be used to fast-forward a fresh node, the same way
+//a node catches up with its peers.
+type Snapshot struct {
+	Version               int
+	Taken                 time.Time
+	LastConsensusRound    int
+	ConsensusEvents       int
+	ConsensusTransactions int
+	Frame                 hg.Frame
+	Store                 bool //a copy of the store follows the Snapshot
+}
+
+//Snapshot pauses consensus for the time it takes to copy the last frame and
+//resumes it immediately
+func (n *Node) Snapshot() (Snapshot, error) {
+	snapshot, store, err := n.snapshot()
+	if store != nil {
+		store.Close()
+	}
+	return snapshot, err
+}
+
+//snapshot also opens a view of the store at the same frame boundary, when the
+//store is a BadgerStore. The view must be closed.
+func (n *Node) snapshot() (Snapshot, *hg.StoreBackup, error) {
+	n.coreLock.RLock()
+	defer n.coreLock.RUnlock()
+
+	frame, err := n.core.GetFrame()
+	if err != nil {
+		return Snapshot{}, nil, err
+	}
+
+	lcr := -1
+	if r := n.core.GetLastConsensusRoundIndex(); r != nil {
+		lcr = *r
+	}
+
+	var store *hg.StoreBackup
+	if bs, ok := n.core.hg.Store.(*hg.BadgerStore); ok {
+		store = bs.Backup()
+	}
+
+	return Snapshot{
+		Version:               snapshotVersion,
+		Taken:                 time.Now().UTC(),
+		LastConsensusRound:    lcr,
+		ConsensusEvents:       n.core.GetConsensusEventsCount(),
+		ConsensusTransactions: n.core.GetConsensusTransactionsCount(),
+		Frame:                 frame,
+		Store:                 store != nil,
+	}, store, nil
+}
+
+//WriteSnapshot takes a backup of the node and writes it to w
+func (n *Node) WriteSnapshot(w io.Writer) (Snapshot, error) {
+	snapshot, store, err := n.snapshot()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if store != nil {
+		defer store.Close()
+	}
+	if err := codec.Gob.NewEncoder(w).Encode(snapshot); err != nil {
+		return Snapshot{}, err
+	}
+	if store != nil {
+		if _, err := store.WriteTo(w); err != nil {
+			return Snapshot{}, err
+		}
+	}
+	return snapshot, nil
+}
+
+//ReadSnapshot reads a backup written by WriteSnapshot, and checks that the copy
+//of the store that follows the Snapshot, if any, is complete
+func ReadSnapshot(r io.Reader) (Snapshot, error) {
+	br := bufio.NewReader(r)
+	snapshot, err := readSnapshot(br)
+	if err != nil || !snapshot.Store {
+		return snapshot, err
+	}
+	err = hg.ReadStoreBackup(br, func(key, val []byte) error { return nil })
+	if err != nil {
+		return Snapshot{}, fmt.Errorf("Reading store: %s", err)
+	}
+	return snapshot, nil
+}
+
+//RestoreSnapshot reads a backup written by WriteSnapshot and writes the copy of
+//the store it holds to a new BadgerStore at storePath, which LoadBadgerStore
+//opens
+func RestoreSnapshot(r io.Reader, storePath string) (Snapshot, error) {
+	br := bufio.NewReader(r)
+	snapshot, err := readSnapshot(br)
+	if err != nil {
+		return Snapshot{}, err
+	}
+	if !snapshot.Store {
+		return Snapshot{}, fmt.Errorf("Backup has no store, only a Frame")
+	}
+	if err := hg.RestoreBadgerStore(storePath, br); err != nil {
+		return Snapshot{}, err
+	}
+	return snapshot, nil
+}
+
+//readSnapshot reads the Snapshot at the start of a backup. br is a ByteReader,
+//so the gob decoder leaves the store that follows in it.
+func readSnapshot(br *bufio.Reader) (Snapshot, error) {
+	var snapshot Snapshot
+	if err := codec.Gob.NewDecoder(br).Decode(&snapshot); err != nil {
+		return Snapshot{}, err
+	}
+	if snapshot.Version != snapshotVersion {
+		return Snapshot{}, fmt.Errorf("Unsupported snapshot version %d", snapshot.Version)
+	}
+	return snapshot, nil
+}
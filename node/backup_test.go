@@ -0,0 +1,123 @@
+package node
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestSnapshot(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	keys, nodes := initNodes(4, 1000, logger)
+	defer shutdownNodes(nodes)
+
+	if err := gossip(nodes, 5, false, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	//consensus keeps running while the snapshot is taken
+	var buf bytes.Buffer
+	snapshot, err := nodes[0].WriteSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.LastConsensusRound < 5 {
+		t.Fatalf("Snapshot should be taken after round 5, not %d", snapshot.LastConsensusRound)
+	}
+	if snapshot.Store {
+		t.Fatal("Snapshot of a node with an InmemStore should only hold the Frame")
+	}
+	if l := len(snapshot.Frame.Roots); l != 4 {
+		t.Fatalf("Snapshot Frame should have 4 Roots, not %d", l)
+	}
+
+	read, err := ReadSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read.LastConsensusRound != snapshot.LastConsensusRound ||
+		len(read.Frame.Events) != len(snapshot.Frame.Events) {
+		t.Fatalf("Read snapshot does not match: %d/%d rounds, %d/%d events",
+			read.LastConsensusRound, snapshot.LastConsensusRound,
+			len(read.Frame.Events), len(snapshot.Frame.Events))
+	}
+
+	//a fresh node can fast-forward from the snapshot
+	orig := nodes[0].core
+	fresh := NewCore(orig.id, keys[0], orig.participants,
+		hg.NewInmemStore(orig.participants, 1000), nil, logger)
+	if err := fresh.FastForward(read.Frame); err != nil {
+		t.Fatal(err)
+	}
+	if fresh.Head == "" {
+		t.Fatal("Fresh node should have a Head after fast-forwarding")
+	}
+}
+
+func TestBadgerSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(4)
+	nodes := []*Node{}
+	for i := range peers {
+		conf := NewConfig(5*time.Millisecond, time.Second, 1000, 1000, logger)
+		conf.Seed = common.TestSeed()
+		conf.Store = "badger"
+		conf.StorePath = filepath.Join(dir, fmt.Sprintf("node%d", i))
+		trans, err := net.NewTCPTransport(peers[i].NetAddr, nil, 2, time.Second, nil, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node := NewNode(conf, keys[i], peers, trans, aproxy.NewInmemAppProxy(logger))
+		if err := node.Init(); err != nil {
+			t.Fatal(err)
+		}
+		nodes = append(nodes, &node)
+	}
+	defer shutdownNodes(nodes)
+	if err := gossip(nodes, 3, false, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	snapshot, err := nodes[0].WriteSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !snapshot.Store {
+		t.Fatal("Snapshot of a node with a BadgerStore should hold the store")
+	}
+	data := buf.Bytes()
+	if _, err := ReadSnapshot(bytes.NewReader(data[:len(data)-1])); err == nil {
+		t.Fatal("Reading a truncated backup should fail")
+	}
+
+	path := filepath.Join(dir, "restored")
+	if _, err := RestoreSnapshot(bytes.NewReader(data), path); err != nil {
+		t.Fatal(err)
+	}
+	store, err := hg.LoadBadgerStore(1000, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	for _, e := range snapshot.Frame.Events {
+		if _, err := store.GetEvent(e.Hex()); err != nil {
+			t.Fatalf("Event %s of the Frame should be in the restored store: %v", e.Hex(), err)
+		}
+	}
+}
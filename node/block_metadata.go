@@ -0,0 +1,59 @@
+package node
+
+import (
+	"bytes"
+	"fmt"
+)
+
+/*
+The App of a node can attach opaque metadata to the next Event the node creates,
+through the MetadataCh of its AppProxy, to signal something to the Apps of the
+other nodes along with the consensus order: oracle data, a vote, a checkpoint.
+The metadata rides in the Event as a transaction with a magic prefix, so that
+the format and hash of Events do not change. When the Event reaches consensus,
+the metadata is taken out of the transactions and delivered in the Metadata of
+its Block, with the public key of the node that created the Event. A Block that
+only carries metadata is committed like any other.
+
+Metadata is never split into chunks, so that every node recognises it: it must
+fit in the payload of an Event. Application transactions that start with the
+same prefix are wrapped in a chunk envelope, like the ones that look like
+PeerJoins.
+*/
+
+var metadataMagic = []byte{0xBA, 0xBB, 0x1E, 0x4D}
+
+//metadataTransaction returns the transaction that carries data in an Event
+func metadataTransaction(data []byte) []byte {
+	tx := make([]byte, 0, len(metadataMagic)+len(data))
+	tx = append(tx, metadataMagic...)
+	return append(tx, data...)
+}
+
+func isMetadata(tx []byte) bool {
+	return bytes.HasPrefix(tx, metadataMagic)
+}
+
+//readMetadata returns the data of tx if it carries block metadata
+func readMetadata(tx []byte) ([]byte, bool) {
+	if !isMetadata(tx) {
+		return nil, false
+	}
+	return tx[len(metadataMagic):], true
+}
+
+//addBlockMetadata attaches data to the next Event of the node
+func (n *Node) addBlockMetadata(data []byte) error {
+	if n.conf.Observer {
+		return fmt.Errorf("Observers do not create Events")
+	}
+	tx := metadataTransaction(data)
+	if n.conf.MaxEventPayload > 0 && len(tx) > n.conf.MaxEventPayload {
+		return fmt.Errorf("Block metadata of %d bytes does not fit in an Event of %d bytes", len(data), n.conf.MaxEventPayload)
+	}
+
+	n.coreLock.Lock()
+	defer n.coreLock.Unlock()
+	n.core.ProposeBlockMetadata(tx)
+	return nil
+}
@@ -0,0 +1,63 @@
+package node
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestBlockMetadata(t *testing.T) {
+	keys, peers := initPeers(1)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	conf := TestConfig(t)
+	conf.MaxEventPayload = 64
+	prox := aproxy.NewInmemAppProxy(conf.Logger)
+	node := NewNode(conf, keys[0], peers, trans, prox)
+
+	//an application transaction that looks like metadata stays a transaction
+	fake := metadataTransaction([]byte("fake"))
+	node.addTransaction(fake)
+	if err := node.addBlockMetadata([]byte("oracle")); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.addBlockMetadata(make([]byte, 64)); err == nil {
+		t.Fatal("Metadata larger than an Event should be refused")
+	}
+
+	creator := keys[0].PublicKey()
+	first := hg.NewEvent(node.core.nextPayload(), []string{"", ""}, creator, 0)
+	first.SetRoundReceived(1)
+
+	//a Block that only carries metadata is committed too
+	if err := node.addBlockMetadata([]byte("vote")); err != nil {
+		t.Fatal(err)
+	}
+	second := hg.NewEvent(node.core.nextPayload(), []string{"", ""}, creator, 1)
+	second.SetRoundReceived(2)
+
+	if err := node.commit([]hg.Event{first, second}); err != nil {
+		t.Fatal(err)
+	}
+
+	if committed := prox.GetCommittedTransactions(); !reflect.DeepEqual(committed, [][]byte{fake}) {
+		t.Fatalf("Committed transactions should be the fake metadata, got %q", committed)
+	}
+	pub := fmt.Sprintf("0x%X", creator)
+	expected := []hg.BlockMetadata{
+		{Creator: pub, Data: []byte("oracle")},
+		{Creator: pub, Data: []byte("vote")},
+	}
+	if metadata := prox.GetCommittedMetadata(); !reflect.DeepEqual(metadata, expected) {
+		t.Fatalf("Committed metadata should be %v, got %v", expected, metadata)
+	}
+	if node.blockIndex != 2 {
+		t.Fatalf("Two Blocks should be committed, got %d", node.blockIndex)
+	}
+}
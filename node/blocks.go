@@ -0,0 +1,278 @@
+package node
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/babbleio/babble/common"
+	hg "github.com/babbleio/babble/hashgraph"
+)
+
+/*
+After committing a Block, the node signs it, with the StateHash returned by the
+App, and gossips the signature in its SyncRequests and SyncResponses, along with
+the signatures of the other validators it collected. A Block signed by a
+super-majority of the validators of its round is final, which clients of the
+service can check with the keys of the validators.
+
+Blocks are numbered by every node in the order it commits them. A node that
+fast-forwards to a Frame takes the numbering of its peer along with the snapshot
+of its App, otherwise it does not collect signatures for its Blocks. A node with
+a badger store writes its last Blocks to it, and resumes their numbering, its
+watermark and the subscriptions from them after a restart.
+*/
+
+const (
+	blocksKept = 100 //Blocks returned by GetBlock
+	//Blocks whose signatures are gossiped. Signatures of Blocks that the node
+	//has not committed yet are kept as long as they are in this window.
+	blocksGossiped = 10
+)
+
+type blockStore struct {
+	sync.Mutex
+	blocks         map[int]*hg.Block
+	last           int //index of the last Block, -1 if none
+	lastFinal      int //index of the last Block signed by a super-majority
+	lastFinalRound int //RoundReceived of that Block, -1 if none
+	pending        map[int][]hg.BlockSignature
+}
+
+//blockPersister is implemented by the stores that keep the last Blocks across
+//restarts, like hashgraph.BadgerStore
+type blockPersister interface {
+	SetBlock(block hg.Block, keep int) error
+	Blocks() ([]hg.Block, error)
+}
+
+//validators tells which signatures of a Block are accepted and how many make
+//it final. It is implemented by Node.
+type validators interface {
+	isValidator(pubKey string, round int) bool
+	superMajority(round int) int
+}
+
+func newBlockStore() *blockStore {
+	return &blockStore{
+		blocks:         make(map[int]*hg.Block),
+		last:           -1,
+		lastFinal:      -1,
+		lastFinalRound: -1,
+		pending:        make(map[int][]hg.BlockSignature),
+	}
+}
+
+//add records a committed Block and the signatures received for it beforehand
+func (s *blockStore) add(block hg.Block, v validators) {
+	s.Lock()
+	defer s.Unlock()
+	s.blocks[block.Index] = &block
+	s.last = block.Index
+	delete(s.blocks, block.Index-blocksKept)
+	for i := range s.pending {
+		if i <= block.Index-blocksGossiped {
+			delete(s.pending, i)
+		}
+	}
+	pending := s.pending[block.Index]
+	delete(s.pending, block.Index)
+	for _, sig := range pending {
+		s.addSignature(sig, v)
+	}
+}
+
+//addSignatures records valid signatures and returns the number of invalid ones
+func (s *blockStore) addSignatures(sigs []hg.BlockSignature, v validators) int {
+	s.Lock()
+	defer s.Unlock()
+	invalid := 0
+	for _, sig := range sigs {
+		if _, ok := s.blocks[sig.Index]; !ok {
+			if sig.Index > s.last && sig.Index < s.last+blocksGossiped {
+				s.pending[sig.Index] = append(s.pending[sig.Index], sig)
+			}
+			continue
+		}
+		if !s.addSignature(sig, v) {
+			invalid++
+		}
+	}
+	return invalid
+}
+
+//must be called with the lock held
+func (s *blockStore) addSignature(sig hg.BlockSignature, v validators) bool {
+	block := s.blocks[sig.Index]
+	if _, ok := block.Signatures[sig.Validator]; ok {
+		return true
+	}
+	if !v.isValidator(sig.Validator, block.RoundReceived) {
+		return false
+	}
+	if err := block.SetSignature(sig); err != nil {
+		return false
+	}
+	if len(block.Signatures) >= v.superMajority(block.RoundReceived) && block.Index > s.lastFinal {
+		s.lastFinal = block.Index
+		s.lastFinalRound = block.RoundReceived
+	}
+	return true
+}
+
+//signatures returns the signatures of the last blocksGossiped Blocks
+func (s *blockStore) signatures() []hg.BlockSignature {
+	s.Lock()
+	defer s.Unlock()
+	sigs := []hg.BlockSignature{}
+	for i := s.last - blocksGossiped + 1; i <= s.last; i++ {
+		if block, ok := s.blocks[i]; ok {
+			sigs = append(sigs, block.GetSignatures()...)
+		}
+	}
+	return sigs
+}
+
+//since returns the Blocks from index from to the last one, or false if some of
+//them are no longer held
+func (s *blockStore) since(from int) ([]hg.Block, bool) {
+	s.Lock()
+	defer s.Unlock()
+	if from < 0 {
+		from = 0
+	}
+	blocks := []hg.Block{}
+	for i := from; i <= s.last; i++ {
+		block, ok := s.blocks[i]
+		if !ok {
+			return nil, false
+		}
+		blocks = append(blocks, *block)
+	}
+	return blocks, true
+}
+
+func (s *blockStore) get(index int) (hg.Block, bool) {
+	s.Lock()
+	defer s.Unlock()
+	block, ok := s.blocks[index]
+	if !ok {
+		return hg.Block{}, false
+	}
+	b := *block
+	b.Signatures = make(map[string]string, len(block.Signatures))
+	for k, v := range block.Signatures {
+		b.Signatures[k] = v
+	}
+	return b, true
+}
+
+func (s *blockStore) stats() (last, lastFinal int) {
+	s.Lock()
+	defer s.Unlock()
+	return s.last, s.lastFinal
+}
+
+//finalRound returns the RoundReceived of the last final Block, or -1
+func (s *blockStore) finalRound() int {
+	s.Lock()
+	defer s.Unlock()
+	return s.lastFinalRound
+}
+
+//GetBlock returns one of the last Blocks committed by the node, with the
+//signatures of the validators collected so far
+func (n *Node) GetBlock(index int) (hg.Block, error) {
+	block, ok := n.blocks.get(index)
+	if !ok {
+		return hg.Block{}, common.NewStoreErr(common.KeyNotFound, strconv.Itoa(index))
+	}
+	return block, nil
+}
+
+//signBlock signs a Block committed by the App and records it
+func (n *Node) signBlock(block hg.Block) {
+	//the signature of an Observer does not count
+	if n.conf.Observer {
+		n.blocks.add(block, n)
+		return
+	}
+	sig, err := block.Sign(n.core.key)
+	if err != nil {
+		n.logger.WithField("error", err).Error("Signing Block")
+		return
+	}
+	n.blocks.add(block, n)
+	n.blocks.addSignatures([]hg.BlockSignature{sig}, n)
+}
+
+//persistBlock writes a recorded Block to the store, if it keeps them. The
+//signatures collected afterwards are gossiped again by the peers.
+func (n *Node) persistBlock(index int) {
+	p, ok := n.core.hg.Store.(blockPersister)
+	if !ok {
+		return
+	}
+	block, ok := n.blocks.get(index)
+	if !ok {
+		return
+	}
+	if err := p.SetBlock(block, blocksKept); err != nil {
+		n.logger.WithFields(logrus.Fields{
+			"index": index,
+			"error": err,
+		}).Error("Persisting Block")
+	}
+}
+
+//restoreBlocks records the Blocks kept in the store and resumes their
+//numbering after the last one
+func (n *Node) restoreBlocks() error {
+	p, ok := n.core.hg.Store.(blockPersister)
+	if !ok {
+		return nil
+	}
+	blocks, err := p.Blocks()
+	if err != nil {
+		return fmt.Errorf("Loading Blocks: %s", err)
+	}
+	for _, block := range blocks {
+		//signatures are only recorded once they are verified
+		sigs := block.GetSignatures()
+		block.Signatures = nil
+		n.blocks.add(block, n)
+		n.blocks.addSignatures(sigs, n)
+	}
+	if len(blocks) > 0 {
+		last := blocks[len(blocks)-1]
+		n.blockIndex = last.Index + 1
+		n.watermark.set(last.Index)
+	}
+	return nil
+}
+
+//receiveBlockSignatures records the signatures gossiped by peer
+func (n *Node) receiveBlockSignatures(peer string, sigs []hg.BlockSignature) {
+	if len(sigs) == 0 {
+		return
+	}
+	if invalid := n.blocks.addSignatures(sigs, n); invalid > 0 {
+		n.logger.WithFields(logrus.Fields{
+			"peer":    peer,
+			"invalid": invalid,
+		}).Debug("Invalid Block signatures")
+	}
+}
+
+func (n *Node) isValidator(pubKey string, round int) bool {
+	n.coreLock.RLock()
+	defer n.coreLock.RUnlock()
+	return n.core.hg.IsValidatorAt(pubKey, round)
+}
+
+func (n *Node) superMajority(round int) int {
+	n.coreLock.RLock()
+	defer n.coreLock.RUnlock()
+	return n.core.hg.SuperMajorityAt(round)
+}
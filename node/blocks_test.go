@@ -0,0 +1,119 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/crypto"
+	hg "github.com/babbleio/babble/hashgraph"
+)
+
+//fixedValidators accepts the keys of a set of validators in every round
+type fixedValidators map[string]bool
+
+func (v fixedValidators) isValidator(pubKey string, round int) bool {
+	return v[pubKey]
+}
+
+func (v fixedValidators) superMajority(round int) int {
+	return 2*len(v)/3 + 1
+}
+
+func TestBlockStore(t *testing.T) {
+	keys := []crypto.KeyPair{}
+	v := fixedValidators{}
+	for i := 0; i < 4; i++ {
+		key, _ := crypto.GenerateKeyPair(crypto.ECDSAP256)
+		keys = append(keys, key)
+		v[crypto.KeyPairPubKeyHex(key)] = true
+	}
+	stranger, _ := crypto.GenerateKeyPair(crypto.ECDSAP256)
+
+	sign := func(b hg.Block, key crypto.KeyPair) hg.BlockSignature {
+		sig, err := b.Sign(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	}
+
+	s := newBlockStore()
+	block := hg.NewBlock(0, 1, [][]byte{[]byte("tx")})
+	block.StateHash = []byte("state")
+
+	//signatures of peers that committed the Block first are kept until this
+	//node commits it
+	if invalid := s.addSignatures([]hg.BlockSignature{sign(block, keys[1])}, v); invalid != 0 {
+		t.Fatalf("Pending signature should not be invalid")
+	}
+	s.add(block, v)
+	invalid := s.addSignatures([]hg.BlockSignature{
+		sign(block, keys[0]),
+		sign(block, stranger),
+	}, v)
+	if invalid != 1 {
+		t.Fatalf("Signature of a non-validator should be invalid, got %d invalid", invalid)
+	}
+	if last, final := s.stats(); last != 0 || final != -1 {
+		t.Fatalf("Block 0 should not be final with 2 signatures, got last %d final %d", last, final)
+	}
+
+	s.addSignatures([]hg.BlockSignature{sign(block, keys[2])}, v)
+	if _, final := s.stats(); final != 0 {
+		t.Fatalf("Block 0 should be final with 3 signatures of 4 validators, got %d", final)
+	}
+
+	got, ok := s.get(0)
+	if !ok || len(got.Signatures) != 3 {
+		t.Fatalf("Block 0 should have 3 signatures, got %v", got.Signatures)
+	}
+	if len(s.signatures()) != 3 {
+		t.Fatalf("The 3 signatures should be gossiped, got %d", len(s.signatures()))
+	}
+}
+
+func TestBlockSignaturesGossip(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	_, nodes := initNodes(4, 1000, logger)
+	defer shutdownNodes(nodes)
+
+	if err := gossip(nodes, 10, false, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	//the signatures keep spreading with the heartbeat gossip
+	timeout := time.After(3 * time.Second)
+	for _, n := range nodes {
+		for {
+			if _, final := n.blocks.stats(); final >= 0 {
+				break
+			}
+			select {
+			case <-timeout:
+				t.Fatalf("Node %d has no final Block", n.id)
+			case <-time.After(10 * time.Millisecond):
+			}
+		}
+	}
+
+	first, err := nodes[0].GetBlock(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range nodes {
+		block, err := n.GetBlock(0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(block.StateHash, first.StateHash) {
+			t.Fatalf("Node %d has state hash %X, not %X", n.id, block.StateHash, first.StateHash)
+		}
+		for _, sig := range block.GetSignatures() {
+			if ok, err := block.Verify(sig); !ok {
+				t.Fatalf("Node %d holds an invalid signature of %s: %v", n.id, sig.Validator, err)
+			}
+		}
+	}
+}
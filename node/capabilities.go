@@ -0,0 +1,62 @@
+package node
+
+import (
+	"github.com/Sirupsen/logrus"
+
+	"github.com/babbleio/babble/net"
+)
+
+/*
+Peers tell each other which optional features they support in every
+SyncRequest and SyncResponse. A feature is only used with a peer when both
+sides advertise it, so a cluster can be upgraded one node at a time: upgraded
+nodes keep talking to the others without the feature, and start using it with a
+peer as soon as they learn that it was upgraded too.
+
+CapProtobuf is the wire format: once both sides advertise it, the node tells the
+transport to encode its RPCs to the peer with the Protobuf codec. CapMultiplex
+likewise makes the transport send all the RPCs to the peer over one connection.
+*/
+
+//setPeerCapabilities records the capabilities advertised by peer and logs them
+//on first contact or when they change
+func (n *Node) setPeerCapabilities(peer string, caps net.Capabilities) {
+	n.peerCapsLock.Lock()
+	defer n.peerCapsLock.Unlock()
+	old, ok := n.peerCaps[peer]
+	if ok && old == caps {
+		return
+	}
+	n.peerCaps[peer] = caps
+	if t, ok := n.trans.(net.WithProtobuf); ok {
+		t.SetPeerProtobuf(peer, n.conf.Capabilities.Has(net.CapProtobuf) && caps.Has(net.CapProtobuf))
+	}
+	if t, ok := n.trans.(net.WithMultiplex); ok {
+		t.SetPeerMultiplex(peer, n.conf.Capabilities.Has(net.CapMultiplex) && caps.Has(net.CapMultiplex))
+	}
+	n.logger.WithFields(logrus.Fields{
+		"peer":         peer,
+		"capabilities": caps.String(),
+		"common":       (caps & n.conf.Capabilities).String(),
+	}).Info("Peer capabilities")
+}
+
+//peerSupports is true if both this node and peer support the features of c
+func (n *Node) peerSupports(peer string, c net.Capabilities) bool {
+	n.peerCapsLock.Lock()
+	defer n.peerCapsLock.Unlock()
+	return n.conf.Capabilities.Has(c) && n.peerCaps[peer].Has(c)
+}
+
+//Capabilities returns the capabilities of this node and of the peers it has
+//exchanged syncs with, by net address
+func (n *Node) Capabilities() map[string]net.Capabilities {
+	n.peerCapsLock.Lock()
+	defer n.peerCapsLock.Unlock()
+	res := make(map[string]net.Capabilities, len(n.peerCaps)+1)
+	for peer, caps := range n.peerCaps {
+		res[peer] = caps
+	}
+	res[n.localAddr] = n.conf.Capabilities
+	return res
+}
@@ -0,0 +1,117 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestCapabilitiesString(t *testing.T) {
+	cases := map[net.Capabilities]string{
+		0:                                    "none",
+		net.CapCompression | net.CapObserver: "compression,observer",
+		net.CapProtobuf | net.Capabilities(1<<10): "protobuf,0x400",
+	}
+	for caps, expected := range cases {
+		if s := caps.String(); s != expected {
+			t.Fatalf("Capabilities %d should be %s, not %s", caps, expected, s)
+		}
+	}
+}
+
+func TestCapabilitiesExchange(t *testing.T) {
+	keys, peers := initPeers(3)
+	testLogger := common.NewTestLogger(t)
+
+	//node2 runs an older version that advertises nothing
+	caps := []net.Capabilities{
+		net.CapCompression | net.CapProtobuf,
+		net.CapCompression,
+		0,
+	}
+	nodes := []*Node{}
+	for i := range peers {
+		trans, err := net.NewTCPTransport(peers[i].NetAddr, nil, 2, time.Second, nil, testLogger)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		conf := TestConfig(t)
+		conf.Capabilities = caps[i]
+		node := NewNode(conf, keys[i], peers, trans, aproxy.NewInmemAppProxy(testLogger))
+		node.Init()
+		node.RunAsync(false)
+		nodes = append(nodes, &node)
+	}
+	defer shutdownNodes(nodes)
+
+	if nodes[0].peerSupports(nodes[1].localAddr, net.CapCompression) {
+		t.Fatal("Capabilities should be unknown before the first contact")
+	}
+
+	for _, peer := range nodes[1:] {
+		if _, _, err := nodes[0].pull(context.Background(), peer.localAddr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//both sides learn from a single exchange
+	if !nodes[0].peerSupports(nodes[1].localAddr, net.CapCompression) ||
+		!nodes[1].peerSupports(nodes[0].localAddr, net.CapCompression) {
+		t.Fatal("node0 and node1 should both use compression")
+	}
+	if nodes[0].peerSupports(nodes[1].localAddr, net.CapProtobuf) {
+		t.Fatal("node1 does not support protobuf")
+	}
+	if nodes[0].peerSupports(nodes[2].localAddr, net.CapCompression) {
+		t.Fatal("node2 does not support compression")
+	}
+
+	known := nodes[0].Capabilities()
+	if known[nodes[0].localAddr] != caps[0] || known[nodes[1].localAddr] != caps[1] {
+		t.Fatalf("Unexpected capabilities %v", known)
+	}
+	if c, ok := known[nodes[2].localAddr]; !ok || c != 0 {
+		t.Fatal("node2 should be known with no capabilities")
+	}
+}
+
+//Nodes that all support protobuf switch to it after their first exchange and
+//keep reaching the same consensus
+func TestProtobufGossip(t *testing.T) {
+	_, nodes := initNodes(4, 1000, common.NewTestLogger(t))
+	//the nodes share the same Config
+	nodes[0].conf.Capabilities = net.CapProtobuf
+
+	if err := gossip(nodes, 50, true, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	checkGossip(nodes, t)
+
+	for _, peer := range nodes[1:] {
+		if !nodes[0].peerSupports(peer.localAddr, net.CapProtobuf) {
+			t.Fatalf("node0 should use protobuf with %s", peer.localAddr)
+		}
+	}
+}
+
+//Nodes that all support multiplexing send their RPCs over mux sessions
+func TestMultiplexGossip(t *testing.T) {
+	_, nodes := initNodes(4, 1000, common.NewTestLogger(t))
+	//the nodes share the same Config
+	nodes[0].conf.Capabilities = net.CapMultiplex | net.CapProtobuf
+
+	if err := gossip(nodes, 50, true, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	checkGossip(nodes, t)
+
+	for _, peer := range nodes[1:] {
+		if !nodes[0].peerSupports(peer.localAddr, net.CapMultiplex) {
+			t.Fatalf("node0 should multiplex its RPCs to %s", peer.localAddr)
+		}
+	}
+}
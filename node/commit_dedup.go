@@ -0,0 +1,155 @@
+package node
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"github.com/babbleio/babble/codec"
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/net"
+)
+
+/*
+With Config.CommitDedupRounds, the node delivers to the App only the first copy
+of transactions that reached consensus more than once, as happens when an App
+submits the same transaction to several nodes for redundancy. A copy is dropped
+if the same bytes were committed in the last CommitDedupRounds rounds received.
+
+Every node commits the same transactions in the same order, so they all drop
+the same copies, as long as they start from the same window. A node that
+fast-forwards takes the window of its peer along with the Frame and the snapshot
+of its App, and a node with a badger store writes its window to it after each
+commit and resumes from it after a restart.
+*/
+
+//dedupState is the name of the window in the store, see
+//hashgraph.BadgerStore.SetNodeState
+const dedupState = "commit_dedup"
+
+type dedupEntry struct {
+	round int
+	hash  string
+}
+
+//commitDedup remembers the transactions committed in a window of rounds
+//received. It is guarded by the commitLock, except dropped which the stats read
+//without it.
+type commitDedup struct {
+	dropped int64           //atomic. First for its alignment
+	rounds  int             //0 disables deduplication
+	seen    map[string]bool //[TxHash]
+	entries []dedupEntry    //in the order they were committed
+}
+
+func newCommitDedup(rounds int) *commitDedup {
+	return &commitDedup{
+		rounds: rounds,
+		seen:   make(map[string]bool),
+	}
+}
+
+//duplicate reports whether tx was already committed in the window ending at
+//round, and records it otherwise
+func (d *commitDedup) duplicate(tx []byte, round int) bool {
+	if d.rounds <= 0 {
+		return false
+	}
+	d.expire(round)
+
+	hash := TxHash(tx)
+	if d.seen[hash] {
+		atomic.AddInt64(&d.dropped, 1)
+		return true
+	}
+	d.seen[hash] = true
+	d.entries = append(d.entries, dedupEntry{round, hash})
+	return false
+}
+
+//duplicates returns the number of copies dropped
+func (d *commitDedup) duplicates() int {
+	return int(atomic.LoadInt64(&d.dropped))
+}
+
+//expire forgets the transactions committed before the window ending at round
+func (d *commitDedup) expire(round int) {
+	i := 0
+	for ; i < len(d.entries) && d.entries[i].round <= round-d.rounds; i++ {
+		delete(d.seen, d.entries[i].hash)
+	}
+	d.entries = d.entries[i:]
+}
+
+//window returns the transactions of the window in the order they were
+//committed
+func (d *commitDedup) window() []net.DedupEntry {
+	window := make([]net.DedupEntry, 0, len(d.entries))
+	for _, e := range d.entries {
+		window = append(window, net.DedupEntry{Round: e.round, Hash: e.hash})
+	}
+	return window
+}
+
+//restore replaces the window with one returned by window, of a peer or of the
+//store
+func (d *commitDedup) restore(window []net.DedupEntry) {
+	d.seen = make(map[string]bool)
+	d.entries = make([]dedupEntry, 0, len(window))
+	for _, e := range window {
+		d.seen[e.Hash] = true
+		d.entries = append(d.entries, dedupEntry{e.Round, e.Hash})
+	}
+}
+
+//nodeStateStore is implemented by the stores that keep the state of the node
+//across restarts, like hashgraph.BadgerStore
+type nodeStateStore interface {
+	SetNodeState(name string, val []byte) error
+	GetNodeState(name string) ([]byte, error)
+}
+
+//persistDedup writes the window to the store, if it keeps the state of the
+//node. It is called with the commitLock held.
+func (n *Node) persistDedup() {
+	s, ok := n.core.hg.Store.(nodeStateStore)
+	if !ok || n.commitDedup.rounds <= 0 {
+		return
+	}
+	var val []byte
+	for _, e := range n.commitDedup.window() {
+		val = codec.AppendBytes(val, 1, e.MarshalProto())
+	}
+	if err := s.SetNodeState(dedupState, val); err != nil {
+		n.logger.WithField("error", err).Error("Persisting commit deduplication window")
+	}
+}
+
+//restoreDedup resumes the window written to the store before a restart
+func (n *Node) restoreDedup() error {
+	s, ok := n.core.hg.Store.(nodeStateStore)
+	if !ok {
+		return nil
+	}
+	val, err := s.GetNodeState(dedupState)
+	if common.Is(err, common.KeyNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	window := []net.DedupEntry{}
+	err = codec.ReadFields(val, func(f codec.ProtoField) error {
+		var e net.DedupEntry
+		if err := e.UnmarshalProto(f.Bytes); err != nil {
+			return err
+		}
+		window = append(window, e)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("Loading commit deduplication window: %s", err)
+	}
+	n.commitDedup.restore(window)
+	n.logger.WithField("transactions", len(window)).Debug("Restored commit deduplication window")
+	return nil
+}
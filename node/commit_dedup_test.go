@@ -0,0 +1,136 @@
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+func TestCommitDedup(t *testing.T) {
+	d := newCommitDedup(2)
+	steps := []struct {
+		tx        string
+		round     int
+		duplicate bool
+	}{
+		{"a", 1, false},
+		{"b", 1, false},
+		{"a", 1, true},
+		{"a", 2, true},
+		//"a" and "b" were committed in round 1, outside the window of round 3
+		{"a", 3, false},
+		{"b", 4, false},
+		{"a", 4, true},
+	}
+	for i, s := range steps {
+		if dup := d.duplicate([]byte(s.tx), s.round); dup != s.duplicate {
+			t.Fatalf("Step %d: %s in round %d should be duplicate=%v", i, s.tx, s.round, s.duplicate)
+		}
+	}
+	if d.duplicates() != 3 {
+		t.Fatalf("3 duplicates should be dropped, not %d", d.duplicates())
+	}
+
+	//a window restored from another one drops the same copies
+	restored := newCommitDedup(2)
+	restored.restore(d.window())
+	if !restored.duplicate([]byte("b"), 5) || restored.duplicate([]byte("c"), 5) {
+		t.Fatal("The restored window should hold b, and not c")
+	}
+
+	disabled := newCommitDedup(0)
+	if disabled.duplicate([]byte("a"), 1) || disabled.duplicate([]byte("a"), 1) {
+		t.Fatal("Deduplication should be disabled")
+	}
+}
+
+func TestNodeCommitDedup(t *testing.T) {
+	keys, peers := initPeers(1)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	conf := TestConfig(t)
+	conf.CommitDedupRounds = 5
+	prox := &blockProxy{submitCh: make(chan []byte)}
+	node := NewNode(conf, keys[0], peers, trans, prox)
+
+	//the same transaction included by the Events of two validators
+	ev1 := hg.NewEvent([][]byte{[]byte("tx")}, []string{"", ""}, []byte{}, 0)
+	ev2 := hg.NewEvent([][]byte{[]byte("tx"), []byte("other")}, []string{"", ""}, []byte{}, 1)
+	ev1.SetRoundReceived(1)
+	ev2.SetRoundReceived(2)
+	if err := node.commit([]hg.Event{ev1, ev2}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []hg.Block{
+		hg.NewBlock(0, 1, [][]byte{[]byte("tx")}),
+		hg.NewBlock(1, 2, [][]byte{[]byte("other")}),
+	}
+	if !reflect.DeepEqual(prox.blocks, expected) {
+		t.Fatalf("Committed Blocks should be %v, not %v", expected, prox.blocks)
+	}
+	if _, ok := node.TxStatus(TxHash([]byte("tx"))); !ok {
+		t.Fatal("Deduplicated transaction should still be reported as committed")
+	}
+	if d := node.GetStats()["duplicate_commits"]; d != "1" {
+		t.Fatalf("duplicate_commits should be 1, not %s", d)
+	}
+}
+
+func TestCommitDedupRestart(t *testing.T) {
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keys, peers := initPeers(1)
+	newNode := func() (*Node, *blockProxy) {
+		_, trans := net.NewInmemTransport(peers[0].NetAddr)
+		conf := TestConfig(t)
+		conf.CommitDedupRounds = 5
+		conf.Store = "badger"
+		conf.StorePath = dir
+		prox := &blockProxy{submitCh: make(chan []byte)}
+		node := NewNode(conf, keys[0], peers, trans, prox)
+		if node.confErr != nil {
+			t.Fatal(node.confErr)
+		}
+		return &node, prox
+	}
+
+	node, _ := newNode()
+	ev := hg.NewEvent([][]byte{[]byte("tx")}, []string{"", ""}, []byte{}, 0)
+	ev.SetRoundReceived(1)
+	if err := node.commit([]hg.Event{ev}); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.core.hg.Store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	//the restarted node still drops the copy committed in the next round
+	node, prox := newNode()
+	defer node.core.hg.Store.Close()
+	if err := node.restoreBlocks(); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.restoreDedup(); err != nil {
+		t.Fatal(err)
+	}
+	again := hg.NewEvent([][]byte{[]byte("tx"), []byte("other")}, []string{"", ""}, []byte{}, 1)
+	again.SetRoundReceived(2)
+	if err := node.commit([]hg.Event{again}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []hg.Block{hg.NewBlock(1, 2, [][]byte{[]byte("other")})}
+	if !reflect.DeepEqual(prox.blocks, expected) {
+		t.Fatalf("Committed Blocks should be %v, not %v", expected, prox.blocks)
+	}
+}
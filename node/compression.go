@@ -0,0 +1,59 @@
+package node
+
+import (
+	"github.com/Sirupsen/logrus"
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+/*
+A node that advertises CapCompression accepts compressed Events: it sets
+Compress in its SyncRequests and decompresses the CompressedEvents of the
+SyncResponses and EagerSyncRequests it receives. It only compresses the Events
+it sends itself if CompressEvents is set in its Config, which trades CPU for
+bandwidth when syncs carry many transactions.
+
+With a peer that also advertises CapDictionary, the Events are compressed
+with EventsDictionaryV1, which makes the small syncs of an idle network smaller
+too.
+*/
+
+//eventsDictionary returns the dictionary to compress the Events sent to a peer
+//with capabilities caps
+func (n *Node) eventsDictionary(caps net.Capabilities) uint32 {
+	if n.conf.Capabilities.Has(net.CapDictionary) && caps.Has(net.CapDictionary) {
+		return net.EventsDictionaryV1
+	}
+	return net.NoDictionary
+}
+
+//compressEvents returns the compressed encoding of events with dictionary if
+//compression is enabled and makes them smaller. Otherwise it returns nil and
+//events are sent as they are.
+func (n *Node) compressEvents(events []hg.WireEvent, dictionary uint32) []byte {
+	if !n.conf.CompressEvents || len(events) == 0 {
+		return nil
+	}
+	compressed, smaller, err := net.CompressEvents(events, dictionary)
+	if err != nil {
+		n.logger.WithField("error", err).Error("Compressing Events")
+		return nil
+	}
+	if !smaller {
+		return nil
+	}
+	n.logger.WithFields(logrus.Fields{
+		"events":     len(events),
+		"compressed": len(compressed),
+		"dictionary": dictionary,
+	}).Debug("Compressed Events")
+	return compressed
+}
+
+//decompressEvents returns the Events of a sync, compressed or not
+func decompressEvents(events []hg.WireEvent, compressed []byte, dictionary uint32) ([]hg.WireEvent, error) {
+	if len(compressed) == 0 {
+		return events, nil
+	}
+	return net.DecompressEvents(compressed, dictionary)
+}
@@ -0,0 +1,69 @@
+package node
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+func TestCompressEvents(t *testing.T) {
+	_, nodes := initNodes(1, 1000, common.NewTestLogger(t))
+	node := nodes[0]
+	events := []hg.WireEvent{{Body: hg.WireBody{Transactions: [][]byte{bytes.Repeat([]byte("tx"), 100)}}}}
+
+	if c := node.compressEvents(events, net.NoDictionary); c != nil {
+		t.Fatal("Events should not be compressed unless CompressEvents is set")
+	}
+
+	node.conf.CompressEvents = true
+	compressed := node.compressEvents(events, net.NoDictionary)
+	if compressed == nil {
+		t.Fatal("Repeated transactions should be compressed")
+	}
+	res, err := decompressEvents(nil, compressed, net.NoDictionary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(events, res) {
+		t.Fatalf("Decompressed Events should be %#v, not %#v", events, res)
+	}
+	if res, _ := decompressEvents(events, nil, net.NoDictionary); !reflect.DeepEqual(events, res) {
+		t.Fatal("Uncompressed Events should be returned as they are")
+	}
+
+	//the dictionary is only used when both sides advertise it
+	if d := node.eventsDictionary(net.CapDictionary); d != net.NoDictionary {
+		t.Fatalf("Node without CapDictionary should use dictionary %d, not %d", net.NoDictionary, d)
+	}
+	node.conf.Capabilities |= net.CapDictionary
+	if d := node.eventsDictionary(net.CapCompression); d != net.NoDictionary {
+		t.Fatalf("Peer without CapDictionary should get dictionary %d, not %d", net.NoDictionary, d)
+	}
+	if d := node.eventsDictionary(net.CapCompression | net.CapDictionary); d != net.EventsDictionaryV1 {
+		t.Fatalf("Peer with CapDictionary should get dictionary %d, not %d", net.EventsDictionaryV1, d)
+	}
+}
+
+func TestCompressedGossip(t *testing.T) {
+	_, nodes := initNodes(4, 1000, common.NewTestLogger(t))
+	defer shutdownNodes(nodes)
+	//the nodes share the same Config
+	nodes[0].conf.Capabilities = net.CapCompression | net.CapDictionary
+	nodes[0].conf.CompressEvents = true
+
+	if err := gossip(nodes, 50, false, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	checkGossip(nodes, t)
+
+	for _, peer := range nodes[1:] {
+		if !nodes[0].peerSupports(peer.localAddr, net.CapCompression|net.CapDictionary) {
+			t.Fatalf("node0 should compress Events for %s", peer.localAddr)
+		}
+	}
+}
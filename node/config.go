@@ -1,19 +1,79 @@
 package node
 
 import (
+	"fmt"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/babbleio/babble/common"
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
 	"github.com/Sirupsen/logrus"
 )
 
+//Config holds the parameters of a Node. Build one with NewDefaultConfig or
+//NewConfig, adjust it, and check it with Validate before passing it to NewNode.
 type Config struct {
-	HeartbeatTimeout time.Duration
-	TCPTimeout       time.Duration
-	CacheSize        int
-	SyncLimit        int
-	Logger           *logrus.Logger
+	HeartbeatTimeout  time.Duration
+	HeartbeatJitter   float64       //share of HeartbeatTimeout added at random to each delay between gossips, in [0, 1]. 0 means 1
+	MaxHeartbeat      time.Duration //delay between gossips reached by backing off while no transactions arrive. 0 disables
+	TCPTimeout        time.Duration
+	CacheSize         int
+	SyncLimit         int
+	SyncBytesLimit    int           //max size of the events in a single sync. 0 means no limit
+	MaxEventPayload   int           //max bytes of transactions per Event. 0 means no limit
+	StallTimeout      time.Duration //time without consensus progress before recovery. 0 disables
+	InboundSyncs      int           //inbound syncs processed at the same time. 0 means 1
+	FanOut            int           //peers gossiped with concurrently in each gossip round. 0 means 1
+	MaxBackgroundJobs int           //periodic background jobs run at the same time. 0 means no limit
+	DutyWindow        int           //consensus rounds and syncs the validator duties are computed over. 0 means DefaultDutyWindow
+	MaxPoolTxs        int           //transactions in the pool above which submissions are refused. 0 means no limit
+	MaxPoolBytes      int           //bytes in the pool above which submissions are refused. 0 means no limit
+	BlockOnFullPool   bool          //submissions wait for room in a full pool instead of being refused
+	InsertChunk       int           //events of a backfill inserted at a time. 0 inserts batches whole
+	PushBatch         int           //events in each EagerSync request of a push, see push_batches.go. 0 means no limit but SyncBytesLimit
+	FastForwardChunk  int           //bytes of the Frame and App snapshot received per FastForward request, see fast_forward_transfer.go. 0 receives them whole
+	VerifyWorkers     int           //goroutines checking the signatures of synced events. 0 means one per CPU
+	ConsensusCPUShare float64       //max share of time spent computing consensus, in (0, 1]. 0 means no cap
+	Store             string        //hashgraph store: "inmem" or "badger". Empty means inmem
+	StorePath         string        //directory of the badger store
+	MinFreeDisk       int64         //free bytes below which the node is under disk pressure, see disk_pressure.go. 0 disables
+	DiskPath          string        //directory whose filesystem is checked for MinFreeDisk. Empty means StorePath
+	PruneInterval     time.Duration //interval at which a validator prunes the badger store, see prune.go. 0 means DefaultPruneInterval, negative disables
+	PruneRetention    int           //decided rounds kept by pruning. 0 means DefaultPruneRetention
+	EventPolicy       EventCreationPolicy
+	TxMiddleware      []TxMiddleware      //applied in order to submitted and committed transactions
+	TxCost            TxCostFunc          //submitter and cost of the transactions, checked against SubmitterBudget
+	SubmitterBudget   int                 //max cost of the pending transactions of each submitter. 0 means no limit
+	TxAppID           TxAppIDFunc         //App of the transactions, for the AppID of CommitFilters. nil disables
+	CommitDedupRounds int                 //rounds within which a transaction committed again is dropped. 0 disables
+	OrphanRounds      int                 //rounds after which Events whose parents never arrived are discarded
+	ShareConnectivity bool                //gossip connectivity rows to build the cluster matrix
+	Capabilities      net.Capabilities    //optional features advertised to peers
+	CompressEvents    bool                //compress the Events sent to the peers that accept it
+	RPCRateLimits     net.RPCRateLimits   //requests accepted from each peer per type of RPC. Zero values mean no limit
+	Admission         net.AdmissionPolicy //decides which inbound connections are served, see net/admission.go. nil admits all
+	AddressBook       *net.AddressBook    //records the peers learned at runtime. nil disables
+	AdmitKeys         []string            //public keys this node proposes and endorses as new participants, see membership.go
+	Metrics           bool                //collect Prometheus metrics
+	Seed              int64               //seed of peer selection and heartbeat jitter. 0 picks one at random
+	PeerSelection     string              //strategy picking the peers to gossip with, see NewPeerSelector. Empty means random
+	Zone              string              //zone or region of the node, advertised to peers
+	ZoneAffinity      float64             //share of gossip rounds with peers of the same Zone, in [0, 1)
+	MaxActivePeers    int                 //peers gossiped with at a time, see active_peers.go. 0 means all
+	PeerRotation      time.Duration       //interval at which an active peer is replaced by another. 0 disables
+	GenesisState      []byte              //delivered to the App with InitChain on the first start. nil disables
+	History           *History            //committed to the App as pre-genesis Blocks on the first start. nil disables
+	HistoryHash       []byte              //expected Hash of History. nil accepts any
+	Observer          bool                //follow the hashgraph without creating Events, see observer.go
+	Archive           bool                //keep the whole hashgraph and serve its history, see archive.go
+	CloneFrom         string              //address of the node an Observer fast-forwards from first
+	SchemeVersion     int                 //version of the scheme Events are hashed and signed with
+	MinSchemeVersion  int                 //oldest scheme version accepted from other nodes
+	Hash              string              //hash function of Events and Blocks, chosen at genesis: sha256, blake2b-256 or keccak-256. Empty means sha256
+	ListenAddr        string              //IP:Port for the transport of embedders that build it from a LoadConfig file. NewNode uses the address of its transport
+	Logger            *logrus.Logger
 }
 
 func NewConfig(heartbeat time.Duration,
@@ -30,7 +90,9 @@ func NewConfig(heartbeat time.Duration,
 	}
 }
 
-func DefaultConfig() *Config {
+//NewDefaultConfig returns a valid Config with sensible values for a small
+//cluster on a local network
+func NewDefaultConfig() *Config {
 	logger := logrus.New()
 	logger.Level = logrus.DebugLevel
 	return &Config{
@@ -38,12 +100,195 @@ func DefaultConfig() *Config {
 		TCPTimeout:       1000 * time.Millisecond,
 		CacheSize:        500,
 		SyncLimit:        100,
+		SyncBytesLimit:   16 * 1024 * 1024,
+		MaxEventPayload:  1024 * 1024,
+		StallTimeout:     time.Minute,
+		InboundSyncs:     2,
+		InsertChunk:      50,
+		FastForwardChunk: 1024 * 1024,
+		OrphanRounds:     10,
+		Capabilities:     net.CapFetch | net.CapCompression | net.CapMultiplex | net.CapDictionary | net.CapPushBatches,
+		EventPolicy:      EverySyncPolicy{},
 		Logger:           logger,
 	}
 }
 
 func TestConfig(t *testing.T) *Config {
-	config := DefaultConfig()
+	config := NewDefaultConfig()
 	config.Logger = common.NewTestLogger(t)
+	config.Seed = common.TestSeed()
 	return config
 }
+
+//ConfigError lists the problems found by Config.Validate
+type ConfigError []string
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("Invalid config: %s", strings.Join(e, "; "))
+}
+
+/*
+Validate checks the parameters of the Config, alone and against one another,
+and returns a ConfigError listing all the problems, or nil. NewNode validates
+its Config and Init or Join report the error, but embedders can call Validate
+beforehand to reject a bad configuration early.
+
+Beyond the ranges of the parameters, it checks that a sync fits in the cache of
+the receiver (SyncLimit <= CacheSize), that an Event fits in a sync
+(MaxEventPayload <= SyncBytesLimit), and that the stall monitor leaves time for
+a few gossip rounds, each of which may wait HeartbeatTimeout before it starts
+and TCPTimeout for the response.
+*/
+func (c *Config) Validate() error {
+	var errs ConfigError
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Logger != nil, "Logger is required")
+	check(c.HeartbeatTimeout > 0, "HeartbeatTimeout must be positive, got %s", c.HeartbeatTimeout)
+	check(c.HeartbeatJitter >= 0 && c.HeartbeatJitter <= 1,
+		"HeartbeatJitter must be between 0 and 1, got %g", c.HeartbeatJitter)
+	check(c.MaxHeartbeat == 0 || c.MaxHeartbeat >= c.HeartbeatTimeout,
+		"MaxHeartbeat %s is shorter than HeartbeatTimeout %s", c.MaxHeartbeat, c.HeartbeatTimeout)
+	check(c.TCPTimeout > 0, "TCPTimeout must be positive, got %s", c.TCPTimeout)
+	check(c.CacheSize > 0, "CacheSize must be positive, got %d", c.CacheSize)
+	check(c.SyncLimit > 0, "SyncLimit must be positive, got %d", c.SyncLimit)
+	check(c.SyncBytesLimit >= 0, "SyncBytesLimit must not be negative, got %d", c.SyncBytesLimit)
+	check(c.MaxEventPayload >= 0, "MaxEventPayload must not be negative, got %d", c.MaxEventPayload)
+	check(c.StallTimeout >= 0, "StallTimeout must not be negative, got %s", c.StallTimeout)
+	check(c.InboundSyncs >= 0, "InboundSyncs must not be negative, got %d", c.InboundSyncs)
+	check(c.MaxBackgroundJobs >= 0, "MaxBackgroundJobs must not be negative, got %d", c.MaxBackgroundJobs)
+	check(c.DutyWindow >= 0, "DutyWindow must not be negative, got %d", c.DutyWindow)
+	check(c.MaxPoolTxs >= 0, "MaxPoolTxs must not be negative, got %d", c.MaxPoolTxs)
+	check(c.MaxPoolBytes >= 0, "MaxPoolBytes must not be negative, got %d", c.MaxPoolBytes)
+	check(c.FanOut >= 0, "FanOut must not be negative, got %d", c.FanOut)
+	check(c.SubmitterBudget >= 0, "SubmitterBudget must not be negative, got %d", c.SubmitterBudget)
+	check((c.TxCost != nil) == (c.SubmitterBudget > 0), "TxCost and SubmitterBudget must be set together")
+	if err := c.RPCRateLimits.Validate(); err != nil {
+		check(false, "%s", err)
+	}
+	check(c.InsertChunk >= 0, "InsertChunk must not be negative, got %d", c.InsertChunk)
+	check(c.PushBatch >= 0, "PushBatch must not be negative, got %d", c.PushBatch)
+	check(c.FastForwardChunk >= 0, "FastForwardChunk must not be negative, got %d", c.FastForwardChunk)
+	check(c.VerifyWorkers >= 0, "VerifyWorkers must not be negative, got %d", c.VerifyWorkers)
+	check(c.CommitDedupRounds >= 0, "CommitDedupRounds must not be negative, got %d", c.CommitDedupRounds)
+	check(c.OrphanRounds >= 0, "OrphanRounds must not be negative, got %d", c.OrphanRounds)
+	for _, pk := range c.AdmitKeys {
+		p := net.Peer{PubKeyHex: pk}
+		_, err := p.Algorithm()
+		check(err == nil, "AdmitKeys: %v", err)
+	}
+	check(c.ZoneAffinity >= 0 && c.ZoneAffinity < 1,
+		"ZoneAffinity must be at least 0 and less than 1, got %g", c.ZoneAffinity)
+	check(c.Zone != "" || c.ZoneAffinity == 0, "ZoneAffinity requires a Zone")
+	check(c.MaxActivePeers >= 0, "MaxActivePeers must not be negative, got %d", c.MaxActivePeers)
+	check(c.PeerRotation >= 0, "PeerRotation must not be negative, got %s", c.PeerRotation)
+	check(c.MaxActivePeers == 0 || c.FanOut <= c.MaxActivePeers,
+		"FanOut %d exceeds MaxActivePeers %d", c.FanOut, c.MaxActivePeers)
+	check(c.ConsensusCPUShare >= 0 && c.ConsensusCPUShare <= 1,
+		"ConsensusCPUShare must be between 0 and 1, got %g", c.ConsensusCPUShare)
+	if err := c.schemeWindow().Validate(); err != nil {
+		check(false, "%s", err)
+	}
+	if c.Hash != "" {
+		_, err := hg.HashScheme(c.Hash)
+		check(err == nil, "%s", err)
+		check(c.SchemeVersion == 0 && c.MinSchemeVersion == 0,
+			"Hash selects the scheme version, SchemeVersion and MinSchemeVersion must not be set")
+	}
+	check(c.HistoryHash == nil || c.History != nil, "HistoryHash requires a History")
+	check(c.CloneFrom == "" || c.Observer, "CloneFrom requires Observer")
+	check(c.MinFreeDisk >= 0, "MinFreeDisk must not be negative, got %d", c.MinFreeDisk)
+	check(c.MinFreeDisk == 0 || c.diskPath() != "", "MinFreeDisk requires a DiskPath or StorePath")
+	check(c.PruneRetention >= 0, "PruneRetention must not be negative, got %d", c.PruneRetention)
+	check(c.PruneInterval <= 0 || c.Store == "badger", "PruneInterval requires the badger Store")
+	check(!c.Archive || c.Store == "badger", "Archive requires the badger Store")
+	check(!c.Archive || c.PruneInterval <= 0, "Archive nodes do not prune, PruneInterval must not be set")
+	check(!c.Archive || c.CloneFrom == "", "Archive nodes do not fast-forward, CloneFrom must not be set")
+
+	check(c.SyncLimit <= c.CacheSize,
+		"SyncLimit %d exceeds CacheSize %d", c.SyncLimit, c.CacheSize)
+	if c.SyncBytesLimit > 0 && c.MaxEventPayload > 0 {
+		check(c.MaxEventPayload <= c.SyncBytesLimit,
+			"MaxEventPayload %d exceeds SyncBytesLimit %d", c.MaxEventPayload, c.SyncBytesLimit)
+	}
+	if c.StallTimeout > 0 && c.HeartbeatTimeout > 0 && c.TCPTimeout > 0 {
+		round := c.HeartbeatTimeout + c.TCPTimeout
+		check(c.StallTimeout >= minStallRounds*round,
+			"StallTimeout %s is shorter than %d gossip rounds of HeartbeatTimeout + TCPTimeout (%s)",
+			c.StallTimeout, minStallRounds, minStallRounds*round)
+	}
+
+	if _, err := NewPeerSelector(c.PeerSelection, nil, ""); err != nil {
+		errs = append(errs, err.Error())
+	}
+
+	switch c.Store {
+	case "", "inmem":
+	case "badger":
+		check(c.StorePath != "", "Badger store requires a StorePath")
+	default:
+		errs = append(errs, fmt.Sprintf("Unknown store %s", c.Store))
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+//minStallRounds is the number of gossip rounds the stall monitor must wait
+//before it considers consensus stuck
+const minStallRounds = 10
+
+//diskPath is the directory checked for Config.MinFreeDisk
+func (c *Config) diskPath() string {
+	if c.DiskPath != "" {
+		return c.DiskPath
+	}
+	return c.StorePath
+}
+
+//pruneInterval is the interval of the pruning job, 0 if the store is not pruned
+func (c *Config) pruneInterval() time.Duration {
+	switch {
+	case c.Archive || c.Store != "badger" || c.PruneInterval < 0:
+		return 0
+	case c.PruneInterval > 0:
+		return c.PruneInterval
+	default:
+		return DefaultPruneInterval
+	}
+}
+
+//pruneRetention is the number of decided rounds kept by pruning
+func (c *Config) pruneRetention() int {
+	if c.PruneRetention > 0 {
+		return c.PruneRetention
+	}
+	return DefaultPruneRetention
+}
+
+//schemeWindow is the range of Event scheme versions set by the Config. A Hash
+//admits the single version that hashes with it.
+func (c *Config) schemeWindow() hg.SchemeWindow {
+	if c.Hash != "" {
+		v := c.blockVersion()
+		return hg.SchemeWindow{Active: v, Oldest: v}
+	}
+	return hg.SchemeWindow{Active: c.SchemeVersion, Oldest: c.MinSchemeVersion}
+}
+
+//blockVersion is the scheme Blocks are hashed with. Unlike the version of
+//Events, it only follows the Hash chosen at genesis, so that every node signs
+//the same hash while the scheme of Events changes.
+func (c *Config) blockVersion() int {
+	if c.Hash == "" {
+		return 0
+	}
+	v, _ := hg.HashScheme(c.Hash)
+	return v
+}
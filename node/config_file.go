@@ -0,0 +1,259 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+/*
+LoadConfig builds a Config from a file and the environment, so that an
+embedder does not have to hardcode its tunables. Every field of the Config that
+holds a duration, a number, a boolean or a string is a setting, named after the
+field in snake case: HeartbeatTimeout is heartbeat_timeout, StorePath is
+store_path, ListenAddr is listen_addr. Durations are written like "500ms".
+
+The file is JSON, or TOML if its name ends in .toml. Only the flat key = value
+subset of TOML is read, which is all the settings need:
+
+	# babble.toml
+	heartbeat_timeout = "50ms"
+	cache_size = 10_000
+	store = "badger"
+	store_path = "/var/lib/babble/badger_db"
+	listen_addr = "172.77.5.1:1337"
+
+The environment variable named BABBLE_ followed by the setting in upper case,
+like BABBLE_SYNC_LIMIT, overrides the file. Settings start from
+NewDefaultConfig.
+*/
+
+//ConfigEnvPrefix starts the names of the environment variables read by
+//LoadConfig
+const ConfigEnvPrefix = "BABBLE_"
+
+//LoadConfig returns the Config of the file at path, which may be empty, with
+//the overrides of the environment. Every problem, from a bad value to an invalid
+//Config, is reported in a ConfigError.
+func LoadConfig(path string) (*Config, error) {
+	conf := NewDefaultConfig()
+	settings := configSettings(conf)
+	var errs ConfigError
+
+	if path != "" {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var values map[string]string
+		if strings.EqualFold(filepath.Ext(path), ".toml") {
+			values, err = parseTOML(data)
+		} else {
+			values, err = parseJSONSettings(data)
+		}
+		if err != nil {
+			return nil, ConfigError{fmt.Sprintf("%s: %s", path, err)}
+		}
+
+		//sorted so that the problems are always listed in the same order
+		keys := make([]string, 0, len(values))
+		for k := range values {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			field, ok := settings[k]
+			if !ok {
+				errs = append(errs, fmt.Sprintf("%s: unknown setting %s", path, k))
+				continue
+			}
+			if err := setConfigValue(field, values[k]); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s: %s", path, k, err))
+			}
+		}
+	}
+
+	names := make([]string, 0, len(settings))
+	for name := range settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		env := ConfigEnvPrefix + strings.ToUpper(name)
+		value, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		if err := setConfigValue(settings[name], value); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", env, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+//configSettings maps the names of the settings to the fields of conf
+func configSettings(conf *Config) map[string]reflect.Value {
+	settings := make(map[string]reflect.Value)
+	v := reflect.ValueOf(conf).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		f := v.Field(i)
+		if f.Type() != durationType && f.Type().PkgPath() != "" {
+			continue
+		}
+		switch f.Kind() {
+		case reflect.Int, reflect.Int64, reflect.Float64, reflect.Bool, reflect.String:
+			settings[snakeCase(v.Type().Field(i).Name)] = f
+		}
+	}
+	return settings
+}
+
+func setConfigValue(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q, expected a value like \"500ms\" or \"2s\"", value)
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.Int, reflect.Int64:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		field.SetInt(i)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		field.SetFloat(f)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q, expected true or false", value)
+		}
+		field.SetBool(b)
+	case reflect.String:
+		field.SetString(value)
+	}
+	return nil
+}
+
+//snakeCase turns a field name like TCPTimeout into tcp_timeout
+func snakeCase(name string) string {
+	r := []rune(name)
+	var b strings.Builder
+	for i, c := range r {
+		if i > 0 && unicode.IsUpper(c) &&
+			(unicode.IsLower(r[i-1]) || (i+1 < len(r) && unicode.IsLower(r[i+1]))) {
+			b.WriteByte('_')
+		}
+		b.WriteRune(unicode.ToLower(c))
+	}
+	return b.String()
+}
+
+//parseJSONSettings reads a JSON object of settings into their text values
+func parseJSONSettings(data []byte) (map[string]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(raw))
+	for k, v := range raw {
+		var s string
+		if err := json.Unmarshal(v, &s); err == nil {
+			values[k] = s
+			continue
+		}
+		text := strings.TrimSpace(string(v))
+		if strings.HasPrefix(text, "{") || strings.HasPrefix(text, "[") || text == "null" {
+			return nil, fmt.Errorf("%s must be a string, a number or a boolean", k)
+		}
+		values[k] = text
+	}
+	return values, nil
+}
+
+//parseTOML reads the key = value lines of a TOML file into their text values
+func parseTOML(data []byte) (map[string]string, error) {
+	values := make(map[string]string)
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(stripTOMLComment(line))
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			return nil, fmt.Errorf("line %d: tables are not supported, settings must be at the top level", i+1)
+		}
+		eq := strings.Index(line, "=")
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		key := strings.TrimSpace(line[:eq])
+		value := strings.TrimSpace(line[eq+1:])
+		if key == "" || value == "" {
+			return nil, fmt.Errorf("line %d: expected key = value", i+1)
+		}
+		if _, ok := values[key]; ok {
+			return nil, fmt.Errorf("line %d: %s is set twice", i+1, key)
+		}
+		switch {
+		case strings.HasPrefix(value, `"`):
+			s, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: invalid string %s", i+1, value)
+			}
+			value = s
+		case strings.HasPrefix(value, "'"):
+			if len(value) < 2 || !strings.HasSuffix(value, "'") {
+				return nil, fmt.Errorf("line %d: invalid string %s", i+1, value)
+			}
+			value = value[1 : len(value)-1]
+		default:
+			//numbers may group their digits with underscores
+			value = strings.Replace(value, "_", "", -1)
+		}
+		values[key] = value
+	}
+	return values, nil
+}
+
+//stripTOMLComment removes the comment that ends a line, if any
+func stripTOMLComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote == '"' && c == '\\':
+			i++ //an escaped character does not end a basic string
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote == 0 && (c == '"' || c == '\''):
+			quote = c
+		case quote == 0 && c == '#':
+			return line[:i]
+		}
+	}
+	return line
+}
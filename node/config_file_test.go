@@ -0,0 +1,94 @@
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	dir, err := ioutil.TempDir("", "babble_config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfig(t *testing.T) {
+	toml := writeConfigFile(t, "babble.toml", `
+# tunables
+heartbeat_timeout = "50ms"  # faster gossip
+cache_size = 10_000
+store = "badger"
+store_path = '/var/lib/babble/#badger'
+listen_addr = "172.77.5.1:1337"
+`)
+	defer os.RemoveAll(filepath.Dir(toml))
+
+	os.Setenv("BABBLE_SYNC_LIMIT", "200")
+	os.Setenv("BABBLE_CACHE_SIZE", "20000")
+	defer os.Unsetenv("BABBLE_SYNC_LIMIT")
+	defer os.Unsetenv("BABBLE_CACHE_SIZE")
+
+	conf, err := LoadConfig(toml)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.HeartbeatTimeout != 50*time.Millisecond ||
+		conf.StorePath != "/var/lib/babble/#badger" ||
+		conf.ListenAddr != "172.77.5.1:1337" ||
+		conf.TCPTimeout != NewDefaultConfig().TCPTimeout {
+		t.Fatalf("Settings of the file should be loaded, got %+v", conf)
+	}
+	//the environment overrides the file
+	if conf.SyncLimit != 200 || conf.CacheSize != 20000 {
+		t.Fatalf("Environment should override the file, got SyncLimit %d and CacheSize %d",
+			conf.SyncLimit, conf.CacheSize)
+	}
+
+	json := writeConfigFile(t, "babble.json", `{"tcp_timeout": "2s", "consensus_cpu_share": 0.5, "observer": false}`)
+	defer os.RemoveAll(filepath.Dir(json))
+	if conf, err = LoadConfig(json); err != nil {
+		t.Fatal(err)
+	}
+	if conf.TCPTimeout != 2*time.Second || conf.ConsensusCPUShare != 0.5 {
+		t.Fatalf("Settings of the JSON file should be loaded, got %+v", conf)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		file    string
+		content string
+		problem string
+	}{
+		{"unknown setting", "c.json", `{"heartbeat": "1s"}`, "unknown setting heartbeat"},
+		{"bad duration", "c.json", `{"heartbeat_timeout": 50}`, `heartbeat_timeout: invalid duration "50"`},
+		{"bad integer", "c.toml", `cache_size = "many"`, `cache_size: invalid integer "many"`},
+		{"table", "c.toml", "[node]\ncache_size = 1", "line 1: tables are not supported"},
+		{"missing value", "c.toml", "cache_size =", "line 1: expected key = value"},
+		{"invalid config", "c.toml", "sync_limit = 1000", "SyncLimit 1000 exceeds CacheSize 500"},
+	}
+	for _, tc := range cases {
+		path := writeConfigFile(t, tc.file, tc.content)
+		_, err := LoadConfig(path)
+		os.RemoveAll(filepath.Dir(path))
+		if _, ok := err.(ConfigError); !ok || !strings.Contains(err.Error(), tc.problem) {
+			t.Fatalf("%s: LoadConfig should report %q, got %v", tc.name, tc.problem, err)
+		}
+	}
+
+	os.Setenv("BABBLE_FAN_OUT", "two")
+	defer os.Unsetenv("BABBLE_FAN_OUT")
+	if _, err := LoadConfig(""); err == nil || !strings.Contains(err.Error(), "BABBLE_FAN_OUT") {
+		t.Fatalf("LoadConfig should report the bad environment variable, got %v", err)
+	}
+}
@@ -0,0 +1,88 @@
+package node
+
+import (
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	if err := TestConfig(t).Validate(); err != nil {
+		t.Fatalf("Default config should be valid: %s", err)
+	}
+
+	cases := []struct {
+		name     string
+		change   func(*Config)
+		problems int
+	}{
+		{"no logger", func(c *Config) { c.Logger = nil }, 1},
+		{"zero heartbeat", func(c *Config) { c.HeartbeatTimeout = 0 }, 1},
+		{"negative chunk", func(c *Config) { c.InsertChunk = -1 }, 1},
+		{"negative verify workers", func(c *Config) { c.VerifyWorkers = -1 }, 1},
+		{"negative dedup window", func(c *Config) { c.CommitDedupRounds = -1 }, 1},
+		{"negative orphan rounds", func(c *Config) { c.OrphanRounds = -1 }, 1},
+		{"invalid admitted key", func(c *Config) { c.AdmitKeys = []string{"0x04FF"} }, 1},
+		{"cpu share above 1", func(c *Config) { c.ConsensusCPUShare = 1.5 }, 1},
+		{"heartbeat jitter above 1", func(c *Config) { c.HeartbeatJitter = 2 }, 1},
+		{"max heartbeat below heartbeat", func(c *Config) { c.MaxHeartbeat = time.Millisecond }, 1},
+		{"negative background jobs", func(c *Config) { c.MaxBackgroundJobs = -1 }, 1},
+		{"negative duty window", func(c *Config) { c.DutyWindow = -1 }, 1},
+		{"negative pool limits", func(c *Config) {
+			c.MaxPoolTxs = -1
+			c.MaxPoolBytes = -1
+		}, 2},
+		{"budget without cost", func(c *Config) { c.SubmitterBudget = 10 }, 1},
+		{"negative rate limit", func(c *Config) { c.RPCRateLimits.Sync.PerSecond = -1 }, 1},
+		{"history hash without history", func(c *Config) { c.HistoryHash = []byte{1} }, 1},
+		{"clone without observer", func(c *Config) { c.CloneFrom = "127.0.0.1:1337" }, 1},
+		{"negative min free disk", func(c *Config) {
+			c.MinFreeDisk = -1
+			c.DiskPath = "/tmp"
+		}, 1},
+		{"min free disk without path", func(c *Config) { c.MinFreeDisk = 1 }, 1},
+		{"unknown scheme", func(c *Config) { c.SchemeVersion = 99 }, 1},
+		{"unknown hash", func(c *Config) { c.Hash = "md5" }, 1},
+		{"hash with scheme version", func(c *Config) {
+			c.Hash = "keccak-256"
+			c.SchemeVersion = 2
+		}, 1},
+		{"min scheme above active", func(c *Config) { c.MinSchemeVersion = 1 }, 1},
+		{"affinity without zone", func(c *Config) { c.ZoneAffinity = 0.5 }, 1},
+		{"affinity of 1", func(c *Config) {
+			c.Zone = "eu"
+			c.ZoneAffinity = 1
+		}, 1},
+		{"sync larger than cache", func(c *Config) { c.SyncLimit = c.CacheSize + 1 }, 1},
+		{"payload larger than sync", func(c *Config) { c.MaxEventPayload = c.SyncBytesLimit + 1 }, 1},
+		{"unlimited payload", func(c *Config) { c.MaxEventPayload = 0 }, 0},
+		{"stall shorter than gossip", func(c *Config) { c.TCPTimeout = 10 * time.Second }, 1},
+		{"stall monitor disabled", func(c *Config) {
+			c.TCPTimeout = 10 * time.Second
+			c.StallTimeout = 0
+		}, 0},
+		{"badger without path", func(c *Config) { c.Store = "badger" }, 1},
+		{"unknown store", func(c *Config) { c.Store = "unknown" }, 1},
+		{"all problems", func(c *Config) {
+			c.CacheSize = 0
+			c.Store = "unknown"
+		}, 3},
+	}
+	for _, tc := range cases {
+		conf := TestConfig(t)
+		tc.change(conf)
+		err := conf.Validate()
+		if tc.problems == 0 {
+			if err != nil {
+				t.Fatalf("%s: config should be valid: %s", tc.name, err)
+			}
+			continue
+		}
+		errs, ok := err.(ConfigError)
+		if !ok {
+			t.Fatalf("%s: Validate should return a ConfigError, not %v", tc.name, err)
+		}
+		if len(errs) != tc.problems {
+			t.Fatalf("%s: there should be %d problems, not %d: %s", tc.name, tc.problems, len(errs), err)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package node
+
+import (
+	"sync"
+	"time"
+
+	"github.com/babbleio/babble/net"
+)
+
+/*
+Every node measures its links with its peers: when it last reached them, when
+they last reached it, how long requests take and how many failed in a row.
+Comparing the rows of two nodes shows asymmetric problems, like a peer that can
+send requests to a node but not receive any from it.
+
+With Config.ShareConnectivity, nodes attach their row to SyncRequests and
+SyncResponses, so that each node eventually holds the rows of all the peers it
+gossips with and can report the connectivity matrix of the whole cluster.
+
+Received rows are kept by the public key of the peer that sent them, which the
+node looks up in its peer set from the address of the request or of the peer it
+pulled from. Rows from addresses that are not peers are dropped, so there are
+never more rows than peers, and the row of a peer is deleted when it leaves or
+is evicted.
+*/
+
+//weight of a new sample in the smoothed RTT, as in TCP
+const rttAlpha = 0.125
+
+//Connectivity is the connectivity matrix known by a node
+type Connectivity struct {
+	Local   net.ConnectivityRow
+	Cluster map[string]net.ConnectivityRow `json:",omitempty"` //[net addr] => row of that node
+}
+
+type connectivity struct {
+	l        sync.Mutex
+	local    net.ConnectivityRow
+	received map[string]receivedRow //[pub key] => row of that peer
+	now      func() time.Time
+}
+
+type receivedRow struct {
+	addr string
+	row  net.ConnectivityRow
+}
+
+func newConnectivity() *connectivity {
+	return &connectivity{
+		local:    make(net.ConnectivityRow),
+		received: make(map[string]receivedRow),
+		now:      time.Now,
+	}
+}
+
+//outbound records the result of a request sent to peer
+func (c *connectivity) outbound(peer string, rtt time.Duration, err error) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	link := c.local[peer]
+	if err != nil {
+		link.Failures++
+		c.local[peer] = link
+		return
+	}
+	link.Failures = 0
+	link.LastOutbound = c.now().UTC()
+	if link.RTT == 0 {
+		link.RTT = rtt
+	} else {
+		link.RTT = time.Duration((1-rttAlpha)*float64(link.RTT) + rttAlpha*float64(rtt))
+	}
+	c.local[peer] = link
+}
+
+//inbound records a request received from peer
+func (c *connectivity) inbound(peer string) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	link := c.local[peer]
+	link.LastInbound = c.now().UTC()
+	c.local[peer] = link
+}
+
+//receive stores the row shared by peer, replacing the previous one
+func (c *connectivity) receive(peer net.Peer, row net.ConnectivityRow) {
+	if row == nil {
+		return
+	}
+	c.l.Lock()
+	defer c.l.Unlock()
+	c.received[peer.PubKeyHex] = receivedRow{addr: peer.NetAddr, row: row}
+}
+
+//forget deletes the row of a peer that left, and the link with it
+func (c *connectivity) forget(peer net.Peer) {
+	c.l.Lock()
+	defer c.l.Unlock()
+	delete(c.received, peer.PubKeyHex)
+	delete(c.local, peer.NetAddr)
+}
+
+func (c *connectivity) row() net.ConnectivityRow {
+	c.l.Lock()
+	defer c.l.Unlock()
+	return copyRow(c.local)
+}
+
+func copyRow(row net.ConnectivityRow) net.ConnectivityRow {
+	res := make(net.ConnectivityRow, len(row))
+	for k, v := range row {
+		res[k] = v
+	}
+	return res
+}
+
+//sharedRow returns the row to attach to sync messages, if any
+func (n *Node) sharedRow() net.ConnectivityRow {
+	if !n.conf.ShareConnectivity {
+		return nil
+	}
+	return n.connectivity.row()
+}
+
+//receiveConnectivity stores the row shared by the peer at addr, unless addr is
+//not the address of a peer
+func (n *Node) receiveConnectivity(addr string, row net.ConnectivityRow) {
+	if row == nil {
+		return
+	}
+	peer, ok := n.peerAt(addr)
+	if !ok {
+		return
+	}
+	n.connectivity.receive(peer, row)
+}
+
+//Connectivity returns the links of this node and, when connectivity is shared,
+//the rows received from the other nodes
+func (n *Node) Connectivity() Connectivity {
+	res := Connectivity{
+		Local: n.connectivity.row(),
+	}
+	if !n.conf.ShareConnectivity {
+		return res
+	}
+
+	n.connectivity.l.Lock()
+	defer n.connectivity.l.Unlock()
+	res.Cluster = make(map[string]net.ConnectivityRow)
+	for _, r := range n.connectivity.received {
+		res.Cluster[r.addr] = copyRow(r.row)
+	}
+	res.Cluster[n.localAddr] = res.Local
+	return res
+}
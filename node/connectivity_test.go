@@ -0,0 +1,106 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestConnectivityLinks(t *testing.T) {
+	c := newConnectivity()
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.now = func() time.Time { return now }
+
+	c.outbound("A", 80*time.Millisecond, nil)
+	c.outbound("A", 160*time.Millisecond, nil)
+	c.inbound("B")
+	c.outbound("B", 0, errors.New("timeout"))
+	c.outbound("B", 0, errors.New("timeout"))
+
+	row := c.row()
+	a, b := row["A"], row["B"]
+	if a.RTT != 90*time.Millisecond {
+		t.Fatalf("Smoothed RTT of A should be 90ms, not %s", a.RTT)
+	}
+	if !a.Reachable() || a.LastOutbound != now || !a.LastInbound.IsZero() {
+		t.Fatalf("A should be reachable but never seen inbound, got %#v", a)
+	}
+	if b.Reachable() || b.Failures != 2 || b.LastInbound != now {
+		t.Fatalf("B should reach us but be unreachable, got %#v", b)
+	}
+
+	c.outbound("B", 10*time.Millisecond, nil)
+	if b := c.row()["B"]; !b.Reachable() || b.Failures != 0 {
+		t.Fatalf("B should be reachable again, got %#v", b)
+	}
+}
+
+func TestSharedConnectivity(t *testing.T) {
+	keys, peers := initPeers(2)
+	testLogger := common.NewTestLogger(t)
+
+	nodes := []*Node{}
+	for i := range peers {
+		trans, err := net.NewTCPTransport(peers[i].NetAddr, nil, 2, time.Second, nil, testLogger)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		conf := TestConfig(t)
+		conf.ShareConnectivity = true
+		node := NewNode(conf, keys[i], peers, trans, aproxy.NewInmemAppProxy(testLogger))
+		node.Init()
+		node.RunAsync(false)
+		nodes = append(nodes, &node)
+	}
+	defer shutdownNodes(nodes)
+
+	//node0 pulls from node1 twice so that node1's row mentions node0
+	for i := 0; i < 2; i++ {
+		if _, _, err := nodes[0].pull(context.Background(), nodes[1].localAddr); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c0 := nodes[0].Connectivity()
+	if link := c0.Local[nodes[1].localAddr]; !link.Reachable() || link.RTT <= 0 {
+		t.Fatalf("node0 should have reached node1, got %#v", link)
+	}
+	row1, ok := c0.Cluster[nodes[1].localAddr]
+	if !ok {
+		t.Fatal("node0 should have received the row of node1")
+	}
+	if row1[nodes[0].localAddr].LastInbound.IsZero() {
+		t.Fatal("node1's row should show requests from node0")
+	}
+
+	c1 := nodes[1].Connectivity()
+	if link := c1.Local[nodes[0].localAddr]; link.Reachable() || link.LastInbound.IsZero() {
+		t.Fatalf("node1 should have only been reached by node0, got %#v", link)
+	}
+	if _, ok := c1.Cluster[nodes[0].localAddr]; !ok {
+		t.Fatal("node1 should have received the row of node0")
+	}
+
+	//rows from addresses that are not peers are dropped
+	nodes[0].receiveConnectivity("127.0.0.1:1", net.ConnectivityRow{"x": {}})
+	if l := len(nodes[0].Connectivity().Cluster); l != 2 {
+		t.Fatalf("node0 should only hold the rows of itself and node1, not %d", l)
+	}
+
+	//the row of a peer that left is deleted
+	peer1, _ := nodes[0].peerAt(nodes[1].localAddr)
+	nodes[0].commitPeerLeave(hg.PeerLeave{PubKey: peer1.PubKeyHex})
+	c0 = nodes[0].Connectivity()
+	if _, ok := c0.Cluster[nodes[1].localAddr]; ok {
+		t.Fatal("node0 should have deleted the row of node1")
+	}
+	if _, ok := c0.Local[nodes[1].localAddr]; ok {
+		t.Fatal("node0 should have deleted its link with node1")
+	}
+}
@@ -2,18 +2,23 @@ package node
 
 import (
 	"math/rand"
+	"sync/atomic"
 	"time"
 )
 
 type timerFactory func() <-chan time.Time
 
 type ControlTimer struct {
+	base int64 //delay of a NewRandomControlTimer set by SetBase, atomic. First for its alignment
+
 	timerFactory timerFactory
 	tickCh       chan struct{} //sends a signal to listening process
 	resetCh      chan struct{} //receives instruction to reset the heartbeatTimer
 	stopCh       chan struct{} //receives instruction to stop the heartbeatTimer
 	shutdownCh   chan struct{} //receives instruction to exit Run loop
 	set          bool
+
+	backoff *heartbeatBackoff //stretches the random delays while the node is idle
 }
 
 func NewControlTimer(timerFactory timerFactory) *ControlTimer {
@@ -26,16 +31,44 @@ func NewControlTimer(timerFactory timerFactory) *ControlTimer {
 	}
 }
 
-func NewRandomControlTimer(base time.Duration) *ControlTimer {
-	randomTimeout := func() <-chan time.Time {
-		minVal := base
-		if minVal == 0 {
+//NewRandomControlTimer ticks after base plus a random share of base, up to
+//jitter, so that nodes configured with the same heartbeat do not gossip at the
+//same time. The delays are drawn from seed.
+func NewRandomControlTimer(base time.Duration, jitter float64, seed int64) *ControlTimer {
+	next := randomDelays(base, jitter, seed)
+	c := NewControlTimer(nil)
+	c.base = int64(base)
+	c.timerFactory = func() <-chan time.Time {
+		current := time.Duration(atomic.LoadInt64(&c.base))
+		if base == 0 || current == 0 {
 			return nil
 		}
-		extra := (time.Duration(rand.Int63()) % minVal)
-		return time.After(minVal + extra)
+		//the delays are drawn for base and scaled to the current one
+		delay := time.Duration(float64(next()) * float64(current) / float64(base))
+		return time.After(c.backoff.stretch(delay))
+	}
+	return c
+}
+
+//SetBase changes the base delay of a NewRandomControlTimer from the next tick on
+func (c *ControlTimer) SetBase(base time.Duration) {
+	atomic.StoreInt64(&c.base, int64(base))
+}
+
+//randomDelays returns the delays of NewRandomControlTimer. The first one is a
+//random share of a whole delay, which spreads the phases of nodes that start
+//at the same time, for example after a deployment.
+func randomDelays(base time.Duration, jitter float64, seed int64) func() time.Duration {
+	rnd := rand.New(rand.NewSource(seed))
+	first := true
+	return func() time.Duration {
+		delay := base + time.Duration(jitter*rnd.Float64()*float64(base))
+		if first {
+			first = false
+			delay = time.Duration(rnd.Float64() * float64(delay))
+		}
+		return delay
 	}
-	return NewControlTimer(randomTimeout)
 }
 
 func (c *ControlTimer) Run() {
@@ -49,7 +82,12 @@ func (c *ControlTimer) Run() {
 	for {
 		select {
 		case <-timer:
-			c.tickCh <- struct{}{}
+			select {
+			case c.tickCh <- struct{}{}:
+			case <-c.shutdownCh:
+				c.set = false
+				return
+			}
 			c.set = false
 		case <-c.resetCh:
 			timer = setTimer()
@@ -63,6 +101,23 @@ func (c *ControlTimer) Run() {
 	}
 }
 
+//Reset restarts the timer. It does not block once the timer is shut down.
+func (c *ControlTimer) Reset() {
+	select {
+	case c.resetCh <- struct{}{}:
+	case <-c.shutdownCh:
+	}
+}
+
+//Stop stops the timer until the next Reset. It does not block once the timer is
+//shut down.
+func (c *ControlTimer) Stop() {
+	select {
+	case c.stopCh <- struct{}{}:
+	case <-c.shutdownCh:
+	}
+}
+
 func (c *ControlTimer) Shutdown() {
 	close(c.shutdownCh)
 }
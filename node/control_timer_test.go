@@ -0,0 +1,32 @@
+package node
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRandomDelays(t *testing.T) {
+	base := 100 * time.Millisecond
+	next := randomDelays(base, 0.2, 1)
+
+	if first := next(); first < 0 || first >= 120*time.Millisecond {
+		t.Fatalf("The first delay should be less than 120ms, not %s", first)
+	}
+	for i := 0; i < 100; i++ {
+		if d := next(); d < base || d > 120*time.Millisecond {
+			t.Fatalf("Delay %d should be between 100ms and 120ms, not %s", i, d)
+		}
+	}
+
+	//nodes with different seeds start out of phase
+	if randomDelays(base, 0.2, 1)() == randomDelays(base, 0.2, 2)() {
+		t.Fatal("Different seeds should give different first delays")
+	}
+
+	//no jitter, but a random phase
+	next = randomDelays(base, 0, 1)
+	next()
+	if d := next(); d != base {
+		t.Fatalf("Delays without jitter should be 100ms, not %s", d)
+	}
+}
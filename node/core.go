@@ -1,37 +1,55 @@
 package node
 
 import (
-	"crypto/ecdsa"
+	"bytes"
+	"errors"
 	"fmt"
+	"runtime"
 	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/Sirupsen/logrus"
 
+	"github.com/babbleio/babble/common"
 	"github.com/babbleio/babble/crypto"
 	hg "github.com/babbleio/babble/hashgraph"
 )
 
+//errStaleSync is returned by Sync when all the Events of the batch were inserted
+//since the peer computed it. No new head is created on top of a stale view of
+//the peer.
+var errStaleSync = errors.New("Sync batch is stale")
+
 type Core struct {
 	id     int
-	key    *ecdsa.PrivateKey
+	key    crypto.KeyPair
 	pubKey []byte
 	hexID  string
 	hg     hg.Hashgraph
 
-	participants        map[string]int //[PubKey] => id
-	reverseParticipants map[int]string //[id] => PubKey
-	Head                string
-	Seq                 int
+	participants map[string]int //[PubKey] => id
+	Head         string
+	Seq          int
+	published    int64 //index of the last self-event handed to peers, accessed atomically
 
 	transactionPool [][]byte
+	poolBytes       int //bytes of the transactions in transactionPool
+	maxEventPayload int //max bytes of transactions per Event. 0 means no limit
+
+	eventPolicy   EventCreationPolicy
+	schemeVersion int       //version of the scheme self-events are signed with
+	lastEventTime time.Time //time of the last self-event
+
+	concurrentSyncs bool //the batches passed to Sync may overlap
+	verifyWorkers   int  //goroutines checking the signatures of a batch
 
 	logger *logrus.Logger
 }
 
 func NewCore(
 	id int,
-	key *ecdsa.PrivateKey,
+	key crypto.KeyPair,
 	participants map[string]int,
 	store hg.Store,
 	commitCh chan []hg.Event,
@@ -41,19 +59,19 @@ func NewCore(
 		logger.Level = logrus.DebugLevel
 	}
 
-	reverseParticipants := make(map[int]string)
-	for pk, id := range participants {
-		reverseParticipants[id] = pk
-	}
-
+	//the keys are computed once so that read-only callers can share the Core
+	pubKey := key.PublicKey()
 	core := Core{
-		id:                  id,
-		key:                 key,
-		hg:                  hg.NewHashgraph(participants, store, commitCh, logger),
-		participants:        participants,
-		reverseParticipants: reverseParticipants,
-		transactionPool:     [][]byte{},
-		logger:              logger,
+		id:              id,
+		key:             key,
+		pubKey:          pubKey,
+		hexID:           fmt.Sprintf("0x%X", pubKey),
+		hg:              hg.NewHashgraph(participants, store, commitCh, logger),
+		participants:    participants,
+		transactionPool: [][]byte{},
+		eventPolicy:     EverySyncPolicy{},
+		published:       -1,
+		logger:          logger,
 	}
 	return core
 }
@@ -63,17 +81,10 @@ func (c *Core) ID() int {
 }
 
 func (c *Core) PubKey() []byte {
-	if c.pubKey == nil {
-		c.pubKey = crypto.FromECDSAPub(&c.key.PublicKey)
-	}
 	return c.pubKey
 }
 
 func (c *Core) HexID() string {
-	if c.hexID == "" {
-		pubKey := c.PubKey()
-		c.hexID = fmt.Sprintf("0x%X", pubKey)
-	}
 	return c.hexID
 }
 
@@ -86,6 +97,7 @@ func (c *Core) Init() error {
 }
 
 func (c *Core) SignAndInsertSelfEvent(event hg.Event) error {
+	event.Body.Version = c.schemeVersion
 	if err := event.Sign(c.key); err != nil {
 		return err
 	}
@@ -102,15 +114,45 @@ func (c *Core) InsertEvent(event hg.Event, setWireInfo bool) error {
 	if event.Creator() == c.HexID() {
 		c.Head = event.Hex()
 		c.Seq = event.Index()
+		c.lastEventTime = time.Now()
 	}
 	return nil
 }
 
+//SetEventCreationPolicy sets the policy deciding when to create self-events
+func (c *Core) SetEventCreationPolicy(policy EventCreationPolicy) {
+	c.eventPolicy = policy
+}
+
+func (c *Core) shouldCreateEvent(trigger EventTrigger, otherEvents int) bool {
+	return c.eventPolicy.ShouldCreateEvent(EventContext{
+		Trigger:     trigger,
+		OtherEvents: otherEvents,
+		PendingTxs:  len(c.transactionPool),
+		SinceLast:   time.Since(c.lastEventTime),
+	})
+}
+
 func (c *Core) Known() map[int]int {
 	return c.hg.Known()
 }
 
+func (c *Core) KnownRanges() hg.KnownRanges {
+	return c.hg.KnownRanges()
+}
+
+//Missing returns the ids of the participants whose Events, after those in
+//known, are no longer held by c. A peer with that Known cannot be synced by c.
+func (c *Core) Missing(known map[int]int) []int {
+	return c.KnownRanges().Missing(c.Known(), known)
+}
+
+//OverSyncLimit is true if a node with known misses more than syncLimit Events.
+//A syncLimit of 0 means no limit.
 func (c *Core) OverSyncLimit(known map[int]int, syncLimit int) bool {
+	if syncLimit <= 0 {
+		return false
+	}
 	totUnknown := 0
 	myKnown := c.Known()
 	for i, li := range myKnown {
@@ -130,16 +172,30 @@ func (c *Core) GetFrame() (hg.Frame, error) {
 
 //returns events that c knowns about that are not in 'known'
 func (c *Core) Diff(known map[int]int) (events []hg.Event, err error) {
+	return c.DiffLimit(known, 0)
+}
+
+//DiffLimit is Diff cut to its first limit Events, 0 for no limit. They can be
+//inserted on their own, and each participant has at most limit Events among
+//them, so only those are read from the store.
+func (c *Core) DiffLimit(known map[int]int, limit int) (events []hg.Event, err error) {
 	unknown := []hg.Event{}
 	//known represents the number of events known for every participant
 	//compare this to our view of events and fill unknown with events that we know of
 	// and the other doesnt
 	for id, ct := range known {
-		pk := c.reverseParticipants[id]
+		pk, ok := c.hg.ReverseParticipants[id]
+		if !ok {
+			//a participant that joined after our last consensus Events
+			continue
+		}
 		participantEvents, err := c.hg.Store.ParticipantEvents(pk, ct)
 		if err != nil {
 			return []hg.Event{}, err
 		}
+		if limit > 0 && len(participantEvents) > limit {
+			participantEvents = participantEvents[:limit]
+		}
 		for _, e := range participantEvents {
 			ev, err := c.hg.Store.GetEvent(e)
 			if err != nil {
@@ -149,10 +205,17 @@ func (c *Core) Diff(known map[int]int) (events []hg.Event, err error) {
 		}
 	}
 	sort.Sort(hg.ByTopologicalOrder(unknown))
+	if limit > 0 && len(unknown) > limit {
+		unknown = unknown[:limit]
+	}
 
 	return unknown, nil
 }
 
+//Sync inserts the Events received from a peer and creates a new head on top of
+//the last one. The Events inserted since the peer computed the diff, by another
+//sync or by an EagerSync, are skipped. If they all were, no head is created and
+//Sync returns errStaleSync, or nil with concurrent syncs.
 func (c *Core) Sync(unknown []hg.WireEvent) error {
 
 	c.logger.WithFields(logrus.Fields{
@@ -160,26 +223,48 @@ func (c *Core) Sync(unknown []hg.WireEvent) error {
 		"txPool":  len(c.transactionPool),
 	}).Debug("Sync")
 
+	known := c.Known()
+	batch := make([]hg.WireEvent, 0, len(unknown))
 	otherHead := ""
-	//add unknown events
+	stale := false
+	//skip the events inserted since the peer computed the batch
 	for k, we := range unknown {
-		ev, err := c.hg.ReadWireInfo(we)
-		if err != nil {
-			return err
-		}
-		if err := c.InsertEvent(*ev, false); err != nil {
-			return err
-		}
-		//assume last event corresponds to other-head
-		if k == len(unknown)-1 {
-			otherHead = ev.Hex()
+		if last, ok := known[we.Body.CreatorID]; ok && we.Body.Index <= last {
+			stale = true
+			if k == len(unknown)-1 {
+				pk := c.hg.ReverseParticipants[we.Body.CreatorID]
+				hash, err := c.hg.Store.ParticipantEvent(pk, we.Body.Index)
+				if err != nil {
+					return err
+				}
+				otherHead = hash
+			}
+			continue
 		}
+		batch = append(batch, we)
+	}
+
+	//add unknown events
+	inserted, err := c.insertBatch(batch)
+	if err != nil {
+		return err
+	}
+	//assume last event corresponds to other-head
+	if otherHead == "" && len(inserted) > 0 {
+		otherHead = inserted[len(inserted)-1].Hex()
+	}
+
+	if stale && !c.concurrentSyncs && len(inserted) == 0 {
+		return errStaleSync
+	}
+	if len(unknown) > 0 && len(inserted) == 0 {
+		return nil
 	}
 
 	//create new event with self head and other head
-	//only if there are pending loaded events or the transaction pool is not empty
-	if len(unknown) > 0 || len(c.transactionPool) > 0 {
-		newHead := hg.NewEvent(c.transactionPool,
+	//only if the event creation policy says so
+	if c.shouldCreateEvent(SyncTrigger, len(inserted)) {
+		newHead := hg.NewEvent(c.nextPayload(),
 			[]string{c.Head, otherHead},
 			c.PubKey(),
 			c.Seq+1)
@@ -187,27 +272,181 @@ func (c *Core) Sync(unknown []hg.WireEvent) error {
 		if err := c.SignAndInsertSelfEvent(newHead); err != nil {
 			return fmt.Errorf("Error inserting new head: %s", err)
 		}
-
-		//empty the transaction pool
-		c.transactionPool = [][]byte{}
 	}
 
 	return nil
 }
 
+//Backfill inserts a chunk of the Events received from a peer, in topological
+//order, without creating a new head. Events that another sync inserted since
+//the peer computed the batch are skipped.
+func (c *Core) Backfill(unknown []hg.WireEvent) error {
+	c.logger.WithField("unknown", len(unknown)).Debug("Backfill")
+
+	known := c.Known()
+	batch := make([]hg.WireEvent, 0, len(unknown))
+	for _, we := range unknown {
+		if k, ok := known[we.Body.CreatorID]; ok && we.Body.Index <= k {
+			continue
+		}
+		batch = append(batch, we)
+	}
+	_, err := c.insertBatch(batch)
+	return err
+}
+
+//insertBatch inserts Events received from a peer, in topological order, after
+//checking their signatures in parallel. It returns the Events inserted before
+//an error.
+func (c *Core) insertBatch(batch []hg.WireEvent) ([]*hg.Event, error) {
+	events, readErr := c.hg.ReadWireEvents(batch)
+	hg.VerifyEvents(events, c.verifyWorkers)
+	for i, ev := range events {
+		if err := c.InsertEvent(*ev, false); err != nil {
+			return events[:i], err
+		}
+	}
+	return events, readErr
+}
+
+//SplitOrphans separates the Events whose parents are neither in the hashgraph
+//nor before them in events, and the Events that descend from them, from the
+//Events that can be inserted. The order of events is kept in both.
+func (c *Core) SplitOrphans(events []hg.WireEvent) (ready []hg.WireEvent, orphans []hg.WireEvent) {
+	known := c.Known()
+	isKnown := func(creatorID, index int) bool {
+		last, ok := known[creatorID]
+		return index < 0 || (ok && index <= last)
+	}
+	for _, we := range events {
+		if isKnown(we.Body.CreatorID, we.Body.SelfParentIndex) &&
+			isKnown(we.Body.OtherParentCreatorID, we.Body.OtherParentIndex) {
+			ready = append(ready, we)
+			if last, ok := known[we.Body.CreatorID]; !ok || we.Body.Index > last {
+				known[we.Body.CreatorID] = we.Body.Index
+			}
+		} else {
+			orphans = append(orphans, we)
+		}
+	}
+	return ready, orphans
+}
+
+//MissingParents returns the parents of orphans that are neither in the
+//hashgraph nor among the orphans themselves
+func (c *Core) MissingParents(orphans []hg.WireEvent) []hg.WireRef {
+	known := c.Known()
+	held := make(map[hg.WireRef]bool, len(orphans))
+	for _, we := range orphans {
+		held[hg.WireRef{CreatorID: we.Body.CreatorID, Index: we.Body.Index}] = true
+	}
+	missing := []hg.WireRef{}
+	for _, we := range orphans {
+		for _, ref := range []hg.WireRef{
+			{CreatorID: we.Body.CreatorID, Index: we.Body.SelfParentIndex},
+			{CreatorID: we.Body.OtherParentCreatorID, Index: we.Body.OtherParentIndex},
+		} {
+			if last, ok := known[ref.CreatorID]; ref.Index < 0 || (ok && ref.Index <= last) || held[ref] {
+				continue
+			}
+			held[ref] = true
+			missing = append(missing, ref)
+		}
+	}
+	return missing
+}
+
+//Fetch returns the Events with the given hashes or references, and their
+//ancestors that a peer with Known known does not have, in topological order.
+//Beyond limit Events (0 for no limit), the latest ones are left out, so that
+//the result can still be inserted. Events that are not in the store, because
+//they were never received or were pruned, are left out too.
+func (c *Core) Fetch(hashes []string, refs []hg.WireRef, known map[int]int, limit int) []hg.Event {
+	for _, ref := range refs {
+		pk, ok := c.hg.ReverseParticipants[ref.CreatorID]
+		if !ok {
+			continue
+		}
+		hash, err := c.hg.Store.ParticipantEvent(pk, ref.Index)
+		if err != nil {
+			continue
+		}
+		hashes = append(hashes, hash)
+	}
+
+	events := []hg.Event{}
+	visited := make(map[string]bool)
+	for len(hashes) > 0 {
+		hash := hashes[len(hashes)-1]
+		hashes = hashes[:len(hashes)-1]
+		if hash == "" || visited[hash] {
+			continue
+		}
+		visited[hash] = true
+
+		ev, err := c.hg.Store.GetEvent(hash)
+		if err != nil {
+			continue
+		}
+		id, ok := c.hg.Participants[ev.Creator()]
+		if !ok {
+			continue
+		}
+		if last, ok := known[id]; ok && ev.Index() <= last {
+			continue
+		}
+		events = append(events, ev)
+		hashes = append(hashes, ev.Body.Parents...)
+	}
+	sort.Sort(hg.ByTopologicalOrder(events))
+
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+	return events
+}
+
+//FastForward resets the hashgraph to a Frame received from a peer. It fails if
+//this node handed Events of its own beyond the Frame to its peers: the new head
+//would be on top of an older one and fork its sequence of Events. The Frames of
+//the peers catch up with them as consensus goes on. The Events that never left
+//the node are dropped.
 func (c *Core) FastForward(frame hg.Frame) error {
+	published := int(atomic.LoadInt64(&c.published))
+	if seq := c.frameSeq(frame); published > seq {
+		return fmt.Errorf("Event %d was sent to peers but the Frame stops at %d", published, seq)
+	}
+
+	//participants may have joined since this node last saw the hashgraph
+	if len(frame.Participants) > 0 {
+		if err := c.hg.SetParticipants(frame.Participants); err != nil {
+			return err
+		}
+		id, ok := c.hg.Participants[c.HexID()]
+		switch {
+		case ok:
+			c.id = id
+		case !c.observer():
+			return fmt.Errorf("Not a participant of the Frame")
+		}
+	}
+
+	c.hg.SetProposals(frame.Proposals)
+
 	err := c.hg.Reset(frame.Roots)
 	if err != nil {
 		return err
 	}
 
+	//an Observer has no Root
 	myRoot, ok := frame.Roots[c.HexID()]
-	if !ok {
+	if !ok && !c.observer() {
 		return fmt.Errorf("No Root for self")
 	}
-
-	c.Head = myRoot.X
-	c.Seq = myRoot.Index
+	if ok {
+		c.Head = myRoot.X
+		c.Seq = myRoot.Index
+	}
 
 	otherHead := ""
 	//add unknown events
@@ -222,9 +461,9 @@ func (c *Core) FastForward(frame hg.Frame) error {
 	}
 
 	//create new event with self head and other head
-	//only if there are pending loaded events or the transaction pool is not empty
-	if len(frame.Events) > 0 || len(c.transactionPool) > 0 {
-		newHead := hg.NewEvent(c.transactionPool,
+	//only if the event creation policy says so
+	if c.shouldCreateEvent(SyncTrigger, len(frame.Events)) {
+		newHead := hg.NewEvent(c.nextPayload(),
 			[]string{c.Head, otherHead},
 			c.PubKey(),
 			c.Seq+1)
@@ -232,9 +471,6 @@ func (c *Core) FastForward(frame hg.Frame) error {
 		if err := c.SignAndInsertSelfEvent(newHead); err != nil {
 			return fmt.Errorf("Error inserting new head: %s", err)
 		}
-
-		//empty the transaction pool
-		c.transactionPool = [][]byte{}
 	}
 
 	err = c.RunConsensus()
@@ -245,15 +481,89 @@ func (c *Core) FastForward(frame hg.Frame) error {
 	return nil
 }
 
+//Bootstrap rebuilds the hashgraph from a persistent store and continues the
+//node's own sequence of Events from the last one stored. A store without any
+//Event from this node is initialized like a new one. It returns the Events
+//that reached consensus during the replay and whether the store was new.
+//frameSeq returns the index of the last Event of this node in a Frame, -1 if
+//there is none
+func (c *Core) frameSeq(frame hg.Frame) int {
+	seq := -1
+	if root, ok := frame.Roots[c.HexID()]; ok {
+		seq = root.Index
+	}
+	for _, ev := range frame.Events {
+		if ev.Creator() == c.HexID() && ev.Index() > seq {
+			seq = ev.Index()
+		}
+	}
+	return seq
+}
+
+func (c *Core) Bootstrap() ([]hg.Event, bool, error) {
+	committed, err := c.hg.Bootstrap()
+	if err != nil {
+		return nil, false, err
+	}
+
+	//participants may have joined before the restart
+	if id, ok := c.hg.Participants[c.HexID()]; ok {
+		c.id = id
+	}
+
+	//an Observer has no Events of its own, its store is fresh until it holds
+	//some of the participants'
+	if c.observer() {
+		fresh := true
+		for _, index := range c.Known() {
+			if index >= 0 {
+				fresh = false
+			}
+		}
+		return committed, fresh, nil
+	}
+
+	last, isRoot, err := c.hg.Store.LastFrom(c.HexID())
+	if err != nil {
+		return nil, false, err
+	}
+	if last == "" {
+		return committed, true, c.Init()
+	}
+	c.Head = last
+	if isRoot {
+		root, err := c.hg.Store.GetRoot(c.HexID())
+		if err != nil {
+			return nil, false, err
+		}
+		c.Seq = root.Index
+	} else {
+		head, err := c.hg.Store.GetEvent(last)
+		if err != nil {
+			return nil, false, err
+		}
+		c.Seq = head.Index()
+	}
+	//the previous process may have sent all its Events
+	c.publish(c.Seq)
+	return committed, false, nil
+}
+
 func (c *Core) AddSelfEvent() error {
 	if len(c.transactionPool) == 0 {
 		c.logger.Debug("Empty TxPool")
 		return nil
 	}
 
+	if !c.shouldCreateEvent(SelfTrigger, 0) {
+		c.logger.Debug("Event creation deferred by policy")
+		return nil
+	}
+
 	//create new event with self head and empty other parent
 	//empty transaction pool in its payload
-	newHead := hg.NewEvent(c.transactionPool,
+	payload := c.nextPayload()
+	newHead := hg.NewEvent(payload,
 		[]string{c.Head, ""},
 		c.PubKey(), c.Seq+1)
 
@@ -262,11 +572,10 @@ func (c *Core) AddSelfEvent() error {
 	}
 
 	c.logger.WithFields(logrus.Fields{
-		"transactions": len(c.transactionPool),
+		"transactions": len(payload),
+		"remaining":    len(c.transactionPool),
 	}).Debug("Created Self-Event")
 
-	c.transactionPool = [][]byte{}
-
 	return nil
 }
 
@@ -282,14 +591,30 @@ func (c *Core) FromWire(wireEvents []hg.WireEvent) ([]hg.Event, error) {
 	return events, nil
 }
 
+//ToWire converts Events to send them to a peer. It keeps track of the last
+//self-event sent, for FastForward.
 func (c *Core) ToWire(events []hg.Event) ([]hg.WireEvent, error) {
 	wireEvents := make([]hg.WireEvent, len(events), len(events))
 	for i, e := range events {
 		wireEvents[i] = e.ToWire()
+		if bytes.Equal(e.Body.Creator, c.pubKey) {
+			c.publish(e.Index())
+		}
 	}
 	return wireEvents, nil
 }
 
+//publish notes that the self-event of the given index was sent to a peer. It is
+//called by the readers of the Core, concurrently.
+func (c *Core) publish(index int) {
+	for {
+		last := atomic.LoadInt64(&c.published)
+		if int64(index) <= last || atomic.CompareAndSwapInt64(&c.published, last, int64(index)) {
+			return
+		}
+	}
+}
+
 func (c *Core) RunConsensus() error {
 	start := time.Now()
 	err := c.hg.DivideRounds()
@@ -318,8 +643,138 @@ func (c *Core) RunConsensus() error {
 	return nil
 }
 
-func (c *Core) AddTransactions(txs [][]byte) {
-	c.transactionPool = append(c.transactionPool, txs...)
+//SetMaxEventPayload sets the maximum number of transaction bytes packed in a
+//single Event. Transactions larger than that are chunked. 0 means no limit.
+func (c *Core) SetMaxEventPayload(max int) {
+	c.maxEventPayload = max
+}
+
+//SetConcurrentSyncs tells Sync whether the batches it receives may overlap,
+//because several syncs of a gossip round run at the same time. Sync then
+//returns nil for a batch that another sync inserted, instead of errStaleSync.
+func (c *Core) SetConcurrentSyncs(concurrent bool) {
+	c.concurrentSyncs = concurrent
+}
+
+//SetVerifyWorkers sets the number of goroutines that check the signatures of
+//the Events of a sync before they are inserted. 0 means one per CPU.
+func (c *Core) SetVerifyWorkers(workers int) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	c.verifyWorkers = workers
+}
+
+//SetSchemeWindow sets the versions of the Events accepted from other nodes.
+//Self-events are signed with the Active one.
+func (c *Core) SetSchemeWindow(w hg.SchemeWindow) {
+	c.schemeVersion = w.Active
+	c.hg.SetSchemeWindow(w)
+}
+
+//ResetCaches drops the memoized results of the consensus methods
+func (c *Core) ResetCaches() {
+	c.hg.ResetCaches()
+}
+
+func (c *Core) CacheStats() map[string]common.LRUStats {
+	return c.hg.CacheStats()
+}
+
+//ResetUndecidedRounds makes the next consensus run vote again on the fame of
+//every witness after the last decided round
+func (c *Core) ResetUndecidedRounds() {
+	c.hg.ResetUndecidedRounds()
+}
+
+func (c *Core) AddTransactions(txs [][]byte) error {
+	for _, tx := range txs {
+		chunks, err := splitTransaction(tx, c.maxEventPayload)
+		if err != nil {
+			return err
+		}
+		c.transactionPool = append(c.transactionPool, chunks...)
+		for _, chunk := range chunks {
+			c.poolBytes += len(chunk)
+		}
+	}
+	return nil
+}
+
+//PoolSize returns the number of transactions in the pool and their size in
+//bytes. Large transactions count once per chunk.
+func (c *Core) PoolSize() (int, int) {
+	return len(c.transactionPool), c.poolBytes
+}
+
+//ProposePeerJoin adds a PeerJoin to the next Event. It is not split like
+//application transactions so that every participant can recognise it.
+func (c *Core) ProposePeerJoin(join hg.PeerJoin) error {
+	tx, err := join.Marshal()
+	if err != nil {
+		return err
+	}
+	c.transactionPool = append(c.transactionPool, tx)
+	c.poolBytes += len(tx)
+	return nil
+}
+
+//ProposePeerLeave adds a PeerLeave to the next Event
+func (c *Core) ProposePeerLeave(leave hg.PeerLeave) error {
+	tx, err := leave.Marshal()
+	if err != nil {
+		return err
+	}
+	c.transactionPool = append(c.transactionPool, tx)
+	c.poolBytes += len(tx)
+	return nil
+}
+
+//ProposeBlockMetadata adds a transaction made by metadataTransaction to the
+//next Event. Like membership transactions, it is not split.
+func (c *Core) ProposeBlockMetadata(tx []byte) {
+	c.transactionPool = append(c.transactionPool, tx)
+	c.poolBytes += len(tx)
+}
+
+//IsParticipant is true if pubKey is a participant of the hashgraph that was
+//not removed
+func (c *Core) IsParticipant(pubKey string) bool {
+	return c.hg.Active(pubKey)
+}
+
+//WasRemoved is true if pubKey was a participant of the hashgraph
+func (c *Core) WasRemoved(pubKey string) bool {
+	_, ok := c.hg.Participants[pubKey]
+	return ok && !c.hg.Active(pubKey)
+}
+
+//nextPayload removes and returns the transactions for the next Event. When a
+//payload limit is set, it takes as many transactions as fit in the limit but
+//always at least one.
+func (c *Core) nextPayload() [][]byte {
+	if c.maxEventPayload <= 0 {
+		payload := c.transactionPool
+		c.transactionPool = [][]byte{}
+		c.poolBytes = 0
+		return payload
+	}
+
+	size := 0
+	n := 0
+	for n < len(c.transactionPool) {
+		size += len(c.transactionPool[n])
+		if n > 0 && size > c.maxEventPayload {
+			break
+		}
+		n++
+	}
+	payload := c.transactionPool[:n:n]
+	c.transactionPool = c.transactionPool[n:]
+	for _, tx := range payload {
+		c.poolBytes -= len(tx)
+	}
+	return payload
 }
 
 func (c *Core) GetHead() (hg.Event, error) {
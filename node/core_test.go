@@ -2,11 +2,12 @@ package node
 
 import (
 	"bytes"
-	"crypto/ecdsa"
 	"encoding/gob"
 	"fmt"
+	"math/big"
 	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/babbleio/babble/common"
@@ -15,9 +16,9 @@ import (
 )
 
 func TestInit(t *testing.T) {
-	key, _ := crypto.GenerateECDSAKey()
+	key, _ := crypto.GenerateKeyPair(crypto.ECDSAP256)
 	participants := map[string]int{
-		fmt.Sprintf("0x%X", crypto.FromECDSAPub(&key.PublicKey)): 0,
+		crypto.KeyPairPubKeyHex(key): 0,
 	}
 	core := NewCore(0, key, participants, hg.NewInmemStore(participants, 10), nil, common.NewTestLogger(t))
 	if err := core.Init(); err != nil {
@@ -25,18 +26,18 @@ func TestInit(t *testing.T) {
 	}
 }
 
-func initCores(n int, t *testing.T) ([]Core, []*ecdsa.PrivateKey, map[string]string) {
+func initCores(n int, t *testing.T) ([]Core, []crypto.KeyPair, map[string]string) {
 	cacheSize := 1000
 
 	cores := []Core{}
 	index := make(map[string]string)
 
-	participantKeys := []*ecdsa.PrivateKey{}
+	participantKeys := []crypto.KeyPair{}
 	participants := make(map[string]int)
 	for i := 0; i < n; i++ {
-		key, _ := crypto.GenerateECDSAKey()
+		key, _ := crypto.GenerateKeyPair(crypto.ECDSAP256)
 		participantKeys = append(participantKeys, key)
-		participants[fmt.Sprintf("0x%X", crypto.FromECDSAPub(&key.PublicKey))] = i
+		participants[crypto.KeyPairPubKeyHex(key)] = i
 	}
 
 	for i := 0; i < n; i++ {
@@ -62,7 +63,7 @@ e01 |   |
 e0  e1  e2
 0   1   2
 */
-func initHashgraph(cores []Core, keys []*ecdsa.PrivateKey, index map[string]string, participant int) {
+func initHashgraph(cores []Core, keys []crypto.KeyPair, index map[string]string, participant int) {
 	for i := 0; i < len(cores); i++ {
 		if i != participant {
 			event, _ := cores[i].GetEvent(index[fmt.Sprintf("e%d", i)])
@@ -94,7 +95,7 @@ func initHashgraph(cores []Core, keys []*ecdsa.PrivateKey, index map[string]stri
 	}
 }
 
-func insertEvent(cores []Core, keys []*ecdsa.PrivateKey, index map[string]string,
+func insertEvent(cores []Core, keys []crypto.KeyPair, index map[string]string,
 	event hg.Event, name string, particant int, creator int) error {
 
 	if particant == creator {
@@ -321,6 +322,241 @@ type play struct {
 	payload [][]byte
 }
 
+func TestCoreBackfill(t *testing.T) {
+	cores, keys, index := initCores(3, t)
+	initHashgraph(cores, keys, index, 0)
+
+	unknownBy1, err := cores[0].Diff(cores[1].Known())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire, err := cores[0].ToWire(unknownBy1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the second chunk overlaps the first one
+	if err := cores[1].Backfill(wire[:3]); err != nil {
+		t.Fatal(err)
+	}
+	if err := cores[1].Backfill(wire[1:]); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(cores[1].Known(), cores[0].Known()) {
+		t.Fatalf("Cores[1].Known should be %v, not %v", cores[0].Known(), cores[1].Known())
+	}
+	//e12 is the last Event of core 1, no new head was created on top of it
+	if head := getName(index, cores[1].Head); head != "e12" {
+		t.Fatalf("Cores[1].Head should be e12, not %s", head)
+	}
+}
+
+func TestCoreSyncOverlap(t *testing.T) {
+	cores, keys, index := initCores(3, t)
+	initHashgraph(cores, keys, index, 0)
+
+	unknownBy1, err := cores[0].Diff(cores[1].Known())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire, err := cores[0].ToWire(unknownBy1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cores[1].SetConcurrentSyncs(true)
+	//a concurrent sync inserted the first Events of the batch
+	if err := cores[1].Backfill(wire[:3]); err != nil {
+		t.Fatal(err)
+	}
+	if err := cores[1].Sync(wire); err != nil {
+		t.Fatalf("Sync should skip the Events inserted meanwhile: %s", err)
+	}
+
+	known := cores[1].Known()
+	if known[0] != cores[0].Known()[0] || known[1] != cores[0].Known()[1]+1 {
+		t.Fatalf("Cores[1].Known should be ahead of %v, not %v", cores[0].Known(), known)
+	}
+	head, err := cores[1].GetHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other := getName(index, head.OtherParent()); other != "e12" {
+		t.Fatalf("The new head should be on top of e12, not %s", other)
+	}
+
+	//a batch that is already inserted whole does not create a new head
+	if err := cores[1].Sync(wire); err != nil {
+		t.Fatalf("Sync of known Events should not fail: %s", err)
+	}
+	if cores[1].Head != head.Hex() {
+		t.Fatal("Sync of known Events should not create a new head")
+	}
+}
+
+func TestCoreSyncStale(t *testing.T) {
+	cores, keys, index := initCores(3, t)
+	initHashgraph(cores, keys, index, 0)
+
+	unknownBy1, err := cores[0].Diff(cores[1].Known())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire, err := cores[0].ToWire(unknownBy1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//an EagerSync inserted the whole batch
+	if err := cores[1].Backfill(wire); err != nil {
+		t.Fatal(err)
+	}
+	if err := cores[1].Sync(wire); err != errStaleSync {
+		t.Fatalf("Sync of a stale batch should return errStaleSync, not %v", err)
+	}
+	if head := getName(index, cores[1].Head); head != "e12" {
+		t.Fatalf("Sync of a stale batch should not create a new head on top of e12, got %s", head)
+	}
+
+	//the Events that were not inserted yet still get a new head
+	unknownBy2, err := cores[0].Diff(cores[2].Known())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire, err = cores[0].ToWire(unknownBy2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cores[2].Backfill(wire[:1]); err != nil {
+		t.Fatal(err)
+	}
+	head := cores[2].Head
+	if err := cores[2].Sync(wire); err != nil {
+		t.Fatalf("Sync of a partly stale batch should succeed, got %v", err)
+	}
+	if known := cores[2].Known(); known[0] != cores[0].Known()[0] {
+		t.Fatalf("Cores[2].Known should include %v, not %v", cores[0].Known(), known)
+	}
+	if cores[2].Head == head {
+		t.Fatal("Sync of a partly stale batch should create a new head")
+	}
+}
+
+func TestCoreVerifyWorkers(t *testing.T) {
+	cores, keys, index := initCores(3, t)
+	initHashgraph(cores, keys, index, 0)
+	cores[1].SetVerifyWorkers(4)
+
+	unknownBy1, err := cores[0].Diff(cores[1].Known())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire, err := cores[0].ToWire(unknownBy1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the Events before an invalid signature are still inserted
+	forged := append([]hg.WireEvent{}, wire...)
+	forged[2].R = big.NewInt(1)
+	before := len(cores[1].GetUndeterminedEvents())
+	err = cores[1].Backfill(forged)
+	if err == nil || !strings.Contains(err.Error(), "Invalid signature") {
+		t.Fatalf("Backfill should reject the invalid signature, got %v", err)
+	}
+	if inserted := len(cores[1].GetUndeterminedEvents()) - before; inserted != 2 {
+		t.Fatalf("2 Events should be inserted before the invalid one, not %d", inserted)
+	}
+
+	if err := cores[1].Backfill(wire); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cores[1].Known(), cores[0].Known()) {
+		t.Fatalf("Cores[1].Known should be %v, not %v", cores[0].Known(), cores[1].Known())
+	}
+}
+
+func TestCoreSplitOrphans(t *testing.T) {
+	cores, keys, index := initCores(3, t)
+	initHashgraph(cores, keys, index, 0)
+
+	unknownBy1, err := cores[0].Diff(cores[1].Known())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire, err := cores[0].ToWire(unknownBy1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//without the first Event, its descendants are orphans
+	ready, orphans := cores[1].SplitOrphans(wire[1:])
+	if len(orphans) == 0 || len(ready)+len(orphans) != len(wire)-1 {
+		t.Fatalf("Expected some of %d Events to be orphans, got %d ready and %d orphans",
+			len(wire)-1, len(ready), len(orphans))
+	}
+	if err := cores[1].Backfill(ready); err != nil {
+		t.Fatal(err)
+	}
+
+	//once it arrives, they can be inserted
+	if err := cores[1].Backfill(wire[:1]); err != nil {
+		t.Fatal(err)
+	}
+	ready, orphans = cores[1].SplitOrphans(orphans)
+	if len(orphans) != 0 {
+		t.Fatalf("Expected no orphans, got %d", len(orphans))
+	}
+	if err := cores[1].Backfill(ready); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(cores[1].Known(), cores[0].Known()) {
+		t.Fatalf("Cores[1].Known should be %v, not %v", cores[0].Known(), cores[1].Known())
+	}
+}
+
+func TestCoreFetch(t *testing.T) {
+	cores, keys, index := initCores(3, t)
+	initHashgraph(cores, keys, index, 0)
+
+	unknownBy1, err := cores[0].Diff(cores[1].Known())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire, err := cores[0].ToWire(unknownBy1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//the orphans reference the first Event, which cores[0] returns
+	ready, orphans := cores[1].SplitOrphans(wire[1:])
+	if err := cores[1].Backfill(ready); err != nil {
+		t.Fatal(err)
+	}
+	parents := cores[1].MissingParents(orphans)
+	if len(parents) != 1 || parents[0] != (hg.WireRef{CreatorID: wire[0].Body.CreatorID, Index: wire[0].Body.Index}) {
+		t.Fatalf("The first Event should be the only missing parent, got %v", parents)
+	}
+	fetched := cores[0].Fetch(nil, parents, cores[1].Known(), 0)
+	if len(fetched) != 1 || fetched[0].Hex() != unknownBy1[0].Hex() {
+		t.Fatalf("Fetch should return the first Event, got %d Events", len(fetched))
+	}
+
+	//by hash, with the ancestors that are not known, oldest first
+	last := unknownBy1[len(unknownBy1)-1]
+	fetched = cores[0].Fetch([]string{last.Hex()}, nil, cores[1].Known(), 0)
+	if len(fetched) == 0 || fetched[len(fetched)-1].Hex() != last.Hex() {
+		t.Fatalf("Fetch should end with the requested Event, got %d Events", len(fetched))
+	}
+	if limited := cores[0].Fetch([]string{last.Hex()}, nil, cores[1].Known(), 1); len(limited) != 1 || limited[0].Hex() != fetched[0].Hex() {
+		t.Fatal("Fetch should keep the oldest Events within the limit")
+	}
+	if known := cores[0].Fetch([]string{last.Hex()}, nil, cores[0].Known(), 0); len(known) != 0 {
+		t.Fatalf("Fetch should leave out known Events, got %d", len(known))
+	}
+}
+
 func initConsensusHashgraph(t *testing.T) []Core {
 	cores, _, _ := initCores(3, t)
 	playbook := []play{
@@ -408,6 +644,10 @@ func TestOverSyncLimit(t *testing.T) {
 		t.Fatalf("OverSyncLimit(%v, %v) should return false", known, syncLimit)
 	}
 
+	//no limit
+	if cores[0].OverSyncLimit(map[int]int{}, 0) {
+		t.Fatal("OverSyncLimit with no limit should return false")
+	}
 }
 
 /*
@@ -584,6 +824,29 @@ func TestCoreFastForward(t *testing.T) {
 
 }
 
+func TestCoreFastForwardAhead(t *testing.T) {
+	cores, _, _ := initCores(4, t)
+	initFFHashgraph(cores, t)
+
+	frame, err := cores[1].GetFrame()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//cores[0] sent Events beyond the Frame that the peers did not decide yet
+	seq := cores[0].frameSeq(frame)
+	cores[0].publish(seq + 1)
+	if err := cores[0].FastForward(frame); err == nil {
+		t.Fatal("FastForward should fail when the node sent Events beyond the Frame")
+	}
+
+	//the Events that never left the node do not matter
+	cores[0].published = int64(seq)
+	if err := cores[0].FastForward(frame); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func synchronizeCores(cores []Core, from int, to int, payload [][]byte) error {
 	knownByTo := cores[to].Known()
 	unknownByTo, err := cores[from].Diff(knownByTo)
@@ -0,0 +1,83 @@
+package node
+
+import (
+	"sync"
+	"time"
+)
+
+/*
+With Config.ConsensusCPUShare, the node caps the share of time it spends
+inserting Events and running the consensus methods, so that the applications
+sharing its host are not starved while it catches up. After every batch of
+Events, the next one waits long enough for the time spent on the batch to be at
+most that share of the total. The cap is cooperative: a batch is never
+interrupted, and the node waits without holding the coreLock, so it still
+answers the requests of its peers and of the application in the meantime.
+*/
+
+type cpuBudget struct {
+	share float64 //0 or 1 disable the cap
+
+	l         sync.Mutex
+	next      time.Time     //no batch starts before
+	throttled time.Duration //time spent waiting for the budget
+	now       func() time.Time
+}
+
+func newCPUBudget(share float64) *cpuBudget {
+	return &cpuBudget{
+		share: share,
+		now:   time.Now,
+	}
+}
+
+func (b *cpuBudget) enabled() bool {
+	return b.share > 0 && b.share < 1
+}
+
+//spent records a batch that kept the CPU busy for busy
+func (b *cpuBudget) spent(busy time.Duration) {
+	if !b.enabled() {
+		return
+	}
+	rest := time.Duration(float64(busy) * (1 - b.share) / b.share)
+	b.l.Lock()
+	b.next = b.now().Add(rest)
+	b.l.Unlock()
+}
+
+//pause returns how long to wait before the next batch
+func (b *cpuBudget) pause() time.Duration {
+	if !b.enabled() {
+		return 0
+	}
+	b.l.Lock()
+	defer b.l.Unlock()
+	d := b.next.Sub(b.now())
+	if d <= 0 {
+		return 0
+	}
+	b.throttled += d
+	return d
+}
+
+func (b *cpuBudget) stats() time.Duration {
+	b.l.Lock()
+	defer b.l.Unlock()
+	return b.throttled
+}
+
+//waitCPUBudget blocks until the CPU budget allows another batch or the node
+//shuts down
+func (n *Node) waitCPUBudget() {
+	d := n.cpuBudget.pause()
+	if d == 0 {
+		return
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-n.shutdownCh:
+	}
+}
@@ -0,0 +1,41 @@
+package node
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCPUBudget(t *testing.T) {
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newCPUBudget(0.25)
+	b.now = func() time.Time { return now }
+
+	if d := b.pause(); d != 0 {
+		t.Fatalf("The first batch should not wait, not %s", d)
+	}
+
+	//a batch busy for 10ms leaves 30ms to the other processes
+	b.spent(10 * time.Millisecond)
+	if d := b.pause(); d != 30*time.Millisecond {
+		t.Fatalf("The next batch should wait 30ms, not %s", d)
+	}
+	now = now.Add(20 * time.Millisecond)
+	if d := b.pause(); d != 10*time.Millisecond {
+		t.Fatalf("The next batch should wait 10ms more, not %s", d)
+	}
+	now = now.Add(10 * time.Millisecond)
+	if d := b.pause(); d != 0 {
+		t.Fatalf("The next batch should not wait anymore, not %s", d)
+	}
+	if b.stats() != 40*time.Millisecond {
+		t.Fatalf("Throttled time should be 40ms, not %s", b.stats())
+	}
+
+	for _, share := range []float64{0, 1} {
+		b := newCPUBudget(share)
+		b.spent(time.Second)
+		if d := b.pause(); d != 0 {
+			t.Fatalf("A share of %g should not throttle, not %s", share, d)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/pprof"
+	"sync"
+	"time"
+
+	"github.com/babbleio/babble/net"
+)
+
+//number of sync results kept for diagnostics
+const syncLogSize = 1000
+
+//SyncResult is the outcome of one half of a gossip exchange
+type SyncResult struct {
+	Time     time.Time
+	Peer     string
+	Step     string //pull or push
+	Duration time.Duration
+	Error    string `json:",omitempty"`
+	Code     string `json:",omitempty"` //ErrorCode of the peer, if it responded with one
+}
+
+//syncLog keeps the last results in a ring buffer
+type syncLog struct {
+	l       sync.Mutex
+	results []SyncResult
+	next    int
+}
+
+func newSyncLog(size int) *syncLog {
+	return &syncLog{
+		results: make([]SyncResult, 0, size),
+	}
+}
+
+func (s *syncLog) add(r SyncResult) {
+	s.l.Lock()
+	defer s.l.Unlock()
+	if len(s.results) < cap(s.results) {
+		s.results = append(s.results, r)
+		return
+	}
+	s.results[s.next] = r
+	s.next = (s.next + 1) % len(s.results)
+}
+
+//Results returns the results, oldest first
+func (s *syncLog) Results() []SyncResult {
+	s.l.Lock()
+	defer s.l.Unlock()
+	res := make([]SyncResult, 0, len(s.results))
+	res = append(res, s.results[s.next:]...)
+	res = append(res, s.results[:s.next]...)
+	return res
+}
+
+func (n *Node) recordSync(peer, step string, start time.Time, err error) {
+	r := SyncResult{
+		Time:     start,
+		Peer:     peer,
+		Step:     step,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	if code := errorCode(err); code != net.ErrorNone {
+		r.Code = code.String()
+	}
+	n.syncLog.add(r)
+}
+
+//WriteDiagnostics writes a diagnostic bundle in a new timestamped directory
+//under dir and returns its path. The bundle contains a goroutine dump, a heap
+//profile, the node's stats, the usage of the hashgraph caches and the last
+//sync results.
+func (n *Node) WriteDiagnostics(dir string) (string, error) {
+	path := filepath.Join(dir, "diagnostics-"+time.Now().UTC().Format("20060102-150405"))
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return "", err
+	}
+
+	writeFile := func(name string, write func(f *os.File) error) error {
+		f, err := os.Create(filepath.Join(path, name))
+		if err != nil {
+			return err
+		}
+		if err := write(f); err != nil {
+			f.Close()
+			return fmt.Errorf("%s: %s", name, err)
+		}
+		return f.Close()
+	}
+	writeJSON := func(name string, v interface{}) error {
+		return writeFile(name, func(f *os.File) error {
+			enc := json.NewEncoder(f)
+			enc.SetIndent("", "  ")
+			return enc.Encode(v)
+		})
+	}
+
+	err := writeFile("goroutines.txt", func(f *os.File) error {
+		return pprof.Lookup("goroutine").WriteTo(f, 2)
+	})
+	if err != nil {
+		return path, err
+	}
+
+	err = writeFile("heap.pprof", func(f *os.File) error {
+		runtime.GC()
+		return pprof.WriteHeapProfile(f)
+	})
+	if err != nil {
+		return path, err
+	}
+
+	n.coreLock.RLock()
+	cacheStats := n.core.CacheStats()
+	n.coreLock.RUnlock()
+	if err := writeJSON("caches.json", cacheStats); err != nil {
+		return path, err
+	}
+
+	if err := writeJSON("syncs.json", n.syncLog.Results()); err != nil {
+		return path, err
+	}
+
+	stats := n.GetStats()
+	if err := writeJSON("stats.json", stats); err != nil {
+		return path, err
+	}
+
+	return path, nil
+}
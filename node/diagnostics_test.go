@@ -0,0 +1,68 @@
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+)
+
+func TestSyncLog(t *testing.T) {
+	log := newSyncLog(3)
+	for i := 0; i < 5; i++ {
+		log.add(SyncResult{Peer: fmt.Sprintf("peer%d", i)})
+	}
+	results := log.Results()
+	if len(results) != 3 {
+		t.Fatalf("Sync log should keep 3 results, not %d", len(results))
+	}
+	for i, r := range results {
+		if expected := fmt.Sprintf("peer%d", i+2); r.Peer != expected {
+			t.Fatalf("results[%d] should be %s, not %s", i, expected, r.Peer)
+		}
+	}
+}
+
+func TestWriteDiagnostics(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	_, nodes := initNodes(2, 1000, logger)
+	defer shutdownNodes(nodes)
+
+	if err := gossip(nodes, 2, false, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path, err := nodes[0].WriteDiagnostics(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, f := range []string{"goroutines.txt", "heap.pprof", "caches.json", "syncs.json", "stats.json"} {
+		if _, err := os.Stat(filepath.Join(path, f)); err != nil {
+			t.Fatalf("Diagnostics should contain %s: %s", f, err)
+		}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(path, "syncs.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var syncs []SyncResult
+	if err := json.Unmarshal(data, &syncs); err != nil {
+		t.Fatal(err)
+	}
+	if len(syncs) == 0 {
+		t.Fatal("Diagnostics should contain sync results")
+	}
+}
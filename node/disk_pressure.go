@@ -0,0 +1,138 @@
+package node
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+/*
+A node that runs out of disk space fails in the middle of a write, and may leave
+its store corrupted. With Config.MinFreeDisk, a background job checks the free
+space of the filesystem of Config.DiskPath every diskCheckInterval, and the node
+degrades gracefully as it runs low:
+
+ - below MinFreeDisk, it is under disk pressure: it refuses new transactions
+   with ErrDiskPressure, like a full pool, and compacts its store, which is the
+   only history it can drop safely,
+ - below a quarter of MinFreeDisk, it also stops writing, by moving to the
+   Maintenance state, where it still serves the requests that read its
+   hashgraph,
+ - once the free space is back above MinFreeDisk, it takes transactions again
+   and, if it moved to Maintenance itself, resumes gossip.
+
+The condition is reported by Status and by the disk_pressure and free_disk_mb
+stats, so that load balancers and the service router send transactions
+elsewhere.
+*/
+
+//diskCheckInterval is the period of the free space checks
+const diskCheckInterval = 10 * time.Second
+
+//ErrDiskPressure is returned by SubmitTx while the node is low on disk space
+var ErrDiskPressure = errors.New("Node is low on disk space")
+
+//compactor is implemented by the stores that can reclaim space, like
+//hashgraph.BadgerStore
+type compactor interface {
+	Compact() error
+}
+
+type diskMonitor struct {
+	lock        sync.Mutex
+	free        func(path string) (uint64, error)
+	lastFree    uint64
+	pressure    bool
+	maintenance bool //the monitor moved the node to Maintenance
+}
+
+func newDiskMonitor() *diskMonitor {
+	return &diskMonitor{free: freeDiskSpace}
+}
+
+//DiskPressure is true while the node is low on disk space, see
+//disk_pressure.go
+func (n *Node) DiskPressure() bool {
+	n.disk.lock.Lock()
+	defer n.disk.lock.Unlock()
+	return n.disk.pressure
+}
+
+//checkDisk is the job run every diskCheckInterval
+func (n *Node) checkDisk() error {
+	free, err := n.disk.free(n.conf.diskPath())
+	if err != nil {
+		n.logger.WithField("error", err).Error("Checking free disk space")
+		return err
+	}
+	min := uint64(n.conf.MinFreeDisk)
+
+	n.disk.lock.Lock()
+	n.disk.lastFree = free
+	wasUnder := n.disk.pressure
+	n.disk.pressure = free < min
+	enter := free < min/4 && !n.disk.maintenance
+	leave := free >= min && n.disk.maintenance
+	n.disk.lock.Unlock()
+
+	fields := logrus.Fields{
+		"free_bytes": free,
+		"min_bytes":  min,
+	}
+	switch {
+	case free < min && !wasUnder:
+		n.logger.WithFields(fields).Warn("Low disk space, refusing transactions")
+	case free >= min && wasUnder:
+		n.logger.WithFields(fields).Info("Disk space recovered")
+	}
+
+	if free < min {
+		n.compactStore()
+	}
+	if enter {
+		if err := n.setState(Maintenance); err != nil {
+			n.logger.WithField("error", err).Error("Stopping writes on low disk space")
+		} else {
+			n.setDiskMaintenance(true)
+			n.logger.WithFields(fields).Error("Critically low disk space, stopped gossip")
+		}
+	}
+	if leave {
+		//an operator may have moved the node out of Maintenance already
+		if err := n.EndMaintenance(); err != nil {
+			n.logger.WithField("error", err).Debug("Resuming gossip after disk pressure")
+		}
+		n.setDiskMaintenance(false)
+	}
+	return nil
+}
+
+func (n *Node) setDiskMaintenance(m bool) {
+	n.disk.lock.Lock()
+	n.disk.maintenance = m
+	n.disk.lock.Unlock()
+}
+
+//compactStore reclaims the space of the store, if it can. It does not hold the
+//coreLock, the store being safe for concurrent use.
+func (n *Node) compactStore() {
+	c, ok := n.core.hg.Store.(compactor)
+	if !ok {
+		return
+	}
+	start := time.Now()
+	if err := c.Compact(); err != nil {
+		n.logger.WithField("error", err).Error("Compacting store")
+		return
+	}
+	n.logger.WithField("duration", time.Since(start)).Info("Compacted store")
+}
+
+//freeDiskMB returns the free space measured by the last check, in MB
+func (n *Node) freeDiskMB() uint64 {
+	n.disk.lock.Lock()
+	defer n.disk.lock.Unlock()
+	return n.disk.lastFree / (1024 * 1024)
+}
@@ -0,0 +1,61 @@
+package node
+
+import (
+	"os"
+	"testing"
+
+	"github.com/babbleio/babble/net"
+)
+
+func TestDiskPressure(t *testing.T) {
+	keys, peers := initPeers(1)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	conf := TestConfig(t)
+	conf.MinFreeDisk = 1000
+	conf.DiskPath = os.TempDir()
+	prox := &blockProxy{submitCh: make(chan []byte)}
+	node := NewNode(conf, keys[0], peers, trans, prox)
+
+	free := uint64(500)
+	node.disk.free = func(string) (uint64, error) { return free, nil }
+
+	//under MinFreeDisk, transactions are refused but the node keeps gossiping
+	if err := node.checkDisk(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := node.SubmitTx([]byte("tx")); err != ErrDiskPressure {
+		t.Fatalf("SubmitTx should fail with ErrDiskPressure, got %v", err)
+	}
+	node.addTransaction([]byte("tx"))
+	if s := node.GetStats(); s["rejected_txs"] != "1" || s["disk_pressure"] != "true" {
+		t.Fatalf("Transaction should be rejected under disk pressure, stats %v", s)
+	}
+	if st := node.Status(); !st.DiskPressure || st.State != Babbling.String() {
+		t.Fatalf("Node should report disk pressure and keep Babbling, got %+v", st)
+	}
+
+	//under a quarter of MinFreeDisk, it stops writing
+	free = 100
+	node.checkDisk()
+	if s := node.getState(); s != Maintenance {
+		t.Fatalf("Node should be in Maintenance, not %s", s)
+	}
+
+	//it resumes once the space is back
+	free = 2000
+	node.checkDisk()
+	if s := node.getState(); s != Babbling || node.DiskPressure() {
+		t.Fatalf("Node should resume Babbling without disk pressure, got %s", s)
+	}
+
+	//an operator's Maintenance is left alone
+	node.StartMaintenance()
+	free = 1500
+	node.checkDisk()
+	if s := node.getState(); s != Maintenance {
+		t.Fatalf("Node should stay in the Maintenance started by an operator, not %s", s)
+	}
+}
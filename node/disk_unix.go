@@ -0,0 +1,16 @@
+//go:build !windows
+// +build !windows
+
+package node
+
+import "syscall"
+
+//freeDiskSpace returns the bytes available to the process on the filesystem of
+//path
+func freeDiskSpace(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
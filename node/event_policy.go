@@ -0,0 +1,103 @@
+package node
+
+import (
+	"fmt"
+	"time"
+)
+
+//EventTrigger identifies the situation in which the Core considers creating
+//a new self-event
+type EventTrigger int
+
+const (
+	//SyncTrigger: events were just received from another node
+	SyncTrigger EventTrigger = iota
+	//SelfTrigger: the node is about to gossip and may package its own
+	//transactions
+	SelfTrigger
+)
+
+func (t EventTrigger) String() string {
+	switch t {
+	case SyncTrigger:
+		return "Sync"
+	case SelfTrigger:
+		return "Self"
+	default:
+		return "Unknown"
+	}
+}
+
+//EventContext is the information given to an EventCreationPolicy
+type EventContext struct {
+	Trigger     EventTrigger
+	OtherEvents int           //number of events received in the sync
+	PendingTxs  int           //number of transactions in the pool
+	SinceLast   time.Duration //time elapsed since the last self-event
+}
+
+//EventCreationPolicy decides when the Core creates a new self-event. Creating
+//events often lowers latency but grows the hashgraph faster.
+type EventCreationPolicy interface {
+	ShouldCreateEvent(ctx EventContext) bool
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//EVERY SYNC
+
+//EverySyncPolicy creates an event after every sync that brought new events
+//and whenever there are transactions to package. This is the default.
+type EverySyncPolicy struct{}
+
+func (p EverySyncPolicy) ShouldCreateEvent(ctx EventContext) bool {
+	if ctx.Trigger == SyncTrigger {
+		return ctx.OtherEvents > 0 || ctx.PendingTxs > 0
+	}
+	return ctx.PendingTxs > 0
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//TRANSACTIONS ONLY
+
+//TransactionsOnlyPolicy only creates events that carry transactions. The
+//hashgraph stays small when the network is idle, but events that are already
+//in the hashgraph only reach consensus once new transactions are submitted.
+type TransactionsOnlyPolicy struct{}
+
+func (p TransactionsOnlyPolicy) ShouldCreateEvent(ctx EventContext) bool {
+	return ctx.PendingTxs > 0
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//TIMER
+
+//TimerPolicy behaves like EverySyncPolicy but creates at most one event per
+//Interval. Transactions wait in the pool until the interval has passed.
+type TimerPolicy struct {
+	Interval time.Duration
+}
+
+func (p TimerPolicy) ShouldCreateEvent(ctx EventContext) bool {
+	if ctx.SinceLast < p.Interval {
+		return false
+	}
+	return EverySyncPolicy{}.ShouldCreateEvent(ctx)
+}
+
+//NewEventCreationPolicy returns the built-in policy called name: "sync",
+//"transactions" or "timer". interval is only used by the timer policy.
+func NewEventCreationPolicy(name string, interval time.Duration) (EventCreationPolicy, error) {
+	switch name {
+	case "", "sync":
+		return EverySyncPolicy{}, nil
+	case "transactions":
+		return TransactionsOnlyPolicy{}, nil
+	case "timer":
+		if interval <= 0 {
+			return nil, fmt.Errorf("Timer event policy requires a positive interval")
+		}
+		return TimerPolicy{Interval: interval}, nil
+	default:
+		return nil, fmt.Errorf("Unknown event policy %s", name)
+	}
+}
@@ -0,0 +1,71 @@
+package node
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventPolicies(t *testing.T) {
+	cases := []struct {
+		policy EventCreationPolicy
+		ctx    EventContext
+		create bool
+	}{
+		{EverySyncPolicy{}, EventContext{Trigger: SyncTrigger, OtherEvents: 1}, true},
+		{EverySyncPolicy{}, EventContext{Trigger: SyncTrigger}, false},
+		{EverySyncPolicy{}, EventContext{Trigger: SelfTrigger, PendingTxs: 1}, true},
+		{EverySyncPolicy{}, EventContext{Trigger: SelfTrigger}, false},
+		{TransactionsOnlyPolicy{}, EventContext{Trigger: SyncTrigger, OtherEvents: 3}, false},
+		{TransactionsOnlyPolicy{}, EventContext{Trigger: SyncTrigger, PendingTxs: 1}, true},
+		{TimerPolicy{time.Second}, EventContext{Trigger: SyncTrigger, OtherEvents: 1, SinceLast: time.Millisecond}, false},
+		{TimerPolicy{time.Second}, EventContext{Trigger: SyncTrigger, OtherEvents: 1, SinceLast: 2 * time.Second}, true},
+		{TimerPolicy{time.Second}, EventContext{Trigger: SyncTrigger, SinceLast: 2 * time.Second}, false},
+	}
+
+	for i, c := range cases {
+		if res := c.policy.ShouldCreateEvent(c.ctx); res != c.create {
+			t.Fatalf("cases[%d] should return %v, not %v", i, c.create, res)
+		}
+	}
+}
+
+func TestNewEventCreationPolicy(t *testing.T) {
+	if _, err := NewEventCreationPolicy("timer", 0); err == nil {
+		t.Fatal("Timer policy without interval should fail")
+	}
+	if _, err := NewEventCreationPolicy("whenever", 0); err == nil {
+		t.Fatal("Unknown policy should fail")
+	}
+	p, err := NewEventCreationPolicy("transactions", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := p.(TransactionsOnlyPolicy); !ok {
+		t.Fatalf("Policy should be TransactionsOnlyPolicy, not %T", p)
+	}
+}
+
+func TestCoreEventPolicy(t *testing.T) {
+	cores, _, _ := initCores(1, t)
+	core := cores[0]
+	core.SetEventCreationPolicy(TimerPolicy{Interval: time.Hour})
+
+	if err := core.AddTransactions([][]byte{[]byte("tx")}); err != nil {
+		t.Fatal(err)
+	}
+	head := core.Head
+	if err := core.AddSelfEvent(); err != nil {
+		t.Fatal(err)
+	}
+	if core.Head != head {
+		t.Fatal("Timer policy should defer event creation")
+	}
+
+	core.SetEventCreationPolicy(EverySyncPolicy{})
+	if err := core.AddSelfEvent(); err != nil {
+		t.Fatal(err)
+	}
+	if core.Head == head {
+		t.Fatal("EverySyncPolicy should create an event")
+	}
+}
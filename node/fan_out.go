@@ -0,0 +1,48 @@
+package node
+
+import (
+	"github.com/babbleio/babble/net"
+)
+
+/*
+With a FanOut above 1, every gossip round syncs with that many peers at the
+same time, so that new Events reach a large cluster in fewer rounds. Each sync
+runs in its own goroutine and inserts its Events under the core lock. The peers
+of a round send many of the same Events, so the Core is told to expect
+overlapping batches: Core.Sync skips the Events that a concurrent sync inserted
+first and still creates a new head, where it would otherwise end the gossip
+round.
+*/
+
+//fanOut returns the number of peers gossiped with in each gossip round
+func (n *Node) fanOut() int {
+	if n.conf.FanOut <= 0 {
+		return 1
+	}
+	return n.conf.FanOut
+}
+
+//gossipPeers returns up to count different peers to gossip with. The first one
+//is picked by gossipPeer, the others by the peer selector.
+func (n *Node) gossipPeers(count int) []net.Peer {
+	first := n.gossipPeer()
+	peers := []net.Peer{first}
+	if count <= 1 {
+		return peers
+	}
+
+	picked := map[string]bool{first.NetAddr: true}
+	n.selectorLock.Lock()
+	defer n.selectorLock.Unlock()
+	//the selector may pick the same peer again, so it takes a few tries to find
+	//new peers
+	attempts := count * len(n.peerSelector.Peers())
+	for i := 0; len(peers) < count && i < attempts; i++ {
+		peer := n.peerSelector.Next()
+		if !picked[peer.NetAddr] {
+			picked[peer.NetAddr] = true
+			peers = append(peers, peer)
+		}
+	}
+	return peers
+}
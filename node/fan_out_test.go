@@ -0,0 +1,38 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+)
+
+func TestGossipPeers(t *testing.T) {
+	_, nodes := initNodes(4, 1000, common.NewTestLogger(t))
+	defer shutdownNodes(nodes)
+
+	for count, expected := range map[int]int{0: 1, 1: 1, 2: 2, 3: 3, 10: 3} {
+		peers := nodes[0].gossipPeers(count)
+		if len(peers) != expected {
+			t.Fatalf("gossipPeers(%d) should return %d peers, not %d", count, expected, len(peers))
+		}
+		seen := make(map[string]bool)
+		for _, p := range peers {
+			if p.NetAddr == nodes[0].localAddr || seen[p.NetAddr] {
+				t.Fatalf("gossipPeers(%d) should return different peers, got %v", count, peers)
+			}
+			seen[p.NetAddr] = true
+		}
+	}
+}
+
+func TestFanOutGossip(t *testing.T) {
+	_, nodes := initNodes(4, 1000, common.NewTestLogger(t))
+	//the nodes share the same Config
+	nodes[0].conf.FanOut = 3
+
+	if err := gossip(nodes, 20, true, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	checkGossip(nodes, t)
+}
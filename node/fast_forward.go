@@ -0,0 +1,97 @@
+package node
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/babbleio/babble/net"
+)
+
+/*
+Before fast-forwarding, a node probes a few peers with a FastForwardRequest that
+only asks for their last consensus round. The peers that answer within the
+latency budget, TCPTimeout, are the candidate sources: the most advanced first
+and, among peers at the same round, the quickest to answer. The node requests the
+Frame from the first candidate and falls back to the next one when the transfer
+fails or stalls past the FastForward timeout of the transport. A peer that is
+not ready to serve a Frame, because it has consensus Events on their way to its
+App, is skipped the same way.
+*/
+
+//number of peers probed before fast-forwarding
+const fastForwardProbes = 3
+
+type fastForwardSource struct {
+	addr    string
+	round   int
+	latency time.Duration
+}
+
+//fastForwardSources returns the peers to request a Frame from, in order of
+//preference. When no peer answers the probes in time, it returns a single peer
+//picked by the peer selector. The probes that did not answer in time are
+//cancelled.
+func (n *Node) fastForwardSources(ctx context.Context) []fastForwardSource {
+	peers := n.gossipPeers(fastForwardProbes)
+	ctx, cancel := context.WithTimeout(ctx, n.conf.TCPTimeout)
+	defer cancel()
+
+	//the channel is buffered so that late answers do not block the probes
+	results := make(chan fastForwardSource, len(peers))
+	for _, p := range peers {
+		addr := p.NetAddr
+		go func() {
+			start := time.Now()
+			resp, err := n.fastForwardRPC(ctx, addr, &net.FastForwardRequest{
+				From:  n.localAddr,
+				Probe: true,
+			})
+			if err != nil {
+				n.logger.WithFields(logrus.Fields{
+					"peer":  addr,
+					"error": err,
+				}).Debug("Probing FastForward source")
+				resp.Round = -1
+			}
+			results <- fastForwardSource{addr: addr, round: resp.Round, latency: time.Since(start)}
+		}()
+	}
+
+	sources := []fastForwardSource{}
+wait:
+	for range peers {
+		select {
+		case s := <-results:
+			if s.round >= 0 {
+				sources = append(sources, s)
+			}
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	if len(sources) == 0 {
+		return []fastForwardSource{{addr: peers[0].NetAddr, round: -1}}
+	}
+	rankFastForwardSources(sources)
+	n.logger.WithFields(logrus.Fields{
+		"source":  sources[0].addr,
+		"round":   sources[0].round,
+		"latency": sources[0].latency,
+		"probed":  len(peers),
+	}).Debug("Picked FastForward source")
+	return sources
+}
+
+//rankFastForwardSources sorts the sources by decreasing round, then increasing
+//latency
+func rankFastForwardSources(sources []fastForwardSource) {
+	sort.SliceStable(sources, func(i, j int) bool {
+		if sources[i].round != sources[j].round {
+			return sources[i].round > sources[j].round
+		}
+		return sources[i].latency < sources[j].latency
+	})
+}
@@ -0,0 +1,136 @@
+package node
+
+import (
+	"context"
+	"hash/crc32"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/net"
+)
+
+func TestRankFastForwardSources(t *testing.T) {
+	sources := []fastForwardSource{
+		{addr: "slow", round: 10, latency: 30 * time.Millisecond},
+		{addr: "behind", round: 8, latency: time.Millisecond},
+		{addr: "fast", round: 10, latency: 10 * time.Millisecond},
+	}
+	rankFastForwardSources(sources)
+
+	expected := []string{"fast", "slow", "behind"}
+	for i, addr := range expected {
+		if sources[i].addr != addr {
+			t.Fatalf("Source %d should be %s, not %s", i, addr, sources[i].addr)
+		}
+	}
+}
+
+func TestFastForwardProbe(t *testing.T) {
+	_, nodes := initNodes(4, 1000, common.NewTestLogger(t))
+	defer shutdownNodes(nodes)
+
+	if err := gossip(nodes[1:], 10, false, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := nodes[0].fastForwardRPC(context.Background(), nodes[1].localAddr, &net.FastForwardRequest{
+		From:  nodes[0].localAddr,
+		Probe: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Round < 10 || len(resp.Frame.Events) > 0 {
+		t.Fatalf("A probe should return the round only, got round %d and %d Events",
+			resp.Round, len(resp.Frame.Events))
+	}
+
+	sources := nodes[0].fastForwardSources(context.Background())
+	if len(sources) == 0 || sources[0].round < 10 {
+		t.Fatalf("The first source should be at round 10 or more, got %+v", sources)
+	}
+}
+
+func TestChunkedFastForward(t *testing.T) {
+	_, nodes := initNodes(4, 1000, common.NewTestLogger(t))
+	defer shutdownNodes(nodes)
+
+	if err := gossip(nodes[1:], 10, false, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	chunk := 512
+	nodes[0].conf.FastForwardChunk = chunk
+	progress := []FastForwardProgress{}
+	nodes[0].OnFastForwardProgress(func(p FastForwardProgress) {
+		//the source stops serving for a while after the first chunk
+		if len(progress) == 0 {
+			nodes[1].Suspend()
+			time.AfterFunc(150*time.Millisecond, func() { nodes[1].Resume() })
+		}
+		progress = append(progress, p)
+	})
+
+	//peers refuse to answer while consensus Events are on their way to the App
+	var resp net.FastForwardResponse
+	var err error
+	for i := 0; i < 10; i++ {
+		if resp, err = nodes[0].requestFastForward(context.Background(), nodes[1].localAddr); err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Round < 10 || len(resp.Frame.Events) == 0 {
+		t.Fatalf("The transfer should carry a Frame at round 10 or more, got round %d and %d Events",
+			resp.Round, len(resp.Frame.Events))
+	}
+
+	//the transfer resumed after the first chunk instead of starting over
+	if len(progress) < 3 {
+		t.Fatalf("The Frame should come in several chunks, got %d", len(progress))
+	}
+	for i, p := range progress {
+		received := p.Received
+		if i > 0 {
+			received -= progress[i-1].Received
+		}
+		if received <= 0 || received > chunk || p.Total != progress[0].Total {
+			t.Fatalf("Chunk %d should add up to %d bytes to the transfer, got %+v after %+v", i, chunk, p, progress[:i])
+		}
+	}
+	if last := progress[len(progress)-1]; last.Received != last.Total {
+		t.Fatalf("The last chunk should complete the transfer, got %+v", last)
+	}
+}
+
+func TestCheckChunk(t *testing.T) {
+	data := []byte("chunk")
+	good := net.FastForwardResponse{Transfer: "id", Size: 10, Offset: 5, Chunk: data, Checksum: crc32.Checksum(data, castagnoli)}
+	if err := checkChunk(good, "id", 10, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	corrupted := good
+	corrupted.Chunk = []byte("chonk")
+	tooLong := good
+	tooLong.Size = 9
+	for _, c := range []struct {
+		name   string
+		resp   net.FastForwardResponse
+		offset int
+	}{
+		{"corrupted", corrupted, 5},
+		{"wrong offset", good, 4},
+		{"past the end", tooLong, 5},
+		{"other transfer", net.FastForwardResponse{Transfer: "other", Size: 10, Offset: 5, Chunk: data, Checksum: good.Checksum}, 5},
+		{"empty", net.FastForwardResponse{Transfer: "id", Size: 10, Offset: 5}, 5},
+	} {
+		if err := checkChunk(c.resp, "id", c.resp.Size, c.offset); err == nil {
+			t.Fatalf("A chunk %s should be refused", c.name)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package node
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/babbleio/babble/net"
+)
+
+/*
+The Frame and the App snapshot of a node that is far behind can be too large to
+get in one FastForward response within the timeout of the RPC, and a connection
+that drops near the end of a single response wastes the whole transfer. With
+Config.FastForwardChunk, they are transferred in chunks instead:
+
+ 1. the first FastForwardRequest sets ChunkSize. The source encodes its Frame,
+    Block, Snapshot and commit deduplication window once, keeps the encoding
+    for the next requests, and answers with its first chunk, the size of the
+    transfer and its ID, the SHA256 of the encoding,
+ 2. each following request asks for the chunk at the offset received so far.
+    Every chunk comes with its CRC-32C; a chunk that is corrupted, or that does
+    not arrive because the connection dropped, is requested again from the
+    same offset, up to fastForwardChunkRetries times in a row,
+ 3. once the transfer is complete, its SHA256 must match its ID, and the
+    Frame, Block, Snapshot and window are decoded from it.
+
+The hooks registered with OnFastForwardProgress are called after every chunk.
+Sources that do not chunk answer the first request with the whole Frame, which
+is used as is. A source keeps the encodings of its last transfers for
+fastForwardTransferTTL after their last chunk; a node that asks for a transfer
+the source forgot moves on to the next source.
+*/
+
+const (
+	//fastForwardChunkRetries is the number of times a chunk is requested again
+	//before the transfer from a source is abandoned
+	fastForwardChunkRetries = 5
+
+	//fastForwardRetryDelay is the delay before the first retry of a chunk. It
+	//grows with every retry.
+	fastForwardRetryDelay = 100 * time.Millisecond
+
+	//maxFastForwardTransfers is the number of transfers a source keeps at once
+	maxFastForwardTransfers = 4
+
+	//fastForwardTransferTTL is how long a source keeps a transfer after its
+	//last chunk was requested
+	fastForwardTransferTTL = 2 * time.Minute
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+//FastForwardProgress is the state of a chunked transfer from a source
+type FastForwardProgress struct {
+	Source   string
+	Received int //bytes received so far
+	Total    int //bytes of the whole transfer
+}
+
+//sentTransfer is the encoding of a Frame, a Block and a Snapshot served in
+//chunks
+type sentTransfer struct {
+	payload  []byte
+	lastUsed time.Time
+}
+
+//fastForwardTransfers keeps the transfers a node serves, and the progress hooks
+//of the transfers it receives
+type fastForwardTransfers struct {
+	l        sync.Mutex
+	sent     map[string]*sentTransfer
+	progress []func(FastForwardProgress)
+}
+
+func newFastForwardTransfers() *fastForwardTransfers {
+	return &fastForwardTransfers{sent: make(map[string]*sentTransfer)}
+}
+
+//add keeps payload and returns the ID of its transfer. The transfers that
+//expired, and the oldest ones over maxFastForwardTransfers, are dropped.
+func (t *fastForwardTransfers) add(payload []byte) string {
+	sum := sha256.Sum256(payload)
+	id := hex.EncodeToString(sum[:])
+
+	t.l.Lock()
+	defer t.l.Unlock()
+	now := time.Now()
+	for k, s := range t.sent {
+		if now.Sub(s.lastUsed) > fastForwardTransferTTL {
+			delete(t.sent, k)
+		}
+	}
+	for len(t.sent) >= maxFastForwardTransfers {
+		oldest := ""
+		for k, s := range t.sent {
+			if oldest == "" || s.lastUsed.Before(t.sent[oldest].lastUsed) {
+				oldest = k
+			}
+		}
+		delete(t.sent, oldest)
+	}
+	t.sent[id] = &sentTransfer{payload: payload, lastUsed: now}
+	return id
+}
+
+//get returns the payload of the transfer id, if it is still kept
+func (t *fastForwardTransfers) get(id string) ([]byte, bool) {
+	t.l.Lock()
+	defer t.l.Unlock()
+	s, ok := t.sent[id]
+	if !ok {
+		return nil, false
+	}
+	s.lastUsed = time.Now()
+	return s.payload, true
+}
+
+func (t *fastForwardTransfers) onProgress(f func(FastForwardProgress)) {
+	t.l.Lock()
+	defer t.l.Unlock()
+	t.progress = append(t.progress, f)
+}
+
+func (t *fastForwardTransfers) report(p FastForwardProgress) {
+	t.l.Lock()
+	hooks := t.progress
+	t.l.Unlock()
+	for _, h := range hooks {
+		h(p)
+	}
+}
+
+//OnFastForwardProgress registers a hook called after every chunk of a
+//fast-forward is received
+func (n *Node) OnFastForwardProgress(hook func(FastForwardProgress)) {
+	n.transfers.onProgress(hook)
+}
+
+//startFastForwardTransfer keeps the encoding of the Frame, Block, Snapshot and
+//commit deduplication window of resp, and returns the ID of its transfer
+func (n *Node) startFastForwardTransfer(resp *net.FastForwardResponse) string {
+	return n.transfers.add(net.FastForwardResponse{
+		Frame:    resp.Frame,
+		Block:    resp.Block,
+		Snapshot: resp.Snapshot,
+		Dedup:    resp.Dedup,
+	}.MarshalProto())
+}
+
+//respondFastForwardChunk answers with the chunk of transfer at offset
+func (n *Node) respondFastForwardChunk(rpc net.RPC, round int, transfer string, offset, chunkSize int) {
+	payload, ok := n.transfers.get(transfer)
+	if !ok {
+		rpc.Respond(&net.FastForwardResponse{From: n.localAddr, Round: round},
+			fmt.Errorf("Unknown FastForward transfer %s", transfer))
+		return
+	}
+	if offset < 0 || offset >= len(payload) {
+		rpc.Respond(&net.FastForwardResponse{From: n.localAddr, Round: round},
+			fmt.Errorf("Offset %d out of the %d bytes of transfer %s", offset, len(payload), transfer))
+		return
+	}
+
+	end := offset + chunkSize
+	if end > len(payload) {
+		end = len(payload)
+	}
+	chunk := payload[offset:end]
+	rpc.Respond(&net.FastForwardResponse{
+		From:     n.localAddr,
+		Round:    round,
+		Transfer: transfer,
+		Size:     len(payload),
+		Offset:   offset,
+		Chunk:    chunk,
+		Checksum: crc32.Checksum(chunk, castagnoli),
+	}, nil)
+}
+
+//receiveFastForward gets the rest of the chunked transfer that started with
+//first, and decodes the Frame, Block and Snapshot it carries
+func (n *Node) receiveFastForward(ctx context.Context, target string, args net.FastForwardRequest, first net.FastForwardResponse) (net.FastForwardResponse, error) {
+	transfer, size := first.Transfer, first.Size
+	payload := make([]byte, 0, size)
+	resp, err := first, error(nil)
+	failures := 0
+	for {
+		if err == nil {
+			err = checkChunk(resp, transfer, size, len(payload))
+		}
+		if err == nil {
+			failures = 0
+			payload = append(payload, resp.Chunk...)
+			n.transfers.report(FastForwardProgress{Source: target, Received: len(payload), Total: size})
+			if len(payload) == size {
+				break
+			}
+		} else {
+			failures++
+			if failures > fastForwardChunkRetries {
+				return net.FastForwardResponse{}, fmt.Errorf("Transfer from %s failed at %d of %d bytes: %s", target, len(payload), size, err)
+			}
+			n.logger.WithFields(logrus.Fields{
+				"source":   target,
+				"received": len(payload),
+				"size":     size,
+				"error":    err,
+			}).Debug("Retrying FastForward chunk")
+			select {
+			case <-time.After(time.Duration(failures) * fastForwardRetryDelay):
+			case <-ctx.Done():
+				return net.FastForwardResponse{}, ctx.Err()
+			}
+		}
+
+		args.Transfer, args.Offset = transfer, len(payload)
+		resp, err = n.fastForwardRPC(ctx, target, &args)
+	}
+
+	if sum := sha256.Sum256(payload); hex.EncodeToString(sum[:]) != transfer {
+		return net.FastForwardResponse{}, fmt.Errorf("Transfer from %s does not match its SHA256", target)
+	}
+	var out net.FastForwardResponse
+	if err := out.UnmarshalProto(payload); err != nil {
+		return net.FastForwardResponse{}, err
+	}
+	out.From, out.Round = first.From, first.Round
+	return out, nil
+}
+
+//checkChunk returns an error unless resp carries the intact chunk at offset of
+//the transfer
+func checkChunk(resp net.FastForwardResponse, transfer string, size, offset int) error {
+	switch {
+	case resp.Transfer != transfer || resp.Size != size:
+		return fmt.Errorf("Chunk of transfer %s of %d bytes, not %s of %d bytes", resp.Transfer, resp.Size, transfer, size)
+	case resp.Offset != offset:
+		return fmt.Errorf("Chunk at offset %d, not %d", resp.Offset, offset)
+	case len(resp.Chunk) == 0 || offset+len(resp.Chunk) > size:
+		return fmt.Errorf("Chunk of %d bytes at offset %d of %d", len(resp.Chunk), offset, size)
+	case crc32.Checksum(resp.Chunk, castagnoli) != resp.Checksum:
+		return fmt.Errorf("Chunk at offset %d does not match its checksum", offset)
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package node
+
+import (
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+/*
+A node that kept orphans aside asks the peer that sent them for their missing
+ancestors with a FetchRequest, instead of waiting for the next full syncs to
+fill the gap. The request references the missing parents like the orphans do,
+by creator id and index, and carries the Known map of the node so that the peer
+only returns the ancestors it does not have, oldest first, up to its SyncLimit.
+The Events received are inserted without creating a new head, then the orphans
+whose parents arrived.
+
+Only peers that advertise CapFetch are asked.
+*/
+
+//fetchParents fetches the missing ancestors of the orphans received from peer
+func (n *Node) fetchParents(peer string) error {
+	if !n.peerSupports(peer, net.CapFetch) {
+		return nil
+	}
+	orphans := n.orphans.startFetch(peer)
+	if len(orphans) == 0 {
+		return nil
+	}
+	defer n.orphans.endFetch(peer)
+
+	n.coreLock.RLock()
+	parents := n.core.MissingParents(orphans)
+	known := n.core.Known()
+	n.coreLock.RUnlock()
+	if len(parents) == 0 {
+		return nil
+	}
+
+	args := net.FetchRequest{
+		From:    n.localAddr,
+		Parents: parents,
+		Known:   known,
+	}
+	var out net.FetchResponse
+	start := time.Now()
+	err := n.trans.Fetch(peer, &args, &out)
+	n.recordSync(peer, "fetch", start, err)
+	if err != nil {
+		n.logger.WithField("error", err).Error("requestFetch()")
+		return err
+	}
+	n.logger.WithFields(logrus.Fields{
+		"from":    peer,
+		"parents": len(parents),
+		"events":  len(out.Events),
+	}).Debug("FetchResponse")
+
+	return n.insertFetched(peer, out.Events)
+}
+
+//insertFetched inserts fetched Events, or the first batches of a push, then the
+//orphans that were waiting for them, without creating a new head
+func (n *Node) insertFetched(from string, events []hg.WireEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	n.metrics.synced(len(events))
+	n.knowledge.record(from, events)
+
+	n.inserts.acquire(liveInsert)
+	defer n.inserts.release()
+	n.waitCPUBudget()
+	n.coreLock.Lock()
+	defer n.coreLock.Unlock()
+	start := time.Now()
+	defer func() { n.cpuBudget.spent(time.Since(start)) }()
+
+	n.traffic.received(from, events, n.countDuplicates(events))
+	ready := n.splitOrphans(from, events)
+	if err := n.backfill(ready); err != nil {
+		return err
+	}
+	return n.insertOrphans()
+}
+
+func (n *Node) processFetchRequest(rpc net.RPC, cmd *net.FetchRequest) {
+	n.logger.WithFields(logrus.Fields{
+		"from":    cmd.From,
+		"hashes":  len(cmd.Hashes),
+		"parents": len(cmd.Parents),
+	}).Debug("process FetchRequest")
+
+	resp := &net.FetchResponse{
+		From: n.localAddr,
+	}
+
+	n.coreLock.RLock()
+	events := n.core.Fetch(cmd.Hashes, cmd.Parents, cmd.Known, n.syncLimit())
+	n.coreLock.RUnlock()
+
+	wireEvents, err := n.core.ToWire(events)
+	if err == nil {
+		resp.Events = truncateWireEvents(wireEvents, n.conf.SyncBytesLimit)
+		n.traffic.sent(cmd.From, resp.Events)
+	}
+
+	n.logger.WithFields(logrus.Fields{
+		"Events": len(resp.Events),
+		"Error":  err,
+	}).Debug("Responding to FetchRequest")
+	rpc.Respond(resp, err)
+}
@@ -0,0 +1,58 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/net"
+)
+
+func TestFetchParents(t *testing.T) {
+	_, nodes := initNodes(4, 1000, common.NewTestLogger(t))
+	defer shutdownNodes(nodes)
+	//the nodes share the same Config
+	nodes[0].conf.Capabilities = net.CapFetch
+	nodes[0].conf.OrphanRounds = 10
+
+	//nodes[0] does not gossip
+	if err := gossip(nodes[1:], 5, false, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	peer := nodes[1].localAddr
+	nodes[0].setPeerCapabilities(peer, net.CapFetch)
+
+	//it receives the Events of nodes[1] without the first one
+	nodes[0].coreLock.RLock()
+	known := nodes[0].core.Known()
+	nodes[0].coreLock.RUnlock()
+	nodes[1].coreLock.RLock()
+	diff, err := nodes[1].core.Diff(known)
+	nodes[1].coreLock.RUnlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wire, err := nodes[1].core.ToWire(diff)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes[0].insert(peer, wire[1:]); err != nil {
+		t.Fatal(err)
+	}
+	if orphans, _ := nodes[0].orphans.stats(); orphans == 0 {
+		t.Fatal("Events without their first ancestor should be orphans")
+	}
+
+	if err := nodes[0].fetchParents(peer); err != nil {
+		t.Fatal(err)
+	}
+	if orphans, _ := nodes[0].orphans.stats(); orphans != 0 {
+		t.Fatalf("Orphans should be inserted once their parents are fetched, %d left", orphans)
+	}
+	nodes[0].coreLock.RLock()
+	defer nodes[0].coreLock.RUnlock()
+	last := wire[len(wire)-1].Body
+	if known := nodes[0].core.Known(); known[last.CreatorID] < last.Index {
+		t.Fatalf("The last Event received should be inserted, known %v", known)
+	}
+}
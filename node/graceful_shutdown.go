@@ -0,0 +1,69 @@
+package node
+
+import (
+	"time"
+)
+
+/*
+Shutdown closes the transport straight away: the syncs in flight fail on both
+sides, and the peers that were waiting on the node only learn about it when
+their requests time out. GracefulShutdown drains the node first:
+
+ 1. it stops starting gossip routines, and refuses new requests with
+    ErrorStopping, which tells the peers to back off from the node without
+    waiting for a timeout,
+ 2. it waits for its own syncs and for the inbound syncs it already accepted
+    to finish, for at most timeout,
+ 3. it then shuts down as usual: the transport is closed and the store is
+    flushed and closed.
+
+A call made while the node is already draining shuts it down straight away, so
+that a second signal does not wait for the first drain.
+*/
+
+//drainPollInterval is how often GracefulShutdown checks for in-flight syncs
+const drainPollInterval = 10 * time.Millisecond
+
+//GracefulShutdown shuts the node down after the syncs in flight finish, or
+//timeout expires
+func (n *Node) GracefulShutdown(timeout time.Duration) {
+	if !n.startDraining() {
+		n.Shutdown()
+		return
+	}
+	n.logger.WithField("timeout", timeout).Info("Draining")
+	start := time.Now()
+
+	//the gossip routines that started before draining
+	routines := make(chan struct{})
+	go func() {
+		n.waitRoutines()
+		close(routines)
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for !n.drained(routines) {
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			n.logger.Warn("Drain timed out")
+			n.Shutdown()
+			return
+		}
+	}
+	n.logger.WithField("duration", time.Since(start)).Info("Drained")
+	n.Shutdown()
+}
+
+//drained is true once routines is closed and no inbound sync is left
+func (n *Node) drained(routines <-chan struct{}) bool {
+	select {
+	case <-routines:
+		return n.syncQueue.idle()
+	default:
+		return false
+	}
+}
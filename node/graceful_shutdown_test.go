@@ -0,0 +1,52 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/net"
+)
+
+func TestGracefulShutdown(t *testing.T) {
+	_, nodes := initNodes(2, 1000, common.NewTestLogger(t))
+	defer shutdownNodes(nodes)
+	node := nodes[0]
+
+	//an accepted sync that no worker processes holds the drain until timeout
+	if !node.syncQueue.push("peer", syncRPC("peer", 1)) {
+		t.Fatal("Sync should be queued")
+	}
+	done := make(chan time.Duration)
+	go func() {
+		start := time.Now()
+		node.GracefulShutdown(200 * time.Millisecond)
+		done <- time.Since(start)
+	}()
+
+	//new requests are refused while draining
+	time.Sleep(50 * time.Millisecond)
+	if node.getState() == Shutdown {
+		t.Fatal("Node should drain before shutting down")
+	}
+	respCh := make(chan net.RPCResponse, 1)
+	node.processRPC(net.RPC{Command: &net.SyncRequest{From: "peer"}, RespChan: respCh})
+	resp := (<-respCh).Response.(*net.SyncResponse)
+	if resp.ErrorCode != net.ErrorStopping {
+		t.Fatalf("Request while draining should get %s, not %s", net.ErrorStopping, resp.ErrorCode)
+	}
+
+	if d := <-done; d < 200*time.Millisecond {
+		t.Fatalf("Drain should wait for the queued sync until timeout, took %s", d)
+	}
+	if s := node.getState(); s != Shutdown {
+		t.Fatalf("Node should be shut down, not %s", s)
+	}
+
+	//without syncs in flight, the node shuts down straight away
+	start := time.Now()
+	nodes[1].GracefulShutdown(time.Second)
+	if d := time.Since(start); d > 500*time.Millisecond || nodes[1].getState() != Shutdown {
+		t.Fatalf("Idle node should shut down at once, took %s", d)
+	}
+}
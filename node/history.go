@@ -0,0 +1,227 @@
+package node
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/babbleio/babble/codec"
+	"github.com/babbleio/babble/crypto"
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+/*
+A cluster that replaces a legacy system can start from its history. The ordered
+transaction log of the legacy system is turned into a History: pre-genesis
+Blocks, with RoundReceived -1, and a synthetic Frame listing the genesis
+participants, with base Roots and no Events.
+
+On its first start, after InitChain, the node commits the Blocks of the History
+to the App, in order, and signs them like any other Block. The Blocks of
+consensus are numbered after them. Since the genesis validators sign the
+pre-genesis Blocks with the StateHash returned by their App, the imported
+history becomes final and verifiable the same way as the rest of the chain.
+
+The Hash of a History chains the hashes of its Blocks, starting from the
+participants of its Frame. Every node must import the same History, which
+operators check by comparing its Hash, or by pinning it in Config.HistoryHash.
+*/
+
+//historyVersion is incremented when the format of History changes
+const historyVersion = 1
+
+//History is an external transaction log imported as the Blocks that precede
+//the first Block of consensus
+type History struct {
+	Version int
+	Blocks  []hg.Block //Index from 0, RoundReceived -1
+	Frame   hg.Frame   //genesis participants, with base Roots and no Events
+	Hash    []byte
+}
+
+//NewHistory returns the History of the transaction log txs, one batch of
+//transactions per Block, for a cluster of peers. Empty batches are skipped.
+func NewHistory(txs [][][]byte, peers []net.Peer) (History, error) {
+	sorted := make([]net.Peer, len(peers))
+	copy(sorted, peers)
+	sort.Sort(net.ByPubKey(sorted))
+
+	frame := hg.Frame{Roots: make(map[string]hg.Root)}
+	for id, p := range sorted {
+		frame.Roots[p.PubKeyHex] = hg.NewBaseRoot()
+		frame.Participants = append(frame.Participants, hg.Participant{
+			PubKey: p.PubKeyHex,
+			ID:     id,
+			Round:  -1,
+			Until:  -1,
+		})
+	}
+
+	history := History{Version: historyVersion, Frame: frame}
+	for _, batch := range txs {
+		if len(batch) == 0 {
+			continue
+		}
+		history.Blocks = append(history.Blocks, hg.NewBlock(len(history.Blocks), -1, batch))
+	}
+	hash, err := history.ComputeHash()
+	if err != nil {
+		return History{}, err
+	}
+	history.Hash = hash
+	return history, nil
+}
+
+//ComputeHash returns the hash of the participants of the Frame chained with
+//the hashes of the Blocks, without their StateHash and signatures
+func (h *History) ComputeHash() ([]byte, error) {
+	hash, err := codec.Marshal(codec.Gob, h.Frame.Participants)
+	if err != nil {
+		return nil, err
+	}
+	hash = crypto.SHA256(hash)
+	for _, b := range h.Blocks {
+		body := hg.NewBlock(b.Index, b.RoundReceived, b.Transactions)
+		bh, err := body.Hash()
+		if err != nil {
+			return nil, err
+		}
+		hash = crypto.SHA256(append(hash, bh...))
+	}
+	return hash, nil
+}
+
+//Verify checks that the Blocks are numbered from 0, precede the genesis, and
+//match the Hash
+func (h *History) Verify() error {
+	if h.Version != historyVersion {
+		return fmt.Errorf("Unsupported history version %d", h.Version)
+	}
+	for i, b := range h.Blocks {
+		if b.Index != i || b.RoundReceived != -1 {
+			return fmt.Errorf("Block %d of the history has index %d and round %d", i, b.Index, b.RoundReceived)
+		}
+		if len(b.Transactions) == 0 {
+			return fmt.Errorf("Block %d of the history is empty", i)
+		}
+	}
+	hash, err := h.ComputeHash()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(hash, h.Hash) {
+		return fmt.Errorf("History hash %X does not match its Blocks", h.Hash)
+	}
+	return nil
+}
+
+//Transactions returns the number of transactions in the Blocks
+func (h *History) Transactions() int {
+	txs := 0
+	for _, b := range h.Blocks {
+		txs += len(b.Transactions)
+	}
+	return txs
+}
+
+//EncodeHistory writes history to w in the format read by ReadHistory
+func EncodeHistory(w io.Writer, history History) error {
+	return codec.Gob.NewEncoder(w).Encode(history)
+}
+
+//ReadHistory reads and verifies a History written by EncodeHistory
+func ReadHistory(r io.Reader) (History, error) {
+	var history History
+	if err := codec.Gob.NewDecoder(r).Decode(&history); err != nil {
+		return History{}, err
+	}
+	if err := history.Verify(); err != nil {
+		return History{}, err
+	}
+	return history, nil
+}
+
+//ReadTransactionLog reads an ordered transaction log with one batch of
+//transactions per line, as a JSON list of base64 strings:
+//
+//	["dHgx","dHgy"]
+//	["dHgz"]
+//
+//Blank lines are skipped.
+func ReadTransactionLog(r io.Reader) ([][][]byte, error) {
+	txs := [][][]byte{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+		var batch [][]byte
+		if err := json.Unmarshal(text, &batch); err != nil {
+			return nil, fmt.Errorf("Line %d: %s", line, err)
+		}
+		txs = append(txs, batch)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+//importHistory commits the Blocks of the History of the Config to the App, on
+//the first start, after the genesis state
+func (n *Node) importHistory() error {
+	history := n.conf.History
+	if history == nil {
+		return nil
+	}
+	if err := history.Verify(); err != nil {
+		return err
+	}
+	if n.conf.HistoryHash != nil && !bytes.Equal(n.conf.HistoryHash, history.Hash) {
+		return fmt.Errorf("History hash is %X, expected %X", history.Hash, n.conf.HistoryHash)
+	}
+
+	//the history must be the one of this cluster
+	n.coreLock.RLock()
+	genesis := []string{}
+	for _, p := range n.core.hg.GetParticipants() {
+		if p.Round < 0 {
+			genesis = append(genesis, p.PubKey)
+		}
+	}
+	n.coreLock.RUnlock()
+	if len(genesis) != len(history.Frame.Participants) {
+		return fmt.Errorf("History has %d participants, the cluster %d", len(history.Frame.Participants), len(genesis))
+	}
+	for i, p := range history.Frame.Participants {
+		if p.PubKey != genesis[i] {
+			return fmt.Errorf("Participant %s of the history is not in the cluster", p.PubKey)
+		}
+	}
+
+	n.commitLock.Lock()
+	defer n.commitLock.Unlock()
+	if n.blockIndex != 0 {
+		return fmt.Errorf("History imported after Block %d", n.blockIndex-1)
+	}
+	for _, b := range history.Blocks {
+		if err := n.commitBlock(b.RoundReceived, b.Transactions, nil); err != nil {
+			return fmt.Errorf("Importing history Block %d: %s", b.Index, err)
+		}
+	}
+	n.logger.WithFields(logrus.Fields{
+		"blocks": len(history.Blocks),
+		"txs":    history.Transactions(),
+		"hash":   fmt.Sprintf("%X", history.Hash),
+	}).Info("Imported history")
+	return nil
+}
@@ -0,0 +1,115 @@
+package node
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+func TestReadTransactionLog(t *testing.T) {
+	log := "[\"YQ==\",\"Yg==\"]\n\n[]\n[\"Yw==\"]\n"
+	txs, err := ReadTransactionLog(strings.NewReader(log))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][][]byte{{[]byte("a"), []byte("b")}, {}, {[]byte("c")}}
+	if !reflect.DeepEqual(txs, expected) {
+		t.Fatalf("Log should read %q, not %q", expected, txs)
+	}
+	if _, err := ReadTransactionLog(strings.NewReader("[\"YQ==\"]\nabc\n")); err == nil {
+		t.Fatal("Invalid line should be refused")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	_, peers := initPeers(3)
+	txs := [][][]byte{{[]byte("a"), []byte("b")}, {}, {[]byte("c")}}
+	history, err := NewHistory(txs, peers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history.Blocks) != 2 || history.Blocks[1].Index != 1 || history.Blocks[1].RoundReceived != -1 {
+		t.Fatalf("History should have 2 pre-genesis Blocks, got %v", history.Blocks)
+	}
+	if len(history.Frame.Participants) != 3 || len(history.Frame.Roots) != 3 || len(history.Frame.Events) != 0 {
+		t.Fatalf("Frame should list 3 participants without Events, got %+v", history.Frame)
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeHistory(&buf, history); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadHistory(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(read.Hash, history.Hash) {
+		t.Fatalf("Hash should be %X, not %X", history.Hash, read.Hash)
+	}
+
+	//the hash covers the transactions and the participants
+	tampered := history
+	tampered.Blocks = []hg.Block{history.Blocks[0], hg.NewBlock(1, -1, [][]byte{[]byte("d")})}
+	if err := tampered.Verify(); err == nil {
+		t.Fatal("History with another transaction should be invalid")
+	}
+	other, _ := NewHistory(txs, peers[:2])
+	if bytes.Equal(other.Hash, history.Hash) {
+		t.Fatal("Histories of other participants should have another hash")
+	}
+}
+
+func TestImportHistory(t *testing.T) {
+	keys, peers := initPeers(1)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	history, err := NewHistory([][][]byte{{[]byte("a")}, {[]byte("b"), []byte("c")}}, peers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf := TestConfig(t)
+	conf.GenesisState = []byte("genesis")
+	conf.History = &history
+	conf.HistoryHash = history.Hash
+	prox := &blockProxy{submitCh: make(chan []byte)}
+	node := NewNode(conf, keys[0], peers, trans, prox)
+	if err := node.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(prox.genesis) != 1 || !reflect.DeepEqual(prox.blocks, history.Blocks) {
+		t.Fatalf("App should receive the genesis state then the history, got %q and %v", prox.genesis, prox.blocks)
+	}
+	if node.blockIndex != 2 {
+		t.Fatalf("The first Block of consensus should be 2, not %d", node.blockIndex)
+	}
+	if _, final := node.blocks.stats(); final != 1 {
+		t.Fatalf("History Blocks should be signed by the genesis validator, last final is %d", final)
+	}
+}
+
+func TestImportHistoryOtherCluster(t *testing.T) {
+	keys, peers := initPeers(1)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	_, others := initPeers(1)
+	history, _ := NewHistory([][][]byte{{[]byte("a")}}, others)
+	conf := TestConfig(t)
+	conf.History = &history
+	prox := &blockProxy{submitCh: make(chan []byte)}
+	node := NewNode(conf, keys[0], peers, trans, prox)
+	if err := node.Init(); err == nil {
+		t.Fatal("History of another cluster should be refused")
+	}
+	if len(prox.blocks) != 0 {
+		t.Fatalf("No Block should be committed, got %v", prox.blocks)
+	}
+}
@@ -0,0 +1,154 @@
+package node
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	hg "github.com/babbleio/babble/hashgraph"
+)
+
+/*
+The Events received from peers are inserted in the hashgraph in turns handed
+out by an insertQueue. Live batches, the few Events a peer created since the
+last sync, extend the rounds that are still undecided and always go first.
+Backfill batches, the long histories sent to a node that is catching up, are
+inserted Config.InsertChunk Events at a time, with a run of the consensus
+methods after every chunk, and go back to the end of the queue in between. A
+node that is backfilling therefore keeps deciding rounds with the Events it has
+already inserted, and the live syncs of its other peers do not wait for the
+whole backlog.
+
+A live batch never depends on the rest of a backfill batch: a peer sends
+everything that follows what the node knows, so any Event of the backfill that
+it still needs is in the live batch too, and the next chunks of the backfill
+skip it.
+*/
+
+type insertPriority int
+
+const (
+	liveInsert insertPriority = iota
+	backfillInsert
+)
+
+func (p insertPriority) String() string {
+	switch p {
+	case liveInsert:
+		return "Live"
+	case backfillInsert:
+		return "Backfill"
+	default:
+		return "Unknown"
+	}
+}
+
+type insertQueue struct {
+	l        sync.Mutex
+	busy     bool
+	lanes    [2][]chan struct{} //[priority] => callers waiting for their turn
+	inserted [2]int             //[priority] => chunks inserted
+}
+
+func newInsertQueue() *insertQueue {
+	return &insertQueue{}
+}
+
+//acquire blocks until it is the caller's turn to insert a chunk of Events
+func (q *insertQueue) acquire(p insertPriority) {
+	q.l.Lock()
+	if !q.busy {
+		q.busy = true
+		q.inserted[p]++
+		q.l.Unlock()
+		return
+	}
+	turn := make(chan struct{})
+	q.lanes[p] = append(q.lanes[p], turn)
+	q.l.Unlock()
+	<-turn
+}
+
+//release hands the turn to the first caller waiting with the highest priority
+func (q *insertQueue) release() {
+	q.l.Lock()
+	defer q.l.Unlock()
+	for p, lane := range q.lanes {
+		if len(lane) == 0 {
+			continue
+		}
+		q.lanes[p] = lane[1:]
+		q.inserted[p]++
+		close(lane[0])
+		return
+	}
+	q.busy = false
+}
+
+//stats returns the number of callers waiting and of chunks inserted, by
+//priority
+func (q *insertQueue) stats() (waiting [2]int, inserted [2]int) {
+	q.l.Lock()
+	defer q.l.Unlock()
+	for p, lane := range q.lanes {
+		waiting[p] = len(lane)
+	}
+	return waiting, q.inserted
+}
+
+//insert adds a batch of Events received from a peer to the hashgraph and
+//runs the consensus methods. A batch larger than Config.InsertChunk is
+//backfill and is inserted a chunk at a time; the last chunk creates the new
+//head like a regular sync.
+func (n *Node) insert(from string, events []hg.WireEvent) error {
+	n.metrics.synced(len(events))
+	n.knowledge.record(from, events)
+
+	chunkSize := n.conf.InsertChunk
+	if chunkSize <= 0 {
+		chunkSize = len(events)
+	}
+	priority := liveInsert
+	if len(events) > chunkSize {
+		priority = backfillInsert
+		n.logger.WithFields(logrus.Fields{
+			"from":   from,
+			"events": len(events),
+		}).Debug("Backfilling")
+	}
+
+	for {
+		chunk := events
+		if len(chunk) > chunkSize {
+			chunk = events[:chunkSize]
+		}
+		last := len(chunk) == len(events)
+
+		n.inserts.acquire(priority)
+		n.waitCPUBudget()
+		n.coreLock.Lock()
+		start := time.Now()
+		n.traffic.received(from, chunk, n.countDuplicates(chunk))
+		ready := n.splitOrphans(from, chunk)
+		var err error
+		if last {
+			err = n.sync(ready)
+			if err == nil || err == errStaleSync {
+				if orphansErr := n.insertOrphans(); orphansErr != nil {
+					err = orphansErr
+				}
+			}
+		} else {
+			err = n.backfill(ready)
+		}
+		n.cpuBudget.spent(time.Since(start))
+		n.coreLock.Unlock()
+		n.inserts.release()
+
+		if err != nil || last {
+			return err
+		}
+		events = events[len(chunk):]
+	}
+}
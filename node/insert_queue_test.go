@@ -0,0 +1,69 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+)
+
+func TestInsertQueuePriority(t *testing.T) {
+	q := newInsertQueue()
+	q.acquire(backfillInsert)
+
+	//while a chunk is inserted, a backfill and then a live batch wait
+	order := make(chan insertPriority, 2)
+	wait := func(p insertPriority) {
+		q.acquire(p)
+		order <- p
+		q.release()
+	}
+	go wait(backfillInsert)
+	for {
+		if waiting, _ := q.stats(); waiting[backfillInsert] == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	go wait(liveInsert)
+	for {
+		if waiting, _ := q.stats(); waiting[liveInsert] == 1 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	q.release()
+	for _, expected := range []insertPriority{liveInsert, backfillInsert} {
+		if p := <-order; p != expected {
+			t.Fatalf("%s batch should be inserted before %s", expected, p)
+		}
+	}
+
+	if _, inserted := q.stats(); inserted[liveInsert] != 1 || inserted[backfillInsert] != 2 {
+		t.Fatalf("1 live and 2 backfill chunks should be inserted, not %v", inserted)
+	}
+}
+
+func TestBackfill(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	_, nodes := initNodes(4, 1000, logger)
+	defer shutdownNodes(nodes)
+	//the nodes share their Config
+	nodes[0].conf.InsertChunk = 10
+
+	target := 10
+	if err := gossip(nodes[1:], target, false, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	//node 0 receives the whole history in its first sync
+	nodes[0].RunAsync(true)
+	if err := bombardAndWait(nodes, target+10, 6*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if chunks := nodes[0].GetStats()["backfill_chunks"]; chunks == "0" {
+		t.Fatalf("Node 0 should have backfilled its history")
+	}
+	checkGossip(nodes, t)
+}
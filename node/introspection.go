@@ -0,0 +1,95 @@
+package node
+
+import (
+	"time"
+
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+//Status summarises the state of a node, for dashboards and for debugging a
+//cluster that does not make progress
+type Status struct {
+	State              string
+	ID                 int
+	PubKey             string
+	LastRound          int  //last round of the hashgraph
+	LastConsensusRound *int //nil until a round is decided
+	Known              map[int]int
+	Peers              []net.Peer
+	TransactionPool    int
+	UndeterminedEvents int
+	CommittedWatermark int  //index of the last Block committed to the App, -1 if none
+	DiskPressure       bool //the node refuses transactions for lack of disk space
+}
+
+//EventInfo describes an Event of the hashgraph and what the node decided
+//about it
+type EventInfo struct {
+	Hash          string
+	Creator       string
+	Index         int
+	SelfParent    string
+	OtherParent   string
+	Timestamp     time.Time
+	Transactions  [][]byte
+	Round         int
+	Witness       bool
+	RoundReceived int //-1 until the Event reaches consensus
+}
+
+func (n *Node) Status() Status {
+	n.coreLock.RLock()
+	s := Status{
+		State:              n.getState().String(),
+		ID:                 n.id,
+		PubKey:             n.core.HexID(),
+		LastRound:          n.core.hg.Store.LastRound(),
+		LastConsensusRound: n.core.GetLastConsensusRoundIndex(),
+		Known:              n.core.Known(),
+		TransactionPool:    len(n.core.transactionPool),
+		UndeterminedEvents: len(n.core.GetUndeterminedEvents()),
+	}
+	n.coreLock.RUnlock()
+	s.CommittedWatermark = n.CommittedWatermark()
+	s.DiskPressure = n.DiskPressure()
+
+	n.selectorLock.Lock()
+	s.Peers = append([]net.Peer{}, n.peerSelector.Peers()...)
+	n.selectorLock.Unlock()
+	return s
+}
+
+//EventInfo looks up the Event with the given hash, in the 0x-prefixed hex
+//format of Event.Hex
+func (n *Node) EventInfo(hash string) (EventInfo, error) {
+	//computing the round fills the caches of the hashgraph
+	n.coreLock.Lock()
+	defer n.coreLock.Unlock()
+
+	ev, err := n.core.GetEvent(hash)
+	if err != nil {
+		return EventInfo{}, err
+	}
+	return EventInfo{
+		Hash:          ev.Hex(),
+		Creator:       ev.Creator(),
+		Index:         ev.Index(),
+		SelfParent:    ev.SelfParent(),
+		OtherParent:   ev.OtherParent(),
+		Timestamp:     ev.Body.Timestamp,
+		Transactions:  ev.Transactions(),
+		Round:         n.core.hg.Round(hash),
+		Witness:       n.core.hg.Witness(hash),
+		RoundReceived: n.core.hg.RoundReceived(hash),
+	}, nil
+}
+
+//ExportGraph copies the rounds from to to of the hashgraph, for visualization,
+//see hashgraph/export.go
+func (n *Node) ExportGraph(from, to int) (hg.Graph, error) {
+	//computing the rounds fills the caches of the hashgraph
+	n.coreLock.Lock()
+	defer n.coreLock.Unlock()
+	return n.core.hg.Export(from, to)
+}
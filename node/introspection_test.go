@@ -0,0 +1,50 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+)
+
+func TestIntrospection(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	_, nodes := initNodes(4, 1000, logger)
+	defer shutdownNodes(nodes)
+
+	if err := gossip(nodes, 5, false, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	node := nodes[0]
+	status := node.Status()
+	if status.LastConsensusRound == nil || *status.LastConsensusRound < 5 {
+		t.Fatalf("Status should report the last consensus round: %+v", status)
+	}
+	if status.LastRound < *status.LastConsensusRound {
+		t.Fatalf("The last round %d should not be before the last consensus round %d",
+			status.LastRound, *status.LastConsensusRound)
+	}
+	if len(status.Known) != 4 || len(status.Peers) != 3 {
+		t.Fatalf("Status should list 4 participants and 3 peers: %+v", status)
+	}
+
+	head, err := node.core.GetEvent(node.core.Head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := node.EventInfo(node.core.Head)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Hash != node.core.Head || info.Creator != node.core.HexID() || info.Index != head.Index() {
+		t.Fatalf("EventInfo should describe the head: %+v", info)
+	}
+	if info.Round < 0 {
+		t.Fatalf("The head should have a round: %+v", info)
+	}
+
+	if _, err := node.EventInfo("0x00"); !common.Is(err, common.KeyNotFound) {
+		t.Fatalf("An unknown Event should not be found, not %v", err)
+	}
+}
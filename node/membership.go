@@ -0,0 +1,345 @@
+package node
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+/*
+A node can join a running cluster without restarting the other nodes. Instead
+of Init, it calls Join with the address of one of the participants, which
+proposes it to the others in a PeerJoin transaction. The JoinRequest is signed
+with the key of the new node, and the PeerJoin carries the signature, so that
+every participant checks that the new node holds its key.
+
+Joining takes the consent of the operators: a participant only proposes or
+endorses the keys admitted on its node, with Config.AdmitKeys or Admit, and
+refuses the JoinRequests of other keys. When the PeerJoin of the participant
+that received the JoinRequest is committed, every participant that admitted
+the key endorses it with a PeerJoin of its own. The hashgraph adds the new
+node once a super-majority of the participants proposed it, and every
+participant then adds it to the peers it gossips with. The participant that
+received the JoinRequest accepts it, and the new node fast-forwards from the
+cluster to start gossiping.
+
+The new node polls the participant until it is accepted. The participant
+proposes the PeerJoin again if it was not committed after joinRetryInterval,
+for example because its Event was lost in a fast-forward, which also gives the
+participants admitting the key late another chance to endorse it.
+
+Participants are removed with a PeerLeave, proposed through ProposeEviction.
+Evicting another participant takes a PeerLeave from a super-majority of the
+other participants, each proposed on its own node, which the hashgraph counts
+in consensus order. Once the hashgraph removed the participant, the other nodes
+stop gossiping with it, and the removed node stops gossiping altogether. A
+removed participant can not join again with the same key.
+*/
+
+const joinRetryInterval = 30 * time.Second
+
+func (n *Node) processJoinRequest(rpc net.RPC, cmd *net.JoinRequest) {
+	n.logger.WithFields(logrus.Fields{
+		"from": cmd.From,
+		"peer": cmd.Peer.NetAddr,
+	}).Debug("process JoinRequest")
+
+	resp := &net.JoinResponse{
+		From: n.localAddr,
+	}
+	join := hg.PeerJoin{
+		PubKey:    cmd.Peer.PubKeyHex,
+		NetAddr:   cmd.Peer.NetAddr,
+		Signature: cmd.Signature,
+	}
+
+	//a node with a key of another algorithm could not verify the Events of
+	//the cluster, nor the cluster its Events
+	algorithm, err := cmd.Peer.Algorithm()
+	if err == nil && algorithm != n.core.key.Algorithm() {
+		err = fmt.Errorf("%s keys are not accepted by a cluster of %s keys", algorithm, n.core.key.Algorithm())
+	}
+	if err == nil && !join.Verify() {
+		err = fmt.Errorf("JoinRequest is not signed by %s", cmd.Peer.PubKeyHex)
+	}
+	if err != nil {
+		rpc.Respond(resp, err)
+		return
+	}
+
+	n.coreLock.Lock()
+	self := net.Peer{NetAddr: n.localAddr, PubKeyHex: n.core.HexID()}
+	accepted := n.core.IsParticipant(cmd.Peer.PubKeyHex)
+	admitted := accepted || n.admits(cmd.Peer.PubKeyHex)
+	if n.core.WasRemoved(cmd.Peer.PubKeyHex) {
+		err = fmt.Errorf("%s was removed from the participants", cmd.Peer.PubKeyHex)
+	} else if admitted && !accepted {
+		err = n.proposeJoin(join)
+	}
+	n.coreLock.Unlock()
+	if err == nil && !admitted {
+		rpc.Respond(resp, fmt.Errorf("%s is not admitted by %s", cmd.Peer.PubKeyHex, n.localAddr))
+		return
+	}
+	if err != nil {
+		n.logger.WithField("error", err).Error("Proposing PeerJoin")
+		rpc.Respond(resp, err)
+		return
+	}
+
+	if accepted {
+		n.joinsLock.Lock()
+		delete(n.pendingJoins, cmd.Peer.PubKeyHex)
+		n.joinsLock.Unlock()
+
+		n.selectorLock.Lock()
+		resp.Peers = append([]net.Peer{}, n.peerSelector.Peers()...)
+		n.selectorLock.Unlock()
+		resp.Peers = append(resp.Peers, self)
+		resp.Accepted = true
+	}
+
+	rpc.Respond(resp, nil)
+}
+
+//proposeJoin adds join to the transaction pool unless a PeerJoin of the same
+//key was proposed recently. Must be called with the coreLock held.
+func (n *Node) proposeJoin(join hg.PeerJoin) error {
+	n.joinsLock.Lock()
+	defer n.joinsLock.Unlock()
+	if proposed, ok := n.pendingJoins[join.PubKey]; ok && time.Since(proposed) < joinRetryInterval {
+		return nil
+	}
+	if err := n.core.ProposePeerJoin(join); err != nil {
+		return err
+	}
+	n.pendingJoins[join.PubKey] = time.Now()
+	n.logger.WithField("peer", join.NetAddr).Info("Proposed PeerJoin")
+	return nil
+}
+
+//admits is true if pubKey was admitted on this node, see Admit
+func (n *Node) admits(pubKey string) bool {
+	n.joinsLock.Lock()
+	defer n.joinsLock.Unlock()
+	return n.admitted[pubKey]
+}
+
+//Admit lets the node with public key pubKey join the cluster, like the keys of
+//Config.AdmitKeys: this node proposes it when it receives its JoinRequest, and
+//endorses the PeerJoins of the other participants for it. The node is added
+//once a super-majority of the participants admitted it.
+func (n *Node) Admit(pubKey string) error {
+	p := net.Peer{PubKeyHex: pubKey}
+	if _, err := p.Algorithm(); err != nil {
+		return err
+	}
+	n.joinsLock.Lock()
+	n.admitted[pubKey] = true
+	join, proposed := n.candidates[pubKey]
+	delete(n.candidates, pubKey)
+	n.joinsLock.Unlock()
+	n.logger.WithField("pub_key", pubKey).Info("Admitted")
+
+	//endorse the PeerJoins committed before the key was admitted
+	if !proposed {
+		return nil
+	}
+	n.coreLock.Lock()
+	defer n.coreLock.Unlock()
+	if n.core.IsParticipant(pubKey) || n.core.WasRemoved(pubKey) {
+		return nil
+	}
+	return n.proposeJoin(join)
+}
+
+//commitPeerJoin starts gossiping with a participant added by consensus
+func (n *Node) commitPeerJoin(join hg.PeerJoin) {
+	if join.NetAddr == n.localAddr {
+		return
+	}
+	n.selectorLock.Lock()
+	peer := net.Peer{
+		NetAddr:   join.NetAddr,
+		PubKeyHex: join.PubKey,
+	}
+	n.peerSelector.AddPeer(peer)
+	n.selectorLock.Unlock()
+	n.learnPeer(peer, net.SourceJoin)
+
+	n.logger.WithFields(logrus.Fields{
+		"peer":    join.NetAddr,
+		"pub_key": join.PubKey,
+	}).Info("Peer joined")
+}
+
+//commitMembership applies a committed PeerJoin or PeerLeave to the peers of
+//the node. Both only count as proposals until the hashgraph adds or removes the
+//participant, and the node endorses the PeerJoins of the keys it admitted. It
+//returns false if tx is not a membership transaction.
+func (n *Node) commitMembership(tx []byte) bool {
+	if join, ok := hg.ReadPeerJoin(tx); ok {
+		var err error
+		n.coreLock.Lock()
+		joined := n.core.IsParticipant(join.PubKey)
+		if !joined && !n.core.WasRemoved(join.PubKey) && join.Verify() {
+			if n.admits(join.PubKey) {
+				err = n.proposeJoin(join)
+			} else {
+				n.joinsLock.Lock()
+				n.candidates[join.PubKey] = join
+				n.joinsLock.Unlock()
+			}
+		}
+		n.coreLock.Unlock()
+		if err != nil {
+			n.logger.WithField("error", err).Error("Endorsing PeerJoin")
+		}
+		if joined {
+			n.joinsLock.Lock()
+			delete(n.pendingJoins, join.PubKey)
+			delete(n.candidates, join.PubKey)
+			n.joinsLock.Unlock()
+			n.commitPeerJoin(join)
+		}
+		return true
+	}
+	if leave, ok := hg.ReadPeerLeave(tx); ok {
+		n.coreLock.RLock()
+		removed := n.core.WasRemoved(leave.PubKey)
+		n.coreLock.RUnlock()
+		if removed {
+			n.commitPeerLeave(leave)
+		}
+		return true
+	}
+	return false
+}
+
+//commitPeerLeave stops gossiping with a participant removed by consensus
+func (n *Node) commitPeerLeave(leave hg.PeerLeave) {
+	self := n.core.HexID()
+	if leave.PubKey == self {
+		n.logger.Warn("This node was removed from the participants and stops gossiping")
+		return
+	}
+
+	n.selectorLock.Lock()
+	for _, p := range n.peerSelector.Peers() {
+		if p.PubKeyHex == leave.PubKey {
+			n.connectivity.forget(p)
+		}
+	}
+	n.peerSelector.RemovePeer(leave.PubKey)
+	n.selectorLock.Unlock()
+	n.forgetPeer(leave.PubKey)
+
+	n.logger.WithField("pub_key", leave.PubKey).Info("Peer left")
+}
+
+//IsParticipant is true if pubKey is a participant of the hashgraph, including
+//the participants that joined at runtime
+func (n *Node) IsParticipant(pubKey string) bool {
+	n.coreLock.RLock()
+	defer n.coreLock.RUnlock()
+	return n.core.IsParticipant(pubKey)
+}
+
+//ProposeEviction proposes to remove a participant. The participant stops
+//counting, and the other nodes stop gossiping with it, once a super-majority of
+//the other participants proposed it. A node can propose its own removal to
+//leave the cluster, which takes effect on its own.
+func (n *Node) ProposeEviction(pubKey string) error {
+	n.coreLock.Lock()
+	err := n.proposeLeave(pubKey)
+	n.coreLock.Unlock()
+	if err != nil {
+		return err
+	}
+	n.logger.WithField("pub_key", pubKey).Info("Proposed PeerLeave")
+
+	//gossip the proposal even if the node was idle
+	select {
+	case n.controlTimer.resetCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+//must be called with the coreLock held
+func (n *Node) proposeLeave(pubKey string) error {
+	if !n.core.IsParticipant(pubKey) {
+		return fmt.Errorf("%s is not a participant", pubKey)
+	}
+	return n.core.ProposePeerLeave(hg.PeerLeave{PubKey: pubKey})
+}
+
+//Join makes this node a participant of the cluster that target is part of. It
+//replaces Init and blocks, in the Joining state, until target accepts the node
+//or timeout expires. The node then fast-forwards from the cluster when it runs.
+func (n *Node) Join(target string, timeout time.Duration) error {
+	if n.confErr != nil {
+		return n.confErr
+	}
+	if err := n.setState(Joining); err != nil {
+		return err
+	}
+	if err := n.join(target, timeout); err != nil {
+		n.casState(Joining, Babbling)
+		return err
+	}
+	return n.casState(Joining, CatchingUp)
+}
+
+func (n *Node) join(target string, timeout time.Duration) error {
+	join := hg.PeerJoin{PubKey: n.core.HexID(), NetAddr: n.localAddr}
+	if err := join.Sign(n.core.key); err != nil {
+		return err
+	}
+	args := net.JoinRequest{
+		From: n.localAddr,
+		Peer: net.Peer{
+			NetAddr:   n.localAddr,
+			PubKeyHex: n.core.HexID(),
+		},
+		Signature: join.Signature,
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var out net.JoinResponse
+		err := n.trans.Join(target, &args, &out)
+		if err != nil {
+			n.logger.WithField("error", err).Debug("requestJoin()")
+		} else if out.Accepted {
+			n.selectorLock.Lock()
+			for _, p := range out.Peers {
+				if p.NetAddr != n.localAddr {
+					n.peerSelector.AddPeer(p)
+				}
+			}
+			n.selectorLock.Unlock()
+			for _, p := range out.Peers {
+				n.learnPeer(p, net.SourceJoin)
+			}
+
+			n.logger.WithField("peers", len(out.Peers)).Info("Joined")
+			//the snapshot of the App received with the Frame replaces the
+			//genesis state once the cluster has committed Blocks
+			return n.initChain()
+		}
+
+		if time.Now().After(deadline) {
+			return fmt.Errorf("Not accepted by %s after %s", target, timeout)
+		}
+		select {
+		case <-time.After(n.heartbeat()):
+		case <-n.shutdownCh:
+			return fmt.Errorf("Shutdown while joining")
+		}
+	}
+}
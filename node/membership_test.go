@@ -0,0 +1,216 @@
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/crypto"
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestJoin(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	conf := NewConfig(5*time.Millisecond, time.Second, 1000, 1000, logger)
+	conf.Seed = common.TestSeed()
+
+	keys, allPeers := initPeers(4)
+	keyByPeer := make(map[string]crypto.KeyPair)
+	for _, k := range keys {
+		keyByPeer[crypto.KeyPairPubKeyHex(k)] = k
+	}
+	//the last peer is not part of the initial cluster
+	peers := allPeers[:3]
+
+	nodes := []*Node{}
+	for i, p := range allPeers {
+		trans, err := net.NewTCPTransport(p.NetAddr, nil, 2, time.Second, nil, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node := NewNode(conf, keyByPeer[p.PubKeyHex], peers, trans, aproxy.NewInmemAppProxy(logger))
+		if i < len(peers) {
+			node.Init()
+		}
+		nodes = append(nodes, &node)
+	}
+	defer shutdownNodes(nodes)
+	cluster, joiner := nodes[:3], nodes[3]
+
+	if err := gossip(cluster, 5, false, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	//the cluster needs transactions to reach consensus on the PeerJoin
+	quit := make(chan struct{})
+	makeRandomTransactions(cluster, quit)
+
+	joinerKey := joiner.core.HexID()
+	if err := joiner.join(peers[0].NetAddr, 100*time.Millisecond); err == nil {
+		t.Fatal("A node that was not admitted should not join")
+	}
+
+	//2 participants out of 3 are not a super-majority
+	for _, n := range cluster[:2] {
+		if err := n.Admit(joinerKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := joiner.join(peers[0].NetAddr, time.Second); err == nil {
+		t.Fatal("A node admitted by 2 participants out of 3 should not join")
+	}
+	for i, n := range cluster {
+		if n.IsParticipant(joinerKey) {
+			t.Fatalf("nodes[%d] should not add a node admitted by 2 participants out of 3", i)
+		}
+	}
+
+	if err := cluster[2].Admit(joinerKey); err != nil {
+		t.Fatal(err)
+	}
+	err := joiner.Join(peers[0].NetAddr, 5*time.Second)
+	close(quit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if joiner.getState() != CatchingUp {
+		t.Fatalf("The new node should be CatchingUp, not %s", joiner.getState())
+	}
+
+	//the cluster went on while the new node waited for its admission
+	cluster[0].coreLock.RLock()
+	target := *cluster[0].core.GetLastConsensusRoundIndex() + 40
+	cluster[0].coreLock.RUnlock()
+
+	joiner.RunAsync(true)
+	if err := bombardAndWait(nodes, target, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	joinerID := joiner.core.ID()
+	for i, n := range cluster {
+		n.coreLock.Lock()
+		participants := len(n.core.hg.Participants)
+		known := n.core.Known()[joinerID]
+		n.coreLock.Unlock()
+		if participants != 4 {
+			t.Fatalf("nodes[%d] should have 4 participants, not %d", i, participants)
+		}
+		if known < 0 {
+			t.Fatalf("nodes[%d] should have received Events from the new node", i)
+		}
+
+		n.selectorLock.Lock()
+		selectable := len(n.peerSelector.Peers())
+		n.selectorLock.Unlock()
+		if selectable != 3 {
+			t.Fatalf("nodes[%d] should gossip with 3 peers, not %d", i, selectable)
+		}
+	}
+	checkGossip(cluster, t)
+}
+
+func TestEvict(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	_, nodes := initNodes(4, 1000, logger)
+	defer shutdownNodes(nodes)
+
+	if err := gossip(nodes, 5, false, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	evicted := nodes[3].core.HexID()
+	if err := nodes[0].ProposeEviction(evicted); err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes[0].ProposeEviction("0x04FF"); err == nil {
+		t.Fatal("Evicting an unknown participant should fail")
+	}
+
+	//a single proposal is not enough
+	if err := bombardAndWait(nodes, 15, 6*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range nodes {
+		if !n.IsParticipant(evicted) {
+			t.Fatalf("nodes[%d] should not remove a participant on a single proposal", i)
+		}
+	}
+
+	//the other 3 nodes are a super-majority
+	for _, n := range nodes[1:3] {
+		if err := n.ProposeEviction(evicted); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//the 3 other nodes keep going without the evicted one
+	remaining := nodes[:3]
+	if err := bombardAndWait(remaining, 40, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, n := range remaining {
+		n.coreLock.Lock()
+		active := n.core.IsParticipant(evicted)
+		n.coreLock.Unlock()
+		if active {
+			t.Fatalf("nodes[%d] should have removed the evicted participant", i)
+		}
+
+		n.selectorLock.Lock()
+		for _, p := range n.peerSelector.Peers() {
+			if p.PubKeyHex == evicted {
+				t.Fatalf("nodes[%d] should not gossip with the evicted node", i)
+			}
+		}
+		n.selectorLock.Unlock()
+	}
+	checkGossip(remaining, t)
+}
+
+func TestAddressBookMembership(t *testing.T) {
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keys, peers := initPeers(2)
+	conf := TestConfig(t)
+	conf.AddressBook, err = net.NewAddressBook(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//the peers are sorted by public key, not in the order of the keys
+	self, other := 0, 1
+	if peers[0].PubKeyHex != crypto.KeyPairPubKeyHex(keys[0]) {
+		self, other = 1, 0
+	}
+	addr, trans := net.NewInmemTransport(peers[self].NetAddr)
+	peers[self].NetAddr = addr
+	node := NewNode(conf, keys[0], peers, trans, aproxy.NewInmemAppProxy(conf.Logger))
+	if err := node.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	node.commitPeerJoin(hg.PeerJoin{PubKey: "0x0A", NetAddr: "joiner:1337"})
+	node.commitPeerLeave(hg.PeerLeave{PubKey: peers[other].PubKeyHex})
+	node.Shutdown()
+
+	book, err := net.NewAddressBook(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	known, _ := book.Peers()
+	if len(known) != 1 || known[0].NetAddr != "joiner:1337" {
+		t.Fatalf("The address book should list the joiner only, not %v", known)
+	}
+	if e, _ := book.Entry(peers[other].PubKeyHex); !e.Removed || e.Source != net.SourceParticipants {
+		t.Fatalf("The initial peer should be marked as removed: %+v", e)
+	}
+}
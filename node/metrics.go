@@ -0,0 +1,108 @@
+package node
+
+import (
+	"time"
+
+	"github.com/babbleio/babble/metrics"
+)
+
+/*
+With Config.Metrics, the node collects Prometheus metrics, which the service
+serves on /metrics. Rates, like the transactions committed per second, are left
+to Prometheus: the node only exposes the counters.
+*/
+
+type nodeMetrics struct {
+	registry        *metrics.Registry
+	gossipDuration  *metrics.Histogram
+	syncEvents      *metrics.Histogram
+	txCommitted     *metrics.Counter
+	orphans         *metrics.Counter
+	transportErrors *metrics.CounterVec
+	throttledRPCs   *metrics.CounterVec
+}
+
+func newNodeMetrics(n *Node) *nodeMetrics {
+	r := metrics.NewRegistry()
+	m := &nodeMetrics{
+		registry: r,
+		gossipDuration: r.NewHistogram("babble_gossip_duration_seconds",
+			"Duration of a gossip round-trip (pull and push) with a peer",
+			metrics.ExponentialBuckets(0.001, 2, 14)),
+		syncEvents: r.NewHistogram("babble_sync_events",
+			"Events received per sync",
+			metrics.ExponentialBuckets(1, 2, 14)),
+		txCommitted: r.NewCounter("babble_transactions_committed_total",
+			"Transactions committed to the application"),
+		orphans: r.NewCounter("babble_orphans_discarded_total",
+			"Events discarded because their parents never arrived"),
+		transportErrors: r.NewCounterVec("babble_transport_errors_total",
+			"Requests to peers that failed, by type of request", "rpc"),
+		throttledRPCs: r.NewCounterVec("babble_rpcs_throttled_total",
+			"Requests from peers refused by the rate limits, by type of request", "rpc"),
+	}
+
+	r.NewGaugeFunc("babble_last_consensus_round",
+		"Index of the last round that reached consensus", func() float64 {
+			n.coreLock.RLock()
+			defer n.coreLock.RUnlock()
+			if round := n.core.GetLastConsensusRoundIndex(); round != nil {
+				return float64(*round)
+			}
+			return -1
+		})
+	r.NewGaugeFunc("babble_undetermined_events",
+		"Events that did not reach consensus yet", func() float64 {
+			n.coreLock.RLock()
+			defer n.coreLock.RUnlock()
+			return float64(len(n.core.GetUndeterminedEvents()))
+		})
+	return m
+}
+
+//The methods of nodeMetrics do nothing when metrics are disabled
+
+func (m *nodeMetrics) gossiped(start time.Time) {
+	if m != nil {
+		m.gossipDuration.Observe(time.Since(start).Seconds())
+	}
+}
+
+func (m *nodeMetrics) synced(events int) {
+	if m != nil {
+		m.syncEvents.Observe(float64(events))
+	}
+}
+
+func (m *nodeMetrics) committed(txs int) {
+	if m != nil {
+		m.txCommitted.Add(float64(txs))
+	}
+}
+
+func (m *nodeMetrics) orphansDiscarded(events int) {
+	if m != nil && events > 0 {
+		m.orphans.Add(float64(events))
+	}
+}
+
+func (m *nodeMetrics) transportError(rpc string) {
+	if m != nil {
+		m.transportErrors.With(rpc).Inc()
+	}
+}
+
+func (m *nodeMetrics) throttled(rpc string) {
+	if m != nil {
+		m.throttledRPCs.With(rpc).Inc()
+	}
+}
+
+//Metrics returns the registry of the metrics of the node, or nil if
+//Config.Metrics is off
+func (n *Node) Metrics() *metrics.Registry {
+	if n.metrics == nil {
+		return nil
+	}
+	return n.metrics.registry
+}
@@ -0,0 +1,40 @@
+package node
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+)
+
+func TestNodeMetrics(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	_, nodes := initNodes(4, 1000, logger)
+	defer shutdownNodes(nodes)
+	if nodes[1].Metrics() != nil {
+		t.Fatalf("Metrics should be disabled by default")
+	}
+	nodes[0].metrics = newNodeMetrics(nodes[0])
+
+	if err := gossip(nodes, 5, false, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	m := nodes[0].metrics
+	if m.gossipDuration.Count() == 0 || m.syncEvents.Count() == 0 {
+		t.Fatalf("Gossip and syncs should be measured")
+	}
+	if m.txCommitted.Value() == 0 {
+		t.Fatalf("Committed transactions should be counted")
+	}
+
+	var buf bytes.Buffer
+	if err := nodes[0].Metrics().Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(buf.String(), "babble_last_consensus_round -1") {
+		t.Fatalf("The last consensus round should be exposed:\n%s", buf.String())
+	}
+}
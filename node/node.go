@@ -1,8 +1,10 @@
 package node
 
 import (
-	"crypto/ecdsa"
+	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"sort"
 	"sync"
 	"time"
@@ -11,44 +13,112 @@ import (
 
 	"strconv"
 
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/crypto"
 	hg "github.com/babbleio/babble/hashgraph"
 	"github.com/babbleio/babble/net"
 	"github.com/babbleio/babble/proxy"
 )
 
+//errPeerLagging is returned by push when the peer is too far behind
+var errPeerLagging = errors.New("peer is over the sync limit")
+
 type Node struct {
 	nodeState
 
 	conf   *Config
 	logger *logrus.Entry
 
-	id       int
-	core     *Core
-	coreLock sync.Mutex
+	id   int
+	core *Core
+	//coreLock is held for writing while the hashgraph is modified and for
+	//reading by the syncs, queries and stats that only look at it
+	coreLock sync.RWMutex
 
 	localAddr string
 
 	peerSelector PeerSelector
 	selectorLock sync.Mutex
 
+	peerLimits     map[string]net.SyncLimits //[net addr] => advertised limits
+	peerLimitsLock sync.Mutex
+
+	peerCaps     map[string]net.Capabilities //[net addr] => advertised capabilities
+	peerCapsLock sync.Mutex
+
+	pendingJoins map[string]time.Time   //[pub key] => last time the PeerJoin was proposed
+	admitted     map[string]bool        //[pub key] => may join, see Admit
+	candidates   map[string]hg.PeerJoin //[pub key] => PeerJoin of the other participants, until it is admitted
+	joinsLock    sync.Mutex
+
 	trans net.Transport
 	netCh <-chan net.RPC
 
-	proxy    proxy.AppProxy
-	submitCh chan []byte
-
-	commitCh chan []hg.Event
+	proxy       proxy.AppProxy
+	submitCh    chan []byte
+	metadataCh  chan []byte
+	rejectedTxs int //transactions refused by a full pool or disk pressure, under the coreLock
+
+	commitCh   chan []hg.Event
+	chunks     *chunkAssembler
+	txPipeline *txPipeline
+	budgets    *txBudgets
+
+	committedTxs *committedTxs
+	blockIndex   int //index of the next Block committed to the App
+	watermark    *watermark
+	commitDedup  *commitDedup
+	subscribers  *subscriptions
+	blocks       *blockStore
+
+	//commitLock is held while Blocks are committed to the App and while its
+	//state is restored or read for a snapshot
+	commitLock      sync.Mutex
+	committedEvents int //consensus Events passed to commit
+	restoredRound   int //round of the last Block restored from a snapshot
+
+	//guarded by the coreLock, see prune.go
+	prunedRound  int //round below which the store was last pruned, -1 if never
+	prunedEvents int //Events pruned since the node started
 
 	shutdownCh chan struct{}
 
 	controlTimer *ControlTimer
+	scheduler    *scheduler
 
 	start        time.Time
 	syncRequests int
 	syncErrors   int
+
+	//guarded by the coreLock, see stall_monitor.go
+	stallRecoveries  int
+	lastRecoveryStep string
+
+	readyNotified bool
+
+	cloned bool //the Observer fast-forwarded from Config.CloneFrom
+
+	disk   *diskMonitor
+	tuning *tuning
+
+	syncLog *syncLog
+
+	connectivity *connectivity
+	traffic      *traffic
+	knowledge    *peerKnowledge
+	duties       *dutyTracker
+	syncQueue    *syncQueue
+	inserts      *insertQueue
+	transfers    *fastForwardTransfers
+	orphans      *orphanPool
+	cpuBudget    *cpuBudget
+
+	confErr error //invalid Config or error opening the store it selects
+
+	metrics *nodeMetrics //nil unless Config.Metrics
 }
 
-func NewNode(conf *Config, key *ecdsa.PrivateKey, participants []net.Peer, trans net.Transport, proxy proxy.AppProxy) Node {
+func NewNode(conf *Config, key crypto.KeyPair, participants []net.Peer, trans net.Transport, proxy proxy.AppProxy) Node {
 	localAddr := trans.LocalAddr()
 
 	sort.Sort(net.ByPubKey(participants))
@@ -61,11 +131,63 @@ func NewNode(conf *Config, key *ecdsa.PrivateKey, participants []net.Peer, trans
 		}
 	}
 
-	store := hg.NewInmemStore(pmap, conf.CacheSize)
+	//An invalid Config, peers with keys of another algorithm, or a store that
+	//can not be opened, are reported by Init and Join
+	var store hg.Store
+	confErr := conf.Validate()
+	if confErr == nil {
+		confErr = net.CheckKeyAlgorithms(key.Algorithm(), participants)
+	}
+	if confErr == nil && conf.Observer {
+		confErr = checkObserverKey(key, participants)
+	}
+	//an Observer has no id among the participants
+	if conf.Observer {
+		id = -1
+		conf.Capabilities |= net.CapObserver
+	}
+	if conf.Archive {
+		conf.Capabilities |= net.CapArchive
+	}
+	if confErr == nil {
+		store, confErr = newStore(conf, pmap)
+	}
+	if confErr != nil {
+		store = hg.NewInmemStore(pmap, conf.CacheSize)
+	}
 	commitCh := make(chan []hg.Event, 20)
 	core := NewCore(id, key, pmap, store, commitCh, conf.Logger)
+	core.SetMaxEventPayload(conf.MaxEventPayload)
+	core.SetSchemeWindow(conf.schemeWindow())
+	core.SetConcurrentSyncs(conf.FanOut > 1)
+	core.SetVerifyWorkers(conf.VerifyWorkers)
+	if conf.EventPolicy != nil {
+		core.SetEventCreationPolicy(conf.EventPolicy)
+	}
+	if conf.Observer {
+		core.SetEventCreationPolicy(ObserverPolicy{})
+	}
 
-	peerSelector := NewRandomPeerSelector(participants, localAddr)
+	//Nodes sharing a Config.Seed still make different choices, but the same
+	//ones from one run to the next
+	seed := conf.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rnd := rand.New(rand.NewSource(seed + int64(id)))
+	jitter := conf.HeartbeatJitter
+	if jitter == 0 {
+		jitter = 1
+	}
+
+	//an unknown strategy is already reported by Validate
+	peerSelector, err := NewPeerSelector(conf.PeerSelection, participants, localAddr)
+	if err != nil {
+		peerSelector = NewRandomPeerSelector(participants, localAddr)
+	}
+	peerSelector.Seed(rnd.Int63())
+	peerSelector.SetLocalZone(conf.Zone, conf.ZoneAffinity)
+	peerSelector.SetMaxActive(conf.MaxActivePeers)
 
 	node := Node{
 		id:           id,
@@ -74,28 +196,185 @@ func NewNode(conf *Config, key *ecdsa.PrivateKey, participants []net.Peer, trans
 		localAddr:    localAddr,
 		logger:       conf.Logger.WithField("node", localAddr),
 		peerSelector: peerSelector,
+		peerLimits:   make(map[string]net.SyncLimits),
+		peerCaps:     make(map[string]net.Capabilities),
+		pendingJoins: make(map[string]time.Time),
+		admitted:     make(map[string]bool),
+		candidates:   make(map[string]hg.PeerJoin),
 		trans:        trans,
 		netCh:        trans.Consumer(),
 		proxy:        proxy,
 		submitCh:     proxy.SubmitCh(),
+		metadataCh:   proxy.MetadataCh(),
 		commitCh:     commitCh,
+		chunks:       newChunkAssembler(),
+		txPipeline:   newTxPipeline(conf.TxMiddleware),
+		budgets:      newTxBudgets(conf.TxCost, conf.SubmitterBudget),
+		committedTxs: newCommittedTxs(committedTxsSize),
+		watermark:    newWatermark(),
+		commitDedup:  newCommitDedup(conf.CommitDedupRounds),
+		blocks:       newBlockStore(),
+		syncLog:      newSyncLog(syncLogSize),
+		subscribers:  newSubscriptions(conf.TxCost, conf.TxAppID),
+		connectivity: newConnectivity(),
+		traffic:      newTraffic(),
+		knowledge:    newPeerKnowledge(),
+		duties:       newDutyTracker(conf.DutyWindow),
+		syncQueue:    newSyncQueue(maxQueuedSyncsPerPeer),
+		inserts:      newInsertQueue(),
+		orphans:      newOrphanPool(),
+		cpuBudget:    newCPUBudget(conf.ConsensusCPUShare),
+		disk:         newDiskMonitor(),
+		tuning:       newTuning(conf),
+		transfers:    newFastForwardTransfers(),
+		confErr:      confErr,
 		shutdownCh:   make(chan struct{}),
-		controlTimer: NewRandomControlTimer(conf.HeartbeatTimeout),
+		controlTimer: NewRandomControlTimer(conf.HeartbeatTimeout, jitter, rnd.Int63()),
+	}
+	node.scheduler = newScheduler(conf.MaxBackgroundJobs, node.logger)
+	node.controlTimer.backoff = newHeartbeatBackoff(conf.HeartbeatTimeout, conf.MaxHeartbeat)
+
+	for _, p := range participants {
+		node.learnPeer(p, net.SourceParticipants)
+	}
+	for _, pk := range conf.AdmitKeys {
+		node.admitted[pk] = true
+	}
+	if conf.Metrics {
+		node.metrics = newNodeMetrics(&node)
+	}
+	if t, ok := trans.(net.WithPeerLimit); ok && conf.MaxActivePeers > 0 {
+		t.SetMaxPeers(connectedPeersPerActivePeer * conf.MaxActivePeers)
+	}
+	if t, ok := trans.(net.WithRateLimits); ok && conf.RPCRateLimits != (net.RPCRateLimits{}) {
+		m, logger := node.metrics, node.logger
+		t.SetRateLimits(conf.RPCRateLimits, func(peer, rpc string) {
+			m.throttled(rpc)
+			logger.WithFields(logrus.Fields{
+				"peer": peer,
+				"rpc":  rpc,
+			}).Debug("Throttled inbound RPC")
+		})
+	}
+	if t, ok := trans.(net.WithAdmission); ok && conf.Admission != nil {
+		logger := node.logger
+		t.SetAdmissionPolicy(conf.Admission, func(conn net.InboundConn, err error) {
+			logger.WithFields(logrus.Fields{
+				"addr":  conn.RemoteAddr,
+				"from":  conn.From,
+				"error": err,
+			}).Debug("Refused inbound connection")
+		})
 	}
 
-	//Initialize as Babbling
-	node.setState(Babbling)
+	//no snapshot of the App restored yet
+	node.restoredRound = -1
+	node.prunedRound = -1
+
+	//Nodes start Babbling, the zero value of the state
+	logger := node.logger
+	node.onStateChange(func(from, to NodeState) {
+		logger.WithFields(logrus.Fields{
+			"from": from.String(),
+			"to":   to.String(),
+		}).Debug("State change")
+	})
 
 	return node
 }
 
 func (n *Node) Init() error {
+	if n.confErr != nil {
+		return n.confErr
+	}
 	peerAddresses := []string{}
 	for _, p := range n.peerSelector.Peers() {
 		peerAddresses = append(peerAddresses, p.NetAddr)
 	}
 	n.logger.WithField("peers", peerAddresses).Debug("Init Node")
-	return n.core.Init()
+	if _, ok := n.core.hg.Store.(*hg.BadgerStore); ok {
+		return n.bootstrap()
+	}
+	if n.conf.Observer {
+		return n.initObserver()
+	}
+	if err := n.core.Init(); err != nil {
+		return err
+	}
+	return n.initFresh()
+}
+
+//State returns the current state of the node
+func (n *Node) State() NodeState {
+	return n.getState()
+}
+
+//OnStateChange registers a hook called after every change of state of the node
+func (n *Node) OnStateChange(hook StateHook) {
+	n.onStateChange(hook)
+}
+
+//SubscribeState returns a StateSubscription to the changes of state of the
+//node. Unlike a StateHook, it can be closed, and the subscriber reacts from its
+//own routine.
+func (n *Node) SubscribeState() *StateSubscription {
+	return n.subscribe()
+}
+
+//Suspend stops gossiping and answering requests until Resume, for example
+//during a rolling upgrade. The transport and the store stay open, and peers are
+//answered with ErrorSuspended so that they turn to other nodes.
+func (n *Node) Suspend() error {
+	return n.setState(Suspended)
+}
+
+//Resume resumes gossip after Suspend. A node that fell behind while it was
+//suspended catches up through the usual SyncLimit mechanism.
+//
+//Resume also prepares a node that takes over from a previous process using the
+//same key, instead of Init. Creating a new initial Event would fork the node's
+//own sequence of Events, so the node goes on from its last Event: the one in
+//its badger store, or else the last one known to the network, by
+//fast-forwarding from a peer.
+func (n *Node) Resume() error {
+	if n.getState() == Suspended {
+		return n.casState(Suspended, Babbling)
+	}
+	n.coreLock.RLock()
+	initialized := n.core.Head != ""
+	n.coreLock.RUnlock()
+	if initialized {
+		return fmt.Errorf("Node is %s, not suspended", n.getState())
+	}
+	n.logger.Debug("Resume Node")
+	if _, ok := n.core.hg.Store.(*hg.BadgerStore); ok {
+		return n.bootstrap()
+	}
+	return n.setState(CatchingUp)
+}
+
+//StartMaintenance stops gossiping but keeps serving the requests that only read
+//the hashgraph. It is also the way out of the Faulted state.
+func (n *Node) StartMaintenance() error {
+	return n.setState(Maintenance)
+}
+
+//EndMaintenance resumes gossip after StartMaintenance
+func (n *Node) EndMaintenance() error {
+	return n.casState(Maintenance, Babbling)
+}
+
+//UpdatePeerAddresses updates the addresses of the participants, for example
+//when discovery reports that a peer was rescheduled on another host. The set
+//of participants does not change.
+func (n *Node) UpdatePeerAddresses(peers []net.Peer) {
+	n.selectorLock.Lock()
+	n.peerSelector.UpdateAddresses(peers)
+	n.selectorLock.Unlock()
+
+	for _, p := range peers {
+		n.learnPeer(p, net.SourceDiscovery)
+	}
 }
 
 func (n *Node) RunAsync(gossip bool) {
@@ -104,14 +383,61 @@ func (n *Node) RunAsync(gossip bool) {
 }
 
 func (n *Node) Run(gossip bool) {
+	//Shutdown waits for the Run loop and the routines it starts. A node that
+	//was shut down does not run again.
+	if !n.startLoop() {
+		return
+	}
+	defer n.loops.Done()
+
 	//The ControlTimer allows the background routines to control the
 	//heartbeat timer when the node is in the Babbling state. The timer should
 	//only be running when there are uncommitted transactions in the system.
-	go n.controlTimer.Run()
+	n.goLoop(n.controlTimer.Run)
 
 	//Execute some background work regardless of the state of the node.
 	//Process RPC requests as well as SumbitTx and CommitTx requests
-	go n.doBackgroundWork()
+	n.goLoop(n.doBackgroundWork)
+
+	//Process the inbound syncs queued by doBackgroundWork
+	for i := 0; i < n.inboundSyncWorkers(); i++ {
+		n.goLoop(n.processSyncs)
+	}
+
+	//Write the peers learned by the node to disk
+	if n.conf.AddressBook != nil {
+		n.scheduler.add("address_book", addressBookInterval, n.saveAddressBook)
+	}
+
+	//Watch for consensus stalls and try to recover from them
+	if gossip && n.conf.StallTimeout > 0 {
+		n.scheduler.add("stall_monitor", n.conf.StallTimeout/2, n.stallMonitor())
+	}
+
+	//Gossip with other peers over time when they are not all active
+	if gossip && n.conf.MaxActivePeers > 0 && n.conf.PeerRotation > 0 {
+		n.scheduler.add("peer_rotation", n.conf.PeerRotation, n.rotatePeers)
+	}
+
+	//Refuse transactions and stop writing before the disk fills up
+	if n.conf.MinFreeDisk > 0 {
+		n.scheduler.add("disk_monitor", diskCheckInterval, n.checkDisk)
+	}
+
+	//Drop the history below the last final Blocks
+	if interval := n.conf.pruneInterval(); interval > 0 {
+		n.scheduler.add("prune", interval, n.pruneStore)
+	}
+
+	//Ping the systemd watchdog while consensus makes progress
+	if interval, err := common.SdWatchdogInterval(); err != nil {
+		n.logger.WithField("error", err).Error("Reading systemd watchdog interval")
+	} else if interval > 0 {
+		n.scheduler.add("watchdog", interval/2, n.watchdogPing())
+	}
+
+	//Run the background jobs added above
+	n.goLoop(func() { n.scheduler.run(n.shutdownCh) })
 
 	//Execute Node State Machine
 	for {
@@ -126,24 +452,42 @@ func (n *Node) Run(gossip bool) {
 			n.fastForward()
 		case Shutdown:
 			return
+		default:
+			n.idle(state)
 		}
 	}
 }
 
+//idle waits until the node leaves a state in which it does not gossip
+func (n *Node) idle(state NodeState) {
+	select {
+	case <-n.stateChanged(state):
+	case <-n.shutdownCh:
+	}
+}
+
 func (n *Node) doBackgroundWork() {
 	for {
+		submitCh, poolCheck := n.submissions()
 		select {
 		case rpc := <-n.netCh:
 			n.logger.Debug("Processing RPC")
 			n.processRPC(rpc)
-			if n.core.NeedGossip() && !n.controlTimer.set {
-				n.controlTimer.resetCh <- struct{}{}
+			if n.needConsensus() && !n.controlTimer.set {
+				n.controlTimer.Reset()
 			}
-		case t := <-n.submitCh:
+		case <-poolCheck:
+		case t := <-submitCh:
 			n.logger.Debug("Adding Transaction")
 			n.addTransaction(t)
-			if !n.controlTimer.set {
-				n.controlTimer.resetCh <- struct{}{}
+			if n.controlTimer.backoff.busy() || !n.controlTimer.set {
+				n.controlTimer.Reset()
+			}
+		case data := <-n.metadataCh:
+			if err := n.addBlockMetadata(data); err != nil {
+				n.logger.WithField("error", err).Debug("Block metadata dropped")
+			} else if !n.controlTimer.set {
+				n.controlTimer.Reset()
 			}
 		case events := <-n.commitCh:
 			n.logger.WithField("events", len(events)).Debug("Committing Events")
@@ -157,29 +501,35 @@ func (n *Node) doBackgroundWork() {
 }
 
 func (n *Node) babble(gossip bool) {
+	if gossip {
+		n.notifyReady()
+	}
 	for {
-		oldState := n.getState()
 		select {
 		case <-n.controlTimer.tickCh:
 			if gossip {
+				n.adaptHeartbeat()
 				proceed, err := n.preGossip()
 				if proceed && err == nil {
 					n.logger.Debug("Time to gossip!")
-					peer := n.peerSelector.Next()
-					n.goFunc(func() { n.gossip(peer.NetAddr) })
+					ctx := n.stateContext(Babbling)
+					for _, peer := range n.gossipPeers(n.fanOut()) {
+						addr := peer.NetAddr
+						n.goFunc(func() { n.gossip(ctx, addr) })
+					}
 				}
 			}
-			if !n.core.NeedGossip() {
-				n.controlTimer.stopCh <- struct{}{}
+			if n.conf.Observer {
+				//an Observer keeps polling its peers for new Events
+				n.controlTimer.Reset()
+			} else if !n.needConsensus() {
+				n.controlTimer.Stop()
 			} else if !n.controlTimer.set {
-				n.controlTimer.resetCh <- struct{}{}
+				n.controlTimer.Reset()
 			}
-		case <-n.shutdownCh:
+		case <-n.stateChanged(Babbling):
 			return
-		}
-
-		newState := n.getState()
-		if newState != oldState {
+		case <-n.shutdownCh:
 			return
 		}
 	}
@@ -187,24 +537,45 @@ func (n *Node) babble(gossip bool) {
 
 func (n *Node) processRPC(rpc net.RPC) {
 
-	if s := n.getState(); s != Babbling {
+	switch cmd := rpc.Command.(type) {
+	case *net.SyncRequest:
+		n.connectivity.inbound(cmd.From)
+	case *net.EagerSyncRequest:
+		n.connectivity.inbound(cmd.From)
+	case *net.FastForwardRequest:
+		n.connectivity.inbound(cmd.From)
+	case *net.FetchRequest:
+		n.connectivity.inbound(cmd.From)
+	}
+
+	if n.isDraining() {
+		n.refuseSync(rpc, withCode(net.ErrorStopping, fmt.Errorf("Shutting down")))
+		return
+	}
+	if s := n.getState(); !serves(s, rpc.Command) {
 		n.logger.WithField("state", s.String()).Debug("Discarding RPC Request")
-		//XXX Use a SyncResponse by default but this should be either a special
-		//ErrorResponse type or a type that corresponds to the request
-		resp := &net.SyncResponse{
-			From: n.localAddr,
+		//XXX Requests other than EagerSyncs get a SyncResponse, but this should
+		//be either a special ErrorResponse type or a type that corresponds to
+		//the request
+		code := net.ErrorBusy
+		if s == Suspended {
+			code = net.ErrorSuspended
 		}
-		rpc.Respond(resp, fmt.Errorf("not ready: %s", s.String()))
+		n.refuseSync(rpc, withCode(code, fmt.Errorf("not ready: %s", s.String())))
 		return
 	}
 
 	switch cmd := rpc.Command.(type) {
 	case *net.SyncRequest:
-		n.processSyncRequest(rpc, cmd)
+		n.queueSync(cmd.From, rpc)
 	case *net.EagerSyncRequest:
-		n.processEagerSyncRequest(rpc, cmd)
+		n.queueSync(cmd.From, rpc)
 	case *net.FastForwardRequest:
 		n.processFastForwardRequest(rpc, cmd)
+	case *net.FetchRequest:
+		n.processFetchRequest(rpc, cmd)
+	case *net.JoinRequest:
+		n.processJoinRequest(rpc, cmd)
 	default:
 		n.logger.WithField("cmd", rpc.Command).Error("Unexpected RPC command")
 		rpc.Respond(nil, fmt.Errorf("unexpected command"))
@@ -217,30 +588,59 @@ func (n *Node) processSyncRequest(rpc net.RPC, cmd *net.SyncRequest) {
 		"known": cmd.Known,
 	}).Debug("process SyncRequest")
 
+	n.receiveConnectivity(cmd.From, cmd.Connectivity)
+	n.setPeerCapabilities(cmd.From, cmd.Capabilities)
+	n.setPeerZone(cmd.From, cmd.Zone)
+	n.receiveBlockSignatures(cmd.From, cmd.BlockSignatures)
+
 	resp := &net.SyncResponse{
-		From: n.localAddr,
+		From:            n.localAddr,
+		Limits:          n.localSyncLimits(),
+		Connectivity:    n.sharedRow(),
+		Capabilities:    n.conf.Capabilities,
+		Zone:            n.conf.Zone,
+		BlockSignatures: n.blocks.signatures(),
 	}
 	var respErr error
 
-	//Check sync limit
-	n.coreLock.Lock()
-	overSyncLimit := n.core.OverSyncLimit(cmd.Known, n.conf.SyncLimit)
-	n.coreLock.Unlock()
-	if overSyncLimit {
+	//Respect the strictest of our limits and the requester's
+	limits := mergeSyncLimits(n.localSyncLimits(), cmd.Limits)
+
+	//Check sync limit, and that the Events the requester is missing were not
+	//pruned from our history
+	n.coreLock.RLock()
+	overSyncLimit := n.core.OverSyncLimit(cmd.Known, limits.Events)
+	missing := n.core.Missing(cmd.Known)
+	n.coreLock.RUnlock()
+	//archive peers are sent the oldest Events they miss instead
+	deepSync := overSyncLimit && n.servesHistory(cmd.From)
+	if overSyncLimit && !deepSync {
 		n.logger.Debug("SyncLimit")
 		resp.SyncLimit = true
+		resp.ErrorCode = net.ErrorTooFarBehind
+	} else if len(missing) > 0 {
+		n.logger.WithField("participants", missing).Debug("SyncLimit: history pruned")
+		resp.SyncLimit = true
+		resp.ErrorCode = net.ErrorTooFarBehind
 	} else {
-		//Compute Diff
+		//Compute Diff, without the Events the requester is known to have
+		known, skipped := n.knowledge.known(cmd.From, cmd.Known)
+		n.traffic.skipped(cmd.From, skipped)
 		start := time.Now()
-		n.coreLock.Lock()
-		diff, err := n.core.Diff(cmd.Known)
-		n.coreLock.Unlock()
+		limit := 0
+		if deepSync {
+			limit = limits.Events
+		}
+		n.coreLock.RLock()
+		diff, err := n.core.DiffLimit(known, limit)
+		n.coreLock.RUnlock()
 
 		elapsed := time.Since(start)
 		n.logger.WithField("duration", elapsed.Nanoseconds()).Debug("Diff()")
 		if err != nil {
 			n.logger.WithField("error", err).Error("Calculating Diff")
 			respErr = err
+			resp.ErrorCode = net.ErrorStore
 		}
 
 		//Convert to WireEvents
@@ -248,16 +648,28 @@ func (n *Node) processSyncRequest(rpc net.RPC, cmd *net.SyncRequest) {
 		if err != nil {
 			n.logger.WithField("error", err).Debug("Converting to WireEvent")
 			respErr = err
+			resp.ErrorCode = net.ErrorStore
 		} else {
-			resp.Events = wireEvents
+			resp.Events = truncateWireEvents(wireEvents, limits.Bytes)
+			//a deep sync sends the first Events over the limit
+			resp.Truncated = deepSync || len(resp.Events) < len(wireEvents)
+			n.traffic.sent(cmd.From, resp.Events)
+			if cmd.Compress {
+				dict := n.eventsDictionary(cmd.Capabilities)
+				if resp.CompressedEvents = n.compressEvents(resp.Events, dict); resp.CompressedEvents != nil {
+					resp.Events = nil
+					resp.Dictionary = dict
+				}
+			}
 		}
 	}
 
 	//Get Self Known
-	n.coreLock.Lock()
-	known := n.core.Known()
-	n.coreLock.Unlock()
-	resp.Known = known
+	n.coreLock.RLock()
+	resp.Known = n.core.Known()
+	resp.Ranges = n.core.KnownRanges()
+	n.coreLock.RUnlock()
+	n.peerSynced(cmd.From, resp.Known, cmd.Known)
 
 	n.logger.WithFields(logrus.Fields{
 		"Events":    len(resp.Events),
@@ -276,9 +688,19 @@ func (n *Node) processEagerSyncRequest(rpc net.RPC, cmd *net.EagerSyncRequest) {
 	}).Debug("EagerSyncRequest")
 
 	success := true
-	n.coreLock.Lock()
-	err := n.sync(cmd.Events)
-	n.coreLock.Unlock()
+	events, err := decompressEvents(cmd.Events, cmd.CompressedEvents, cmd.Dictionary)
+	switch {
+	case err != nil:
+	case cmd.More:
+		//the new head goes on top of the last batch of the push
+		err = n.insertFetched(cmd.From, events)
+	default:
+		err = n.insert(cmd.From, events)
+		//the Events were already inserted
+		if err == errStaleSync {
+			err = nil
+		}
+	}
 	if err != nil {
 		n.logger.WithField("error", err).Error("sync()")
 		success = false
@@ -288,7 +710,32 @@ func (n *Node) processEagerSyncRequest(rpc net.RPC, cmd *net.EagerSyncRequest) {
 		From:    n.localAddr,
 		Success: success,
 	}
+	//the Events of a node that is not a peer yet, for example because its
+	//PeerJoin is not committed here, can not be inserted
+	if !success && !n.isPeer(cmd.From) {
+		resp.ErrorCode = net.ErrorNotInPeerSet
+	}
 	rpc.Respond(resp, err)
+
+	//the requester waits for the response before serving the fetch
+	if success {
+		n.goFunc(func() { n.fetchParents(cmd.From) })
+	}
+}
+
+//serves is true if a node in state s processes cmd. A node in Maintenance only
+//serves the requests that read its hashgraph.
+func serves(s NodeState, cmd interface{}) bool {
+	switch s {
+	case Babbling:
+		return true
+	case Maintenance:
+		switch cmd.(type) {
+		case *net.SyncRequest, *net.FastForwardRequest, *net.FetchRequest:
+			return true
+		}
+	}
+	return false
 }
 
 func (n *Node) processFastForwardRequest(rpc net.RPC, cmd *net.FastForwardRequest) {
@@ -297,22 +744,40 @@ func (n *Node) processFastForwardRequest(rpc net.RPC, cmd *net.FastForwardReques
 	}).Debug("process FastForwardRequest")
 
 	resp := &net.FastForwardResponse{
-		From: n.localAddr,
+		From:  n.localAddr,
+		Round: n.lastConsensusRound(),
+	}
+	if cmd.Probe {
+		rpc.Respond(resp, nil)
+		return
+	}
+	//the next chunks of a transfer come from the encoding kept for it
+	if cmd.ChunkSize > 0 && cmd.Transfer != "" {
+		n.respondFastForwardChunk(rpc, resp.Round, cmd.Transfer, cmd.Offset, cmd.ChunkSize)
+		return
 	}
 	var respErr error
 
-	//Get latest Frame
-	frame, err := n.core.GetFrame()
+	//Get latest Frame and the state of the App
+	frame, block, snapshot, dedup, err := n.appSnapshot()
 	if err != nil {
 		n.logger.WithField("error", err).Error("Getting Frame")
 		respErr = err
 	}
 	resp.Frame = frame
+	resp.Block = block
+	resp.Snapshot = snapshot
+	resp.Dedup = dedup
 
 	n.logger.WithFields(logrus.Fields{
 		"Events": len(resp.Frame.Events),
+		"Block":  resp.Block.Index,
 		"Error":  respErr,
 	}).Debug("Responding to FastForwardRequest")
+	if cmd.ChunkSize > 0 && respErr == nil {
+		n.respondFastForwardChunk(rpc, resp.Round, n.startFastForwardTransfer(resp), 0, cmd.ChunkSize)
+		return
+	}
 	rpc.Respond(resp, respErr)
 }
 
@@ -320,6 +785,17 @@ func (n *Node) preGossip() (bool, error) {
 	n.coreLock.Lock()
 	defer n.coreLock.Unlock()
 
+	//An Observer only pulls, and always has something to pull
+	if n.conf.Observer {
+		return true, nil
+	}
+
+	//A node that was removed from the participants does not gossip anymore
+	if !n.core.IsParticipant(n.core.HexID()) {
+		n.logger.Debug("Not a participant")
+		return false, nil
+	}
+
 	//Check if it is necessary to gossip
 	needGossip := n.core.NeedGossip()
 	if !needGossip {
@@ -337,90 +813,169 @@ func (n *Node) preGossip() (bool, error) {
 	return true, nil
 }
 
-func (n *Node) gossip(peerAddr string) error {
+//gossip pulls from and pushes to a peer. The RPCs are abandoned when ctx is
+//done.
+func (n *Node) gossip(ctx context.Context, peerAddr string) error {
 	//pull
-	syncLimit, otherKnown, err := n.pull(peerAddr)
+	start := time.Now()
+	gossipStart := start
+	syncLimit, otherKnown, err := n.pull(ctx, peerAddr)
+	n.recordSync(peerAddr, "pull", start, err)
+	//Events were inserted meanwhile, probably pushed by the same peer: its
+	//Known is stale too, so the push waits for the next round
+	if err == errStaleSync {
+		return nil
+	}
 	if err != nil {
+		n.markPeerFailure(peerAddr)
 		return err
 	}
 
 	//check and handle syncLimit
 	if syncLimit {
 		n.logger.WithField("from", peerAddr).Debug("SyncLimit")
+		n.markPeerFailure(peerAddr)
+		//an archive node waits for an archive peer to serve the history it
+		//misses instead of fast-forwarding over it
+		if n.conf.Archive {
+			return nil
+		}
 		//TODO: Count 1/3 synclimits before initiating fastSync?
-		n.setState(CatchingUp)
+		if err := n.casState(Babbling, CatchingUp); err != nil {
+			n.logger.WithField("error", err).Debug("Not catching up")
+		}
 		return nil
 	}
 
-	//push
-	err = n.push(peerAddr, otherKnown)
-	if err != nil {
-		return err
+	//fetch the missing parents of the Events that could not be inserted
+	n.fetchParents(peerAddr)
+
+	//push, unless the node is an Observer, which has no Events of its own
+	if !n.conf.Observer {
+		start = time.Now()
+		err = n.push(ctx, peerAddr, otherKnown)
+		n.recordSync(peerAddr, "push", start, err)
+		if err == errPeerLagging {
+			//the peer is too far behind to be helped by a sync; try others first
+			n.markPeerFailure(peerAddr)
+			return nil
+		}
+		if err != nil {
+			n.markPeerFailure(peerAddr)
+			return err
+		}
 	}
 
 	//update peer selector
 	n.selectorLock.Lock()
 	n.peerSelector.UpdateLast(peerAddr)
+	if b, ok := n.peerSelector.(BackoffSelector); ok {
+		b.MarkSuccess(peerAddr)
+	}
 	n.selectorLock.Unlock()
 
+	n.metrics.gossiped(gossipStart)
 	n.logStats()
 
 	return nil
 }
 
-func (n *Node) pull(peerAddr string) (syncLimit bool, otherKnown map[int]int, err error) {
+//pull requests the Events of a peer it misses, again while the responses of
+//the peer are truncated to the sync limits
+func (n *Node) pull(ctx context.Context, peerAddr string) (syncLimit bool, otherKnown map[int]int, err error) {
+	for i := 1; ; i++ {
+		var truncated bool
+		syncLimit, otherKnown, truncated, err = n.pullOnce(ctx, peerAddr)
+		if err != nil || syncLimit || !truncated || i >= maxTruncatedPulls {
+			return syncLimit, otherKnown, err
+		}
+		n.logger.WithField("from", peerAddr).Debug("SyncResponse truncated, pulling again")
+	}
+}
+
+func (n *Node) pullOnce(ctx context.Context, peerAddr string) (syncLimit bool, otherKnown map[int]int, truncated bool, err error) {
 	//Compute Known
-	n.coreLock.Lock()
+	n.coreLock.RLock()
 	known := n.core.Known()
-	n.coreLock.Unlock()
+	n.coreLock.RUnlock()
 
 	//Send SyncRequest
 	start := time.Now()
-	resp, err := n.requestSync(peerAddr, known)
+	resp, err := n.requestSync(ctx, peerAddr, known)
 	elapsed := time.Since(start)
 	n.logger.WithField("duration", elapsed.Nanoseconds()).Debug("requestSync()")
+	if temporary(err) {
+		n.logger.WithFields(logrus.Fields{
+			"error": err,
+			"code":  errorCode(err),
+		}).Debug("requestSync()")
+		return false, nil, false, err
+	}
 	if err != nil {
 		n.logger.WithField("error", err).Error("requestSync()")
-		return false, nil, err
+		return false, nil, false, err
 	}
 	n.logger.WithFields(logrus.Fields{
 		"sync_limit": resp.SyncLimit,
 		"events":     len(resp.Events),
 		"known":      resp.Known,
+		"limits":     resp.Limits,
+		"truncated":  resp.Truncated,
 	}).Debug("SyncResponse")
 
+	//Adapt subsequent exchanges to the limits advertised by the peer
+	n.setPeerSyncLimits(peerAddr, resp.Limits)
+	n.peerSynced(peerAddr, known, resp.Known)
+
 	if resp.SyncLimit {
-		return true, nil, nil
+		if missing := resp.Ranges.Missing(resp.Known, known); len(missing) > 0 {
+			n.logger.WithFields(logrus.Fields{
+				"from":         peerAddr,
+				"participants": missing,
+			}).Debug("Peer pruned the Events we are missing")
+		}
+		return true, nil, false, nil
 	}
 
 	//Add Events to Hashgraph and create new Head if necessary
-	n.coreLock.Lock()
-	err = n.sync(resp.Events)
-	n.coreLock.Unlock()
+	err = n.insert(peerAddr, resp.Events)
+	if err == errStaleSync {
+		n.logger.WithField("from", peerAddr).Debug("Stale SyncResponse")
+		return false, nil, false, err
+	}
 	if err != nil {
 		n.logger.WithField("error", err).Error("sync()")
-		return false, nil, err
+		return false, nil, false, err
 	}
 
-	return false, resp.Known, nil
+	return false, resp.Known, resp.Truncated, nil
 }
 
-func (n *Node) push(peerAddr string, known map[int]int) error {
+func (n *Node) push(ctx context.Context, peerAddr string, known map[int]int) error {
+
+	limits := n.peerSyncLimits(peerAddr)
 
 	//Check SyncLimit
-	n.coreLock.Lock()
-	overSyncLimit := n.core.OverSyncLimit(known, n.conf.SyncLimit)
-	n.coreLock.Unlock()
+	n.coreLock.RLock()
+	overSyncLimit := n.core.OverSyncLimit(known, limits.Events)
+	missing := n.core.Missing(known)
+	n.coreLock.RUnlock()
 	if overSyncLimit {
 		n.logger.Debug("SyncLimit")
-		return nil
+		return errPeerLagging
+	}
+	if len(missing) > 0 {
+		n.logger.WithField("participants", missing).Debug("SyncLimit: history pruned")
+		return errPeerLagging
 	}
 
-	//Compute Diff
+	//Compute Diff, without the Events the peer is known to have
+	known, skipped := n.knowledge.known(peerAddr, known)
+	n.traffic.skipped(peerAddr, skipped)
 	start := time.Now()
-	n.coreLock.Lock()
+	n.coreLock.RLock()
 	diff, err := n.core.Diff(known)
-	n.coreLock.Unlock()
+	n.coreLock.RUnlock()
 	elapsed := time.Since(start)
 	n.logger.WithField("duration", elapsed.Nanoseconds()).Debug("Diff()")
 	if err != nil {
@@ -434,46 +989,93 @@ func (n *Node) push(peerAddr string, known map[int]int) error {
 		n.logger.WithField("error", err).Debug("Converting to WireEvent")
 		return err
 	}
-
-	//Create and Send EagerSyncRequest
-	start = time.Now()
-	resp2, err := n.requestEagerSync(peerAddr, wireEvents)
-	elapsed = time.Since(start)
-	n.logger.WithField("duration", elapsed.Nanoseconds()).Debug("requestEagerSync()")
-	if err != nil {
-		n.logger.WithField("error", err).Error("requestEagerSync()")
-		return err
+	batches := n.pushBatches(peerAddr, wireEvents, limits)
+
+	//Create and Send an EagerSyncRequest per batch
+	for i, batch := range batches {
+		more := i < len(batches)-1
+		start = time.Now()
+		resp2, err := n.requestEagerSync(ctx, peerAddr, batch, more)
+		elapsed = time.Since(start)
+		n.logger.WithField("duration", elapsed.Nanoseconds()).Debug("requestEagerSync()")
+		if temporary(err) {
+			n.logger.WithFields(logrus.Fields{
+				"error": err,
+				"code":  errorCode(err),
+			}).Debug("requestEagerSync()")
+			return err
+		}
+		if err != nil {
+			n.logger.WithField("error", err).Error("requestEagerSync()")
+			return err
+		}
+		n.traffic.sent(peerAddr, batch)
+		if resp2.Success {
+			n.knowledge.record(peerAddr, batch)
+		}
+		n.logger.WithFields(logrus.Fields{
+			"from":    resp2.From,
+			"success": resp2.Success,
+			"more":    more,
+		}).Debug("EagerSyncResponse")
 	}
-	n.logger.WithFields(logrus.Fields{
-		"from":    resp2.From,
-		"success": resp2.Success,
-	}).Debug("EagerSyncResponse")
 
 	return nil
 }
 
+func (n *Node) markPeerFailure(peerAddr string) {
+	n.selectorLock.Lock()
+	if b, ok := n.peerSelector.(BackoffSelector); ok {
+		b.MarkFailure(peerAddr)
+	}
+	n.selectorLock.Unlock()
+}
+
 func (n *Node) fastForward() error {
 	n.logger.Debug("IN CATCHING-UP STATE")
 
 	//wait until sync routines finish
 	n.waitRoutines()
 
-	//fastForwardRequest
-	peer := n.peerSelector.Next()
-	start := time.Now()
-	resp, err := n.requestFastForward(peer.NetAddr)
-	elapsed := time.Since(start)
-	n.logger.WithField("duration", elapsed.Nanoseconds()).Debug("requestFastForward()")
+	//the requests are abandoned when the node leaves its state, e.g. when it
+	//is suspended or shut down
+	ctx := n.stateContext(n.getState())
+
+	//fastForwardRequest, to the most advanced and closest peers first
+	var resp net.FastForwardResponse
+	var err error
+	for _, source := range n.withCloneSource(n.fastForwardSources(ctx)) {
+		start := time.Now()
+		resp, err = n.requestFastForward(ctx, source.addr)
+		elapsed := time.Since(start)
+		n.logger.WithField("duration", elapsed.Nanoseconds()).Debug("requestFastForward()")
+		if err == nil {
+			break
+		}
+		n.logger.WithFields(logrus.Fields{
+			"source": source.addr,
+			"error":  err,
+		}).Error("requestFastForward()")
+	}
 	if err != nil {
-		n.logger.WithField("error", err).Error("requestFastForward()")
 		return err
 	}
 	n.logger.WithField("events", len(resp.Frame.Events)).Debug("FastForwardResponse")
 
-	//prepare core. ie: fresh hashgraph
-	n.coreLock.Lock()
-	err = n.core.FastForward(resp.Frame)
-	n.coreLock.Unlock()
+	//prepare core. ie: fresh hashgraph, and the App resumes from the state of
+	//the peer if it committed Blocks
+	if resp.Snapshot != nil {
+		err = n.restoreApp(resp.Frame, resp.Block, resp.Snapshot, resp.Dedup)
+	} else {
+		n.commitLock.Lock()
+		n.commitDedup.restore(resp.Dedup)
+		n.persistDedup()
+		n.commitLock.Unlock()
+		n.coreLock.Lock()
+		err = n.core.FastForward(resp.Frame)
+		n.id = n.core.ID()
+		n.coreLock.Unlock()
+	}
 
 	if err != nil {
 		n.logger.WithField("error", err).Error("Fast Forwarding Hashgraph")
@@ -481,47 +1083,93 @@ func (n *Node) fastForward() error {
 	}
 
 	n.logger.Debug("Fast-Forward OK")
+	n.cloned = true
 
-	n.setState(Babbling)
+	//the node may have been suspended or shut down in the meantime
+	if err := n.casState(CatchingUp, Babbling); err != nil {
+		n.logger.WithField("error", err).Debug("Leaving CatchingUp")
+	}
 
 	return nil
 }
 
-func (n *Node) requestSync(target string, known map[int]int) (net.SyncResponse, error) {
+func (n *Node) requestSync(ctx context.Context, target string, known map[int]int) (net.SyncResponse, error) {
 	args := net.SyncRequest{
-		From:  n.localAddr,
-		Known: known,
+		From:            n.localAddr,
+		Known:           known,
+		Limits:          n.peerSyncLimits(target),
+		Connectivity:    n.sharedRow(),
+		Capabilities:    n.conf.Capabilities,
+		Zone:            n.conf.Zone,
+		BlockSignatures: n.blocks.signatures(),
+		Compress:        n.conf.Capabilities.Has(net.CapCompression),
 	}
 
 	var out net.SyncResponse
-	err := n.trans.Sync(target, &args, &out)
+	start := time.Now()
+	err := n.trans.Sync(ctx, target, &args, &out)
+	err = withCode(out.ErrorCode, err)
+	n.outbound(target, "sync", start, linkError(err))
+	n.duties.synced(target, err == nil)
+	if err == nil {
+		n.receiveConnectivity(target, out.Connectivity)
+		n.setPeerCapabilities(target, out.Capabilities)
+		n.setPeerZone(target, out.Zone)
+		n.receiveBlockSignatures(target, out.BlockSignatures)
+		out.Events, err = decompressEvents(out.Events, out.CompressedEvents, out.Dictionary)
+	}
 
 	return out, err
 }
 
-func (n *Node) requestEagerSync(target string, events []hg.WireEvent) (net.EagerSyncResponse, error) {
+func (n *Node) requestEagerSync(ctx context.Context, target string, events []hg.WireEvent, more bool) (net.EagerSyncResponse, error) {
 	args := net.EagerSyncRequest{
 		From:   n.localAddr,
 		Events: events,
+		More:   more,
+	}
+	if n.peerSupports(target, net.CapCompression) {
+		dict := net.NoDictionary
+		if n.peerSupports(target, net.CapDictionary) {
+			dict = net.EventsDictionaryV1
+		}
+		if args.CompressedEvents = n.compressEvents(events, dict); args.CompressedEvents != nil {
+			args.Events = nil
+			args.Dictionary = dict
+		}
 	}
 
 	var out net.EagerSyncResponse
-	err := n.trans.EagerSync(target, &args, &out)
+	start := time.Now()
+	err := n.trans.EagerSync(ctx, target, &args, &out)
+	err = withCode(out.ErrorCode, err)
+	n.outbound(target, "eager_sync", start, linkError(err))
 
 	return out, err
 }
 
-func (n *Node) requestFastForward(target string) (net.FastForwardResponse, error) {
+func (n *Node) requestFastForward(ctx context.Context, target string) (net.FastForwardResponse, error) {
 	n.logger.WithFields(logrus.Fields{
 		"target": target,
 	}).Debug("RequestFastForward()")
 
 	args := net.FastForwardRequest{
-		From: n.localAddr,
+		From:      n.localAddr,
+		ChunkSize: n.conf.FastForwardChunk,
 	}
+	resp, err := n.fastForwardRPC(ctx, target, &args)
+	//sources that do not chunk send the Frame whole
+	if err != nil || resp.Transfer == "" {
+		return resp, err
+	}
+	return n.receiveFastForward(ctx, target, args, resp)
+}
 
+func (n *Node) fastForwardRPC(ctx context.Context, target string, args *net.FastForwardRequest) (net.FastForwardResponse, error) {
 	var out net.FastForwardResponse
-	err := n.trans.FastForward(target, &args, &out)
+	start := time.Now()
+	err := n.trans.FastForward(ctx, target, args, &out)
+	n.outbound(target, "fast_forward", start, err)
 
 	return out, err
 }
@@ -529,51 +1177,200 @@ func (n *Node) requestFastForward(target string) (net.FastForwardResponse, error
 func (n *Node) sync(events []hg.WireEvent) error {
 	//Insert Events in Hashgraph and create new Head if necessary
 	start := time.Now()
-	err := n.core.Sync(events)
+	syncErr := n.core.Sync(events)
 	elapsed := time.Since(start)
 	n.logger.WithField("duration", elapsed.Nanoseconds()).Debug("Processed Sync()")
-	if err != nil {
-		return err
+	//a stale batch was inserted by another sync, which may not have run consensus
+	if syncErr != nil && syncErr != errStaleSync {
+		return syncErr
 	}
 
 	//Run consensus methods
 	start = time.Now()
-	err = n.core.RunConsensus()
+	err := n.core.RunConsensus()
 	elapsed = time.Since(start)
 	n.logger.WithField("duration", elapsed.Nanoseconds()).Debug("Processed RunConsensus()")
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return syncErr
+}
+
+//backfill inserts a chunk of a backfill batch and runs consensus without
+//creating a new head
+func (n *Node) backfill(events []hg.WireEvent) error {
+	start := time.Now()
+	err := n.core.Backfill(events)
+	elapsed := time.Since(start)
+	n.logger.WithField("duration", elapsed.Nanoseconds()).Debug("Processed Backfill()")
+	if err != nil {
+		return err
+	}
+
+	return n.core.RunConsensus()
 }
 
+//commit passes the transactions of consensus Events to the App, in one Block
+//per round received
 func (n *Node) commit(events []hg.Event) error {
-	for _, ev := range events {
-		for _, tx := range ev.Transactions() {
-			if err := n.proxy.CommitTx(tx); err != nil {
+	n.commitLock.Lock()
+	defer n.commitLock.Unlock()
+
+	round := -1
+	txs := [][]byte{}
+	metadata := []hg.BlockMetadata{}
+	//the transactions that reach consensus, including the ones that are not
+	//delivered to the App, reported by TxStatus once their Block is committed
+	seen := [][]byte{}
+	for i, ev := range events {
+		//already in the snapshot of the App
+		if n.restoredRound >= 0 && ev.RoundReceived() <= n.restoredRound {
+			continue
+		}
+		n.committedEvents++
+		if i > 0 && ev.RoundReceived() != round {
+			if err := n.commitBlock(round, txs, metadata); err != nil {
 				return err
 			}
+			n.committedTxs.addAll(seen, n.blockIndex-1)
+			txs = [][]byte{}
+			seen = [][]byte{}
+			metadata = []hg.BlockMetadata{}
+		}
+		round = ev.RoundReceived()
+		n.chunks.Expire(round)
+		for _, tx := range ev.Transactions() {
+			//PeerJoins and PeerLeaves are already applied by the hashgraph
+			if n.commitMembership(tx) {
+				continue
+			}
+			if data, ok := readMetadata(tx); ok {
+				metadata = append(metadata, hg.BlockMetadata{Creator: ev.Creator(), Data: data})
+				continue
+			}
+			//chunks of large transactions are only committed once the full
+			//transaction has been reassembled
+			full, ok, err := n.chunks.Add(tx, round)
+			if err != nil {
+				n.logger.WithField("error", err).Error("Reassembling transaction")
+				continue
+			}
+			if !ok {
+				continue
+			}
+			seen = append(seen, full)
+			n.budgets.refund(full)
+			if n.commitDedup.duplicate(full, round) {
+				continue
+			}
+			full, err = n.txPipeline.commit(full)
+			if err != nil {
+				n.logger.WithField("error", err).Debug("Transaction rejected by middleware")
+				continue
+			}
+			txs = append(txs, full)
 		}
 	}
+	if err := n.commitBlock(round, txs, metadata); err != nil {
+		return err
+	}
+	n.committedTxs.addAll(seen, n.blockIndex-1)
+	n.persistDedup()
+	return nil
+}
+
+//commitBlock commits a Block to the App, unless there are no transactions and
+//no metadata
+func (n *Node) commitBlock(roundReceived int, txs [][]byte, metadata []hg.BlockMetadata) error {
+	if len(txs) == 0 && len(metadata) == 0 {
+		return nil
+	}
+	block := hg.NewBlock(n.blockIndex, roundReceived, txs)
+	if len(metadata) > 0 {
+		block.Metadata = metadata
+	}
+	block.Version = n.conf.blockVersion()
+	stateHash, err := n.proxy.CommitBlock(block)
+	if err != nil {
+		return err
+	}
+	n.logger.WithFields(logrus.Fields{
+		"index":          block.Index,
+		"round_received": roundReceived,
+		"txs":            len(txs),
+		"metadata":       len(metadata),
+		"state_hash":     fmt.Sprintf("%X", stateHash),
+	}).Debug("Committed Block")
+	block.StateHash = stateHash
+	n.signBlock(block)
+	n.persistBlock(block.Index)
+	n.blockIndex++
+	n.watermark.set(block.Index)
+	n.subscribers.publish(block.Index, roundReceived, stateHash, txs)
+	n.metrics.committed(len(txs))
 	return nil
 }
 
 func (n *Node) addTransaction(tx []byte) {
+	//transactions submitted to an Observer would never be in an Event
+	if n.conf.Observer {
+		n.logger.Debug("Transaction dropped by Observer")
+		return
+	}
+
+	tx, err := n.txPipeline.submit(tx)
+	if err != nil {
+		n.logger.WithField("error", err).Debug("Transaction rejected by middleware")
+		return
+	}
+
+	if err := n.budgets.charge(tx); err != nil {
+		n.logger.WithField("error", err).Debug("Transaction rejected by budget")
+		return
+	}
+
 	n.coreLock.Lock()
 	defer n.coreLock.Unlock()
-	n.core.AddTransactions([][]byte{tx})
+	if n.DiskPressure() {
+		n.rejectedTxs++
+		n.budgets.refund(tx)
+		n.logger.Debug("Transaction rejected on low disk space")
+		return
+	}
+	if n.poolFullLocked() {
+		n.rejectedTxs++
+		n.budgets.refund(tx)
+		n.logger.Debug("Transaction rejected by full pool")
+		return
+	}
+	if err := n.core.AddTransactions([][]byte{tx}); err != nil {
+		n.budgets.refund(tx)
+		n.logger.WithField("error", err).Error("Adding Transaction")
+	}
 }
 
 func (n *Node) Shutdown() {
-	if n.getState() != Shutdown {
-		n.logger.Debug("Shutdown")
-		n.waitRoutines()
-		n.controlTimer.Shutdown()
-		close(n.shutdownCh)
-		n.trans.Close()
-		n.setState(Shutdown)
+	//Entering the Shutdown state first stops other routines from starting
+	//and makes a second call return straight away
+	if err := n.setState(Shutdown); err != nil {
+		return
+	}
+	n.logger.Debug("Shutdown")
+	n.notifyStopping()
+	n.controlTimer.Shutdown()
+	close(n.shutdownCh)
+	n.trans.Close()
+	n.waitRoutines()
+	n.waitLoops()
+	if n.conf.AddressBook != nil {
+		n.saveAddressBook()
 	}
+	n.coreLock.Lock()
+	if err := n.core.hg.Store.Close(); err != nil {
+		n.logger.WithField("error", err).Error("Closing Store")
+	}
+	n.coreLock.Unlock()
 }
 
 func (n *Node) GetStats() map[string]string {
@@ -584,11 +1381,26 @@ func (n *Node) GetStats() map[string]string {
 		return strconv.Itoa(*i)
 	}
 
+	//before the coreLock, which the blockStore takes after its own lock
+	lastBlock, lastFinalBlock := n.blocks.stats()
+
+	n.coreLock.RLock()
+	defer n.coreLock.RUnlock()
+
 	timeElapsed := time.Since(n.start)
+	_, traffic := n.traffic.snapshot()
+	queuedSyncs, rejectedSyncs, expiredSyncs := n.syncQueue.stats()
+	submitRejected, commitRejected := n.txPipeline.rejected()
+	_, insertedChunks := n.inserts.stats()
+	orphans, discardedOrphans := n.orphans.stats()
 
 	consensusEvents := n.core.GetConsensusEventsCount()
 	consensusEventsPerSecond := float64(consensusEvents) / timeElapsed.Seconds()
 
+	n.selectorLock.Lock()
+	zonePeers, localGossipRatio := n.topologyStats()
+	n.selectorLock.Unlock()
+
 	lastConsensusRound := n.core.GetLastConsensusRoundIndex()
 	var consensusRoundsPerSecond float64
 	if lastConsensusRound != nil {
@@ -601,6 +1413,17 @@ func (n *Node) GetStats() map[string]string {
 		"consensus_transactions": strconv.Itoa(n.core.GetConsensusTransactionsCount()),
 		"undetermined_events":    strconv.Itoa(len(n.core.GetUndeterminedEvents())),
 		"transaction_pool":       strconv.Itoa(len(n.core.transactionPool)),
+		"rejected_txs":           strconv.Itoa(n.rejectedTxs),
+		"over_budget_txs":        strconv.Itoa(n.budgets.overBudget()),
+		"subscribers":            strconv.Itoa(n.subscribers.count()),
+		"heartbeat":              n.heartbeat().String(),
+		"sync_limit":             strconv.Itoa(n.syncLimit()),
+		"disk_pressure":          strconv.FormatBool(n.DiskPressure()),
+		"free_disk_mb":           strconv.FormatUint(n.freeDiskMB(), 10),
+		"pruned_round":           strconv.Itoa(n.prunedRound),
+		"pruned_events":          strconv.Itoa(n.prunedEvents),
+		"archive":                strconv.FormatBool(n.conf.Archive),
+		"observer":               strconv.FormatBool(n.conf.Observer),
 		"num_peers":              strconv.Itoa(len(n.peerSelector.Peers())),
 		"sync_rate":              strconv.FormatFloat(n.SyncRate(), 'f', 2, 64),
 		"events_per_second":      strconv.FormatFloat(consensusEventsPerSecond, 'f', 2, 64),
@@ -608,6 +1431,27 @@ func (n *Node) GetStats() map[string]string {
 		"round_events":           strconv.Itoa(n.core.GetLastCommitedRoundEventsCount()),
 		"id":                     strconv.Itoa(n.id),
 		"state":                  n.getState().String(),
+		"stall_recoveries":       strconv.Itoa(n.stallRecoveries),
+		"last_recovery_step":     n.lastRecoveryStep,
+		"rejected_submits":       strconv.Itoa(submitRejected),
+		"rejected_commits":       strconv.Itoa(commitRejected),
+		"duplicate_commits":      strconv.Itoa(n.commitDedup.duplicates()),
+		"last_block_index":       strconv.Itoa(lastBlock),
+		"last_final_block":       strconv.Itoa(lastFinalBlock),
+		"events_sent":            strconv.Itoa(traffic.EventsSent),
+		"events_received":        strconv.Itoa(traffic.EventsReceived),
+		"duplicate_ratio":        strconv.FormatFloat(traffic.DuplicateRatio, 'f', 2, 64),
+		"events_skipped":         strconv.Itoa(traffic.EventsSkipped),
+		"queued_syncs":           strconv.Itoa(queuedSyncs),
+		"rejected_syncs":         strconv.Itoa(rejectedSyncs),
+		"expired_syncs":          strconv.Itoa(expiredSyncs),
+		"backfill_chunks":        strconv.Itoa(insertedChunks[backfillInsert]),
+		"orphans":                strconv.Itoa(orphans),
+		"discarded_orphans":      strconv.Itoa(discardedOrphans),
+		"zone":                   n.conf.Zone,
+		"zone_peers":             zonePeers,
+		"local_gossip_ratio":     strconv.FormatFloat(localGossipRatio, 'f', 2, 64),
+		"cpu_throttled_ms":       strconv.FormatInt(int64(n.cpuBudget.stats()/time.Millisecond), 10),
 	}
 	return s
 }
@@ -1,9 +1,10 @@
 package node
 
 import (
-	"crypto/ecdsa"
+	"context"
 	"fmt"
 	"math/rand"
+	"os"
 	"reflect"
 	"sort"
 	"strconv"
@@ -12,6 +13,7 @@ import (
 
 	"github.com/babbleio/babble/common"
 	"github.com/babbleio/babble/crypto"
+	hg "github.com/babbleio/babble/hashgraph"
 	"github.com/babbleio/babble/net"
 	aproxy "github.com/babbleio/babble/proxy/app"
 	"github.com/Sirupsen/logrus"
@@ -19,16 +21,16 @@ import (
 
 var ip = 9990
 
-func initPeers(n int) ([]*ecdsa.PrivateKey, []net.Peer) {
-	keys := []*ecdsa.PrivateKey{}
+func initPeers(n int) ([]crypto.KeyPair, []net.Peer) {
+	keys := []crypto.KeyPair{}
 	peers := []net.Peer{}
 
 	for i := 0; i < n; i++ {
-		key, _ := crypto.GenerateECDSAKey()
+		key, _ := crypto.GenerateKeyPair(crypto.ECDSAP256)
 		keys = append(keys, key)
 		peers = append(peers, net.Peer{
 			NetAddr:   fmt.Sprintf("127.0.0.1:%d", ip),
-			PubKeyHex: fmt.Sprintf("0x%X", crypto.FromECDSAPub(&keys[i].PublicKey)),
+			PubKeyHex: crypto.KeyPairPubKeyHex(keys[i]),
 		})
 		ip++
 	}
@@ -42,7 +44,7 @@ func TestProcessSync(t *testing.T) {
 
 	//Start two nodes
 
-	peer0Trans, err := net.NewTCPTransport(peers[0].NetAddr, nil, 2, time.Second, testLogger)
+	peer0Trans, err := net.NewTCPTransport(peers[0].NetAddr, nil, 2, time.Second, nil, testLogger)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -53,7 +55,7 @@ func TestProcessSync(t *testing.T) {
 
 	node0.RunAsync(false)
 
-	peer1Trans, err := net.NewTCPTransport(peers[1].NetAddr, nil, 2, time.Second, testLogger)
+	peer1Trans, err := net.NewTCPTransport(peers[1].NetAddr, nil, 2, time.Second, nil, testLogger)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -92,7 +94,7 @@ func TestProcessSync(t *testing.T) {
 	//Make actual SyncRequest and check SyncResponse
 
 	var out net.SyncResponse
-	if err := peer0Trans.Sync(peers[1].NetAddr, &args, &out); err != nil {
+	if err := peer0Trans.Sync(context.Background(), peers[1].NetAddr, &args, &out); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
@@ -128,7 +130,7 @@ func TestProcessEagerSync(t *testing.T) {
 
 	//Start two nodes
 
-	peer0Trans, err := net.NewTCPTransport(peers[0].NetAddr, nil, 2, time.Second, testLogger)
+	peer0Trans, err := net.NewTCPTransport(peers[0].NetAddr, nil, 2, time.Second, nil, testLogger)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -139,7 +141,7 @@ func TestProcessEagerSync(t *testing.T) {
 
 	node0.RunAsync(false)
 
-	peer1Trans, err := net.NewTCPTransport(peers[1].NetAddr, nil, 2, time.Second, testLogger)
+	peer1Trans, err := net.NewTCPTransport(peers[1].NetAddr, nil, 2, time.Second, nil, testLogger)
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -176,7 +178,7 @@ func TestProcessEagerSync(t *testing.T) {
 	//Make actual EagerSyncRequest and check EagerSyncResponse
 
 	var out net.EagerSyncResponse
-	if err := peer0Trans.EagerSync(peers[1].NetAddr, &args, &out); err != nil {
+	if err := peer0Trans.EagerSync(context.Background(), peers[1].NetAddr, &args, &out); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
@@ -195,7 +197,7 @@ func TestAddTransaction(t *testing.T) {
 
 	//Start two nodes
 
-	peer0Trans, err := net.NewTCPTransport(peers[0].NetAddr, nil, 2, time.Second, common.NewTestLogger(t))
+	peer0Trans, err := net.NewTCPTransport(peers[0].NetAddr, nil, 2, time.Second, nil, common.NewTestLogger(t))
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -207,7 +209,7 @@ func TestAddTransaction(t *testing.T) {
 
 	node0.RunAsync(false)
 
-	peer1Trans, err := net.NewTCPTransport(peers[1].NetAddr, nil, 2, time.Second, common.NewTestLogger(t))
+	peer1Trans, err := net.NewTCPTransport(peers[1].NetAddr, nil, 2, time.Second, nil, common.NewTestLogger(t))
 	if err != nil {
 		t.Fatalf("err: %v", err)
 	}
@@ -233,7 +235,7 @@ func TestAddTransaction(t *testing.T) {
 	}
 
 	var out net.SyncResponse
-	if err := peer0Trans.Sync(peers[1].NetAddr, &args, &out); err != nil {
+	if err := peer0Trans.Sync(context.Background(), peers[1].NetAddr, &args, &out); err != nil {
 		t.Fatal(err)
 	}
 
@@ -260,15 +262,46 @@ func TestAddTransaction(t *testing.T) {
 	node1.Shutdown()
 }
 
-func initNodes(n int, syncLimit int, logger *logrus.Logger) ([]*ecdsa.PrivateKey, []*Node) {
+func TestConcurrentReads(t *testing.T) {
+	keys, peers := initPeers(1)
+	testLogger := common.NewTestLogger(t)
+
+	_, trans := net.NewInmemTransport(peers[0].NetAddr)
+	node := NewNode(TestConfig(t), keys[0], peers, trans, aproxy.NewInmemAppProxy(testLogger))
+	node.Init()
+
+	//a long read, like a Diff, does not block the stats and the other queries
+	node.coreLock.RLock()
+	defer node.coreLock.RUnlock()
+
+	done := make(chan struct{})
+	go func() {
+		node.GetStats()
+		node.lastConsensusRound()
+		node.needConsensus()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Reads should not wait for each other")
+	}
+}
+
+func TestMain(m *testing.M) {
+	os.Exit(common.RunWithSeed(m))
+}
+
+func initNodes(n int, syncLimit int, logger *logrus.Logger) ([]crypto.KeyPair, []*Node) {
 	conf := NewConfig(5*time.Millisecond, time.Second, 1000, syncLimit, logger)
+	conf.Seed = common.TestSeed()
 
 	keys, peers := initPeers(n)
 	nodes := []*Node{}
 	proxies := []*aproxy.InmemAppProxy{}
 	for i := 0; i < len(peers); i++ {
 		trans, err := net.NewTCPTransport(peers[i].NetAddr,
-			nil, 2, time.Second, logger)
+			nil, 2, time.Second, nil, logger)
 		if err != nil {
 			logger.Panicf("failed to create transport for peer %d: %s\n", i, err.Error())
 		}
@@ -356,7 +389,7 @@ func TestSyncLimit(t *testing.T) {
 	}
 
 	var out net.SyncResponse
-	if err := nodes[0].trans.Sync(nodes[1].localAddr, &args, &out); err != nil {
+	if err := nodes[0].trans.Sync(context.Background(), nodes[1].localAddr, &args, &out); err != nil {
 		t.Fatalf("err: %v", err)
 	}
 
@@ -367,12 +400,18 @@ func TestSyncLimit(t *testing.T) {
 	if expectedResp.SyncLimit != true {
 		t.Fatal("SyncResponse.SyncLimit should be true")
 	}
+	if out.Limits.Events != 300 {
+		t.Fatalf("SyncResponse.Limits.Events should be 300, not %d", out.Limits.Events)
+	}
 }
 
 func TestFastForward(t *testing.T) {
 	logger := common.NewTestLogger(t)
 	_, nodes := initNodes(4, 1000, logger)
 	defer shutdownNodes(nodes)
+	for _, n := range nodes {
+		n.commitDedup = newCommitDedup(5)
+	}
 
 	target := 50
 	err := gossip(nodes[1:], target, false, 3*time.Second)
@@ -380,7 +419,13 @@ func TestFastForward(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	err = nodes[0].fastForward()
+	//peers refuse to answer while consensus Events are on their way to the App
+	for i := 0; i < 10; i++ {
+		if err = nodes[0].fastForward(); err == nil {
+			break
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
 	if err != nil {
 		t.Fatalf("Error FastForwarding: %s", err)
 	}
@@ -392,6 +437,22 @@ func TestFastForward(t *testing.T) {
 		}
 		t.Fatalf("nodes[0].LastConsensusRound should be at least %d. Got %s", target, disp)
 	}
+
+	//the App of node 0 resumes from the state of its peer
+	if nodes[0].blockIndex == 0 {
+		t.Fatal("nodes[0] should have restored a snapshot of the App")
+	}
+	block, err := nodes[0].GetBlock(nodes[0].blockIndex - 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(block.StateHash) == 0 {
+		t.Fatalf("Block %d should have the state hash of the peer", block.Index)
+	}
+	//and drops the same copies of transactions
+	if len(nodes[0].commitDedup.window()) == 0 {
+		t.Fatal("nodes[0] should have the commit deduplication window of its peer")
+	}
 }
 
 func TestCatchUp(t *testing.T) {
@@ -437,7 +498,7 @@ func TestShutdown(t *testing.T) {
 
 	nodes[0].Shutdown()
 
-	err := nodes[1].gossip(nodes[0].localAddr)
+	err := nodes[1].gossip(context.Background(), nodes[0].localAddr)
 	if err == nil {
 		t.Fatal("Expected Timeout Error")
 	}
@@ -538,6 +599,7 @@ func checkGossip(nodes []*Node, t *testing.T) {
 }
 
 func makeRandomTransactions(nodes []*Node, quit chan struct{}) {
+	rnd := rand.New(rand.NewSource(common.TestSeed()))
 	go func() {
 		seq := make(map[int]int)
 		for {
@@ -545,7 +607,7 @@ func makeRandomTransactions(nodes []*Node, quit chan struct{}) {
 			case <-quit:
 				return
 			default:
-				n := rand.Intn(len(nodes))
+				n := rnd.Intn(len(nodes))
 				node := nodes[n]
 				submitTransaction(node, []byte(fmt.Sprintf("node%d transaction %d", n, seq[n])))
 				seq[n] = seq[n] + 1
@@ -571,3 +633,125 @@ func BenchmarkGossip(b *testing.B) {
 		gossip(nodes, 5, true, 3*time.Second)
 	}
 }
+
+//blockProxy records the genesis state and the Blocks committed by a node
+type blockProxy struct {
+	submitCh   chan []byte
+	metadataCh chan []byte
+	genesis    [][]byte
+	blocks     []hg.Block
+}
+
+func (p *blockProxy) SubmitCh() chan []byte {
+	return p.submitCh
+}
+
+func (p *blockProxy) MetadataCh() chan []byte {
+	return p.metadataCh
+}
+
+func (p *blockProxy) CommitBlock(block hg.Block) ([]byte, error) {
+	p.blocks = append(p.blocks, block)
+	return []byte{byte(block.Index)}, nil
+}
+
+func (p *blockProxy) InitChain(appState []byte) error {
+	p.genesis = append(p.genesis, appState)
+	return nil
+}
+
+func (p *blockProxy) GetSnapshot(blockIndex int) ([]byte, error) {
+	return []byte{byte(blockIndex)}, nil
+}
+
+func (p *blockProxy) Restore(snapshot []byte) error {
+	return nil
+}
+
+func TestKeyAlgorithms(t *testing.T) {
+	_, ecdsaPeers := initPeers(1)
+	key, _ := crypto.GenerateKeyPair(crypto.Ed25519)
+	peers := []net.Peer{{NetAddr: "127.0.0.1:9990", PubKeyHex: crypto.KeyPairPubKeyHex(key)}}
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	//a node with an Ed25519 key signs its Events with it
+	conf := TestConfig(t)
+	node := NewNode(conf, key, peers, trans, aproxy.NewInmemAppProxy(conf.Logger))
+	if err := node.Init(); err != nil {
+		t.Fatal(err)
+	}
+	head, err := node.core.GetHead()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := head.Verify(); err != nil || !ok {
+		t.Fatalf("Event signed with Ed25519 should be valid, got %v, %v", ok, err)
+	}
+
+	//but not in a cluster of ECDSA keys
+	mixed := NewNode(conf, key, append(peers, ecdsaPeers...), trans, aproxy.NewInmemAppProxy(conf.Logger))
+	if err := mixed.Init(); err == nil {
+		t.Fatal("Cluster with keys of several algorithms should be refused")
+	}
+}
+
+func TestGenesisState(t *testing.T) {
+	keys, peers := initPeers(1)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	conf := TestConfig(t)
+	conf.GenesisState = []byte("genesis")
+	prox := &blockProxy{submitCh: make(chan []byte)}
+	node := NewNode(conf, keys[0], peers, trans, prox)
+	if err := node.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(prox.genesis, [][]byte{[]byte("genesis")}) {
+		t.Fatalf("App should receive the genesis state once, got %q", prox.genesis)
+	}
+}
+
+func TestCommitBlocks(t *testing.T) {
+	keys, peers := initPeers(1)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	prox := &blockProxy{submitCh: make(chan []byte)}
+	node := NewNode(TestConfig(t), keys[0], peers, trans, prox)
+
+	event := func(rr int, txs ...string) hg.Event {
+		ev := hg.NewEvent([][]byte{}, []string{"", ""}, []byte{}, 0)
+		for _, tx := range txs {
+			ev.Body.Transactions = append(ev.Body.Transactions, []byte(tx))
+		}
+		ev.SetRoundReceived(rr)
+		return ev
+	}
+
+	//Events received in the same round make one Block, and rounds without
+	//transactions make none
+	events := []hg.Event{event(1, "a"), event(1, "b", "c"), event(2), event(3, "d")}
+	if err := node.commit(events); err != nil {
+		t.Fatal(err)
+	}
+	if err := node.commit([]hg.Event{event(4, "e")}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []hg.Block{
+		hg.NewBlock(0, 1, [][]byte{[]byte("a"), []byte("b"), []byte("c")}),
+		hg.NewBlock(1, 3, [][]byte{[]byte("d")}),
+		hg.NewBlock(2, 4, [][]byte{[]byte("e")}),
+	}
+	if !reflect.DeepEqual(prox.blocks, expected) {
+		t.Fatalf("Committed Blocks should be %v, not %v", expected, prox.blocks)
+	}
+	if i := node.GetStats()["last_block_index"]; i != "2" {
+		t.Fatalf("last_block_index should be 2, not %s", i)
+	}
+}
@@ -0,0 +1,127 @@
+package node
+
+import (
+	"fmt"
+
+	"github.com/babbleio/babble/crypto"
+	"github.com/babbleio/babble/net"
+)
+
+/*
+An Observer follows the hashgraph of the participants without taking part in
+consensus. Its key is not among the participants, it never creates Events, and
+it only pulls Events from its peers, so it adds capacity to serve reads - the
+service, Blocks and subscriptions - without slowing consensus down.
+
+Observers are used to scale reads out quickly by cloning a running node:
+
+ 1. the new node gets the participants of the source from its /Clone endpoint,
+    which is what 'babble clone' writes to the peers.json of the new datadir,
+ 2. it starts with Config.Observer and Config.CloneFrom set to the source, and
+    fast-forwards from it: the source sends its last Frame and a snapshot of its
+    App, like to any participant that catches up,
+ 3. it then keeps pulling the Events that followed from the participants, and
+    commits the same Blocks as they do.
+
+The source keeps gossiping throughout: it only holds its core lock for the time
+it takes to copy the Frame. Peers that require TLS only accept the keys of the
+participants, so they do not serve Observers.
+
+Without CloneFrom, an Observer follows the hashgraph from genesis instead: it
+pulls every Event from the participants, computes the consensus order like them
+and commits every Block to its App, which is what indexers need. Participants
+with a SyncLimit make it fast-forward once the hashgraph is too large, unless
+they are archive nodes, see archive.go. With the badger store, an Observer that
+restarts carries on from its store, like a participant.
+*/
+
+//ObserverPolicy never creates Events. It is the policy of Observers.
+type ObserverPolicy struct{}
+
+func (p ObserverPolicy) ShouldCreateEvent(ctx EventContext) bool {
+	return false
+}
+
+//observer is true if c follows the hashgraph without creating Events
+func (c *Core) observer() bool {
+	_, ok := c.eventPolicy.(ObserverPolicy)
+	return ok
+}
+
+//CloneInfo is what a new Observer needs to follow the same hashgraph as a
+//node
+type CloneInfo struct {
+	Source             net.Peer   //the node, to fast-forward from
+	Peers              []net.Peer //the participants, source included
+	LastBlock          int        //index of the last Block committed by the source
+	LastConsensusRound int
+}
+
+//CloneInfo returns the CloneInfo of the node
+func (n *Node) CloneInfo() CloneInfo {
+	n.coreLock.RLock()
+	self := net.Peer{
+		NetAddr:      n.localAddr,
+		PubKeyHex:    n.core.HexID(),
+		KeyAlgorithm: n.core.key.Algorithm(),
+	}
+	isParticipant := n.core.IsParticipant(self.PubKeyHex)
+	lcr := -1
+	if r := n.core.GetLastConsensusRoundIndex(); r != nil {
+		lcr = *r
+	}
+	n.coreLock.RUnlock()
+
+	n.selectorLock.Lock()
+	peers := append([]net.Peer{}, n.peerSelector.Peers()...)
+	n.selectorLock.Unlock()
+	if isParticipant {
+		peers = append(peers, self)
+	}
+
+	lastBlock, _ := n.watermark.get()
+
+	return CloneInfo{
+		Source:             self,
+		Peers:              peers,
+		LastBlock:          lastBlock,
+		LastConsensusRound: lcr,
+	}
+}
+
+//initObserver starts an Observer with an empty store. A clone fast-forwards,
+//as it has no Events of its own to start from, the others start from genesis.
+func (n *Node) initObserver() error {
+	n.logger.WithField("clone_from", n.conf.CloneFrom).Debug("Init Observer")
+	if n.conf.CloneFrom == "" {
+		return n.initFresh()
+	}
+	return n.setState(CatchingUp)
+}
+
+//withCloneSource puts Config.CloneFrom first among the sources of the first
+//fast-forward of an Observer
+func (n *Node) withCloneSource(sources []fastForwardSource) []fastForwardSource {
+	if n.conf.CloneFrom == "" || n.cloned {
+		return sources
+	}
+	res := []fastForwardSource{{addr: n.conf.CloneFrom, round: -1}}
+	for _, s := range sources {
+		if s.addr != n.conf.CloneFrom {
+			res = append(res, s)
+		}
+	}
+	return res
+}
+
+//checkObserverKey returns an error if the key of an Observer is one of the
+//participants, which would fork its sequence of Events
+func checkObserverKey(key crypto.KeyPair, participants []net.Peer) error {
+	pub := crypto.KeyPairPubKeyHex(key)
+	for _, p := range participants {
+		if p.PubKeyHex == pub {
+			return fmt.Errorf("The key of an Observer must not be a participant")
+		}
+	}
+	return nil
+}
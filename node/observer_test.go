@@ -0,0 +1,153 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/crypto"
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestObserverClone(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	_, nodes := initNodes(3, 1000, logger)
+	defer shutdownNodes(nodes)
+
+	target := 20
+	if err := gossip(nodes, target, false, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	info := nodes[0].CloneInfo()
+	if len(info.Peers) != 3 || info.Source.NetAddr != nodes[0].localAddr || info.LastConsensusRound < target {
+		t.Fatalf("CloneInfo should list the 3 participants from round %d, got %+v", target, info)
+	}
+
+	conf := NewConfig(5*time.Millisecond, time.Second, 1000, 1000, logger)
+	conf.Observer = true
+	conf.CloneFrom = info.Source.NetAddr
+	key, _ := crypto.GenerateKeyPair(crypto.ECDSAP256)
+	trans, err := net.NewTCPTransport(fmt.Sprintf("127.0.0.1:%d", ip), nil, 2, time.Second, nil, logger)
+	ip++
+	if err != nil {
+		t.Fatal(err)
+	}
+	observer := NewNode(conf, key, info.Peers, trans, aproxy.NewInmemAppProxy(logger))
+	defer observer.Shutdown()
+	if err := observer.Init(); err != nil {
+		t.Fatal(err)
+	}
+	observer.RunAsync(true)
+
+	//the participants carry on while the observer clones node 0
+	if err := bombardAndWait(nodes, target+20, 6*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(6 * time.Second)
+	for {
+		r := observer.core.GetLastConsensusRoundIndex()
+		if r != nil && *r >= target+20 {
+			break
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("Observer should follow the participants to round %d", target+20)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	if !observer.cloned || observer.id != -1 || observer.core.Head != "" {
+		t.Fatal("Observer should have cloned node 0 without creating Events")
+	}
+	if nodes[0].core.IsParticipant(observer.core.HexID()) {
+		t.Fatal("Observer should not be a participant")
+	}
+	if !observer.conf.Capabilities.Has(net.CapObserver) {
+		t.Fatal("Observer should advertise CapObserver")
+	}
+}
+
+func TestObserverGenesis(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	_, nodes := initNodes(3, 1000, logger)
+	defer shutdownNodes(nodes)
+
+	conf := NewConfig(5*time.Millisecond, time.Second, 1000, 1000, logger)
+	conf.Observer = true
+	key, _ := crypto.GenerateKeyPair(crypto.ECDSAP256)
+	trans, err := net.NewTCPTransport(fmt.Sprintf("127.0.0.1:%d", ip), nil, 2, time.Second, nil, logger)
+	ip++
+	if err != nil {
+		t.Fatal(err)
+	}
+	peers := []net.Peer{}
+	for _, n := range nodes {
+		peers = append(peers, net.Peer{NetAddr: n.localAddr, PubKeyHex: n.core.HexID()})
+	}
+	observer := NewNode(conf, key, peers, trans, aproxy.NewInmemAppProxy(logger))
+	defer observer.Shutdown()
+	if err := observer.Init(); err != nil {
+		t.Fatal(err)
+	}
+	observer.RunAsync(true)
+	runNodes(nodes, true)
+
+	target := 10
+	if err := bombardAndWait(nodes, target, 6*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	timeout := time.After(6 * time.Second)
+	for {
+		r := observer.core.GetLastConsensusRoundIndex()
+		if r != nil && *r >= target {
+			break
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("Observer should follow the participants to round %d", target)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	//the observer has the whole history, not what followed a Frame
+	if observer.cloned || observer.core.Head != "" {
+		t.Fatal("Observer should not have fast-forwarded or created Events")
+	}
+	observerEvents := observer.core.GetConsensusEvents()
+	participantEvents := nodes[0].core.GetConsensusEvents()
+	if len(observerEvents) == 0 || len(participantEvents) < len(observerEvents) {
+		t.Fatalf("Observer should have a prefix of the %d consensus Events, got %d",
+			len(participantEvents), len(observerEvents))
+	}
+	for i, e := range observerEvents {
+		if participantEvents[i] != e {
+			t.Fatalf("Consensus Event %d should be %s, not %s", i, participantEvents[i], e)
+		}
+	}
+	observerTxs, _ := getCommittedTransactions(&observer)
+	participantTxs, _ := getCommittedTransactions(nodes[0])
+	if len(observerTxs) == 0 || len(participantTxs) < len(observerTxs) {
+		t.Fatalf("Observer should commit a prefix of the %d transactions, got %d",
+			len(participantTxs), len(observerTxs))
+	}
+	if observer.GetStats()["observer"] != "true" {
+		t.Fatal("The observer stat should be true")
+	}
+}
+
+func TestObserverKey(t *testing.T) {
+	keys, peers := initPeers(2)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	conf := TestConfig(t)
+	conf.Observer = true
+	node := NewNode(conf, keys[0], peers, trans, &blockProxy{submitCh: make(chan []byte)})
+	if err := node.Init(); err == nil {
+		t.Fatal("Observer with the key of a participant should fail to start")
+	}
+}
@@ -0,0 +1,238 @@
+package node
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+/*
+Events received from a peer whose parents are not in the hashgraph yet are
+orphans. Instead of failing the whole sync, the node inserts the Events that do
+not depend on them and keeps the orphans aside, to insert them after the next
+syncs if their parents arrived in the meantime.
+
+The node first asks the peer that sent them for their missing ancestors with a
+FetchRequest, if the peer supports it (see fetch.go). If they are still waiting
+after orphanRetryBeats heartbeats, the next gossip goes to another peer than the
+ones that sent them, which sends the missing parents if it has them. Orphans that waited while the hashgraph advanced by more
+than Config.OrphanRounds rounds are discarded, and so are the oldest ones beyond
+maxOrphans.
+*/
+
+const (
+	orphanRetryBeats = 3
+	maxOrphans       = 10000
+)
+
+type orphan struct {
+	event     hg.WireEvent
+	from      string
+	round     int       //last round of the hashgraph when it was received
+	requested time.Time //when it was received or its parents last re-requested
+}
+
+type orphanPool struct {
+	sync.Mutex
+	orphans   []orphan
+	keys      map[[2]int]bool //[creator id, index] of the orphans
+	discarded int
+	fetching  map[string]bool //peers asked for missing parents right now
+}
+
+func newOrphanPool() *orphanPool {
+	return &orphanPool{
+		keys:     make(map[[2]int]bool),
+		fetching: make(map[string]bool),
+	}
+}
+
+func orphanKey(we hg.WireEvent) [2]int {
+	return [2]int{we.Body.CreatorID, we.Body.Index}
+}
+
+//add keeps events received from a peer until their parents arrive
+func (p *orphanPool) add(from string, events []hg.WireEvent, round int, now time.Time) {
+	p.Lock()
+	defer p.Unlock()
+	for _, we := range events {
+		if p.keys[orphanKey(we)] {
+			continue
+		}
+		p.keys[orphanKey(we)] = true
+		p.orphans = append(p.orphans, orphan{event: we, from: from, round: round, requested: now})
+	}
+	if extra := len(p.orphans) - maxOrphans; extra > 0 {
+		for _, o := range p.orphans[:extra] {
+			delete(p.keys, orphanKey(o.event))
+		}
+		p.orphans = p.orphans[extra:]
+		p.discarded += extra
+	}
+}
+
+//take removes and returns all the orphans, in the order they were received
+func (p *orphanPool) take() []orphan {
+	p.Lock()
+	defer p.Unlock()
+	orphans := p.orphans
+	p.orphans = nil
+	p.keys = make(map[[2]int]bool)
+	return orphans
+}
+
+//keep puts back the orphans that were not inserted, unless the hashgraph
+//advanced by more than maxRounds since they were received. It returns the
+//number of orphans discarded.
+func (p *orphanPool) keep(orphans []orphan, round int, maxRounds int) int {
+	p.Lock()
+	defer p.Unlock()
+	discarded := 0
+	for _, o := range orphans {
+		if round-o.round > maxRounds {
+			discarded++
+			continue
+		}
+		if p.keys[orphanKey(o.event)] {
+			continue
+		}
+		p.keys[orphanKey(o.event)] = true
+		p.orphans = append(p.orphans, o)
+	}
+	p.discarded += discarded
+	return discarded
+}
+
+//due returns the peers that sent orphans received or re-requested before
+//since, and counts the orphans as re-requested now
+func (p *orphanPool) due(since time.Time, now time.Time) map[string]bool {
+	p.Lock()
+	defer p.Unlock()
+	peers := make(map[string]bool)
+	for i := range p.orphans {
+		if p.orphans[i].requested.Before(since) {
+			peers[p.orphans[i].from] = true
+			p.orphans[i].requested = now
+		}
+	}
+	return peers
+}
+
+//startFetch returns the orphans received from peer, unless the node is already
+//fetching their parents, in which case it returns nil. A non-empty result must
+//be followed by endFetch.
+func (p *orphanPool) startFetch(peer string) []hg.WireEvent {
+	p.Lock()
+	defer p.Unlock()
+	if p.fetching[peer] {
+		return nil
+	}
+	events := []hg.WireEvent{}
+	for _, o := range p.orphans {
+		if o.from == peer {
+			events = append(events, o.event)
+		}
+	}
+	if len(events) > 0 {
+		p.fetching[peer] = true
+	}
+	return events
+}
+
+func (p *orphanPool) endFetch(peer string) {
+	p.Lock()
+	defer p.Unlock()
+	delete(p.fetching, peer)
+}
+
+func (p *orphanPool) stats() (orphans, discarded int) {
+	p.Lock()
+	defer p.Unlock()
+	return len(p.orphans), p.discarded
+}
+
+//splitOrphans returns the events that can be inserted and keeps the others
+//aside. Must be called with the coreLock held.
+func (n *Node) splitOrphans(from string, events []hg.WireEvent) []hg.WireEvent {
+	ready, orphans := n.core.SplitOrphans(events)
+	if len(orphans) > 0 {
+		n.orphans.add(from, orphans, n.core.hg.Store.LastRound(), time.Now())
+		n.logger.WithFields(logrus.Fields{
+			"from":    from,
+			"orphans": len(orphans),
+		}).Debug("Events with missing parents")
+	}
+	return ready
+}
+
+//insertOrphans inserts the orphans whose parents arrived and discards the
+//expired ones. Must be called with the coreLock held.
+func (n *Node) insertOrphans() error {
+	orphans := n.orphans.take()
+	if len(orphans) == 0 {
+		return nil
+	}
+
+	inserted := 0
+	for {
+		events := make([]hg.WireEvent, len(orphans))
+		for i, o := range orphans {
+			events[i] = o.event
+		}
+		ready, _ := n.core.SplitOrphans(events)
+		if len(ready) == 0 {
+			break
+		}
+		readyKeys := make(map[[2]int]bool, len(ready))
+		for _, we := range ready {
+			readyKeys[orphanKey(we)] = true
+		}
+		rest := []orphan{}
+		for _, o := range orphans {
+			if !readyKeys[orphanKey(o.event)] {
+				rest = append(rest, o)
+			}
+		}
+		if err := n.core.Backfill(ready); err != nil {
+			n.orphans.keep(rest, n.core.hg.Store.LastRound(), n.conf.OrphanRounds)
+			return err
+		}
+		inserted += len(ready)
+		orphans = rest
+	}
+
+	discarded := n.orphans.keep(orphans, n.core.hg.Store.LastRound(), n.conf.OrphanRounds)
+	n.metrics.orphansDiscarded(discarded)
+	if inserted > 0 || discarded > 0 {
+		n.logger.WithFields(logrus.Fields{
+			"inserted":  inserted,
+			"discarded": discarded,
+			"waiting":   len(orphans) - discarded,
+		}).Debug("Orphans")
+	}
+	if inserted == 0 {
+		return nil
+	}
+	return n.core.RunConsensus()
+}
+
+//gossipPeer returns the next peer to gossip with, avoiding the peers that sent
+//orphans still waiting for their parents after orphanRetryBeats heartbeats
+func (n *Node) gossipPeer() net.Peer {
+	now := time.Now()
+	avoid := n.orphans.due(now.Add(-orphanRetryBeats*n.heartbeat()), now)
+
+	n.selectorLock.Lock()
+	defer n.selectorLock.Unlock()
+	peer := n.peerSelector.Next()
+	for i := 0; avoid[peer.NetAddr] && i < len(n.peerSelector.Peers()); i++ {
+		peer = n.peerSelector.Next()
+	}
+	if len(avoid) > 0 {
+		n.logger.WithField("peer", peer.NetAddr).Debug("Re-requesting parents of orphans")
+	}
+	return peer
+}
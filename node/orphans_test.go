@@ -0,0 +1,47 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	hg "github.com/babbleio/babble/hashgraph"
+)
+
+func wireEvent(creator, index int) hg.WireEvent {
+	return hg.WireEvent{Body: hg.WireBody{CreatorID: creator, Index: index}}
+}
+
+func TestOrphanPool(t *testing.T) {
+	pool := newOrphanPool()
+	start := time.Now()
+
+	pool.add("a", []hg.WireEvent{wireEvent(0, 1), wireEvent(0, 2)}, 5, start)
+	pool.add("b", []hg.WireEvent{wireEvent(0, 2), wireEvent(1, 1)}, 7, start.Add(time.Second))
+	if orphans, _ := pool.stats(); orphans != 3 {
+		t.Fatalf("Orphans received twice should be kept once, got %d orphans", orphans)
+	}
+
+	//only the orphans of a are due, and they are not due again right away
+	due := pool.due(start.Add(500*time.Millisecond), start.Add(time.Second))
+	if len(due) != 1 || !due["a"] {
+		t.Fatalf("Orphans of a should be due, got %v", due)
+	}
+	if due := pool.due(start.Add(500*time.Millisecond), start.Add(time.Second)); len(due) != 0 {
+		t.Fatalf("No orphans should be due after a re-request, got %v", due)
+	}
+
+	//the orphans received in round 5 expire after round 8
+	orphans := pool.take()
+	if len(orphans) != 3 || orphans[0].event.Body.Index != 1 {
+		t.Fatalf("take should return the orphans in order, got %v", orphans)
+	}
+	if discarded := pool.keep(orphans, 8, 3); discarded != 0 {
+		t.Fatalf("No orphans should be discarded in round 8, got %d", discarded)
+	}
+	if discarded := pool.keep(pool.take(), 9, 3); discarded != 2 {
+		t.Fatalf("2 orphans should be discarded in round 9, got %d", discarded)
+	}
+	if orphans, discarded := pool.stats(); orphans != 1 || discarded != 2 {
+		t.Fatalf("Expected 1 orphan and 2 discarded, got %d and %d", orphans, discarded)
+	}
+}
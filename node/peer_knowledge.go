@@ -0,0 +1,102 @@
+package node
+
+import (
+	"sync"
+	"time"
+
+	hg "github.com/babbleio/babble/hashgraph"
+)
+
+/*
+The Known map of a peer can be stale by the time the node sends it Events: with
+FanOut, concurrent syncs or a pull followed by a push, the peer may have sent
+the same Events to the node, or received them from it, since it computed the
+map. Every node records, for each peer, the last Event of each creator that the
+peer is known to have from recent exchanges: the Events the peer sent, and the
+ones it accepted in an EagerSync. The diffs sent to the peer start after the
+highest of the two, and the Events skipped that way are counted in its
+PeerTraffic.
+
+Events are inserted in order for every creator, so a peer that has an Event has
+all the previous Events of its creator. The Known map of a peer only grows: when
+a peer reports less than before, it lost Events, for example when it restarted
+without a persistent store, and its record is dropped. Records also expire after
+peerKnowledgeTTL without an exchange.
+*/
+
+//time after which what a peer is known to have is forgotten
+const peerKnowledgeTTL = 30 * time.Second
+
+type peerKnowledge struct {
+	l     sync.Mutex
+	peers map[string]*knownEvents //[net addr] => what the peer has
+	now   func() time.Time
+}
+
+type knownEvents struct {
+	last     map[int]int //[creator id] => index of the last Event the peer has
+	reported map[int]int //[creator id] => highest index in the Known maps of the peer
+	updated  time.Time
+}
+
+func newPeerKnowledge() *peerKnowledge {
+	return &peerKnowledge{
+		peers: make(map[string]*knownEvents),
+		now:   time.Now,
+	}
+}
+
+//must be called with k.l locked
+func (k *peerKnowledge) get(peer string) *knownEvents {
+	ke, ok := k.peers[peer]
+	if !ok || k.now().Sub(ke.updated) > peerKnowledgeTTL {
+		ke = &knownEvents{
+			last:     make(map[int]int),
+			reported: make(map[int]int),
+		}
+		k.peers[peer] = ke
+	}
+	ke.updated = k.now()
+	return ke
+}
+
+//record notes that peer has events, because it sent them or accepted them
+func (k *peerKnowledge) record(peer string, events []hg.WireEvent) {
+	if len(events) == 0 {
+		return
+	}
+	k.l.Lock()
+	defer k.l.Unlock()
+	ke := k.get(peer)
+	for _, e := range events {
+		if last, ok := ke.last[e.Body.CreatorID]; !ok || e.Body.Index > last {
+			ke.last[e.Body.CreatorID] = e.Body.Index
+		}
+	}
+}
+
+//known completes the Known map of peer with the Events it is known to have,
+//and returns how many Events of the diff are skipped that way
+func (k *peerKnowledge) known(peer string, known map[int]int) (map[int]int, int) {
+	k.l.Lock()
+	defer k.l.Unlock()
+	ke := k.get(peer)
+	for id, index := range known {
+		if reported, ok := ke.reported[id]; ok && index < reported {
+			delete(k.peers, peer)
+			return known, 0
+		}
+	}
+
+	res := make(map[int]int, len(known))
+	skipped := 0
+	for id, index := range known {
+		ke.reported[id] = index
+		res[id] = index
+		if last, ok := ke.last[id]; ok && last > index {
+			res[id] = last
+			skipped += last - index
+		}
+	}
+	return res, skipped
+}
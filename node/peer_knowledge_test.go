@@ -0,0 +1,54 @@
+package node
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	hg "github.com/babbleio/babble/hashgraph"
+)
+
+func wireEventsOf(creator int, indexes ...int) []hg.WireEvent {
+	events := []hg.WireEvent{}
+	for _, i := range indexes {
+		events = append(events, hg.WireEvent{Body: hg.WireBody{CreatorID: creator, Index: i}})
+	}
+	return events
+}
+
+func TestPeerKnowledge(t *testing.T) {
+	k := newPeerKnowledge()
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	k.now = func() time.Time { return now }
+
+	//nothing recorded, the Known map is used as is
+	known, skipped := k.known("A", map[int]int{0: 3, 1: 2})
+	if !reflect.DeepEqual(known, map[int]int{0: 3, 1: 2}) || skipped != 0 {
+		t.Fatalf("Known map should not change, got %v and %d skipped", known, skipped)
+	}
+
+	//A sent Events of creator 0 up to 6, and accepted Events of creator 2
+	k.record("A", wireEventsOf(0, 4, 5, 6))
+	k.record("A", wireEventsOf(2, 0, 1))
+	known, skipped = k.known("A", map[int]int{0: 3, 1: 2, 2: -1})
+	expected := map[int]int{0: 6, 1: 2, 2: 1}
+	if !reflect.DeepEqual(known, expected) || skipped != 5 {
+		t.Fatalf("Known map should be %v with 5 skipped, got %v and %d", expected, known, skipped)
+	}
+	if known, _ = k.known("B", map[int]int{0: 3}); known[0] != 3 {
+		t.Fatalf("The Events of A should not apply to B, got %v", known)
+	}
+
+	//A Known map that goes back means A lost Events
+	known, skipped = k.known("A", map[int]int{0: 1, 1: 2, 2: -1})
+	if !reflect.DeepEqual(known, map[int]int{0: 1, 1: 2, 2: -1}) || skipped != 0 {
+		t.Fatalf("What A had should be forgotten, got %v and %d skipped", known, skipped)
+	}
+
+	//records expire
+	k.record("A", wireEventsOf(0, 7))
+	now = now.Add(peerKnowledgeTTL + time.Second)
+	if known, _ = k.known("A", map[int]int{0: 1}); known[0] != 1 {
+		t.Fatalf("Expired records should not apply, got %v", known)
+	}
+}
@@ -2,45 +2,304 @@ package node
 
 import (
 	"math/rand"
+	"time"
 
 	"github.com/babbleio/babble/net"
 )
 
+//PeerSelector picks the peers a node gossips with, with one of the strategies
+//of NewPeerSelector. The node calls it with its selectorLock held.
 type PeerSelector interface {
 	Peers() []net.Peer
 	UpdateLast(peer string)
 	Next() net.Peer
+	Synced(peer string, lag int)
+	UpdateAddresses(peers []net.Peer)
+	AddPeer(peer net.Peer)
+	RemovePeer(pubKey string)
+	Seed(seed int64)
+	SetLocalZone(zone string, affinity float64)
+	SetZone(peer string, zone string)
+	Zones() (peers map[string]int, local, remote int)
+	SetMaxActive(max int)
+	Rotate() (out, in string)
+}
+
+//BackoffSelector is implemented by the PeerSelectors that back off from peers
+//that keep failing, like the ones of NewPeerSelector. The node reports the
+//result of every exchange to them.
+type BackoffSelector interface {
+	MarkFailure(peer string)
+	MarkSuccess(peer string)
 }
 
 //+++++++++++++++++++++++++++++++++++++++
-//RANDOM
+//PEER SET
 
-type RandomPeerSelector struct {
-	peers []net.Peer
-	last  string
+//peerSet is what the strategies share: the peers, their back-off and zones,
+//and the syncs with each of them
+type peerSet struct {
+	peers   []net.Peer
+	last    string
+	backoff *peerBackoff
+	rand    *rand.Rand
+	zones   *zoneBias
+	syncs   int            //syncs and picks so far
+	synced  map[string]int //[net addr] => value of syncs at the last sync or pick of the peer
+	lags    map[string]int //[net addr] => Events the peer was missing at the last sync
+	//peers gossiped with when maxActive limits them, see active_peers.go
+	maxActive int
+	active    map[string]bool
 }
 
-func NewRandomPeerSelector(participants []net.Peer, localAddr string) *RandomPeerSelector {
+func newPeerSet(participants []net.Peer, localAddr string) *peerSet {
 	_, peers := net.ExcludePeer(participants, localAddr)
-	return &RandomPeerSelector{
-		peers: peers,
+	return &peerSet{
+		peers:   peers,
+		backoff: newPeerBackoff(defaultBackoffBase, defaultBackoffMax),
+		rand:    rand.New(rand.NewSource(time.Now().UnixNano())),
+		zones:   newZoneBias("", 0),
+		synced:  make(map[string]int),
+		lags:    make(map[string]int),
+		active:  make(map[string]bool),
 	}
 }
 
-func (ps *RandomPeerSelector) Peers() []net.Peer {
+//Seed makes the sequence of selected peers reproducible
+func (ps *peerSet) Seed(seed int64) {
+	ps.rand = rand.New(rand.NewSource(seed))
+}
+
+func (ps *peerSet) Peers() []net.Peer {
 	return ps.peers
 }
 
-func (ps *RandomPeerSelector) UpdateLast(peer string) {
+func (ps *peerSet) UpdateLast(peer string) {
 	ps.last = peer
 }
 
-func (ps *RandomPeerSelector) Next() net.Peer {
-	selectablePeers := ps.peers
+//MarkFailure records a failed exchange (timeout, SyncLimit) with peer
+func (ps *peerSet) MarkFailure(peer string) {
+	ps.backoff.failure(peer)
+}
+
+//MarkSuccess records a successful exchange with peer and clears its back-off
+func (ps *peerSet) MarkSuccess(peer string) {
+	ps.backoff.success(peer)
+}
+
+//Synced records a sync with peer, requested by either side, after which it
+//was still missing lag of the Events of the node
+func (ps *peerSet) Synced(peer string, lag int) {
+	ps.touch(peer)
+	ps.lags[peer] = lag
+}
+
+//touch makes peer the most recently synced or picked
+func (ps *peerSet) touch(peer string) {
+	ps.syncs++
+	ps.synced[peer] = ps.syncs
+}
+
+//UpdateAddresses changes the address of known peers, matched by public key,
+//when they have moved. Unknown peers are ignored.
+func (ps *peerSet) UpdateAddresses(peers []net.Peer) {
+	addrs := make(map[string]string)
+	for _, p := range peers {
+		addrs[p.PubKeyHex] = p.NetAddr
+	}
+	for i, p := range ps.peers {
+		addr, ok := addrs[p.PubKeyHex]
+		if !ok || addr == p.NetAddr {
+			continue
+		}
+		ps.backoff.success(p.NetAddr)
+		if ps.last == p.NetAddr {
+			ps.last = addr
+		}
+		ps.synced[addr], ps.lags[addr] = ps.synced[p.NetAddr], ps.lags[p.NetAddr]
+		if ps.active[p.NetAddr] {
+			ps.active[addr] = true
+		}
+		ps.forget(p.NetAddr)
+		ps.peers[i].NetAddr = addr
+	}
+}
+
+//AddPeer starts selecting a participant added at runtime. A known peer is
+//only updated with its new address.
+func (ps *peerSet) AddPeer(peer net.Peer) {
+	for _, p := range ps.peers {
+		if p.PubKeyHex == peer.PubKeyHex {
+			ps.UpdateAddresses([]net.Peer{peer})
+			return
+		}
+	}
+	ps.peers = append(ps.peers, peer)
+}
+
+//RemovePeer stops selecting a participant removed at runtime
+func (ps *peerSet) RemovePeer(pubKey string) {
+	peers := []net.Peer{}
+	for _, p := range ps.peers {
+		if p.PubKeyHex == pubKey {
+			ps.backoff.success(p.NetAddr)
+			if ps.last == p.NetAddr {
+				ps.last = ""
+			}
+			ps.forget(p.NetAddr)
+			continue
+		}
+		peers = append(peers, p)
+	}
+	ps.peers = peers
+}
+
+func (ps *peerSet) forget(peer string) {
+	delete(ps.synced, peer)
+	delete(ps.lags, peer)
+	delete(ps.active, peer)
+}
+
+//candidates returns the peers a strategy picks from: the active ones that are
+//not backed off, without the last one if possible, narrowed down by zone. It is
+//empty when every peer is backed off.
+func (ps *peerSet) candidates() []net.Peer {
+	selectablePeers := ps.activePeers()
 	if len(selectablePeers) > 1 {
 		_, selectablePeers = net.ExcludePeer(selectablePeers, ps.last)
 	}
-	i := rand.Intn(len(selectablePeers))
-	peer := selectablePeers[i]
-	return peer
+
+	healthy := []net.Peer{}
+	for _, p := range selectablePeers {
+		if !ps.backoff.backedOff(p.NetAddr) {
+			healthy = append(healthy, p)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	return ps.zones.pick(healthy, ps.rand)
+}
+
+//pick returns the peer chosen by a strategy, or, when every peer is backed
+//off, the one that is due the soonest
+func (ps *peerSet) pick(peer *net.Peer) net.Peer {
+	if peer == nil {
+		return ps.backoff.soonest(ps.peers)
+	}
+	ps.zones.picked(peer.NetAddr)
+	return *peer
+}
+
+//SetLocalZone sets the zone of this node and the share of the peers selected
+//within it, when there are peers in several zones
+func (ps *peerSet) SetLocalZone(zone string, affinity float64) {
+	ps.zones.local = zone
+	ps.zones.affinity = affinity
+}
+
+//SetZone records the zone advertised by peer
+func (ps *peerSet) SetZone(peer string, zone string) {
+	ps.zones.set(peer, zone)
+}
+
+//Zones returns the number of peers in each zone and the number of times a
+//peer of the local zone and of another zone was selected. Peers that did not
+//advertise a zone yet are counted in the empty zone.
+func (ps *peerSet) Zones() (peers map[string]int, local, remote int) {
+	peers = make(map[string]int)
+	for _, p := range ps.peers {
+		peers[ps.zones.zones[p.NetAddr]]++
+	}
+	return peers, ps.zones.localPicks, ps.zones.remotePicks
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//RANDOM
+
+type RandomPeerSelector struct {
+	*peerSet
+}
+
+func NewRandomPeerSelector(participants []net.Peer, localAddr string) *RandomPeerSelector {
+	return &RandomPeerSelector{newPeerSet(participants, localAddr)}
+}
+
+//Next picks a random peer among those that are not backed off, avoiding the
+//last one if possible. When every peer is backed off, the one that is due the
+//soonest is returned.
+func (ps *RandomPeerSelector) Next() net.Peer {
+	healthy := ps.candidates()
+	if len(healthy) == 0 {
+		return ps.pick(nil)
+	}
+	return ps.pick(&healthy[ps.rand.Intn(len(healthy))])
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//BACKOFF
+
+const (
+	//consecutive failures before a peer is backed off
+	backoffThreshold   = 2
+	defaultBackoffBase = 1 * time.Second
+	defaultBackoffMax  = 1 * time.Minute
+)
+
+//peerBackoff keeps track of consecutive failures per peer. After
+//backoffThreshold failures, a peer is not selected for a delay that doubles
+//with every new failure, up to max.
+type peerBackoff struct {
+	base     time.Duration
+	max      time.Duration
+	failures map[string]int       //[net addr] => consecutive failures
+	retryAt  map[string]time.Time //[net addr] => end of back-off
+	now      func() time.Time
+}
+
+func newPeerBackoff(base, max time.Duration) *peerBackoff {
+	return &peerBackoff{
+		base:     base,
+		max:      max,
+		failures: make(map[string]int),
+		retryAt:  make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+func (b *peerBackoff) failure(peer string) {
+	b.failures[peer]++
+	f := b.failures[peer]
+	if f < backoffThreshold {
+		return
+	}
+	delay := b.base
+	for i := backoffThreshold; i < f && delay < b.max; i++ {
+		delay *= 2
+	}
+	if delay > b.max {
+		delay = b.max
+	}
+	b.retryAt[peer] = b.now().Add(delay)
+}
+
+func (b *peerBackoff) success(peer string) {
+	delete(b.failures, peer)
+	delete(b.retryAt, peer)
+}
+
+func (b *peerBackoff) backedOff(peer string) bool {
+	t, ok := b.retryAt[peer]
+	return ok && b.now().Before(t)
+}
+
+func (b *peerBackoff) soonest(peers []net.Peer) net.Peer {
+	res := peers[0]
+	for _, p := range peers[1:] {
+		if b.retryAt[p.NetAddr].Before(b.retryAt[res.NetAddr]) {
+			res = p
+		}
+	}
+	return res
 }
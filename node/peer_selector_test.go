@@ -0,0 +1,193 @@
+package node
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/net"
+)
+
+func TestPeerBackoff(t *testing.T) {
+	now := time.Now()
+	b := newPeerBackoff(time.Second, 10*time.Second)
+	b.now = func() time.Time { return now }
+
+	b.failure("a")
+	if b.backedOff("a") {
+		t.Fatal("A single failure should not back off a peer")
+	}
+
+	expected := []time.Duration{1, 2, 4, 8, 10, 10}
+	for i, d := range expected {
+		b.failure("a")
+		if r := b.retryAt["a"].Sub(now); r != d*time.Second {
+			t.Fatalf("Back-off %d should be %s, not %s", i, d*time.Second, r)
+		}
+		if !b.backedOff("a") {
+			t.Fatalf("Peer should be backed off after %d failures", i+2)
+		}
+	}
+
+	now = now.Add(11 * time.Second)
+	if b.backedOff("a") {
+		t.Fatal("Peer should not be backed off once the delay has passed")
+	}
+
+	b.success("a")
+	if f := b.failures["a"]; f != 0 {
+		t.Fatalf("Success should clear failures, got %d", f)
+	}
+}
+
+func TestRandomPeerSelectorSeed(t *testing.T) {
+	peers := []net.Peer{}
+	for i := 0; i < 10; i++ {
+		peers = append(peers, net.Peer{NetAddr: fmt.Sprintf("peer%d", i)})
+	}
+
+	sequence := func(seed int64) []string {
+		ps := NewRandomPeerSelector(peers, "peer0")
+		ps.Seed(seed)
+		res := []string{}
+		for i := 0; i < 20; i++ {
+			p := ps.Next()
+			ps.UpdateLast(p.NetAddr)
+			res = append(res, p.NetAddr)
+		}
+		return res
+	}
+
+	if a, b := sequence(42), sequence(42); !reflect.DeepEqual(a, b) {
+		t.Fatalf("The same seed should select the same peers, got %v and %v", a, b)
+	}
+	if a, b := sequence(42), sequence(43); reflect.DeepEqual(a, b) {
+		t.Fatalf("Different seeds should select different peers, got %v twice", a)
+	}
+}
+
+func TestRandomPeerSelectorBackoff(t *testing.T) {
+	peers := []net.Peer{}
+	for i := 0; i < 4; i++ {
+		peers = append(peers, net.Peer{NetAddr: fmt.Sprintf("peer%d", i)})
+	}
+	ps := NewRandomPeerSelector(peers, "peer0")
+
+	//back off peer1 and peer2
+	for i := 0; i < 3; i++ {
+		ps.MarkFailure("peer1")
+		ps.MarkFailure("peer2")
+	}
+	for i := 0; i < 20; i++ {
+		if p := ps.Next(); p.NetAddr != "peer3" {
+			t.Fatalf("Only the healthy peer3 should be selected, got %s", p.NetAddr)
+		}
+	}
+
+	//when every peer is backed off, the one due the soonest is selected
+	ps.MarkFailure("peer3")
+	ps.MarkFailure("peer3")
+	if p := ps.Next(); p.NetAddr != "peer3" {
+		t.Fatalf("peer3 has the shortest back-off and should be selected, got %s", p.NetAddr)
+	}
+
+	ps.MarkSuccess("peer1")
+	found := false
+	for i := 0; i < 20; i++ {
+		if ps.Next().NetAddr == "peer1" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatal("peer1 should be selectable again after a success")
+	}
+}
+
+func TestRandomPeerSelectorUpdateAddresses(t *testing.T) {
+	peers := []net.Peer{
+		{NetAddr: "10.0.0.1:1337", PubKeyHex: "0xAA"},
+		{NetAddr: "10.0.0.2:1337", PubKeyHex: "0xBB"},
+	}
+	ps := NewRandomPeerSelector(peers, "10.0.0.1:1337")
+	ps.UpdateLast("10.0.0.2:1337")
+
+	ps.UpdateAddresses([]net.Peer{
+		{NetAddr: "10.0.0.9:1337", PubKeyHex: "0xBB"},
+		{NetAddr: "10.0.0.8:1337", PubKeyHex: "0xCC"},
+	})
+
+	if l := len(ps.Peers()); l != 1 {
+		t.Fatalf("The set of peers should not change, got %d peers", l)
+	}
+	if p := ps.Next(); p.NetAddr != "10.0.0.9:1337" {
+		t.Fatalf("Peer 0xBB should have moved to 10.0.0.9:1337, not %s", p.NetAddr)
+	}
+	if ps.last != "10.0.0.9:1337" {
+		t.Fatalf("Last peer should follow the new address, got %s", ps.last)
+	}
+}
+
+func TestRandomPeerSelectorAddRemovePeer(t *testing.T) {
+	peers := []net.Peer{
+		{NetAddr: "10.0.0.1:1337", PubKeyHex: "0xAA"},
+		{NetAddr: "10.0.0.2:1337", PubKeyHex: "0xBB"},
+	}
+	ps := NewRandomPeerSelector(peers, "10.0.0.1:1337")
+
+	ps.AddPeer(net.Peer{NetAddr: "10.0.0.3:1337", PubKeyHex: "0xCC"})
+	ps.AddPeer(net.Peer{NetAddr: "10.0.0.9:1337", PubKeyHex: "0xBB"})
+
+	selectable := ps.Peers()
+	if len(selectable) != 2 {
+		t.Fatalf("There should be 2 peers, not %d", len(selectable))
+	}
+	if selectable[0].NetAddr != "10.0.0.9:1337" || selectable[1].PubKeyHex != "0xCC" {
+		t.Fatalf("Unexpected peers %v", selectable)
+	}
+
+	ps.UpdateLast("10.0.0.9:1337")
+	ps.RemovePeer("0xBB")
+	for i := 0; i < 10; i++ {
+		if p := ps.Next(); p.PubKeyHex != "0xCC" {
+			t.Fatalf("Removed peer %s should not be selected", p.PubKeyHex)
+		}
+	}
+}
+
+func TestRandomPeerSelectorZones(t *testing.T) {
+	peers := []net.Peer{}
+	for i := 0; i < 6; i++ {
+		peers = append(peers, net.Peer{NetAddr: fmt.Sprintf("peer%d", i)})
+	}
+	ps := NewRandomPeerSelector(peers, "peer0")
+	ps.Seed(42)
+	ps.SetLocalZone("eu", 0.8)
+	//peer1 and peer2 are local, peer3 and peer4 remote, peer5 unknown
+	ps.SetZone("peer1", "eu")
+	ps.SetZone("peer2", "eu")
+	ps.SetZone("peer3", "us")
+	ps.SetZone("peer4", "us")
+
+	counts := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		counts[ps.Next().NetAddr]++
+	}
+	local := counts["peer1"] + counts["peer2"]
+	if local < 700 || local > 900 {
+		t.Fatalf("About 80%% of the peers should be local, not %d/1000", local)
+	}
+	for _, p := range []string{"peer3", "peer4", "peer5"} {
+		if counts[p] == 0 {
+			t.Fatalf("Remote and unknown peers should still be selected: %v", counts)
+		}
+	}
+
+	zones, l, r := ps.Zones()
+	if zones["eu"] != 2 || zones["us"] != 2 || zones[""] != 1 {
+		t.Fatalf("Zones should count 2 eu, 2 us and 1 unknown peers, not %v", zones)
+	}
+	if l != local || l+r != 1000 {
+		t.Fatalf("Zones should count %d local picks out of 1000, not %d and %d", local, l, r)
+	}
+}
@@ -0,0 +1,146 @@
+package node
+
+import (
+	"fmt"
+
+	"github.com/babbleio/babble/net"
+)
+
+/*
+Config.PeerSelection chooses how a node picks the peer of each gossip:
+
+ - random, the default, picks any peer,
+ - round-robin goes through the peers in turn, so that each one is synced with
+   once every len(peers) gossips,
+ - least-recent picks the peer with the oldest sync, counting the syncs that
+   the peers requested too: a peer that just pulled from the node has little to
+   gain from a sync,
+ - lag-weighted picks at random, with each peer weighted by 1 plus the number
+   of Events of the node it was missing at its last sync, so that the peers
+   falling behind catch up first.
+
+Whatever the strategy, peers that are backed off are skipped, the last peer is
+avoided if there is another one, and the peers of the local zone are preferred
+as set by Config.ZoneAffinity. The lag of a peer is measured after each sync,
+from the Known maps exchanged with it.
+*/
+
+//NewPeerSelector returns the PeerSelector of a strategy: random, round-robin,
+//least-recent or lag-weighted. Empty means random.
+func NewPeerSelector(strategy string, participants []net.Peer, localAddr string) (PeerSelector, error) {
+	switch strategy {
+	case "", "random":
+		return NewRandomPeerSelector(participants, localAddr), nil
+	case "round-robin":
+		return &RoundRobinPeerSelector{peerSet: newPeerSet(participants, localAddr)}, nil
+	case "least-recent":
+		return &LeastRecentPeerSelector{newPeerSet(participants, localAddr)}, nil
+	case "lag-weighted":
+		return &LagWeightedPeerSelector{newPeerSet(participants, localAddr)}, nil
+	default:
+		return nil, fmt.Errorf("Unknown peer selection %s", strategy)
+	}
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//ROUND-ROBIN
+
+type RoundRobinPeerSelector struct {
+	*peerSet
+	next int //position in peers where the next turn starts
+}
+
+//Next picks the first candidate from the position after the previous pick
+func (ps *RoundRobinPeerSelector) Next() net.Peer {
+	candidates := ps.candidates()
+	if len(candidates) == 0 {
+		return ps.pick(nil)
+	}
+	selectable := make(map[string]bool, len(candidates))
+	for _, p := range candidates {
+		selectable[p.NetAddr] = true
+	}
+	for i := 0; i < len(ps.peers); i++ {
+		pos := (ps.next + i) % len(ps.peers)
+		if selectable[ps.peers[pos].NetAddr] {
+			ps.next = (pos + 1) % len(ps.peers)
+			return ps.pick(&ps.peers[pos])
+		}
+	}
+	return ps.pick(&candidates[0])
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//LEAST-RECENT
+
+type LeastRecentPeerSelector struct {
+	*peerSet
+}
+
+//Next picks the candidate synced or picked the longest ago, at random among
+//the ones never synced
+func (ps *LeastRecentPeerSelector) Next() net.Peer {
+	candidates := ps.candidates()
+	if len(candidates) == 0 {
+		return ps.pick(nil)
+	}
+	oldest := []int{}
+	for i, p := range candidates {
+		switch {
+		case len(oldest) == 0 || ps.synced[p.NetAddr] < ps.synced[candidates[oldest[0]].NetAddr]:
+			oldest = []int{i}
+		case ps.synced[p.NetAddr] == ps.synced[candidates[oldest[0]].NetAddr]:
+			oldest = append(oldest, i)
+		}
+	}
+	peer := &candidates[oldest[ps.rand.Intn(len(oldest))]]
+	//picked peers count as synced, so that a round with several peers does not
+	//pick the same one again
+	ps.touch(peer.NetAddr)
+	return ps.pick(peer)
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//LAG-WEIGHTED
+
+type LagWeightedPeerSelector struct {
+	*peerSet
+}
+
+//Next picks a random candidate, weighted by 1 plus its lag
+func (ps *LagWeightedPeerSelector) Next() net.Peer {
+	candidates := ps.candidates()
+	if len(candidates) == 0 {
+		return ps.pick(nil)
+	}
+	total := 0
+	for _, p := range candidates {
+		total += 1 + ps.lags[p.NetAddr]
+	}
+	r := ps.rand.Intn(total)
+	for i, p := range candidates {
+		r -= 1 + ps.lags[p.NetAddr]
+		if r < 0 {
+			return ps.pick(&candidates[i])
+		}
+	}
+	return ps.pick(&candidates[len(candidates)-1])
+}
+
+//peerSynced tells the peer selector that peer synced with the node, which then
+//knew known while the peer knew peerKnown
+func (n *Node) peerSynced(peer string, known, peerKnown map[int]int) {
+	lag := 0
+	for id, index := range known {
+		theirs, ok := peerKnown[id]
+		if !ok {
+			theirs = -1
+		}
+		if index > theirs {
+			lag += index - theirs
+		}
+	}
+	n.selectorLock.Lock()
+	n.peerSelector.Synced(peer, lag)
+	n.selectorLock.Unlock()
+}
@@ -0,0 +1,145 @@
+package node
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func strategyPeers(n int) []net.Peer {
+	peers := []net.Peer{}
+	for i := 0; i < n; i++ {
+		peers = append(peers, net.Peer{NetAddr: fmt.Sprintf("peer%d", i), PubKeyHex: fmt.Sprintf("0x%02X", i)})
+	}
+	return peers
+}
+
+func newTestPeerSelector(t *testing.T, strategy string, peers []net.Peer) PeerSelector {
+	ps, err := NewPeerSelector(strategy, peers, "peer0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ps.Seed(1)
+	return ps
+}
+
+//picks returns the next count peers, as the gossip of the node selects them
+func picks(ps PeerSelector, count int) []string {
+	res := []string{}
+	for i := 0; i < count; i++ {
+		p := ps.Next()
+		ps.UpdateLast(p.NetAddr)
+		res = append(res, p.NetAddr)
+	}
+	return res
+}
+
+func TestNewPeerSelector(t *testing.T) {
+	for _, s := range []string{"", "random", "round-robin", "least-recent", "lag-weighted"} {
+		if _, err := NewPeerSelector(s, nil, ""); err != nil {
+			t.Fatalf("Strategy %q should exist: %s", s, err)
+		}
+	}
+	if _, err := NewPeerSelector("fastest", nil, ""); err == nil {
+		t.Fatal("Unknown strategy should be refused")
+	}
+	conf := TestConfig(t)
+	conf.PeerSelection = "fastest"
+	if err := conf.Validate(); err == nil {
+		t.Fatal("Config with an unknown strategy should be invalid")
+	}
+}
+
+func TestRoundRobinPeerSelector(t *testing.T) {
+	ps := newTestPeerSelector(t, "round-robin", strategyPeers(4))
+	expected := []string{"peer1", "peer2", "peer3", "peer1", "peer2", "peer3"}
+	if p := picks(ps, 6); !reflect.DeepEqual(p, expected) {
+		t.Fatalf("Peers should be picked in turn, expected %v, got %v", expected, p)
+	}
+
+	//backed off peers lose their turn
+	ps.(BackoffSelector).MarkFailure("peer2")
+	ps.(BackoffSelector).MarkFailure("peer2")
+	expected = []string{"peer1", "peer3", "peer1", "peer3"}
+	if p := picks(ps, 4); !reflect.DeepEqual(p, expected) {
+		t.Fatalf("peer2 should be skipped, expected %v, got %v", expected, p)
+	}
+}
+
+func TestLeastRecentPeerSelector(t *testing.T) {
+	ps := newTestPeerSelector(t, "least-recent", strategyPeers(5))
+
+	//peer3 and peer4 synced with the node, the others are picked first
+	ps.Synced("peer3", 0)
+	ps.Synced("peer4", 0)
+	first := picks(ps, 2)
+	if first[0] == first[1] || first[0] == "peer3" || first[0] == "peer4" || first[1] == "peer3" || first[1] == "peer4" {
+		t.Fatalf("Peers never synced should be picked first, got %v", first)
+	}
+	if p := picks(ps, 2); !reflect.DeepEqual(p, []string{"peer3", "peer4"}) {
+		t.Fatalf("Peers should then be picked by their last sync, got %v", p)
+	}
+
+	//several picks in a round go to different peers, all but the last one
+	seen := map[string]bool{}
+	for i := 0; i < 3; i++ {
+		seen[ps.Next().NetAddr] = true
+	}
+	if len(seen) != 3 || seen["peer4"] {
+		t.Fatalf("Consecutive picks should go to every peer but peer4, got %v", seen)
+	}
+}
+
+func TestLagWeightedPeerSelector(t *testing.T) {
+	peers := strategyPeers(4)
+	ps := newTestPeerSelector(t, "lag-weighted", peers)
+	ps.Synced("peer1", 0)
+	ps.Synced("peer2", 0)
+	ps.Synced("peer3", 98)
+
+	counts := map[string]int{}
+	for _, p := range picks(ps, 1000) {
+		counts[p]++
+	}
+	//peer3 is weighted 99 against 1 for the others, but the last peer is
+	//avoided, so it is picked about every other time
+	if counts["peer3"] < 400 || counts["peer1"] == 0 || counts["peer2"] == 0 {
+		t.Fatalf("The lagging peer3 should be preferred, got %v", counts)
+	}
+
+	//the lag follows the peer when it moves
+	moved := peers[3]
+	moved.NetAddr = "peer3b"
+	ps.UpdateAddresses([]net.Peer{moved})
+	ps.UpdateLast("peer1")
+	counts = map[string]int{}
+	for i := 0; i < 100; i++ {
+		counts[ps.Next().NetAddr]++
+	}
+	if counts["peer3b"] < 80 {
+		t.Fatalf("The lag should follow the new address, got %v", counts)
+	}
+}
+
+func TestPeerSynced(t *testing.T) {
+	keys, peers := initPeers(2)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	conf := TestConfig(t)
+	conf.PeerSelection = "lag-weighted"
+	node := NewNode(conf, keys[0], peers, trans, aproxy.NewInmemAppProxy(conf.Logger))
+
+	node.peerSynced(peers[1].NetAddr, map[int]int{0: 10, 1: 4}, map[int]int{0: 6, 1: 5})
+	if lag := node.peerSelector.(*LagWeightedPeerSelector).lags[peers[1].NetAddr]; lag != 4 {
+		t.Fatalf("Peer should be 4 Events behind, got %d", lag)
+	}
+	node.peerSynced(peers[1].NetAddr, map[int]int{0: 1}, map[int]int{})
+	if lag := node.peerSelector.(*LagWeightedPeerSelector).lags[peers[1].NetAddr]; lag != 2 {
+		t.Fatalf("Peer knowing nothing should be 2 Events behind, got %d", lag)
+	}
+}
@@ -0,0 +1,111 @@
+package node
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+//Profile is a coherent set of tuning parameters for a type of deployment
+type Profile struct {
+	Name               string
+	HeartbeatTimeout   time.Duration
+	TCPTimeout         time.Duration
+	FastForwardTimeout time.Duration //FastForward responses carry a whole Frame
+	MaxPool            int           //pooled connections per peer in the transport
+	CacheSize          int
+	SyncLimit          int
+	SyncBytesLimit     int
+	MaxEventPayload    int
+	StallTimeout       time.Duration
+	InboundSyncs       int     //syncs from peers processed at the same time
+	InsertChunk        int     //events of a backfill inserted between consensus runs
+	ConsensusCPUShare  float64 //share of time spent computing consensus. 0 means no cap
+}
+
+var profiles = map[string]Profile{
+	//small devices: little memory, slow links, few transactions
+	"embedded": {
+		Name:               "embedded",
+		HeartbeatTimeout:   2000 * time.Millisecond,
+		TCPTimeout:         2000 * time.Millisecond,
+		FastForwardTimeout: 2 * time.Minute,
+		MaxPool:            1,
+		CacheSize:          100,
+		SyncLimit:          100,
+		SyncBytesLimit:     1024 * 1024,
+		MaxEventPayload:    64 * 1024,
+		StallTimeout:       2 * time.Minute,
+		InboundSyncs:       1,
+		InsertChunk:        25,
+		ConsensusCPUShare:  0.5,
+	},
+	//the defaults of the babble command
+	"standard": {
+		Name:               "standard",
+		HeartbeatTimeout:   1000 * time.Millisecond,
+		TCPTimeout:         1000 * time.Millisecond,
+		FastForwardTimeout: 30 * time.Second,
+		MaxPool:            2,
+		CacheSize:          1000,
+		SyncLimit:          1000,
+		SyncBytesLimit:     16 * 1024 * 1024,
+		MaxEventPayload:    1024 * 1024,
+		StallTimeout:       time.Minute,
+		InboundSyncs:       2,
+		InsertChunk:        100,
+	},
+	//servers on a fast network with a steady flow of transactions. The
+	//caches must hold a few rounds worth of events, which grow with the
+	//gossip frequency.
+	"high-throughput": {
+		Name:               "high-throughput",
+		HeartbeatTimeout:   10 * time.Millisecond,
+		TCPTimeout:         1000 * time.Millisecond,
+		FastForwardTimeout: 30 * time.Second,
+		MaxPool:            8,
+		CacheSize:          10000,
+		SyncLimit:          5000,
+		SyncBytesLimit:     64 * 1024 * 1024,
+		MaxEventPayload:    4 * 1024 * 1024,
+		StallTimeout:       30 * time.Second,
+		InboundSyncs:       4,
+		InsertChunk:        500,
+	},
+}
+
+//GetProfile returns the profile called name
+func GetProfile(name string) (Profile, error) {
+	p, ok := profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("Unknown profile %s. Available profiles: %s",
+			name, strings.Join(ProfileNames(), ", "))
+	}
+	return p, nil
+}
+
+//ProfileNames returns the names of the available profiles
+func ProfileNames() []string {
+	names := []string{}
+	for n := range profiles {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+//Config returns a Config with the parameters of the profile
+func (p Profile) Config(logger *logrus.Logger) *Config {
+	conf := NewConfig(p.HeartbeatTimeout, p.TCPTimeout, p.CacheSize, p.SyncLimit, logger)
+	conf.SyncBytesLimit = p.SyncBytesLimit
+	conf.MaxEventPayload = p.MaxEventPayload
+	conf.StallTimeout = p.StallTimeout
+	conf.InboundSyncs = p.InboundSyncs
+	conf.InsertChunk = p.InsertChunk
+	conf.ConsensusCPUShare = p.ConsensusCPUShare
+	conf.EventPolicy = EverySyncPolicy{}
+	return conf
+}
@@ -0,0 +1,40 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/Sirupsen/logrus"
+)
+
+func TestProfiles(t *testing.T) {
+	if _, err := GetProfile("huge"); err == nil {
+		t.Fatal("Unknown profile should fail")
+	}
+
+	for _, name := range ProfileNames() {
+		p, err := GetProfile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conf := p.Config(logrus.New())
+		if err := conf.Validate(); err != nil {
+			t.Fatalf("Profile %s: %s", name, err)
+		}
+		if conf.HeartbeatTimeout <= 0 || conf.TCPTimeout <= 0 || conf.CacheSize <= 0 ||
+			conf.SyncLimit <= 0 || p.MaxPool <= 0 {
+			t.Fatalf("Profile %s should set every parameter", name)
+		}
+		//a sync must fit in the caches of the receiver
+		if conf.SyncLimit > conf.CacheSize {
+			t.Fatalf("Profile %s: SyncLimit %d exceeds CacheSize %d", name, conf.SyncLimit, conf.CacheSize)
+		}
+		//a Frame takes longer to transfer than a sync
+		if p.FastForwardTimeout < p.TCPTimeout {
+			t.Fatalf("Profile %s: FastForwardTimeout is shorter than TCPTimeout", name)
+		}
+		//an event must fit in a sync
+		if conf.MaxEventPayload > conf.SyncBytesLimit {
+			t.Fatalf("Profile %s: MaxEventPayload exceeds SyncBytesLimit", name)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package node
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+	hg "github.com/babbleio/babble/hashgraph"
+)
+
+/*
+The badger store keeps every Event the node ever received. Unless the node is
+an archive node, see archive.go, a background job prunes it every
+PruneInterval: it deletes the Events and Rounds below a decided round, moves
+the Roots of the store up to the witnesses of that round, and compacts the
+database, see hashgraph/prune.go. Node.Prune does the same on demand, for
+operators through the admin channel.
+
+The pruned round is PruneRetention rounds below both the last consensus round
+and the round of the last final Block, so history is only dropped once a
+super-majority of the validators signed the state that results from it. A node
+that does not collect Block signatures, like one that fast-forwarded without
+the Block numbering of its peers, does not prune. Neither are the rounds of the
+Events that are not decided yet.
+
+The node still holds the last consensus round and the Events above it, which is
+what a Frame is made of, so it keeps serving fast-forwards. Peers that miss
+Events below the pruned round are told they are too far behind, and fast-forward
+instead of syncing.
+*/
+
+//DefaultPruneInterval is the PruneInterval used when Config.PruneInterval is
+//0
+const DefaultPruneInterval = time.Minute
+
+//DefaultPruneRetention is the PruneRetention used when Config.PruneRetention
+//is 0
+const DefaultPruneRetention = 100
+
+//ErrNotPrunable is returned by Prune when the store of the node can not be
+//pruned
+var ErrNotPrunable = errors.New("Store can not be pruned")
+
+//PruneResult describes a pruning of the store
+type PruneResult struct {
+	Round    int //Events and Rounds below this round were deleted, -1 if nothing was
+	Events   int //Events deleted
+	Duration time.Duration
+}
+
+//Prune deletes the history of the store below the round PruneRetention rounds
+//before the last consensus round and the last final Block, and compacts the
+//store. Nothing is deleted when that round was already pruned.
+func (n *Node) Prune() (PruneResult, error) {
+	res := PruneResult{Round: -1}
+	if n.conf.Archive {
+		return res, ErrArchive
+	}
+	pruner, ok := n.core.hg.Store.(hg.Pruner)
+	if !ok {
+		return res, ErrNotPrunable
+	}
+	start := time.Now()
+	//read before the coreLock, which checking Block signatures takes
+	final := n.blocks.finalRound()
+
+	n.coreLock.Lock()
+	round := n.pruneRound(final)
+	if round <= n.prunedRound {
+		n.coreLock.Unlock()
+		return res, nil
+	}
+	roots, err := n.core.hg.PruneRoots(round)
+	if err == nil {
+		res.Events, err = pruner.Prune(roots, round)
+	}
+	if err == nil {
+		res.Round = round
+		n.prunedRound = round
+		n.prunedEvents += res.Events
+	}
+	n.coreLock.Unlock()
+	if err != nil {
+		return res, err
+	}
+
+	n.compactStore()
+	res.Duration = time.Since(start)
+	n.logger.WithFields(logrus.Fields{
+		"round":    res.Round,
+		"events":   res.Events,
+		"duration": res.Duration,
+	}).Info("Pruned store")
+	return res, nil
+}
+
+//pruneRound returns the round below which the store can be pruned, given the
+//RoundReceived of the last final Block. It must be called with the coreLock
+//held.
+func (n *Node) pruneRound(final int) int {
+	lcr := n.core.GetLastConsensusRoundIndex()
+	if lcr == nil || final < 0 {
+		return -1
+	}
+	round := *lcr
+	if final < round {
+		round = final
+	}
+	round -= n.conf.pruneRetention()
+	for _, e := range n.core.GetUndeterminedEvents() {
+		if r := n.core.hg.Round(e); r < round {
+			round = r
+		}
+	}
+	return round
+}
+
+//pruneStore is the job run every PruneInterval
+func (n *Node) pruneStore() error {
+	if _, err := n.Prune(); err != nil {
+		n.logger.WithField("error", err).Error("Pruning store")
+		return err
+	}
+	return nil
+}
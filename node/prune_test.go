@@ -0,0 +1,95 @@
+package node
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestPrune(t *testing.T) {
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(4)
+	newNode := func(i int) *Node {
+		conf := NewConfig(5*time.Millisecond, time.Second, 1000, 1000, logger)
+		conf.Seed = common.TestSeed()
+		conf.Store = "badger"
+		conf.StorePath = filepath.Join(dir, fmt.Sprintf("node%d", i))
+		conf.PruneRetention = 2
+		trans, err := net.NewTCPTransport(peers[i].NetAddr, nil, 2, time.Second, nil, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node := NewNode(conf, keys[i], peers, trans, aproxy.NewInmemAppProxy(logger))
+		if err := node.Init(); err != nil {
+			t.Fatal(err)
+		}
+		return &node
+	}
+
+	nodes := []*Node{}
+	for i := range peers {
+		nodes = append(nodes, newNode(i))
+	}
+	if err := gossip(nodes, 8, false, 10*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := nodes[0].Prune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Round < 0 || res.Events == 0 {
+		t.Fatalf("The node should prune its store, got %+v", res)
+	}
+	if stats := nodes[0].GetStats(); stats["pruned_round"] != fmt.Sprint(res.Round) {
+		t.Fatalf("pruned_round should be %d, got %s", res.Round, stats["pruned_round"])
+	}
+	if again, err := nodes[0].Prune(); err != nil || again.Round >= 0 && again.Round <= res.Round {
+		t.Fatalf("The pruned rounds should not be pruned again, got %+v (%v)", again, err)
+	}
+	shutdownNodes(nodes)
+
+	//restart node 0 from its pruned store
+	before := nodes[0].core
+	node := newNode(0)
+	defer node.Shutdown()
+	if !reflect.DeepEqual(node.core.Known(), before.Known()) {
+		t.Fatalf("Known should be %v, not %v", before.Known(), node.core.Known())
+	}
+	if missing := node.core.Missing(map[int]int{0: -1, 1: -1, 2: -1, 3: -1}); len(missing) == 0 {
+		t.Fatal("A peer without Events should be too far behind the pruned store")
+	}
+}
+
+func TestPruneInmem(t *testing.T) {
+	_, nodes := initNodes(1, 1000, common.NewTestLogger(t))
+	defer shutdownNodes(nodes)
+	if _, err := nodes[0].Prune(); err != ErrNotPrunable {
+		t.Fatalf("The inmem store should not be pruned, got %v", err)
+	}
+
+	conf := TestConfig(t)
+	conf.PruneInterval = time.Minute
+	if err := conf.Validate(); err == nil {
+		t.Fatal("PruneInterval should require the badger store")
+	}
+	conf.Store = "badger"
+	conf.StorePath = "badger_db"
+	if err := conf.Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,49 @@
+package node
+
+import (
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+/*
+A push sends the diff of a peer in topological order, so that the peer can
+insert each part as it arrives: the parents of an Event are either known to the
+peer or earlier in the diff. The diff is split in batches of at most
+Config.PushBatch Events and of the Bytes of the sync limits of the peer, like
+the responses of the pull path, and the batches are sent one after the other.
+Every EagerSync request but the last is marked More: the peer backfills it
+without creating an Event, and creates its new head on top of the last batch
+only, as after a single request.
+
+Peers that do not advertise CapPushBatches get the first batch only, as before;
+they receive the rest at the next gossip.
+*/
+
+//pushBatches splits the Events pushed to peer into the batches of its
+//EagerSync requests. There is always at least one batch, possibly empty.
+func (n *Node) pushBatches(peer string, events []hg.WireEvent, limits net.SyncLimits) [][]hg.WireEvent {
+	batches := splitWireEvents(events, n.conf.PushBatch, limits.Bytes)
+	if len(batches) == 0 {
+		return [][]hg.WireEvent{events}
+	}
+	if !n.peerSupports(peer, net.CapPushBatches) {
+		return batches[:1]
+	}
+	return batches
+}
+
+//splitWireEvents splits events in consecutive batches of at most maxEvents
+//Events and maxBytes bytes. Each batch holds at least one Event. Zero limits are
+//ignored.
+func splitWireEvents(events []hg.WireEvent, maxEvents, maxBytes int) [][]hg.WireEvent {
+	batches := [][]hg.WireEvent{}
+	for len(events) > 0 {
+		batch := truncateWireEvents(events, maxBytes)
+		if maxEvents > 0 && len(batch) > maxEvents {
+			batch = batch[:maxEvents]
+		}
+		batches = append(batches, batch)
+		events = events[len(batch):]
+	}
+	return batches
+}
@@ -0,0 +1,105 @@
+package node
+
+import (
+	"context"
+	"testing"
+
+	"github.com/babbleio/babble/common"
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+func TestSplitWireEvents(t *testing.T) {
+	events := []hg.WireEvent{}
+	for i := 0; i < 5; i++ {
+		events = append(events, hg.WireEvent{Body: hg.WireBody{Index: i}})
+	}
+
+	if batches := splitWireEvents(events, 2, 0); len(batches) != 3 || len(batches[2]) != 1 {
+		t.Fatalf("5 Events should be split in batches of 2, 2 and 1, got %v", batches)
+	}
+	if batches := splitWireEvents(events, 0, 2*wireEventOverhead); len(batches) != 3 {
+		t.Fatalf("The byte limit should split 5 Events in 3 batches, got %d", len(batches))
+	}
+	//the smallest limit wins, and every Event is sent once, in order
+	batches := splitWireEvents(events, 4, 3*wireEventOverhead)
+	next := 0
+	for _, b := range batches {
+		if len(b) > 3 {
+			t.Fatalf("Batches should hold at most 3 Events, got %d", len(b))
+		}
+		for _, e := range b {
+			if e.Body.Index != next {
+				t.Fatalf("Event %d should come next, got %d", next, e.Body.Index)
+			}
+			next++
+		}
+	}
+	if next != 5 {
+		t.Fatalf("All 5 Events should be in the batches, got %d", next)
+	}
+	if batches := splitWireEvents(nil, 2, 0); len(batches) != 0 {
+		t.Fatalf("No Events should make no batch, got %v", batches)
+	}
+}
+
+func TestPushBatches(t *testing.T) {
+	_, nodes := initNodes(2, 1000, common.NewTestLogger(t))
+	runNodes(nodes, false)
+	defer shutdownNodes(nodes)
+	sender, receiver := nodes[0], nodes[1]
+	sender.conf.PushBatch = 2
+
+	sender.coreLock.Lock()
+	initial := sender.core.Known()
+	for i := 0; i < 5; i++ {
+		sender.core.AddTransactions([][]byte{[]byte{byte(i)}})
+		if err := sender.core.AddSelfEvent(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	senderKnown := sender.core.Known()
+	sender.coreLock.Unlock()
+	//the ids of the nodes in the Known maps
+	senderID, receiverID := 0, 1
+	if senderKnown[0] == initial[0] {
+		senderID, receiverID = 1, 0
+	}
+
+	receiver.coreLock.RLock()
+	before := receiver.core.Known()
+	receiver.coreLock.RUnlock()
+
+	//without CapPushBatches, only the first batch is sent
+	if err := sender.push(context.Background(), receiver.localAddr, before); err != nil {
+		t.Fatal(err)
+	}
+	receiver.coreLock.RLock()
+	known := receiver.core.Known()
+	receiver.coreLock.RUnlock()
+	if known[senderID] != before[senderID]+2 {
+		t.Fatalf("The receiver should have 2 more Events of the sender, got %v after %v", known, before)
+	}
+	if known[receiverID] != before[receiverID]+1 {
+		t.Fatalf("The receiver should have created a head, got %v", known)
+	}
+
+	//with it, the rest follows in batches, with a single new head on top
+	sender.conf.Capabilities |= net.CapPushBatches
+	sender.setPeerCapabilities(receiver.localAddr, net.CapPushBatches)
+	if err := sender.push(context.Background(), receiver.localAddr, known); err != nil {
+		t.Fatal(err)
+	}
+	receiver.coreLock.RLock()
+	after := receiver.core.Known()
+	receiver.coreLock.RUnlock()
+	if after[senderID] != senderKnown[senderID] {
+		t.Fatalf("The receiver should have every Event of the sender, %v, got %v", senderKnown, after)
+	}
+	if after[receiverID] != known[receiverID]+1 {
+		t.Fatalf("The receiver should have created a single head, got %v after %v", after, known)
+	}
+	if !NewDefaultConfig().Capabilities.Has(net.CapPushBatches) {
+		t.Fatal("Nodes should advertise CapPushBatches by default")
+	}
+}
@@ -0,0 +1,104 @@
+package node
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+/*
+Operators tune a live cluster without restarting its nodes. Reload changes the
+parameters that are safe to change at runtime:
+
+ - HeartbeatTimeout, from the next tick of the control timer on, keeping the
+   jitter and the idle backoff,
+ - SyncLimit, for the next syncs, which also tells the peers the new limit,
+ - the level of the logger, which is shared by everything that logs with it.
+
+The new values are checked with Validate, against the rest of the Config, before
+any of them applies. The Config itself is left untouched, since embedders may
+share it between nodes; the node keeps the values it runs with, and reports them
+in the heartbeat and sync_limit stats.
+*/
+
+//Reloadable holds the parameters Reload changes. Zero values are left
+//unchanged.
+type Reloadable struct {
+	HeartbeatTimeout time.Duration
+	SyncLimit        int
+	LogLevel         string //debug, info, warn, error, fatal or panic
+}
+
+//tuning holds the reloadable parameters the node runs with
+type tuning struct {
+	lock      sync.RWMutex
+	heartbeat time.Duration
+	syncLimit int
+}
+
+func newTuning(conf *Config) *tuning {
+	return &tuning{
+		heartbeat: conf.HeartbeatTimeout,
+		syncLimit: conf.SyncLimit,
+	}
+}
+
+//heartbeat is the HeartbeatTimeout the node runs with
+func (n *Node) heartbeat() time.Duration {
+	n.tuning.lock.RLock()
+	defer n.tuning.lock.RUnlock()
+	return n.tuning.heartbeat
+}
+
+//syncLimit is the SyncLimit the node runs with
+func (n *Node) syncLimit() int {
+	n.tuning.lock.RLock()
+	defer n.tuning.lock.RUnlock()
+	return n.tuning.syncLimit
+}
+
+//Reload applies the parameters of r that are set, or none of them if they do
+//not make a valid Config, see reload.go
+func (n *Node) Reload(r Reloadable) error {
+	var level logrus.Level
+	var err error
+	if r.LogLevel != "" {
+		if level, err = logrus.ParseLevel(r.LogLevel); err != nil {
+			return err
+		}
+	}
+
+	n.tuning.lock.Lock()
+	conf := *n.conf
+	conf.HeartbeatTimeout = n.tuning.heartbeat
+	conf.SyncLimit = n.tuning.syncLimit
+	if r.HeartbeatTimeout != 0 {
+		conf.HeartbeatTimeout = r.HeartbeatTimeout
+	}
+	if r.SyncLimit != 0 {
+		conf.SyncLimit = r.SyncLimit
+	}
+	if err := conf.Validate(); err != nil {
+		n.tuning.lock.Unlock()
+		return err
+	}
+	n.tuning.heartbeat = conf.HeartbeatTimeout
+	n.tuning.syncLimit = conf.SyncLimit
+	n.tuning.lock.Unlock()
+
+	n.controlTimer.SetBase(conf.HeartbeatTimeout)
+	n.controlTimer.backoff.setBase(conf.HeartbeatTimeout)
+	if r.LogLevel != "" {
+		//the logger reads its level concurrently
+		atomic.StoreUint32((*uint32)(&n.conf.Logger.Level), uint32(level))
+	}
+
+	n.logger.WithFields(logrus.Fields{
+		"heartbeat":  conf.HeartbeatTimeout,
+		"sync_limit": conf.SyncLimit,
+		"log_level":  r.LogLevel,
+	}).Info("Reloaded config")
+	return nil
+}
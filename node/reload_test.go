@@ -0,0 +1,40 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/net"
+)
+
+func TestReload(t *testing.T) {
+	keys, peers := initPeers(1)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	conf := TestConfig(t)
+	node := NewNode(conf, keys[0], peers, trans, &blockProxy{submitCh: make(chan []byte)})
+
+	if err := node.Reload(Reloadable{HeartbeatTimeout: 20 * time.Millisecond, SyncLimit: 50}); err != nil {
+		t.Fatal(err)
+	}
+	if node.heartbeat() != 20*time.Millisecond || node.localSyncLimits().Events != 50 {
+		t.Fatalf("Reload should apply heartbeat 20ms and sync limit 50, got %s and %d",
+			node.heartbeat(), node.syncLimit())
+	}
+	if conf.HeartbeatTimeout == 20*time.Millisecond || conf.SyncLimit == 50 {
+		t.Fatal("Reload should leave the Config alone")
+	}
+
+	//nothing applies if a value is invalid
+	if err := node.Reload(Reloadable{SyncLimit: 10, HeartbeatTimeout: -1}); err == nil {
+		t.Fatal("Reload with a negative heartbeat should fail")
+	}
+	if err := node.Reload(Reloadable{SyncLimit: 10, LogLevel: "loud"}); err == nil {
+		t.Fatal("Reload with an unknown log level should fail")
+	}
+	if s := node.GetStats(); s["sync_limit"] != "50" || s["heartbeat"] != "20ms" {
+		t.Fatalf("Failed Reloads should not change anything, got %v", s)
+	}
+}
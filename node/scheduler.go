@@ -0,0 +1,192 @@
+package node
+
+import (
+	"sync"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+/*
+The periodic background jobs of the node, like saving the address book,
+watching for consensus stalls and pinging the systemd watchdog, run on a single
+scheduler rather than in loops of their own. A job runs every interval, counted
+from the end of its previous run, and never twice at the same time. With
+Config.MaxBackgroundJobs, the scheduler also limits how many jobs run at once;
+the jobs that are due wait for a free slot.
+
+The scheduler keeps the last runs of every job, with their duration and error,
+which operators read with the Jobs method of the admin channel. Jobs log their
+own errors.
+*/
+
+//runs kept in the history of each job
+const jobHistorySize = 10
+
+//JobRun is a run of a background job
+type JobRun struct {
+	Start    time.Time
+	Duration time.Duration
+	Error    string `json:",omitempty"`
+}
+
+//JobStatus describes a background job and its last runs, oldest first
+type JobStatus struct {
+	Name     string
+	Interval time.Duration
+	Running  bool
+	Runs     int //runs since the node started
+	Failures int //failed runs since the node started
+	History  []JobRun
+}
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      func() error
+	next     time.Time
+	running  bool
+	runs     int
+	failures int
+	history  []JobRun
+}
+
+type scheduler struct {
+	lock   sync.Mutex
+	jobs   []*job
+	slots  chan struct{} //nil when the number of running jobs is not limited
+	doneCh chan struct{} //signals the end of a run
+	wg     sync.WaitGroup
+	logger *logrus.Entry
+}
+
+//newScheduler runs up to limit jobs at the same time. 0 means no limit.
+func newScheduler(limit int, logger *logrus.Entry) *scheduler {
+	s := &scheduler{
+		doneCh: make(chan struct{}, 1),
+		logger: logger,
+	}
+	if limit > 0 {
+		s.slots = make(chan struct{}, limit)
+	}
+	return s
+}
+
+//add registers a job that first runs after one interval
+func (s *scheduler) add(name string, interval time.Duration, run func() error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.jobs = append(s.jobs, &job{
+		name:     name,
+		interval: interval,
+		run:      run,
+		next:     time.Now().Add(interval),
+	})
+}
+
+//run starts the jobs as they fall due until shutdownCh is closed, and then
+//waits for the running ones to return
+func (s *scheduler) run(shutdownCh <-chan struct{}) {
+	defer s.wg.Wait()
+	for {
+		timer := time.NewTimer(s.startDue(shutdownCh))
+		select {
+		case <-timer.C:
+		case <-s.doneCh:
+			timer.Stop()
+		case <-shutdownCh:
+			timer.Stop()
+			return
+		}
+	}
+}
+
+//startDue starts the jobs that are due and returns the time until the next one
+//is. The jobs that are running are due again once they return.
+func (s *scheduler) startDue(shutdownCh <-chan struct{}) time.Duration {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	now := time.Now()
+	wait := time.Hour
+	for _, j := range s.jobs {
+		if j.running {
+			continue
+		}
+		if !j.next.After(now) {
+			j.running = true
+			s.wg.Add(1)
+			go s.execute(j, shutdownCh)
+			continue
+		}
+		if d := j.next.Sub(now); d < wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+func (s *scheduler) execute(j *job, shutdownCh <-chan struct{}) {
+	defer s.wg.Done()
+	if s.slots != nil {
+		select {
+		case s.slots <- struct{}{}:
+			defer func() { <-s.slots }()
+		case <-shutdownCh:
+			s.lock.Lock()
+			j.running = false
+			s.lock.Unlock()
+			return
+		}
+	}
+
+	run := JobRun{Start: time.Now()}
+	err := j.run()
+	run.Duration = time.Since(run.Start)
+	if err != nil {
+		run.Error = err.Error()
+		s.logger.WithFields(logrus.Fields{
+			"job":   j.name,
+			"error": err,
+		}).Debug("Background job failed")
+	}
+
+	s.lock.Lock()
+	j.running = false
+	j.next = time.Now().Add(j.interval)
+	j.runs++
+	if err != nil {
+		j.failures++
+	}
+	j.history = append(j.history, run)
+	if len(j.history) > jobHistorySize {
+		j.history = j.history[len(j.history)-jobHistorySize:]
+	}
+	s.lock.Unlock()
+
+	select {
+	case s.doneCh <- struct{}{}:
+	default:
+	}
+}
+
+func (s *scheduler) status() []JobStatus {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	res := make([]JobStatus, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		res = append(res, JobStatus{
+			Name:     j.name,
+			Interval: j.interval,
+			Running:  j.running,
+			Runs:     j.runs,
+			Failures: j.failures,
+			History:  append([]JobRun{}, j.history...),
+		})
+	}
+	return res
+}
+
+//Jobs returns the background jobs of the node and their last runs
+func (n *Node) Jobs() []JobStatus {
+	return n.scheduler.status()
+}
@@ -0,0 +1,68 @@
+package node
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+)
+
+func TestScheduler(t *testing.T) {
+	s := newScheduler(1, common.NewTestLogger(t).WithField("test", "scheduler"))
+
+	var lock sync.Mutex
+	running, maxRunning := 0, 0
+	job := func(err error) func() error {
+		return func() error {
+			lock.Lock()
+			running++
+			if running > maxRunning {
+				maxRunning = running
+			}
+			lock.Unlock()
+			time.Sleep(2 * time.Millisecond)
+			lock.Lock()
+			running--
+			lock.Unlock()
+			return err
+		}
+	}
+	s.add("ok", time.Millisecond, job(nil))
+	s.add("failing", time.Millisecond, job(fmt.Errorf("boom")))
+
+	shutdownCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		s.run(shutdownCh)
+		close(done)
+	}()
+	time.Sleep(200 * time.Millisecond)
+	close(shutdownCh)
+	<-done
+
+	if maxRunning != 1 {
+		t.Fatalf("Jobs should run one at a time, %d ran together", maxRunning)
+	}
+	for _, j := range s.status() {
+		if j.Runs < jobHistorySize || len(j.History) != jobHistorySize {
+			t.Fatalf("Job %s should have run at least %d times and kept %d runs, got %d and %d",
+				j.Name, jobHistorySize, jobHistorySize, j.Runs, len(j.History))
+		}
+		if j.Running {
+			t.Fatalf("Job %s should not run after shutdown", j.Name)
+		}
+		last := j.History[len(j.History)-1]
+		switch j.Name {
+		case "ok":
+			if j.Failures != 0 || last.Error != "" {
+				t.Fatalf("Job ok should not fail, got %d failures", j.Failures)
+			}
+		case "failing":
+			if j.Failures != j.Runs || last.Error != "boom" {
+				t.Fatalf("Every run of job failing should fail, got %d of %d", j.Failures, j.Runs)
+			}
+		}
+	}
+}
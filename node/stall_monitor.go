@@ -0,0 +1,160 @@
+package node
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Sirupsen/logrus"
+)
+
+/*
+The stall monitor watches the last consensus round. When it has not moved for
+StallTimeout while there are transactions or events waiting for consensus, the
+node tries a series of recovery steps, from the cheapest to the most
+expensive, and stops as soon as consensus makes progress again:
+
+  1. resync:  gossip with every peer instead of a random one
+  2. caches:  drop the memoized results of the consensus methods
+  3. refame:  vote again on the fame of every witness after the last decided
+              round
+
+If none of the steps helps, an error is logged and operator intervention is
+required. If no peer can be reached, the network is partitioned rather than
+stalled and no further step is attempted.
+*/
+
+type recoveryStep struct {
+	name string
+	run  func() error
+}
+
+//stallMonitor returns the job that checks for stalls every half StallTimeout
+func (n *Node) stallMonitor() func() error {
+	lastRound := n.lastConsensusRound()
+	lastProgress := time.Now()
+
+	return func() error {
+		if n.getState() != Babbling {
+			lastProgress = time.Now()
+			return nil
+		}
+
+		round := n.lastConsensusRound()
+		if round != lastRound || !n.needConsensus() {
+			lastRound = round
+			lastProgress = time.Now()
+			return nil
+		}
+
+		if time.Since(lastProgress) < n.conf.StallTimeout {
+			return nil
+		}
+
+		n.logger.WithFields(logrus.Fields{
+			"last_consensus_round": round,
+			"stalled_for":          time.Since(lastProgress).String(),
+		}).Warn("Consensus stalled")
+
+		step, err := n.recoverFromStall(n.recoverySteps(), func() bool {
+			return n.lastConsensusRound() != round
+		})
+		if err == errUnreachable {
+			n.logger.WithField("error", err).Error("Consensus stall recovery failed, no peer could be reached")
+		} else if err != nil {
+			//the peers answer but consensus does not progress
+			n.logger.WithField("error", err).Error("Consensus stall recovery failed, operator intervention required")
+			if err := n.casState(Babbling, Faulted); err != nil {
+				n.logger.WithField("error", err).Debug("Not faulting")
+			}
+		} else {
+			n.logger.WithField("step", step).Info("Recovered from consensus stall")
+		}
+
+		lastRound = n.lastConsensusRound()
+		lastProgress = time.Now()
+		return err
+	}
+}
+
+func (n *Node) recoverySteps() []recoveryStep {
+	return []recoveryStep{
+		{"resync", n.resyncAll},
+		{"caches", func() error {
+			n.coreLock.Lock()
+			defer n.coreLock.Unlock()
+			n.core.ResetCaches()
+			return n.core.RunConsensus()
+		}},
+		{"refame", func() error {
+			n.coreLock.Lock()
+			defer n.coreLock.Unlock()
+			n.core.ResetUndecidedRounds()
+			return n.core.RunConsensus()
+		}},
+	}
+}
+
+//recoverFromStall runs the steps in order until progressed returns true and
+//returns the name of the step that fixed the stall
+func (n *Node) recoverFromStall(steps []recoveryStep, progressed func() bool) (string, error) {
+	for _, s := range steps {
+		n.logger.WithField("step", s.name).Debug("Attempting stall recovery")
+		if err := s.run(); err != nil {
+			n.logger.WithFields(logrus.Fields{
+				"step":  s.name,
+				"error": err,
+			}).Error("Stall recovery step")
+			if err == errUnreachable {
+				return "", err
+			}
+			continue
+		}
+		if progressed() {
+			//read by GetStats
+			n.coreLock.Lock()
+			n.stallRecoveries++
+			n.lastRecoveryStep = s.name
+			n.coreLock.Unlock()
+			return s.name, nil
+		}
+	}
+	return "", fmt.Errorf("no recovery step fixed the stall")
+}
+
+var errUnreachable = errors.New("no peer could be reached")
+
+//resyncAll gossips with every peer and fails only if none could be reached
+func (n *Node) resyncAll() error {
+	n.selectorLock.Lock()
+	peers := n.peerSelector.Peers()
+	n.selectorLock.Unlock()
+
+	ctx := n.stateContext(Babbling)
+	reached := 0
+	for _, p := range peers {
+		if err := n.gossip(ctx, p.NetAddr); err != nil {
+			continue
+		}
+		reached++
+	}
+	if reached == 0 && len(peers) > 0 {
+		return errUnreachable
+	}
+	return nil
+}
+
+func (n *Node) lastConsensusRound() int {
+	n.coreLock.RLock()
+	defer n.coreLock.RUnlock()
+	if lcr := n.core.GetLastConsensusRoundIndex(); lcr != nil {
+		return *lcr
+	}
+	return -1
+}
+
+func (n *Node) needConsensus() bool {
+	n.coreLock.RLock()
+	defer n.coreLock.RUnlock()
+	return n.core.NeedGossip()
+}
@@ -0,0 +1,117 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/babbleio/babble/common"
+)
+
+func TestRecoverFromStall(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	_, nodes := initNodes(1, 1000, logger)
+	defer shutdownNodes(nodes)
+	node := nodes[0]
+
+	progress := false
+	ran := []string{}
+	step := func(name string, err error, fix bool) recoveryStep {
+		return recoveryStep{name, func() error {
+			ran = append(ran, name)
+			if fix {
+				progress = true
+			}
+			return err
+		}}
+	}
+
+	//the first step fails, the second does not help, the third fixes the stall
+	steps := []recoveryStep{
+		step("a", fmt.Errorf("boom"), false),
+		step("b", nil, false),
+		step("c", nil, true),
+		step("d", nil, true),
+	}
+	fixed, err := node.recoverFromStall(steps, func() bool { return progress })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fixed != "c" {
+		t.Fatalf("Stall should be fixed by step c, not %s", fixed)
+	}
+	if len(ran) != 3 {
+		t.Fatalf("Steps after the fix should not run. Ran %v", ran)
+	}
+	if s := node.GetStats()["last_recovery_step"]; s != "c" {
+		t.Fatalf("last_recovery_step should be c, not %s", s)
+	}
+
+	//an unreachable network aborts recovery
+	progress = false
+	ran = []string{}
+	steps = []recoveryStep{
+		step("resync", errUnreachable, false),
+		step("caches", nil, true),
+	}
+	if _, err := node.recoverFromStall(steps, func() bool { return progress }); err != errUnreachable {
+		t.Fatalf("Recovery should abort with errUnreachable, got %v", err)
+	}
+	if len(ran) != 1 {
+		t.Fatalf("No step should run after an unreachable network. Ran %v", ran)
+	}
+}
+
+func TestRecoveryStepsOnHealthyNode(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	_, nodes := initNodes(4, 1000, logger)
+	defer shutdownNodes(nodes)
+
+	//the nodes only gossip when told to, so that the steps do not race with
+	//syncs in the background
+	runNodes(nodes, false)
+	if err := gossipInTurn(nodes, 5); err != nil {
+		t.Fatal(err)
+	}
+
+	//every step must leave a healthy node in a consistent state
+	for _, s := range nodes[0].recoverySteps() {
+		if err := s.run(); err != nil {
+			t.Fatalf("step %s: %s", s.name, err)
+		}
+	}
+
+	if err := gossipInTurn(nodes, 10); err != nil {
+		t.Fatal(err)
+	}
+	checkGossip(nodes, t)
+}
+
+//gossipInTurn makes the nodes gossip with each other one at a time, each
+//submitting a transaction first, until all of them reach the consensus round
+//target
+func gossipInTurn(nodes []*Node, target int) error {
+	for pass := 0; pass < 100; pass++ {
+		done := true
+		for _, n := range nodes {
+			if n.lastConsensusRound() < target {
+				done = false
+			}
+		}
+		if done {
+			return nil
+		}
+		for i, n := range nodes {
+			n.addTransaction([]byte(fmt.Sprintf("node%d pass%d", i, pass)))
+			for _, p := range nodes {
+				if p == n {
+					continue
+				}
+				if err := n.gossip(context.Background(), p.localAddr); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return fmt.Errorf("consensus round %d not reached", target)
+}
@@ -1,11 +1,13 @@
 package node
 
 import (
+	"context"
+	"fmt"
 	"sync"
 	"sync/atomic"
 )
 
-// NodeState captures the state of a Babble node: Babbling, CatchingUp or Shutdown
+// NodeState captures the state of a Babble node
 type NodeState uint32
 
 const (
@@ -15,6 +17,22 @@ const (
 	CatchingUp
 
 	Shutdown
+
+	// Suspended nodes neither gossip nor answer requests until they are
+	// unsuspended
+	Suspended
+
+	// Faulted nodes could not recover from a stalled consensus and wait for
+	// an operator
+	Faulted
+
+	// Maintenance nodes do not gossip but still serve the requests that only
+	// read their hashgraph
+	Maintenance
+
+	// Joining nodes wait for a participant to accept them into the cluster,
+	// before catching up with it
+	Joining
 )
 
 func (s NodeState) String() string {
@@ -25,15 +43,107 @@ func (s NodeState) String() string {
 		return "CatchingUp"
 	case Shutdown:
 		return "Shutdown"
+	case Suspended:
+		return "Suspended"
+	case Faulted:
+		return "Faulted"
+	case Maintenance:
+		return "Maintenance"
+	case Joining:
+		return "Joining"
 	default:
 		return "Unknown"
 	}
 }
 
+//transitions lists the states that can follow each state. Shutdown is final.
+var transitions = map[NodeState][]NodeState{
+	Babbling:    {CatchingUp, Suspended, Faulted, Maintenance, Joining, Shutdown},
+	CatchingUp:  {Babbling, Suspended, Faulted, Maintenance, Shutdown},
+	Suspended:   {Babbling, Shutdown},
+	Faulted:     {Maintenance, Shutdown},
+	Maintenance: {Babbling, Shutdown},
+	Joining:     {CatchingUp, Babbling, Shutdown},
+}
+
+//CanTransition is true if a node in state from can move to state to
+func CanTransition(from, to NodeState) bool {
+	for _, s := range transitions[from] {
+		if s == to {
+			return true
+		}
+	}
+	return false
+}
+
+//StateHook is called after every change of state, by the routine that made it
+type StateHook func(from, to NodeState)
+
+//stateSubscriptionBuffer is the number of changes of state a subscriber can
+//fall behind
+const stateSubscriptionBuffer = 16
+
+//StateChange is a change of state of the node
+type StateChange struct {
+	From NodeState
+	To   NodeState
+}
+
+/*
+StateSubscription receives the changes of state of the node on C, in order, so
+that an embedding application can react to a catch-up or a shutdown without
+polling State. Initial is the state of the node when it subscribed.
+
+Changing state never waits for a subscriber: one that lets the buffer of C fill
+up is dropped, its channel closed with Dropped set, and reads State to resync.
+C is also closed after the change to Shutdown, which is final.
+*/
+type StateSubscription struct {
+	C       <-chan StateChange
+	Initial NodeState
+	ch      chan StateChange
+	dropped bool
+	state   *nodeState
+}
+
+//Dropped is true once C is closed because the subscriber fell behind
+func (s *StateSubscription) Dropped() bool {
+	s.state.l.Lock()
+	defer s.state.l.Unlock()
+	return s.dropped
+}
+
+//Close stops the subscription and closes C
+func (s *StateSubscription) Close() {
+	s.state.l.Lock()
+	defer s.state.l.Unlock()
+	s.state.unsubscribe(s, false)
+}
+
+/*
+nodeState is the state machine of the node. Every change of state goes through
+setState or casState, which refuse the transitions that are not listed above,
+so a routine that finishes late, like a fast-forward that completes after
+Shutdown, can not bring the node back to life.
+
+It also keeps track of the routines of the node: the gossip routines, which the
+node waits for before fast-forwarding, and the long-running loops, which
+Shutdown waits for. No routine is started once the node is in the Shutdown
+state.
+*/
 type nodeState struct {
-	state NodeState
+	l       sync.Mutex
+	state   NodeState
+	changed chan struct{} //closed and replaced by every change of state
+	ctx     context.Context
+	cancel  context.CancelFunc //cancels ctx, replaced by every change of state
+	hooks   []StateHook
+	subs    map[*StateSubscription]bool
+
+	draining bool //no gossip routine starts, see GracefulShutdown
 
-	wg sync.WaitGroup
+	wg    sync.WaitGroup //gossip routines
+	loops sync.WaitGroup //Run loop and background routines
 }
 
 func (b *nodeState) getState() NodeState {
@@ -41,14 +151,144 @@ func (b *nodeState) getState() NodeState {
 	return NodeState(atomic.LoadUint32(stateAddr))
 }
 
-func (b *nodeState) setState(s NodeState) {
+//setState moves to state s if the transition is legal
+func (b *nodeState) setState(s NodeState) error {
+	b.l.Lock()
+	return b.transition(s)
+}
+
+//casState moves from state from to state to. It fails if the node is not in
+//state from anymore, i.e. another routine changed the state in the meantime.
+func (b *nodeState) casState(from, to NodeState) error {
+	b.l.Lock()
+	if b.state != from {
+		s := b.state
+		b.l.Unlock()
+		return fmt.Errorf("Not %s but %s", from, s)
+	}
+	return b.transition(to)
+}
+
+//transition must be called with b.l locked. It unlocks it before running the
+//hooks, which may change the state again.
+func (b *nodeState) transition(s NodeState) error {
+	from := b.state
+	if !CanTransition(from, s) {
+		b.l.Unlock()
+		return fmt.Errorf("Illegal state transition from %s to %s", from, s)
+	}
+	b.move(s)
+	b.publish(StateChange{From: from, To: s})
+	hooks := b.hooks
+	b.l.Unlock()
+
+	for _, h := range hooks {
+		h(from, s)
+	}
+	return nil
+}
+
+//must be called with b.l locked
+func (b *nodeState) move(s NodeState) {
 	stateAddr := (*uint32)(&b.state)
 	atomic.StoreUint32(stateAddr, uint32(s))
+	if b.changed != nil {
+		close(b.changed)
+	}
+	b.changed = make(chan struct{})
+	if b.cancel != nil {
+		b.cancel()
+	}
+	b.ctx, b.cancel = context.WithCancel(context.Background())
 }
 
-// Start a goroutine and add it to waitgroup
+//stateChanged returns a channel that is closed when the node leaves state s
+func (b *nodeState) stateChanged(s NodeState) <-chan struct{} {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.changed == nil {
+		b.changed = make(chan struct{})
+	}
+	if b.state != s {
+		closed := make(chan struct{})
+		close(closed)
+		return closed
+	}
+	return b.changed
+}
+
+//stateContext returns a context that is cancelled when the node leaves state
+//s, to abandon the RPCs that only make sense in it
+func (b *nodeState) stateContext(s NodeState) context.Context {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.ctx == nil {
+		b.ctx, b.cancel = context.WithCancel(context.Background())
+	}
+	if b.state != s {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx
+	}
+	return b.ctx
+}
+
+//subscribe returns a StateSubscription to the changes of state from now on.
+//Its channel is closed at once if the node is already shut down.
+func (b *nodeState) subscribe() *StateSubscription {
+	b.l.Lock()
+	defer b.l.Unlock()
+	ch := make(chan StateChange, stateSubscriptionBuffer)
+	sub := &StateSubscription{C: ch, Initial: b.state, ch: ch, state: b}
+	if b.state == Shutdown {
+		close(ch)
+		return sub
+	}
+	if b.subs == nil {
+		b.subs = make(map[*StateSubscription]bool)
+	}
+	b.subs[sub] = true
+	return sub
+}
+
+//must be called with b.l locked
+func (b *nodeState) unsubscribe(sub *StateSubscription, dropped bool) {
+	if !b.subs[sub] {
+		return
+	}
+	delete(b.subs, sub)
+	sub.dropped = dropped
+	close(sub.ch)
+}
+
+//publish sends c to the subscribers, and drops the ones that fell behind. It
+//must be called with b.l locked, which keeps the changes in order.
+func (b *nodeState) publish(c StateChange) {
+	for sub := range b.subs {
+		select {
+		case sub.ch <- c:
+		default:
+			b.unsubscribe(sub, true)
+			continue
+		}
+		if c.To == Shutdown {
+			b.unsubscribe(sub, false)
+		}
+	}
+}
+
+//onStateChange registers a hook called after every change of state
+func (b *nodeState) onStateChange(h StateHook) {
+	b.l.Lock()
+	defer b.l.Unlock()
+	b.hooks = append(b.hooks, h)
+}
+
+// Start a goroutine and add it to waitgroup, unless the node is shutting down
 func (b *nodeState) goFunc(f func()) {
-	b.wg.Add(1)
+	if !b.add(&b.wg) {
+		return
+	}
 	go func() {
 		defer b.wg.Done()
 		f()
@@ -58,3 +298,54 @@ func (b *nodeState) goFunc(f func()) {
 func (b *nodeState) waitRoutines() {
 	b.wg.Wait()
 }
+
+//startLoop registers a long-running loop. It returns false if the node is
+//shutting down, in which case the loop must not run.
+func (b *nodeState) startLoop() bool {
+	return b.add(&b.loops)
+}
+
+func (b *nodeState) goLoop(f func()) {
+	if !b.startLoop() {
+		return
+	}
+	go func() {
+		defer b.loops.Done()
+		f()
+	}()
+}
+
+func (b *nodeState) waitLoops() {
+	b.loops.Wait()
+}
+
+//startDraining stops gossip routines from starting. It returns false if the
+//node is already draining or shut down.
+func (b *nodeState) startDraining() bool {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.draining || b.state == Shutdown {
+		return false
+	}
+	b.draining = true
+	return true
+}
+
+func (b *nodeState) isDraining() bool {
+	b.l.Lock()
+	defer b.l.Unlock()
+	return b.draining
+}
+
+//add increments wg unless the node is shutting down, or draining for the gossip
+//routines. Holding b.l guarantees that nothing is added once Shutdown starts
+//waiting.
+func (b *nodeState) add(wg *sync.WaitGroup) bool {
+	b.l.Lock()
+	defer b.l.Unlock()
+	if b.state == Shutdown || (b.draining && wg == &b.wg) {
+		return false
+	}
+	wg.Add(1)
+	return true
+}
@@ -0,0 +1,224 @@
+package node
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestStateTransitions(t *testing.T) {
+	var s nodeState
+
+	changes := []string{}
+	s.onStateChange(func(from, to NodeState) {
+		changes = append(changes, from.String()+">"+to.String())
+	})
+
+	steps := []struct {
+		to    NodeState
+		legal bool
+	}{
+		{Babbling, false},
+		{CatchingUp, true},
+		{Suspended, true},
+		{CatchingUp, false},
+		{Babbling, true},
+		{Faulted, true},
+		{Babbling, false},
+		{Maintenance, true},
+		{Babbling, true},
+		{Shutdown, true},
+		{Babbling, false},
+		{Shutdown, false},
+	}
+	for i, step := range steps {
+		from := s.getState()
+		err := s.setState(step.to)
+		if step.legal && err != nil {
+			t.Fatalf("step %d: %s to %s should be legal: %s", i, from, step.to, err)
+		}
+		if !step.legal && err == nil {
+			t.Fatalf("step %d: %s to %s should be illegal", i, from, step.to)
+		}
+		if !step.legal && s.getState() != from {
+			t.Fatalf("step %d: an illegal transition should not change the state", i)
+		}
+	}
+
+	expected := []string{"Babbling>CatchingUp", "CatchingUp>Suspended",
+		"Suspended>Babbling", "Babbling>Faulted", "Faulted>Maintenance",
+		"Maintenance>Babbling", "Babbling>Shutdown"}
+	if len(changes) != len(expected) {
+		t.Fatalf("Hooks should see %v, not %v", expected, changes)
+	}
+	for i, c := range expected {
+		if changes[i] != c {
+			t.Fatalf("Hooks should see %v, not %v", expected, changes)
+		}
+	}
+}
+
+func TestCasState(t *testing.T) {
+	var s nodeState
+	s.setState(CatchingUp)
+	s.setState(Suspended)
+
+	//a fast-forward that completes late does not undo the suspension
+	if err := s.casState(CatchingUp, Babbling); err == nil {
+		t.Fatalf("casState should fail when the state changed")
+	}
+	if st := s.getState(); st != Suspended {
+		t.Fatalf("State should be Suspended, not %s", st)
+	}
+
+	changed := s.stateChanged(Suspended)
+	if err := s.casState(Suspended, Babbling); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	default:
+		t.Fatalf("Leaving Suspended should close the channel")
+	}
+}
+
+func TestStateContext(t *testing.T) {
+	var s nodeState
+
+	ctx := s.stateContext(Babbling)
+	if ctx.Err() != nil {
+		t.Fatal("The context of the current state should not be done")
+	}
+	if s.stateContext(CatchingUp).Err() == nil {
+		t.Fatal("The context of another state should be done")
+	}
+
+	s.setState(CatchingUp)
+	if ctx.Err() != context.Canceled {
+		t.Fatal("The context should be cancelled when the node leaves its state")
+	}
+	ctx = s.stateContext(CatchingUp)
+	s.setState(Shutdown)
+	if ctx.Err() != context.Canceled {
+		t.Fatal("The context should be cancelled on Shutdown")
+	}
+}
+
+func TestStateSubscription(t *testing.T) {
+	var s nodeState
+	sub := s.subscribe()
+	closed := s.subscribe()
+	closed.Close()
+	closed.Close()
+	if _, ok := <-closed.C; ok || closed.Dropped() {
+		t.Fatal("Closed subscription should be closed without being dropped")
+	}
+
+	s.setState(Joining)
+	s.setState(CatchingUp)
+	s.setState(Joining) //illegal, not published
+	s.setState(Shutdown)
+
+	expected := []StateChange{{Babbling, Joining}, {Joining, CatchingUp}, {CatchingUp, Shutdown}}
+	got := []StateChange{}
+	for c := range sub.C {
+		got = append(got, c)
+	}
+	if sub.Initial != Babbling || !reflect.DeepEqual(got, expected) || sub.Dropped() {
+		t.Fatalf("Subscriber should see %v from Babbling, not %v from %s", expected, got, sub.Initial)
+	}
+
+	if _, ok := <-s.subscribe().C; ok {
+		t.Fatal("Subscription after Shutdown should be closed")
+	}
+}
+
+func TestSlowStateSubscriber(t *testing.T) {
+	var s nodeState
+	slow := s.subscribe()
+	for i := 0; i <= stateSubscriptionBuffer/2; i++ {
+		s.setState(Suspended)
+		s.setState(Babbling)
+	}
+	n := 0
+	for range slow.C {
+		n++
+	}
+	if !slow.Dropped() || n != stateSubscriptionBuffer {
+		t.Fatalf("Subscriber with a full buffer should be dropped after %d changes, got %d", stateSubscriptionBuffer, n)
+	}
+}
+
+func TestNoRoutineAfterShutdown(t *testing.T) {
+	var s nodeState
+	s.setState(Shutdown)
+
+	ran := make(chan struct{}, 2)
+	s.goFunc(func() { ran <- struct{}{} })
+	s.goLoop(func() { ran <- struct{}{} })
+	s.waitRoutines()
+	s.waitLoops()
+	if s.startLoop() {
+		t.Fatalf("No loop should start after Shutdown")
+	}
+	if len(ran) != 0 {
+		t.Fatalf("No routine should start after Shutdown")
+	}
+}
+
+func TestSuspend(t *testing.T) {
+	logger := common.NewTestLogger(t)
+	_, nodes := initNodes(4, 1000, logger)
+	defer shutdownNodes(nodes)
+
+	if err := gossip(nodes, 3, false, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := nodes[0].Suspend(); err != nil {
+		t.Fatal(err)
+	}
+	//a suspended node refuses syncs
+	args := net.SyncRequest{From: nodes[1].localAddr, Known: nodes[1].core.Known()}
+	var out net.SyncResponse
+	if err := nodes[1].trans.Sync(context.Background(), nodes[0].localAddr, &args, &out); err == nil {
+		t.Fatalf("A suspended node should refuse syncs")
+	}
+
+	//the others still have a supermajority
+	if err := bombardAndWait(nodes[1:], 6, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := nodes[0].Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if err := nodes[0].Resume(); err == nil {
+		t.Fatal("Resuming a node that is not suspended should fail")
+	}
+	if err := bombardAndWait(nodes, 10, 6*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	checkGossip(nodes, t)
+}
+
+func TestResumeTakeOver(t *testing.T) {
+	keys, peers := initPeers(2)
+	conf := TestConfig(t)
+	_, trans := net.NewInmemTransport(peers[0].NetAddr)
+	node := NewNode(conf, keys[0], peers, trans, aproxy.NewInmemAppProxy(conf.Logger))
+	defer node.Shutdown()
+
+	//a node taking over from a previous process catches up instead of Init
+	if err := node.Resume(); err != nil {
+		t.Fatal(err)
+	}
+	if node.State() != CatchingUp {
+		t.Fatalf("Resume should move a new node to CatchingUp, not %s", node.State())
+	}
+}
@@ -0,0 +1,72 @@
+package node
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/Sirupsen/logrus"
+
+	hg "github.com/babbleio/babble/hashgraph"
+)
+
+//newStore returns the hashgraph Store selected by conf.Store: "inmem" or
+//"badger". The badger store is written to conf.StorePath and is loaded from
+//there if the node ran before.
+func newStore(conf *Config, participants map[string]int) (hg.Store, error) {
+	switch conf.Store {
+	case "", "inmem":
+		return hg.NewInmemStore(participants, conf.CacheSize), nil
+	case "badger":
+		if conf.StorePath == "" {
+			return nil, fmt.Errorf("Badger store requires a path")
+		}
+		if files, err := ioutil.ReadDir(conf.StorePath); err == nil && len(files) > 0 {
+			return hg.LoadBadgerStore(conf.CacheSize, conf.StorePath)
+		}
+		return hg.NewBadgerStore(participants, conf.CacheSize, conf.StorePath)
+	default:
+		return nil, fmt.Errorf("Unknown store %s", conf.Store)
+	}
+}
+
+//bootstrap is Init for a node with a persistent store. The node picks up where
+//it stopped instead of creating a new initial Event. The application received
+//the transactions replayed from the store before the restart, so only the
+//membership changes are applied again. A new store is a first start, so the App
+//receives the genesis state. The numbering of the Blocks resumes after the last
+//one kept in the store, and the commit deduplication from its window.
+func (n *Node) bootstrap() error {
+	n.coreLock.Lock()
+	committed, fresh, err := n.core.Bootstrap()
+	n.id = n.core.ID()
+	n.committedEvents = n.core.GetConsensusEventsCount()
+	known := n.core.Known()
+	n.coreLock.Unlock()
+	if err != nil {
+		return err
+	}
+
+	for _, ev := range committed {
+		for _, tx := range ev.Transactions() {
+			n.commitMembership(tx)
+		}
+	}
+	if err := n.restoreBlocks(); err != nil {
+		return err
+	}
+	if err := n.restoreDedup(); err != nil {
+		return err
+	}
+
+	n.logger.WithFields(logrus.Fields{
+		"known":     known,
+		"committed": len(committed),
+	}).Info("Bootstrapped from store")
+	if fresh && n.conf.Observer {
+		return n.initObserver()
+	}
+	if fresh {
+		return n.initFresh()
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package node
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestNodeStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	keys, peers := initPeers(1)
+	testLogger := common.NewTestLogger(t)
+
+	conf := TestConfig(t)
+	conf.Store = "unknown"
+	_, trans := net.NewInmemTransport(peers[0].NetAddr)
+	node := NewNode(conf, keys[0], peers, trans, aproxy.NewInmemAppProxy(testLogger))
+	if err := node.Init(); err == nil {
+		t.Fatal("Init should fail with an unknown store")
+	}
+
+	conf = TestConfig(t)
+	conf.Store = "badger"
+	conf.StorePath = dir
+	_, trans = net.NewInmemTransport(peers[0].NetAddr)
+	node = NewNode(conf, keys[0], peers, trans, aproxy.NewInmemAppProxy(testLogger))
+	if err := node.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := node.core.hg.Store.(*hg.BadgerStore); !ok {
+		t.Fatalf("The node should use a BadgerStore, not %T", node.core.hg.Store)
+	}
+	node.Shutdown()
+
+	//the initial Event was written to the database
+	store, err := hg.LoadBadgerStore(conf.CacheSize, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if _, err := store.ParticipantEvent(node.core.HexID(), 0); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBootstrapNode(t *testing.T) {
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logger := common.NewTestLogger(t)
+	keys, peers := initPeers(4)
+	newNode := func(i int) *Node {
+		conf := NewConfig(5*time.Millisecond, time.Second, 1000, 1000, logger)
+		conf.Seed = common.TestSeed()
+		conf.Store = "badger"
+		conf.StorePath = filepath.Join(dir, fmt.Sprintf("node%d", i))
+		trans, err := net.NewTCPTransport(peers[i].NetAddr, nil, 2, time.Second, nil, logger)
+		if err != nil {
+			t.Fatal(err)
+		}
+		node := NewNode(conf, keys[i], peers, trans, aproxy.NewInmemAppProxy(logger))
+		if err := node.Init(); err != nil {
+			t.Fatal(err)
+		}
+		return &node
+	}
+
+	nodes := []*Node{}
+	for i := range peers {
+		nodes = append(nodes, newNode(i))
+	}
+	if err := gossip(nodes, 3, true, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	//restart node 0 from its store
+	before := nodes[0].core
+	node := newNode(0)
+	defer node.Shutdown()
+
+	if !reflect.DeepEqual(node.core.Known(), before.Known()) {
+		t.Fatalf("Known should be %v, not %v", before.Known(), node.core.Known())
+	}
+	if node.core.Head != before.Head || node.core.Seq != before.Seq {
+		t.Fatalf("Head should be %s (%d), not %s (%d)", before.Head, before.Seq,
+			node.core.Head, node.core.Seq)
+	}
+	lcr, lcrBefore := node.core.GetLastConsensusRoundIndex(), before.GetLastConsensusRoundIndex()
+	if lcr == nil || *lcr < *lcrBefore {
+		t.Fatalf("LastConsensusRound should be at least %d, not %v", *lcrBefore, lcr)
+	}
+
+	//the Blocks are numbered from where the node stopped
+	if nodes[0].blockIndex == 0 {
+		t.Fatal("Node 0 should have committed Blocks")
+	}
+	if node.blockIndex != nodes[0].blockIndex {
+		t.Fatalf("The next Block should be %d, not %d", nodes[0].blockIndex, node.blockIndex)
+	}
+	if w, _ := node.watermark.get(); w != nodes[0].blockIndex-1 {
+		t.Fatalf("The watermark should be %d, not %d", nodes[0].blockIndex-1, w)
+	}
+	last, _ := nodes[0].blocks.get(nodes[0].blockIndex - 1)
+	if block, err := node.GetBlock(last.Index); err != nil || !reflect.DeepEqual(block.StateHash, last.StateHash) {
+		t.Fatalf("Block %d should be restored, got %#v (%v)", last.Index, block, err)
+	}
+}
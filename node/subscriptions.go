@@ -0,0 +1,270 @@
+package node
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	hg "github.com/babbleio/babble/hashgraph"
+)
+
+/*
+Clients that follow the ledger subscribe to the Blocks committed by the node
+instead of polling GetBlock. On a high-volume ledger most consumers only care
+about a few of the transactions, so a subscriber registers a CommitFilter and
+the node only sends it the transactions that match:
+
+ - Prefix: the transactions that start with these bytes,
+ - Submitter: the transactions charged to this submitter by Config.TxCost,
+ - AppID: the transactions that Config.TxAppID assigns to this App.
+
+A transaction must match every field that is set. Blocks without any matching
+transaction are not sent at all.
+
+Committing Blocks never waits for a subscriber: each one has a buffer of
+subscriptionBuffer Blocks, and a subscriber that lets it fill up is dropped, its
+channel closed with Dropped set, so that it resubscribes from the watermark it
+reached.
+
+SubscribeFrom resumes a subscription: the subscriber first receives the Blocks
+committed since a given index, among the last blocksKept Blocks the node holds,
+then the new ones. The held Blocks are read while no Block is being published,
+and the ones published afterwards are skipped if they were already sent, so
+none is missed or sent twice.
+*/
+
+//subscriptionBuffer is the number of Blocks a subscriber can fall behind
+const subscriptionBuffer = 100
+
+var (
+	//ErrSubmitterFilter is returned by Subscribe for a Submitter filter without
+	//Config.TxCost
+	ErrSubmitterFilter = errors.New("Filtering by submitter requires Config.TxCost")
+	//ErrAppIDFilter is returned by Subscribe for an AppID filter without
+	//Config.TxAppID
+	ErrAppIDFilter = errors.New("Filtering by App ID requires Config.TxAppID")
+	//ErrResumeTooOld is returned by SubscribeFrom for an index of a Block that
+	//the node no longer holds
+	ErrResumeTooOld = errors.New("Blocks to resume from are no longer held")
+)
+
+//TxAppIDFunc returns the App a transaction belongs to
+type TxAppIDFunc func(tx []byte) string
+
+//CommitFilter selects the committed transactions sent to a subscriber. The zero
+//value matches every transaction.
+type CommitFilter struct {
+	Prefix    []byte //nil matches any payload
+	Submitter string //"" matches any submitter
+	AppID     string //"" matches any App
+}
+
+//CommittedBlock is the part of a committed Block that matches the filter of a
+//subscriber
+type CommittedBlock struct {
+	Index         int
+	RoundReceived int
+	StateHash     []byte
+	Transactions  [][]byte //the matching transactions, in consensus order
+	Filtered      int      //transactions of the Block that did not match
+}
+
+//Subscription receives the Blocks committed after Subscribe on C, until Close
+type Subscription struct {
+	C       <-chan CommittedBlock
+	ch      chan CommittedBlock
+	filter  CommitFilter
+	next    int //index of the first Block to send, the previous ones were replayed
+	dropped bool
+	subs    *subscriptions
+}
+
+//Dropped is true once C is closed because the subscriber fell behind
+func (s *Subscription) Dropped() bool {
+	s.subs.lock.Lock()
+	defer s.subs.lock.Unlock()
+	return s.dropped
+}
+
+//Close stops the Subscription and closes C
+func (s *Subscription) Close() {
+	s.subs.remove(s, false)
+}
+
+type subscriptions struct {
+	lock    sync.Mutex
+	subs    map[*Subscription]bool
+	costFn  TxCostFunc
+	appIDFn TxAppIDFunc
+}
+
+func newSubscriptions(costFn TxCostFunc, appIDFn TxAppIDFunc) *subscriptions {
+	return &subscriptions{
+		subs:    make(map[*Subscription]bool),
+		costFn:  costFn,
+		appIDFn: appIDFn,
+	}
+}
+
+func (s *subscriptions) add(filter CommitFilter) (*Subscription, error) {
+	return s.resume(filter, nil)
+}
+
+//resume registers a subscriber that first receives the Blocks returned by
+//replay, if not nil, which is called while no Block is published
+func (s *subscriptions) resume(filter CommitFilter, replay func() ([]hg.Block, error)) (*Subscription, error) {
+	if filter.Submitter != "" && s.costFn == nil {
+		return nil, ErrSubmitterFilter
+	}
+	if filter.AppID != "" && s.appIDFn == nil {
+		return nil, ErrAppIDFilter
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	var blocks []hg.Block
+	if replay != nil {
+		var err error
+		if blocks, err = replay(); err != nil {
+			return nil, err
+		}
+	}
+	ch := make(chan CommittedBlock, subscriptionBuffer+len(blocks))
+	sub := &Subscription{C: ch, ch: ch, filter: filter, subs: s}
+	for _, b := range blocks {
+		if block, ok := s.newMatcher(b.Transactions).committed(filter, b.Index, b.RoundReceived, b.StateHash); ok {
+			ch <- block
+		}
+		sub.next = b.Index + 1
+	}
+	s.subs[sub] = true
+	return sub, nil
+}
+
+func (s *subscriptions) remove(sub *Subscription, dropped bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.removeLocked(sub, dropped)
+}
+
+func (s *subscriptions) removeLocked(sub *Subscription, dropped bool) {
+	if !s.subs[sub] {
+		return
+	}
+	delete(s.subs, sub)
+	sub.dropped = dropped
+	close(sub.ch)
+}
+
+func (s *subscriptions) count() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return len(s.subs)
+}
+
+//publish sends the matching transactions of a Block to every subscriber, and
+//drops the ones whose buffer is full.
+func (s *subscriptions) publish(index, roundReceived int, stateHash []byte, txs [][]byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if len(s.subs) == 0 {
+		return
+	}
+
+	m := s.newMatcher(txs)
+	for sub := range s.subs {
+		if index < sub.next {
+			continue
+		}
+		block, ok := m.committed(sub.filter, index, roundReceived, stateHash)
+		if !ok {
+			continue
+		}
+		select {
+		case sub.ch <- block:
+		default:
+			s.removeLocked(sub, true)
+		}
+	}
+}
+
+//matcher matches the transactions of a Block against the filters of the
+//subscribers. The submitter and App of each transaction are only computed if a
+//filter needs them.
+type matcher struct {
+	subs       *subscriptions
+	txs        [][]byte
+	submitters []string
+	appIDs     []string
+}
+
+func (s *subscriptions) newMatcher(txs [][]byte) *matcher {
+	return &matcher{subs: s, txs: txs}
+}
+
+func (m *matcher) submitter(i int) string {
+	if m.submitters == nil {
+		m.submitters = make([]string, len(m.txs))
+		for j, tx := range m.txs {
+			m.submitters[j], _, _ = m.subs.costFn(tx)
+		}
+	}
+	return m.submitters[i]
+}
+
+func (m *matcher) appID(i int) string {
+	if m.appIDs == nil {
+		m.appIDs = make([]string, len(m.txs))
+		for j, tx := range m.txs {
+			m.appIDs[j] = m.subs.appIDFn(tx)
+		}
+	}
+	return m.appIDs[i]
+}
+
+//committed returns the part of the Block that matches f, or false if none of
+//its transactions do
+func (m *matcher) committed(f CommitFilter, index, roundReceived int, stateHash []byte) (CommittedBlock, bool) {
+	matching := [][]byte{}
+	for i, tx := range m.txs {
+		if f.Prefix != nil && !bytes.HasPrefix(tx, f.Prefix) {
+			continue
+		}
+		if f.Submitter != "" && m.submitter(i) != f.Submitter {
+			continue
+		}
+		if f.AppID != "" && m.appID(i) != f.AppID {
+			continue
+		}
+		matching = append(matching, tx)
+	}
+	if len(matching) == 0 {
+		return CommittedBlock{}, false
+	}
+	return CommittedBlock{
+		Index:         index,
+		RoundReceived: roundReceived,
+		StateHash:     stateHash,
+		Transactions:  matching,
+		Filtered:      len(m.txs) - len(matching),
+	}, true
+}
+
+//Subscribe returns a Subscription to the transactions matching filter in the
+//Blocks committed from now on, see subscriptions.go
+func (n *Node) Subscribe(filter CommitFilter) (*Subscription, error) {
+	return n.subscribers.add(filter)
+}
+
+//SubscribeFrom is Subscribe, but the Subscription starts with the Blocks
+//committed since the Block of index from. It fails with ErrResumeTooOld if the
+//node no longer holds that Block.
+func (n *Node) SubscribeFrom(filter CommitFilter, from int) (*Subscription, error) {
+	return n.subscribers.resume(filter, func() ([]hg.Block, error) {
+		blocks, ok := n.blocks.since(from)
+		if !ok {
+			return nil, ErrResumeTooOld
+		}
+		return blocks, nil
+	})
+}
@@ -0,0 +1,175 @@
+package node
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+func TestSubscriptionFilters(t *testing.T) {
+	//transactions are "app/submitter/payload"
+	cost := func(tx []byte) (string, int, error) {
+		return strings.Split(string(tx), "/")[1], 1, nil
+	}
+	appID := func(tx []byte) string {
+		return strings.Split(string(tx), "/")[0]
+	}
+	s := newSubscriptions(cost, appID)
+
+	all, _ := s.add(CommitFilter{})
+	prefix, _ := s.add(CommitFilter{Prefix: []byte("a/")})
+	submitter, _ := s.add(CommitFilter{Submitter: "bob"})
+	both, _ := s.add(CommitFilter{AppID: "b", Submitter: "bob"})
+
+	txs := [][]byte{[]byte("a/alice/1"), []byte("b/bob/2"), []byte("a/bob/3")}
+	s.publish(4, 7, []byte("state"), txs)
+
+	expect := func(sub *Subscription, want ...string) {
+		t.Helper()
+		select {
+		case b := <-sub.C:
+			got := []string{}
+			for _, tx := range b.Transactions {
+				got = append(got, string(tx))
+			}
+			if !reflect.DeepEqual(got, want) || b.Index != 4 || b.Filtered != len(txs)-len(want) {
+				t.Fatalf("Subscriber should receive %v of Block 4, got %+v", want, b)
+			}
+		default:
+			t.Fatalf("Subscriber should receive %v", want)
+		}
+	}
+	expect(all, "a/alice/1", "b/bob/2", "a/bob/3")
+	expect(prefix, "a/alice/1", "a/bob/3")
+	expect(submitter, "b/bob/2", "a/bob/3")
+	expect(both, "b/bob/2")
+
+	//Blocks without matching transactions are not sent
+	s.publish(5, 8, nil, [][]byte{[]byte("c/carol/4")})
+	select {
+	case b := <-prefix.C:
+		t.Fatalf("Block without matching transaction should not be sent, got %+v", b)
+	default:
+	}
+
+	if _, err := newSubscriptions(nil, nil).add(CommitFilter{Submitter: "bob"}); err != ErrSubmitterFilter {
+		t.Fatalf("Submitter filter without TxCost should fail, got %v", err)
+	}
+	if _, err := newSubscriptions(nil, nil).add(CommitFilter{AppID: "a"}); err != ErrAppIDFilter {
+		t.Fatalf("AppID filter without TxAppID should fail, got %v", err)
+	}
+}
+
+func TestSlowSubscriber(t *testing.T) {
+	s := newSubscriptions(nil, nil)
+	slow, _ := s.add(CommitFilter{})
+	for i := 0; i <= subscriptionBuffer; i++ {
+		s.publish(i, i, nil, [][]byte{[]byte("tx")})
+	}
+	if !slow.Dropped() || s.count() != 0 {
+		t.Fatal("Subscriber with a full buffer should be dropped")
+	}
+	n := 0
+	for range slow.C {
+		n++
+	}
+	if n != subscriptionBuffer {
+		t.Fatalf("Dropped subscriber should keep the %d buffered Blocks, got %d", subscriptionBuffer, n)
+	}
+
+	closed, _ := s.add(CommitFilter{})
+	closed.Close()
+	closed.Close()
+	if _, ok := <-closed.C; ok || closed.Dropped() {
+		t.Fatal("Closed subscription should be closed without being dropped")
+	}
+}
+
+func TestSubscribeCommits(t *testing.T) {
+	keys, peers := initPeers(1)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	prox := &blockProxy{submitCh: make(chan []byte)}
+	node := NewNode(TestConfig(t), keys[0], peers, trans, prox)
+	sub, err := node.Subscribe(CommitFilter{Prefix: []byte("x")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	if err := node.commitBlock(2, [][]byte{[]byte("a"), []byte("xb")}, nil); err != nil {
+		t.Fatal(err)
+	}
+	b := <-sub.C
+	if b.Index != 0 || b.RoundReceived != 2 || len(b.Transactions) != 1 || string(b.StateHash) != "\x00" {
+		t.Fatalf("Subscriber should receive xb in Block 0, got %+v", b)
+	}
+	if s := node.GetStats()["subscribers"]; s != "1" {
+		t.Fatalf("subscribers should be 1, not %s", s)
+	}
+}
+
+func TestSubscribeFrom(t *testing.T) {
+	keys, peers := initPeers(1)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	prox := &blockProxy{submitCh: make(chan []byte)}
+	node := NewNode(TestConfig(t), keys[0], peers, trans, prox)
+	for _, tx := range []string{"xa", "b", "xc"} {
+		if err := node.commitBlock(1, [][]byte{[]byte(tx)}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sub, err := node.SubscribeFrom(CommitFilter{Prefix: []byte("x")}, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if err := node.commitBlock(2, [][]byte{[]byte("xd")}, nil); err != nil {
+		t.Fatal(err)
+	}
+	//Block 1 does not match, Block 2 is replayed and Block 3 follows
+	for _, index := range []int{2, 3} {
+		if b := <-sub.C; b.Index != index {
+			t.Fatalf("Subscriber should receive Block %d, got %+v", index, b)
+		}
+	}
+
+	for i := 0; i < blocksKept; i++ {
+		if err := node.commitBlock(3, [][]byte{[]byte("e")}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := node.SubscribeFrom(CommitFilter{}, 0); err != ErrResumeTooOld {
+		t.Fatalf("Resuming from a Block that is no longer held should fail, got %v", err)
+	}
+}
+
+func TestResumeSkipsReplayed(t *testing.T) {
+	s := newSubscriptions(nil, nil)
+	replayed := []hg.Block{hg.NewBlock(4, 1, [][]byte{[]byte("a")}), hg.NewBlock(5, 1, [][]byte{[]byte("b")})}
+	sub, err := s.resume(CommitFilter{}, func() ([]hg.Block, error) { return replayed, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	//Block 5 was committed before the subscription, but published after it
+	s.publish(5, 1, nil, [][]byte{[]byte("b")})
+	s.publish(6, 2, nil, [][]byte{[]byte("c")})
+	sub.Close()
+
+	indexes := []int{}
+	for b := range sub.C {
+		indexes = append(indexes, b.Index)
+	}
+	if !reflect.DeepEqual(indexes, []int{4, 5, 6}) {
+		t.Fatalf("Subscriber should receive Blocks 4, 5 and 6 once, got %v", indexes)
+	}
+}
@@ -0,0 +1,84 @@
+package node
+
+import (
+	"github.com/babbleio/babble/net"
+)
+
+/*
+A node that refuses or fails a Sync or an EagerSync tells the requester why with
+an ErrorCode in the response, next to the error message. The requester gets
+the code back with errorCode and adapts:
+
+ - busy, not-in-peerset, rate-limited, stopping, suspended and not-admitted
+   responses come from a peer that works but can not serve this request now;
+   the peer is backed off without logging an error.
+ - too-far-behind comes with SyncLimit and moves the requester to CatchingUp.
+ - all coded responses show that the link to the peer works, so they are not
+   counted as transport failures in the connectivity of the node.
+
+The code of the last failed syncs is also recorded in SyncResults.
+*/
+
+//codedError is an error with the ErrorCode to respond with
+type codedError struct {
+	code net.ErrorCode
+	err  error
+}
+
+func (e codedError) Error() string {
+	return e.err.Error()
+}
+
+//withCode attaches code to err, unless err is nil or code is ErrorNone
+func withCode(code net.ErrorCode, err error) error {
+	if err == nil || code == net.ErrorNone {
+		return err
+	}
+	return codedError{code: code, err: err}
+}
+
+//errorCode returns the ErrorCode attached to err, or ErrorNone
+func errorCode(err error) net.ErrorCode {
+	if e, ok := err.(codedError); ok {
+		return e.code
+	}
+	return net.ErrorNone
+}
+
+//temporary is true for the errors of peers that can not serve a request now,
+//but may later
+func temporary(err error) bool {
+	switch errorCode(err) {
+	case net.ErrorBusy, net.ErrorNotInPeerSet, net.ErrorRateLimited, net.ErrorStopping, net.ErrorSuspended,
+		net.ErrorNotAdmitted:
+		return true
+	}
+	return false
+}
+
+//linkError returns the errors that show a problem with the link to a peer, which
+//coded errors do not since the peer responded
+func linkError(err error) error {
+	if errorCode(err) != net.ErrorNone {
+		return nil
+	}
+	return err
+}
+
+//isPeer is true if addr is the address of a peer the node gossips with
+func (n *Node) isPeer(addr string) bool {
+	_, ok := n.peerAt(addr)
+	return ok
+}
+
+//peerAt returns the peer whose address is addr
+func (n *Node) peerAt(addr string) (net.Peer, bool) {
+	n.selectorLock.Lock()
+	defer n.selectorLock.Unlock()
+	for _, p := range n.peerSelector.Peers() {
+		if p.NetAddr == addr {
+			return p, true
+		}
+	}
+	return net.Peer{}, false
+}
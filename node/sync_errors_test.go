@@ -0,0 +1,73 @@
+package node
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/babbleio/babble/common"
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+func TestErrorCodes(t *testing.T) {
+	err := errors.New("failed")
+	if errorCode(err) != net.ErrorNone || linkError(err) != err || temporary(err) {
+		t.Fatal("An error without code should be a link error")
+	}
+	if withCode(net.ErrorBusy, nil) != nil {
+		t.Fatal("withCode should keep nil errors")
+	}
+
+	busy := withCode(net.ErrorBusy, err)
+	if busy.Error() != "failed" || errorCode(busy) != net.ErrorBusy {
+		t.Fatalf("busy error should keep its message and code, got %v, %v", busy, errorCode(busy))
+	}
+	if linkError(busy) != nil || !temporary(busy) {
+		t.Fatal("busy errors should be temporary and not link errors")
+	}
+	if temporary(withCode(net.ErrorStore, err)) {
+		t.Fatal("store errors should not be temporary")
+	}
+
+	if s := net.ErrorNotInPeerSet.String(); s != "not-in-peerset" {
+		t.Fatalf("ErrorNotInPeerSet should be not-in-peerset, not %s", s)
+	}
+}
+
+func TestSyncErrorCodes(t *testing.T) {
+	_, nodes := initNodes(2, 1000, common.NewTestLogger(t))
+	runNodes(nodes, false)
+	defer shutdownNodes(nodes)
+
+	//a suspended node says so
+	if err := nodes[1].Suspend(); err != nil {
+		t.Fatal(err)
+	}
+	_, err := nodes[0].requestSync(context.Background(), nodes[1].localAddr, map[int]int{})
+	if code := errorCode(err); code != net.ErrorSuspended {
+		t.Fatalf("Sync with a suspended node should fail with suspended, not %v (%v)", code, err)
+	}
+	_, err = nodes[0].requestEagerSync(context.Background(), nodes[1].localAddr, nil, false)
+	if code := errorCode(err); code != net.ErrorSuspended {
+		t.Fatalf("EagerSync with a suspended node should fail with suspended, not %v (%v)", code, err)
+	}
+	if err := nodes[1].Resume(); err != nil {
+		t.Fatal(err)
+	}
+
+	//the Events of an unknown node can not be inserted
+	cmd := &net.EagerSyncRequest{
+		From:   "unknown",
+		Events: []hg.WireEvent{{Body: hg.WireBody{CreatorID: 42, SelfParentIndex: -1, OtherParentIndex: -1}}},
+	}
+	respCh := make(chan net.RPCResponse, 1)
+	nodes[1].processEagerSyncRequest(net.RPC{Command: cmd, RespChan: respCh}, cmd)
+	resp := <-respCh
+	if resp.Error == nil {
+		t.Fatal("Events of an unknown creator should not be inserted")
+	}
+	if code := resp.Response.(*net.EagerSyncResponse).ErrorCode; code != net.ErrorNotInPeerSet {
+		t.Fatalf("EagerSync from an unknown node should fail with not-in-peerset, not %v", code)
+	}
+}
@@ -0,0 +1,91 @@
+package node
+
+import (
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+/*
+Sync limits are negotiated between peers. Every SyncRequest carries the limits
+the requester wants the response to respect, the strictest of its own and of
+the ones last advertised by the peer, and every SyncResponse carries the limits
+the responder wants subsequent requests to respect. Each side applies
+the strictest of its own configured limits and the ones advertised by the
+other side, so nodes with different configurations can still gossip. A limit of
+0 means no limit, on either side.
+
+A response cut to the limits is marked Truncated: the requester pulls again
+right away, up to maxTruncatedPulls times, instead of waiting for its next
+gossip round to receive the rest.
+*/
+
+const (
+	//approximate gob overhead of a WireEvent without its transactions
+	wireEventOverhead = 200
+	//pulls made in a row from a peer whose responses are truncated
+	maxTruncatedPulls = 4
+)
+
+func (n *Node) localSyncLimits() net.SyncLimits {
+	return net.SyncLimits{
+		Events: n.syncLimit(),
+		Bytes:  n.conf.SyncBytesLimit,
+	}
+}
+
+//peerSyncLimits returns the limits to apply when exchanging events with peer
+func (n *Node) peerSyncLimits(peer string) net.SyncLimits {
+	n.peerLimitsLock.Lock()
+	defer n.peerLimitsLock.Unlock()
+	return mergeSyncLimits(n.localSyncLimits(), n.peerLimits[peer])
+}
+
+func (n *Node) setPeerSyncLimits(peer string, limits net.SyncLimits) {
+	n.peerLimitsLock.Lock()
+	defer n.peerLimitsLock.Unlock()
+	n.peerLimits[peer] = limits
+}
+
+//mergeSyncLimits returns the strictest of two sets of limits. Zero values mean
+//no limit, and so are only kept when both sides have no limit.
+func mergeSyncLimits(a, b net.SyncLimits) net.SyncLimits {
+	return net.SyncLimits{
+		Events: minLimit(a.Events, b.Events),
+		Bytes:  minLimit(a.Bytes, b.Bytes),
+	}
+}
+
+func minLimit(a, b int) int {
+	if a <= 0 {
+		return b
+	}
+	if b <= 0 || a < b {
+		return a
+	}
+	return b
+}
+
+func wireEventSize(e hg.WireEvent) int {
+	size := wireEventOverhead
+	for _, tx := range e.Body.Transactions {
+		size += len(tx)
+	}
+	return size
+}
+
+//truncateWireEvents returns the longest prefix of events that fits in
+//maxBytes. It always keeps at least one event so that progress can be made.
+//Events are in topological order so any prefix can be inserted on its own.
+func truncateWireEvents(events []hg.WireEvent, maxBytes int) []hg.WireEvent {
+	if maxBytes <= 0 {
+		return events
+	}
+	size := 0
+	for i, e := range events {
+		size += wireEventSize(e)
+		if i > 0 && size > maxBytes {
+			return events[:i]
+		}
+	}
+	return events
+}
@@ -0,0 +1,140 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	aproxy "github.com/babbleio/babble/proxy/app"
+
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+)
+
+func TestMergeSyncLimits(t *testing.T) {
+	cases := []struct {
+		a, b, expected net.SyncLimits
+	}{
+		{net.SyncLimits{Events: 100, Bytes: 0}, net.SyncLimits{}, net.SyncLimits{Events: 100, Bytes: 0}},
+		{net.SyncLimits{}, net.SyncLimits{Events: 50, Bytes: 1000}, net.SyncLimits{Events: 50, Bytes: 1000}},
+		{net.SyncLimits{Events: 100, Bytes: 500}, net.SyncLimits{Events: 50, Bytes: 1000}, net.SyncLimits{Events: 50, Bytes: 500}},
+		{net.SyncLimits{}, net.SyncLimits{}, net.SyncLimits{}},
+	}
+	for i, c := range cases {
+		if res := mergeSyncLimits(c.a, c.b); res != c.expected {
+			t.Fatalf("case %d: merged limits should be %v, not %v", i, c.expected, res)
+		}
+	}
+}
+
+func TestTruncateWireEvents(t *testing.T) {
+	events := make([]hg.WireEvent, 5)
+	for i := range events {
+		events[i].Body.Transactions = [][]byte{make([]byte, 800)}
+	}
+
+	if l := len(truncateWireEvents(events, 0)); l != 5 {
+		t.Fatalf("No limit should keep all 5 events, not %d", l)
+	}
+	if l := len(truncateWireEvents(events, 3000)); l != 3 {
+		t.Fatalf("3000 bytes should fit 3 events, not %d", l)
+	}
+	if l := len(truncateWireEvents(events, 10)); l != 1 {
+		t.Fatalf("At least 1 event should be kept, not %d", l)
+	}
+}
+
+func TestTruncatedPull(t *testing.T) {
+	keys, peers := initPeers(2)
+	testLogger := common.NewTestLogger(t)
+
+	nodes := []*Node{}
+	for i := range peers {
+		trans, err := net.NewTCPTransport(peers[i].NetAddr, nil, 2, time.Second, nil, testLogger)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		conf := TestConfig(t)
+		conf.SyncBytesLimit = 2000
+		conf.MaxEventPayload = 1000
+		node := NewNode(conf, keys[i], peers, trans, aproxy.NewInmemAppProxy(testLogger))
+		if err := node.Init(); err != nil {
+			t.Fatal(err)
+		}
+		node.RunAsync(false)
+		nodes = append(nodes, &node)
+	}
+	defer shutdownNodes(nodes)
+
+	//node1 creates Events that do not fit in a single response
+	nodes[1].coreLock.Lock()
+	for i := 0; i < 5; i++ {
+		nodes[1].core.AddTransactions([][]byte{make([]byte, 800)})
+		if err := nodes[1].core.AddSelfEvent(); err != nil {
+			nodes[1].coreLock.Unlock()
+			t.Fatal(err)
+		}
+	}
+	known := nodes[1].core.Known()
+	nodes[1].coreLock.Unlock()
+
+	if _, _, err := nodes[0].pull(context.Background(), nodes[1].localAddr); err != nil {
+		t.Fatal(err)
+	}
+	nodes[0].coreLock.RLock()
+	got := nodes[0].core.Known()
+	nodes[0].coreLock.RUnlock()
+	for id, last := range known {
+		if got[id] < last {
+			t.Fatalf("node0 should have pulled again up to Event %d of %d, not %d", last, id, got[id])
+		}
+	}
+}
+
+func TestRequestSyncLimits(t *testing.T) {
+	keys, peers := initPeers(2)
+	testLogger := common.NewTestLogger(t)
+
+	nodes := []*Node{}
+	for i := range peers {
+		trans, err := net.NewTCPTransport(peers[i].NetAddr, nil, 2, time.Second, nil, testLogger)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		conf := TestConfig(t)
+		conf.MaxEventPayload = 1000
+		node := NewNode(conf, keys[i], peers, trans, aproxy.NewInmemAppProxy(testLogger))
+		if err := node.Init(); err != nil {
+			t.Fatal(err)
+		}
+		node.RunAsync(false)
+		nodes = append(nodes, &node)
+	}
+	defer shutdownNodes(nodes)
+
+	nodes[1].coreLock.Lock()
+	for i := 0; i < 5; i++ {
+		nodes[1].core.AddTransactions([][]byte{make([]byte, 800)})
+		if err := nodes[1].core.AddSelfEvent(); err != nil {
+			nodes[1].coreLock.Unlock()
+			t.Fatal(err)
+		}
+	}
+	nodes[1].coreLock.Unlock()
+
+	//node0 has no byte limit of its own but node1 advertised one, which the
+	//request carries
+	nodes[0].setPeerSyncLimits(nodes[1].localAddr, net.SyncLimits{Bytes: 2000})
+	nodes[0].coreLock.RLock()
+	known := nodes[0].core.Known()
+	nodes[0].coreLock.RUnlock()
+
+	resp, err := nodes[0].requestSync(context.Background(), nodes[1].localAddr, known)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.Truncated || len(resp.Events) >= 5 {
+		t.Fatalf("Response should be truncated to the advertised limit, got %d Events", len(resp.Events))
+	}
+}
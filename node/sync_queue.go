@@ -0,0 +1,193 @@
+package node
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/babbleio/babble/net"
+)
+
+/*
+Inbound Sync and EagerSync requests are not processed as they arrive but queued
+and handed to a fixed number of workers, Config.InboundSyncs. Every peer has its
+own queue and the workers take requests from the peers in turn, so a peer that
+sends a burst of requests does not delay the others. A peer can only have
+maxQueuedSyncsPerPeer requests waiting: the next ones are refused straight away
+instead of timing out, and so are the requests that waited longer than the
+requester is willing to wait.
+*/
+
+const maxQueuedSyncsPerPeer = 2
+
+type queuedSync struct {
+	rpc      net.RPC
+	received time.Time
+}
+
+type syncQueue struct {
+	l          sync.Mutex
+	peers      map[string][]queuedSync //[net addr] => pending requests
+	order      []string                //peers with pending requests, in turn
+	maxPerPeer int
+	active     int //requests popped and not done yet
+	rejected   int
+	expired    int
+	notify     chan struct{}
+	now        func() time.Time
+}
+
+func newSyncQueue(maxPerPeer int) *syncQueue {
+	return &syncQueue{
+		peers:      make(map[string][]queuedSync),
+		maxPerPeer: maxPerPeer,
+		notify:     make(chan struct{}, 1),
+		now:        time.Now,
+	}
+}
+
+//push queues a request from peer. It returns false if peer already has too
+//many pending requests.
+func (q *syncQueue) push(peer string, rpc net.RPC) bool {
+	q.l.Lock()
+	defer q.l.Unlock()
+	pending := q.peers[peer]
+	if len(pending) >= q.maxPerPeer {
+		q.rejected++
+		return false
+	}
+	if len(pending) == 0 {
+		q.order = append(q.order, peer)
+	}
+	q.peers[peer] = append(pending, queuedSync{rpc: rpc, received: q.now()})
+	q.signal()
+	return true
+}
+
+//pop returns the oldest request of the next peer in turn
+func (q *syncQueue) pop() (queuedSync, bool) {
+	q.l.Lock()
+	defer q.l.Unlock()
+	if len(q.order) == 0 {
+		return queuedSync{}, false
+	}
+	peer := q.order[0]
+	q.order = q.order[1:]
+	pending := q.peers[peer]
+	item := pending[0]
+	q.active++
+	if len(pending) > 1 {
+		q.peers[peer] = pending[1:]
+		q.order = append(q.order, peer)
+	} else {
+		delete(q.peers, peer)
+	}
+	//wake up another worker for the remaining requests
+	if len(q.order) > 0 {
+		q.signal()
+	}
+	return item, true
+}
+
+//must be called with q.l locked
+func (q *syncQueue) signal() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+//done is called when a popped request is processed or refused
+func (q *syncQueue) done() {
+	q.l.Lock()
+	defer q.l.Unlock()
+	q.active--
+}
+
+func (q *syncQueue) expire() {
+	q.l.Lock()
+	defer q.l.Unlock()
+	q.expired++
+}
+
+//idle is true if no request is queued or being processed
+func (q *syncQueue) idle() bool {
+	q.l.Lock()
+	defer q.l.Unlock()
+	return q.active == 0 && len(q.order) == 0
+}
+
+//stats returns the number of queued, rejected and expired requests
+func (q *syncQueue) stats() (int, int, int) {
+	q.l.Lock()
+	defer q.l.Unlock()
+	queued := 0
+	for _, pending := range q.peers {
+		queued += len(pending)
+	}
+	return queued, q.rejected, q.expired
+}
+
+//queueSync queues an inbound Sync or EagerSync request, or refuses it if the
+//peer has too many pending requests
+func (n *Node) queueSync(peer string, rpc net.RPC) {
+	if n.syncQueue.push(peer, rpc) {
+		return
+	}
+	n.logger.WithField("from", peer).Debug("Too many pending syncs")
+	n.refuseSync(rpc, withCode(net.ErrorBusy, fmt.Errorf("Too many pending syncs from %s", peer)))
+}
+
+//refuseSync responds to a request without processing it, with the ErrorCode of
+//err
+func (n *Node) refuseSync(rpc net.RPC, err error) {
+	switch rpc.Command.(type) {
+	case *net.EagerSyncRequest:
+		rpc.Respond(&net.EagerSyncResponse{From: n.localAddr, ErrorCode: errorCode(err)}, err)
+	default:
+		rpc.Respond(&net.SyncResponse{From: n.localAddr, ErrorCode: errorCode(err)}, err)
+	}
+}
+
+//processSyncs is run by each of the Config.InboundSyncs workers
+func (n *Node) processSyncs() {
+	for {
+		item, ok := n.syncQueue.pop()
+		if !ok {
+			select {
+			case <-n.syncQueue.notify:
+				continue
+			case <-n.shutdownCh:
+				return
+			}
+		}
+
+		n.processQueuedSync(item)
+		n.syncQueue.done()
+	}
+}
+
+func (n *Node) processQueuedSync(item queuedSync) {
+	//the requester gave up on requests that waited too long
+	if wait := time.Since(item.received); n.conf.TCPTimeout > 0 && wait > n.conf.TCPTimeout {
+		n.syncQueue.expire()
+		n.logger.WithField("wait", wait).Debug("Dropping expired sync")
+		n.refuseSync(item.rpc, withCode(net.ErrorBusy, fmt.Errorf("Sync request expired after %s", wait)))
+		return
+	}
+
+	switch cmd := item.rpc.Command.(type) {
+	case *net.SyncRequest:
+		n.processSyncRequest(item.rpc, cmd)
+	case *net.EagerSyncRequest:
+		n.processEagerSyncRequest(item.rpc, cmd)
+	}
+}
+
+//inboundSyncWorkers returns the number of workers processing inbound syncs
+func (n *Node) inboundSyncWorkers() int {
+	if n.conf.InboundSyncs <= 0 {
+		return 1
+	}
+	return n.conf.InboundSyncs
+}
@@ -0,0 +1,77 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/babbleio/babble/net"
+)
+
+func syncRPC(from string, known int) net.RPC {
+	return net.RPC{
+		Command:  &net.SyncRequest{From: from, Known: map[int]int{0: known}},
+		RespChan: make(chan net.RPCResponse, 1),
+	}
+}
+
+func TestSyncQueueFairness(t *testing.T) {
+	q := newSyncQueue(2)
+
+	//a burst from A does not delay B
+	for _, p := range []struct {
+		from  string
+		known int
+	}{{"A", 1}, {"A", 2}, {"B", 1}} {
+		if !q.push(p.from, syncRPC(p.from, p.known)) {
+			t.Fatalf("Request %d from %s should be queued", p.known, p.from)
+		}
+	}
+
+	expected := []struct {
+		from  string
+		known int
+	}{{"A", 1}, {"B", 1}, {"A", 2}}
+	for i, e := range expected {
+		item, ok := q.pop()
+		if !ok {
+			t.Fatalf("pop %d should return a request", i)
+		}
+		cmd := item.rpc.Command.(*net.SyncRequest)
+		if cmd.From != e.from || cmd.Known[0] != e.known {
+			t.Fatalf("pop %d should return request %d from %s, not %d from %s",
+				i, e.known, e.from, cmd.Known[0], cmd.From)
+		}
+	}
+	if _, ok := q.pop(); ok {
+		t.Fatal("The queue should be empty")
+	}
+}
+
+func TestSyncQueueMaxPerPeer(t *testing.T) {
+	q := newSyncQueue(2)
+
+	for i := 0; i < 3; i++ {
+		queued := q.push("A", syncRPC("A", i))
+		if queued != (i < 2) {
+			t.Fatalf("push %d from A should return %v", i, i < 2)
+		}
+	}
+	if !q.push("B", syncRPC("B", 0)) {
+		t.Fatal("A full queue for A should not refuse requests from B")
+	}
+
+	//a slot is freed once a request is processed
+	q.pop()
+	if q.idle() {
+		t.Fatal("The queue should not be idle while a request is processed")
+	}
+	q.done()
+	if !q.push("A", syncRPC("A", 3)) {
+		t.Fatal("A should be able to queue a request again")
+	}
+
+	q.expire()
+	queued, rejected, expired := q.stats()
+	if queued != 3 || rejected != 1 || expired != 1 {
+		t.Fatalf("stats should be (3, 1, 1), not (%d, %d, %d)", queued, rejected, expired)
+	}
+}
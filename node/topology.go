@@ -0,0 +1,105 @@
+package node
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/babbleio/babble/net"
+)
+
+/*
+Nodes spread over several datacenters can be given a zone, such as a region
+name, with Config.Zone. They advertise it in every SyncRequest and
+SyncResponse, and with Config.ZoneAffinity they pick a peer of their own zone
+for that share of their gossip rounds, as long as there is one. The other
+rounds go to the rest of the cluster, so Events still cross zones every few
+rounds and every node keeps seeing the whole hashgraph, but most of the
+bandwidth stays within each zone. Peers that did not advertise a zone yet
+count as remote, which is how their zone is learned.
+*/
+
+type zoneBias struct {
+	local       string
+	affinity    float64           //share of picks among local peers
+	zones       map[string]string //[net addr] => zone advertised by the peer
+	localPicks  int
+	remotePicks int
+}
+
+func newZoneBias(local string, affinity float64) *zoneBias {
+	return &zoneBias{
+		local:    local,
+		affinity: affinity,
+		zones:    make(map[string]string),
+	}
+}
+
+func (z *zoneBias) set(peer string, zone string) {
+	z.zones[peer] = zone
+}
+
+func (z *zoneBias) isLocal(peer string) bool {
+	return z.local != "" && z.zones[peer] == z.local
+}
+
+//pick narrows candidates down to the peers of the local zone, with
+//probability affinity, or to the peers of the other zones
+func (z *zoneBias) pick(candidates []net.Peer, rnd *rand.Rand) []net.Peer {
+	if z.local == "" || z.affinity <= 0 {
+		return candidates
+	}
+	local, remote := []net.Peer{}, []net.Peer{}
+	for _, p := range candidates {
+		if z.isLocal(p.NetAddr) {
+			local = append(local, p)
+		} else {
+			remote = append(remote, p)
+		}
+	}
+	if len(local) == 0 || len(remote) == 0 {
+		return candidates
+	}
+	if rnd.Float64() < z.affinity {
+		return local
+	}
+	return remote
+}
+
+//picked counts the selection of peer
+func (z *zoneBias) picked(peer string) {
+	if z.local == "" {
+		return
+	}
+	if z.isLocal(peer) {
+		z.localPicks++
+	} else {
+		z.remotePicks++
+	}
+}
+
+//setPeerZone records the zone advertised by peer
+func (n *Node) setPeerZone(peer string, zone string) {
+	n.selectorLock.Lock()
+	n.peerSelector.SetZone(peer, zone)
+	n.selectorLock.Unlock()
+}
+
+//topologyStats describes the zones of the peers and the share of gossip rounds
+//spent within the local zone. Must be called with the selectorLock held.
+func (n *Node) topologyStats() (zonePeers string, localRatio float64) {
+	peers, local, remote := n.peerSelector.Zones()
+	zones := []string{}
+	for z, count := range peers {
+		if z == "" {
+			z = "unknown"
+		}
+		zones = append(zones, fmt.Sprintf("%s=%d", z, count))
+	}
+	sort.Strings(zones)
+	if local+remote > 0 {
+		localRatio = float64(local) / float64(local+remote)
+	}
+	return strings.Join(zones, ","), localRatio
+}
@@ -0,0 +1,136 @@
+package node
+
+import (
+	"sync"
+	"time"
+
+	hg "github.com/babbleio/babble/hashgraph"
+)
+
+/*
+Every node counts what it exchanges with each of its peers during gossip. A high
+duplicate ratio means that the node is sent Events it already had, typically
+because several peers push the same Events to it at the same time. Many bytes
+for few Events point to sync limits that are too large for the link.
+
+Bytes are the estimated size of the Events exchanged, as used by the sync
+limits, not what the transport writes on the wire.
+*/
+
+//PeerTraffic counts what was exchanged with a peer since Since
+type PeerTraffic struct {
+	Since          time.Time
+	BytesSent      int
+	BytesReceived  int
+	EventsSent     int
+	EventsReceived int
+	Duplicates     int     //received Events that were already known
+	EventsSkipped  int     //Events not sent because the peer already had them, see peer_knowledge.go
+	DuplicateRatio float64 //Duplicates / EventsReceived
+}
+
+type traffic struct {
+	l     sync.Mutex
+	peers map[string]PeerTraffic //[net addr] => traffic
+	now   func() time.Time
+}
+
+func newTraffic() *traffic {
+	return &traffic{
+		peers: make(map[string]PeerTraffic),
+		now:   time.Now,
+	}
+}
+
+//must be called with t.l locked
+func (t *traffic) get(peer string) PeerTraffic {
+	pt, ok := t.peers[peer]
+	if !ok {
+		pt.Since = t.now().UTC()
+	}
+	return pt
+}
+
+func (t *traffic) sent(peer string, events []hg.WireEvent) {
+	t.l.Lock()
+	defer t.l.Unlock()
+	pt := t.get(peer)
+	pt.EventsSent += len(events)
+	for _, e := range events {
+		pt.BytesSent += wireEventSize(e)
+	}
+	t.peers[peer] = pt
+}
+
+func (t *traffic) received(peer string, events []hg.WireEvent, duplicates int) {
+	t.l.Lock()
+	defer t.l.Unlock()
+	pt := t.get(peer)
+	pt.EventsReceived += len(events)
+	pt.Duplicates += duplicates
+	for _, e := range events {
+		pt.BytesReceived += wireEventSize(e)
+	}
+	t.peers[peer] = pt
+}
+
+//skipped counts Events left out of a diff sent to peer
+func (t *traffic) skipped(peer string, events int) {
+	if events == 0 {
+		return
+	}
+	t.l.Lock()
+	defer t.l.Unlock()
+	pt := t.get(peer)
+	pt.EventsSkipped += events
+	t.peers[peer] = pt
+}
+
+//snapshot returns the traffic per peer and the total for all peers
+func (t *traffic) snapshot() (map[string]PeerTraffic, PeerTraffic) {
+	t.l.Lock()
+	defer t.l.Unlock()
+	res := make(map[string]PeerTraffic, len(t.peers))
+	var total PeerTraffic
+	for peer, pt := range t.peers {
+		pt.DuplicateRatio = duplicateRatio(pt)
+		res[peer] = pt
+		if total.Since.IsZero() || pt.Since.Before(total.Since) {
+			total.Since = pt.Since
+		}
+		total.BytesSent += pt.BytesSent
+		total.BytesReceived += pt.BytesReceived
+		total.EventsSent += pt.EventsSent
+		total.EventsReceived += pt.EventsReceived
+		total.Duplicates += pt.Duplicates
+		total.EventsSkipped += pt.EventsSkipped
+	}
+	total.DuplicateRatio = duplicateRatio(total)
+	return res, total
+}
+
+func duplicateRatio(pt PeerTraffic) float64 {
+	if pt.EventsReceived == 0 {
+		return 0
+	}
+	return float64(pt.Duplicates) / float64(pt.EventsReceived)
+}
+
+//countDuplicates returns how many events are already in the hashgraph. Must be
+//called with the coreLock held.
+func (n *Node) countDuplicates(events []hg.WireEvent) int {
+	known := n.core.Known()
+	duplicates := 0
+	for _, e := range events {
+		if e.Body.Index <= known[e.Body.CreatorID] {
+			duplicates++
+		}
+	}
+	return duplicates
+}
+
+//Traffic returns what this node exchanged with each of its peers
+func (n *Node) Traffic() map[string]PeerTraffic {
+	res, _ := n.traffic.snapshot()
+	return res
+}
@@ -0,0 +1,97 @@
+package node
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestTrafficAccounting(t *testing.T) {
+	tr := newTraffic()
+	now := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr.now = func() time.Time { return now }
+
+	events := []hg.WireEvent{
+		{Body: hg.WireBody{Transactions: [][]byte{make([]byte, 100)}}},
+		{Body: hg.WireBody{}},
+	}
+	tr.sent("A", events)
+	tr.received("A", events, 1)
+	now = now.Add(time.Minute)
+	tr.received("B", events, 0)
+	tr.received("B", events[:1], 0)
+
+	peers, total := tr.snapshot()
+	a, b := peers["A"], peers["B"]
+	size := 2*wireEventOverhead + 100
+	if a.EventsSent != 2 || a.BytesSent != size || a.BytesReceived != size {
+		t.Fatalf("A should have exchanged 2 Events of %d bytes both ways, got %#v", size, a)
+	}
+	if a.DuplicateRatio != 0.5 || b.DuplicateRatio != 0 {
+		t.Fatalf("Duplicate ratios should be 0.5 and 0, not %f and %f",
+			a.DuplicateRatio, b.DuplicateRatio)
+	}
+	if b.Since != now || b.EventsReceived != 3 {
+		t.Fatalf("B should have sent 3 Events since %s, got %#v", now, b)
+	}
+	if total.EventsReceived != 5 || total.Duplicates != 1 || total.Since != a.Since {
+		t.Fatalf("Total should count 5 Events received with 1 duplicate, got %#v", total)
+	}
+}
+
+func TestTrafficBetweenNodes(t *testing.T) {
+	keys, peers := initPeers(2)
+	testLogger := common.NewTestLogger(t)
+
+	nodes := []*Node{}
+	for i := range peers {
+		trans, err := net.NewTCPTransport(peers[i].NetAddr, nil, 2, time.Second, nil, testLogger)
+		if err != nil {
+			t.Fatalf("err: %v", err)
+		}
+		node := NewNode(TestConfig(t), keys[i], peers, trans, aproxy.NewInmemAppProxy(testLogger))
+		node.Init()
+		node.RunAsync(false)
+		nodes = append(nodes, &node)
+	}
+	defer shutdownNodes(nodes)
+
+	//node1's Events that node0 does not know yet
+	nodes[1].coreLock.Lock()
+	diff, err := nodes[1].core.Diff(nodes[0].core.Known())
+	if err != nil {
+		nodes[1].coreLock.Unlock()
+		t.Fatal(err)
+	}
+	wireEvents, err := nodes[1].core.ToWire(diff)
+	nodes[1].coreLock.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := nodes[0].pull(context.Background(), nodes[1].localAddr); err != nil {
+		t.Fatal(err)
+	}
+
+	received := nodes[0].Traffic()[nodes[1].localAddr]
+	if received.EventsReceived != len(wireEvents) || received.Duplicates != 0 {
+		t.Fatalf("node0 should have received %d new Events, got %#v", len(wireEvents), received)
+	}
+	sent := nodes[1].Traffic()[nodes[0].localAddr]
+	if sent.EventsSent != len(wireEvents) || sent.BytesSent != received.BytesReceived {
+		t.Fatalf("node1 should have sent what node0 received, got %#v", sent)
+	}
+
+	//the same Events are now duplicates for node0
+	nodes[0].coreLock.Lock()
+	duplicates := nodes[0].countDuplicates(wireEvents)
+	nodes[0].coreLock.Unlock()
+	if duplicates != len(wireEvents) {
+		t.Fatalf("All %d Events should be duplicates, not %d", len(wireEvents), duplicates)
+	}
+}
@@ -0,0 +1,136 @@
+package node
+
+import (
+	"fmt"
+	"sync"
+)
+
+/*
+Consortium networks can keep a member from filling the blocks at the expense of
+the others with Config.TxCost and Config.SubmitterBudget, without building a
+fee market. TxCost is called with every transaction that passed the Submit
+middleware; it tells which submitter the transaction is charged to and how much
+it costs, for example its size, or rejects it with an error. The node then
+refuses the transactions that would take the pending cost of their submitter,
+that is the cost of its transactions submitted to this node that did not reach
+consensus yet, above SubmitterBudget. The cost is given back once the
+transaction reaches consensus, whether or not the App accepts it.
+
+Refused transactions are dropped like the ones of a full pool and counted in the
+over_budget_txs stat, while the errors of TxCost are logged like the ones of
+middleware. A transaction that costs more than the budget on its own is always
+refused. Budgets only apply to the transactions submitted to this node; every
+node enforces its own.
+*/
+
+//TxCostFunc returns the submitter a transaction is charged to and its cost. An
+//error rejects the transaction.
+type TxCostFunc func(tx []byte) (submitter string, cost int, err error)
+
+type pendingTx struct {
+	submitter string
+	cost      int
+}
+
+//txBudgets records the pending cost of each submitter
+type txBudgets struct {
+	lock    sync.Mutex
+	costFn  TxCostFunc
+	budget  int
+	spent   map[string]int
+	pending map[string][]pendingTx //by TxHash, identical transactions queue up
+	refused int
+}
+
+//newTxBudgets returns nil, which admits every transaction, unless both the
+//cost function and the budget are set
+func newTxBudgets(costFn TxCostFunc, budget int) *txBudgets {
+	if costFn == nil || budget <= 0 {
+		return nil
+	}
+	return &txBudgets{
+		costFn:  costFn,
+		budget:  budget,
+		spent:   make(map[string]int),
+		pending: make(map[string][]pendingTx),
+	}
+}
+
+//charge adds the cost of tx to the pending cost of its submitter, or returns
+//an error if it does not fit in the budget
+func (b *txBudgets) charge(tx []byte) error {
+	if b == nil {
+		return nil
+	}
+	submitter, cost, err := b.costFn(tx)
+	if err != nil {
+		return err
+	}
+	if cost < 0 {
+		return fmt.Errorf("Negative transaction cost %d", cost)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.spent[submitter]+cost > b.budget {
+		b.refused++
+		return fmt.Errorf("Submitter %s over budget: %d pending, transaction costs %d, budget %d",
+			submitter, b.spent[submitter], cost, b.budget)
+	}
+	b.spent[submitter] += cost
+	hash := TxHash(tx)
+	b.pending[hash] = append(b.pending[hash], pendingTx{submitter, cost})
+	return nil
+}
+
+//refund gives the cost of a transaction that reached consensus, or left the
+//pool otherwise, back to its submitter. Transactions submitted to other nodes
+//are ignored.
+func (b *txBudgets) refund(tx []byte) {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	hash := TxHash(tx)
+	queue, ok := b.pending[hash]
+	if !ok {
+		return
+	}
+	p := queue[0]
+	if len(queue) == 1 {
+		delete(b.pending, hash)
+	} else {
+		b.pending[hash] = queue[1:]
+	}
+	b.spent[p.submitter] -= p.cost
+	if b.spent[p.submitter] <= 0 {
+		delete(b.spent, p.submitter)
+	}
+}
+
+//overBudget returns the number of transactions refused for exceeding a budget
+func (b *txBudgets) overBudget() int {
+	if b == nil {
+		return 0
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.refused
+}
+
+func (b *txBudgets) pendingCost(submitter string) int {
+	if b == nil {
+		return 0
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return b.spent[submitter]
+}
+
+//PendingCost returns the cost of the transactions of a submitter that were
+//submitted to this node and did not reach consensus yet. It is 0 without
+//Config.TxCost and Config.SubmitterBudget.
+func (n *Node) PendingCost(submitter string) int {
+	return n.budgets.pendingCost(submitter)
+}
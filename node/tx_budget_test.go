@@ -0,0 +1,52 @@
+package node
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestTxBudgets(t *testing.T) {
+	keys, peers := initPeers(1)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	conf := TestConfig(t)
+	//transactions are charged to their first byte, one per byte
+	conf.TxCost = func(tx []byte) (string, int, error) {
+		if len(tx) == 0 {
+			return "", 0, fmt.Errorf("Empty transaction")
+		}
+		return string(tx[:1]), len(tx), nil
+	}
+	conf.SubmitterBudget = 5
+	node := NewNode(conf, keys[0], peers, trans, aproxy.NewInmemAppProxy(conf.Logger))
+
+	for _, tx := range []string{"a123", "a1", "b1", "", "a", "b123456"} {
+		node.addTransaction([]byte(tx))
+	}
+	if txs, _ := node.core.PoolSize(); txs != 3 {
+		t.Fatalf("The pool should hold 3 transactions, not %d", txs)
+	}
+	if a, b := node.PendingCost("a"), node.PendingCost("b"); a != 5 || b != 2 {
+		t.Fatalf("Pending costs should be 5 and 2, not %d and %d", a, b)
+	}
+	if over := node.budgets.overBudget(); over != 2 {
+		t.Fatalf("2 transactions should be over budget, not %d", over)
+	}
+
+	//reaching consensus gives the cost back, once per transaction
+	node.budgets.refund([]byte("a123"))
+	node.budgets.refund([]byte("a123"))
+	node.budgets.refund([]byte("c1"))
+	if a := node.PendingCost("a"); a != 1 {
+		t.Fatalf("Pending cost of a should be 1, not %d", a)
+	}
+	node.addTransaction([]byte("a123"))
+	if a := node.PendingCost("a"); a != 5 {
+		t.Fatalf("Pending cost of a should be 5 again, not %d", a)
+	}
+}
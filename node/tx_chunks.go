@@ -0,0 +1,241 @@
+package node
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/babbleio/babble/codec"
+	"github.com/babbleio/babble/crypto"
+	hg "github.com/babbleio/babble/hashgraph"
+)
+
+/*
+Transactions that do not fit in a single Event's payload budget are split into
+chunks before they enter the transaction pool. Each chunk is an ordinary
+transaction carrying a small envelope: the SHA256 of the full transaction, the
+position of the chunk and the total number of chunks. Chunks are reassembled
+when they come out of consensus and the full transaction is only committed to
+the App once every chunk has been received and the hash checks out.
+
+Chunks are recognised by a magic prefix. An application transaction that
+happens to start with the same prefix, or with the prefix of a PeerJoin, a
+PeerLeave or block metadata, is wrapped in a single-chunk envelope so that it
+can never be mistaken for a chunk, a change of participants or metadata.
+
+Chunks come out of Events created by other nodes, so the assembler trusts none
+of their envelope: a transaction is at most MaxTransactionSize bytes once
+reassembled, which bounds the number of its chunks and the size of each, and a
+transaction that is still incomplete ChunkRounds rounds after its first chunk
+reached consensus is dropped. At most MaxPendingChunked transactions, holding
+MaxPendingChunkBytes bytes in total, are assembled at once: the chunks of other
+transactions are dropped until some complete or expire. All of this only
+depends on consensus, so every node drops the same transactions.
+*/
+
+var chunkMagic = []byte{0xBA, 0xBB, 0x1E, 0xC4}
+
+const (
+	//MaxTransactionSize is the largest transaction that is chunked and
+	//reassembled
+	MaxTransactionSize = 64 * 1024 * 1024
+	//ChunkRounds is the number of rounds received after which a transaction
+	//whose chunks did not all reach consensus is dropped
+	ChunkRounds = 10
+	//MaxPendingChunked is the largest number of transactions that are
+	//assembled at once
+	MaxPendingChunked = 64
+	//MaxPendingChunkBytes is the largest number of bytes held by the chunks of
+	//the transactions being assembled
+	MaxPendingChunkBytes = 4 * MaxTransactionSize
+
+	//upper bound on the bytes added by the envelope of a chunk
+	chunkOverhead = 128
+	//smallest amount of data carried by a chunk
+	minChunkData = 64
+	//largest number of chunks of a transaction
+	maxChunks = (MaxTransactionSize + minChunkData - 1) / minChunkData
+)
+
+type txChunk struct {
+	ID    []byte //sha256 of the full transaction
+	Index int    //position of this chunk
+	Total int    //number of chunks in the transaction
+	Data  []byte
+}
+
+func (c *txChunk) Marshal() ([]byte, error) {
+	var b bytes.Buffer
+	b.Write(chunkMagic)
+	enc := codec.Gob.NewEncoder(&b)
+	if err := enc.Encode(c); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (c *txChunk) Unmarshal(data []byte) error {
+	b := bytes.NewBuffer(data[len(chunkMagic):])
+	dec := codec.Gob.NewDecoder(b)
+	return dec.Decode(c)
+}
+
+func isChunk(tx []byte) bool {
+	return bytes.HasPrefix(tx, chunkMagic)
+}
+
+//splitTransaction returns the transactions to add to the pool in place of tx.
+//maxPayload is the Event payload budget in bytes; 0 disables chunking.
+func splitTransaction(tx []byte, maxPayload int) ([][]byte, error) {
+	reserved := isChunk(tx) || hg.IsMembershipTransaction(tx) || isMetadata(tx)
+	if !reserved && (maxPayload <= 0 || len(tx) <= maxPayload) {
+		return [][]byte{tx}, nil
+	}
+	if len(tx) > MaxTransactionSize {
+		return nil, fmt.Errorf("Transaction of %d bytes exceeds MaxTransactionSize %d", len(tx), MaxTransactionSize)
+	}
+
+	chunkData := maxPayload - chunkOverhead
+	if maxPayload <= 0 {
+		chunkData = len(tx)
+	} else if chunkData < minChunkData {
+		chunkData = minChunkData
+	}
+
+	id := crypto.SHA256(tx)
+	total := (len(tx) + chunkData - 1) / chunkData
+
+	res := make([][]byte, 0, total)
+	for i := 0; i < total; i++ {
+		end := (i + 1) * chunkData
+		if end > len(tx) {
+			end = len(tx)
+		}
+		chunk := txChunk{
+			ID:    id,
+			Index: i,
+			Total: total,
+			Data:  tx[i*chunkData : end],
+		}
+		raw, err := chunk.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, raw)
+	}
+	return res, nil
+}
+
+//++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+type partialTx struct {
+	chunks map[int][]byte //[index] => data
+	total  int
+	bytes  int
+	round  int //round received of the first chunk
+}
+
+//chunkAssembler collects the chunks of consensus transactions and releases
+//the full transactions once they are complete.
+type chunkAssembler struct {
+	partial  map[string]*partialTx //[hex id] => partial transaction
+	bytes    int                   //sum of the bytes of the partial transactions
+	maxCount int
+	maxBytes int
+}
+
+func newChunkAssembler() *chunkAssembler {
+	return &chunkAssembler{
+		partial:  make(map[string]*partialTx),
+		maxCount: MaxPendingChunked,
+		maxBytes: MaxPendingChunkBytes,
+	}
+}
+
+//Add returns the full transaction and true when tx completes one. Regular
+//transactions are returned as is. round is the round received of the Event of
+//tx.
+func (a *chunkAssembler) Add(tx []byte, round int) ([]byte, bool, error) {
+	a.Expire(round)
+	if !isChunk(tx) {
+		return tx, true, nil
+	}
+
+	var chunk txChunk
+	if err := chunk.Unmarshal(tx); err != nil {
+		return nil, false, err
+	}
+	if chunk.Total <= 0 || chunk.Total > maxChunks || chunk.Index < 0 || chunk.Index >= chunk.Total {
+		return nil, false, fmt.Errorf("Invalid chunk %d/%d", chunk.Index, chunk.Total)
+	}
+	if len(chunk.Data) == 0 || len(chunk.Data) > MaxTransactionSize {
+		return nil, false, fmt.Errorf("Invalid chunk of %d bytes", len(chunk.Data))
+	}
+
+	id := fmt.Sprintf("0x%X", chunk.ID)
+	p, ok := a.partial[id]
+	if !ok {
+		if len(a.partial) >= a.maxCount {
+			return nil, false, fmt.Errorf("Too many chunked transactions pending, dropping chunk of %s", id)
+		}
+		p = &partialTx{
+			chunks: make(map[int][]byte),
+			total:  chunk.Total,
+			round:  round,
+		}
+		a.partial[id] = p
+	}
+	if p.total != chunk.Total {
+		a.drop(id)
+		return nil, false, fmt.Errorf("Chunk count mismatch for %s", id)
+	}
+	if _, ok := p.chunks[chunk.Index]; !ok {
+		if p.bytes+len(chunk.Data) > MaxTransactionSize {
+			a.drop(id)
+			return nil, false, fmt.Errorf("Chunked transaction %s exceeds MaxTransactionSize %d", id, MaxTransactionSize)
+		}
+		if a.bytes+len(chunk.Data) > a.maxBytes {
+			a.drop(id)
+			return nil, false, fmt.Errorf("Chunked transactions exceed %d pending bytes, dropping %s", a.maxBytes, id)
+		}
+		p.chunks[chunk.Index] = chunk.Data
+		p.bytes += len(chunk.Data)
+		a.bytes += len(chunk.Data)
+	}
+	if len(p.chunks) < p.total {
+		return nil, false, nil
+	}
+
+	a.drop(id)
+	full := make([]byte, 0, p.bytes)
+	for i := 0; i < p.total; i++ {
+		full = append(full, p.chunks[i]...)
+	}
+	if !bytes.Equal(crypto.SHA256(full), chunk.ID) {
+		return nil, false, fmt.Errorf("Integrity check failed for chunked transaction %s", id)
+	}
+	return full, true, nil
+}
+
+//Expire drops the transactions whose first chunk was received ChunkRounds
+//rounds or more before round. Add calls it with every transaction, and the
+//node with every Event it commits, so that stale chunks do not wait for other
+//transactions to be released.
+func (a *chunkAssembler) Expire(round int) {
+	for id, p := range a.partial {
+		if round-p.round >= ChunkRounds {
+			a.drop(id)
+		}
+	}
+}
+
+func (a *chunkAssembler) drop(id string) {
+	if p, ok := a.partial[id]; ok {
+		a.bytes -= p.bytes
+		delete(a.partial, id)
+	}
+}
+
+//Pending returns the number of transactions that are partially assembled
+func (a *chunkAssembler) Pending() int {
+	return len(a.partial)
+}
@@ -0,0 +1,268 @@
+package node
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/babbleio/babble/crypto"
+	hg "github.com/babbleio/babble/hashgraph"
+)
+
+func TestSplitAndAssemble(t *testing.T) {
+	tx := make([]byte, 5000)
+	rand.Read(tx)
+
+	chunks, err := splitTransaction(tx, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if l := len(chunks); l != 6 {
+		t.Fatalf("Transaction should be split in 6 chunks, not %d", l)
+	}
+	for i, c := range chunks {
+		if len(c) > 1000 {
+			t.Fatalf("chunks[%d] should not exceed 1000 bytes, got %d", i, len(c))
+		}
+	}
+
+	assembler := newChunkAssembler()
+	//deliver out of order
+	order := []int{3, 0, 5, 1, 4}
+	for _, i := range order {
+		_, ok, err := assembler.Add(chunks[i], 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok {
+			t.Fatalf("Transaction should not be complete after chunk %d", i)
+		}
+	}
+	if p := assembler.Pending(); p != 1 {
+		t.Fatalf("Assembler should have 1 pending transaction, not %d", p)
+	}
+
+	full, ok, err := assembler.Add(chunks[2], 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("Transaction should be complete")
+	}
+	if !bytes.Equal(full, tx) {
+		t.Fatal("Reassembled transaction does not match original")
+	}
+	if p := assembler.Pending(); p != 0 {
+		t.Fatalf("Assembler should have 0 pending transactions, not %d", p)
+	}
+}
+
+func TestSplitSmallTransaction(t *testing.T) {
+	tx := []byte("small")
+
+	chunks, err := splitTransaction(tx, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 1 || !bytes.Equal(chunks[0], tx) {
+		t.Fatal("Small transaction should not be chunked")
+	}
+
+	//a transaction that looks like a chunk is always wrapped
+	fake := append(append([]byte{}, chunkMagic...), []byte("app data")...)
+	chunks, err = splitTransaction(fake, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 1 || bytes.Equal(chunks[0], fake) {
+		t.Fatal("Transaction with chunk prefix should be wrapped")
+	}
+
+	full, ok, err := newChunkAssembler().Add(chunks[0], 0)
+	if err != nil || !ok || !bytes.Equal(full, fake) {
+		t.Fatalf("Wrapped transaction should be returned as is, got %v, %v", ok, err)
+	}
+
+	//so is one that looks like a PeerJoin, even without chunking
+	join, _ := hg.PeerJoin{PubKey: "0x04", NetAddr: "127.0.0.1:1337"}.Marshal()
+	chunks, err = splitTransaction(join, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 1 || hg.IsPeerJoin(chunks[0]) {
+		t.Fatal("Transaction with PeerJoin prefix should be wrapped")
+	}
+	full, ok, err = newChunkAssembler().Add(chunks[0], 0)
+	if err != nil || !ok || !bytes.Equal(full, join) {
+		t.Fatalf("Wrapped PeerJoin should be returned as is, got %v, %v", ok, err)
+	}
+}
+
+func TestAssembleIntegrity(t *testing.T) {
+	tx := make([]byte, 3000)
+	rand.Read(tx)
+
+	chunks, err := splitTransaction(tx, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//tamper with the data of the first chunk
+	var c txChunk
+	if err := c.Unmarshal(chunks[0]); err != nil {
+		t.Fatal(err)
+	}
+	c.Data[0] ^= 0xFF
+	chunks[0], _ = c.Marshal()
+
+	assembler := newChunkAssembler()
+	var lastErr error
+	for _, c := range chunks {
+		_, ok, err := assembler.Add(c, 0)
+		if ok {
+			t.Fatal("Tampered transaction should not be released")
+		}
+		lastErr = err
+	}
+	if lastErr == nil {
+		t.Fatal("Tampered transaction should fail integrity check")
+	}
+}
+
+func TestAssembleBounds(t *testing.T) {
+	assembler := newChunkAssembler()
+	id := crypto.SHA256([]byte("tx"))
+
+	invalid := []txChunk{
+		{ID: id, Index: 0, Total: maxChunks + 1, Data: []byte("a")},
+		{ID: id, Index: 0, Total: 2, Data: nil},
+		{ID: id, Index: 0, Total: 2, Data: make([]byte, MaxTransactionSize+1)},
+	}
+	for i, c := range invalid {
+		raw, _ := c.Marshal()
+		if _, ok, err := assembler.Add(raw, 0); ok || err == nil {
+			t.Fatalf("Chunk %d should be refused", i)
+		}
+	}
+
+	//chunks adding up to more than MaxTransactionSize
+	big := make([]byte, MaxTransactionSize/2+1)
+	for i := 0; i < 2; i++ {
+		raw, _ := (&txChunk{ID: id, Index: i, Total: 3, Data: big}).Marshal()
+		_, ok, err := assembler.Add(raw, 0)
+		if ok || (i == 0) != (err == nil) {
+			t.Fatalf("Chunk %d: expected an error only past MaxTransactionSize, got %v, %v", i, ok, err)
+		}
+	}
+	if p := assembler.Pending(); p != 0 {
+		t.Fatalf("Oversized transaction should be dropped, %d pending", p)
+	}
+}
+
+func TestAssembleExpiry(t *testing.T) {
+	tx := make([]byte, 3000)
+	rand.Read(tx)
+	chunks, err := splitTransaction(tx, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assembler := newChunkAssembler()
+	if _, ok, err := assembler.Add(chunks[0], 5); ok || err != nil {
+		t.Fatalf("First chunk should be kept, got %v, %v", ok, err)
+	}
+	if _, ok, err := assembler.Add(chunks[1], 5+ChunkRounds-1); ok || err != nil {
+		t.Fatalf("Second chunk should be kept, got %v, %v", ok, err)
+	}
+	//the transaction expired before its last chunk
+	if _, ok, _ := assembler.Add(chunks[2], 5+ChunkRounds); ok {
+		t.Fatal("Expired transaction should not be released")
+	}
+	if p := assembler.Pending(); p != 1 {
+		t.Fatalf("Only the last chunk should be pending, not %d transactions", p)
+	}
+	if _, ok, _ := assembler.Add([]byte("regular"), 5+2*ChunkRounds); !ok {
+		t.Fatal("Regular transaction should be released")
+	}
+	if _, ok, _ := assembler.Add(chunks[0], 5+2*ChunkRounds); ok {
+		t.Fatal("Transaction should not be released from a single chunk")
+	}
+	if p := assembler.Pending(); p != 1 {
+		t.Fatalf("Stale chunk should be expired, got %d pending transactions", p)
+	}
+}
+
+func TestAssembleLimits(t *testing.T) {
+	assembler := newChunkAssembler()
+	assembler.maxCount = 2
+	assembler.maxBytes = 250
+
+	first := func(tx string, size int) []byte {
+		raw, _ := (&txChunk{ID: crypto.SHA256([]byte(tx)), Index: 0, Total: 2, Data: make([]byte, size)}).Marshal()
+		return raw
+	}
+
+	for _, tx := range []string{"a", "b"} {
+		if _, _, err := assembler.Add(first(tx, 100), 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//a third transaction is refused while two are pending
+	if _, _, err := assembler.Add(first("c", 10), 0); err == nil {
+		t.Fatal("Chunk of a third transaction should be refused")
+	}
+	if p := assembler.Pending(); p != 2 {
+		t.Fatalf("Pending should be 2, not %d", p)
+	}
+
+	//the second chunk of b goes over the pending bytes, so b is dropped
+	raw, _ := (&txChunk{ID: crypto.SHA256([]byte("b")), Index: 1, Total: 2, Data: make([]byte, 100)}).Marshal()
+	if _, _, err := assembler.Add(raw, 0); err == nil {
+		t.Fatal("Chunk going over the pending bytes should be refused")
+	}
+	if p, b := assembler.Pending(), assembler.bytes; p != 1 || b != 100 {
+		t.Fatalf("Only a should be pending, got %d transactions of %d bytes", p, b)
+	}
+	if _, _, err := assembler.Add(first("c", 10), 0); err != nil {
+		t.Fatal(err)
+	}
+
+	//expiry does not wait for another chunk
+	assembler.Expire(ChunkRounds)
+	if p, b := assembler.Pending(), assembler.bytes; p != 0 || b != 0 {
+		t.Fatalf("Expired transactions should be dropped, got %d transactions of %d bytes", p, b)
+	}
+}
+
+func TestNextPayload(t *testing.T) {
+	cores, _, _ := initCores(1, t)
+	core := cores[0]
+	core.SetMaxEventPayload(1000)
+
+	big := make([]byte, 2500)
+	if err := core.AddTransactions([][]byte{[]byte("a"), big, []byte("b")}); err != nil {
+		t.Fatal(err)
+	}
+
+	events := 0
+	for len(core.transactionPool) > 0 {
+		if err := core.AddSelfEvent(); err != nil {
+			t.Fatal(err)
+		}
+		head, err := core.GetHead()
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := 0
+		for _, tx := range head.Transactions() {
+			size += len(tx)
+		}
+		if len(head.Transactions()) > 1 && size > 1000 {
+			t.Fatalf("Event payload should not exceed 1000 bytes, got %d", size)
+		}
+		events++
+	}
+	if events < 3 {
+		t.Fatalf("Transactions should be spread over at least 3 events, not %d", events)
+	}
+}
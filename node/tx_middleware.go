@@ -0,0 +1,124 @@
+package node
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sync/atomic"
+
+	"github.com/babbleio/babble/common"
+)
+
+/*
+Transaction middleware lets embedders inspect, transform or reject
+transactions on their way through the node. Middleware is registered in
+Config.TxMiddleware and runs in order:
+
+  - Submit is called between the App's SubmitTx and the transaction pool. An
+    error drops the transaction before it reaches the hashgraph.
+  - Commit is called between consensus and the App's CommitBlock, after large
+    transactions have been reassembled. An error drops the transaction for this
+    node only, so Commit must give the same result on every node or the
+    Apps will diverge.
+
+The transaction returned by a middleware is passed to the next one.
+*/
+
+//TxMiddleware is a stage of the transaction pipeline
+type TxMiddleware interface {
+	Submit(tx []byte) ([]byte, error)
+	Commit(tx []byte) ([]byte, error)
+}
+
+//TxMiddlewareFuncs implements TxMiddleware with functions. A nil function lets
+//transactions through unchanged.
+type TxMiddlewareFuncs struct {
+	OnSubmit func(tx []byte) ([]byte, error)
+	OnCommit func(tx []byte) ([]byte, error)
+}
+
+func (m TxMiddlewareFuncs) Submit(tx []byte) ([]byte, error) {
+	if m.OnSubmit == nil {
+		return tx, nil
+	}
+	return m.OnSubmit(tx)
+}
+
+func (m TxMiddlewareFuncs) Commit(tx []byte) ([]byte, error) {
+	if m.OnCommit == nil {
+		return tx, nil
+	}
+	return m.OnCommit(tx)
+}
+
+//txPipeline runs transactions through an ordered list of middleware and
+//counts the rejections. Submissions and commits run on different goroutines,
+//so the counters are atomic.
+type txPipeline struct {
+	submitRejected int64 //atomic. First for its alignment
+	commitRejected int64 //atomic
+	middleware     []TxMiddleware
+}
+
+func newTxPipeline(middleware []TxMiddleware) *txPipeline {
+	return &txPipeline{middleware: middleware}
+}
+
+func (p *txPipeline) submit(tx []byte) ([]byte, error) {
+	for _, m := range p.middleware {
+		var err error
+		if tx, err = m.Submit(tx); err != nil {
+			atomic.AddInt64(&p.submitRejected, 1)
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+func (p *txPipeline) commit(tx []byte) ([]byte, error) {
+	for _, m := range p.middleware {
+		var err error
+		if tx, err = m.Commit(tx); err != nil {
+			atomic.AddInt64(&p.commitRejected, 1)
+			return nil, err
+		}
+	}
+	return tx, nil
+}
+
+//rejected returns the number of transactions rejected by Submit and by Commit
+func (p *txPipeline) rejected() (submit, commit int) {
+	return int(atomic.LoadInt64(&p.submitRejected)), int(atomic.LoadInt64(&p.commitRejected))
+}
+
+//+++++++++++++++++++++++++++++++++++++++
+//DEDUP
+
+//ErrDuplicateTx is returned by DedupMiddleware
+var ErrDuplicateTx = errors.New("Duplicate transaction")
+
+//DedupMiddleware rejects transactions identical to one of the last transactions
+//submitted to this node. It does not look at transactions submitted to other
+//nodes.
+type DedupMiddleware struct {
+	seen *common.LRU
+}
+
+//NewDedupMiddleware remembers the hashes of the last size transactions
+func NewDedupMiddleware(size int) *DedupMiddleware {
+	return &DedupMiddleware{
+		seen: common.NewLRU(size, nil),
+	}
+}
+
+func (m *DedupMiddleware) Submit(tx []byte) ([]byte, error) {
+	key := sha256.Sum256(tx)
+	if m.seen.Contains(key) {
+		return nil, ErrDuplicateTx
+	}
+	m.seen.Add(key, true)
+	return tx, nil
+}
+
+func (m *DedupMiddleware) Commit(tx []byte) ([]byte, error) {
+	return tx, nil
+}
@@ -0,0 +1,135 @@
+package node
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestTxPipelineOrder(t *testing.T) {
+	calls := []string{}
+	mw := func(name string) TxMiddleware {
+		return TxMiddlewareFuncs{
+			OnSubmit: func(tx []byte) ([]byte, error) {
+				calls = append(calls, name)
+				return append(tx, name...), nil
+			},
+		}
+	}
+	p := newTxPipeline([]TxMiddleware{mw("a"), mw("b")})
+
+	tx, err := p.submit([]byte("tx-"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(tx) != "tx-ab" {
+		t.Fatalf("Transaction should be transformed in order, got %s", tx)
+	}
+	if !reflect.DeepEqual(calls, []string{"a", "b"}) {
+		t.Fatalf("Middleware should be called in order, got %v", calls)
+	}
+
+	//no OnCommit: commit is a pass-through
+	tx, err = p.commit([]byte("tx"))
+	if err != nil || string(tx) != "tx" {
+		t.Fatalf("Commit should let the transaction through, got %s, %v", tx, err)
+	}
+}
+
+func TestTxPipelineReject(t *testing.T) {
+	errRejected := errors.New("rejected")
+	called := false
+	p := newTxPipeline([]TxMiddleware{
+		TxMiddlewareFuncs{
+			OnCommit: func(tx []byte) ([]byte, error) {
+				return nil, errRejected
+			},
+		},
+		TxMiddlewareFuncs{
+			OnCommit: func(tx []byte) ([]byte, error) {
+				called = true
+				return tx, nil
+			},
+		},
+	})
+
+	if _, err := p.commit([]byte("tx")); err != errRejected {
+		t.Fatalf("Commit should return the middleware error, not %v", err)
+	}
+	if called {
+		t.Fatal("Middleware after a rejection should not be called")
+	}
+	if submits, commits := p.rejected(); commits != 1 || submits != 0 {
+		t.Fatalf("Rejections should be counted, got %d submits %d commits",
+			submits, commits)
+	}
+}
+
+func TestDedupMiddleware(t *testing.T) {
+	m := NewDedupMiddleware(2)
+
+	for _, tx := range []string{"a", "b"} {
+		if _, err := m.Submit([]byte(tx)); err != nil {
+			t.Fatalf("First submission of %s should be accepted: %v", tx, err)
+		}
+	}
+	if _, err := m.Submit([]byte("a")); err != ErrDuplicateTx {
+		t.Fatalf("Second submission of a should be rejected, got %v", err)
+	}
+
+	//"a" is the oldest and is evicted by "c"
+	m.Submit([]byte("c"))
+	if _, err := m.Submit([]byte("a")); err != nil {
+		t.Fatalf("Evicted transaction should be accepted again, got %v", err)
+	}
+}
+
+func TestNodeTxMiddleware(t *testing.T) {
+	keys, peers := initPeers(1)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+
+	conf := TestConfig(t)
+	conf.MaxEventPayload = 0
+	conf.TxMiddleware = []TxMiddleware{
+		NewDedupMiddleware(10),
+		TxMiddlewareFuncs{
+			OnCommit: func(tx []byte) ([]byte, error) {
+				if bytes.HasPrefix(tx, []byte("invalid")) {
+					return nil, errors.New("invalid")
+				}
+				return bytes.ToUpper(tx), nil
+			},
+		},
+	}
+	prox := aproxy.NewInmemAppProxy(conf.Logger)
+	node := NewNode(conf, keys[0], peers, trans, prox)
+	defer trans.Close()
+
+	for _, tx := range []string{"one", "one", "invalid"} {
+		node.addTransaction([]byte(tx))
+	}
+	if l := len(node.core.transactionPool); l != 2 {
+		t.Fatalf("Duplicate should not reach the pool: pool has %d transactions", l)
+	}
+
+	event := hg.NewEvent(node.core.transactionPool, []string{"", ""}, []byte{}, 0)
+	if err := node.commit([]hg.Event{event}); err != nil {
+		t.Fatal(err)
+	}
+
+	committed := prox.GetCommittedTransactions()
+	if !reflect.DeepEqual(committed, [][]byte{[]byte("ONE")}) {
+		t.Fatalf("Committed transactions should be [ONE], got %q", committed)
+	}
+	stats := node.GetStats()
+	if stats["rejected_submits"] != "1" || stats["rejected_commits"] != "1" {
+		t.Fatalf("Rejections should be reported in stats, got %s submits %s commits",
+			stats["rejected_submits"], stats["rejected_commits"])
+	}
+}
@@ -0,0 +1,49 @@
+package node
+
+import (
+	"errors"
+	"time"
+)
+
+/*
+With Config.MaxPoolTxs or Config.MaxPoolBytes, the transaction pool stops taking
+transactions once it holds that many, until gossip moves them into self-events.
+The last transaction admitted may take the pool past the limit in bytes, so that
+a transaction larger than the limit still gets in when the pool is not full.
+
+What happens to the transactions submitted in the meantime depends on
+Config.BlockOnFullPool. By default they are refused: SubmitTx returns
+ErrPoolFull, and the transactions of the App are dropped and counted in the
+rejected_txs stat. With BlockOnFullPool, the node stops reading the submit
+channel instead, so that SubmitTx and the App block until there is room again.
+*/
+
+//ErrPoolFull is returned by SubmitTx when the transaction pool is full
+var ErrPoolFull = errors.New("Transaction pool is full")
+
+//poolFull is true if the transaction pool reached one of its limits
+func (n *Node) poolFull() bool {
+	if n.conf.MaxPoolTxs <= 0 && n.conf.MaxPoolBytes <= 0 {
+		return false
+	}
+	n.coreLock.RLock()
+	defer n.coreLock.RUnlock()
+	return n.poolFullLocked()
+}
+
+//must be called with the coreLock held
+func (n *Node) poolFullLocked() bool {
+	txs, bytes := n.core.PoolSize()
+	return (n.conf.MaxPoolTxs > 0 && txs >= n.conf.MaxPoolTxs) ||
+		(n.conf.MaxPoolBytes > 0 && bytes >= n.conf.MaxPoolBytes)
+}
+
+//submissions returns the channel to read transactions from, which is nil while
+//a full pool blocks them, and a channel that fires when the pool should be
+//checked again
+func (n *Node) submissions() (<-chan []byte, <-chan time.Time) {
+	if n.conf.BlockOnFullPool && n.poolFull() {
+		return nil, time.After(n.heartbeat())
+	}
+	return n.submitCh, nil
+}
@@ -0,0 +1,63 @@
+package node
+
+import (
+	"testing"
+
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestPoolLimits(t *testing.T) {
+	keys, peers := initPeers(1)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	conf := TestConfig(t)
+	conf.MaxPoolTxs = 2
+	node := NewNode(conf, keys[0], peers, trans, aproxy.NewInmemAppProxy(conf.Logger))
+
+	for _, tx := range []string{"a", "b", "c"} {
+		node.addTransaction([]byte(tx))
+	}
+	if txs, bytes := node.core.PoolSize(); txs != 2 || bytes != 2 {
+		t.Fatalf("The pool should hold 2 transactions of 2 bytes, not %d of %d", txs, bytes)
+	}
+	if node.rejectedTxs != 1 {
+		t.Fatalf("1 transaction should be rejected, not %d", node.rejectedTxs)
+	}
+	if _, err := node.SubmitTx([]byte("d")); err != ErrPoolFull {
+		t.Fatalf("SubmitTx should fail with a full pool, got %v", err)
+	}
+
+	//a blocking pool stops reading submissions instead
+	conf.BlockOnFullPool = true
+	if submitCh, poolCheck := node.submissions(); submitCh != nil || poolCheck == nil {
+		t.Fatal("A full blocking pool should stop reading submissions")
+	}
+
+	node.core.nextPayload()
+	if txs, bytes := node.core.PoolSize(); txs != 0 || bytes != 0 {
+		t.Fatalf("The pool should be empty, not %d transactions of %d bytes", txs, bytes)
+	}
+	if submitCh, _ := node.submissions(); submitCh == nil {
+		t.Fatal("An empty pool should read submissions")
+	}
+}
+
+func TestPoolBytes(t *testing.T) {
+	cores, _, _ := initCores(1, t)
+	core := cores[0]
+	core.maxEventPayload = 4
+
+	core.AddTransactions([][]byte{[]byte("abc"), []byte("defgh"), []byte("ij")})
+	if _, bytes := core.PoolSize(); bytes < 10 {
+		t.Fatalf("The pool should hold at least 10 bytes, not %d", bytes)
+	}
+	for len(core.transactionPool) > 0 {
+		core.nextPayload()
+	}
+	if _, bytes := core.PoolSize(); bytes != 0 {
+		t.Fatalf("An empty pool should hold 0 bytes, not %d", bytes)
+	}
+}
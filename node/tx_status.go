@@ -0,0 +1,107 @@
+package node
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/babbleio/babble/common"
+)
+
+//number of committed transactions remembered by TxStatus
+const committedTxsSize = 10000
+
+//longest time SubmitTx waits for the node to take a transaction
+const submitTimeout = 10 * time.Second
+
+//ErrShuttingDown is returned by SubmitTx once the node is shutting down
+var ErrShuttingDown = errors.New("Node is shutting down")
+
+//ErrSubmitTimeout is returned by SubmitTx when the node did not take the
+//transaction within submitTimeout, for example because its pool stayed full
+var ErrSubmitTimeout = errors.New("Timed out submitting transaction")
+
+//TxHash is the identifier of a transaction used by SubmitTx and TxStatus: the
+//hex encoded SHA256 of its bytes
+func TxHash(tx []byte) string {
+	return fmt.Sprintf("%X", sha256.Sum256(tx))
+}
+
+//committedTxs remembers when the last transactions reached consensus. It is
+//written by the commit loop and read by the service.
+type committedTxs struct {
+	sync.Mutex
+	cache *common.LRU
+}
+
+type committedTx struct {
+	time      time.Time
+	watermark int //committed watermark once the transaction was committed
+}
+
+func newCommittedTxs(size int) *committedTxs {
+	return &committedTxs{cache: common.NewLRU(size, nil)}
+}
+
+//addAll records transactions once the App committed the Block of the
+//watermark, which is the Block that contains them unless none of them was
+//delivered to the App
+func (c *committedTxs) addAll(txs [][]byte, watermark int) {
+	c.Lock()
+	defer c.Unlock()
+	now := time.Now()
+	for _, tx := range txs {
+		c.cache.Add(TxHash(tx), committedTx{time: now, watermark: watermark})
+	}
+}
+
+func (c *committedTxs) get(hash string) (committedTx, bool) {
+	c.Lock()
+	defer c.Unlock()
+	t, ok := c.cache.Peek(hash)
+	if !ok {
+		return committedTx{}, false
+	}
+	return t.(committedTx), true
+}
+
+//SubmitTx queues a transaction as if it came from the App and returns its
+//TxHash. It returns ErrPoolFull when the transaction pool is full, unless
+//Config.BlockOnFullPool makes it wait for room, ErrSubmitTimeout if it waits
+//longer than submitTimeout, and ErrDiskPressure while the node is low on disk
+//space.
+func (n *Node) SubmitTx(tx []byte) (string, error) {
+	if n.DiskPressure() {
+		return "", ErrDiskPressure
+	}
+	if !n.conf.BlockOnFullPool && n.poolFull() {
+		return "", ErrPoolFull
+	}
+	select {
+	case n.submitCh <- tx:
+		return TxHash(tx), nil
+	case <-n.shutdownCh:
+		return "", ErrShuttingDown
+	case <-time.After(submitTimeout):
+		return "", ErrSubmitTimeout
+	}
+}
+
+//TxStatus reports whether the transaction with the given TxHash was among the
+//last transactions to reach consensus, and when it was committed. Transactions
+//are identified by their bytes before the Commit middleware, which are the
+//submitted bytes unless a Submit middleware transformed them.
+func (n *Node) TxStatus(hash string) (time.Time, bool) {
+	tx, ok := n.committedTxs.get(hash)
+	return tx.time, ok
+}
+
+//TxWatermark returns the committed watermark at which the App had applied the
+//transaction with the given TxHash, which other nodes reach before they
+//reflect it. See WaitWatermark.
+func (n *Node) TxWatermark(hash string) (int, bool) {
+	tx, ok := n.committedTxs.get(hash)
+	return tx.watermark, ok
+}
@@ -0,0 +1,39 @@
+package node
+
+import (
+	"testing"
+
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestTxStatus(t *testing.T) {
+	keys, peers := initPeers(1)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	conf := TestConfig(t)
+	node := NewNode(conf, keys[0], peers, trans, aproxy.NewInmemAppProxy(conf.Logger))
+
+	tx := []byte("transaction")
+	hash := TxHash(tx)
+	if _, ok := node.TxStatus(hash); ok {
+		t.Fatal("Transaction should not be committed yet")
+	}
+
+	event := hg.NewEvent([][]byte{tx}, []string{"", ""}, []byte{}, 0)
+	if err := node.commit([]hg.Event{event}); err != nil {
+		t.Fatal(err)
+	}
+	committed, ok := node.TxStatus(hash)
+	if !ok || committed.IsZero() {
+		t.Fatal("Transaction should be committed")
+	}
+
+	close(node.shutdownCh)
+	if _, err := node.SubmitTx(tx); err != ErrShuttingDown {
+		t.Fatalf("SubmitTx should fail after shutdown, got %v", err)
+	}
+}
@@ -0,0 +1,150 @@
+package node
+
+import (
+	"sort"
+	"sync"
+)
+
+/*
+Governance processes deciding who stays in the validator set need objective
+data on how each validator does its job. ValidatorDuties reports, for every
+current participant, over a sliding window of the last Config.DutyWindow rounds
+that reached consensus:
+
+ - the Events it created in those rounds,
+ - the rounds in which it had a witness, and the rounds it missed,
+ - of the last DutyWindow syncs this node requested from it, how many it
+   answered.
+
+The rounds come from the hashgraph store and are read when the duties are
+requested, so the window shrinks to the rounds still held by the store. Syncs
+are seen from this node only; comparing the reports of several nodes tells a
+validator that does not answer from a node with a bad link.
+*/
+
+//DefaultDutyWindow is the DutyWindow used when Config.DutyWindow is 0
+const DefaultDutyWindow = 100
+
+//ValidatorDuty is the participation of a validator over the duty window
+type ValidatorDuty struct {
+	PubKey         string
+	NetAddr        string
+	Events         int //Events created in the rounds of the window
+	Witnesses      int //rounds of the window with a witness of the validator
+	RoundsMissed   int //rounds of the window without a witness of the validator
+	SyncsRequested int //syncs requested from the validator, out of the last DutyWindow
+	SyncsAnswered  int
+}
+
+//ValidatorDuties is the participation of the validators in the consensus
+//rounds from FromRound to ToRound. Both are -1 when no round reached consensus.
+type ValidatorDuties struct {
+	FromRound  int
+	ToRound    int
+	Validators []ValidatorDuty
+}
+
+//dutyTracker keeps the results of the last syncs requested from each peer
+type dutyTracker struct {
+	lock   sync.Mutex
+	window int
+	syncs  map[string][]bool //[net addr] => answered, oldest first
+}
+
+func newDutyTracker(window int) *dutyTracker {
+	if window <= 0 {
+		window = DefaultDutyWindow
+	}
+	return &dutyTracker{
+		window: window,
+		syncs:  make(map[string][]bool),
+	}
+}
+
+func (d *dutyTracker) synced(peer string, answered bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	results := append(d.syncs[peer], answered)
+	if len(results) > d.window {
+		results = results[len(results)-d.window:]
+	}
+	d.syncs[peer] = results
+}
+
+//syncStats returns the syncs requested from peer and answered, in the window
+func (d *dutyTracker) syncStats(peer string) (int, int) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+	answered := 0
+	for _, ok := range d.syncs[peer] {
+		if ok {
+			answered++
+		}
+	}
+	return len(d.syncs[peer]), answered
+}
+
+//ValidatorDuties returns the participation of the current participants over
+//the last rounds that reached consensus, see validator_duties.go
+func (n *Node) ValidatorDuties() ValidatorDuties {
+	addrs := make(map[string]string)
+	n.selectorLock.Lock()
+	for _, p := range n.peerSelector.Peers() {
+		addrs[p.PubKeyHex] = p.NetAddr
+	}
+	n.selectorLock.Unlock()
+
+	n.coreLock.RLock()
+	duties := ValidatorDuties{FromRound: -1, ToRound: -1}
+	events := make(map[string]int)
+	witnesses := make(map[string]int)
+	rounds := 0
+	if last := n.core.GetLastConsensusRoundIndex(); last != nil {
+		duties.ToRound = *last
+		for r := *last; r > *last-n.duties.window && r >= 0; r-- {
+			info, err := n.core.hg.Store.GetRound(r)
+			if err != nil {
+				break
+			}
+			duties.FromRound = r
+			rounds++
+			witnessed := make(map[string]bool)
+			for hash, re := range info.Events {
+				ev, err := n.core.hg.Store.GetEvent(hash)
+				if err != nil {
+					continue
+				}
+				events[ev.Creator()]++
+				if re.Witness {
+					witnessed[ev.Creator()] = true
+				}
+			}
+			for creator := range witnessed {
+				witnesses[creator]++
+			}
+		}
+	}
+	participants := make([]string, 0, len(n.core.hg.Participants))
+	for pubKey := range n.core.hg.Participants {
+		participants = append(participants, pubKey)
+	}
+	n.coreLock.RUnlock()
+
+	sort.Strings(participants)
+	for _, pubKey := range participants {
+		duty := ValidatorDuty{
+			PubKey:       pubKey,
+			NetAddr:      addrs[pubKey],
+			Events:       events[pubKey],
+			Witnesses:    witnesses[pubKey],
+			RoundsMissed: rounds - witnesses[pubKey],
+		}
+		if pubKey == n.core.HexID() {
+			duty.NetAddr = n.localAddr
+		} else if duty.NetAddr != "" {
+			duty.SyncsRequested, duty.SyncsAnswered = n.duties.syncStats(duty.NetAddr)
+		}
+		duties.Validators = append(duties.Validators, duty)
+	}
+	return duties
+}
@@ -0,0 +1,53 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+)
+
+func TestDutyTracker(t *testing.T) {
+	d := newDutyTracker(3)
+	for _, answered := range []bool{false, true, false, true} {
+		d.synced("A", answered)
+	}
+	if requested, answered := d.syncStats("A"); requested != 3 || answered != 2 {
+		t.Fatalf("The window should hold 3 syncs with 2 answered, not %d and %d", requested, answered)
+	}
+	if requested, _ := d.syncStats("B"); requested != 0 {
+		t.Fatalf("No sync should be requested from B, not %d", requested)
+	}
+}
+
+func TestValidatorDuties(t *testing.T) {
+	_, nodes := initNodes(4, 1000, common.NewTestLogger(t))
+	if err := gossip(nodes, 10, true, 3*time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	duties := nodes[0].ValidatorDuties()
+	if duties.ToRound < 0 || duties.FromRound < 0 || duties.FromRound > duties.ToRound {
+		t.Fatalf("Duties should cover consensus rounds, not %d to %d", duties.FromRound, duties.ToRound)
+	}
+	if len(duties.Validators) != len(nodes) {
+		t.Fatalf("Duties should list %d validators, not %d", len(nodes), len(duties.Validators))
+	}
+	rounds := duties.ToRound - duties.FromRound + 1
+	requested := 0
+	for _, v := range duties.Validators {
+		if v.Events == 0 || v.Witnesses == 0 {
+			t.Fatalf("Validator %s should have Events and witnesses, got %+v", v.NetAddr, v)
+		}
+		if v.Witnesses+v.RoundsMissed != rounds {
+			t.Fatalf("Validator %s should have a witness or miss each of %d rounds, got %+v", v.NetAddr, rounds, v)
+		}
+		if v.SyncsAnswered > v.SyncsRequested {
+			t.Fatalf("Validator %s answered more syncs than requested: %+v", v.NetAddr, v)
+		}
+		requested += v.SyncsRequested
+	}
+	if requested == 0 {
+		t.Fatal("Duties should count the syncs requested from the peers")
+	}
+}
@@ -0,0 +1,56 @@
+package node
+
+import (
+	"github.com/babbleio/babble/common"
+)
+
+/*
+When Babble runs as a systemd service with Type=notify, the node sends READY=1
+once it starts gossiping and STOPPING=1 when it shuts down. If the unit also
+sets WatchdogSec, the node pings the watchdog only while consensus is making
+progress, or while there is nothing to agree on, so that systemd restarts a
+node that is stuck rather than one that is merely alive. WatchdogSec should be
+larger than StallTimeout to leave time for the stall monitor to recover.
+*/
+
+func (n *Node) notifyReady() {
+	if n.readyNotified {
+		return
+	}
+	n.readyNotified = true
+	if _, err := common.SdNotify(common.SdReady); err != nil {
+		n.logger.WithField("error", err).Error("Notifying systemd")
+	}
+}
+
+func (n *Node) notifyStopping() {
+	if _, err := common.SdNotify(common.SdStopping); err != nil {
+		n.logger.WithField("error", err).Error("Notifying systemd")
+	}
+}
+
+//watchdogPing returns the job that pings the watchdog, every half of its
+//interval
+func (n *Node) watchdogPing() func() error {
+	lastRound := n.lastConsensusRound()
+
+	return func() error {
+		round := n.lastConsensusRound()
+		//a Faulted node is left to the watchdog, the other states without
+		//gossip are deliberate
+		state := n.getState()
+		healthy := round != lastRound || !n.needConsensus() ||
+			(state != Babbling && state != Faulted)
+		lastRound = round
+
+		if !healthy {
+			n.logger.WithField("last_consensus_round", round).Debug("Withholding watchdog ping")
+			return nil
+		}
+		_, err := common.SdNotify(common.SdWatchdog)
+		if err != nil {
+			n.logger.WithField("error", err).Error("Pinging systemd watchdog")
+		}
+		return err
+	}
+}
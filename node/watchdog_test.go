@@ -0,0 +1,48 @@
+package node
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+)
+
+func TestSystemdNotifications(t *testing.T) {
+	dir, err := ioutil.TempDir("", "babble")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	addr := &net.UnixAddr{Name: filepath.Join(dir, "notify.sock"), Net: "unixgram"}
+	conn, err := net.ListenUnixgram("unixgram", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	os.Setenv("NOTIFY_SOCKET", addr.Name)
+	os.Setenv("WATCHDOG_USEC", "100000")
+	defer os.Unsetenv("NOTIFY_SOCKET")
+	defer os.Unsetenv("WATCHDOG_USEC")
+
+	logger := common.NewTestLogger(t)
+	_, nodes := initNodes(2, 1000, logger)
+	defer shutdownNodes(nodes)
+	runNodes(nodes, true)
+
+	received := make(map[string]bool)
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(3 * time.Second))
+	for !received[common.SdReady] || !received[common.SdWatchdog] {
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("Waiting for notifications, got %v: %s", received, err)
+		}
+		received[string(buf[:n])] = true
+	}
+}
@@ -0,0 +1,77 @@
+package node
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+/*
+The committed watermark is the index of the last Block that the App committed,
+-1 before the first one. It only moves once the App returned from CommitBlock,
+and transactions are only reported committed by TxStatus from then on, so a
+client that saw its transaction committed by a node finds it applied in every
+query it makes to that node afterwards.
+
+Every node numbers the same Blocks the same way, so the watermark also lets a
+client read its writes on other nodes: it waits, with WaitWatermark, until their
+watermark reaches the one that TxWatermark reported for its transaction.
+*/
+
+//ErrWatermarkTimeout is returned by WaitWatermark when the node does not commit
+//the Block in time
+var ErrWatermarkTimeout = errors.New("Timeout waiting for the committed watermark")
+
+type watermark struct {
+	sync.Mutex
+	index   int
+	changed chan struct{} //closed and replaced when index moves
+}
+
+func newWatermark() *watermark {
+	return &watermark{
+		index:   -1,
+		changed: make(chan struct{}),
+	}
+}
+
+func (w *watermark) set(index int) {
+	w.Lock()
+	defer w.Unlock()
+	w.index = index
+	close(w.changed)
+	w.changed = make(chan struct{})
+}
+
+//get returns the index and a channel closed when it moves
+func (w *watermark) get() (int, <-chan struct{}) {
+	w.Lock()
+	defer w.Unlock()
+	return w.index, w.changed
+}
+
+//CommittedWatermark returns the index of the last Block committed to the App,
+//-1 if none
+func (n *Node) CommittedWatermark() int {
+	index, _ := n.watermark.get()
+	return index
+}
+
+//WaitWatermark waits until the App committed the Block of the given index
+func (n *Node) WaitWatermark(index int, timeout time.Duration) error {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		current, changed := n.watermark.get()
+		if current >= index {
+			return nil
+		}
+		select {
+		case <-changed:
+		case <-timer.C:
+			return ErrWatermarkTimeout
+		case <-n.shutdownCh:
+			return ErrShuttingDown
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package node
+
+import (
+	"testing"
+	"time"
+
+	hg "github.com/babbleio/babble/hashgraph"
+	"github.com/babbleio/babble/net"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+func TestWatermark(t *testing.T) {
+	keys, peers := initPeers(1)
+	addr, trans := net.NewInmemTransport(peers[0].NetAddr)
+	peers[0].NetAddr = addr
+	defer trans.Close()
+
+	conf := TestConfig(t)
+	node := NewNode(conf, keys[0], peers, trans, aproxy.NewInmemAppProxy(conf.Logger))
+
+	if w := node.CommittedWatermark(); w != -1 {
+		t.Fatalf("Watermark should be -1 before the first Block, not %d", w)
+	}
+	if err := node.WaitWatermark(0, 10*time.Millisecond); err != ErrWatermarkTimeout {
+		t.Fatalf("Waiting for Block 0 should time out, got %v", err)
+	}
+
+	commit := func(txs ...string) {
+		event := hg.NewEvent(nil, []string{"", ""}, []byte{}, 0)
+		for _, tx := range txs {
+			event.Body.Transactions = append(event.Body.Transactions, []byte(tx))
+		}
+		if err := node.commit([]hg.Event{event}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	//a query waiting for the next Block is released when it is committed
+	done := make(chan error)
+	go func() { done <- node.WaitWatermark(0, 5*time.Second) }()
+	commit("a")
+	if err := <-done; err != nil {
+		t.Fatalf("Waiting for Block 0 should succeed, got %v", err)
+	}
+	if w, ok := node.TxWatermark(TxHash([]byte("a"))); !ok || w != 0 {
+		t.Fatalf("Transaction a should be committed at watermark 0, got %d, %v", w, ok)
+	}
+
+	commit("b")
+	if w := node.CommittedWatermark(); w != 1 {
+		t.Fatalf("Watermark should be 1, not %d", w)
+	}
+	if w, _ := node.TxWatermark(TxHash([]byte("b"))); w != 1 {
+		t.Fatalf("Transaction b should be committed at watermark 1, not %d", w)
+	}
+	if node.Status().CommittedWatermark != 1 {
+		t.Fatalf("Status should report watermark 1")
+	}
+
+	close(node.shutdownCh)
+	if err := node.WaitWatermark(5, time.Second); err != ErrShuttingDown {
+		t.Fatalf("WaitWatermark should fail after shutdown, got %v", err)
+	}
+}
@@ -0,0 +1,303 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/babbleio/babble/hashgraph"
+)
+
+//gRPC status codes
+const (
+	grpcOK            = 0
+	grpcUnknown       = 2
+	grpcUnimplemented = 12
+)
+
+var errNoApp = errors.New("No App connected to the gRPC proxy")
+
+//GrpcAppProxy serves the Babble service of babble.proto over HTTP/2 without
+//TLS, the gRPC equivalent of SocketAppProxy. The App calls the node instead of
+//exposing a server of its own: the node sends its requests on the stream that
+//the App opens with Connect.
+type GrpcAppProxy struct {
+	listener   net.Listener
+	server     *http.Server
+	submitCh   chan []byte
+	metadataCh chan []byte
+
+	lock   sync.Mutex
+	stream *appStream //nil until the App connects
+	nextID uint64
+
+	logger *logrus.Logger
+}
+
+//appStream is the Connect stream of an App
+type appStream struct {
+	w         io.Writer
+	flusher   http.Flusher
+	writeLock sync.Mutex
+	pending   map[uint64]chan appResponse //guarded by the lock of the proxy
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewGrpcAppProxy(bindAddr string, logger *logrus.Logger) (*GrpcAppProxy, error) {
+	if logger == nil {
+		logger = logrus.New()
+		logger.Level = logrus.DebugLevel
+	}
+
+	listener, err := net.Listen("tcp", bindAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	proxy := &GrpcAppProxy{
+		listener:   listener,
+		submitCh:   make(chan []byte),
+		metadataCh: make(chan []byte),
+		logger:     logger,
+	}
+	proxy.server = &http.Server{Handler: proxy}
+	proxy.server.Protocols = new(http.Protocols)
+	proxy.server.Protocols.SetUnencryptedHTTP2(true)
+	go func() {
+		err := proxy.server.Serve(listener)
+		if err != nil && err != http.ErrServerClosed {
+			logger.WithField("error", err).Error("gRPC proxy failed")
+		}
+	}()
+
+	return proxy, nil
+}
+
+//Addr returns the address the proxy listens on
+func (p *GrpcAppProxy) Addr() string {
+	return p.listener.Addr().String()
+}
+
+func (p *GrpcAppProxy) Close() error {
+	return p.server.Close()
+}
+
+//++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+//Implement AppProxy Interface
+
+func (p *GrpcAppProxy) SubmitCh() chan []byte {
+	return p.submitCh
+}
+
+func (p *GrpcAppProxy) MetadataCh() chan []byte {
+	return p.metadataCh
+}
+
+func (p *GrpcAppProxy) InitChain(appState []byte) error {
+	_, err := p.request(appRequest{kind: requestInitChain, data: appState})
+	return err
+}
+
+func (p *GrpcAppProxy) CommitBlock(block hashgraph.Block) ([]byte, error) {
+	return p.request(appRequest{kind: requestCommitBlock, block: block})
+}
+
+func (p *GrpcAppProxy) GetSnapshot(blockIndex int) ([]byte, error) {
+	return p.request(appRequest{kind: requestGetSnapshot, blockIndex: blockIndex})
+}
+
+func (p *GrpcAppProxy) Restore(snapshot []byte) error {
+	_, err := p.request(appRequest{kind: requestRestore, data: snapshot})
+	return err
+}
+
+//++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
+
+//request sends req to the App and waits for its response
+func (p *GrpcAppProxy) request(req appRequest) ([]byte, error) {
+	p.lock.Lock()
+	s := p.stream
+	if s == nil {
+		p.lock.Unlock()
+		return nil, errNoApp
+	}
+	p.nextID++
+	req.id = p.nextID
+	respCh := make(chan appResponse, 1)
+	s.pending[req.id] = respCh
+	p.lock.Unlock()
+
+	if err := s.send(req.marshal()); err != nil {
+		p.lock.Lock()
+		delete(s.pending, req.id)
+		p.lock.Unlock()
+		return nil, err
+	}
+
+	select {
+	case resp := <-respCh:
+		if resp.err != "" {
+			return nil, errors.New(resp.err)
+		}
+		return resp.result, nil
+	case <-s.done:
+		return nil, fmt.Errorf("App disconnected")
+	}
+}
+
+func (p *GrpcAppProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" || r.Header.Get("Content-Type") != "application/grpc" && r.Header.Get("Content-Type") != "application/grpc+proto" {
+		http.Error(w, "Only gRPC requests are served", http.StatusUnsupportedMediaType)
+		return
+	}
+	w.Header().Set("Content-Type", "application/grpc")
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+
+	code, err := grpcOK, error(nil)
+	switch r.URL.Path {
+	case "/babble.Babble/SubmitTx":
+		err = p.submitTxs(w, r, p.submitCh)
+	case "/babble.Babble/SubmitMetadata":
+		err = p.submitTxs(w, r, p.metadataCh)
+	case "/babble.Babble/Connect":
+		err = p.connect(w, r)
+	default:
+		code, err = grpcUnimplemented, fmt.Errorf("Unknown method %s", r.URL.Path)
+	}
+	if err != nil && code == grpcOK {
+		code = grpcUnknown
+	}
+	if err != nil {
+		p.logger.WithFields(logrus.Fields{
+			"method": r.URL.Path,
+			"error":  err,
+		}).Debug("gRPC proxy")
+		w.Header().Set("Grpc-Message", err.Error())
+	}
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+}
+
+//submitTxs passes the Txs of a SubmitTx or SubmitMetadata stream to the node
+//on ch, and answers Empty when the App closes the stream
+func (p *GrpcAppProxy) submitTxs(w http.ResponseWriter, r *http.Request, ch chan []byte) error {
+	for {
+		msg, err := readFrame(r.Body)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		tx, err := unmarshalTx(msg)
+		if err != nil {
+			return err
+		}
+		select {
+		case ch <- tx:
+		case <-r.Context().Done():
+			return r.Context().Err()
+		}
+	}
+	return writeFrame(w, nil)
+}
+
+//connect makes the stream of the request the one the node sends its requests
+//on, until the App closes it or connects again
+func (p *GrpcAppProxy) connect(w http.ResponseWriter, r *http.Request) error {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return fmt.Errorf("Streaming is not supported")
+	}
+	s := &appStream{
+		w:       w,
+		flusher: flusher,
+		pending: make(map[uint64]chan appResponse),
+		done:    make(chan struct{}),
+	}
+
+	p.lock.Lock()
+	old := p.stream
+	p.stream = s
+	p.lock.Unlock()
+	if old != nil {
+		old.close()
+	}
+	defer func() {
+		p.lock.Lock()
+		if p.stream == s {
+			p.stream = nil
+		}
+		p.lock.Unlock()
+		s.close()
+		//wait for a write in progress, the stream can not be written once the
+		//handler returned
+		s.writeLock.Lock()
+		s.writeLock.Unlock()
+	}()
+
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	p.logger.WithField("from", r.RemoteAddr).Info("App connected to the gRPC proxy")
+
+	errCh := make(chan error, 1)
+	go func() {
+		for {
+			msg, err := readFrame(r.Body)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			resp, err := unmarshalAppResponse(msg)
+			if err != nil {
+				errCh <- err
+				return
+			}
+			p.lock.Lock()
+			respCh, ok := s.pending[resp.id]
+			delete(s.pending, resp.id)
+			p.lock.Unlock()
+			if ok {
+				respCh <- resp
+			}
+		}
+	}()
+
+	select {
+	case err := <-errCh:
+		if err == io.EOF {
+			return nil
+		}
+		return err
+	case <-s.done:
+		return fmt.Errorf("Replaced by a new Connect stream")
+	case <-r.Context().Done():
+		return nil
+	}
+}
+
+func (s *appStream) send(msg []byte) error {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	select {
+	case <-s.done:
+		return fmt.Errorf("App disconnected")
+	default:
+	}
+	if err := writeFrame(s.w, msg); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *appStream) close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
@@ -0,0 +1,133 @@
+package app
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/babbleio/babble/codec"
+	"github.com/babbleio/babble/hashgraph"
+)
+
+/*
+The messages of babble.proto are encoded by hand, like the gRPC framing, so
+that the gRPC proxy does not depend on the protobuf and gRPC libraries. They
+are written and read with the protobuf helpers of the codec package.
+*/
+
+//largest gRPC message accepted
+const maxGrpcMessage = 64 * 1024 * 1024
+
+//------------------------------------------------------------------------------
+
+//AppRequest.request
+const (
+	requestInitChain   = 2
+	requestCommitBlock = 3
+	requestGetSnapshot = 4
+	requestRestore     = 5
+)
+
+type appRequest struct {
+	id         uint64
+	kind       int //one of the request* field numbers
+	data       []byte
+	block      hashgraph.Block
+	blockIndex int
+}
+
+func (r appRequest) marshal() []byte {
+	b := codec.AppendVarint(nil, 1, r.id)
+	switch r.kind {
+	case requestCommitBlock:
+		b = codec.AppendBytes(b, requestCommitBlock, marshalBlock(r.block))
+	case requestGetSnapshot:
+		//a member of a oneof is written even if it is zero
+		b = codec.AppendOneofVarint(b, requestGetSnapshot, uint64(r.blockIndex))
+	default:
+		b = codec.AppendBytes(b, r.kind, r.data)
+	}
+	return b
+}
+
+func marshalBlock(block hashgraph.Block) []byte {
+	var b []byte
+	b = codec.AppendVarint(b, 1, uint64(block.Index))
+	b = codec.AppendVarint(b, 2, uint64(block.RoundReceived))
+	for _, tx := range block.Transactions {
+		b = codec.AppendBytes(b, 3, tx)
+	}
+	for _, m := range block.Metadata {
+		mb := codec.AppendBytes(nil, 1, []byte(m.Creator))
+		mb = codec.AppendBytes(mb, 2, m.Data)
+		b = codec.AppendBytes(b, 4, mb)
+	}
+	return b
+}
+
+type appResponse struct {
+	id     uint64
+	result []byte
+	err    string
+}
+
+func unmarshalAppResponse(msg []byte) (appResponse, error) {
+	var r appResponse
+	err := codec.ReadFields(msg, func(f codec.ProtoField) error {
+		switch {
+		case f.Number == 1 && f.Type == codec.WireVarint:
+			r.id = f.Varint
+		case f.Number == 2 && f.Type == codec.WireBytes:
+			r.result = f.Copy()
+		case f.Number == 3 && f.Type == codec.WireBytes:
+			r.err = f.String()
+		}
+		return nil
+	})
+	return r, err
+}
+
+//unmarshalTx returns the data of a Tx
+func unmarshalTx(msg []byte) ([]byte, error) {
+	tx := []byte{}
+	err := codec.ReadFields(msg, func(f codec.ProtoField) error {
+		if f.Number == 1 && f.Type == codec.WireBytes {
+			tx = f.Copy()
+		}
+		return nil
+	})
+	return tx, err
+}
+
+//------------------------------------------------------------------------------
+
+//readFrame reads a length-prefixed gRPC message
+func readFrame(r io.Reader) ([]byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+	if header[0] != 0 {
+		return nil, errors.New("Compressed gRPC messages are not supported")
+	}
+	size := binary.BigEndian.Uint32(header[1:])
+	if size > maxGrpcMessage {
+		return nil, fmt.Errorf("gRPC message of %d bytes is too large", size)
+	}
+	msg := make([]byte, size)
+	if _, err := io.ReadFull(r, msg); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return msg, nil
+}
+
+func writeFrame(w io.Writer, msg []byte) error {
+	frame := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:], uint32(len(msg)))
+	_, err := w.Write(append(frame, msg...))
+	return err
+}
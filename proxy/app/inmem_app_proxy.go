@@ -1,11 +1,21 @@
 package app
 
-import "github.com/Sirupsen/logrus"
+import (
+	"fmt"
+
+	"github.com/Sirupsen/logrus"
+	"github.com/babbleio/babble/crypto"
+	"github.com/babbleio/babble/hashgraph"
+)
 
 //InmemProxy is used for testing
 type InmemAppProxy struct {
 	submitCh    chan []byte
+	metadataCh  chan []byte
 	commitedTxs [][]byte
+	metadata    []hashgraph.BlockMetadata
+	stateHash   []byte
+	snapshots   map[int][]byte //[block index] => state hash
 	logger      *logrus.Logger
 }
 
@@ -16,7 +26,10 @@ func NewInmemAppProxy(logger *logrus.Logger) *InmemAppProxy {
 	}
 	return &InmemAppProxy{
 		submitCh:    make(chan []byte),
+		metadataCh:  make(chan []byte),
 		commitedTxs: [][]byte{},
+		stateHash:   []byte{},
+		snapshots:   make(map[int][]byte),
 		logger:      logger,
 	}
 }
@@ -25,9 +38,48 @@ func (p *InmemAppProxy) SubmitCh() chan []byte {
 	return p.submitCh
 }
 
-func (p *InmemAppProxy) CommitTx(tx []byte) error {
-	p.logger.WithField("tx", tx).Debug("InmemProxy CommitTx")
-	p.commitedTxs = append(p.commitedTxs, tx)
+func (p *InmemAppProxy) MetadataCh() chan []byte {
+	return p.metadataCh
+}
+
+//InitChain starts the chain of state hashes from the hash of the genesis state
+func (p *InmemAppProxy) InitChain(appState []byte) error {
+	p.stateHash = crypto.SHA256(appState)
+	p.logger.WithField("state_hash", fmt.Sprintf("%X", p.stateHash)).Debug("InmemProxy InitChain")
+	return nil
+}
+
+//CommitBlock chains the hashes of the committed transactions into the state
+//hash
+func (p *InmemAppProxy) CommitBlock(block hashgraph.Block) ([]byte, error) {
+	p.logger.WithFields(logrus.Fields{
+		"index":          block.Index,
+		"round_received": block.RoundReceived,
+		"txs":            len(block.Transactions),
+		"metadata":       len(block.Metadata),
+	}).Debug("InmemProxy CommitBlock")
+	for _, tx := range block.Transactions {
+		p.commitedTxs = append(p.commitedTxs, tx)
+		p.stateHash = crypto.SHA256(append(p.stateHash, tx...))
+	}
+	p.metadata = append(p.metadata, block.Metadata...)
+	p.snapshots[block.Index] = p.stateHash
+	return p.stateHash, nil
+}
+
+//GetSnapshot returns the state hash after the Block, which is all the state
+//of the InmemAppProxy
+func (p *InmemAppProxy) GetSnapshot(blockIndex int) ([]byte, error) {
+	snapshot, ok := p.snapshots[blockIndex]
+	if !ok {
+		return nil, fmt.Errorf("Snapshot %d not found", blockIndex)
+	}
+	return snapshot, nil
+}
+
+func (p *InmemAppProxy) Restore(snapshot []byte) error {
+	p.logger.WithField("state_hash", fmt.Sprintf("%X", snapshot)).Debug("InmemProxy Restore")
+	p.stateHash = snapshot
 	return nil
 }
 
@@ -38,6 +90,15 @@ func (p *InmemAppProxy) SubmitTx(tx []byte) {
 	p.submitCh <- tx
 }
 
+func (p *InmemAppProxy) SubmitMetadata(data []byte) {
+	p.metadataCh <- data
+}
+
 func (p *InmemAppProxy) GetCommittedTransactions() [][]byte {
 	return p.commitedTxs
 }
+
+//GetCommittedMetadata returns the Metadata of the committed Blocks
+func (p *InmemAppProxy) GetCommittedMetadata() []hashgraph.BlockMetadata {
+	return p.metadata
+}
@@ -1,11 +1,11 @@
 package app
 
 import (
-	"time"
-
 	"fmt"
+	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/babbleio/babble/hashgraph"
 )
 
 type SocketAppProxy struct {
@@ -46,13 +46,36 @@ func (p *SocketAppProxy) SubmitCh() chan []byte {
 	return p.server.submitCh
 }
 
-func (p *SocketAppProxy) CommitTx(tx []byte) error {
-	ack, err := p.client.CommitTx(tx)
+func (p *SocketAppProxy) MetadataCh() chan []byte {
+	return p.server.metadataCh
+}
+
+func (p *SocketAppProxy) CommitBlock(block hashgraph.Block) ([]byte, error) {
+	return p.client.CommitBlock(block)
+}
+
+func (p *SocketAppProxy) GetSnapshot(blockIndex int) ([]byte, error) {
+	return p.client.GetSnapshot(blockIndex)
+}
+
+func (p *SocketAppProxy) Restore(snapshot []byte) error {
+	ack, err := p.client.Restore(snapshot)
+	if err != nil {
+		return err
+	}
+	if !*ack {
+		return fmt.Errorf("App returned false to Restore")
+	}
+	return nil
+}
+
+func (p *SocketAppProxy) InitChain(appState []byte) error {
+	ack, err := p.client.InitChain(appState)
 	if err != nil {
 		return err
 	}
 	if !*ack {
-		return fmt.Errorf("App returned false to CommitTx")
+		return fmt.Errorf("App returned false to InitChain")
 	}
 	return nil
 }
@@ -1,12 +1,13 @@
 package app
 
 import (
-	"net"
 	"net/rpc"
 	"net/rpc/jsonrpc"
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/hashgraph"
 )
 
 type SocketAppProxyClient struct {
@@ -24,20 +25,63 @@ func NewSocketAppProxyClient(clientAddr string, timeout time.Duration, logger *l
 }
 
 func (p *SocketAppProxyClient) getConnection() (*rpc.Client, error) {
-	conn, err := net.DialTimeout("tcp", p.clientAddr, p.timeout)
+	conn, err := common.DialSocket(p.clientAddr, p.timeout)
 	if err != nil {
 		return nil, err
 	}
 	return jsonrpc.NewClient(conn), nil
 }
 
-func (p *SocketAppProxyClient) CommitTx(tx []byte) (*bool, error) {
+func (p *SocketAppProxyClient) CommitBlock(block hashgraph.Block) ([]byte, error) {
 	rpcConn, err := p.getConnection()
 	if err != nil {
 		return nil, err
 	}
+	defer rpcConn.Close()
+	var stateHash []byte
+	err = rpcConn.Call("State.CommitBlock", block, &stateHash)
+	if err != nil {
+		return nil, err
+	}
+	return stateHash, nil
+}
+
+func (p *SocketAppProxyClient) GetSnapshot(blockIndex int) ([]byte, error) {
+	rpcConn, err := p.getConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer rpcConn.Close()
+	var snapshot []byte
+	err = rpcConn.Call("State.GetSnapshot", blockIndex, &snapshot)
+	if err != nil {
+		return nil, err
+	}
+	return snapshot, nil
+}
+
+func (p *SocketAppProxyClient) Restore(snapshot []byte) (*bool, error) {
+	rpcConn, err := p.getConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer rpcConn.Close()
+	var ack bool
+	err = rpcConn.Call("State.Restore", snapshot, &ack)
+	if err != nil {
+		return nil, err
+	}
+	return &ack, nil
+}
+
+func (p *SocketAppProxyClient) InitChain(appState []byte) (*bool, error) {
+	rpcConn, err := p.getConnection()
+	if err != nil {
+		return nil, err
+	}
+	defer rpcConn.Close()
 	var ack bool
-	err = rpcConn.Call("State.CommitTx", tx, &ack)
+	err = rpcConn.Call("State.InitChain", appState, &ack)
 	if err != nil {
 		return nil, err
 	}
@@ -6,19 +6,22 @@ import (
 	"net/rpc/jsonrpc"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/babbleio/babble/common"
 )
 
 type SocketAppProxyServer struct {
 	netListener *net.Listener
 	rpcServer   *rpc.Server
 	submitCh    chan []byte
+	metadataCh  chan []byte
 	logger      *logrus.Logger
 }
 
 func NewSocketAppProxyServer(bindAddress string, logger *logrus.Logger) *SocketAppProxyServer {
 	server := &SocketAppProxyServer{
-		submitCh: make(chan []byte),
-		logger:   logger,
+		submitCh:   make(chan []byte),
+		metadataCh: make(chan []byte),
+		logger:     logger,
 	}
 	server.register(bindAddress)
 	return server
@@ -29,7 +32,7 @@ func (p *SocketAppProxyServer) register(bindAddress string) {
 	rpcServer.RegisterName("Babble", p)
 	p.rpcServer = rpcServer
 
-	l, err := net.Listen("tcp", bindAddress)
+	l, err := common.ListenSocket(bindAddress)
 	if err != nil {
 		p.logger.WithField("error", err).Error("Failed to listen")
 	}
@@ -53,3 +56,10 @@ func (p *SocketAppProxyServer) SubmitTx(tx []byte, ack *bool) error {
 	*ack = true
 	return nil
 }
+
+func (p *SocketAppProxyServer) SubmitMetadata(data []byte, ack *bool) error {
+	p.logger.Debug("SubmitMetadata")
+	p.metadataCh <- data
+	*ack = true
+	return nil
+}
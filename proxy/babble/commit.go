@@ -0,0 +1,22 @@
+package babble
+
+import "github.com/babbleio/babble/hashgraph"
+
+//Commit is a Block sent by Babble to the App, which must call Respond once it
+//has committed the Block
+type Commit struct {
+	Block    hashgraph.Block
+	RespChan chan<- CommitResponse
+}
+
+//CommitResponse is what the App returns to Babble after committing a Block
+type CommitResponse struct {
+	StateHash []byte
+	Error     error
+}
+
+//Respond sends the hash of the state of the App after the Block, or the error
+//that prevented committing it, back to Babble
+func (c Commit) Respond(stateHash []byte, err error) {
+	c.RespChan <- CommitResponse{StateHash: stateHash, Error: err}
+}
@@ -0,0 +1,12 @@
+package babble
+
+//InitChainRequest delivers the genesis state to the App, before any Commit.
+//The App must call Respond.
+type InitChainRequest struct {
+	AppState []byte
+	RespChan chan<- error
+}
+
+func (r InitChainRequest) Respond(err error) {
+	r.RespChan <- err
+}
@@ -0,0 +1,28 @@
+package babble
+
+//SnapshotRequest asks the App for its state after a Block, to help another
+//node catch up. The App must call Respond.
+type SnapshotRequest struct {
+	BlockIndex int
+	RespChan   chan<- SnapshotResponse
+}
+
+type SnapshotResponse struct {
+	Snapshot []byte
+	Error    error
+}
+
+func (r SnapshotRequest) Respond(snapshot []byte, err error) {
+	r.RespChan <- SnapshotResponse{Snapshot: snapshot, Error: err}
+}
+
+//RestoreRequest asks the App to replace its state with a snapshot taken by
+//another node. The App must call Respond.
+type RestoreRequest struct {
+	Snapshot []byte
+	RespChan chan<- error
+}
+
+func (r RestoreRequest) Respond(err error) {
+	r.RespChan <- err
+}
@@ -34,10 +34,22 @@ func NewSocketBabbleProxy(nodeAddr string, bindAddr string, timeout time.Duratio
 //++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++++
 //Implement BabbleProxy interface
 
-func (p *SocketBabbleProxy) CommitCh() chan []byte {
+func (p *SocketBabbleProxy) InitChainCh() chan InitChainRequest {
+	return p.server.initChainCh
+}
+
+func (p *SocketBabbleProxy) CommitCh() chan Commit {
 	return p.server.commitCh
 }
 
+func (p *SocketBabbleProxy) SnapshotRequestCh() chan SnapshotRequest {
+	return p.server.snapshotRequestCh
+}
+
+func (p *SocketBabbleProxy) RestoreCh() chan RestoreRequest {
+	return p.server.restoreCh
+}
+
 func (p *SocketBabbleProxy) SubmitTx(tx []byte) error {
 	ack, err := p.client.SubmitTx(tx)
 	if err != nil {
@@ -48,3 +60,14 @@ func (p *SocketBabbleProxy) SubmitTx(tx []byte) error {
 	}
 	return nil
 }
+
+func (p *SocketBabbleProxy) SubmitMetadata(data []byte) error {
+	ack, err := p.client.SubmitMetadata(data)
+	if err != nil {
+		return err
+	}
+	if !*ack {
+		return fmt.Errorf("Failed to deliver block metadata to Babble")
+	}
+	return nil
+}
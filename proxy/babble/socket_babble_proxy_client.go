@@ -16,10 +16,11 @@ limitations under the License.
 package babble
 
 import (
-	"net"
 	"net/rpc"
 	"net/rpc/jsonrpc"
 	"time"
+
+	"github.com/babbleio/babble/common"
 )
 
 type SocketBabbleProxyClient struct {
@@ -35,7 +36,7 @@ func NewSocketBabbleProxyClient(nodeAddr string, timeout time.Duration) *SocketB
 }
 
 func (p *SocketBabbleProxyClient) getConnection() (*rpc.Client, error) {
-	conn, err := net.DialTimeout("tcp", p.nodeAddr, p.timeout)
+	conn, err := common.DialSocket(p.nodeAddr, p.timeout)
 	if err != nil {
 		return nil, err
 	}
@@ -54,3 +55,16 @@ func (p *SocketBabbleProxyClient) SubmitTx(tx []byte) (*bool, error) {
 	}
 	return &ack, nil
 }
+
+func (p *SocketBabbleProxyClient) SubmitMetadata(data []byte) (*bool, error) {
+	rpcConn, err := p.getConnection()
+	if err != nil {
+		return nil, err
+	}
+	var ack bool
+	err = rpcConn.Call("Babble.SubmitMetadata", data, &ack)
+	if err != nil {
+		return nil, err
+	}
+	return &ack, nil
+}
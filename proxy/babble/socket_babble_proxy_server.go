@@ -4,17 +4,26 @@ import (
 	"net"
 	"net/rpc"
 	"net/rpc/jsonrpc"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/hashgraph"
 )
 
 type SocketBabbleProxyServer struct {
-	netListener *net.Listener
-	rpcServer   *rpc.Server
-	commitCh    chan []byte
+	netListener       *net.Listener
+	rpcServer         *rpc.Server
+	initChainCh       chan InitChainRequest
+	commitCh          chan Commit
+	snapshotRequestCh chan SnapshotRequest
+	restoreCh         chan RestoreRequest
 }
 
 func NewSocketBabbleProxyServer(bindAddress string) (*SocketBabbleProxyServer, error) {
 	server := &SocketBabbleProxyServer{
-		commitCh: make(chan []byte),
+		initChainCh:       make(chan InitChainRequest),
+		commitCh:          make(chan Commit),
+		snapshotRequestCh: make(chan SnapshotRequest),
+		restoreCh:         make(chan RestoreRequest),
 	}
 
 	if err := server.register(bindAddress); err != nil {
@@ -29,7 +38,7 @@ func (p *SocketBabbleProxyServer) register(bindAddress string) error {
 	rpcServer.RegisterName("State", p)
 	p.rpcServer = rpcServer
 
-	l, err := net.Listen("tcp", bindAddress)
+	l, err := common.ListenSocket(bindAddress)
 	if err != nil {
 		return err
 	}
@@ -51,8 +60,48 @@ func (p *SocketBabbleProxyServer) listen() error {
 	return nil
 }
 
-func (p *SocketBabbleProxyServer) CommitTx(tx []byte, ack *bool) error {
-	p.commitCh <- tx
+//CommitBlock passes the Block to the App and waits for it to be committed
+func (p *SocketBabbleProxyServer) CommitBlock(block hashgraph.Block, stateHash *[]byte) error {
+	respCh := make(chan CommitResponse)
+	p.commitCh <- Commit{Block: block, RespChan: respCh}
+	resp := <-respCh
+	if resp.Error != nil {
+		return resp.Error
+	}
+	*stateHash = resp.StateHash
+	return nil
+}
+
+//GetSnapshot passes the request to the App and waits for the snapshot
+func (p *SocketBabbleProxyServer) GetSnapshot(blockIndex int, snapshot *[]byte) error {
+	respCh := make(chan SnapshotResponse)
+	p.snapshotRequestCh <- SnapshotRequest{BlockIndex: blockIndex, RespChan: respCh}
+	resp := <-respCh
+	if resp.Error != nil {
+		return resp.Error
+	}
+	*snapshot = resp.Snapshot
+	return nil
+}
+
+//Restore passes the snapshot to the App and waits for it to be restored
+func (p *SocketBabbleProxyServer) Restore(snapshot []byte, ack *bool) error {
+	respCh := make(chan error)
+	p.restoreCh <- RestoreRequest{Snapshot: snapshot, RespChan: respCh}
+	if err := <-respCh; err != nil {
+		return err
+	}
+	*ack = true
+	return nil
+}
+
+//InitChain passes the genesis state to the App and waits for it to be applied
+func (p *SocketBabbleProxyServer) InitChain(appState []byte, ack *bool) error {
+	respCh := make(chan error)
+	p.initChainCh <- InitChainRequest{AppState: appState, RespChan: respCh}
+	if err := <-respCh; err != nil {
+		return err
+	}
 	*ack = true
 	return nil
 }
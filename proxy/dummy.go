@@ -7,16 +7,47 @@ import (
 	"time"
 
 	"github.com/Sirupsen/logrus"
+	"github.com/babbleio/babble/crypto"
+	"github.com/babbleio/babble/hashgraph"
 	bproxy "github.com/babbleio/babble/proxy/babble"
 )
 
 type State struct {
-	logger *logrus.Logger
+	stateHash []byte
+	snapshots map[int][]byte //[block index] => state hash
+	logger    *logrus.Logger
 }
 
-func (a *State) CommitTx(tx []byte) error {
-	a.logger.WithField("Tx", string(tx)).Debug("CommitTx")
-	a.writeMessage(tx)
+//InitChain starts the chain of state hashes from the hash of the genesis state
+func (a *State) InitChain(appState []byte) error {
+	a.stateHash = crypto.SHA256(appState)
+	a.logger.WithField("state_hash", fmt.Sprintf("%X", a.stateHash)).Debug("InitChain")
+	return nil
+}
+
+func (a *State) CommitBlock(block hashgraph.Block) ([]byte, error) {
+	a.logger.WithField("index", block.Index).Debug("CommitBlock")
+	for _, tx := range block.Transactions {
+		a.writeMessage(tx)
+		a.stateHash = crypto.SHA256(append(a.stateHash, tx...))
+	}
+	a.snapshots[block.Index] = a.stateHash
+	return a.stateHash, nil
+}
+
+//GetSnapshot returns the state hash after a Block. The messages file is not
+//part of the snapshot.
+func (a *State) GetSnapshot(blockIndex int) ([]byte, error) {
+	snapshot, ok := a.snapshots[blockIndex]
+	if !ok {
+		return nil, fmt.Errorf("Snapshot %d not found", blockIndex)
+	}
+	return snapshot, nil
+}
+
+func (a *State) Restore(snapshot []byte) error {
+	a.logger.WithField("state_hash", fmt.Sprintf("%X", snapshot)).Debug("Restore")
+	a.stateHash = snapshot
 	return nil
 }
 
@@ -59,7 +90,10 @@ func NewDummySocketClient(clientAddr string, nodeAddr string, logger *logrus.Log
 		return nil, err
 	}
 
-	state := State{logger: logger}
+	state := State{
+		snapshots: make(map[int][]byte),
+		logger:    logger,
+	}
 	state.writeMessage([]byte(clientAddr))
 
 	client := &DummySocketClient{
@@ -76,9 +110,15 @@ func NewDummySocketClient(clientAddr string, nodeAddr string, logger *logrus.Log
 func (c *DummySocketClient) Run() {
 	for {
 		select {
-		case tx := <-c.babbleProxy.CommitCh():
-			c.logger.Debug("CommitTx")
-			c.state.CommitTx(tx)
+		case req := <-c.babbleProxy.InitChainCh():
+			req.Respond(c.state.InitChain(req.AppState))
+		case commit := <-c.babbleProxy.CommitCh():
+			c.logger.Debug("CommitBlock")
+			commit.Respond(c.state.CommitBlock(commit.Block))
+		case req := <-c.babbleProxy.SnapshotRequestCh():
+			req.Respond(c.state.GetSnapshot(req.BlockIndex))
+		case req := <-c.babbleProxy.RestoreCh():
+			req.Respond(c.state.Restore(req.Snapshot))
 		}
 	}
 }
@@ -0,0 +1,163 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/hashgraph"
+	aproxy "github.com/babbleio/babble/proxy/app"
+)
+
+//grpcClient plays the App with hand-encoded messages, like a client generated
+//from babble.proto would
+type grpcClient struct {
+	addr   string
+	client *http.Client
+}
+
+func newGrpcClient(addr string) *grpcClient {
+	transport := &http.Transport{Protocols: new(http.Protocols)}
+	transport.Protocols.SetUnencryptedHTTP2(true)
+	return &grpcClient{addr: addr, client: &http.Client{Transport: transport}}
+}
+
+func (c *grpcClient) call(method string, body io.Reader) (*http.Response, error) {
+	req, err := http.NewRequest("POST", "http://"+c.addr+"/babble.Babble/"+method, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/grpc")
+	return c.client.Do(req)
+}
+
+func frame(msg []byte) []byte {
+	f := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(f[1:], uint32(len(msg)))
+	return append(f, msg...)
+}
+
+func readFrame(t *testing.T, r io.Reader) []byte {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		t.Fatal(err)
+	}
+	msg := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(r, msg); err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+//bytesField encodes a length-delimited protobuf field shorter than 128 bytes
+func bytesField(field int, data []byte) []byte {
+	return append([]byte{byte(field<<3 | 2), byte(len(data))}, data...)
+}
+
+func TestGrpcAppProxy(t *testing.T) {
+	proxy, err := aproxy.NewGrpcAppProxy("127.0.0.1:0", common.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer proxy.Close()
+	client := newGrpcClient(proxy.Addr())
+
+	if _, err := proxy.CommitBlock(hashgraph.NewBlock(0, 1, nil)); err == nil {
+		t.Fatal("CommitBlock should fail before the App connects")
+	}
+
+	//SubmitTx
+	go func() {
+		resp, err := client.call("SubmitTx", bytes.NewReader(frame(bytesField(1, []byte("the test transaction")))))
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}()
+	select {
+	case tx := <-proxy.SubmitCh():
+		if string(tx) != "the test transaction" {
+			t.Fatalf("Submitted transaction should be %q, not %q", "the test transaction", tx)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for the transaction")
+	}
+
+	//SubmitMetadata
+	go func() {
+		resp, err := client.call("SubmitMetadata", bytes.NewReader(frame(bytesField(1, []byte("oracle")))))
+		if err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+	}()
+	select {
+	case data := <-proxy.MetadataCh():
+		if string(data) != "oracle" {
+			t.Fatalf("Submitted metadata should be %q, not %q", "oracle", data)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for the metadata")
+	}
+
+	//Connect: the App answers a CommitBlock with its state hash
+	reqBody, appWriter := io.Pipe()
+	defer appWriter.Close()
+	resp, err := client.call("Connect", reqBody)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	block := hashgraph.NewBlock(0, 1, [][]byte{[]byte("tx")})
+	hashCh := make(chan []byte)
+	errCh := make(chan error)
+	go func() {
+		stateHash, err := proxy.CommitBlock(block)
+		if err != nil {
+			errCh <- err
+			return
+		}
+		hashCh <- stateHash
+	}()
+
+	//id 1, commit_block {round_received 1, transactions "tx"}
+	request := readFrame(t, resp.Body)
+	expected := append([]byte{1<<3 | 0, 1}, bytesField(3, []byte{2<<3 | 0, 1, 3<<3 | 2, 2, 't', 'x'})...)
+	if !reflect.DeepEqual(request, expected) {
+		t.Fatalf("AppRequest should be %v, not %v", expected, request)
+	}
+	response := append([]byte{1<<3 | 0, 1}, bytesField(2, []byte("the state hash"))...)
+	if _, err := appWriter.Write(frame(response)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case stateHash := <-hashCh:
+		if string(stateHash) != "the state hash" {
+			t.Fatalf("State hash should be %q, not %q", "the state hash", stateHash)
+		}
+	case err := <-errCh:
+		t.Fatal(err)
+	case <-time.After(time.Second):
+		t.Fatal("Timeout waiting for the state hash")
+	}
+
+	//errors of the App are returned to the node
+	go func() {
+		errCh <- proxy.Restore([]byte("snapshot"))
+	}()
+	readFrame(t, resp.Body)
+	response = append([]byte{1<<3 | 0, 2}, bytesField(3, []byte("bad snapshot"))...)
+	if _, err := appWriter.Write(frame(response)); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-errCh; err == nil || err.Error() != "bad snapshot" {
+		t.Fatalf("Restore should return the error of the App, got %v", err)
+	}
+}
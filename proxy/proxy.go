@@ -1,11 +1,35 @@
 package proxy
 
+import (
+	"github.com/babbleio/babble/hashgraph"
+	bproxy "github.com/babbleio/babble/proxy/babble"
+)
+
 type AppProxy interface {
 	SubmitCh() chan []byte
-	CommitTx(tx []byte) error
+	//MetadataCh receives the opaque metadata that the App attaches to the next
+	//Event of the node. It is delivered to every App in the Metadata of the
+	//Block the Event is committed in.
+	MetadataCh() chan []byte
+	//InitChain delivers the genesis state of the App, on the first start of
+	//the node and before any Block
+	InitChain(appState []byte) error
+	//CommitBlock commits the transactions of a Block and returns the hash of
+	//the resulting state of the App
+	CommitBlock(block hashgraph.Block) ([]byte, error)
+	//GetSnapshot returns the state of the App after the Block blockIndex. The
+	//App must keep the snapshots of its recent Blocks.
+	GetSnapshot(blockIndex int) ([]byte, error)
+	//Restore replaces the state of the App with a snapshot returned by the
+	//GetSnapshot of another node
+	Restore(snapshot []byte) error
 }
 
 type BabbleProxy interface {
-	CommitCh() chan []byte
+	InitChainCh() chan bproxy.InitChainRequest
+	CommitCh() chan bproxy.Commit
+	SnapshotRequestCh() chan bproxy.SnapshotRequest
+	RestoreCh() chan bproxy.RestoreRequest
 	SubmitTx(tx []byte) error
+	SubmitMetadata(data []byte) error
 }
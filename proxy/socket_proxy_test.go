@@ -6,6 +6,8 @@ import (
 	"time"
 
 	"github.com/babbleio/babble/common"
+	"github.com/babbleio/babble/crypto"
+	"github.com/babbleio/babble/hashgraph"
 	aproxy "github.com/babbleio/babble/proxy/app"
 )
 
@@ -53,22 +55,73 @@ func TestSocketProxyClient(t *testing.T) {
 	}
 	clientCh := dummyClient.babbleProxy.CommitCh()
 
-	tx := []byte("the test transaction")
+	block := hashgraph.NewBlock(0, 1, [][]byte{[]byte("the test transaction")})
+	expectedHash := []byte("the state hash")
 
 	// Listen for a request
 	go func() {
 		select {
-		case st := <-clientCh:
-			if !reflect.DeepEqual(st, tx) {
-				t.Fatalf("tx mismatch: %#v %#v", tx, st)
+		case commit := <-clientCh:
+			if !reflect.DeepEqual(commit.Block, block) {
+				t.Fatalf("block mismatch: %#v %#v", block, commit.Block)
 			}
+			commit.Respond(expectedHash, nil)
 		case <-time.After(200 * time.Millisecond):
 			t.Fatalf("timeout")
 		}
 	}()
 
-	err = proxy.CommitTx(tx)
+	stateHash, err := proxy.CommitBlock(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(stateHash, expectedHash) {
+		t.Fatalf("state hash mismatch: %#v %#v", expectedHash, stateHash)
+	}
+}
+
+//the state of the dummy App goes through InitChain, CommitBlock, GetSnapshot
+//and Restore
+func TestSocketProxySnapshot(t *testing.T) {
+	clientAddr := "127.0.0.1:9994"
+	proxyAddr := "127.0.0.1:9995"
+	proxy := aproxy.NewSocketAppProxy(clientAddr, proxyAddr, 1*time.Second, common.NewTestLogger(t))
+
+	dummyClient, err := NewDummySocketClient(clientAddr, proxyAddr, common.NewTestLogger(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genesis := []byte("the genesis state")
+	if err := proxy.InitChain(genesis); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dummyClient.state.stateHash, crypto.SHA256(genesis)) {
+		t.Fatalf("state hash should be the hash of the genesis state, not %#v", dummyClient.state.stateHash)
+	}
+
+	block := hashgraph.NewBlock(0, 1, [][]byte{[]byte("the test transaction")})
+	stateHash, err := proxy.CommitBlock(block)
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	snapshot, err := proxy.GetSnapshot(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(snapshot, stateHash) {
+		t.Fatalf("snapshot should be %#v, not %#v", stateHash, snapshot)
+	}
+	if _, err := proxy.GetSnapshot(1); err == nil {
+		t.Fatal("GetSnapshot of an unknown Block should fail")
+	}
+
+	restored := []byte("the restored state hash")
+	if err := proxy.Restore(restored); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(dummyClient.state.stateHash, restored) {
+		t.Fatalf("state hash should be %#v, not %#v", restored, dummyClient.state.stateHash)
+	}
 }
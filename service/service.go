@@ -1,9 +1,17 @@
 package service
 
 import (
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/babbleio/babble/client"
+	"github.com/babbleio/babble/common"
 	"github.com/babbleio/babble/node"
 	"github.com/Sirupsen/logrus"
 	"github.com/gorilla/mux"
@@ -12,6 +20,9 @@ import (
 type Service struct {
 	bindAddress string
 	node        *node.Node
+	router      *client.Router
+	maxLag      int
+	submit      bool
 	logger      *logrus.Logger
 }
 
@@ -25,10 +36,42 @@ func NewService(bindAddress string, node *node.Node, logger *logrus.Logger) *Ser
 	return &service
 }
 
+//SetRouter makes the Service redirect the transactions submitted to it to the
+//healthiest node of router when its node is not Babbling or lags more than
+//maxLag rounds behind. It must be called before Serve.
+func (s *Service) SetRouter(router *client.Router, maxLag int) {
+	s.router = router
+	s.maxLag = maxLag
+}
+
+//EnableSubmitTx makes the Service accept transactions on /SubmitTx. Anyone who
+//can reach the Service can then submit transactions, so it is off unless
+//asked for. It must be called before Serve.
+func (s *Service) EnableSubmitTx() {
+	s.submit = true
+}
+
 func (s *Service) Serve() {
 	s.logger.WithField("bind_address", s.bindAddress).Debug("Service serving")
 	r := mux.NewRouter()
 	r.HandleFunc("/Stats", s.GetStats)
+	r.HandleFunc("/Status", s.consistent(s.GetStatus)).Methods("GET")
+	r.HandleFunc("/Event/{hash}", s.consistent(s.GetEvent)).Methods("GET")
+	r.HandleFunc("/Round/{index}", s.consistent(s.GetRound)).Methods("GET")
+	r.HandleFunc("/Graph", s.GetGraph).Methods("GET")
+	r.HandleFunc("/Block/{index}", s.consistent(s.GetBlock)).Methods("GET")
+	r.HandleFunc("/Connectivity", s.GetConnectivity).Methods("GET")
+	r.HandleFunc("/Traffic", s.GetTraffic).Methods("GET")
+	r.HandleFunc("/Duties", s.GetDuties).Methods("GET")
+	r.HandleFunc("/Capabilities", s.GetCapabilities).Methods("GET")
+	r.HandleFunc("/Clone", s.GetCloneInfo).Methods("GET")
+	if s.submit {
+		r.HandleFunc("/SubmitTx", s.SubmitTx).Methods("POST")
+	}
+	r.HandleFunc("/Tx/{hash}", s.consistent(s.GetTx)).Methods("GET")
+	r.HandleFunc("/Subscribe", s.Subscribe).Methods("GET")
+	r.HandleFunc("/subscribe", s.SubscribeWebSocket).Methods("GET")
+	r.HandleFunc("/metrics", s.GetMetrics).Methods("GET")
 	http.Handle("/", &CORSServer{r})
 	err := http.ListenAndServe(s.bindAddress, nil)
 	if err != nil {
@@ -36,6 +79,31 @@ func (s *Service) Serve() {
 	}
 }
 
+//longest time a query waits for the watermark it asks for
+const maxWatermarkWait = 30 * time.Second
+
+//consistent makes a query wait until the node has committed the Block given by
+//its watermark parameter, if any, so that a client reads its writes on any node.
+//The Babble-Watermark header of the response is the committed watermark that
+//the response reflects at least.
+func (s *Service) consistent(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if param := r.URL.Query().Get("watermark"); param != "" {
+			index, err := strconv.Atoi(param)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := s.node.WaitWatermark(index, maxWatermarkWait); err != nil {
+				http.Error(w, err.Error(), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.Header().Set("Babble-Watermark", strconv.Itoa(s.node.CommittedWatermark()))
+		handler(w, r)
+	}
+}
+
 func (s *Service) GetStats(w http.ResponseWriter, r *http.Request) {
 	stats := s.node.GetStats()
 
@@ -43,6 +111,393 @@ func (s *Service) GetStats(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(stats)
 }
 
+//GetStatus returns the rounds, known Events, peers and pending transactions of
+//the node
+func (s *Service) GetStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.node.Status())
+}
+
+//GetEvent returns an Event of the hashgraph, by hash, with its round and
+//whether it reached consensus
+func (s *Service) GetEvent(w http.ResponseWriter, r *http.Request) {
+	hash := "0x" + strings.TrimPrefix(strings.ToUpper(mux.Vars(r)["hash"]), "0X")
+	info, err := s.node.EventInfo(hash)
+	if common.Is(err, common.KeyNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+//GetRound returns a round of the hashgraph with its witnesses. Archive nodes
+//have every round, the others only those above their Roots.
+func (s *Service) GetRound(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(mux.Vars(r)["index"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	info, err := s.node.RoundInfo(index)
+	if common.Is(err, common.KeyNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+//defaultGraphRounds is the number of rounds GetGraph exports without from
+const defaultGraphRounds = 10
+
+//GetGraph exports rounds of the hashgraph, by default the last ones, as JSON or
+//with format=dot for GraphViz
+func (s *Service) GetGraph(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	to := s.node.Status().LastRound
+	if param := q.Get("to"); param != "" {
+		var err error
+		if to, err = strconv.Atoi(param); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	from := to - defaultGraphRounds + 1
+	if from < 0 {
+		from = 0
+	}
+	if param := q.Get("from"); param != "" {
+		var err error
+		if from, err = strconv.Atoi(param); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	graph, err := s.node.ExportGraph(from, to)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch format := q.Get("format"); format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(graph)
+	case "dot":
+		w.Header().Set("Content-Type", "text/vnd.graphviz")
+		graph.WriteDOT(w)
+	default:
+		http.Error(w, "Unknown format "+format, http.StatusBadRequest)
+	}
+}
+
+//GetBlock returns one of the last Blocks committed by the node, with the
+//signatures of the validators that clients check to verify its finality
+func (s *Service) GetBlock(w http.ResponseWriter, r *http.Request) {
+	index, err := strconv.Atoi(mux.Vars(r)["index"])
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	block, err := s.node.GetBlock(index)
+	if common.Is(err, common.KeyNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(block)
+}
+
+//GetMetrics serves the metrics of the node to Prometheus, if they are enabled
+func (s *Service) GetMetrics(w http.ResponseWriter, r *http.Request) {
+	registry := s.node.Metrics()
+	if registry == nil {
+		http.Error(w, "Metrics are disabled", http.StatusNotFound)
+		return
+	}
+	registry.ServeHTTP(w, r)
+}
+
+//GetConnectivity returns the links of the node with its peers and, if they
+//are shared, the links of the other nodes
+func (s *Service) GetConnectivity(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.node.Connectivity())
+}
+
+//GetTraffic returns the bytes and Events exchanged with every peer
+func (s *Service) GetTraffic(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.node.Traffic())
+}
+
+//GetDuties returns the participation of every validator over the last rounds
+func (s *Service) GetDuties(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.node.ValidatorDuties())
+}
+
+//GetCapabilities returns the features supported by the node and its peers
+func (s *Service) GetCapabilities(w http.ResponseWriter, r *http.Request) {
+	res := make(map[string]string)
+	for addr, caps := range s.node.Capabilities() {
+		res[addr] = caps.String()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(res)
+}
+
+//GetCloneInfo returns what a new observer needs to clone the node
+func (s *Service) GetCloneInfo(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.node.CloneInfo())
+}
+
+//maximum size of a transaction submitted through the service
+const maxSubmitSize = 64 * 1024 * 1024
+
+//TxResponse is returned by SubmitTx and GetTx
+type TxResponse struct {
+	Hash      string
+	Committed bool
+	Time      time.Time
+	Watermark int //committed watermark from which nodes reflect the transaction, once Committed
+}
+
+//submitContentType is the only Content-Type accepted by SubmitTx. Browsers
+//can not send it to another origin without a preflight request, which the
+//Service does not allow for SubmitTx, so web pages can not submit transactions
+//on behalf of their visitors.
+const submitContentType = "application/octet-stream"
+
+//SubmitTx submits the body of the request as a transaction
+func (s *Service) SubmitTx(w http.ResponseWriter, r *http.Request) {
+	if ct := r.Header.Get("Content-Type"); ct != submitContentType {
+		http.Error(w, fmt.Sprintf("Content-Type must be %s", submitContentType), http.StatusUnsupportedMediaType)
+		return
+	}
+	if addr, ok := s.redirect(); ok {
+		s.logger.WithField("to", addr).Debug("Redirecting transaction")
+		http.Redirect(w, r, "http://"+addr+"/SubmitTx", http.StatusTemporaryRedirect)
+		return
+	}
+
+	tx, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxSubmitSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(tx) == 0 {
+		http.Error(w, "Empty transaction", http.StatusBadRequest)
+		return
+	}
+
+	hash, err := s.node.SubmitTx(tx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.logger.WithField("hash", hash).Debug("Transaction submitted through service")
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(TxResponse{Hash: hash})
+}
+
+//redirect returns the service of a healthier node, if the node of this
+//Service should not take transactions
+func (s *Service) redirect() (string, bool) {
+	if s.router == nil {
+		return "", false
+	}
+	status := s.node.Status()
+	lastRound := -1
+	if status.LastConsensusRound != nil {
+		lastRound = *status.LastConsensusRound
+	}
+	if status.State == node.Babbling.String() && !status.DiskPressure && s.router.MaxRound()-lastRound <= s.maxLag {
+		return "", false
+	}
+	//the ranking may be older than the status of this node
+	best, err := s.router.Healthiest()
+	if err != nil || best == s.bindAddress {
+		return "", false
+	}
+	return best, true
+}
+
+//GetTx reports whether a transaction submitted with SubmitTx was committed
+func (s *Service) GetTx(w http.ResponseWriter, r *http.Request) {
+	hash := strings.TrimPrefix(strings.ToUpper(mux.Vars(r)["hash"]), "0X")
+	committed, ok := s.node.TxStatus(hash)
+	watermark, _ := s.node.TxWatermark(hash)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(TxResponse{
+		Hash:      hash,
+		Committed: ok,
+		Time:      committed,
+		Watermark: watermark,
+	})
+}
+
+//commitFilter reads the filter of a subscription from the query: prefix, in
+//hex, submitter and app, and the index of the Block to start from, -1 if not
+//given
+func commitFilter(r *http.Request) (node.CommitFilter, int, error) {
+	q := r.URL.Query()
+	filter := node.CommitFilter{
+		Submitter: q.Get("submitter"),
+		AppID:     q.Get("app"),
+	}
+	if prefix := q.Get("prefix"); prefix != "" {
+		var err error
+		if filter.Prefix, err = hex.DecodeString(strings.TrimPrefix(prefix, "0x")); err != nil {
+			return filter, -1, fmt.Errorf("Invalid prefix: %s", err)
+		}
+	}
+	from := -1
+	if param := q.Get("from"); param != "" {
+		var err error
+		if from, err = strconv.Atoi(param); err != nil || from < 0 {
+			return filter, -1, fmt.Errorf("Invalid from: %s", param)
+		}
+	}
+	return filter, from, nil
+}
+
+//subscribe subscribes to the Blocks committed since from, or from now on if it
+//is -1
+func (s *Service) subscribe(filter node.CommitFilter, from int) (*node.Subscription, error) {
+	if from < 0 {
+		return s.node.Subscribe(filter)
+	}
+	return s.node.SubscribeFrom(filter, from)
+}
+
+//subscribeError responds with the error of subscribe
+func subscribeError(w http.ResponseWriter, err error) {
+	code := http.StatusBadRequest
+	if err == node.ErrResumeTooOld {
+		code = http.StatusGone
+	}
+	http.Error(w, err.Error(), code)
+}
+
+//Subscribe streams the Blocks committed from now on, one JSON object per line,
+//with the transactions that match the filter of the query: prefix, in hex,
+//submitter and app. With from, it starts with the Blocks committed since that
+//index. The stream ends when the client disconnects, or when it falls too far
+//behind.
+func (s *Service) Subscribe(w http.ResponseWriter, r *http.Request) {
+	filter, from, err := commitFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+	sub, err := s.subscribe(filter, from)
+	if err != nil {
+		subscribeError(w, err)
+		return
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	enc := json.NewEncoder(w)
+	for {
+		select {
+		case block, ok := <-sub.C:
+			if !ok {
+				s.logger.Debug("Dropped slow subscriber")
+				return
+			}
+			if err := enc.Encode(block); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+//SubscribeWebSocket streams the Blocks committed by the node over a WebSocket,
+//one JSON text message per Block, in consensus order, with the query of
+//Subscribe. A client that falls behind is resumed from the Block after the last
+//one it received, as long as the node still holds it; otherwise the WebSocket
+//is closed, and the client reconnects with from.
+func (s *Service) SubscribeWebSocket(w http.ResponseWriter, r *http.Request) {
+	filter, from, err := commitFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	//the Block after the last one sent, or committed before subscribing
+	next := from
+	if from < 0 {
+		next = s.node.CommittedWatermark() + 1
+	}
+	sub, err := s.subscribe(filter, from)
+	if err != nil {
+		subscribeError(w, err)
+		return
+	}
+	defer func() { sub.Close() }()
+
+	ws, err := upgradeWebSocket(w, r)
+	if err != nil {
+		s.logger.WithField("error", err).Debug("WebSocket handshake")
+		return
+	}
+	for {
+		select {
+		case block, ok := <-sub.C:
+			if !ok {
+				s.logger.WithField("from", next).Debug("Resuming slow subscriber")
+				resumed, err := s.node.SubscribeFrom(filter, next)
+				if err != nil {
+					ws.close(wsCloseInternal, err.Error())
+					return
+				}
+				sub = resumed
+				continue
+			}
+			data, err := json.Marshal(block)
+			if err == nil {
+				err = ws.writeText(data)
+			}
+			if err != nil {
+				ws.close(wsCloseInternal, err.Error())
+				return
+			}
+			next = block.Index + 1
+		case <-ws.done():
+			ws.close(wsCloseNormal, "")
+			return
+		}
+	}
+}
+
 //------------------------------------------------------------------------------
 
 type CORSServer struct {
@@ -50,7 +505,8 @@ type CORSServer struct {
 }
 
 func (s *CORSServer) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
-	if origin := req.Header.Get("Origin"); origin != "" {
+	//transactions are not submitted from other origins, see SubmitTx
+	if origin := req.Header.Get("Origin"); origin != "" && req.URL.Path != "/SubmitTx" {
 		rw.Header().Set("Access-Control-Allow-Origin", origin)
 		rw.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
 		rw.Header().Set("Access-Control-Allow-Headers",
@@ -0,0 +1,245 @@
+package service
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+The service speaks just enough of the WebSocket protocol (RFC 6455) to stream
+data to its clients, without depending on a WebSocket library:
+
+ - the server side of the opening handshake,
+ - unfragmented text messages from the server,
+ - the close handshake and pings from the client, which are answered.
+
+The messages that clients send are read and discarded.
+*/
+
+//wsGUID is appended to the key of the client to compute Sec-WebSocket-Accept
+const wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	wsText  = 0x1
+	wsClose = 0x8
+	wsPing  = 0x9
+	wsPong  = 0xA
+
+	//status codes of close frames
+	wsCloseNormal   = 1000
+	wsCloseInternal = 1011
+
+	//longest time a write to the client may take
+	wsWriteTimeout = 10 * time.Second
+	//largest payload of a control frame
+	wsMaxControl = 125
+)
+
+var errNotWebSocket = errors.New("Not a WebSocket handshake")
+
+//wsConn is the server side of a WebSocket
+type wsConn struct {
+	conn      net.Conn
+	r         *bufio.Reader
+	writeLock sync.Mutex
+	closed    bool
+	doneCh    chan struct{} //closed once the client closed the WebSocket or the connection failed
+}
+
+//headerHas is true if the comma-separated header name of r contains token
+func headerHas(r *http.Request, name, token string) bool {
+	for _, v := range r.Header[name] {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//upgradeWebSocket answers the opening handshake of a WebSocket and takes over
+//the connection. The client gets an HTTP error if the request is not a
+//WebSocket handshake.
+func upgradeWebSocket(w http.ResponseWriter, r *http.Request) (*wsConn, error) {
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if r.Method != "GET" ||
+		!headerHas(r, "Connection", "upgrade") ||
+		!headerHas(r, "Upgrade", "websocket") ||
+		r.Header.Get("Sec-WebSocket-Version") != "13" ||
+		key == "" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, errNotWebSocket.Error(), http.StatusBadRequest)
+		return nil, errNotWebSocket
+	}
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "WebSocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("ResponseWriter can not be hijacked")
+	}
+	conn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + wsGUID))
+	conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Sec-WebSocket-Accept: "+base64.StdEncoding.EncodeToString(sum[:])+"\r\n\r\n"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	ws := &wsConn{
+		conn:   conn,
+		r:      brw.Reader,
+		doneCh: make(chan struct{}),
+	}
+	go ws.readLoop()
+	return ws, nil
+}
+
+//done is closed once the client is gone
+func (ws *wsConn) done() <-chan struct{} {
+	return ws.doneCh
+}
+
+//writeFrame sends a frame of the given opcode, unmasked as the frames of
+//servers must be
+func (ws *wsConn) writeFrame(opcode byte, payload []byte) error {
+	ws.writeLock.Lock()
+	defer ws.writeLock.Unlock()
+	if ws.closed {
+		return io.ErrClosedPipe
+	}
+
+	header := []byte{0x80 | opcode, 0, 0, 0, 0, 0, 0, 0, 0, 0}
+	n := 2
+	switch l := len(payload); {
+	case l < 126:
+		header[1] = byte(l)
+	case l <= 0xFFFF:
+		header[1] = 126
+		binary.BigEndian.PutUint16(header[2:], uint16(l))
+		n = 4
+	default:
+		header[1] = 127
+		binary.BigEndian.PutUint64(header[2:], uint64(l))
+		n = 10
+	}
+	ws.conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+	if _, err := ws.conn.Write(append(header[:n], payload...)); err != nil {
+		return err
+	}
+	if opcode == wsClose {
+		ws.closed = true
+	}
+	return nil
+}
+
+//writeText sends a text message
+func (ws *wsConn) writeText(data []byte) error {
+	return ws.writeFrame(wsText, data)
+}
+
+//close starts the close handshake with a status code and a reason, and closes
+//the connection once the client answered or left
+func (ws *wsConn) close(code int, reason string) {
+	if len(reason) > wsMaxControl-2 {
+		reason = reason[:wsMaxControl-2]
+	}
+	payload := make([]byte, 2, 2+len(reason))
+	binary.BigEndian.PutUint16(payload, uint16(code))
+	ws.writeFrame(wsClose, append(payload, reason...))
+
+	select {
+	case <-ws.doneCh:
+	case <-time.After(wsWriteTimeout):
+	}
+	ws.conn.Close()
+}
+
+//readLoop reads the frames of the client until it closes the WebSocket,
+//answering its pings
+func (ws *wsConn) readLoop() {
+	defer close(ws.doneCh)
+	for {
+		opcode, payload, err := ws.readFrame()
+		if err != nil {
+			return
+		}
+		switch opcode {
+		case wsPing:
+			ws.writeFrame(wsPong, payload)
+		case wsClose:
+			//echo the status code, as the close handshake wants
+			if len(payload) > 2 {
+				payload = payload[:2]
+			}
+			ws.writeFrame(wsClose, payload)
+			return
+		}
+	}
+}
+
+//readFrame reads a frame of the client. The payloads of control frames are
+//returned unmasked, the others are discarded.
+func (ws *wsConn) readFrame() (byte, []byte, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(ws.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(ws.r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	var mask [4]byte
+	if masked {
+		if _, err := io.ReadFull(ws.r, mask[:]); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	if opcode < wsClose {
+		_, err := io.CopyN(ioutil.Discard, ws.r, int64(length))
+		return opcode, nil, err
+	}
+	if length > wsMaxControl {
+		return 0, nil, errors.New("WebSocket control frame too large")
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(ws.r, payload); err != nil {
+		return 0, nil, err
+	}
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+	return opcode, payload, nil
+}